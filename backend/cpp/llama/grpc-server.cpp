@@ -10,6 +10,7 @@
 #include <iostream>
 #include <memory>
 #include <string>
+#include <fstream>
 #include <getopt.h>
 #include "clip.h"
 #include "llava.h"
@@ -28,6 +29,7 @@
 #include <mutex>
 #include <chrono>
 #include <regex>
+#include <cmath>
 #include <condition_variable>
 #include <grpcpp/ext/proto_server_reflection_plugin.h>
 #include <grpcpp/grpcpp.h>
@@ -153,6 +155,7 @@ static json probs_vector_to_json(const llama_context *ctx, const std::vector<com
         std::string tok_str = tokens_to_output_formatted_string(ctx, prob.tok);
         out.push_back(json{
             {"content", tok_str},
+            {"prob",    prob.prob},
             {"probs",   probs_for_token},
         });
     }
@@ -406,6 +409,8 @@ struct llama_server_context
     bool clean_kv_cache     = true;
     bool all_slots_are_idle = false;
     bool add_bos_token      = true;
+    // LOCALAI: shift the context when it is exhausted instead of releasing the slot
+    bool context_shift      = false;
 
     int32_t n_ctx;  // total context for all clients / slots
 
@@ -623,6 +628,7 @@ struct llama_server_context
  
         slot->params.stream             = json_value(data, "stream",            false);
         slot->params.cache_prompt       = json_value(data, "cache_prompt",      false);
+        slot->params.raw                = json_value(data, "raw",               false);
         slot->params.n_predict          = json_value(data, "n_predict",         default_params.n_predict);
         slot->sparams.top_k             = json_value(data, "top_k",             default_sparams.top_k);
         slot->sparams.top_p             = json_value(data, "top_p",             default_sparams.top_p);
@@ -1418,6 +1424,32 @@ struct llama_server_context
         return true;
     }
 
+    // request_state saves or restores the sessions of the slots in the directory path, in the loop of the tasks
+    void request_state(int task_id, task_type type, const std::string &path)
+    {
+        task_server task;
+        task.id = task_id;
+        task.type = type;
+        task.data = { {"path", path} };
+        queue_tasks.post(task);
+    }
+
+    static std::string slot_state_file(const std::string &path, int slot_id)
+    {
+        return path + "/slot-" + std::to_string(slot_id) + ".bin";
+    }
+
+    void send_state_result(task_server &task, int n_slots)
+    {
+        task_result res;
+        res.id = task.id;
+        res.multitask_id = task.multitask_id;
+        res.stop = true;
+        res.error = false;
+        res.result_json = { { "n_slots", n_slots } };
+        queue_results.send(res);
+    }
+
     void request_cancel(int task_id)
     {
         task_server task;
@@ -1513,6 +1545,55 @@ struct llama_server_context
             case TASK_TYPE_NEXT_RESPONSE: {
                 // do nothing
             } break;
+            case TASK_TYPE_SLOT_SAVE: {
+                // the idle slots with a session are saved, except the multimodal ones whose images are not in the
+                // cached tokens
+                const std::string path = task.data.at("path");
+                int n_saved = 0;
+                for (llama_client_slot &slot : slots)
+                {
+                    if (!slot.available() || slot.cache_tokens.empty() || !slot.images.empty())
+                    {
+                        continue;
+                    }
+                    const std::string filename = slot_state_file(path, slot.id);
+                    if (llama_state_seq_save_file(ctx, filename.c_str(), slot.id, slot.cache_tokens.data(), slot.cache_tokens.size()) == 0)
+                    {
+                        send_error(task, "failed to save the state of slot " + std::to_string(slot.id));
+                        return;
+                    }
+                    n_saved++;
+                }
+                LOG_TEE("saved the state of %d slots in %s\n", n_saved, path.c_str());
+                send_state_result(task, n_saved);
+            } break;
+            case TASK_TYPE_SLOT_RESTORE: {
+                const std::string path = task.data.at("path");
+                int n_restored = 0;
+                for (llama_client_slot &slot : slots)
+                {
+                    const std::string filename = slot_state_file(path, slot.id);
+                    if (!slot.available() || !std::ifstream(filename).good())
+                    {
+                        continue;
+                    }
+                    std::vector<llama_token> tokens(slot.n_ctx);
+                    size_t n_tokens = 0;
+                    if (llama_state_seq_load_file(ctx, filename.c_str(), slot.id, tokens.data(), tokens.size(), &n_tokens) == 0)
+                    {
+                        // e.g. the state of another model, or of a larger context
+                        LOG_TEE("slot %d - failed to restore the state from %s\n", slot.id, filename.c_str());
+                        continue;
+                    }
+                    tokens.resize(n_tokens);
+                    slot.cache_tokens = tokens;
+                    slot.n_past = 0;
+                    slot.n_past_se = 0;
+                    n_restored++;
+                }
+                LOG_TEE("restored the state of %d slots from %s\n", n_restored, path.c_str());
+                send_state_result(task, n_restored);
+            } break;
         }
     }
 
@@ -1567,6 +1648,31 @@ struct llama_server_context
                 if (slot.is_processing() && system_tokens.size() + slot.cache_tokens.size() >= (size_t) slot.n_ctx)
                 {
                     // START LOCALAI changes
+                    // Opt-in context shifting (StreamingLLM-style): the first n_keep tokens are kept as attention sinks,
+                    // half of the others are discarded and the KV cache of the remaining ones is shifted.
+                    // Generations without token limit are still released once they produced a whole context,
+                    // as they could loop forever otherwise.
+                    if (context_shift && (slot.params.n_predict >= 0 || slot.n_decoded < slot.n_ctx))
+                    {
+                        const int n_keep    = slot.params.n_keep + add_bos_token;
+                        const int n_left    = (int) system_tokens.size() + slot.n_past - n_keep;
+                        const int n_discard = n_left / 2;
+
+                        LOG_TEE("slot %d: context shift - n_keep = %d, n_left = %d, n_discard = %d\n", slot.id, n_keep, n_left, n_discard);
+                        llama_kv_cache_seq_rm (ctx, slot.id, n_keep            , n_keep + n_discard);
+                        llama_kv_cache_seq_add(ctx, slot.id, n_keep + n_discard, system_tokens.size() + slot.n_past, -n_discard);
+
+                        for (size_t i = n_keep + n_discard; i < slot.cache_tokens.size(); i++)
+                        {
+                            slot.cache_tokens[i - n_discard] = slot.cache_tokens[i];
+                        }
+                        slot.cache_tokens.resize(slot.cache_tokens.size() - n_discard);
+
+                        slot.n_past -= n_discard;
+                        slot.truncated = true;
+                        continue;
+                    }
+
                     // Temporary disable context-shifting as it can lead to infinite loops (issue: https://github.com/ggerganov/llama.cpp/issues/3969)
                     // See: https://github.com/mudler/LocalAI/issues/1333
                     // Context is exhausted, release the slot
@@ -1678,7 +1784,7 @@ struct llama_server_context
                     }
                     else
                     {
-                        prompt_tokens = tokenize(slot.prompt, system_prompt.empty() && add_bos_token);  // add BOS if there isn't system prompt
+                        prompt_tokens = tokenize(slot.prompt, !slot.params.raw && system_prompt.empty() && add_bos_token);  // add BOS if there isn't system prompt, unless the prompt is raw
                     }
 
                     slot.num_prompt_tokens = prompt_tokens.size();
@@ -1961,6 +2067,16 @@ struct llama_server_context
                     result.probs.push_back({cur_p.data[i].id, cur_p.data[i].p});
                 }
 
+                // the sampled token isn't always among the n_probs most likely ones
+                for (size_t i = 0; n_probs > 0 && i < cur_p.size; ++i)
+                {
+                    if (cur_p.data[i].id == id)
+                    {
+                        result.prob = cur_p.data[i].p;
+                        break;
+                    }
+                }
+
                 if (!process_token(result, slot))
                 {
                     slot.release();
@@ -2110,6 +2226,7 @@ json parse_options(bool streaming, const backend::PredictOptions* predict, llama
     data["grammar"] = predict->grammar();
     data["prompt"] = predict->prompt();
     data["ignore_eos"] = predict->ignoreeos();
+    data["raw"] = predict->raw();
     data["embeddings"] = predict->embeddings();
 
     // for each image in the request, add the image data
@@ -2123,12 +2240,38 @@ json parse_options(bool streaming, const backend::PredictOptions* predict, llama
     }
 
     data["stop"] = predict->stopprompts();
-    // data["n_probs"] = predict->nprobs();
+    // the log probabilities need the probability of the sampled token at least
+    data["n_probs"] = predict->logprobs() ? std::max(predict->toplogprobs(), 1) : 0;
     //TODO: images,
 
     return data;
 }
 
+// log of a probability, -9999 for the impossible tokens as OpenAI
+static float to_logprob(float prob)
+{
+    return prob > 0 ? std::log(prob) : -9999.0f;
+}
+
+// add to the reply the log probabilities of the completion_probabilities of a result, with the top_logprobs most
+// likely tokens for each token
+static void add_logprobs(backend::Reply *reply, const json &probs, int top_logprobs)
+{
+    for (const auto &prob : probs)
+    {
+        backend::TokenLogprob *logprob = reply->add_logprobs();
+        logprob->set_token(prob.value("content", ""));
+        logprob->set_logprob(to_logprob(prob.value("prob", 0.0f)));
+        const json candidates = prob.value("probs", json::array());
+        for (size_t i = 0; i < std::min(candidates.size(), (size_t)top_logprobs); ++i)
+        {
+            backend::TokenLogprob *top = logprob->add_top_logprobs();
+            top->set_token(candidates[i].value("tok_str", ""));
+            top->set_logprob(to_logprob(candidates[i].value("prob", 0.0f)));
+        }
+    }
+}
+
 // static void parse_options_completion(bool streaming,const backend::PredictOptions* predict, llama_server_context &llama)
 // {
 //     // https://github.com/ggerganov/llama.cpp/blob/d9b33fe95bd257b36c84ee5769cc048230067d6f/examples/server/server.cpp#L673
@@ -2253,6 +2396,13 @@ static void params_parse(const backend::ModelOptions* request,
     if (!request->maingpu().empty()) {
         params.main_gpu = std::stoi(request->maingpu());
     }
+    if (request->numastrategy() == "isolate") {
+        params.numa = GGML_NUMA_STRATEGY_ISOLATE;
+    } else if (request->numastrategy() == "numactl") {
+        params.numa = GGML_NUMA_STRATEGY_NUMACTL;
+    } else if (request->numa()) {
+        params.numa = GGML_NUMA_STRATEGY_DISTRIBUTE;
+    }
     if (!request->loraadapter().empty() && !request->lorabase().empty()) {
      float scale_factor = 1.0f;
      if (request->lorascale() != 0.0f) {
@@ -2266,6 +2416,12 @@ static void params_parse(const backend::ModelOptions* request,
     params.use_mmap = request->mmap();
     params.flash_attn = request->flashattention();
     params.no_kv_offload = request->nokvoffload();
+    if (!request->cachetypekey().empty()) {
+        params.cache_type_k = request->cachetypekey();
+    }
+    if (!request->cachetypevalue().empty()) {
+        params.cache_type_v = request->cachetypevalue();
+    }
 
     params.embedding = request->embeddings();
 
@@ -2306,6 +2462,7 @@ public:
     // Implement LoadModel RPC
     gpt_params params;
     params_parse(request, params);
+    llama.context_shift = request->contextshift();
 
     llama_backend_init();
     llama_numa_init(params.numa);
@@ -2349,6 +2506,10 @@ public:
                 reply.set_tokens(tokens_predicted);
                 int32_t tokens_evaluated = result.result_json.value("tokens_evaluated", 0);
                 reply.set_prompt_tokens(tokens_evaluated);
+                // the final result repeats the log probabilities of all the tokens streamed
+                if (!result.stop && result.result_json.contains("completion_probabilities")) {
+                    add_logprobs(&reply, result.result_json["completion_probabilities"], request->toplogprobs());
+                }
 
                 // Send the reply
                 writer->Write(reply);
@@ -2379,6 +2540,9 @@ public:
             reply->set_prompt_tokens(tokens_evaluated);
             reply->set_tokens(tokens_predicted);
             reply->set_message(completion_text);
+            if (result.result_json.contains("completion_probabilities")) {
+                add_logprobs(reply, result.result_json["completion_probabilities"], request->toplogprobs());
+            }
         }
         else
         {
@@ -2412,6 +2576,34 @@ public:
 
         return grpc::Status::OK;
     }
+
+    grpc::Status SaveState(ServerContext* context, const backend::StateRequest* request, backend::Result* result) {
+        return state_task(TASK_TYPE_SLOT_SAVE, request, result);
+    }
+
+    grpc::Status LoadState(ServerContext* context, const backend::StateRequest* request, backend::Result* result) {
+        return state_task(TASK_TYPE_SLOT_RESTORE, request, result);
+    }
+
+private:
+    grpc::Status state_task(task_type type, const backend::StateRequest* request, backend::Result* result) {
+        if (!loaded_model) {
+            return grpc::Status(grpc::StatusCode::FAILED_PRECONDITION, "no model loaded");
+        }
+        const int task_id = llama.queue_tasks.get_new_id();
+        llama.queue_results.add_waiting_task_id(task_id);
+        llama.request_state(task_id, type, request->path());
+        task_result res = llama.queue_results.recv(task_id);
+        llama.queue_results.remove_waiting_task_id(task_id);
+        if (res.error) {
+            result->set_message(res.result_json.value("content", ""));
+            result->set_success(false);
+            return grpc::Status::OK;
+        }
+        result->set_message(std::to_string(res.result_json.value("n_slots", 0)) + " slots");
+        result->set_success(true);
+        return grpc::Status::OK;
+    }
 };
 
 void RunServer(const std::string& server_address) {
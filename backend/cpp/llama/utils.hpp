@@ -51,7 +51,9 @@ enum server_state {
 enum task_type {
     TASK_TYPE_COMPLETION,
     TASK_TYPE_CANCEL,
-    TASK_TYPE_NEXT_RESPONSE
+    TASK_TYPE_NEXT_RESPONSE,
+    TASK_TYPE_SLOT_SAVE,
+    TASK_TYPE_SLOT_RESTORE
 };
 
 struct task_server {
@@ -96,6 +98,7 @@ struct slot_params
 {
     bool stream       = true;
     bool cache_prompt = false; // remember the prompt to avoid reprocessing all prompt
+    bool raw          = false; // tokenize the prompt as is, without adding the BOS token
 
     uint32_t seed      = -1; // RNG seed
     int32_t  n_keep    =  0; // number of tokens to keep from initial prompt
@@ -131,6 +134,7 @@ struct completion_token_output
 
     std::vector<token_prob> probs;
     llama_token tok;
+    float prob = 0.0f; // the probability of the sampled token, when the probabilities are requested
     std::string text_to_send;
 };
 
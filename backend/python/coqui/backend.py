@@ -11,6 +11,7 @@ import os
 import backend_pb2
 import backend_pb2_grpc
 
+import numpy as np
 import torch
 from TTS.api import TTS
 
@@ -85,6 +86,30 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
             return backend_pb2.Result(success=False, message=f"Unexpected {err=}, {type(err)=}")
         return backend_pb2.Result(success=True)
 
+    def TTSStream(self, request, context):
+        """
+        Synthesizes the text sentence by sentence, yielding the PCM16 audio of each sentence as soon as it's ready
+        """
+        lang = request.language or COQUI_LANGUAGE
+        if lang == "":
+            lang = None
+        if self.tts.is_multi_lingual and lang is None:
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "Model is multi-lingual, but no language was provided")
+        if self.tts.is_multi_speaker and self.AudioPath is None and not request.voice:
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "Model is multi-speaker, but no speaker was provided")
+
+        if self.tts.is_multi_speaker and request.voice:
+            speaker = {"speaker": request.voice}
+        else:
+            speaker = {"speaker_wav": self.AudioPath}
+        synthesizer = self.tts.synthesizer
+        for sentence in synthesizer.split_into_sentences(request.text):
+            if not context.is_active():
+                return
+            wav = self.tts.tts(text=sentence, language=lang, split_sentences=False, **speaker)
+            audio = (np.clip(np.array(wav), -1, 1) * 32767).astype(np.int16)
+            yield backend_pb2.TTSChunk(audio=audio.tobytes(), sample_rate=synthesizer.output_sample_rate)
+
 def serve(address):
     server = grpc.server(futures.ThreadPoolExecutor(max_workers=MAX_WORKERS))
     backend_pb2_grpc.add_BackendServicer_to_server(BackendServicer(), server)
@@ -78,5 +78,27 @@ class TestBackendServicer(unittest.TestCase):
         except Exception as err:
             print(err)
             self.fail("TTS service failed")
+        finally:
+            self.tearDown()
+
+    def test_tts_stream(self):
+        """
+        This method tests if the audio is streamed successfully
+        """
+        try:
+            self.setUp()
+            with grpc.insecure_channel("localhost:50051") as channel:
+                stub = backend_pb2_grpc.BackendStub(channel)
+                response = stub.LoadModel(backend_pb2.ModelOptions(Model="tts_models/en/vctk/vits"))
+                self.assertTrue(response.success)
+                tts_request = backend_pb2.TTSRequest(text="This is the first sentence. This is the second one.", voice="p225")
+                chunks = list(stub.TTSStream(tts_request))
+                self.assertEqual(len(chunks), 2)
+                for chunk in chunks:
+                    self.assertGreater(len(chunk.audio), 0)
+                    self.assertGreater(chunk.sample_rate, 0)
+        except Exception as err:
+            print(err)
+            self.fail("TTSStream service failed")
         finally:
             self.tearDown()
\ No newline at end of file
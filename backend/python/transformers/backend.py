@@ -80,6 +80,7 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
 
         self.CUDA = torch.cuda.is_available()
         self.OV=False
+        self.Seq2Seq=False
 
         device_map="cpu"
 
@@ -186,6 +187,20 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
                                                                 export=True,
                                                                 device=device_map)
                 self.OV = True
+            elif request.Type == "AutoModelForSeq2SeqLM":
+                from transformers import AutoModelForSeq2SeqLM
+                self.model = AutoModelForSeq2SeqLM.from_pretrained(model_name,
+                                                                   trust_remote_code=request.TrustRemoteCode,
+                                                                   quantization_config=quantization,
+                                                                   device_map=device_map,
+                                                                   torch_dtype=compute)
+                self.Seq2Seq = True
+            elif request.Type == "AutoModelForSequenceClassification":
+                from transformers import AutoModelForSequenceClassification
+                self.model = AutoModelForSequenceClassification.from_pretrained(model_name,
+                                                                                trust_remote_code=request.TrustRemoteCode,
+                                                                                device_map=device_map,
+                                                                                torch_dtype=compute)
             else:
                 print("Automodel", file=sys.stderr)
                 self.model = AutoModel.from_pretrained(model_name, 
@@ -247,6 +262,37 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
         sentence_embeddings = mean_pooling(model_output, encoded_input['attention_mask'])
         return backend_pb2.EmbeddingResult(embeddings=sentence_embeddings[0])
 
+    def Classify(self, request, context):
+        """
+        A gRPC method that scores the labels of texts with a sequence classification model.
+
+        Args:
+            request: A ClassifyRequest object that contains the texts to classify.
+            context: A grpc.ServicerContext object that provides information about the RPC.
+
+        Returns:
+            A ClassifyResult object that contains the scores of the labels of each text.
+        """
+        encoded_input = self.tokenizer(list(request.texts), padding=True, truncation=True, return_tensors="pt")
+        if self.CUDA:
+            encoded_input = encoded_input.to("cuda")
+
+        with torch.no_grad():
+            logits = self.model(**encoded_input).logits
+
+        # the multi-label models score each label independently, the others share the probability between them
+        if self.model.config.problem_type == "multi_label_classification":
+            scores = torch.sigmoid(logits)
+        else:
+            scores = torch.softmax(logits, dim=-1)
+
+        id2label = self.model.config.id2label
+        classifications = []
+        for text_scores in scores.tolist():
+            labels = [backend_pb2.LabelScore(label=id2label[i], score=score) for i, score in enumerate(text_scores)]
+            classifications.append(backend_pb2.Classification(labels=labels))
+        return backend_pb2.ClassifyResult(classifications=classifications)
+
     async def _predict(self, request, context, streaming=False): 
         set_seed(request.Seed)
         if request.TopP < 0 or request.TopP > 1:
@@ -267,7 +313,26 @@ class BackendServicer(backend_pb2_grpc.BackendServicer):
         if not request.Prompt and request.UseTokenizerTemplate and request.Messages:    
             prompt = self.tokenizer.apply_chat_template(request.Messages, tokenize=False, add_generation_prompt=True)
 
-        inputs = self.tokenizer(prompt, return_tensors="pt")
+        if self.Seq2Seq:
+            # the translation models (NLLB, M2M100) translate the prompt from the source into the target language
+            if request.SourceLanguage:
+                self.tokenizer.src_lang = request.SourceLanguage
+            inputs = self.tokenizer(prompt, return_tensors="pt")
+            if self.CUDA:
+                inputs = inputs.to("cuda")
+            config = dict(inputs, max_new_tokens=request.Tokens if request.Tokens > 0 else self.max_tokens)
+            if request.TargetLanguage:
+                if hasattr(self.tokenizer, "get_lang_id"):
+                    config["forced_bos_token_id"] = self.tokenizer.get_lang_id(request.TargetLanguage)
+                else:
+                    config["forced_bos_token_id"] = self.tokenizer.convert_tokens_to_ids(request.TargetLanguage)
+            outputs = self.model.generate(**config)
+            generated_text = self.tokenizer.batch_decode(outputs, skip_special_tokens=True)[0]
+            yield backend_pb2.Reply(message=bytes(generated_text, encoding='utf-8'))
+            return
+
+        # raw prompts are tokenized as they are, without the special tokens (BOS, EOS) of the tokenizer
+        inputs = self.tokenizer(prompt, return_tensors="pt", add_special_tokens=not request.Raw)
 
         if request.Tokens > 0:
             max_tokens = request.Tokens
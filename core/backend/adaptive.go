@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/rs/zerolog/log"
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+const (
+	// cpuSaturation is the CPU usage (percent) above which the requests get even fewer threads
+	cpuSaturation     = 90
+	cpuSampleInterval = 2 * time.Second
+
+	// defaultBatch is the batch size the models are loaded with, when not configured
+	defaultBatch = 512
+	minBatch     = 32
+)
+
+var (
+	// runningPredictions counts the requests being computed on any model, which share the CPU
+	runningPredictions atomic.Int64
+
+	// cpuUsage is the last sampled CPU usage, as math.Float64bits of the percent
+	cpuUsage       atomic.Uint64
+	cpuSamplerOnce sync.Once
+)
+
+// sampleCPU keeps cpuUsage up to date, it's started the first time the adaptive mode is used
+func sampleCPU() {
+	go func() {
+		for {
+			usage, err := cpu.Percent(cpuSampleInterval, false)
+			if err != nil || len(usage) == 0 {
+				log.Debug().Err(err).Msg("failed sampling the CPU usage, adaptive threads will ignore it")
+				return
+			}
+			cpuUsage.Store(math.Float64bits(usage[0]))
+		}
+	}()
+}
+
+// adaptThreads returns the threads and batch size of a request when running requests share the CPU:
+// the configured threads are split between them, and halved again when the CPU is saturated.
+// The batch size shrinks in the same proportion, as larger batches only help with spare cores.
+func adaptThreads(threads, batch, running int, cpuPercent float64) (int, int) {
+	if threads <= 0 {
+		return threads, batch
+	}
+	if batch <= 0 {
+		batch = defaultBatch
+	}
+
+	adapted := threads / max(running, 1)
+	if cpuPercent >= cpuSaturation && running > 1 {
+		adapted /= 2
+	}
+	adapted = max(adapted, 1)
+
+	return adapted, max(batch*adapted/threads, min(batch, minBatch))
+}
+
+// withAdaptiveThreads tunes the threads and batch size of the request on the current load,
+// the returned function has to be called once the request is complete
+func withAdaptiveThreads(opts *pb.PredictOptions) func() {
+	cpuSamplerOnce.Do(sampleCPU)
+
+	running := runningPredictions.Add(1)
+	threads, batch := adaptThreads(int(opts.Threads), int(opts.Batch), int(running), math.Float64frombits(cpuUsage.Load()))
+	if threads != int(opts.Threads) {
+		log.Debug().Int64("running", running).Int32("threads", opts.Threads).Int("adapted_threads", threads).Int("adapted_batch", batch).Msg("adapting the threads to the load")
+		opts.Threads = int32(threads)
+		opts.Batch = int32(batch)
+	}
+
+	return func() {
+		runningPredictions.Add(-1)
+	}
+}
@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+// ModelClassify scores the labels of the texts with a classifier model, one classification per text
+func ModelClassify(ctx context.Context, texts []string, loader *model.ModelLoader, appConfig *config.ApplicationConfig, backendConfig config.BackendConfig) ([]*proto.Classification, error) {
+	if backendConfig.Backend == "" {
+		return nil, fmt.Errorf("backend is required")
+	}
+
+	opts := modelOpts(backendConfig, appConfig, []model.Option{
+		model.WithBackendString(backendConfig.Backend),
+		model.WithModel(backendConfig.Model),
+		model.WithContext(appConfig.Context),
+		model.WithAssetDir(appConfig.AssetsDestination),
+		model.WithLoadGRPCLoadModelOpts(gRPCModelOpts(backendConfig)),
+	})
+	classifierModel, err := loader.BackendLoader(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if classifierModel == nil {
+		return nil, fmt.Errorf("could not load classifier model")
+	}
+
+	res, err := classifierModel.Classify(ctx, &proto.ClassifyRequest{Texts: texts})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Classifications) != len(texts) {
+		return nil, fmt.Errorf("the classifier returned %d classifications for %d texts", len(res.Classifications), len(texts))
+	}
+	return res.Classifications, nil
+}
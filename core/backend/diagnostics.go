@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// lastRequests keeps the options of the last request sent to each model, with the prompts redacted,
+// to be collected in the diagnostics when its backend crashes
+var lastRequests sync.Map
+
+func trackRequest(modelID string, opts *pb.PredictOptions) {
+	lastRequests.Store(modelID, redactPredictOptions(opts))
+}
+
+// LastRequest returns the options of the last request sent to the model, with the prompts redacted
+func LastRequest(modelID string) *pb.PredictOptions {
+	if opts, exists := lastRequests.Load(modelID); exists {
+		return opts.(*pb.PredictOptions)
+	}
+	return nil
+}
+
+func redacted(s string) string {
+	if s == "" {
+		return s
+	}
+	return fmt.Sprintf("[redacted %d characters]", len(s))
+}
+
+// redactPredictOptions returns a copy of the options without the content sent by the user
+func redactPredictOptions(opts *pb.PredictOptions) *pb.PredictOptions {
+	r := proto.Clone(opts).(*pb.PredictOptions)
+	r.Prompt = redacted(r.Prompt)
+	r.NegativePrompt = redacted(r.NegativePrompt)
+	r.Embeddings = redacted(r.Embeddings)
+	r.EmbeddingTokens = nil
+	for i := range r.Images {
+		r.Images[i] = redacted(r.Images[i])
+	}
+	for _, m := range r.Messages {
+		m.Content = redacted(m.Content)
+	}
+	return r
+}
@@ -40,6 +40,9 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendCo
 	case grpc.Backend:
 		fn = func() ([]float32, error) {
 			predictOptions := gRPCPredictOpts(backendConfig, loader.ModelPath)
+			if appConfig.AdaptiveThreads {
+				defer withAdaptiveThreads(predictOptions)()
+			}
 			if len(tokens) > 0 {
 				embeds := []int32{}
 
@@ -47,6 +50,7 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendCo
 					embeds = append(embeds, int32(t))
 				}
 				predictOptions.EmbeddingTokens = embeds
+				trackRequest(modelFile, predictOptions)
 
 				res, err := model.Embeddings(appConfig.Context, predictOptions)
 				if err != nil {
@@ -56,6 +60,7 @@ func ModelEmbedding(s string, tokens []int, loader *model.ModelLoader, backendCo
 				return res.Embeddings, nil
 			}
 			predictOptions.Embeddings = s
+			trackRequest(modelFile, predictOptions)
 
 			res, err := model.Embeddings(appConfig.Context, predictOptions)
 			if err != nil {
@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+const fileSearchChunkSize = 1000
+
+// FileSearchChunk is the value stored for every chunk of a file indexed for file_search
+type FileSearchChunk struct {
+	FileID  string `json:"file_id"`
+	Content string `json:"content"`
+}
+
+type FileSearchResult struct {
+	FileSearchChunk
+	Score float32 `json:"score"`
+}
+
+// FileSearchIngest splits the content of a file in chunks and indexes them in the given store.
+// Embeddings are always computed locally, with the embedding_model configured for the store.
+func FileSearchIngest(ctx context.Context, sl, ml *model.ModelLoader, cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig, storeName, fileID string, content []byte) error {
+	sd, err := StoreDriver(sl, appConfig, storeName)
+	if err != nil {
+		return err
+	}
+
+	keys := [][]float32{}
+	values := [][]byte{}
+	for _, chunk := range chunkText(string(content), fileSearchChunkSize) {
+		embedding, err := fileSearchEmbedding(ml, cl, appConfig, storeName, chunk)
+		if err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(FileSearchChunk{FileID: fileID, Content: chunk})
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, embedding)
+		values = append(values, value)
+	}
+
+	return sd.Set(ctx, keys, values)
+}
+
+// FileSearch returns the topK chunks of the store most similar to the query
+func FileSearch(ctx context.Context, sl, ml *model.ModelLoader, cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig, storeName, query string, topK int) ([]FileSearchResult, error) {
+	sd, err := StoreDriver(sl, appConfig, storeName)
+	if err != nil {
+		return nil, err
+	}
+
+	embedding, err := fileSearchEmbedding(ml, cl, appConfig, storeName, query)
+	if err != nil {
+		return nil, err
+	}
+
+	_, values, similarities, err := sd.Find(ctx, embedding, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []FileSearchResult{}
+	for i, v := range values {
+		r := FileSearchResult{Score: similarities[i]}
+		if err := json.Unmarshal(v, &r.FileSearchChunk); err != nil {
+			// not indexed by file_search, return the raw value
+			r.Content = string(v)
+		}
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+func fileSearchEmbedding(ml *model.ModelLoader, cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig, storeName, s string) ([]float32, error) {
+	modelName := appConfig.VectorStores[storeName].EmbeddingModel
+	if modelName == "" {
+		return nil, fmt.Errorf("no embedding_model configured for store %q", storeName)
+	}
+
+	cfg, err := cl.LoadBackendConfigFileByName(modelName, appConfig.ModelPath, appConfig.ToConfigLoaderOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, err := ModelEmbedding(s, []int{}, ml, *cfg, appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return fn()
+}
+
+// chunkText splits s in chunks of at most size characters, breaking on whitespace when possible
+func chunkText(s string, size int) []string {
+	chunks := []string{}
+	current := strings.Builder{}
+	for _, word := range strings.Fields(s) {
+		if current.Len() > 0 && current.Len()+len(word)+1 > size {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(" ")
+		}
+		current.WriteString(word)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
@@ -2,11 +2,14 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode/utf8"
 
 	"github.com/mudler/LocalAI/core/config"
@@ -17,16 +20,24 @@ import (
 	"github.com/mudler/LocalAI/pkg/grpc/proto"
 	model "github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
 )
 
 type LLMResponse struct {
 	Response string // should this be []byte?
 	Usage    TokenUsage
+	// Logprobs are the log probabilities of the tokens of the response, when requested
+	Logprobs []schema.LogprobContent
+	// Warnings tells how the settings of the model were reduced after it ran out of memory
+	Warnings []string
 }
 
 type TokenUsage struct {
 	Prompt     int
 	Completion int
+	// Logprobs are the log probabilities of the tokens output since the previous call of the token callback, when
+	// requested
+	Logprobs []schema.LogprobContent
 }
 
 func ModelInference(ctx context.Context, s string, messages []schema.Message, images []string, loader *model.ModelLoader, c config.BackendConfig, o *config.ApplicationConfig, tokenCallback func(string, TokenUsage) bool) (func() (LLMResponse, error), error) {
@@ -35,22 +46,33 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 	if *threads == 0 && o.Threads != 0 {
 		threads = &o.Threads
 	}
-	grpcOpts := gRPCModelOpts(c)
 
 	var inferenceModel grpc.Backend
 	var err error
 
-	opts := modelOpts(c, o, []model.Option{
-		model.WithLoadGRPCLoadModelOpts(grpcOpts),
-		model.WithThreads(uint32(*threads)), // some models uses this to allocate threads during startup
-		model.WithAssetDir(o.AssetsDestination),
-		model.WithModel(modelFile),
-		model.WithContext(o.Context),
-	})
+	// the model stays loaded with the settings reduced after running out of memory, the later requests use them too
+	warnings := []string{}
+	configured := c
+	if reduced, ok := loader.ReducedSettings(modelFile); ok {
+		c = applyReducedSettings(c, reduced)
+		warnings = append(warnings, fmt.Sprintf("the model %s runs with settings reduced after running out of memory: %s", c.Name, strings.Join(reduced.Changes(), ", ")))
+	}
 
-	if c.Backend != "" {
-		opts = append(opts, model.WithBackendString(c.Backend))
+	// the options are built again when the settings of the model are reduced
+	loadOpts := func() []model.Option {
+		opts := modelOpts(c, o, []model.Option{
+			model.WithLoadGRPCLoadModelOpts(gRPCModelOpts(c)),
+			model.WithThreads(uint32(*threads)), // some models uses this to allocate threads during startup
+			model.WithAssetDir(o.AssetsDestination),
+			model.WithModel(modelFile),
+			model.WithContext(o.Context),
+		})
+		if c.Backend != "" {
+			opts = append(opts, model.WithBackendString(c.Backend))
+		}
+		return opts
 	}
+	opts := loadOpts()
 
 	// Check if the modelFile exists, if it doesn't try to load it from the gallery
 	if o.AutoloadGalleries { // experimental
@@ -64,12 +86,47 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 		}
 	}
 
-	if c.Backend == "" {
-		inferenceModel, err = loader.GreedyLoader(opts...)
-	} else {
-		inferenceModel, err = loader.BackendLoader(opts...)
+	load := func() (m grpc.Backend, err error) {
+		_, span := tracer.Start(ctx, "model.load", spanAttributes(c))
+		defer func() { endSpan(span, err) }()
+		if c.Backend == "" {
+			m, err = loader.GreedyLoader(opts...)
+		} else {
+			m, err = loader.BackendLoader(opts...)
+		}
+		if err == nil {
+			if reduced, ok := reducedSettings(configured, c); ok {
+				loader.SetReducedSettings(modelFile, reduced)
+			}
+		}
+		return m, err
 	}
 
+	// reduceSettings lowers the settings of the model when its backend ran out of memory, and stops the backend to
+	// reload the model with them. It tells whether the request can be retried
+	attempts := 0
+	reduceSettings := func(err error) bool {
+		if attempts >= c.OOMRetry.MaxAttempts() || !loader.IsOutOfMemory(modelFile, err) {
+			return false
+		}
+		changes := c.OOMRetry.Reduce(&c)
+		if len(changes) == 0 {
+			return false
+		}
+		attempts++
+		log.Warn().Err(err).Str("model", c.Name).Strs("changes", changes).Msg("the backend ran out of memory, reloading the model with reduced settings")
+		warnings = append(warnings, fmt.Sprintf("the model %s ran out of memory and was reloaded with %s", c.Name, strings.Join(changes, ", ")))
+		if err := loader.ShutdownModel(modelFile); err != nil {
+			log.Debug().Err(err).Str("model", c.Name).Msg("no backend to stop after running out of memory")
+		}
+		opts = loadOpts()
+		return true
+	}
+
+	inferenceModel, err = load()
+	for err != nil && reduceSettings(err) {
+		inferenceModel, err = load()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -92,18 +149,32 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 		}
 	}
 
+	var firstTokenTimeout time.Duration
+	if c.FirstTokenTimeout != "" {
+		firstTokenTimeout, err = time.ParseDuration(c.FirstTokenTimeout)
+		if err != nil {
+			log.Warn().Err(err).Str("model", c.Name).Msg("invalid first_token_timeout, ignoring it")
+		}
+	}
+
 	// in GRPC, the backend is supposed to answer to 1 single token if stream is not supported
-	fn := func() (LLMResponse, error) {
+	predict := func(ctx context.Context, onOutput func()) (LLMResponse, error) {
 		opts := gRPCPredictOpts(c, loader.ModelPath)
 		opts.Prompt = s
 		opts.Messages = protoMessages
 		opts.UseTokenizerTemplate = c.TemplateConfig.UseTokenizerTemplate
 		opts.Images = images
+		if o.AdaptiveThreads {
+			defer withAdaptiveThreads(opts)()
+		}
+		trackRequest(modelFile, opts)
 
 		tokenUsage := TokenUsage{}
 
 		// check the per-model feature flag for usage, since tokenCallback may have a cost.
-		// Defaults to off as for now it is still experimental
+		// Defaults to off as for now it is still experimental.
+		// The callback is wrapped for each attempt, so that a retry doesn't report the usage of the previous one
+		callback := tokenCallback
 		if c.FeatureFlag.Enabled("usage") {
 			userTokenCallback := tokenCallback
 			if userTokenCallback == nil {
@@ -117,18 +188,26 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 				tokenUsage.Prompt = int(promptInfo.Length)
 			}
 
-			tokenCallback = func(token string, usage TokenUsage) bool {
+			callback = func(token string, usage TokenUsage) bool {
 				tokenUsage.Completion++
-				return userTokenCallback(token, tokenUsage)
+				usage.Prompt, usage.Completion = tokenUsage.Prompt, tokenUsage.Completion
+				return userTokenCallback(token, usage)
 			}
 		}
 
-		if tokenCallback != nil {
+		// The first token can be detected only when streaming from the backend
+		if callback != nil || firstTokenTimeout > 0 {
 			ss := ""
 
 			var partialRune []byte
-			err := inferenceModel.PredictStream(ctx, opts, func(chars []byte) {
-				partialRune = append(partialRune, chars...)
+			// the log probabilities of a reply are passed with its first rune
+			var logprobs, pendingLogprobs []schema.LogprobContent
+			err := inferenceModel.PredictStream(ctx, opts, func(reply *proto.Reply) {
+				onOutput()
+				partialRune = append(partialRune, reply.GetMessage()...)
+				replyLogprobs := logprobsFromProto(reply.GetLogprobs())
+				logprobs = append(logprobs, replyLogprobs...)
+				pendingLogprobs = append(pendingLogprobs, replyLogprobs...)
 
 				for len(partialRune) > 0 {
 					r, size := utf8.DecodeRune(partialRune)
@@ -137,7 +216,11 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 						break
 					}
 
-					tokenCallback(string(r), tokenUsage)
+					if callback != nil {
+						usage := tokenUsage
+						usage.Logprobs, pendingLogprobs = pendingLogprobs, nil
+						callback(string(r), usage)
+					}
 					ss += string(r)
 
 					partialRune = partialRune[size:]
@@ -146,6 +229,7 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 			return LLMResponse{
 				Response: ss,
 				Usage:    tokenUsage,
+				Logprobs: logprobs,
 			}, err
 		} else {
 			// TODO: Is the chicken bit the only way to get here? is that acceptable?
@@ -162,13 +246,149 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 			return LLMResponse{
 				Response: string(reply.Message),
 				Usage:    tokenUsage,
+				Logprobs: logprobsFromProto(reply.Logprobs),
 			}, err
 		}
 	}
 
+	if inferenceMetrics != nil {
+		predict = observePredict(c.Name, inferenceMetrics, predict)
+	}
+	predict = tracePredict(c, predict)
+
+	// a backend producing no output within first_token_timeout is suspected to be hung: it's recorded in the model
+	// events, restarted and the request is retried once
+	restartHung := func() error {
+		log.Warn().Str("model", c.Name).Dur("first_token_timeout", firstTokenTimeout).Msg("no output from the backend within first_token_timeout, restarting it")
+		loader.MarkHung(modelFile)
+		if err := loader.ShutdownModel(modelFile); err != nil {
+			log.Error().Err(err).Str("model", c.Name).Msg("error shutting down the suspect backend")
+		}
+		var err error
+		inferenceModel, err = load()
+		return err
+	}
+	run := func(onOutput func()) (LLMResponse, error) {
+		if firstTokenTimeout <= 0 {
+			return predict(ctx, onOutput)
+		}
+		res, err := retryHung(ctx, firstTokenTimeout, predict, onOutput, restartHung)
+		if errors.Is(err, errHung) {
+			return res, fmt.Errorf("model %s produced no output within %s", c.Name, firstTokenTimeout)
+		}
+		return res, err
+	}
+
+	fn := func() (LLMResponse, error) {
+		res, err := retryOutOfMemory(run, reduceSettings, func() (err error) {
+			inferenceModel, err = load()
+			return err
+		})
+		res.Warnings = warnings
+		return res, err
+	}
+
 	return fn, nil
 }
 
+// logprobsFromProto converts the log probabilities of the tokens of a reply, nil when there are none
+func logprobsFromProto(tokens []*proto.TokenLogprob) []schema.LogprobContent {
+	if len(tokens) == 0 {
+		return nil
+	}
+	logprobs := make([]schema.LogprobContent, len(tokens))
+	for i, t := range tokens {
+		logprobs[i] = schema.LogprobContent{TopLogprob: topLogprob(t), TopLogprobs: []schema.TopLogprob{}}
+		for _, top := range t.TopLogprobs {
+			logprobs[i].TopLogprobs = append(logprobs[i].TopLogprobs, topLogprob(top))
+		}
+	}
+	return logprobs
+}
+
+func topLogprob(t *proto.TokenLogprob) schema.TopLogprob {
+	bytes := make([]int, len(t.Token))
+	for i, b := range []byte(t.Token) {
+		bytes[i] = int(b)
+	}
+	return schema.TopLogprob{Token: t.Token, Logprob: float64(t.Logprob), Bytes: bytes}
+}
+
+// reducedSettings returns the settings of the model lower than configured, false when there are none
+func reducedSettings(configured, c config.BackendConfig) (model.ReducedSettings, bool) {
+	reduced := model.ReducedSettings{}
+	if c.NGPULayers != nil && (configured.NGPULayers == nil || *c.NGPULayers != *configured.NGPULayers) {
+		reduced.GPULayers = c.NGPULayers
+	}
+	if c.ContextSize != nil && (configured.ContextSize == nil || *c.ContextSize != *configured.ContextSize) {
+		reduced.ContextSize = c.ContextSize
+	}
+	if c.Batch != configured.Batch {
+		reduced.Batch = c.Batch
+	}
+	return reduced, len(reduced.Changes()) > 0
+}
+
+// applyReducedSettings returns the config with the settings reduced after running out of memory
+func applyReducedSettings(c config.BackendConfig, reduced model.ReducedSettings) config.BackendConfig {
+	if reduced.GPULayers != nil {
+		c.NGPULayers = reduced.GPULayers
+	}
+	if reduced.ContextSize != nil {
+		c.ContextSize = reduced.ContextSize
+	}
+	if reduced.Batch != 0 {
+		c.Batch = reduced.Batch
+	}
+	return c
+}
+
+// retryOutOfMemory runs the request, reloading the model with the settings lowered by reduce and running it again
+// as long as it fails with an error reduce accepts. The request is retried only when the backend didn't output
+// anything yet, not to stream it twice
+func retryOutOfMemory(run func(onOutput func()) (LLMResponse, error), reduce func(error) bool, reload func() error) (LLMResponse, error) {
+	output := false
+	res, err := run(func() { output = true })
+	for err != nil && !output && reduce(err) {
+		if err = reload(); err == nil {
+			res, err = run(func() { output = true })
+		}
+	}
+	return res, err
+}
+
+var errHung = errors.New("no output within the first token timeout")
+
+// retryHung runs predict, cancelling it when it produces no output within timeout. The backend is then restarted
+// and predict is run again once, failing with errHung when it doesn't produce any output either
+func retryHung(ctx context.Context, timeout time.Duration, predict predictFunc, onOutput func(), restart func() error) (LLMResponse, error) {
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := context.WithCancel(ctx)
+		var hung atomic.Bool
+		timer := time.AfterFunc(timeout, func() {
+			hung.Store(true)
+			cancel()
+		})
+
+		res, err := predict(callCtx, func() {
+			timer.Stop()
+			onOutput()
+		})
+		timer.Stop()
+		cancel()
+
+		if !hung.Load() {
+			return res, err
+		}
+		if attempt > 0 {
+			return LLMResponse{}, errHung
+		}
+		if err := restart(); err != nil {
+			return LLMResponse{}, err
+		}
+	}
+}
+
 var cutstrings map[string]*regexp.Regexp = make(map[string]*regexp.Regexp)
 var mu sync.Mutex = sync.Mutex{}
 
@@ -197,3 +417,21 @@ func Finetune(config config.BackendConfig, input, prediction string) string {
 	}
 	return prediction
 }
+
+// TransformResponse post-processes the (finetuned) prediction with the response template of the model, if any
+func TransformResponse(loader *model.ModelLoader, config config.BackendConfig, input, prediction string) string {
+	if config.TemplateConfig.Response == "" {
+		return prediction
+	}
+
+	transformed, err := loader.EvaluateTemplateForResponse(config.TemplateConfig.Response, model.ResponseTemplateData{
+		Input:     input,
+		Response:  prediction,
+		StopWords: config.StopWords,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("model", config.Name).Msg("failed to evaluate the response template, returning the response as is")
+		return prediction
+	}
+	return transformed
+}
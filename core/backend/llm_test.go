@@ -0,0 +1,222 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func streamingPredict(ctx context.Context, onOutput func()) (LLMResponse, error) {
+	onOutput()
+	return LLMResponse{Response: "ok"}, nil
+}
+
+func hungPredict(ctx context.Context, onOutput func()) (LLMResponse, error) {
+	<-ctx.Done()
+	return LLMResponse{}, ctx.Err()
+}
+
+func TestRetryHung(t *testing.T) {
+	timeout := 20 * time.Millisecond
+
+	t.Run("returns the response of a backend producing output", func(t *testing.T) {
+		restarts := 0
+		res, err := retryHung(context.Background(), timeout, streamingPredict, func() {}, func() error {
+			restarts++
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", res.Response)
+		assert.Equal(t, 0, restarts)
+	})
+
+	t.Run("restarts a hung backend and retries once", func(t *testing.T) {
+		predict := hungPredict
+		restarts := 0
+		outputs := 0
+		res, err := retryHung(context.Background(), timeout, func(ctx context.Context, onOutput func()) (LLMResponse, error) {
+			return predict(ctx, onOutput)
+		}, func() { outputs++ }, func() error {
+			restarts++
+			predict = streamingPredict
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", res.Response)
+		assert.Equal(t, 1, restarts)
+		assert.Equal(t, 1, outputs)
+	})
+
+	t.Run("fails when the restarted backend is hung too", func(t *testing.T) {
+		restarts := 0
+		_, err := retryHung(context.Background(), timeout, hungPredict, func() {}, func() error {
+			restarts++
+			return nil
+		})
+		assert.ErrorIs(t, err, errHung)
+		assert.Equal(t, 1, restarts)
+	})
+
+	t.Run("fails when the backend can't be restarted", func(t *testing.T) {
+		loadErr := errors.New("load failed")
+		_, err := retryHung(context.Background(), timeout, hungPredict, func() {}, func() error {
+			return loadErr
+		})
+		assert.ErrorIs(t, err, loadErr)
+	})
+
+	t.Run("doesn't retry the errors of a backend producing output", func(t *testing.T) {
+		predictErr := errors.New("predict failed")
+		restarts := 0
+		_, err := retryHung(context.Background(), timeout, func(ctx context.Context, onOutput func()) (LLMResponse, error) {
+			onOutput()
+			return LLMResponse{}, predictErr
+		}, func() {}, func() error {
+			restarts++
+			return nil
+		})
+		assert.ErrorIs(t, err, predictErr)
+		assert.Equal(t, 0, restarts)
+	})
+}
+
+var errOutOfMemory = errors.New("CUDA error: out of memory")
+
+// oomRun fails with errOutOfMemory the first failures times, after streaming when streamed is set
+func oomRun(failures int, streamed bool) (func(onOutput func()) (LLMResponse, error), *int) {
+	runs := 0
+	return func(onOutput func()) (LLMResponse, error) {
+		runs++
+		if runs <= failures {
+			if streamed {
+				onOutput()
+			}
+			return LLMResponse{}, errOutOfMemory
+		}
+		onOutput()
+		return LLMResponse{Response: "ok"}, nil
+	}, &runs
+}
+
+func TestRetryOutOfMemory(t *testing.T) {
+	reduceUpTo := func(attempts int, reductions *int) func(error) bool {
+		return func(err error) bool {
+			if *reductions >= attempts || !errors.Is(err, errOutOfMemory) {
+				return false
+			}
+			*reductions++
+			return true
+		}
+	}
+
+	t.Run("reloads the model and retries until the request succeeds", func(t *testing.T) {
+		run, runs := oomRun(2, false)
+		reductions, reloads := 0, 0
+		res, err := retryOutOfMemory(run, reduceUpTo(3, &reductions), func() error {
+			reloads++
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", res.Response)
+		assert.Equal(t, 3, *runs)
+		assert.Equal(t, 2, reductions)
+		assert.Equal(t, 2, reloads)
+	})
+
+	t.Run("fails once the attempts are exhausted", func(t *testing.T) {
+		run, runs := oomRun(5, false)
+		reductions := 0
+		_, err := retryOutOfMemory(run, reduceUpTo(2, &reductions), func() error { return nil })
+		assert.ErrorIs(t, err, errOutOfMemory)
+		assert.Equal(t, 3, *runs)
+	})
+
+	t.Run("retries the reloads running out of memory", func(t *testing.T) {
+		run, runs := oomRun(1, false)
+		reductions, reloads := 0, 0
+		res, err := retryOutOfMemory(run, reduceUpTo(3, &reductions), func() error {
+			reloads++
+			if reloads == 1 {
+				return errOutOfMemory
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", res.Response)
+		assert.Equal(t, 2, *runs)
+		assert.Equal(t, 2, reductions)
+		assert.Equal(t, 2, reloads)
+	})
+
+	t.Run("doesn't retry a request which streamed output", func(t *testing.T) {
+		run, runs := oomRun(1, true)
+		reductions := 0
+		_, err := retryOutOfMemory(run, reduceUpTo(3, &reductions), func() error { return nil })
+		assert.ErrorIs(t, err, errOutOfMemory)
+		assert.Equal(t, 1, *runs)
+		assert.Equal(t, 0, reductions)
+	})
+
+	t.Run("doesn't retry the other errors", func(t *testing.T) {
+		otherErr := errors.New("predict failed")
+		reductions := 0
+		_, err := retryOutOfMemory(func(onOutput func()) (LLMResponse, error) {
+			return LLMResponse{}, otherErr
+		}, reduceUpTo(3, &reductions), func() error { return nil })
+		assert.ErrorIs(t, err, otherErr)
+		assert.Equal(t, 0, reductions)
+	})
+}
+
+func TestReducedSettings(t *testing.T) {
+	layers, contextSize := 99, 4096
+	configured := config.BackendConfig{LLMConfig: config.LLMConfig{NGPULayers: &layers, ContextSize: &contextSize}}
+
+	_, ok := reducedSettings(configured, configured)
+	assert.False(t, ok)
+
+	c := configured
+	changes := config.OOMRetry{Enabled: true}.Reduce(&c)
+	assert.NotEmpty(t, changes)
+
+	reduced, ok := reducedSettings(configured, c)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"gpu_layers 32", "context_size 2048", "batch 256"}, reduced.Changes())
+
+	// the settings of the configured model are left alone, the reduced ones apply to the next requests
+	assert.Equal(t, 99, *configured.NGPULayers)
+	applied := applyReducedSettings(configured, reduced)
+	assert.Equal(t, 32, *applied.NGPULayers)
+	assert.Equal(t, 2048, *applied.ContextSize)
+	assert.Equal(t, 256, applied.Batch)
+
+	unchanged := applyReducedSettings(configured, model.ReducedSettings{})
+	assert.Same(t, configured.NGPULayers, unchanged.NGPULayers)
+	assert.Same(t, configured.ContextSize, unchanged.ContextSize)
+}
+
+func TestLogprobsFromProto(t *testing.T) {
+	assert.Nil(t, logprobsFromProto(nil))
+
+	logprobs := logprobsFromProto([]*proto.TokenLogprob{
+		{Token: "Hé", Logprob: -0.5, TopLogprobs: []*proto.TokenLogprob{{Token: "Hé", Logprob: -0.5}, {Token: "Hi", Logprob: -1}}},
+		{Token: "!", Logprob: -0.25},
+	})
+	assert.Equal(t, []schema.LogprobContent{
+		{
+			TopLogprob: schema.TopLogprob{Token: "Hé", Logprob: -0.5, Bytes: []int{72, 195, 169}},
+			TopLogprobs: []schema.TopLogprob{
+				{Token: "Hé", Logprob: -0.5, Bytes: []int{72, 195, 169}},
+				{Token: "Hi", Logprob: -1, Bytes: []int{72, 105}},
+			},
+		},
+		{TopLogprob: schema.TopLogprob{Token: "!", Logprob: -0.25, Bytes: []int{33}}, TopLogprobs: []schema.TopLogprob{}},
+	}, logprobs)
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// InferenceMetrics records the statistics of the text generation requests of the models
+type InferenceMetrics interface {
+	ObserveRequest(model string)
+	ObserveTokens(model string, tokens int)
+	ObserveTimeToFirstToken(model string, d time.Duration)
+	// AddQueued changes the number of requests of the model waiting for their first token
+	AddQueued(model string, delta int)
+}
+
+var inferenceMetrics InferenceMetrics
+
+// SetInferenceMetrics sets where the statistics of the requests are recorded, nil disables them
+func SetInferenceMetrics(m InferenceMetrics) {
+	inferenceMetrics = m
+}
+
+type predictFunc = func(ctx context.Context, onOutput func()) (LLMResponse, error)
+
+// observePredict records the statistics of the requests of the model. The time to the first token is only known
+// when the response is streamed by the backend
+func observePredict(modelName string, m InferenceMetrics, predict predictFunc) predictFunc {
+	return func(ctx context.Context, onOutput func()) (LLMResponse, error) {
+		m.ObserveRequest(modelName)
+		m.AddQueued(modelName, 1)
+		var dequeue sync.Once
+		start := time.Now()
+
+		outputs := 0
+		res, err := predict(ctx, func() {
+			if outputs == 0 {
+				dequeue.Do(func() { m.AddQueued(modelName, -1) })
+				m.ObserveTimeToFirstToken(modelName, time.Since(start))
+			}
+			outputs++
+			onOutput()
+		})
+		dequeue.Do(func() { m.AddQueued(modelName, -1) })
+
+		tokens := res.Usage.Completion
+		if tokens == 0 {
+			tokens = outputs
+		}
+		m.ObserveTokens(modelName, tokens)
+		return res, err
+	}
+}
@@ -32,6 +32,22 @@ func modelOpts(c config.BackendConfig, so *config.ApplicationConfig, opts []mode
 		opts = append(opts, model.WithExternalBackend(k, v))
 	}
 
+	if so.ExternalGRPCBackendsCredentials != nil {
+		opts = append(opts, model.WithExternalBackendsCredentials(so.ExternalGRPCBackendsCredentials))
+	}
+
+	numaNode := c.NUMANode
+	if numaNode == "" && c.CPUs == "" && so.NUMAAuto {
+		numaNode = config.NUMANodeAuto
+	}
+	if c.CPUs != "" || numaNode != "" {
+		opts = append(opts, model.WithCPUPinning(c.CPUs, numaNode))
+	}
+
+	if c.WarmRestart && so.BackendStateDir != "" {
+		opts = append(opts, model.WithStatePath(filepath.Join(so.BackendStateDir, c.Name)))
+	}
+
 	return opts
 }
 
@@ -45,7 +61,7 @@ func getSeed(c config.BackendConfig) int32 {
 }
 
 func gRPCModelOpts(c config.BackendConfig) *pb.ModelOptions {
-	b := 512
+	b := config.DefaultBatch
 	if c.Batch != 0 {
 		b = c.Batch
 	}
@@ -79,6 +95,9 @@ func gRPCModelOpts(c config.BackendConfig) *pb.ModelOptions {
 		MMProj:               c.MMProj,
 		FlashAttention:       c.FlashAttention,
 		NoKVOffload:          c.NoKVOffloading,
+		CacheTypeKey:         c.CacheTypeK,
+		CacheTypeValue:       c.CacheTypeV,
+		ContextShift:         c.ContextShift,
 		YarnExtFactor:        c.YarnExtFactor,
 		YarnAttnFactor:       c.YarnAttnFactor,
 		YarnBetaFast:         c.YarnBetaFast,
@@ -90,7 +109,8 @@ func gRPCModelOpts(c config.BackendConfig) *pb.ModelOptions {
 		RopeScaling:          c.RopeScaling,
 		Type:                 c.ModelType,
 		RopeFreqScale:        c.RopeFreqScale,
-		NUMA:                 c.NUMA,
+		NUMA:                 c.NUMA || c.NUMAStrategy != "",
+		NUMAStrategy:         c.NUMAStrategy,
 		Embeddings:           *c.Embeddings,
 		LowVRAM:              *c.LowVRAM,
 		NGPULayers:           int32(*c.NGPULayers),
@@ -108,6 +128,14 @@ func gRPCModelOpts(c config.BackendConfig) *pb.ModelOptions {
 	}
 }
 
+// predictTensorSplit is the tensor_split of the predictions, auto is resolved when the model is loaded
+func predictTensorSplit(c config.BackendConfig) string {
+	if c.TensorSplit == config.TensorSplitAuto {
+		return ""
+	}
+	return c.TensorSplit
+}
+
 func gRPCPredictOpts(c config.BackendConfig, modelPath string) *pb.PredictOptions {
 	promptCachePath := ""
 	if c.PromptCachePath != "" {
@@ -149,11 +177,14 @@ func gRPCPredictOpts(c config.BackendConfig, modelPath string) *pb.PredictOption
 		NKeep:               int32(c.Keep),
 		Batch:               int32(c.Batch),
 		IgnoreEOS:           c.IgnoreEOS,
+		Raw:                 c.Raw,
+		Logprobs:            c.Logprobs.Enabled,
+		TopLogprobs:         int32(c.Logprobs.Top),
 		Seed:                getSeed(c),
 		MLock:               *c.MMlock,
 		MMap:                *c.MMap,
 		MainGPU:             c.MainGPU,
-		TensorSplit:         c.TensorSplit,
+		TensorSplit:         predictTensorSplit(c),
 		TailFreeSamplingZ:   float32(*c.TFZ),
 		TypicalP:            float32(*c.TypicalP),
 	}
@@ -1,23 +1,68 @@
 package backend
 
 import (
+	"sync"
+
 	"github.com/mudler/LocalAI/core/config"
 
 	"github.com/mudler/LocalAI/pkg/grpc"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/store"
+)
+
+type externalStore struct {
+	config store.Config
+	store  store.Store
+}
+
+var (
+	externalStoresMu sync.Mutex
+	externalStores   = map[string]externalStore{}
 )
 
 func StoreBackend(sl *model.ModelLoader, appConfig *config.ApplicationConfig, storeName string) (grpc.Backend, error) {
-    if storeName == "" {
-      storeName = "default"
-    }
+	if storeName == "" {
+		storeName = "default"
+	}
 
-    sc := []model.Option{
-      model.WithBackendString(model.LocalStoreBackend),
-      model.WithAssetDir(appConfig.AssetsDestination),
-      model.WithModel(storeName),
-    }
+	sc := []model.Option{
+		model.WithBackendString(model.LocalStoreBackend),
+		model.WithAssetDir(appConfig.AssetsDestination),
+		model.WithModel(storeName),
+	}
 
-    return sl.BackendLoader(sc...)
+	return sl.BackendLoader(sc...)
 }
 
+// StoreDriver returns the Store for storeName: the external vector database
+// configured for it, or the internal local-store otherwise
+func StoreDriver(sl *model.ModelLoader, appConfig *config.ApplicationConfig, storeName string) (store.Store, error) {
+	if storeName == "" {
+		storeName = "default"
+	}
+
+	c, exists := appConfig.VectorStores[storeName]
+	if !exists || !c.IsExternal() {
+		sb, err := StoreBackend(sl, appConfig, storeName)
+		if err != nil {
+			return nil, err
+		}
+		return store.NewLocalStore(sb), nil
+	}
+
+	externalStoresMu.Lock()
+	defer externalStoresMu.Unlock()
+
+	// The configuration can change at runtime (vector_stores.json)
+	if s, ok := externalStores[storeName]; ok && s.config == c {
+		return s.store, nil
+	}
+
+	s, err := store.New(storeName, c)
+	if err != nil {
+		return nil, err
+	}
+	externalStores[storeName] = externalStore{config: c, store: s}
+
+	return s, nil
+}
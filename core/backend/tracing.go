@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAI/core/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/mudler/LocalAI/core/backend")
+
+func spanAttributes(c config.BackendConfig) trace.SpanStartEventOption {
+	return trace.WithAttributes(attribute.String("model", c.Name), attribute.String("backend", c.Backend))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracePredict records the calls to the backend in spans, with the tokens of the response
+func tracePredict(c config.BackendConfig, predict predictFunc) predictFunc {
+	return func(ctx context.Context, onOutput func()) (LLMResponse, error) {
+		ctx, span := tracer.Start(ctx, "backend.predict", trace.WithSpanKind(trace.SpanKindClient), spanAttributes(c))
+		first := true
+		res, err := predict(ctx, func() {
+			if first {
+				first = false
+				span.AddEvent("first token")
+			}
+			onOutput()
+		})
+		span.SetAttributes(attribute.Int("prompt_tokens", res.Usage.Prompt), attribute.Int("completion_tokens", res.Usage.Completion))
+		endSpan(span, err)
+		return res, err
+	}
+}
@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+// ModelTranslate translates the text with a dedicated translation model, from the source into the target language
+// given as codes of the model
+func ModelTranslate(ctx context.Context, text, source, target string, loader *model.ModelLoader, appConfig *config.ApplicationConfig, backendConfig config.BackendConfig) (string, error) {
+	if backendConfig.Backend == "" {
+		return "", fmt.Errorf("backend is required")
+	}
+
+	opts := modelOpts(backendConfig, appConfig, []model.Option{
+		model.WithBackendString(backendConfig.Backend),
+		model.WithModel(backendConfig.Model),
+		model.WithContext(appConfig.Context),
+		model.WithAssetDir(appConfig.AssetsDestination),
+		model.WithLoadGRPCLoadModelOpts(gRPCModelOpts(backendConfig)),
+	})
+	translationModel, err := loader.BackendLoader(opts...)
+	if err != nil {
+		return "", err
+	}
+	if translationModel == nil {
+		return "", fmt.Errorf("could not load translation model")
+	}
+
+	predictOpts := gRPCPredictOpts(backendConfig, loader.ModelPath)
+	predictOpts.Prompt = text
+	predictOpts.SourceLanguage = source
+	predictOpts.TargetLanguage = target
+	reply, err := translationModel.Predict(ctx, predictOpts)
+	if err != nil {
+		return "", err
+	}
+	return string(reply.Message), nil
+}
@@ -8,9 +8,14 @@ import (
 
 	"github.com/mudler/LocalAI/core/config"
 
+	"github.com/mudler/LocalAI/pkg/grpc"
 	"github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/sound"
 	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func ModelTTS(
@@ -23,6 +28,105 @@ func ModelTTS(
 	appConfig *config.ApplicationConfig,
 	backendConfig config.BackendConfig,
 ) (string, *proto.Result, error) {
+	ttsModel, modelPath, err := loadTTSModel(backend, modelFile, loader, appConfig, backendConfig)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := os.MkdirAll(appConfig.AudioDir, 0750); err != nil {
+		return "", nil, fmt.Errorf("failed creating audio directory: %s", err)
+	}
+
+	fileName := utils.GenerateUniqueFileName(appConfig.AudioDir, "tts", ".wav")
+	filePath := filepath.Join(appConfig.AudioDir, fileName)
+
+	res, err := ttsModel.TTS(context.Background(), &proto.TTSRequest{
+		Text:     text,
+		Model:    modelPath,
+		Voice:    voice,
+		Dst:      filePath,
+		Language: &language,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	// return RPC error if any
+	if !res.Success {
+		return "", nil, fmt.Errorf(res.Message)
+	}
+
+	return filePath, res, err
+}
+
+// ModelTTSStream synthesizes the text chunk by chunk, passing f the PCM16 audio of each chunk as soon as the backend
+// returns it. The backends not streaming the audio synthesize the whole text, passed to f as a single chunk. The
+// synthesis stops at the first error of f, which is returned
+func ModelTTSStream(
+	ctx context.Context,
+	backend,
+	text,
+	modelFile,
+	voice,
+	language string,
+	loader *model.ModelLoader,
+	appConfig *config.ApplicationConfig,
+	backendConfig config.BackendConfig,
+	f func(audio []byte, sampleRate int) error,
+) error {
+	ttsModel, modelPath, err := loadTTSModel(backend, modelFile, loader, appConfig, backendConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var writeErr error
+	err = ttsModel.TTSStream(ctx, &proto.TTSRequest{
+		Text:     text,
+		Model:    modelPath,
+		Voice:    voice,
+		Language: &language,
+	}, func(chunk *proto.TTSChunk) {
+		if writeErr != nil {
+			return
+		}
+		if writeErr = f(chunk.Audio, int(chunk.SampleRate)); writeErr != nil {
+			cancel()
+		}
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if status.Code(err) != codes.Unimplemented {
+		return err
+	}
+
+	log.Debug().Str("backend", backend).Msg("the backend doesn't stream the audio, synthesizing the whole text")
+	filePath, _, err := ModelTTS(backend, text, modelFile, voice, language, loader, appConfig, backendConfig)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(filePath)
+	wav, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	samples, sampleRate, err := sound.DecodeWAV(wav)
+	if err != nil {
+		return err
+	}
+	return f(sound.Int16ToBytes(samples), sampleRate)
+}
+
+// loadTTSModel loads the backend of the TTS model, returning the path of the model file passed to the backend
+func loadTTSModel(
+	backend,
+	modelFile string,
+	loader *model.ModelLoader,
+	appConfig *config.ApplicationConfig,
+	backendConfig config.BackendConfig,
+) (grpc.Backend, string, error) {
 	bb := backend
 	if bb == "" {
 		bb = model.PiperBackend
@@ -39,20 +143,13 @@ func ModelTTS(
 	})
 	ttsModel, err := loader.BackendLoader(opts...)
 	if err != nil {
-		return "", nil, err
+		return nil, "", err
 	}
 
 	if ttsModel == nil {
-		return "", nil, fmt.Errorf("could not load piper model")
-	}
-
-	if err := os.MkdirAll(appConfig.AudioDir, 0750); err != nil {
-		return "", nil, fmt.Errorf("failed creating audio directory: %s", err)
+		return nil, "", fmt.Errorf("could not load piper model")
 	}
 
-	fileName := utils.GenerateUniqueFileName(appConfig.AudioDir, "tts", ".wav")
-	filePath := filepath.Join(appConfig.AudioDir, fileName)
-
 	// If the model file is not empty, we pass it joined with the model path
 	modelPath := ""
 	if modelFile != "" {
@@ -63,7 +160,7 @@ func ModelTTS(
 		mp := filepath.Join(loader.ModelPath, modelFile)
 		if _, err := os.Stat(mp); err == nil {
 			if err := utils.VerifyPath(mp, appConfig.ModelPath); err != nil {
-				return "", nil, err
+				return nil, "", err
 			}
 			modelPath = mp
 		} else {
@@ -71,21 +168,5 @@ func ModelTTS(
 		}
 	}
 
-	res, err := ttsModel.TTS(context.Background(), &proto.TTSRequest{
-		Text:     text,
-		Model:    modelPath,
-		Voice:    voice,
-		Dst:      filePath,
-		Language: &language,
-	})
-	if err != nil {
-		return "", nil, err
-	}
-
-	// return RPC error if any
-	if !res.Success {
-		return "", nil, fmt.Errorf(res.Message)
-	}
-
-	return filePath, res, err
+	return ttsModel, modelPath, nil
 }
@@ -13,7 +13,8 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func StartP2PStack(ctx context.Context, address, token, networkID string, federated bool) error {
+// StartP2PStack starts the p2p node, if p2p or the federated mode are enabled, and returns it
+func StartP2PStack(ctx context.Context, address, token, networkID string, federated bool) (*node.Node, error) {
 	var n *node.Node
 	// Here we are avoiding creating multiple nodes:
 	// - if the federated mode is enabled, we create a federated node and expose a service
@@ -24,18 +25,18 @@ func StartP2PStack(ctx context.Context, address, token, networkID string, federa
 	if federated {
 		_, port, err := net.SplitHostPort(address)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// Here a new node is created and started
 		// and a service is exposed by the node
 		node, err := p2p.ExposeService(ctx, "localhost", port, token, p2p.NetworkID(networkID, p2p.FederatedID))
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if err := p2p.ServiceDiscoverer(ctx, node, token, p2p.NetworkID(networkID, p2p.FederatedID), nil, false); err != nil {
-			return err
+			return nil, err
 		}
 
 		n = node
@@ -47,11 +48,11 @@ func StartP2PStack(ctx context.Context, address, token, networkID string, federa
 		if n == nil {
 			node, err := p2p.NewNode(token)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			err = node.Start(ctx)
 			if err != nil {
-				return fmt.Errorf("starting new node: %w", err)
+				return nil, fmt.Errorf("starting new node: %w", err)
 			}
 			n = node
 		}
@@ -60,7 +61,10 @@ func StartP2PStack(ctx context.Context, address, token, networkID string, federa
 		log.Info().Msg("Starting P2P server discovery...")
 		if err := p2p.ServiceDiscoverer(ctx, n, token, p2p.NetworkID(networkID, p2p.WorkerID), func(serviceID string, node p2p.NodeData) {
 			var tunnelAddresses []string
-			for _, v := range p2p.GetAvailableNodes(p2p.NetworkID(networkID, p2p.WorkerID)) {
+			// llama.cpp assigns the tensors following the order of the servers: best workers first
+			workers := p2p.GetAvailableNodes(p2p.NetworkID(networkID, p2p.WorkerID))
+			p2p.SortByPerformance(workers)
+			for _, v := range workers {
 				if v.IsOnline() {
 					tunnelAddresses = append(tunnelAddresses, v.TunnelAddress)
 				} else {
@@ -72,9 +76,9 @@ func StartP2PStack(ctx context.Context, address, token, networkID string, federa
 			os.Setenv("LLAMACPP_GRPC_SERVERS", tunnelEnvVar)
 			log.Debug().Msgf("setting LLAMACPP_GRPC_SERVERS to %s", tunnelEnvVar)
 		}, true); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return n, nil
 }
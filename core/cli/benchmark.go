@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/backend"
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+const benchmarkSampleInterval = 500 * time.Millisecond
+
+type BenchmarkCMD struct {
+	Model            string `short:"m" required:"" completion:"models" help:"Model name to benchmark"`
+	Prompt           string `default:"Write a short story about a robot learning to paint." help:"Prompt sent to the model"`
+	Requests         int    `short:"n" default:"8" help:"Number of prompts run at each concurrency level"`
+	Concurrency      []int  `short:"c" default:"1,2,4" help:"Concurrency levels, the number of prompts running in parallel"`
+	MaxTokens        int    `default:"128" help:"Maximum number of tokens generated for each prompt"`
+	Threads          int    `short:"t" env:"LOCALAI_THREADS,THREADS" default:"4" help:"Number of threads used for parallel computation"`
+	ContextSize      int    `env:"LOCALAI_CONTEXT_SIZE,CONTEXT_SIZE" default:"512" help:"Default context size for models"`
+	ParallelRequests bool   `env:"LOCALAI_PARALLEL_REQUESTS,PARALLEL_REQUESTS" help:"Let the backend handle the prompts in parallel if it supports it (e.g. llama.cpp with LLAMACPP_PARALLEL), they are run one at a time otherwise"`
+
+	ModelsPath        string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+	BackendAssetsPath string `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
+}
+
+type benchmarkResult struct {
+	Concurrency      int     `json:"concurrency"`
+	Requests         int     `json:"requests"`
+	Errors           int     `json:"errors"`
+	CompletionTokens int     `json:"completion_tokens"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	TokensPerSecond  float64 `json:"tokens_per_second"`
+	TTFTAvgMS        float64 `json:"ttft_avg_ms"`
+	TTFTP95MS        float64 `json:"ttft_p95_ms"`
+	LatencyAvgMS     float64 `json:"latency_avg_ms"`
+	LatencyP95MS     float64 `json:"latency_p95_ms"`
+	PeakRSS          uint64  `json:"peak_rss"`
+	PeakVRAM         uint64  `json:"peak_vram,omitempty"`
+}
+
+type benchmarkReport struct {
+	Model           string            `json:"model"`
+	Backend         string            `json:"backend,omitempty"`
+	Threads         int               `json:"threads"`
+	ContextSize     int               `json:"context_size"`
+	LoadTimeSeconds float64           `json:"load_time_seconds"`
+	Results         []benchmarkResult `json:"results"`
+}
+
+// benchmarkRun is the measure of a single prompt
+type benchmarkRun struct {
+	ttft, latency time.Duration
+	tokens        int
+	err           error
+}
+
+func (b *BenchmarkCMD) Run(ctx *cliContext.Context) error {
+	opts := &config.ApplicationConfig{
+		ModelPath:               b.ModelsPath,
+		Context:                 context.Background(),
+		AssetsDestination:       b.BackendAssetsPath,
+		Threads:                 b.Threads,
+		ContextSize:             b.ContextSize,
+		ParallelBackendRequests: b.ParallelRequests,
+	}
+
+	cl := config.NewBackendConfigLoader(b.ModelsPath)
+	ml := model.NewModelLoader(opts.ModelPath)
+	if err := cl.LoadBackendConfigsFromPath(b.ModelsPath); err != nil {
+		return err
+	}
+
+	cfg, err := cl.LoadBackendConfigFileByName(b.Model, b.ModelsPath,
+		config.LoadOptionThreads(b.Threads),
+		config.LoadOptionContextSize(b.ContextSize),
+		config.ModelPath(b.ModelsPath),
+	)
+	if err != nil {
+		return err
+	}
+	if !cfg.Validate() {
+		return fmt.Errorf("invalid configuration of the model %s", b.Model)
+	}
+	cfg.Maxtokens = &b.MaxTokens
+
+	defer func() {
+		err := ml.StopAllGRPC()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to stop all grpc processes")
+		}
+	}()
+
+	samplerCtx, stopSampler := context.WithCancel(opts.Context)
+	defer stopSampler()
+	go ml.SampleProcesses(samplerCtx, benchmarkSampleInterval)
+
+	report := benchmarkReport{Model: b.Model, Backend: cfg.Backend, Threads: b.Threads, ContextSize: b.ContextSize}
+
+	// the first prompt loads the model, it's not part of the measures
+	log.Info().Str("model", b.Model).Msg("loading the model")
+	warmup := b.prompt(cfg, ml, opts)
+	if warmup.err != nil {
+		return warmup.err
+	}
+	report.LoadTimeSeconds = warmup.latency.Seconds()
+
+	for _, concurrency := range b.Concurrency {
+		if concurrency < 1 {
+			continue
+		}
+		log.Info().Int("concurrency", concurrency).Int("requests", b.Requests).Msg("running the prompts")
+		report.Results = append(report.Results, b.level(cfg, ml, opts, concurrency))
+	}
+
+	return printResult(ctx, report, func() {
+		fmt.Printf("Model: %s, threads: %d, context size: %d, load time: %.2fs\n\n", report.Model, report.Threads, report.ContextSize, report.LoadTimeSeconds)
+		fmt.Printf("%-11s %-8s %-6s %-8s %-10s %-12s %-12s %-12s %-12s %-10s\n", "CONCURRENCY", "REQUESTS", "ERRORS", "TOKENS", "TOKENS/S", "TTFT AVG", "TTFT P95", "LATENCY AVG", "LATENCY P95", "PEAK RSS")
+		for _, r := range report.Results {
+			fmt.Printf("%-11d %-8d %-6d %-8d %-10.2f %-12s %-12s %-12s %-12s %-10s\n",
+				r.Concurrency, r.Requests, r.Errors, r.CompletionTokens, r.TokensPerSecond,
+				formatMS(r.TTFTAvgMS), formatMS(r.TTFTP95MS), formatMS(r.LatencyAvgMS), formatMS(r.LatencyP95MS), formatBytes(r.PeakRSS))
+		}
+	})
+}
+
+// level runs the prompts with the given concurrency
+func (b *BenchmarkCMD) level(cfg *config.BackendConfig, ml *model.ModelLoader, opts *config.ApplicationConfig, concurrency int) benchmarkResult {
+	result := benchmarkResult{Concurrency: concurrency, Requests: b.Requests}
+
+	done := make(chan struct{})
+	peak := make(chan model.ProcessStats)
+	go func() {
+		p := model.ProcessStats{}
+		ticker := time.NewTicker(benchmarkSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				peak <- p
+				return
+			case <-ticker.C:
+				for _, s := range ml.AllProcessStats() {
+					p.RSS = max(p.RSS, s.RSS)
+					p.VRAM = max(p.VRAM, s.VRAM)
+				}
+			}
+		}
+	}()
+
+	runs := make([]benchmarkRun, b.Requests)
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				runs[i] = b.prompt(cfg, ml, opts)
+			}
+		}()
+	}
+	for i := range runs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	result.DurationSeconds = time.Since(start).Seconds()
+
+	close(done)
+	p := <-peak
+	result.PeakRSS, result.PeakVRAM = p.RSS, p.VRAM
+
+	ttfts, latencies := []float64{}, []float64{}
+	for _, r := range runs {
+		if r.err != nil {
+			log.Error().Err(r.err).Msg("prompt failed")
+			result.Errors++
+			continue
+		}
+		result.CompletionTokens += r.tokens
+		ttfts = append(ttfts, float64(r.ttft.Microseconds())/1000)
+		latencies = append(latencies, float64(r.latency.Microseconds())/1000)
+	}
+	if result.DurationSeconds > 0 {
+		result.TokensPerSecond = float64(result.CompletionTokens) / result.DurationSeconds
+	}
+	result.TTFTAvgMS, result.TTFTP95MS = average(ttfts), percentile(ttfts, 95)
+	result.LatencyAvgMS, result.LatencyP95MS = average(latencies), percentile(latencies, 95)
+	return result
+}
+
+// prompt runs the prompt once, measuring when the first token is received
+func (b *BenchmarkCMD) prompt(cfg *config.BackendConfig, ml *model.ModelLoader, opts *config.ApplicationConfig) benchmarkRun {
+	input := &schema.OpenAIRequest{Messages: []schema.Message{{Role: "user", Content: b.Prompt, StringContent: b.Prompt}}}
+	input.Model = b.Model
+	input.Context, input.Cancel = context.WithCancel(opts.Context)
+	defer input.Cancel()
+
+	predInput := openai.TemplateMessages(cfg, input, ml, nil, false)
+
+	run := benchmarkRun{}
+	streamed := 0
+	start := time.Now()
+	_, usage, err := openai.ComputeChoices(input, predInput, cfg, opts, ml, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+		if streamed == 0 {
+			run.ttft = time.Since(start)
+		}
+		streamed++
+		return true
+	})
+	run.latency = time.Since(start)
+	run.err = err
+
+	// not all the backends count the tokens
+	run.tokens = usage.Completion
+	if run.tokens == 0 {
+		run.tokens = streamed
+	}
+	if streamed == 0 {
+		run.ttft = run.latency
+	}
+	return run
+}
+
+func average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the nearest-rank percentile of values
+func percentile(values []float64, p int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func formatMS(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.2fs", ms/1000)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+func formatBytes(b uint64) string {
+	if b == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fGiB", float64(b)/(1024*1024*1024))
+}
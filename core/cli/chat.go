@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/backend"
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// maxChatLine is the size of the longest message read from the terminal
+const maxChatLine = 1024 * 1024
+
+type ChatCMD struct {
+	Model             string `short:"m" required:"" completion:"models" help:"Model name to chat with"`
+	System            string `short:"s" help:"System prompt of the conversation"`
+	Threads           int    `short:"t" default:"4" help:"Number of threads used for parallel computation"`
+	ContextSize       int    `env:"LOCALAI_CONTEXT_SIZE,CONTEXT_SIZE" default:"512" help:"Default context size for models"`
+	ModelsPath        string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+	BackendAssetsPath string `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
+}
+
+func (c *ChatCMD) Run(ctx *cliContext.Context) error {
+	opts := &config.ApplicationConfig{
+		ModelPath:         c.ModelsPath,
+		Context:           context.Background(),
+		AssetsDestination: c.BackendAssetsPath,
+		Threads:           c.Threads,
+		ContextSize:       c.ContextSize,
+	}
+
+	cl := config.NewBackendConfigLoader(c.ModelsPath)
+	ml := model.NewModelLoader(opts.ModelPath)
+	if err := cl.LoadBackendConfigsFromPath(c.ModelsPath); err != nil {
+		return err
+	}
+
+	cfg, err := cl.LoadBackendConfigFileByName(c.Model, c.ModelsPath,
+		config.LoadOptionThreads(c.Threads),
+		config.LoadOptionContextSize(c.ContextSize),
+		config.ModelPath(c.ModelsPath),
+	)
+	if err != nil {
+		return err
+	}
+	if !cfg.Validate() {
+		return fmt.Errorf("invalid configuration of the model %s", c.Model)
+	}
+
+	defer func() {
+		err := ml.StopAllGRPC()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to stop all grpc processes")
+		}
+	}()
+
+	fmt.Printf("Chatting with %s: /reset clears the conversation, /exit or Ctrl-D quits\n", c.Model)
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 0, 64*1024), maxChatLine)
+
+	messages := c.newConversation()
+	for {
+		fmt.Print("> ")
+		if !in.Scan() {
+			fmt.Println()
+			return in.Err()
+		}
+
+		line := strings.TrimSpace(in.Text())
+		switch line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			messages = c.newConversation()
+			fmt.Println("The conversation was cleared")
+			continue
+		}
+
+		messages = append(messages, schema.Message{Role: "user", Content: line, StringContent: line})
+		reply, err := c.reply(cfg, ml, opts, messages)
+		if err != nil {
+			log.Error().Err(err).Msg("unable to generate the answer")
+			// the message is dropped, so that it can be sent again
+			messages = messages[:len(messages)-1]
+			continue
+		}
+		messages = append(messages, schema.Message{Role: "assistant", Content: reply, StringContent: reply})
+	}
+}
+
+func (c *ChatCMD) newConversation() []schema.Message {
+	if c.System == "" {
+		return []schema.Message{}
+	}
+	return []schema.Message{{Role: "system", Content: c.System, StringContent: c.System}}
+}
+
+// reply streams the answer of the model to the conversation on the terminal, and returns it
+func (c *ChatCMD) reply(cfg *config.BackendConfig, ml *model.ModelLoader, opts *config.ApplicationConfig, messages []schema.Message) (string, error) {
+	input := &schema.OpenAIRequest{Messages: messages}
+	input.Model = c.Model
+	input.Context, input.Cancel = context.WithCancel(opts.Context)
+	defer input.Cancel()
+
+	predInput := openai.TemplateMessages(cfg, input, ml, nil, false)
+	result, _, err := openai.ComputeChoices(input, predInput, cfg, opts, ml, func(s string, choices *[]schema.Choice) {
+		*choices = append(*choices, schema.Choice{Message: &schema.Message{Role: "assistant", Content: s}})
+	}, func(s string, usage backend.TokenUsage) bool {
+		fmt.Print(s)
+		return true
+	})
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if len(result) == 0 {
+		return "", nil
+	}
+	reply, _ := result[0].Message.Content.(string)
+	return reply, nil
+}
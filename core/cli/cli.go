@@ -14,6 +14,14 @@ var CLI struct {
 	TTS             TTSCMD             `cmd:"" help:"Convert text to speech"`
 	SoundGeneration SoundGenerationCMD `cmd:"" help:"Generates audio files from text or audio"`
 	Transcript      TranscriptCMD      `cmd:"" help:"Convert audio to text"`
+	Image           ImageCMD           `cmd:"" help:"Generate images from text"`
+	Embeddings      EmbeddingsCMD      `cmd:"" help:"Generate the embeddings of texts"`
+	Chat            ChatCMD            `cmd:"" help:"Chat with a model in the terminal, without running the API server"`
+	Benchmark       BenchmarkCMD       `cmd:"" help:"Measure the throughput, latency and memory usage of a model"`
+	Config          ConfigCMD          `cmd:"" help:"Manage the model configurations"`
+	Dataset         DatasetCMD         `cmd:"" help:"Build fine-tuning datasets from the stored chat completions"`
+	Completion      CompletionCMD      `cmd:"" help:"Generate the shell completion scripts"`
+	Doctor          DoctorCMD          `cmd:"" help:"Check the system for the common problems running LocalAI: CPU features, GPU drivers, backend assets, paths and port"`
 	Worker          worker.Worker      `cmd:"" help:"Run workers to distribute workload (llama.cpp-only)"`
 	Util            UtilCMD            `cmd:"" help:"Utility commands"`
 	Explorer        ExplorerCMD        `cmd:"" help:"Run p2p explorer"`
@@ -0,0 +1,330 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+const (
+	completeModels = "models"
+	completeFiles  = "files"
+)
+
+type CompletionBashCMD struct{}
+type CompletionZshCMD struct{}
+type CompletionFishCMD struct{}
+
+type CompletionInstalledModelsCMD struct {
+	ModelsPath string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+}
+
+type CompletionCMD struct {
+	Bash CompletionBashCMD `cmd:"" help:"Print the bash completion script, e.g. source <(local-ai completion bash)"`
+	Zsh  CompletionZshCMD  `cmd:"" help:"Print the zsh completion script, e.g. source <(local-ai completion zsh)"`
+	Fish CompletionFishCMD `cmd:"" help:"Print the fish completion script, e.g. local-ai completion fish | source"`
+
+	InstalledModels CompletionInstalledModelsCMD `cmd:"" name:"installed-models" hidden:"" help:"List the installed models, for the completion scripts"`
+}
+
+func (c *CompletionBashCMD) Run(kctx *kong.Context) error {
+	fmt.Print(bashCompletion(kctx.Model.Name, completionCommands(kctx.Model.Node, "")))
+	return nil
+}
+
+func (c *CompletionZshCMD) Run(kctx *kong.Context) error {
+	// zsh runs the bash completion through its compatibility layer
+	fmt.Print("autoload -U +X bashcompinit && bashcompinit\n\n")
+	fmt.Print(bashCompletion(kctx.Model.Name, completionCommands(kctx.Model.Node, "")))
+	return nil
+}
+
+func (c *CompletionFishCMD) Run(kctx *kong.Context) error {
+	fmt.Print(fishCompletion(kctx.Model.Name, completionCommands(kctx.Model.Node, "")))
+	return nil
+}
+
+func (c *CompletionInstalledModelsCMD) Run(ctx *cliContext.Context) error {
+	cl := config.NewBackendConfigLoader(c.ModelsPath)
+	ml := model.NewModelLoader(c.ModelsPath)
+	if err := cl.LoadBackendConfigsFromPath(c.ModelsPath); err != nil {
+		return err
+	}
+
+	models, err := services.ListModels(cl, ml, "", true)
+	if err != nil {
+		return err
+	}
+	sort.Strings(models)
+	for _, m := range models {
+		fmt.Println(m)
+	}
+	return nil
+}
+
+// completionCommand is a command of the CLI, as seen by the completion scripts
+type completionCommand struct {
+	path        string // the names of the command and of its parents, empty for the CLI itself
+	help        string
+	subcommands []*completionCommand
+	flags       []completionFlag
+	args        string // how the arguments are completed: completeModels, completeFiles or not at all
+}
+
+type completionFlag struct {
+	names    []string // the long names, without the dashes
+	short    rune
+	help     string
+	value    bool     // whether the flag takes a value
+	values   []string // the allowed values, if they are enumerated
+	complete string   // how the value is completed: completeModels, completeFiles or not at all
+}
+
+// completionCommands returns the command of the node, followed by all its visible subcommands
+func completionCommands(node *kong.Node, path string) []*completionCommand {
+	cmd := &completionCommand{path: path, help: node.Help}
+	for _, flags := range node.AllFlags(true) {
+		for _, f := range flags {
+			cf := completionFlag{
+				names:    append([]string{f.Name}, f.Aliases...),
+				short:    f.Short,
+				help:     f.Help,
+				value:    !f.IsBool(),
+				complete: completionKind(f.Value),
+			}
+			if f.Enum != "" {
+				for _, v := range strings.Split(f.Enum, ",") {
+					cf.values = append(cf.values, strings.TrimSpace(v))
+				}
+			}
+			cmd.flags = append(cmd.flags, cf)
+		}
+	}
+	if len(node.Positional) > 0 {
+		cmd.args = completionKind(node.Positional[0])
+	}
+
+	commands := []*completionCommand{cmd}
+	for _, child := range node.Children {
+		if child.Hidden || child.Type != kong.CommandNode {
+			continue
+		}
+		sub := completionCommands(child, strings.TrimSpace(path+" "+child.Name))
+		cmd.subcommands = append(cmd.subcommands, sub[0])
+		commands = append(commands, sub...)
+	}
+	return commands
+}
+
+// completionKind returns how a value is completed: the model names for the values tagged with completion:"models",
+// the files for the paths
+func completionKind(v *kong.Value) string {
+	if v.Tag == nil {
+		return ""
+	}
+	if kind := v.Tag.Get("completion"); kind != "" {
+		return kind
+	}
+	switch v.Tag.Type {
+	case "path", "existingfile", "existingdir":
+		return completeFiles
+	}
+	return ""
+}
+
+func (c *completionCommand) subcommandNames() []string {
+	names := []string{}
+	for _, sub := range c.subcommands {
+		names = append(names, sub.path[strings.LastIndex(sub.path, " ")+1:])
+	}
+	return names
+}
+
+// bashCompletion returns the bash completion script. The command being completed is found by walking the words
+// through the subcommands, then its flags, the values of its flags or its subcommands and arguments are offered
+func bashCompletion(name string, commands []*completionCommand) string {
+	models := name + " completion installed-models 2>/dev/null"
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "# bash completion for %s, generated by '%s completion bash'\n\n", name, name)
+
+	sb.WriteString("_local_ai_commands() {\n\tcase \"$1\" in\n")
+	for _, c := range commands {
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(sb, "\t%q) echo %q ;;\n", c.path, strings.Join(c.subcommandNames(), " "))
+		}
+	}
+	sb.WriteString("\tesac\n}\n\n")
+
+	sb.WriteString("_local_ai_flags() {\n\tcase \"$1\" in\n")
+	for _, c := range commands {
+		flags := []string{}
+		for _, f := range c.flags {
+			for _, n := range f.names {
+				flags = append(flags, "--"+n)
+			}
+			if f.short != 0 {
+				flags = append(flags, "-"+string(f.short))
+			}
+		}
+		fmt.Fprintf(sb, "\t%q) echo %q ;;\n", c.path, strings.Join(flags, " "))
+	}
+	sb.WriteString("\tesac\n}\n\n")
+
+	// the values are printed as words, or as !files and !none, nothing is printed for the flags without value
+	reply := func(complete string, values []string) string {
+		switch {
+		case complete == completeModels:
+			return models
+		case complete == completeFiles:
+			return "echo '!files'"
+		case len(values) > 0:
+			return fmt.Sprintf("echo %q", strings.Join(values, " "))
+		}
+		return "echo '!none'"
+	}
+	sb.WriteString("_local_ai_values() {\n\tcase \"$1|$2\" in\n")
+	for _, c := range commands {
+		for _, f := range c.flags {
+			if !f.value {
+				continue
+			}
+			patterns := []string{}
+			for _, n := range f.names {
+				patterns = append(patterns, fmt.Sprintf("%q", c.path+"|--"+n))
+			}
+			if f.short != 0 {
+				patterns = append(patterns, fmt.Sprintf("%q", c.path+"|-"+string(f.short)))
+			}
+			fmt.Fprintf(sb, "\t%s) %s ;;\n", strings.Join(patterns, "|"), reply(f.complete, f.values))
+		}
+	}
+	sb.WriteString("\tesac\n}\n\n")
+
+	sb.WriteString("_local_ai_args() {\n\tcase \"$1\" in\n")
+	for _, c := range commands {
+		if c.args != "" {
+			fmt.Fprintf(sb, "\t%q) %s ;;\n", c.path, reply(c.args, nil))
+		}
+	}
+	sb.WriteString("\tesac\n}\n\n")
+
+	sb.WriteString(`_local_ai_reply() {
+	case "$1" in
+	'!files') COMPREPLY=($(compgen -f -- "$cur")) ;;
+	'!none') COMPREPLY=() ;;
+	*) COMPREPLY=($(compgen -W "$1" -- "$cur")) ;;
+	esac
+}
+
+_local_ai() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" cmd="" word values i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		word="${COMP_WORDS[i]}"
+		if [[ " $(_local_ai_commands "$cmd") " == *" $word "* ]]; then
+			cmd="${cmd:+$cmd }$word"
+		fi
+	done
+
+	if [[ "$prev" == -* && "$cur" != -* ]]; then
+		values="$(_local_ai_values "$cmd" "$prev")"
+		if [[ -n "$values" ]]; then
+			_local_ai_reply "$values"
+			return
+		fi
+	fi
+	if [[ "$cur" == -* ]]; then
+		_local_ai_reply "$(_local_ai_flags "$cmd")"
+		return
+	fi
+	values="$(_local_ai_commands "$cmd")"
+	if [[ -z "$values" ]]; then
+		values="$(_local_ai_args "$cmd")"
+	fi
+	if [[ -n "$values" ]]; then
+		_local_ai_reply "$values"
+	fi
+}
+
+`)
+	fmt.Fprintf(sb, "complete -o default -F _local_ai %s\n", name)
+	return sb.String()
+}
+
+// fishCompletion returns the fish completion script, with the descriptions of the commands and flags
+func fishCompletion(name string, commands []*completionCommand) string {
+	models := fmt.Sprintf("(%s completion installed-models 2>/dev/null)", name)
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "# fish completion for %s, generated by '%s completion fish'\n\n", name, name)
+
+	sb.WriteString("function __local_ai_commands\n\tswitch \"$argv[1]\"\n")
+	for _, c := range commands {
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(sb, "\tcase %s\n\t\tprintf '%%s\\n' %s\n", fishQuote(c.path), strings.Join(c.subcommandNames(), " "))
+		}
+	}
+	sb.WriteString("\tend\nend\n\n")
+
+	sb.WriteString(`# __local_ai_using succeeds when the command being completed is the one given
+function __local_ai_using
+	set -l cmd
+	for word in (commandline -opc)[2..-1]
+		if contains -- $word (__local_ai_commands "$cmd")
+			set cmd (string trim -- "$cmd $word")
+		end
+	end
+	test "$cmd" = "$argv[1]"
+end
+
+`)
+	fmt.Fprintf(sb, "complete -c %s -f\n", name)
+	for _, c := range commands {
+		using := fmt.Sprintf("complete -c %s -n \"__local_ai_using '%s'\"", name, c.path)
+		for _, sub := range c.subcommands {
+			fmt.Fprintf(sb, "%s -a %s -d %s\n", using, fishQuote(sub.path[strings.LastIndex(sub.path, " ")+1:]), fishQuote(firstLine(sub.help)))
+		}
+		switch c.args {
+		case completeModels:
+			fmt.Fprintf(sb, "%s -a '%s'\n", using, models)
+		case completeFiles:
+			fmt.Fprintf(sb, "%s -F\n", using)
+		}
+		for _, f := range c.flags {
+			line := using
+			for _, n := range f.names {
+				line += " -l " + n
+			}
+			if f.short != 0 {
+				line += " -s " + string(f.short)
+			}
+			switch {
+			case !f.value:
+			case f.complete == completeModels:
+				line += fmt.Sprintf(" -x -a '%s'", models)
+			case f.complete == completeFiles:
+				line += " -r -F"
+			case len(f.values) > 0:
+				line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+			default:
+				line += " -x"
+			}
+			fmt.Fprintf(sb, "%s -d %s\n", line, fishQuote(firstLine(f.help)))
+		}
+	}
+	return sb.String()
+}
+
+// fishQuote quotes a string for fish, which only escapes the backslashes and the quotes in single quotes
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
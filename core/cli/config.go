@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+)
+
+type ConfigValidateCMD struct {
+	Paths []string `arg:"" optional:"" type:"path" help:"Model configuration files or directories to validate, defaults to the models path"`
+
+	ModelsPath       string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+	ModelsConfigFile string `env:"LOCALAI_MODELS_CONFIG_FILE,CONFIG_FILE" aliases:"config-file" help:"YAML file containing a list of model backend configs" group:"storage"`
+}
+
+type ConfigCMD struct {
+	Validate ConfigValidateCMD `cmd:"" help:"Check the model configurations for errors, unknown fields, missing files and duplicate names"`
+}
+
+// configValidateResult is the JSON result of 'config validate'
+type configValidateResult struct {
+	Files    int                  `json:"files"`
+	Errors   int                  `json:"errors"`
+	Warnings int                  `json:"warnings"`
+	Issues   []config.ConfigIssue `json:"issues"`
+}
+
+func (cv *ConfigValidateCMD) Run(ctx *cliContext.Context) error {
+	paths := cv.Paths
+	if len(paths) == 0 {
+		paths = []string{cv.ModelsPath}
+	}
+
+	v := config.NewConfigValidator(cv.ModelsPath)
+	files := 0
+	if cv.ModelsConfigFile != "" {
+		v.ValidateMultipleFile(cv.ModelsConfigFile)
+		files++
+	}
+	for _, path := range paths {
+		configs, err := configFiles(path)
+		if err != nil {
+			return err
+		}
+		for _, file := range configs {
+			v.ValidateFile(file)
+		}
+		files += len(configs)
+	}
+
+	result := configValidateResult{Files: files, Errors: v.Errors(), Issues: v.Issues}
+	result.Warnings = len(v.Issues) - result.Errors
+	if result.Issues == nil {
+		result.Issues = []config.ConfigIssue{}
+	}
+	if err := printResult(ctx, result, func() {
+		for _, issue := range v.Issues {
+			fmt.Println(issue)
+		}
+		fmt.Printf("%d files checked, %d errors, %d warnings\n", result.Files, result.Errors, result.Warnings)
+	}); err != nil {
+		return err
+	}
+
+	if result.Errors > 0 {
+		return fmt.Errorf("%d errors found in the model configurations", result.Errors)
+	}
+	return nil
+}
+
+// configFiles returns the YAML files of a directory, skipping the hidden ones like the config loader does, or the
+// path itself when it's a file
+func configFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+			continue
+		}
+		files = append(files, filepath.Join(path, name))
+	}
+	return files, nil
+}
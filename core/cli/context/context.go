@@ -5,7 +5,13 @@ import "embed"
 type Context struct {
 	Debug    bool    `env:"LOCALAI_DEBUG,DEBUG" default:"false" hidden:"" help:"DEPRECATED, use --log-level=debug instead. Enable debug logging"`
 	LogLevel *string `env:"LOCALAI_LOG_LEVEL" enum:"error,warn,info,debug,trace" help:"Set the level of logs to output [${enum}]"`
+	Output   string  `env:"LOCALAI_OUTPUT" enum:"text,json" default:"text" help:"Format of the results of the commands, json is meant for scripts [${enum}]"`
 
 	// This field is not a command line argument/flag, the struct tag excludes it from the parsed CLI
 	BackendAssets embed.FS `kong:"-"`
 }
+
+// JSONOutput returns whether the results of the commands are printed as JSON
+func (c *Context) JSONOutput() bool {
+	return c.Output == "json"
+}
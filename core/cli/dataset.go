@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+type DatasetExportCMD struct {
+	File string `arg:"" optional:"" type:"path" help:"JSONL file the dataset is written to, defaults to stdout"`
+
+	Model     string            `help:"Export only the completions of this model"`
+	APIKey    string            `help:"Export only the completions requested with this API key, by its name or the prefix of its hash shown in the audit log"`
+	MinRating float64           `help:"Export only the completions with a rating metadata of at least this value"`
+	Since     string            `help:"Export only the completions created since this date: unix seconds, RFC3339 or YYYY-MM-DD"`
+	Until     string            `help:"Export only the completions created before this date: unix seconds, RFC3339 or YYYY-MM-DD"`
+	Metadata  map[string]string `help:"Export only the completions with these metadata, as key=value"`
+	Redact    []string          `help:"Redactors of the personal information in the texts: email, phone, ip, credit_card"`
+
+	ConfigPath string `env:"LOCALAI_CONFIG_PATH,CONFIG_PATH" default:"/tmp/localai/config" help:"Configuration path of LocalAI, holding the stored completions" group:"storage"`
+}
+
+type DatasetCMD struct {
+	Export DatasetExportCMD `cmd:"" help:"Export the chat completions stored with 'store: true' as a JSONL fine-tuning dataset in the OpenAI chat format"`
+}
+
+// datasetExportResult is the JSON result of 'dataset export'
+type datasetExportResult struct {
+	File     string `json:"file,omitempty"`
+	Examples int    `json:"examples"`
+}
+
+func (de *DatasetExportCMD) Run(ctx *cliContext.Context) error {
+	filter := services.DatasetFilter{
+		Model:     de.Model,
+		APIKey:    de.APIKey,
+		MinRating: de.MinRating,
+		Metadata:  de.Metadata,
+	}
+	var err error
+	if filter.Since, err = services.ParseTimeBound(de.Since); err != nil {
+		return err
+	}
+	if filter.Until, err = services.ParseTimeBound(de.Until); err != nil {
+		return err
+	}
+	redactors, err := services.Redactors(de.Redact)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if de.File != "" {
+		f, err := os.Create(de.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	completions := services.NewStoredCompletionsService(&config.ApplicationConfig{ConfigsDir: de.ConfigPath})
+	examples, err := completions.ExportDataset(w, filter, redactors...)
+	if err != nil {
+		return err
+	}
+
+	// without a file the dataset is written on stdout, which has to stay valid JSONL
+	if de.File == "" {
+		return nil
+	}
+	return printResult(ctx, datasetExportResult{File: de.File, Examples: examples}, func() {
+		fmt.Printf("%d examples exported to %s\n", examples, de.File)
+	})
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/klauspost/cpuid/v2"
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/assets"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+const (
+	doctorOK      = "ok"
+	doctorWarning = "warning"
+	doctorError   = "error"
+)
+
+type DoctorCMD struct {
+	Address           string `env:"LOCALAI_ADDRESS,ADDRESS" default:":8080" help:"Bind address for the API server, checked to be available"`
+	MinFreeSpace      int    `default:"10" help:"Free disk space, in GiB, below which the models and backend assets paths are reported"`
+	ModelsPath        string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+	BackendAssetsPath string `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
+}
+
+// doctorCheck is the result of a check of 'doctor'
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// doctorReport is the JSON result of 'doctor'
+type doctorReport struct {
+	Version string        `json:"version"`
+	OS      string        `json:"os"`
+	Arch    string        `json:"arch"`
+	Checks  []doctorCheck `json:"checks"`
+}
+
+func (d *DoctorCMD) Run(ctx *cliContext.Context) error {
+	report := doctorReport{Version: internal.PrintableVersion(), OS: runtime.GOOS, Arch: runtime.GOARCH}
+	check := func(name, status, format string, args ...interface{}) {
+		report.Checks = append(report.Checks, doctorCheck{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// CPU: the llama.cpp builds are optimized for these extensions, running them without crashes the backend
+	if runtime.GOARCH == "amd64" {
+		missing := xsysinfo.MissingCPUCaps(cpuid.AVX, cpuid.AVX2, cpuid.FMA3, cpuid.F16C)
+		switch {
+		case len(missing) == 0 && xsysinfo.HasCPUCaps(cpuid.AVX512F):
+			check("cpu", doctorOK, "%s supports AVX, AVX2 and AVX512", cpuid.CPU.BrandName)
+		case len(missing) == 0:
+			check("cpu", doctorOK, "%s supports AVX and AVX2", cpuid.CPU.BrandName)
+		default:
+			check("cpu", doctorWarning, "%s lacks %s, llama.cpp needs a variant built without them", cpuid.CPU.BrandName, strings.Join(missing, ", "))
+		}
+	} else {
+		check("cpu", doctorOK, "%s (%s)", cpuid.CPU.BrandName, runtime.GOARCH)
+	}
+
+	d.checkGPUs(check)
+
+	// backend assets: extracted like at startup, then the llama.cpp variant is chosen for this CPU
+	if err := assets.ExtractFiles(ctx.BackendAssets, d.BackendAssetsPath); err != nil {
+		check("backend-assets", doctorError, "unable to extract the backend assets to %s: %s", d.BackendAssetsPath, err)
+	} else {
+		backends, _ := os.ReadDir(assets.ResolvePath(d.BackendAssetsPath, "grpc"))
+		if len(backends) == 0 {
+			check("backend-assets", doctorWarning, "no backend in %s, this build only runs external backends", d.BackendAssetsPath)
+		} else {
+			check("backend-assets", doctorOK, "%d backends extracted to %s", len(backends), d.BackendAssetsPath)
+		}
+		if variant, err := model.LLamaCPPCPUVariant(d.BackendAssetsPath); err != nil {
+			check("llama-cpp", doctorError, "%s", err)
+		} else if variant != "" {
+			check("llama-cpp", doctorOK, "the %s variant runs on this CPU", variant)
+		}
+	}
+
+	d.checkModelsPath(check)
+
+	for _, path := range []string{d.ModelsPath, d.BackendAssetsPath} {
+		usage, err := disk.Usage(path)
+		switch {
+		case err != nil:
+			check("disk-space", doctorWarning, "unable to read the free space of %s: %s", path, err)
+		case usage.Free < uint64(d.MinFreeSpace)<<30:
+			check("disk-space", doctorWarning, "only %s free in %s", formatBytes(usage.Free), path)
+		default:
+			check("disk-space", doctorOK, "%s free in %s", formatBytes(usage.Free), path)
+		}
+	}
+
+	if l, err := net.Listen("tcp", d.Address); err != nil {
+		check("port", doctorError, "unable to listen on %s: %s", d.Address, err)
+	} else {
+		l.Close()
+		check("port", doctorOK, "%s is available", d.Address)
+	}
+
+	failed := 0
+	for _, c := range report.Checks {
+		if c.Status == doctorError {
+			failed++
+		}
+	}
+	if err := printResult(ctx, report, func() {
+		fmt.Printf("LocalAI %s (%s/%s)\n\n", report.Version, report.OS, report.Arch)
+		for _, c := range report.Checks {
+			fmt.Printf("%-8s %-15s %s\n", "["+c.Status+"]", c.Name, c.Message)
+		}
+	}); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+// checkGPUs reports the GPUs, and whether the tools of their driver are available
+func (d *DoctorCMD) checkGPUs(check func(name, status, format string, args ...interface{})) {
+	gpus, err := xsysinfo.GPUs()
+	if err != nil {
+		check("gpu", doctorWarning, "unable to list the GPUs: %s", err)
+		return
+	}
+
+	found := false
+	for _, g := range gpus {
+		if g.DeviceInfo == nil || g.DeviceInfo.Vendor == nil || g.DeviceInfo.Product == nil {
+			continue
+		}
+		found = true
+		name := strings.TrimSpace(g.DeviceInfo.Vendor.Name + " " + g.DeviceInfo.Product.Name)
+		vendor := strings.ToLower(g.DeviceInfo.Vendor.Name)
+		switch {
+		case strings.Contains(vendor, "nvidia"):
+			if out, err := exec.Command("nvidia-smi", "--query-gpu=driver_version", "--format=csv,noheader").Output(); err != nil {
+				check("gpu", doctorWarning, "%s: the NVIDIA driver is not available (nvidia-smi: %s), models run on the CPU", name, err)
+			} else {
+				check("gpu", doctorOK, "%s, NVIDIA driver %s", name, strings.TrimSpace(strings.Split(string(out), "\n")[0]))
+			}
+		case strings.Contains(vendor, "amd") || strings.Contains(vendor, "advanced micro devices"):
+			if _, err := os.Stat("/dev/kfd"); err != nil {
+				check("gpu", doctorWarning, "%s: the ROCm driver is not available (/dev/kfd is missing), models run on the CPU", name)
+			} else {
+				check("gpu", doctorOK, "%s, ROCm driver loaded", name)
+			}
+		case strings.Contains(vendor, "intel"):
+			if _, err := os.Stat("/dev/dri"); err != nil {
+				check("gpu", doctorWarning, "%s: /dev/dri is missing, models run on the CPU", name)
+			} else {
+				check("gpu", doctorOK, "%s, /dev/dri available", name)
+			}
+		default:
+			check("gpu", doctorOK, "%s", name)
+		}
+	}
+	if !found {
+		check("gpu", doctorOK, "no GPU found, models run on the CPU")
+	}
+}
+
+// checkModelsPath checks that the models path is a directory LocalAI can write the downloaded models to
+func (d *DoctorCMD) checkModelsPath(check func(name, status, format string, args ...interface{})) {
+	fi, err := os.Stat(d.ModelsPath)
+	switch {
+	case os.IsNotExist(err):
+		check("models-path", doctorWarning, "%s does not exist, it's created at startup", d.ModelsPath)
+		return
+	case err != nil:
+		check("models-path", doctorError, "%s", err)
+		return
+	case !fi.IsDir():
+		check("models-path", doctorError, "%s is not a directory", d.ModelsPath)
+		return
+	}
+
+	f, err := os.CreateTemp(d.ModelsPath, ".doctor-*")
+	if err != nil {
+		check("models-path", doctorError, "%s is not writable, the models can't be installed: %s", d.ModelsPath, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	entries, _ := os.ReadDir(d.ModelsPath)
+	configs := 0
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); ext == ".yaml" || ext == ".yml" {
+			configs++
+		}
+	}
+	check("models-path", doctorOK, "%s is writable, %d model configurations", d.ModelsPath, configs)
+}
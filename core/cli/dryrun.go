@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"gopkg.in/yaml.v3"
+)
+
+// effectiveConfig is the configuration 'run --dry-run' resolved from the flags, the environment and the
+// defaults. The API keys and the tokens are not printed, only whether they are set
+type effectiveConfig struct {
+	ModelsPath             string            `json:"models_path" yaml:"models_path"`
+	ModelsConfigFile       string            `json:"models_config_file,omitempty" yaml:"models_config_file,omitempty"`
+	BackendAssetsPath      string            `json:"backend_assets_path" yaml:"backend_assets_path"`
+	LibraryPath            string            `json:"library_path" yaml:"library_path"`
+	ImagePath              string            `json:"image_path" yaml:"image_path"`
+	AudioPath              string            `json:"audio_path" yaml:"audio_path"`
+	UploadPath             string            `json:"upload_path" yaml:"upload_path"`
+	ConfigPath             string            `json:"config_path" yaml:"config_path"`
+	DynamicConfigDir       string            `json:"dynamic_config_dir" yaml:"dynamic_config_dir"`
+	DiagnosticsPath        string            `json:"diagnostics_path,omitempty" yaml:"diagnostics_path,omitempty"`
+	BackendStatePath       string            `json:"backend_state_path,omitempty" yaml:"backend_state_path,omitempty"`
+	StorageURL             string            `json:"storage_url,omitempty" yaml:"storage_url,omitempty"`
+	Galleries              []config.Gallery  `json:"galleries" yaml:"galleries"`
+	Models                 []string          `json:"models,omitempty" yaml:"models,omitempty"`
+	Threads                int               `json:"threads" yaml:"threads"`
+	ContextSize            int               `json:"context_size" yaml:"context_size"`
+	F16                    bool              `json:"f16" yaml:"f16"`
+	Address                string            `json:"address" yaml:"address"`
+	GRPCAddress            string            `json:"grpc_address,omitempty" yaml:"grpc_address,omitempty"`
+	TLS                    bool              `json:"tls" yaml:"tls"`
+	MutualTLS              bool              `json:"mutual_tls" yaml:"mutual_tls"`
+	CORS                   bool              `json:"cors" yaml:"cors"`
+	CORSAllowOrigins       string            `json:"cors_allow_origins,omitempty" yaml:"cors_allow_origins,omitempty"`
+	CSRF                   bool              `json:"csrf" yaml:"csrf"`
+	UploadLimitMB          int               `json:"upload_limit_mb" yaml:"upload_limit_mb"`
+	ReadTimeout            string            `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout           string            `json:"write_timeout" yaml:"write_timeout"`
+	UploadTimeout          string            `json:"upload_timeout" yaml:"upload_timeout"`
+	StreamTimeout          string            `json:"stream_timeout" yaml:"stream_timeout"`
+	APIKeys                int               `json:"api_keys" yaml:"api_keys"`
+	AdminAPIKeys           int               `json:"admin_api_keys" yaml:"admin_api_keys"`
+	TokenBudget            int               `json:"token_budget,omitempty" yaml:"token_budget,omitempty"`
+	MemoryModel            string            `json:"memory_model,omitempty" yaml:"memory_model,omitempty"`
+	DisableWebUI           bool              `json:"disable_webui" yaml:"disable_webui"`
+	DisableGallery         bool              `json:"disable_gallery_endpoint" yaml:"disable_gallery_endpoint"`
+	UploadScanner          string            `json:"upload_scanner,omitempty" yaml:"upload_scanner,omitempty"`
+	OpaqueErrors           bool              `json:"opaque_errors" yaml:"opaque_errors"`
+	PrivacyMode            bool              `json:"privacy_mode" yaml:"privacy_mode"`
+	PrivacyAllowedHosts    []string          `json:"privacy_allowed_hosts,omitempty" yaml:"privacy_allowed_hosts,omitempty"`
+	P2P                    bool              `json:"p2p" yaml:"p2p"`
+	P2PNetworkID           string            `json:"p2p_network_id,omitempty" yaml:"p2p_network_id,omitempty"`
+	Federated              bool              `json:"federated" yaml:"federated"`
+	ExternalBackends       map[string]string `json:"external_backends,omitempty" yaml:"external_backends,omitempty"`
+	ExternalBackendTLS     bool              `json:"external_backend_tls" yaml:"external_backend_tls"`
+	ParallelRequests       bool              `json:"parallel_requests" yaml:"parallel_requests"`
+	SingleActiveBackend    bool              `json:"single_active_backend" yaml:"single_active_backend"`
+	AdaptiveThreads        bool              `json:"adaptive_threads" yaml:"adaptive_threads"`
+	PrefetchModels         bool              `json:"prefetch_models" yaml:"prefetch_models"`
+	ModelMetrics           bool              `json:"model_metrics" yaml:"model_metrics"`
+	OTelEndpoint           string            `json:"otel_endpoint,omitempty" yaml:"otel_endpoint,omitempty"`
+	AuditLog               string            `json:"audit_log,omitempty" yaml:"audit_log,omitempty"`
+	AuditLogRedact         bool              `json:"audit_log_redact" yaml:"audit_log_redact"`
+	WatchdogIdleTimeout    string            `json:"watchdog_idle_timeout,omitempty" yaml:"watchdog_idle_timeout,omitempty"`
+	WatchdogBusyTimeout    string            `json:"watchdog_busy_timeout,omitempty" yaml:"watchdog_busy_timeout,omitempty"`
+	AutoShutdownAfter      string            `json:"auto_shutdown_after,omitempty" yaml:"auto_shutdown_after,omitempty"`
+	AutoloadGalleries      bool              `json:"autoload_galleries" yaml:"autoload_galleries"`
+	PreloadParallelism     int               `json:"preload_parallelism" yaml:"preload_parallelism"`
+	PreloadBackendOnly     bool              `json:"preload_backend_only" yaml:"preload_backend_only"`
+	EnforcePredownloadScan bool              `json:"enforce_predownload_scan" yaml:"enforce_predownload_scan"`
+}
+
+// backendCheck is the result of the check of an external backend
+type backendCheck struct {
+	Name    string `json:"name" yaml:"name"`
+	URI     string `json:"uri" yaml:"uri"`
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// dryRunReport is the result of 'run --dry-run'
+type dryRunReport struct {
+	Config   effectiveConfig      `json:"config" yaml:"config"`
+	Issues   []config.ConfigIssue `json:"issues" yaml:"issues"`
+	Backends []backendCheck       `json:"external_backends" yaml:"external_backends"`
+}
+
+// dryRun prints the configuration resolved from the options, after checking the model configurations and the
+// external backends, without starting the server nor loading any model
+func (r *RunCMD) dryRun(ctx *cliContext.Context, opts []config.AppOption) error {
+	o := config.NewApplicationConfig(opts...)
+
+	report := dryRunReport{
+		Config: effectiveConfig{
+			ModelsPath:             o.ModelPath,
+			ModelsConfigFile:       o.ConfigFile,
+			BackendAssetsPath:      o.AssetsDestination,
+			LibraryPath:            o.LibPath,
+			ImagePath:              o.ImageDir,
+			AudioPath:              o.AudioDir,
+			UploadPath:             o.UploadDir,
+			ConfigPath:             o.ConfigsDir,
+			DynamicConfigDir:       o.DynamicConfigsDir,
+			DiagnosticsPath:        o.DiagnosticsDir,
+			BackendStatePath:       o.BackendStateDir,
+			StorageURL:             r.StorageURL,
+			Galleries:              o.Galleries,
+			Models:                 o.ModelsURL,
+			Threads:                o.Threads,
+			ContextSize:            o.ContextSize,
+			F16:                    o.F16,
+			Address:                r.Address,
+			GRPCAddress:            r.GRPCAddress,
+			TLS:                    r.TLSCert != "",
+			MutualTLS:              r.TLSClientCA != "",
+			CORS:                   o.CORS,
+			CORSAllowOrigins:       o.CORSAllowOrigins,
+			CSRF:                   o.CSRF,
+			UploadLimitMB:          o.UploadLimitMB,
+			ReadTimeout:            o.ReadTimeout.String(),
+			WriteTimeout:           o.WriteTimeout.String(),
+			UploadTimeout:          o.UploadTimeout.String(),
+			StreamTimeout:          o.StreamTimeout.String(),
+			APIKeys:                len(o.ApiKeys),
+			AdminAPIKeys:           len(o.AdminApiKeys),
+			TokenBudget:            o.TokenBudget,
+			MemoryModel:            o.MemoryModel,
+			DisableWebUI:           o.DisableWebUI,
+			DisableGallery:         o.DisableGalleryEndpoint,
+			UploadScanner:          r.UploadScanner,
+			OpaqueErrors:           o.OpaqueErrors,
+			PrivacyMode:            o.NetworkGuard != nil,
+			PrivacyAllowedHosts:    r.PrivacyAllowedHosts,
+			P2P:                    r.Peer2Peer || r.Peer2PeerToken != "",
+			P2PNetworkID:           o.P2PNetworkID,
+			Federated:              r.Federated,
+			ExternalBackends:       o.ExternalGRPCBackends,
+			ExternalBackendTLS:     o.ExternalGRPCBackendsCredentials != nil && o.ExternalGRPCBackendsCredentials.CACert != "",
+			ParallelRequests:       o.ParallelBackendRequests,
+			SingleActiveBackend:    o.SingleBackend,
+			AdaptiveThreads:        o.AdaptiveThreads,
+			PrefetchModels:         o.PrefetchModels,
+			ModelMetrics:           o.ModelMetrics,
+			OTelEndpoint:           o.OTelEndpoint,
+			AuditLog:               o.AuditLog,
+			AuditLogRedact:         o.AuditLogRedact,
+			AutoloadGalleries:      o.AutoloadGalleries,
+			PreloadParallelism:     o.PreloadParallelism,
+			PreloadBackendOnly:     r.PreloadBackendOnly,
+			EnforcePredownloadScan: o.EnforcePredownloadScans,
+		},
+		Issues:   []config.ConfigIssue{},
+		Backends: []backendCheck{},
+	}
+	if o.WatchDogIdle {
+		report.Config.WatchdogIdleTimeout = o.WatchDogIdleTimeout.String()
+	}
+	if o.WatchDogBusy {
+		report.Config.WatchdogBusyTimeout = o.WatchDogBusyTimeout.String()
+	}
+	if o.AutoShutdownAfter > 0 {
+		report.Config.AutoShutdownAfter = o.AutoShutdownAfter.String()
+	}
+
+	v := config.NewConfigValidator(o.ModelPath)
+	if o.ConfigFile != "" {
+		v.ValidateMultipleFile(o.ConfigFile)
+	}
+	if _, err := os.Stat(o.ModelPath); err == nil {
+		configs, err := configFiles(o.ModelPath)
+		if err != nil {
+			return err
+		}
+		for _, file := range configs {
+			v.ValidateFile(file)
+		}
+	}
+	if v.Issues != nil {
+		report.Issues = v.Issues
+	}
+
+	failed := 0
+	names := []string{}
+	for name := range o.ExternalGRPCBackends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		check := checkExternalBackend(name, o.ExternalGRPCBackends[name], o.ExternalGRPCBackendsCredentials)
+		if check.Status == doctorError {
+			failed++
+		}
+		report.Backends = append(report.Backends, check)
+	}
+
+	if err := printResult(ctx, report, func() {
+		out, _ := yaml.Marshal(report)
+		fmt.Print(string(out))
+	}); err != nil {
+		return err
+	}
+
+	switch {
+	case v.Errors() > 0:
+		return fmt.Errorf("%d errors found in the model configurations", v.Errors())
+	case failed > 0:
+		return fmt.Errorf("%d external backends are not available", failed)
+	}
+	return nil
+}
+
+// checkExternalBackend checks that an external backend can be started, or answers the health checks when it's
+// given by address
+func checkExternalBackend(name, uri string, credentials *grpc.Credentials) backendCheck {
+	check := backendCheck{Name: name, URI: uri, Status: doctorOK}
+	if fi, err := os.Stat(uri); err == nil {
+		if fi.IsDir() || fi.Mode()&0111 == 0 {
+			check.Status, check.Message = doctorError, "the file is not executable"
+		}
+		return check
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if ok, err := grpc.NewClient(uri, false, nil, false, credentials).HealthCheck(c); !ok {
+		check.Status, check.Message = doctorError, "the backend does not answer the health checks"
+		if err != nil {
+			check.Message += ": " + err.Error()
+		}
+	}
+	return check
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/backend"
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+type EmbeddingsCMD struct {
+	Text []string `arg:"" optional:"" help:"Text to embed, when no input file is given"`
+
+	Model             string `short:"m" required:"" completion:"models" help:"Model name to generate the embeddings"`
+	Backend           string `short:"b" help:"Backend to run the model, defaults to the one of its configuration"`
+	File              string `short:"f" type:"existingfile" help:"JSONL file with the texts to embed, one per line: either a JSON string or an object with \"text\" and an optional \"id\""`
+	OutputFile        string `short:"o" type:"path" help:"The path to write the embeddings to as JSONL, they are written on stdout otherwise"`
+	Threads           int    `short:"t" env:"LOCALAI_THREADS,THREADS" default:"4" help:"Number of threads used for parallel computation"`
+	ModelsPath        string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+	BackendAssetsPath string `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
+}
+
+// embeddingInput is a line of the input file
+type embeddingInput struct {
+	ID   string `json:"id,omitempty"`
+	Text string `json:"text"`
+}
+
+// embeddingOutput is a line of the output
+type embeddingOutput struct {
+	Index     int       `json:"index"`
+	ID        string    `json:"id,omitempty"`
+	Embedding []float32 `json:"embedding"`
+}
+
+func (e *EmbeddingsCMD) Run(ctx *cliContext.Context) error {
+	inputs, err := e.inputs()
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no text to embed, pass it as argument or with --file")
+	}
+
+	opts := &config.ApplicationConfig{
+		ModelPath:         e.ModelsPath,
+		Context:           context.Background(),
+		AssetsDestination: e.BackendAssetsPath,
+		Threads:           e.Threads,
+	}
+
+	cl := config.NewBackendConfigLoader(e.ModelsPath)
+	ml := model.NewModelLoader(opts.ModelPath)
+	if err := cl.LoadBackendConfigsFromPath(e.ModelsPath); err != nil {
+		return err
+	}
+
+	cfg, err := cl.LoadBackendConfigFileByName(e.Model, e.ModelsPath,
+		config.LoadOptionThreads(e.Threads),
+		config.ModelPath(e.ModelsPath),
+	)
+	if err != nil {
+		return err
+	}
+	if e.Backend != "" {
+		cfg.Backend = e.Backend
+	}
+	if !cfg.Validate() {
+		return fmt.Errorf("invalid configuration of the model %s", e.Model)
+	}
+
+	defer func() {
+		err := ml.StopAllGRPC()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to stop all grpc processes")
+		}
+	}()
+
+	var out io.Writer = os.Stdout
+	if e.OutputFile != "" {
+		f, err := os.Create(e.OutputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+
+	for i, in := range inputs {
+		embedFn, err := backend.ModelEmbedding(in.Text, []int{}, ml, *cfg, opts)
+		if err != nil {
+			return err
+		}
+		embedding, err := embedFn()
+		if err != nil {
+			return fmt.Errorf("embedding input %d: %w", i, err)
+		}
+		if err := enc.Encode(embeddingOutput{Index: i, ID: in.ID, Embedding: embedding}); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if e.OutputFile == "" {
+		return nil
+	}
+	return printResult(ctx, fileResult{File: e.OutputFile}, func() {
+		fmt.Printf("Generate file %s\n", e.OutputFile)
+	})
+}
+
+// inputs returns the texts to embed, from the input file or the arguments
+func (e *EmbeddingsCMD) inputs() ([]embeddingInput, error) {
+	if e.File == "" {
+		text := strings.Join(e.Text, " ")
+		if text == "" {
+			return nil, nil
+		}
+		return []embeddingInput{{Text: text}}, nil
+	}
+
+	f, err := os.Open(e.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	inputs := []embeddingInput{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxChatLine)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		in := embeddingInput{}
+		if strings.HasPrefix(line, "\"") {
+			err = json.Unmarshal([]byte(line), &in.Text)
+		} else {
+			err = json.Unmarshal([]byte(line), &in)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", e.File, n, err)
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, scanner.Err()
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/backend"
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+type ImageCMD struct {
+	Text []string `arg:""`
+
+	Backend           string `short:"b" help:"Backend to run the image generation model, defaults to the one of its configuration or stablediffusion"`
+	Model             string `short:"m" required:"" completion:"models" help:"Model name to generate the image"`
+	NegativePrompt    string `short:"n" help:"What should not appear in the image"`
+	Size              string `short:"s" default:"512x512" help:"Size of the image, as <width>x<height>"`
+	Step              int    `help:"Number of diffusion steps, defaults to the one of the model configuration or 15"`
+	Seed              *int   `help:"Seed of the generation, random by default"`
+	Threads           int    `short:"t" env:"LOCALAI_THREADS,THREADS" default:"4" help:"Number of threads used for parallel computation"`
+	OutputFile        string `short:"o" type:"path" help:"The path to write the output png file"`
+	ModelsPath        string `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+	BackendAssetsPath string `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
+}
+
+func (i *ImageCMD) Run(ctx *cliContext.Context) error {
+	width, height, err := parseImageSize(i.Size)
+	if err != nil {
+		return err
+	}
+
+	outputFile := i.OutputFile
+	outputDir := i.BackendAssetsPath
+	if outputFile != "" {
+		outputDir = filepath.Dir(outputFile)
+	}
+
+	opts := &config.ApplicationConfig{
+		ModelPath:         i.ModelsPath,
+		Context:           context.Background(),
+		ImageDir:          outputDir,
+		AssetsDestination: i.BackendAssetsPath,
+		Threads:           i.Threads,
+	}
+
+	cl := config.NewBackendConfigLoader(i.ModelsPath)
+	ml := model.NewModelLoader(opts.ModelPath)
+	if err := cl.LoadBackendConfigsFromPath(i.ModelsPath); err != nil {
+		return err
+	}
+
+	cfg, err := cl.LoadBackendConfigFileByName(i.Model, i.ModelsPath,
+		config.LoadOptionThreads(i.Threads),
+		config.ModelPath(i.ModelsPath),
+	)
+	if err != nil {
+		return err
+	}
+	if i.Backend != "" {
+		cfg.Backend = i.Backend
+	}
+	switch cfg.Backend {
+	case "stablediffusion", "":
+		cfg.Backend = model.StableDiffusionBackend
+	case "tinydream":
+		cfg.Backend = model.TinyDreamBackend
+	}
+
+	step := i.Step
+	if step == 0 {
+		step = cfg.Step
+	}
+	if step == 0 {
+		step = 15
+	}
+	seed := *cfg.Seed
+	if i.Seed != nil {
+		seed = *i.Seed
+	}
+
+	defer func() {
+		err := ml.StopAllGRPC()
+		if err != nil {
+			log.Error().Err(err).Msg("unable to stop all grpc processes")
+		}
+	}()
+
+	if err := os.MkdirAll(outputDir, 0750); err != nil {
+		return err
+	}
+	if outputFile == "" {
+		f, err := os.CreateTemp(outputDir, "image-*.png")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		outputFile = f.Name()
+	}
+
+	fn, err := backend.ImageGeneration(height, width, 0, step, seed, strings.Join(i.Text, " "), i.NegativePrompt, "", outputFile, ml, *cfg, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return printResult(ctx, fileResult{File: outputFile}, func() {
+		fmt.Printf("Generate file %s\n", outputFile)
+	})
+}
+
+// parseImageSize parses a <width>x<height> image size
+func parseImageSize(size string) (int, int, error) {
+	w, h, ok := strings.Cut(size, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid image size %q, expected <width>x<height>", size)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid image width %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid image height %q", h)
+	}
+	return width, height, nil
+}
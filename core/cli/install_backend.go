@@ -0,0 +1,340 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/assets"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/library"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/phayes/freeport"
+)
+
+const (
+	backendBundled = "bundled"
+	backendPython  = "python"
+
+	// pythonBackendsDir is the directory of the backend assets path holding the python backends, next to the
+	// common scripts their run.sh and install.sh source
+	pythonBackendsDir = "python"
+)
+
+type InstallBackendCMD struct {
+	Backend           string        `arg:"" help:"Backend to install: a python backend (e.g. vllm, diffusers), or a backend bundled in the binary (e.g. whisper)"`
+	BackendAssetsPath string        `env:"LOCALAI_BACKEND_ASSETS_PATH,BACKEND_ASSETS_PATH" type:"path" default:"/tmp/localai/backend_data" help:"Path used to extract libraries that are required by some of the backends in runtime" group:"storage"`
+	LocalaiConfigDir  string        `env:"LOCALAI_CONFIG_DIR" type:"path" default:"${basepath}/configuration" help:"Directory for dynamic loading of certain configuration files, the python backends are registered in its external_backends.json" group:"storage"`
+	Source            string        `type:"path" help:"Checkout of the LocalAI repository providing the sources of the python backends, downloaded at the commit of this binary by default"`
+	Ref               string        `help:"Git ref of the sources downloaded, the commit of this binary by default, else master"`
+	BuildType         string        `env:"BUILD_TYPE" help:"Build type of the python environment (cublas, hipblas, intel...), the install script detects it by default"`
+	SmokeTestTimeout  time.Duration `default:"3m" help:"Time the backend has to answer its health check once started"`
+	SkipSmokeTest     bool          `help:"Install the backend without starting it"`
+}
+
+// installedBackend is the JSON result of 'util install-backend'
+type installedBackend struct {
+	Backend string `json:"backend"`
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	// Registered is the external_backends.json the python backends are registered in
+	Registered string `json:"registered,omitempty"`
+	SmokeTest  string `json:"smoke_test"`
+}
+
+func (u *InstallBackendCMD) Run(ctx *cliContext.Context) error {
+	if u.Backend == "" || u.Backend != filepath.Base(u.Backend) || strings.HasPrefix(u.Backend, ".") {
+		return fmt.Errorf("invalid backend name %q", u.Backend)
+	}
+	out := progressOutput(ctx)
+	stage := func(format string, args ...interface{}) {
+		fmt.Fprintf(out, "==> "+format+"\n", args...)
+	}
+
+	// the backends bundled in the binary only need to be extracted, like at startup
+	stage("Extracting the backend assets to %s", u.BackendAssetsPath)
+	if err := assets.ExtractFiles(ctx.BackendAssets, u.BackendAssetsPath); err != nil {
+		return fmt.Errorf("unable to extract the backend assets: %w", err)
+	}
+	result := installedBackend{Backend: u.Backend, Kind: backendBundled, Path: u.bundledBackend()}
+
+	if result.Path == "" {
+		result.Kind = backendPython
+		if _, err := exec.LookPath("uv"); err != nil {
+			return fmt.Errorf("%s is not bundled in this binary, and uv is required to install it as a python backend: see https://docs.astral.sh/uv/", u.Backend)
+		}
+
+		dir := filepath.Join(u.BackendAssetsPath, pythonBackendsDir)
+		stage("Fetching the sources of %s", u.Backend)
+		if err := u.fetchSources(dir); err != nil {
+			return err
+		}
+
+		stage("Installing the python environment of %s, this can take a while", u.Backend)
+		install := exec.Command("bash", "install.sh")
+		install.Dir = filepath.Join(dir, u.Backend)
+		install.Env = os.Environ()
+		if u.BuildType != "" {
+			install.Env = append(install.Env, "BUILD_TYPE="+u.BuildType)
+		}
+		install.Stdout, install.Stderr = out, os.Stderr
+		if err := install.Run(); err != nil {
+			return fmt.Errorf("the install script of %s failed: %w", u.Backend, err)
+		}
+		result.Path = filepath.Join(install.Dir, "run.sh")
+
+		stage("Registering %s in %s", u.Backend, u.LocalaiConfigDir)
+		registered, err := registerExternalBackend(u.LocalaiConfigDir, u.Backend, result.Path)
+		if err != nil {
+			return fmt.Errorf("unable to register %s: %w", u.Backend, err)
+		}
+		result.Registered = registered
+	}
+
+	result.SmokeTest = "skipped"
+	if !u.SkipSmokeTest {
+		stage("Starting %s and waiting for its health check", u.Backend)
+		process, args := result.Path, []string{}
+		if result.Kind == backendBundled {
+			args, process = library.LoadLDSO(u.BackendAssetsPath, args, process)
+		}
+		if err := smokeTestBackend(process, filepath.Dir(result.Path), args, u.SmokeTestTimeout); err != nil {
+			return fmt.Errorf("the smoke test of %s failed: %w", u.Backend, err)
+		}
+		result.SmokeTest = "passed"
+	}
+
+	return printResult(ctx, result, func() {
+		fmt.Printf("%s is installed in %s (smoke test %s)\n", u.Backend, result.Path, result.SmokeTest)
+		if result.Registered != "" {
+			fmt.Printf("It is registered in %s, 'local-ai run --localai-config-dir %s' uses it\n", result.Registered, u.LocalaiConfigDir)
+		}
+	})
+}
+
+// bundledBackend returns the path of the backend when it's bundled in the binary, the llama.cpp variant of this CPU
+// for llama-cpp
+func (u *InstallBackendCMD) bundledBackend() string {
+	name := u.Backend
+	if name == model.LLamaCPP {
+		if variant, err := model.LLamaCPPCPUVariant(u.BackendAssetsPath); err == nil && variant != "" {
+			name = variant
+		}
+	}
+	p := assets.ResolvePath(u.BackendAssetsPath, "grpc", name)
+	if info, err := os.Stat(p); err != nil || info.IsDir() {
+		return ""
+	}
+	return p
+}
+
+// fetchSources copies the sources of the python backend and their common scripts to dir, from the checkout or from
+// the archive of the repository
+func (u *InstallBackendCMD) fetchSources(dir string) error {
+	if u.Source != "" {
+		for _, d := range []string{"common", u.Backend} {
+			src := filepath.Join(u.Source, "backend", "python", d)
+			if _, err := os.Stat(filepath.Join(src, "install.sh")); d == u.Backend && err != nil {
+				return fmt.Errorf("%s is not a python backend of %s", u.Backend, u.Source)
+			}
+			if err := copyTree(src, filepath.Join(dir, d)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ref := u.Ref
+	if ref == "" {
+		ref = internal.Commit
+	}
+	if ref == "" {
+		ref = "master"
+	}
+	url := fmt.Sprintf("https://github.com/mudler/LocalAI/archive/%s.tar.gz", ref)
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("unable to download the sources: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to download the sources from %s: %s", url, resp.Status)
+	}
+	found, err := extractBackendSources(resp.Body, u.Backend, dir)
+	if err != nil {
+		return fmt.Errorf("unable to extract the sources from %s: %w", url, err)
+	}
+	if !found {
+		return fmt.Errorf("%s is neither bundled in this binary nor a python backend of LocalAI %s", u.Backend, ref)
+	}
+	return nil
+}
+
+// extractBackendSources extracts the sources of the python backend and their common scripts from the archive of the
+// repository to dir, and reports whether the backend is in the archive
+func extractBackendSources(r io.Reader, backend, dir string) (bool, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return false, err
+	}
+	defer gz.Close()
+
+	found := false
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return found, nil
+		}
+		if err != nil {
+			return found, err
+		}
+
+		// the entries are in the top directory of the archive, named after the ref
+		_, name, _ := strings.Cut(path.Clean(hdr.Name), "/")
+		name, ok := strings.CutPrefix(name, "backend/python/")
+		if !ok || !(strings.HasPrefix(name, backend+"/") || strings.HasPrefix(name, "common/")) {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0750); err != nil {
+				return found, err
+			}
+		case tar.TypeReg:
+			found = found || name == backend+"/install.sh"
+			if err := writeFile(target, tr, hdr.FileInfo().Mode()); err != nil {
+				return found, err
+			}
+		}
+	}
+}
+
+// copyTree copies the regular files of the directory src to dst
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			// the environment of a backend installed in the checkout isn't copied
+			if d.Name() == "venv" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0750)
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return writeFile(target, f, info.Mode())
+	})
+}
+
+func writeFile(target string, r io.Reader, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// registerExternalBackend adds the backend to the external_backends.json of the config dir, and returns its path
+func registerExternalBackend(configDir, backend, uri string) (string, error) {
+	file := filepath.Join(configDir, "external_backends.json")
+	backends := map[string]string{}
+	if content, err := os.ReadFile(file); err == nil && len(content) > 0 {
+		if err := json.Unmarshal(content, &backends); err != nil {
+			return "", fmt.Errorf("invalid %s: %w", file, err)
+		}
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	backends[backend] = uri
+
+	content, err := json.MarshalIndent(backends, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(configDir, 0750); err != nil {
+		return "", err
+	}
+	return file, os.WriteFile(file, content, 0600)
+}
+
+// smokeTestBackend starts the backend process in dir, and waits for it to answer its health check
+func smokeTestBackend(process, dir string, args []string, timeout time.Duration) error {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		return fmt.Errorf("failed allocating free ports: %w", err)
+	}
+	address := fmt.Sprintf("127.0.0.1:%d", port)
+
+	output := &bytes.Buffer{}
+	cmd := exec.Command(process, append(args, "--addr", address)...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = output, output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	client := grpc.NewClient(address, false, nil, false, nil)
+	deadline := time.After(timeout)
+	for {
+		if alive, _ := client.HealthCheck(context.Background()); alive {
+			cmd.Process.Kill()
+			<-exited
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("the backend exited (%v): %s", err, lastLines(output.String(), 20))
+		case <-deadline:
+			cmd.Process.Kill()
+			<-exited
+			return fmt.Errorf("no answer to the health check after %s: %s", timeout, lastLines(output.String(), 20))
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// lastLines returns the last n lines of the output of a process
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.Join(lines[max(len(lines)-n, 0):], "\n")
+}
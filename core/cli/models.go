@@ -24,6 +24,16 @@ type ModelsList struct {
 	ModelsCMDFlags `embed:""`
 }
 
+type ModelsSearch struct {
+	Tags         []string `help:"Only the models having all these tags"`
+	Backend      string   `help:"Only the models served by this backend (e.g. llama, whisper, piper)"`
+	License      string   `help:"Only the models with this license"`
+	Quantization string   `help:"Only the models with this quantization (e.g. q4_k_m)"`
+	Query        string   `arg:"" optional:"" name:"query" help:"Text searched in the name, description, tags and gallery of the models"`
+
+	ModelsCMDFlags `embed:""`
+}
+
 type ModelsInstall struct {
 	DisablePredownloadScan bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
 	ModelArgs              []string `arg:"" optional:"" name:"models" help:"Model configuration URLs to load"`
@@ -31,9 +41,37 @@ type ModelsInstall struct {
 	ModelsCMDFlags `embed:""`
 }
 
+type ModelsExport struct {
+	Archive     string `short:"o" help:"Path of the archive to create, its extension gives the format (defaults to <model>.tar.gz, <model>.tar with --with-weights)"`
+	WithWeights bool   `help:"Include the weight files in the archive, to import the model on a machine without network access"`
+	ModelName   string `arg:"" name:"model" completion:"models" help:"Name of the installed model to export"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsImport struct {
+	DisablePredownloadScan bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
+	Force                  bool     `help:"Overwrite an installed model with the same name"`
+	Archives               []string `arg:"" name:"archives" help:"Archives created with 'models export'"`
+
+	ModelsCMDFlags `embed:""`
+}
+
+type ModelsImportLocal struct {
+	Name  string   `help:"Name of the model (defaults to the file name without extension)"`
+	Mode  string   `enum:"symlink,hardlink,copy,move" default:"symlink" help:"How the file is brought into the models path: symlink, hardlink, copy or move"`
+	Files []string `arg:"" name:"files" help:"Model files to register" type:"existingfile"`
+
+	ModelsCMDFlags `embed:""`
+}
+
 type ModelsCMD struct {
-	List    ModelsList    `cmd:"" help:"List the models available in your galleries" default:"withargs"`
-	Install ModelsInstall `cmd:"" help:"Install a model from the gallery"`
+	List        ModelsList        `cmd:"" help:"List the models available in your galleries" default:"withargs"`
+	Search      ModelsSearch      `cmd:"" help:"Search the models of your galleries, by text, tags, backend, license and quantization"`
+	Install     ModelsInstall     `cmd:"" help:"Install a model from the gallery"`
+	Export      ModelsExport      `cmd:"" help:"Export the config, templates and grammars of an installed model to an archive (weights are referenced, or included with --with-weights)"`
+	Import      ModelsImport      `cmd:"" help:"Import a model exported with 'models export', downloading the weights which are not in the archive"`
+	ImportLocal ModelsImportLocal `cmd:"" name:"import-local" help:"Install a model from a file already on disk, without downloading or duplicating it"`
 }
 
 func (ml *ModelsList) Run(ctx *cliContext.Context) error {
@@ -46,14 +84,57 @@ func (ml *ModelsList) Run(ctx *cliContext.Context) error {
 	if err != nil {
 		return err
 	}
-	for _, model := range models {
-		if model.Installed {
-			fmt.Printf(" * %s@%s (installed)\n", model.Gallery.Name, model.Name)
-		} else {
-			fmt.Printf(" - %s@%s\n", model.Gallery.Name, model.Name)
+	return printResult(ctx, models, func() {
+		for _, model := range models {
+			if model.Installed {
+				fmt.Printf(" * %s@%s (installed)\n", model.Gallery.Name, model.Name)
+			} else {
+				fmt.Printf(" - %s@%s\n", model.Gallery.Name, model.Name)
+			}
 		}
+	})
+}
+
+func (ms *ModelsSearch) Run(ctx *cliContext.Context) error {
+	var galleries []config.Gallery
+	if err := json.Unmarshal([]byte(ms.Galleries), &galleries); err != nil {
+		log.Error().Err(err).Msg("unable to load galleries")
+	}
+
+	available, err := gallery.AvailableGalleryModels(galleries, ms.ModelsPath)
+	if err != nil {
+		return err
+	}
+	models := gallery.GalleryModels(available)
+	if ms.Query != "" {
+		models = models.Search(ms.Query)
 	}
-	return nil
+	models = models.Filter(gallery.SearchFilter{
+		Tags:         ms.Tags,
+		Backend:      ms.Backend,
+		License:      ms.License,
+		Quantization: ms.Quantization,
+	})
+	if models == nil {
+		models = gallery.GalleryModels{}
+	}
+
+	return printResult(ctx, models, func() {
+		for _, model := range models {
+			line := fmt.Sprintf("%s@%s", model.Gallery.Name, model.Name)
+			if model.License != "" {
+				line += fmt.Sprintf(" [%s]", model.License)
+			}
+			if model.Installed {
+				fmt.Printf(" * %s (installed)\n", line)
+			} else {
+				fmt.Printf(" - %s\n", line)
+			}
+		}
+		if len(models) == 0 {
+			fmt.Println("No models found")
+		}
+	})
 }
 
 func (mi *ModelsInstall) Run(ctx *cliContext.Context) error {
@@ -62,6 +143,7 @@ func (mi *ModelsInstall) Run(ctx *cliContext.Context) error {
 		log.Error().Err(err).Msg("unable to load galleries")
 	}
 
+	installed := []modelResult{}
 	for _, modelName := range mi.ModelArgs {
 
 		progressBar := progressbar.NewOptions(
@@ -69,6 +151,7 @@ func (mi *ModelsInstall) Run(ctx *cliContext.Context) error {
 			progressbar.OptionSetDescription(fmt.Sprintf("downloading model %s", modelName)),
 			progressbar.OptionShowBytes(false),
 			progressbar.OptionClearOnFinish(),
+			progressbar.OptionSetWriter(progressOutput(ctx)),
 		)
 		progressCallback := func(fileName string, current string, total string, percentage float64) {
 			v := int(percentage * 10)
@@ -104,6 +187,72 @@ func (mi *ModelsInstall) Run(ctx *cliContext.Context) error {
 		if err != nil {
 			return err
 		}
+		installed = append(installed, modelResult{Model: modelName})
+	}
+	return printResult(ctx, installed, func() {})
+}
+
+func (me *ModelsExport) Run(ctx *cliContext.Context) error {
+	output := me.Archive
+	if output == "" {
+		// the weights are already compressed
+		output = me.ModelName + ".tar.gz"
+		if me.WithWeights {
+			output = me.ModelName + ".tar"
+		}
+	}
+
+	if err := gallery.ExportModel(me.ModelsPath, me.ModelName, output, me.WithWeights); err != nil {
+		return err
+	}
+
+	log.Info().Str("model", me.ModelName).Str("archive", output).Msg("model exported")
+	return printResult(ctx, modelResult{Model: me.ModelName, Archive: output}, func() {})
+}
+
+func (mi *ModelsImport) Run(ctx *cliContext.Context) error {
+	imported := []modelResult{}
+	for _, archive := range mi.Archives {
+		progressBar := progressbar.NewOptions(
+			1000,
+			progressbar.OptionSetDescription(fmt.Sprintf("importing model %s", archive)),
+			progressbar.OptionShowBytes(false),
+			progressbar.OptionClearOnFinish(),
+			progressbar.OptionSetWriter(progressOutput(ctx)),
+		)
+		progressCallback := func(fileName string, current string, total string, percentage float64) {
+			v := int(percentage * 10)
+			err := progressBar.Set(v)
+			if err != nil {
+				log.Error().Err(err).Str("filename", fileName).Int("value", v).Msg("error while updating progress bar")
+			}
+		}
+
+		name, err := gallery.ImportModel(archive, mi.ModelsPath, progressCallback, !mi.DisablePredownloadScan, mi.Force)
+		if err != nil {
+			return err
+		}
+
+		log.Info().Str("model", name).Str("archive", archive).Msg("model imported")
+		imported = append(imported, modelResult{Model: name, Archive: archive})
+	}
+	return printResult(ctx, imported, func() {})
+}
+
+func (mi *ModelsImportLocal) Run(ctx *cliContext.Context) error {
+	if mi.Name != "" && len(mi.Files) > 1 {
+		return fmt.Errorf("--name can only be used when importing a single file")
+	}
+
+	imported := []modelResult{}
+	for _, file := range mi.Files {
+		name, err := gallery.ImportLocalModel(mi.ModelsPath, file, mi.Name, gallery.LinkMode(mi.Mode))
+		if err != nil {
+			return err
+		}
+
+		log.Info().Str("model", name).Str("file", file).Str("mode", mi.Mode).Msg("model installed")
+		imported = append(imported, modelResult{Model: name, File: file, Mode: mi.Mode})
 	}
-	return nil
+	return printResult(ctx, imported, func() {})
 }
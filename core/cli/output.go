@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	cliContext "github.com/mudler/LocalAI/core/cli/context"
+)
+
+// modelResult is the JSON result of the commands installing or exporting models
+type modelResult struct {
+	Model   string `json:"model"`
+	Archive string `json:"archive,omitempty"`
+	File    string `json:"file,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+}
+
+// fileResult is the JSON result of the commands generating a file
+type fileResult struct {
+	File string `json:"file"`
+}
+
+// printResult prints the result of a command on stdout: as JSON with --output json, with text() otherwise
+func printResult(ctx *cliContext.Context, result interface{}, text func()) error {
+	if !ctx.JSONOutput() {
+		text()
+		return nil
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// progressOutput returns where the progress bars are drawn, stdout is kept for the JSON results
+func progressOutput(ctx *cliContext.Context) io.Writer {
+	if ctx.JSONOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
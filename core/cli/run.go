@@ -2,16 +2,28 @@ package cli
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
 	cli_api "github.com/mudler/LocalAI/core/cli/api"
 	cliContext "github.com/mudler/LocalAI/core/cli/context"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/grpcapi"
 	"github.com/mudler/LocalAI/core/http"
 	"github.com/mudler/LocalAI/core/p2p"
 	"github.com/mudler/LocalAI/core/startup"
+	"github.com/mudler/LocalAI/pkg/events"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/scanner"
+	"github.com/mudler/LocalAI/pkg/storage"
+	"github.com/mudler/LocalAI/pkg/xnet"
+	"github.com/mudler/edgevpn/pkg/node"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -25,6 +37,9 @@ type RunCMD struct {
 	AudioPath                    string        `env:"LOCALAI_AUDIO_PATH,AUDIO_PATH" type:"path" default:"/tmp/generated/audio" help:"Location for audio generated by backends (e.g. piper)" group:"storage"`
 	UploadPath                   string        `env:"LOCALAI_UPLOAD_PATH,UPLOAD_PATH" type:"path" default:"/tmp/localai/upload" help:"Path to store uploads from files api" group:"storage"`
 	ConfigPath                   string        `env:"LOCALAI_CONFIG_PATH,CONFIG_PATH" default:"/tmp/localai/config" group:"storage"`
+	StorageURL                   string        `env:"LOCALAI_STORAGE_URL,STORAGE_URL" help:"Store generated images, audio and uploads in an S3-compatible object storage instead of the local paths (e.g. s3://bucket/prefix?endpoint=http://minio:9000). Credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY" group:"storage"`
+	DiagnosticsPath              string        `env:"LOCALAI_DIAGNOSTICS_PATH,DIAGNOSTICS_PATH" type:"path" default:"${basepath}/diagnostics" help:"Path where a diagnostics bundle is collected when a backend crashes, set to an empty string to disable" group:"storage"`
+	BackendStatePath             string        `env:"LOCALAI_BACKEND_STATE_PATH" type:"path" default:"${basepath}/backend-state" help:"Path where the sessions of the models with warm_restart are saved when their backend is restarted" group:"storage"`
 	LocalaiConfigDir             string        `env:"LOCALAI_CONFIG_DIR" type:"path" default:"${basepath}/configuration" help:"Directory for dynamic loading of certain configuration files (currently api_keys.json and external_backends.json)" group:"storage"`
 	LocalaiConfigDirPollInterval time.Duration `env:"LOCALAI_CONFIG_DIR_POLL_INTERVAL" help:"Typically the config path picks up changes automatically, but if your system has broken fsnotify events, set this to an interval to poll the LocalAI Config Dir (example: 1m)" group:"storage"`
 	// The alias on this option is there to preserve functionality with the old `--config-file` parameter
@@ -36,36 +51,95 @@ type RunCMD struct {
 	PreloadModels       string   `env:"LOCALAI_PRELOAD_MODELS,PRELOAD_MODELS" help:"A List of models to apply in JSON at start" group:"models"`
 	Models              []string `env:"LOCALAI_MODELS,MODELS" help:"A List of model configuration URLs to load" group:"models"`
 	PreloadModelsConfig string   `env:"LOCALAI_PRELOAD_MODELS_CONFIG,PRELOAD_MODELS_CONFIG" help:"A List of models to apply at startup. Path to a YAML config file" group:"models"`
+	PreloadParallelism  int      `env:"LOCALAI_PRELOAD_PARALLELISM,PRELOAD_PARALLELISM" default:"4" help:"Number of preload models downloaded and applied concurrently at startup" group:"models"`
+
+	TorrentSeed      bool          `env:"LOCALAI_TORRENT_SEED" help:"Keep seeding the model files downloaded over BitTorrent (magnet links and .torrent files of the gallery entries) once installed" group:"models"`
+	TorrentSeedRatio float64       `env:"LOCALAI_TORRENT_SEED_RATIO" default:"1.0" help:"Stop seeding a file once uploaded this many times its size, 0 for no limit" group:"models"`
+	TorrentSeedTime  time.Duration `env:"LOCALAI_TORRENT_SEED_TIME" help:"Stop seeding a file after this time (example: 24h), 0 for no limit" group:"models"`
+	DisableBlobStore bool          `env:"LOCALAI_DISABLE_BLOB_STORE" help:"Download the model files as is, instead of hard links to the blobs of their content shared by the models (in the .blobs directory of the models path)" group:"models"`
 
 	F16         bool `name:"f16" env:"LOCALAI_F16,F16" help:"Enable GPU acceleration" group:"performance"`
 	Threads     int  `env:"LOCALAI_THREADS,THREADS" short:"t" help:"Number of threads used for parallel computation. Usage of the number of physical cores in the system is suggested" group:"performance"`
 	ContextSize int  `env:"LOCALAI_CONTEXT_SIZE,CONTEXT_SIZE" default:"512" help:"Default context size for models" group:"performance"`
 
 	Address                string   `env:"LOCALAI_ADDRESS,ADDRESS" default:":8080" help:"Bind address for the API server" group:"api"`
+	GRPCAddress            string   `env:"LOCALAI_GRPC_ADDRESS,GRPC_ADDRESS" name:"grpc-address" help:"Bind address for the gRPC server exposing the OpenAI API (e.g. :9090). Disabled when empty" group:"api"`
+	TLSCert                string   `env:"LOCALAI_TLS_CERT" name:"tls-cert" type:"path" help:"Path of the certificate of the API server, serving HTTPS instead of HTTP" group:"api"`
+	TLSKey                 string   `env:"LOCALAI_TLS_KEY" name:"tls-key" type:"path" help:"Path of the key of the certificate of the API server" group:"api"`
+	TLSClientCA            string   `env:"LOCALAI_TLS_CLIENT_CA" name:"tls-client-ca" type:"path" help:"Path of the CA certificate verifying the client certificates, which all the clients must present when it's set (mTLS)" group:"api"`
 	CORS                   bool     `env:"LOCALAI_CORS,CORS" help:"" group:"api"`
 	CORSAllowOrigins       string   `env:"LOCALAI_CORS_ALLOW_ORIGINS,CORS_ALLOW_ORIGINS" group:"api"`
 	LibraryPath            string   `env:"LOCALAI_LIBRARY_PATH,LIBRARY_PATH" help:"Path to the library directory (for e.g. external libraries used by backends)" default:"/usr/share/local-ai/libs" group:"backends"`
 	CSRF                   bool     `env:"LOCALAI_CSRF" help:"Enables fiber CSRF middleware" group:"api"`
 	UploadLimit            int      `env:"LOCALAI_UPLOAD_LIMIT,UPLOAD_LIMIT" default:"15" help:"Default upload-limit in MB" group:"api"`
+	ReadTimeout            string   `env:"LOCALAI_READ_TIMEOUT" default:"1m" help:"Time to receive a request, 0 for no limit. The uploads have --upload-timeout" group:"api"`
+	WriteTimeout           string   `env:"LOCALAI_WRITE_TIMEOUT" default:"1m" help:"Time to send a response, 0 for no limit. The streamed answers, the events and the files have --stream-timeout" group:"api"`
+	UploadTimeout          string   `env:"LOCALAI_UPLOAD_TIMEOUT" default:"30m" help:"Time to receive an upload (a multipart form), which may be large. 0 uses --read-timeout" group:"api"`
+	StreamTimeout          string   `env:"LOCALAI_STREAM_TIMEOUT" default:"1h" help:"Time to send a streamed answer, the events or a file. 0 uses --write-timeout" group:"api"`
+	ImageMaxDimension      int      `env:"LOCALAI_IMAGE_MAX_DIMENSION,IMAGE_MAX_DIMENSION" default:"2048" help:"Input images larger than this (in pixels, on any side) are downscaled before being passed to the backends. 0 disables it" group:"api"`
+	ImageMaxPixels         int      `env:"LOCALAI_IMAGE_MAX_PIXELS,IMAGE_MAX_PIXELS" default:"50000000" help:"Input images with more pixels than this are rejected. 0 disables it" group:"api"`
+	MemoryModel            string   `env:"LOCALAI_MEMORY_MODEL,MEMORY_MODEL" help:"Model used to extract the facts to remember about the users. Setting it enables the long-term memory for the chat requests carrying a user" group:"api"`
 	APIKeys                []string `env:"LOCALAI_API_KEY,API_KEY" help:"List of API Keys to enable API authentication. When this is set, all the requests must be authenticated with one of these API keys" group:"api"`
+	AdminAPIKeys           []string `env:"LOCALAI_ADMIN_API_KEY,ADMIN_API_KEY" help:"List of API Keys allowed to use the admin-scoped request fields (e.g. backend). They are valid API keys as well" group:"api"`
+	TokenBudget            int      `env:"LOCALAI_TOKEN_BUDGET,TOKEN_BUDGET" help:"Number of tokens a conversation (the requests with the same conversation_id or user) may use, the requests may set a lower token_budget. 0 disables it" group:"api"`
+	APIKeyTokenBudgets     []string `env:"LOCALAI_API_KEY_TOKEN_BUDGETS" help:"Token budget of the conversations of an API key, as <key>:<budget>, overriding --token-budget" group:"api"`
 	DisableWebUI           bool     `env:"LOCALAI_DISABLE_WEBUI,DISABLE_WEBUI" default:"false" help:"Disable webui" group:"api"`
 	DisablePredownloadScan bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
+	UploadScanner          string   `env:"LOCALAI_UPLOAD_SCANNER" help:"Scan the files received by the files, vision and audio endpoints before they are stored or processed: a command receiving the path of the file (e.g. 'clamdscan --no-summary'), an http(s):// or an icap:// URL" group:"hardening"`
+	UploadScanAction       string   `env:"LOCALAI_UPLOAD_SCAN_ACTION" enum:"block,quarantine" default:"block" help:"What to do with the uploads flagged by the scanner: block rejects them, quarantine rejects them and keeps them in --upload-quarantine-path [${enum}]" group:"hardening"`
+	UploadQuarantinePath   string   `env:"LOCALAI_UPLOAD_QUARANTINE_PATH" type:"path" default:"${basepath}/quarantine" help:"Path where the uploads flagged by the scanner are kept, with --upload-scan-action=quarantine" group:"hardening"`
 	OpaqueErrors           bool     `env:"LOCALAI_OPAQUE_ERRORS" default:"false" help:"If true, all error responses are replaced with blank 500 errors. This is intended only for hardening against information leaks and is normally not recommended." group:"hardening"`
+	PrivacyMode            bool     `env:"LOCALAI_PRIVACY_MODE" help:"Block all the outbound network connections (galleries, remote library, model downloads, proxied models, P2P), except to the loopback and --privacy-allowed-hosts. The blocked attempts are reported in /system" group:"hardening"`
+	PrivacyAllowedHosts    []string `env:"LOCALAI_PRIVACY_ALLOWED_HOSTS" help:"Hosts LocalAI may connect to in privacy mode: host names, *.domain wildcards, IP addresses and CIDRs" group:"hardening"`
+	ModelMetrics           bool     `env:"LOCALAI_MODEL_METRICS" help:"Expose in /metrics the per-model requests, generated tokens, time to first token, queue depth and backend load events" group:"api"`
+	OtelEndpoint           string   `env:"LOCALAI_OTEL_ENDPOINT" help:"OTLP/HTTP endpoint of the OpenTelemetry collector receiving the traces of the requests (e.g. http://otel-collector:4318). Disabled when empty" group:"api"`
+
+	OIDCIssuer            string            `env:"LOCALAI_OIDC_ISSUER" name:"oidc-issuer" help:"Accept the JWTs of this OIDC issuer as bearer tokens besides the API keys, e.g. https://sso.example.com/realms/corp" group:"api"`
+	OIDCAudience          string            `env:"LOCALAI_OIDC_AUDIENCE" name:"oidc-audience" help:"Audience the JWTs must be intended for, required with --oidc-issuer" group:"api"`
+	OIDCJWKSURL           string            `env:"LOCALAI_OIDC_JWKS_URL" name:"oidc-jwks-url" help:"URL of the keys of the issuer, discovered from the issuer when empty" group:"api"`
+	OIDCScopesClaim       string            `env:"LOCALAI_OIDC_SCOPES_CLAIM" name:"oidc-scopes-claim" default:"scope" help:"Claim of the JWTs holding their scopes, e.g. scope, roles or groups" group:"api"`
+	OIDCScopeMap          map[string]string `env:"LOCALAI_OIDC_SCOPE_MAP" name:"oidc-scope-map" help:"Scopes of LocalAI granted by the values of the scopes claim, e.g. ml-admins=admin;ml-users=chat,embeddings" group:"api"`
+	OIDCPassthroughScopes bool              `env:"LOCALAI_OIDC_PASSTHROUGH_SCOPES" name:"oidc-passthrough-scopes" help:"Also grant the values of the scopes claim which are scopes of LocalAI as is, e.g. chat or admin" group:"api"`
+
+	DisableUsageAccounting bool     `env:"LOCALAI_DISABLE_USAGE_ACCOUNTING" help:"Stop counting the requests and the tokens used per API key and per model, reported by /v1/usage" group:"api"`
+	LatencySLO             string   `env:"LOCALAI_LATENCY_SLO" name:"latency-slo" help:"Time the chat and completion requests should complete within (example: 30s), estimated from their prompt, max_tokens and the requests in progress. The requests which can't meet it are queued, then answered with 503. Disabled when empty, the models may set their own latency_slo" group:"api"`
+	AdmissionQueueTimeout  string   `env:"LOCALAI_ADMISSION_QUEUE_TIMEOUT" help:"How long the requests which can't meet the latency SLO wait for the requests in progress before being refused (example: 10s). Refused right away when empty" group:"api"`
+	ConcurrentInferences   int      `env:"LOCALAI_MAX_CONCURRENT_INFERENCES" name:"max-concurrent-inferences" help:"Maximum number of inference requests (chat, completions, embeddings, images, audio, rerank) running at once, the others waiting in a queue. Unlimited when 0" group:"api"`
+	QueuedInferences       int      `env:"LOCALAI_MAX_QUEUED_INFERENCES" name:"max-queued-inferences" default:"100" help:"Maximum number of inference requests waiting for --max-concurrent-inferences, the others being answered with 429 and the estimated wait" group:"api"`
+	BatchConcurrency       int      `env:"LOCALAI_BATCH_CONCURRENCY" name:"batch-concurrency" default:"4" help:"Maximum number of requests of the batches of /v1/batches running at once, across all the batches" group:"api"`
+	UserRateLimit          int      `env:"LOCALAI_USER_RATE_LIMIT" help:"Maximum number of requests per minute of each end user, identified by the 'user' field of the requests and their API key, answered with 429 beyond it. Unlimited when 0" group:"api"`
+	AuditLog               string   `env:"LOCALAI_AUDIT_LOG" help:"Write one JSON line per API request (time, API key ID, endpoint, model, token counts, latency, status) to this file, to 'syslog' or to syslog://host:port (syslog+tcp:// for TCP). Disabled when empty" group:"api"`
+	AuditLogMaxSize        int      `env:"LOCALAI_AUDIT_LOG_MAX_SIZE" default:"100" help:"Size in MB at which the audit log file is rotated, 0 disables the rotation" group:"api"`
+	AuditLogMaxBackups     int      `env:"LOCALAI_AUDIT_LOG_MAX_BACKUPS" default:"5" help:"Number of rotated audit log files kept" group:"api"`
+	AuditLogRedact         bool     `env:"LOCALAI_AUDIT_LOG_REDACT" help:"Replace the prompts in the audit log by their length" group:"api"`
+	EnableFaultInjection   bool     `env:"LOCALAI_ENABLE_FAULT_INJECTION" hidden:"" help:"Enable the /faults admin endpoints, injecting delays, errors and truncated streams in the requests to test the clients. Never enable it in production" group:"api"`
 	Peer2Peer              bool     `env:"LOCALAI_P2P,P2P" name:"p2p" default:"false" help:"Enable P2P mode" group:"p2p"`
 	Peer2PeerDHTInterval   int      `env:"LOCALAI_P2P_DHT_INTERVAL,P2P_DHT_INTERVAL" default:"360" name:"p2p-dht-interval" help:"Interval for DHT refresh (used during token generation)" group:"p2p"`
 	Peer2PeerOTPInterval   int      `env:"LOCALAI_P2P_OTP_INTERVAL,P2P_OTP_INTERVAL" default:"9000" name:"p2p-otp-interval" help:"Interval for OTP refresh (used during token generation)" group:"p2p"`
 	Peer2PeerToken         string   `env:"LOCALAI_P2P_TOKEN,P2P_TOKEN,TOKEN" name:"p2ptoken" help:"Token for P2P mode (optional)" group:"p2p"`
 	Peer2PeerNetworkID     string   `env:"LOCALAI_P2P_NETWORK_ID,P2P_NETWORK_ID" help:"Network ID for P2P mode, can be set arbitrarly by the user for grouping a set of instances" group:"p2p"`
+	Peer2PeerConfigKey     string   `env:"LOCALAI_P2P_CONFIG_SYNC_KEY,P2P_CONFIG_SYNC_KEY" name:"p2p-config-sync-key" help:"Private key (see 'local-ai util p2p-config-sync-keys') making this node the leader: its model configs and API keys are published to the nodes of the p2p network trusting the key" group:"p2p"`
+	Peer2PeerTrustedKey    string   `env:"LOCALAI_P2P_CONFIG_SYNC_TRUSTED_KEY,P2P_CONFIG_SYNC_TRUSTED_KEY" name:"p2p-config-sync-trusted-key" help:"Public key of the leader: the model configs and API keys it publishes over p2p are applied to this node" group:"p2p"`
 	ParallelRequests       bool     `env:"LOCALAI_PARALLEL_REQUESTS,PARALLEL_REQUESTS" help:"Enable backends to handle multiple requests in parallel if they support it (e.g.: llama.cpp or vllm)" group:"backends"`
 	SingleActiveBackend    bool     `env:"LOCALAI_SINGLE_ACTIVE_BACKEND,SINGLE_ACTIVE_BACKEND" help:"Allow only one backend to be run at a time" group:"backends"`
+	AdaptiveThreads        bool     `env:"LOCALAI_ADAPTIVE_THREADS,ADAPTIVE_THREADS" help:"Split the threads of the models between the requests running in parallel (fewer threads each, and fewer still when the CPU is saturated) instead of using all of them in every request" group:"performance"`
+	NUMAAuto               bool     `env:"LOCALAI_NUMA_AUTO" name:"numa-auto" help:"On machines with several NUMA nodes (e.g. dual-socket servers), pin the backend of each model to the CPUs and the memory of a node, the node running the fewest backends, unless the model sets its own cpus or numa_node" group:"performance"`
+	PrefetchModels         bool     `env:"LOCALAI_PREFETCH_MODELS,PREFETCH_MODELS" help:"Learn the order the models are used in (e.g. embeddings after chat), and load in the background the model likely used next while the current request runs, when enough memory is available" group:"performance"`
 	PreloadBackendOnly     bool     `env:"LOCALAI_PRELOAD_BACKEND_ONLY,PRELOAD_BACKEND_ONLY" default:"false" help:"Do not launch the API services, only the preloaded models / backends are started (useful for multi-node setups)" group:"backends"`
 	ExternalGRPCBackends   []string `env:"LOCALAI_EXTERNAL_GRPC_BACKENDS,EXTERNAL_GRPC_BACKENDS" help:"A list of external grpc backends" group:"backends"`
+	ExternalBackendToken   string   `env:"LOCALAI_EXTERNAL_BACKEND_TOKEN,EXTERNAL_BACKEND_TOKEN" help:"Token sent as bearer token to the external grpc backends given by address" group:"backends"`
+	ExternalBackendCA      string   `env:"LOCALAI_EXTERNAL_BACKEND_CA,EXTERNAL_BACKEND_CA" name:"external-backend-ca" help:"Path of the CA certificate verifying the external grpc backends given by address. Enables TLS" group:"backends"`
+	ExternalBackendCert    string   `env:"LOCALAI_EXTERNAL_BACKEND_CERT,EXTERNAL_BACKEND_CERT" help:"Path of the client certificate presented to the external grpc backends given by address (mTLS). Enables TLS" group:"backends"`
+	ExternalBackendKey     string   `env:"LOCALAI_EXTERNAL_BACKEND_KEY,EXTERNAL_BACKEND_KEY" help:"Path of the key of the client certificate presented to the external grpc backends" group:"backends"`
 	EnableWatchdogIdle     bool     `env:"LOCALAI_WATCHDOG_IDLE,WATCHDOG_IDLE" default:"false" help:"Enable watchdog for stopping backends that are idle longer than the watchdog-idle-timeout" group:"backends"`
 	WatchdogIdleTimeout    string   `env:"LOCALAI_WATCHDOG_IDLE_TIMEOUT,WATCHDOG_IDLE_TIMEOUT" default:"15m" help:"Threshold beyond which an idle backend should be stopped" group:"backends"`
 	EnableWatchdogBusy     bool     `env:"LOCALAI_WATCHDOG_BUSY,WATCHDOG_BUSY" default:"false" help:"Enable watchdog for stopping backends that are busy longer than the watchdog-busy-timeout" group:"backends"`
 	WatchdogBusyTimeout    string   `env:"LOCALAI_WATCHDOG_BUSY_TIMEOUT,WATCHDOG_BUSY_TIMEOUT" default:"5m" help:"Threshold beyond which a busy backend should be stopped" group:"backends"`
+	AutoShutdownAfter      string   `env:"LOCALAI_AUTO_SHUTDOWN_AFTER,AUTO_SHUTDOWN_AFTER" help:"Stop LocalAI, after the running requests complete, when no request arrived for this long (example: 30m). Health checks and metrics don't count as requests" group:"backends"`
+	AutoShutdownHook       string   `env:"LOCALAI_AUTO_SHUTDOWN_HOOK,AUTO_SHUTDOWN_HOOK" help:"Shell command to run once LocalAI stopped because of --auto-shutdown-after (example: 'sudo poweroff')" group:"backends"`
 	Federated              bool     `env:"LOCALAI_FEDERATED,FEDERATED" help:"Enable federated instance" group:"federated"`
 	DisableGalleryEndpoint bool     `env:"LOCALAI_DISABLE_GALLERY_ENDPOINT,DISABLE_GALLERY_ENDPOINT" help:"Disable the gallery endpoints" group:"api"`
+	DryRun                 bool     `help:"Print the effective configuration as YAML after checking the model configurations and the external backends, without starting the server nor loading any backend"`
 }
 
 func (r *RunCMD) Run(ctx *cliContext.Context) error {
@@ -73,12 +147,15 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		config.WithConfigFile(r.ModelsConfigFile),
 		config.WithJSONStringPreload(r.PreloadModels),
 		config.WithYAMLConfigPreload(r.PreloadModelsConfig),
+		config.WithPreloadParallelism(r.PreloadParallelism),
 		config.WithModelPath(r.ModelsPath),
 		config.WithContextSize(r.ContextSize),
 		config.WithDebug(zerolog.GlobalLevel() <= zerolog.DebugLevel),
 		config.WithImageDir(r.ImagePath),
 		config.WithAudioDir(r.AudioPath),
 		config.WithUploadDir(r.UploadPath),
+		config.WithDiagnosticsDir(r.DiagnosticsPath),
+		config.WithBackendStateDir(r.BackendStatePath),
 		config.WithConfigsDir(r.ConfigPath),
 		config.WithDynamicConfigDir(r.LocalaiConfigDir),
 		config.WithDynamicConfigDirPollInterval(r.LocalaiConfigDirPollInterval),
@@ -93,15 +170,40 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		config.WithBackendAssets(ctx.BackendAssets),
 		config.WithBackendAssetsOutput(r.BackendAssetsPath),
 		config.WithUploadLimitMB(r.UploadLimit),
+		config.WithImageMaxDimension(r.ImageMaxDimension),
+		config.WithImageMaxPixels(r.ImageMaxPixels),
+		config.WithMemoryModel(r.MemoryModel),
 		config.WithApiKeys(r.APIKeys),
+		config.WithAdminApiKeys(r.AdminAPIKeys),
+		config.WithTokenBudget(r.TokenBudget),
 		config.WithModelsURL(append(r.Models, r.ModelArgs...)...),
 		config.WithOpaqueErrors(r.OpaqueErrors),
+		config.WithModelMetrics(r.ModelMetrics),
+		config.WithOTelEndpoint(r.OtelEndpoint),
+		config.WithOIDC(r.OIDCIssuer, r.OIDCAudience, r.OIDCJWKSURL, r.OIDCScopesClaim, r.OIDCScopeMap),
+		config.WithOIDCPassthroughScopes(r.OIDCPassthroughScopes),
+		config.WithUserRateLimit(r.UserRateLimit),
+		config.WithAuditLog(r.AuditLog),
+		config.WithAuditLogRotation(r.AuditLogMaxSize, r.AuditLogMaxBackups),
+		config.WithAuditLogRedaction(r.AuditLogRedact),
+		config.WithFaultInjection(r.EnableFaultInjection),
 		config.WithEnforcedPredownloadScans(!r.DisablePredownloadScan),
 		config.WithP2PNetworkID(r.Peer2PeerNetworkID),
 	}
 
+	if r.PrivacyMode {
+		if r.Peer2Peer || r.Peer2PeerToken != "" || r.Federated {
+			return fmt.Errorf("p2p and the federated mode are not available in privacy mode")
+		}
+		guard, err := xnet.NewGuard(r.PrivacyAllowedHosts)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithNetworkGuard(guard))
+	}
+
 	token := ""
-	if r.Peer2Peer || r.Peer2PeerToken != "" {
+	if (r.Peer2Peer || r.Peer2PeerToken != "") && !r.DryRun {
 		log.Info().Msg("P2P mode enabled")
 		token = r.Peer2PeerToken
 		if token == "" {
@@ -120,8 +222,16 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 
 	backgroundCtx := context.Background()
 
-	if err := cli_api.StartP2PStack(backgroundCtx, r.Address, token, r.Peer2PeerNetworkID, r.Federated); err != nil {
-		return err
+	var p2pNode *node.Node
+	if !r.DryRun {
+		n, err := cli_api.StartP2PStack(backgroundCtx, r.Address, token, r.Peer2PeerNetworkID, r.Federated)
+		if err != nil {
+			return err
+		}
+		p2pNode = n
+	}
+	if (r.Peer2PeerConfigKey != "" || r.Peer2PeerTrustedKey != "") && p2pNode == nil && !r.DryRun {
+		return fmt.Errorf("the config sync requires p2p or the federated mode")
 	}
 
 	idleWatchDog := r.EnableWatchdogIdle
@@ -131,6 +241,10 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		opts = append(opts, config.DisableWebUI)
 	}
 
+	if r.DisableUsageAccounting {
+		opts = append(opts, config.DisableUsageAccounting)
+	}
+
 	if r.DisableGalleryEndpoint {
 		opts = append(opts, config.DisableGalleryEndpoint)
 	}
@@ -154,12 +268,97 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 			opts = append(opts, config.SetWatchDogBusyTimeout(dur))
 		}
 	}
+	if r.AutoShutdownAfter != "" {
+		dur, err := time.ParseDuration(r.AutoShutdownAfter)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithAutoShutdownAfter(dur))
+	}
+	timeouts := []time.Duration{}
+	for _, timeout := range []string{r.ReadTimeout, r.WriteTimeout, r.UploadTimeout, r.StreamTimeout} {
+		dur, err := time.ParseDuration(timeout)
+		if err != nil {
+			return err
+		}
+		timeouts = append(timeouts, dur)
+	}
+	opts = append(opts, config.WithRequestTimeouts(timeouts[0], timeouts[1], timeouts[2], timeouts[3]))
+	// the models may have a latency_slo without --latency-slo, the queue timeout applies to them too
+	if r.LatencySLO != "" || r.AdmissionQueueTimeout != "" {
+		var slo, queueTimeout time.Duration
+		var err error
+		if r.LatencySLO != "" {
+			if slo, err = time.ParseDuration(r.LatencySLO); err != nil {
+				return err
+			}
+		}
+		if r.AdmissionQueueTimeout != "" {
+			if queueTimeout, err = time.ParseDuration(r.AdmissionQueueTimeout); err != nil {
+				return err
+			}
+		}
+		opts = append(opts, config.WithLatencySLO(slo, queueTimeout))
+	}
+	opts = append(opts, config.WithBatchConcurrency(r.BatchConcurrency))
+	if r.ConcurrentInferences > 0 {
+		opts = append(opts, config.WithInferenceQueue(r.ConcurrentInferences, r.QueuedInferences))
+	}
+	if r.StorageURL != "" {
+		s, err := storage.ParseS3URL(r.StorageURL)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.WithObjectStorage(s))
+	}
+	if r.UploadScanner != "" {
+		s, err := scanner.New(r.UploadScanner)
+		if err != nil {
+			return err
+		}
+		quarantineDir := ""
+		if r.UploadScanAction == "quarantine" {
+			quarantineDir = r.UploadQuarantinePath
+		}
+		opts = append(opts, config.WithUploadScanner(s, quarantineDir))
+	}
 	if r.ParallelRequests {
 		opts = append(opts, config.EnableParallelBackendRequests)
 	}
 	if r.SingleActiveBackend {
 		opts = append(opts, config.EnableSingleBackend)
 	}
+	if r.AdaptiveThreads {
+		opts = append(opts, config.EnableAdaptiveThreads)
+	}
+	if r.NUMAAuto {
+		opts = append(opts, config.EnableNUMAAuto)
+	}
+	if r.TorrentSeed {
+		opts = append(opts, config.WithTorrentSeeding(r.TorrentSeedRatio, r.TorrentSeedTime))
+	}
+	if r.DisableBlobStore {
+		opts = append(opts, config.DisableBlobStore)
+	}
+	if r.PrefetchModels {
+		opts = append(opts, config.EnableModelPrefetch)
+	}
+
+	if len(r.APIKeyTokenBudgets) > 0 {
+		budgets := map[string]int{}
+		for _, v := range r.APIKeyTokenBudgets {
+			i := strings.LastIndexByte(v, ':')
+			if i <= 0 {
+				return fmt.Errorf("invalid API key token budget %q, expected <key>:<budget>", v)
+			}
+			budget, err := strconv.Atoi(v[i+1:])
+			if err != nil {
+				return fmt.Errorf("invalid API key token budget %q: %w", v, err)
+			}
+			budgets[v[:i]] = budget
+		}
+		opts = append(opts, config.WithApiKeyTokenBudgets(budgets))
+	}
 
 	// split ":" to get backend name and the uri
 	for _, v := range r.ExternalGRPCBackends {
@@ -168,25 +367,134 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		opts = append(opts, config.WithExternalBackend(backend, uri))
 	}
 
+	if r.ExternalBackendToken != "" || r.ExternalBackendCA != "" || r.ExternalBackendCert != "" {
+		if (r.ExternalBackendCert == "") != (r.ExternalBackendKey == "") {
+			return fmt.Errorf("both the external backend certificate and key are required for mTLS")
+		}
+		opts = append(opts, config.WithExternalBackendsCredentials(&grpc.Credentials{
+			Token:      r.ExternalBackendToken,
+			CACert:     r.ExternalBackendCA,
+			ClientCert: r.ExternalBackendCert,
+			ClientKey:  r.ExternalBackendKey,
+		}))
+	}
+
 	if r.AutoloadGalleries {
 		opts = append(opts, config.EnableGalleriesAutoload)
 	}
 
+	tlsConfig, err := http.TLSConfig(r.TLSCert, r.TLSKey, r.TLSClientCA)
+	if err != nil {
+		return err
+	}
+
+	if r.DryRun {
+		return r.dryRun(ctx, opts)
+	}
+
 	if r.PreloadBackendOnly {
 		_, _, _, err := startup.Startup(opts...)
 		return err
 	}
 
+	// the health checks are answered while the models are downloaded, to tell the orchestrators that LocalAI is
+	// alive but not ready yet
+	stopProbes, err := http.StartupProbes(r.Address, tlsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", r.Address, err)
+	}
+
 	cl, ml, options, err := startup.Startup(opts...)
 	if err != nil {
+		stopProbes()
 		return fmt.Errorf("failed basic startup tasks with error %s", err.Error())
 	}
 
+	if r.Peer2PeerConfigKey != "" {
+		key, err := p2p.ParseConfigSyncPrivateKey(r.Peer2PeerConfigKey)
+		if err != nil {
+			return err
+		}
+		if err := p2p.PublishConfig(options.Context, p2pNode, r.Peer2PeerNetworkID, key, func() (p2p.ConfigBundle, error) {
+			// the entries of api_keys.json are published with their metadata, followed by the keys of --api-keys
+			keys := options.ApiKeyStore.Keys()
+			for _, k := range options.ApiKeys {
+				keys = append(keys, config.APIKey{Key: k})
+			}
+			apiKeys, err := json.Marshal(keys)
+			if err != nil {
+				return p2p.ConfigBundle{}, err
+			}
+			return p2p.CollectConfigBundle(options.ModelPath, apiKeys)
+		}); err != nil {
+			return err
+		}
+		log.Info().Msg("Publishing the model configs and the API keys to the p2p network")
+	}
+	if r.Peer2PeerTrustedKey != "" {
+		key, err := p2p.ParseConfigSyncPublicKey(r.Peer2PeerTrustedKey)
+		if err != nil {
+			return err
+		}
+		if err := p2p.FollowConfig(options.Context, p2pNode, r.Peer2PeerNetworkID, key, func(b p2p.ConfigBundle) error {
+			if err := p2p.ApplyConfigBundle(b, options.ModelPath, options.DynamicConfigsDir); err != nil {
+				return err
+			}
+			if err := cl.LoadBackendConfigsFromPath(options.ModelPath); err != nil {
+				return err
+			}
+			if err := cl.Preload(options.ModelPath); err != nil {
+				return err
+			}
+			options.Events.Publish(events.ConfigReloaded, map[string]any{"source": "p2p"})
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
 	appHTTP, err := http.App(cl, ml, options)
 	if err != nil {
 		log.Error().Err(err).Msg("error during HTTP App construction")
 		return err
 	}
 
-	return appHTTP.Listen(r.Address)
+	if err := stopProbes(); err != nil {
+		log.Error().Err(err).Msg("error stopping the startup probes")
+	}
+
+	if r.GRPCAddress != "" {
+		go func() {
+			if err := grpcapi.Serve(options.Context, r.GRPCAddress, appHTTP, r.UploadLimit*1024*1024, tlsConfig); err != nil {
+				log.Error().Err(err).Msg("gRPC API server stopped")
+			}
+		}()
+	}
+
+	listen := func() error {
+		if tlsConfig == nil {
+			return appHTTP.Listen(r.Address)
+		}
+		ln, err := tls.Listen(appHTTP.Config().Network, r.Address, tlsConfig)
+		if err != nil {
+			return err
+		}
+		return appHTTP.Listener(ln)
+	}
+	if err := listen(); err != nil || r.AutoShutdownAfter == "" {
+		return err
+	}
+
+	// The server was stopped because it was idle
+	if err := ml.StopAllGRPC(); err != nil {
+		log.Error().Err(err).Msg("error while stopping the backends")
+	}
+	if r.AutoShutdownHook != "" {
+		log.Info().Msgf("Running the shutdown hook: %s", r.AutoShutdownHook)
+		cmd := exec.Command("sh", "-c", r.AutoShutdownHook)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+	return nil
 }
@@ -19,7 +19,7 @@ type SoundGenerationCMD struct {
 	Text []string `arg:""`
 
 	Backend                string   `short:"b" required:"" help:"Backend to run the SoundGeneration model"`
-	Model                  string   `short:"m" required:"" help:"Model name to run the SoundGeneration"`
+	Model                  string   `short:"m" required:"" completion:"models" help:"Model name to run the SoundGeneration"`
 	Duration               string   `short:"d" help:"If specified, the length of audio to generate in seconds"`
 	Temperature            string   `short:"t" help:"If specified, the temperature of the generation"`
 	InputFile              string   `short:"i" help:"If specified, the input file to condition generation upon"`
@@ -64,7 +64,7 @@ func (t *SoundGenerationCMD) Run(ctx *cliContext.Context) error {
 		backend := v[:strings.IndexByte(v, ':')]
 		uri := v[strings.IndexByte(v, ':')+1:]
 		externalBackends[backend] = uri
-		fmt.Printf("TMP externalBackends[%q]=%q\n\n", backend, uri)
+		log.Debug().Str("backend", backend).Str("uri", uri).Msg("external backend")
 	}
 
 	opts := &config.ApplicationConfig{
@@ -102,9 +102,9 @@ func (t *SoundGenerationCMD) Run(ctx *cliContext.Context) error {
 		if err := os.Rename(filePath, outputFile); err != nil {
 			return err
 		}
-		fmt.Printf("Generate file %s\n", outputFile)
-	} else {
-		fmt.Printf("Generate file %s\n", filePath)
+		filePath = outputFile
 	}
-	return nil
+	return printResult(ctx, fileResult{File: filePath}, func() {
+		fmt.Printf("Generate file %s\n", filePath)
+	})
 }
@@ -16,7 +16,7 @@ type TranscriptCMD struct {
 	Filename string `arg:""`
 
 	Backend           string `short:"b" default:"whisper" help:"Backend to run the transcription model"`
-	Model             string `short:"m" required:"" help:"Model name to run the TTS"`
+	Model             string `short:"m" required:"" completion:"models" help:"Model name to run the TTS"`
 	Language          string `short:"l" help:"Language of the audio file"`
 	Translate         bool   `short:"c" help:"Translate the transcription to english"`
 	Threads           int    `short:"t" default:"1" help:"Number of threads used for parallel computation"`
@@ -55,8 +55,9 @@ func (t *TranscriptCMD) Run(ctx *cliContext.Context) error {
 	if err != nil {
 		return err
 	}
-	for _, segment := range tr.Segments {
-		fmt.Println(segment.Start.String(), "-", segment.Text)
-	}
-	return nil
+	return printResult(ctx, tr, func() {
+		for _, segment := range tr.Segments {
+			fmt.Println(segment.Start.String(), "-", segment.Text)
+		}
+	})
 }
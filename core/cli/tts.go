@@ -18,7 +18,7 @@ type TTSCMD struct {
 	Text []string `arg:""`
 
 	Backend           string `short:"b" default:"piper" help:"Backend to run the TTS model"`
-	Model             string `short:"m" required:"" help:"Model name to run the TTS"`
+	Model             string `short:"m" required:"" completion:"models" help:"Model name to run the TTS"`
 	Voice             string `short:"v" help:"Voice name to run the TTS"`
 	Language          string `short:"l" help:"Language to use with the TTS"`
 	OutputFile        string `short:"o" type:"path" help:"The path to write the output wav file"`
@@ -61,9 +61,9 @@ func (t *TTSCMD) Run(ctx *cliContext.Context) error {
 		if err := os.Rename(filePath, outputFile); err != nil {
 			return err
 		}
-		fmt.Printf("Generate file %s\n", outputFile)
-	} else {
-		fmt.Printf("Generate file %s\n", filePath)
+		filePath = outputFile
 	}
-	return nil
+	return printResult(ctx, fileResult{File: filePath}, func() {
+		fmt.Printf("Generate file %s\n", filePath)
+	})
 }
@@ -4,19 +4,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 
 	cliContext "github.com/mudler/LocalAI/core/cli/context"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/model"
 	gguf "github.com/thxcode/gguf-parser-go"
 )
 
 type UtilCMD struct {
 	GGUFInfo GGUFInfoCMD `cmd:"" name:"gguf-info" help:"Get information about a GGUF file"`
 	HFScan   HFScanCMD   `cmd:"" name:"hf-scan" help:"Checks installed models for known security issues. WARNING: this is a best-effort feature and may not catch everything!"`
+
+	P2PConfigSyncKeys P2PConfigSyncKeysCMD `cmd:"" name:"p2p-config-sync-keys" help:"Generate the key pair signing the configuration published by the leader of a p2p network"`
+	TestTemplates     TestTemplatesCMD     `cmd:"" name:"test-templates" help:"Render the test cases of the templates of the models (template.tests of their config) and check the prompts, failing on a mismatch"`
+	InstallBackend    InstallBackendCMD    `cmd:"" name:"install-backend" help:"Install a backend and its python environment in the backend assets path, without the container images, and check that it starts"`
 }
 
 type GGUFInfoCMD struct {
@@ -30,6 +40,91 @@ type HFScanCMD struct {
 	ToScan     []string `arg:""`
 }
 
+type P2PConfigSyncKeysCMD struct{}
+
+type TestTemplatesCMD struct {
+	ModelsPath string   `env:"LOCALAI_MODELS_PATH,MODELS_PATH" type:"path" default:"${basepath}/models" help:"Path containing models used for inferencing" group:"storage"`
+	Models     []string `arg:"" optional:"" name:"models" help:"Models whose templates are tested, all by default"`
+}
+
+// templateTestResult is the JSON result of a test case of 'util test-templates'
+type templateTestResult struct {
+	Model    string   `json:"model"`
+	Test     string   `json:"test"`
+	Prompt   string   `json:"prompt"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+func (u *P2PConfigSyncKeysCMD) Run(ctx *cliContext.Context) error {
+	public, private, err := p2p.GenerateConfigSyncKeys()
+	if err != nil {
+		return err
+	}
+
+	return printResult(ctx, map[string]string{"public_key": public, "private_key": private}, func() {
+		fmt.Printf("Private key (--p2p-config-sync-key on the leader, keep it secret): %s\n", private)
+		fmt.Printf("Public key (--p2p-config-sync-trusted-key on the other nodes): %s\n", public)
+	})
+}
+
+func (u *TestTemplatesCMD) Run(ctx *cliContext.Context) error {
+	cl := config.NewBackendConfigLoader(u.ModelsPath)
+	if err := cl.LoadBackendConfigsFromPath(u.ModelsPath); err != nil {
+		return err
+	}
+	ml := model.NewModelLoader(u.ModelsPath)
+
+	results := []templateTestResult{}
+	for _, cfg := range cl.GetAllBackendConfigs() {
+		if len(u.Models) > 0 && !slices.Contains(u.Models, cfg.Name) {
+			continue
+		}
+		if cfg.TemplateConfig.UseTokenizerTemplate && len(cfg.TemplateConfig.Tests) > 0 {
+			log.Warn().Str("model", cfg.Name).Msg("the model uses the template of its tokenizer, applied by the backend: its template tests are skipped")
+			continue
+		}
+		for i, test := range cfg.TemplateConfig.Tests {
+			input := &schema.OpenAIRequest{}
+			for _, m := range test.Messages {
+				input.Messages = append(input.Messages, schema.Message{Role: m.Role, Content: m.Content, StringContent: m.Content})
+			}
+			name := test.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			prompt := openai.TemplateMessages(&cfg, input, ml, nil, false)
+			results = append(results, templateTestResult{Model: cfg.Name, Test: name, Prompt: prompt, Failures: test.Check(prompt)})
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if len(r.Failures) > 0 {
+			failed++
+		}
+	}
+	err := printResult(ctx, results, func() {
+		for _, r := range results {
+			if len(r.Failures) == 0 {
+				fmt.Printf("PASS %s: %s\n", r.Model, r.Test)
+				continue
+			}
+			fmt.Printf("FAIL %s: %s\n", r.Model, r.Test)
+			for _, f := range r.Failures {
+				fmt.Printf("  %s\n", strings.ReplaceAll(strings.TrimRight(f, "\n"), "\n", "\n  "))
+			}
+		}
+		fmt.Printf("%d template tests, %d failed\n", len(results), failed)
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d template tests failed", failed, len(results))
+	}
+	return nil
+}
+
 func (u *GGUFInfoCMD) Run(ctx *cliContext.Context) error {
 	if u.Args == nil || len(u.Args) == 0 {
 		return fmt.Errorf("no GGUF file provided")
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The scopes restrict the endpoints an API key may use. The keys without scopes may use all of them
+const (
+	// APIKeyScopeAdmin allows all the endpoints and the admin-scoped request fields, like --admin-api-keys
+	APIKeyScopeAdmin      = "admin"
+	APIKeyScopeChat       = "chat"
+	APIKeyScopeCompletion = "completion"
+	APIKeyScopeEmbeddings = "embeddings"
+	APIKeyScopeImages     = "images"
+	APIKeyScopeAudio      = "audio"
+	APIKeyScopeRerank     = "rerank"
+	APIKeyScopeFiles      = "files"
+	// APIKeyScopeGallery allows installing and deleting the models, and managing the galleries
+	APIKeyScopeGallery = "gallery"
+)
+
+var apiKeyScopes = []string{APIKeyScopeAdmin, APIKeyScopeChat, APIKeyScopeCompletion, APIKeyScopeEmbeddings,
+	APIKeyScopeImages, APIKeyScopeAudio, APIKeyScopeRerank, APIKeyScopeFiles, APIKeyScopeGallery}
+
+// The priorities of the inference requests, set by the API keys and by the X-LocalAI-Priority header: the queued
+// requests of higher priority run first
+const (
+	RequestPriorityHigh   = "high"
+	RequestPriorityNormal = "normal"
+	RequestPriorityLow    = "low"
+)
+
+var requestPriorities = []string{RequestPriorityHigh, RequestPriorityNormal, RequestPriorityLow}
+
+// IsAPIKeyScope tells whether s is one of the scopes of the API keys
+func IsAPIKeyScope(s string) bool {
+	return slices.Contains(apiKeyScopes, s)
+}
+
+// APIKey is an entry of api_keys.json. The entries are either the keys themselves, as strings, or objects
+// with the key or its SHA-256 hash and the restrictions of the key
+type APIKey struct {
+	Key string `json:"key,omitempty"`
+	// Hash is the hex-encoded SHA-256 of the key, optionally prefixed with sha256:
+	Hash      string     `json:"hash,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Models the key may use, all of them when empty
+	Models []string `json:"models,omitempty"`
+	// RateLimit is the number of requests per minute, unlimited when 0
+	RateLimit int `json:"rate_limit,omitempty"`
+	// Priority is the default and the highest priority of the inference requests of the key, normal when empty
+	Priority string `json:"priority,omitempty"`
+	// TokensPerSecond caps the tokens per second of the streamed responses of the key, unlimited when 0
+	TokensPerSecond int `json:"tokens_per_second,omitempty"`
+}
+
+// ID identifies the key in the logs and the rate limits, without revealing it
+func (k *APIKey) ID() string {
+	if k.Name != "" {
+		return k.Name
+	}
+	return k.hash()[:12]
+}
+
+func (k *APIKey) hash() string {
+	if k.Hash != "" {
+		return strings.ToLower(strings.TrimPrefix(k.Hash, "sha256:"))
+	}
+	sum := sha256.Sum256([]byte(k.Key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Matches returns whether the key sent by a client is this one
+func (k *APIKey) Matches(key string) bool {
+	if k.Key != "" {
+		return subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1
+	}
+	sum := sha256.Sum256([]byte(key))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(k.hash())) == 1
+}
+
+func (k *APIKey) Expired() bool {
+	return k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt)
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// AllowsScope returns whether the key may use the endpoints of the scope, empty for the endpoints open to all the
+// keys. The keys without scopes and the admin keys may use all of them
+func (k *APIKey) AllowsScope(scope string) bool {
+	return scope == "" || len(k.Scopes) == 0 || k.HasScope(APIKeyScopeAdmin) || k.HasScope(scope)
+}
+
+func (k *APIKey) AllowsModel(model string) bool {
+	return len(k.Models) == 0 || slices.Contains(k.Models, model)
+}
+
+func (k *APIKey) validate() error {
+	switch {
+	case k.Key == "" && k.Hash == "":
+		return fmt.Errorf("either key or hash is required")
+	case k.Key != "" && k.Hash != "":
+		return fmt.Errorf("key and hash are mutually exclusive")
+	}
+	if k.Hash != "" {
+		if h, err := hex.DecodeString(k.hash()); err != nil || len(h) != sha256.Size {
+			return fmt.Errorf("hash must be a hex-encoded SHA-256")
+		}
+	}
+	for _, s := range k.Scopes {
+		if !slices.Contains(apiKeyScopes, s) {
+			return fmt.Errorf("unknown scope %q, the scopes are %s", s, strings.Join(apiKeyScopes, ", "))
+		}
+	}
+	if k.RateLimit < 0 {
+		return fmt.Errorf("rate_limit cannot be negative")
+	}
+	if k.Priority != "" && !slices.Contains(requestPriorities, k.Priority) {
+		return fmt.Errorf("unknown priority %q, the priorities are %s", k.Priority, strings.Join(requestPriorities, ", "))
+	}
+	if k.TokensPerSecond < 0 {
+		return fmt.Errorf("tokens_per_second cannot be negative")
+	}
+	return nil
+}
+
+// ParseAPIKeys parses and validates the content of api_keys.json, a list of keys as strings or as objects
+func ParseAPIKeys(data []byte) ([]APIKey, error) {
+	entries := []json.RawMessage{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	keys := []APIKey{}
+	names := map[string]bool{}
+	for i, e := range entries {
+		k := APIKey{}
+		if err := json.Unmarshal(e, &k.Key); err != nil {
+			dec := json.NewDecoder(bytes.NewReader(e))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&k); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+1, err)
+			}
+		}
+		if err := k.validate(); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		if k.Name != "" {
+			if names[k.Name] {
+				return nil, fmt.Errorf("entry %d: the name %s is already used", i+1, k.Name)
+			}
+			names[k.Name] = true
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// APIKeyStore holds the keys of api_keys.json, replaced as a whole when the file is reloaded
+type APIKeyStore struct {
+	sync.RWMutex
+	keys []APIKey
+}
+
+func (s *APIKeyStore) Set(keys []APIKey) {
+	s.Lock()
+	defer s.Unlock()
+	s.keys = keys
+}
+
+func (s *APIKeyStore) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.keys)
+}
+
+// Lookup returns the entry of the key sent by a client
+func (s *APIKeyStore) Lookup(key string) (*APIKey, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.RLock()
+	defer s.RUnlock()
+	for i := range s.keys {
+		if s.keys[i].Matches(key) {
+			k := s.keys[i]
+			return &k, true
+		}
+	}
+	return nil, false
+}
+
+// Keys returns the entries of api_keys.json
+func (s *APIKeyStore) Keys() []APIKey {
+	if s == nil {
+		return []APIKey{}
+	}
+	s.RLock()
+	defer s.RUnlock()
+	return append([]APIKey{}, s.keys...)
+}
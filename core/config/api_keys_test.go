@@ -0,0 +1,90 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("API keys file", func() {
+	It("accepts the plain list of keys", func() {
+		keys, err := ParseAPIKeys([]byte(`["key-1", "key-2"]`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+		Expect(keys[0].Matches("key-1")).To(BeTrue())
+		Expect(keys[0].Matches("key-2")).To(BeFalse())
+
+		store := &APIKeyStore{}
+		store.Set(keys)
+		Expect(store.Keys()).To(Equal(keys))
+	})
+
+	It("accepts the keys with metadata, mixed with the plain ones", func() {
+		sum := sha256.Sum256([]byte("secret"))
+		keys, err := ParseAPIKeys([]byte(`["key-1", {
+			"hash": "sha256:` + hex.EncodeToString(sum[:]) + `",
+			"name": "ci",
+			"scopes": ["admin"],
+			"expires_at": "2020-01-01T00:00:00Z",
+			"models": ["phi-2"],
+			"rate_limit": 10,
+			"priority": "low"
+		}]`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keys).To(HaveLen(2))
+
+		store := &APIKeyStore{}
+		store.Set(keys)
+		entry, ok := store.Lookup("secret")
+		Expect(ok).To(BeTrue())
+		Expect(entry.ID()).To(Equal("ci"))
+		Expect(entry.HasScope(APIKeyScopeAdmin)).To(BeTrue())
+		Expect(entry.Expired()).To(BeTrue())
+		Expect(entry.AllowsModel("phi-2")).To(BeTrue())
+		Expect(entry.AllowsModel("whisper-1")).To(BeFalse())
+		Expect(entry.RateLimit).To(Equal(10))
+		Expect(entry.Priority).To(Equal(RequestPriorityLow))
+		Expect(store.Keys()).To(Equal(keys))
+
+		_, ok = store.Lookup("other")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("doesn't expire the keys without expiry", func() {
+		future := time.Now().Add(time.Hour)
+		Expect((&APIKey{Key: "k"}).Expired()).To(BeFalse())
+		Expect((&APIKey{Key: "k", ExpiresAt: &future}).Expired()).To(BeFalse())
+		Expect((&APIKey{Key: "k"}).AllowsModel("any")).To(BeTrue())
+	})
+
+	It("restricts the keys with scopes to their endpoints", func() {
+		Expect((&APIKey{Key: "k"}).AllowsScope(APIKeyScopeGallery)).To(BeTrue())
+		Expect((&APIKey{Key: "k", Scopes: []string{APIKeyScopeAdmin}}).AllowsScope(APIKeyScopeGallery)).To(BeTrue())
+
+		chat := &APIKey{Key: "k", Scopes: []string{APIKeyScopeChat, APIKeyScopeEmbeddings}}
+		Expect(chat.AllowsScope(APIKeyScopeChat)).To(BeTrue())
+		Expect(chat.AllowsScope(APIKeyScopeEmbeddings)).To(BeTrue())
+		Expect(chat.AllowsScope("")).To(BeTrue())
+		Expect(chat.AllowsScope(APIKeyScopeGallery)).To(BeFalse())
+		Expect(chat.AllowsScope(APIKeyScopeAdmin)).To(BeFalse())
+	})
+
+	DescribeTable("rejects the invalid entries", func(content, message string) {
+		_, err := ParseAPIKeys([]byte(content))
+		Expect(err).To(MatchError(ContainSubstring(message)))
+	},
+		Entry("no key", `[{"name": "ci"}]`, "entry 1: either key or hash is required"),
+		Entry("key and hash", `[{"key": "k", "hash": "abc"}]`, "mutually exclusive"),
+		Entry("invalid hash", `["k", {"hash": "abc"}]`, "entry 2: hash must be a hex-encoded SHA-256"),
+		Entry("unknown scope", `[{"key": "k", "scopes": ["root"]}]`, `unknown scope "root"`),
+		Entry("unknown field", `[{"key": "k", "model": ["phi-2"]}]`, `unknown field "model"`),
+		Entry("duplicate name", `[{"key": "a", "name": "ci"}, {"key": "b", "name": "ci"}]`, "entry 2: the name ci is already used"),
+		Entry("negative rate limit", `[{"key": "k", "rate_limit": -1}]`, "rate_limit cannot be negative"),
+		Entry("unknown priority", `[{"key": "k", "priority": "urgent"}]`, `unknown priority "urgent"`),
+		Entry("negative tokens per second", `[{"key": "k", "tokens_per_second": -1}]`, "tokens_per_second cannot be negative"),
+		Entry("not a list", `{"key": "k"}`, "cannot unmarshal"),
+	)
+})
@@ -6,6 +6,14 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/events"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/scanner"
+	"github.com/mudler/LocalAI/pkg/storage"
+	"github.com/mudler/LocalAI/pkg/store"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/mudler/LocalAI/pkg/xnet"
 	"github.com/mudler/LocalAI/pkg/xsysinfo"
 	"github.com/rs/zerolog/log"
 )
@@ -16,6 +24,7 @@ type ApplicationConfig struct {
 	ModelPath                           string
 	LibPath                             string
 	UploadLimitMB, Threads, ContextSize int
+	ImageMaxDimension, ImageMaxPixels   int
 	DisableWebUI                        bool
 	F16                                 bool
 	Debug                               bool
@@ -23,14 +32,23 @@ type ApplicationConfig struct {
 	AudioDir                            string
 	UploadDir                           string
 	ConfigsDir                          string
+	DiagnosticsDir                      string
+	BackendStateDir                     string      // where the sessions of the models with warm_restart are kept while their backend restarts
+	ObjectStorage                       *storage.S3 // keeps the generated images and audio and the uploads, instead of their directories
+	UploadScanner                       scanner.Scanner
+	UploadQuarantineDir                 string // when set, the uploads flagged by the scanner are kept there
 	DynamicConfigsDir                   string
 	DynamicConfigsDirPollInterval       time.Duration
 	CORS                                bool
 	CSRF                                bool
 	PreloadJSONModels                   string
 	PreloadModelsFromPath               string
+	PreloadParallelism                  int
+	TorrentOptions                      downloader.TorrentOptions
+	DisableBlobStore                    bool
 	CORSAllowOrigins                    string
 	ApiKeys                             []string
+	AdminApiKeys                        []string
 	EnforcePredownloadScans             bool
 	OpaqueErrors                        bool
 	P2PToken                            string
@@ -44,11 +62,38 @@ type ApplicationConfig struct {
 	AssetsDestination string
 
 	ExternalGRPCBackends map[string]string
+	// ExternalGRPCBackendsCredentials are used to dial the external backends given by address
+	ExternalGRPCBackendsCredentials *grpc.Credentials
+
+	// PreloadFailures holds the models that failed to be preloaded at startup, with the error
+	PreloadFailures map[string]string
+
+	// VectorStores maps a store name to an external vector database
+	VectorStores map[string]store.Config
+
+	// GenerationPresets maps a preset name to the request fields it sets
+	GenerationPresets map[string]json.RawMessage
+
+	// MemoryModel is the model extracting the facts to remember about the users, empty when the memory is disabled
+	MemoryModel string
+
+	// ApiKeyStore holds the keys of api_keys.json, with their metadata and restrictions
+	ApiKeyStore *APIKeyStore
+
+	// Events broadcasts the changes of the state of LocalAI to the subscribers of /events
+	Events *events.Bus
+
+	// TokenBudget is the number of tokens a conversation may use, 0 when unlimited
+	TokenBudget int
+	// ApiKeyTokenBudgets overrides TokenBudget for the conversations of the API keys
+	ApiKeyTokenBudgets map[string]int
 
 	AutoloadGalleries bool
 
 	SingleBackend           bool
 	ParallelBackendRequests bool
+	// NUMAAuto spreads the backends of the models without cpus nor numa_node on the NUMA nodes of the machine
+	NUMAAuto bool
 
 	WatchDogIdle bool
 	WatchDogBusy bool
@@ -58,6 +103,75 @@ type ApplicationConfig struct {
 
 	WatchDogBusyTimeout, WatchDogIdleTimeout time.Duration
 
+	// ReadTimeout and WriteTimeout bound the requests, except the uploads bound by UploadTimeout and the streamed
+	// answers by StreamTimeout
+	ReadTimeout, WriteTimeout, UploadTimeout, StreamTimeout time.Duration
+
+	// AdaptiveThreads splits the threads of the models between the requests running in parallel
+	AdaptiveThreads bool
+
+	// PrefetchModels loads in the background the model likely used after the one of the current request
+	PrefetchModels bool
+
+	// AutoShutdownAfter stops the server when no request arrived for this long, 0 disables it
+	AutoShutdownAfter time.Duration
+
+	// ModelMetrics exposes in /metrics the per-model statistics of the requests and of the backends
+	ModelMetrics bool
+
+	// OTelEndpoint is the OTLP/HTTP endpoint of the collector receiving the traces of the requests, empty when
+	// the tracing is disabled
+	OTelEndpoint string
+
+	// OIDCIssuer is the OIDC issuer of the JWTs accepted as bearer tokens besides the API keys, empty when they
+	// aren't accepted. The keys are fetched from OIDCJWKSURL, or discovered from the issuer when it's empty
+	OIDCIssuer string
+	// OIDCAudience is the audience the tokens must be intended for, required with OIDCIssuer
+	OIDCAudience string
+	OIDCJWKSURL  string
+	// OIDCScopesClaim is the claim of the tokens holding their scopes, "scope" by default
+	OIDCScopesClaim string
+	// OIDCScopeMap maps the values of the scopes claim to the scopes of LocalAI, separated by commas
+	OIDCScopeMap map[string]string
+	// OIDCPassthroughScopes also grants the values of the scopes claim which are scopes of LocalAI as is
+	OIDCPassthroughScopes bool
+
+	// DisableUsageAccounting stops counting the tokens used per API key and per model
+	DisableUsageAccounting bool
+
+	// LatencySLO is the time the chat and completion requests of the models without a latency_slo should complete
+	// within, 0 when they are all admitted. The requests which can't meet it wait up to AdmissionQueueTimeout for
+	// the requests in progress, then are refused
+	LatencySLO            time.Duration
+	AdmissionQueueTimeout time.Duration
+
+	// MaxConcurrentInferences bounds the inference requests running at once, unlimited when 0. Up to
+	// MaxQueuedInferences requests wait for a slot, the others are refused with 429
+	MaxConcurrentInferences int
+	MaxQueuedInferences     int
+
+	// BatchConcurrency is the number of requests of the batches of /v1/batches running at once, across the batches
+	BatchConcurrency int
+
+	// UserRateLimit is the maximum number of requests per minute of each end user, identified by the user field of
+	// the requests and their API key, 0 when it's unlimited
+	UserRateLimit int
+
+	// AuditLog is where the audit log of the API requests is written: a file, syslog or syslog://host:port, empty
+	// when it's disabled
+	AuditLog string
+	// AuditLogMaxSizeMB is the size at which the audit log file is rotated, 0 disables the rotation
+	AuditLogMaxSizeMB  int
+	AuditLogMaxBackups int
+	// AuditLogRedact replaces the prompts of the audit log by their length
+	AuditLogRedact bool
+
+	// NetworkGuard blocks the outbound connections in privacy mode, nil when it's disabled
+	NetworkGuard *xnet.Guard
+
+	// FaultInjection enables the /faults endpoints, injecting delays, errors and truncated streams in the requests
+	FaultInjection bool
+
 	DisableGalleryEndpoint bool
 }
 
@@ -69,6 +183,8 @@ func NewApplicationConfig(o ...AppOption) *ApplicationConfig {
 		UploadLimitMB: 15,
 		ContextSize:   512,
 		Debug:         true,
+		ApiKeyStore:   &APIKeyStore{},
+		Events:        events.NewBus(),
 	}
 	for _, oo := range o {
 		oo(opt)
@@ -137,6 +253,10 @@ var DisableGalleryEndpoint = func(o *ApplicationConfig) {
 	o.DisableGalleryEndpoint = true
 }
 
+var DisableBlobStore = func(o *ApplicationConfig) {
+	o.DisableBlobStore = true
+}
+
 var EnableWatchDogBusyCheck = func(o *ApplicationConfig) {
 	o.WatchDog = true
 	o.WatchDogBusy = true
@@ -146,6 +266,10 @@ var DisableWebUI = func(o *ApplicationConfig) {
 	o.DisableWebUI = true
 }
 
+var DisableUsageAccounting = func(o *ApplicationConfig) {
+	o.DisableUsageAccounting = true
+}
+
 func SetWatchDogBusyTimeout(t time.Duration) AppOption {
 	return func(o *ApplicationConfig) {
 		o.WatchDogBusyTimeout = t
@@ -162,14 +286,32 @@ var EnableSingleBackend = func(o *ApplicationConfig) {
 	o.SingleBackend = true
 }
 
+var EnableNUMAAuto = func(o *ApplicationConfig) {
+	o.NUMAAuto = true
+}
+
 var EnableParallelBackendRequests = func(o *ApplicationConfig) {
 	o.ParallelBackendRequests = true
 }
 
+var EnableAdaptiveThreads = func(o *ApplicationConfig) {
+	o.AdaptiveThreads = true
+}
+
+var EnableModelPrefetch = func(o *ApplicationConfig) {
+	o.PrefetchModels = true
+}
+
 var EnableGalleriesAutoload = func(o *ApplicationConfig) {
 	o.AutoloadGalleries = true
 }
 
+func WithExternalBackendsCredentials(credentials *grpc.Credentials) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ExternalGRPCBackendsCredentials = credentials
+	}
+}
+
 func WithExternalBackend(name string, uri string) AppOption {
 	return func(o *ApplicationConfig) {
 		if o.ExternalGRPCBackends == nil {
@@ -179,6 +321,15 @@ func WithExternalBackend(name string, uri string) AppOption {
 	}
 }
 
+func WithVectorStore(name string, c store.Config) AppOption {
+	return func(o *ApplicationConfig) {
+		if o.VectorStores == nil {
+			o.VectorStores = make(map[string]store.Config)
+		}
+		o.VectorStores[name] = c
+	}
+}
+
 func WithCorsAllowOrigins(b string) AppOption {
 	return func(o *ApplicationConfig) {
 		o.CORSAllowOrigins = b
@@ -229,6 +380,24 @@ func WithYAMLConfigPreload(configFile string) AppOption {
 	}
 }
 
+func WithPreloadParallelism(n int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.PreloadParallelism = n
+	}
+}
+
+// WithTorrentSeeding seeds the files downloaded over BitTorrent until they are uploaded ratio times their size or
+// for seedTime, whichever comes first (0 for no limit)
+func WithTorrentSeeding(ratio float64, seedTime time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.TorrentOptions = downloader.TorrentOptions{
+			Seed:      true,
+			SeedRatio: ratio,
+			SeedTime:  seedTime,
+		}
+	}
+}
+
 func WithJSONStringPreload(configFile string) AppOption {
 	return func(o *ApplicationConfig) {
 		o.PreloadJSONModels = configFile
@@ -240,12 +409,213 @@ func WithConfigFile(configFile string) AppOption {
 	}
 }
 
+// ImageLimits returns the limits applied to the input images
+func (o *ApplicationConfig) ImageLimits() utils.ImageLimits {
+	return utils.ImageLimits{
+		MaxDimension: o.ImageMaxDimension,
+		MaxPixels:    o.ImageMaxPixels,
+	}
+}
+
+// WithUploadScanner scans the uploaded files before they are stored or processed. The flagged files
+// are rejected, and kept in quarantineDir when it's set
+func WithUploadScanner(s scanner.Scanner, quarantineDir string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.UploadScanner = s
+		o.UploadQuarantineDir = quarantineDir
+	}
+}
+
+// WithObjectStorage stores the generated artifacts and the uploads in an S3-compatible object storage
+func WithObjectStorage(s *storage.S3) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ObjectStorage = s
+	}
+}
+
+// ImageStorage returns where the generated images are stored
+func (o *ApplicationConfig) ImageStorage() storage.Storage {
+	if o.ObjectStorage != nil {
+		return o.ObjectStorage.Sub("images")
+	}
+	return storage.NewLocal(o.ImageDir, "/generated-images")
+}
+
+// AudioStorage returns where the generated audio files are stored
+func (o *ApplicationConfig) AudioStorage() storage.Storage {
+	if o.ObjectStorage != nil {
+		return o.ObjectStorage.Sub("audio")
+	}
+	return storage.NewLocal(o.AudioDir, "/generated-audio")
+}
+
+// UploadStorage returns where the files uploaded with the files API are stored
+func (o *ApplicationConfig) UploadStorage() storage.Storage {
+	if o.ObjectStorage != nil {
+		return o.ObjectStorage.Sub("uploads")
+	}
+	return storage.NewLocal(o.UploadDir, "")
+}
+
+// WithImageMaxDimension sets the size to which the input images are downscaled
+func WithImageMaxDimension(size int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ImageMaxDimension = size
+	}
+}
+
+// WithImageMaxPixels sets the number of pixels above which input images are rejected
+func WithImageMaxPixels(pixels int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ImageMaxPixels = pixels
+	}
+}
+
+// WithAutoShutdownAfter stops the server when no request arrived for the given duration
+func WithAutoShutdownAfter(d time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.AutoShutdownAfter = d
+	}
+}
+
+// WithModelMetrics exposes in /metrics the per-model requests, tokens, time to first token, queue depth and
+// backend load events
+func WithModelMetrics(enabled bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ModelMetrics = enabled
+	}
+}
+
+// WithOTelEndpoint sends the traces of the requests to the OTLP/HTTP endpoint of a collector
+func WithOTelEndpoint(endpoint string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.OTelEndpoint = endpoint
+	}
+}
+
+// WithOIDC accepts the JWTs of the OIDC issuer for the audience as bearer tokens, their scopes being read from the
+// scopes claim and mapped to the scopes of LocalAI with scopeMap
+func WithOIDC(issuer, audience, jwksURL, scopesClaim string, scopeMap map[string]string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.OIDCIssuer = issuer
+		o.OIDCAudience = audience
+		o.OIDCJWKSURL = jwksURL
+		o.OIDCScopesClaim = scopesClaim
+		o.OIDCScopeMap = scopeMap
+	}
+}
+
+// WithOIDCPassthroughScopes grants the values of the scopes claim of the JWTs which are scopes of LocalAI, besides
+// the ones of the scope map
+func WithOIDCPassthroughScopes(enabled bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.OIDCPassthroughScopes = enabled
+	}
+}
+
+// WithInferenceQueue runs up to maxConcurrent inference requests at once, queueing up to maxQueued others
+func WithInferenceQueue(maxConcurrent, maxQueued int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.MaxConcurrentInferences = maxConcurrent
+		o.MaxQueuedInferences = maxQueued
+	}
+}
+
+func WithBatchConcurrency(n int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BatchConcurrency = n
+	}
+}
+
+// WithLatencySLO admits the chat and completion requests as long as they can complete within the SLO, the others
+// waiting up to queueTimeout before being refused
+func WithLatencySLO(slo, queueTimeout time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.LatencySLO = slo
+		o.AdmissionQueueTimeout = queueTimeout
+	}
+}
+
+// WithUserRateLimit limits the requests per minute of each end user, identified by the user field of the requests
+func WithUserRateLimit(limit int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.UserRateLimit = limit
+	}
+}
+
+// WithAuditLog writes the audit log of the API requests to a file, to the local syslog or to syslog://host:port
+func WithAuditLog(target string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.AuditLog = target
+	}
+}
+
+// WithAuditLogRotation rotates the audit log file once it reaches maxSizeMB, keeping the given number of files
+func WithAuditLogRotation(maxSizeMB, backups int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.AuditLogMaxSizeMB = maxSizeMB
+		o.AuditLogMaxBackups = backups
+	}
+}
+
+func WithAuditLogRedaction(redact bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.AuditLogRedact = redact
+	}
+}
+
+// WithNetworkGuard enables the privacy mode, the guard is installed at startup
+func WithNetworkGuard(g *xnet.Guard) AppOption {
+	return func(o *ApplicationConfig) {
+		o.NetworkGuard = g
+	}
+}
+
+// WithFaultInjection enables the injection of faults in the requests, managed by the admins through /faults
+func WithFaultInjection(enabled bool) AppOption {
+	return func(o *ApplicationConfig) {
+		o.FaultInjection = enabled
+	}
+}
+
+// WithMemoryModel enables the long-term memory, extracting the facts to remember with the given model
+func WithMemoryModel(model string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.MemoryModel = model
+	}
+}
+
+// WithTokenBudget limits the number of tokens a conversation may use
+func WithTokenBudget(budget int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.TokenBudget = budget
+	}
+}
+
+// WithApiKeyTokenBudgets sets the token budget of the conversations of each API key
+func WithApiKeyTokenBudgets(budgets map[string]int) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ApiKeyTokenBudgets = budgets
+	}
+}
+
 func WithUploadLimitMB(limit int) AppOption {
 	return func(o *ApplicationConfig) {
 		o.UploadLimitMB = limit
 	}
 }
 
+// WithRequestTimeouts sets the timeouts of the requests: the time to receive them, to send their response, to receive
+// the uploads and to send the streamed answers
+func WithRequestTimeouts(read, write, upload, stream time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ReadTimeout = read
+		o.WriteTimeout = write
+		o.UploadTimeout = upload
+		o.StreamTimeout = stream
+	}
+}
+
 func WithThreads(threads int) AppOption {
 	return func(o *ApplicationConfig) {
 		if threads == 0 { // 0 is not allowed
@@ -291,6 +661,20 @@ func WithUploadDir(uploadDir string) AppOption {
 	}
 }
 
+// WithDiagnosticsDir sets where the diagnostics bundles of the backend crashes are collected, empty disables them
+func WithDiagnosticsDir(diagnosticsDir string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.DiagnosticsDir = diagnosticsDir
+	}
+}
+
+// WithBackendStateDir sets where the sessions of the models with warm_restart are saved when their backend is stopped
+func WithBackendStateDir(stateDir string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BackendStateDir = stateDir
+	}
+}
+
 func WithConfigsDir(configsDir string) AppOption {
 	return func(o *ApplicationConfig) {
 		o.ConfigsDir = configsDir
@@ -315,6 +699,13 @@ func WithApiKeys(apiKeys []string) AppOption {
 	}
 }
 
+// WithAdminApiKeys sets the API keys allowed to use the admin-scoped request fields, e.g. backend
+func WithAdminApiKeys(apiKeys []string) AppOption {
+	return func(o *ApplicationConfig) {
+		o.AdminApiKeys = apiKeys
+	}
+}
+
 func WithEnforcedPredownloadScans(enforced bool) AppOption {
 	return func(o *ApplicationConfig) {
 		o.EnforcePredownloadScans = enforced
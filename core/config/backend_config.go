@@ -1,19 +1,36 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/downloader"
 	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/reasoning"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
 )
 
 const (
 	RAND_SEED = -1
 )
 
+// TensorSplitAuto splits the layers of a model between the GPUs proportionally to their free VRAM
+const TensorSplitAuto = "auto"
+
+// maxTensorSplitDevices is the number of GPUs llama.cpp can split a model between
+const maxTensorSplitDevices = 16
+
+var numaStrategies = []string{"distribute", "isolate", "numactl"}
+
+// NUMANodeAuto spreads the backends on the NUMA nodes of the machine
+const NUMANodeAuto = "auto"
+
 type TTSConfig struct {
 
 	// Voice wav path or id
@@ -43,6 +60,9 @@ type BackendConfig struct {
 
 	FunctionsConfig functions.FunctionsConfig `yaml:"function"`
 
+	// Reasoning configures how the reasoning blocks (e.g. <think>...</think>) of the model are returned
+	Reasoning reasoning.Config `yaml:"reasoning"`
+
 	FeatureFlag FeatureFlag `yaml:"feature_flags"` // Feature Flag registry. We move fast, and features may break on a per model/backend basis. Registry for (usually temporary) flags that indicate aborting something early.
 	// LLM configs (GPT4ALL, Llama.cpp, ...)
 	LLMConfig `yaml:",inline"`
@@ -66,8 +86,26 @@ type BackendConfig struct {
 
 	DownloadFiles []File `yaml:"download_files"`
 
+	// Proxy specifics
+	Proxy Proxy `yaml:"proxy"`
+
 	Description string `yaml:"description"`
 	Usage       string `yaml:"usage"`
+
+	// Deprecation marks the model as deprecated, to migrate its clients to a replacement
+	Deprecation Deprecation `yaml:"deprecation"`
+
+	// Maintenance takes the model down, e.g. while it's re-quantized, without breaking its clients
+	Maintenance Maintenance `yaml:"maintenance_mode"`
+
+	// Capabilities overrides the capability tags derived from the config and the backend
+	Capabilities []string `yaml:"capabilities"`
+
+	// Translation routes the requests of /v1/translate to the model
+	Translation Translation `yaml:"translation"`
+
+	// Moderation routes the requests of /v1/moderations to the model
+	Moderation Moderation `yaml:"moderation"`
 }
 
 type File struct {
@@ -87,6 +125,235 @@ func (ff FeatureFlag) Enabled(s string) bool {
 	return exist && v != nil && *v
 }
 
+// ProxyBackend is the backend of the models served by a remote OpenAI-compatible upstream
+const ProxyBackend = "proxy"
+
+type Proxy struct {
+	URL    string `yaml:"url"`     // Base URL of the upstream API, e.g. https://api.openai.com/v1
+	APIKey string `yaml:"api_key"` // Key sent to the upstream as Bearer token
+	Model  string `yaml:"model"`   // Model name on the upstream, defaults to the model name
+}
+
+// Deprecation marks a model as deprecated: its requests still work, but are answered with a warning
+type Deprecation struct {
+	Deprecated  bool   `yaml:"deprecated"`
+	Replacement string `yaml:"replacement"` // Model to use instead
+	Sunset      string `yaml:"sunset"`      // Date when the model is removed, e.g. 2025-06-30
+}
+
+// SunsetTime returns the date when the model is removed, if any
+func (d Deprecation) SunsetTime() (time.Time, bool) {
+	if d.Sunset == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.DateOnly, d.Sunset)
+	return t, err == nil
+}
+
+// Warning returns the warning of the requests to the deprecated model
+func (d Deprecation) Warning(model string) string {
+	warning := fmt.Sprintf("the model %s is deprecated", model)
+	if sunset, ok := d.SunsetTime(); ok {
+		warning += fmt.Sprintf(" and will be removed on %s", sunset.Format(time.DateOnly))
+	}
+	if d.Replacement != "" {
+		warning += fmt.Sprintf(", use %s instead", d.Replacement)
+	}
+	return warning
+}
+
+// Maintenance takes a model down: its requests are answered without loading the backend, with the canned
+// response when set, and with a 503 error telling when the model is back otherwise
+type Maintenance struct {
+	Enabled  bool   `yaml:"enabled"`
+	Response string `yaml:"response"` // Canned answer of the chat and completion requests
+	Message  string `yaml:"message"`  // Reason of the maintenance, e.g. "re-quantization"
+	Until    string `yaml:"until"`    // End of the maintenance, e.g. 2025-06-30 or 2025-06-30T18:00:00Z
+}
+
+// UntilTime returns the end of the maintenance, if any
+func (m Maintenance) UntilTime() (time.Time, bool) {
+	if m.Until == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, m.Until); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Error returns the error of the requests to the model under maintenance
+func (m Maintenance) Error(model string) string {
+	msg := fmt.Sprintf("the model %s is under maintenance", model)
+	if until, ok := m.UntilTime(); ok {
+		msg += fmt.Sprintf(" until %s", until.UTC().Format(time.RFC3339))
+	}
+	if m.Message != "" {
+		msg += ": " + m.Message
+	}
+	return msg
+}
+
+// Seq2SeqModelType is the type of the dedicated translation models of the transformers backend, e.g. NLLB or M2M100
+const Seq2SeqModelType = "AutoModelForSeq2SeqLM"
+
+// Translation routes the requests of /v1/translate translating the language pairs to the model. The dedicated
+// translation models receive the text to translate with the languages, the other models are instructed to translate
+type Translation struct {
+	Enabled bool `yaml:"enabled"`
+	// Pairs are the translated language pairs, as source-target ISO 639-1 codes (e.g. en-fr), * matching any
+	// language. All the pairs are translated when empty
+	Pairs []string `yaml:"pairs"`
+	// Codes are the codes of the languages of the dedicated models, by ISO 639-1 code, e.g. fr: fra_Latn for NLLB.
+	// The ISO 639-1 codes are used for the languages not listed
+	Codes map[string]string `yaml:"codes"`
+}
+
+// Translates tells whether the model translates from the source to the target language, the source being any
+// language when it's empty
+func (t Translation) Translates(source, target string) bool {
+	if !t.Enabled {
+		return false
+	}
+	if len(t.Pairs) == 0 {
+		return true
+	}
+	for _, pair := range t.Pairs {
+		s, tg, _ := strings.Cut(strings.ToLower(pair), "-")
+		if (s == "*" || s == source || source == "") && (tg == "*" || tg == target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Code returns the code of the language for the model
+func (t Translation) Code(language string) string {
+	if code, exists := t.Codes[language]; exists {
+		return code
+	}
+	return language
+}
+
+// IsTranslationModel tells whether the model is a dedicated translation model, rather than a model instructed to
+// translate
+func (c *BackendConfig) IsTranslationModel() bool {
+	return c.ModelType == Seq2SeqModelType
+}
+
+// SequenceClassificationModelType is the type of the classifier models of the transformers backend, e.g. the
+// toxicity classifiers answering the moderations
+const SequenceClassificationModelType = "AutoModelForSequenceClassification"
+
+// Moderation routes the requests of /v1/moderations to the model. The classifier models score the labels of the
+// texts, the other models are asked to judge them with a prompt
+type Moderation struct {
+	Enabled bool `yaml:"enabled"`
+	// Template is the judge prompt of the instructed models, the text being {{.Input}}. The model must answer with
+	// a JSON object of the scores of the categories, between 0 and 1. A prompt judging the categories of OpenAI is
+	// used when empty
+	Template string `yaml:"template"`
+	// Categories maps the labels of the classifier, or the keys answered by the judge, to the moderation categories
+	// (e.g. toxic: harassment). The labels not listed are returned as categories
+	Categories map[string]string `yaml:"categories"`
+	// Threshold is the score from which a category is flagged, 0.5 when not set
+	Threshold float64 `yaml:"threshold"`
+}
+
+// Category returns the moderation category of the label of the model
+func (m Moderation) Category(label string) string {
+	if category, exists := m.Categories[label]; exists {
+		return category
+	}
+	return label
+}
+
+// Flags tells whether the score flags its category
+func (m Moderation) Flags(score float64) bool {
+	threshold := m.Threshold
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+	return score >= threshold
+}
+
+// IsClassifierModel tells whether the model is a classifier, rather than a model asked to judge the texts
+func (c *BackendConfig) IsClassifierModel() bool {
+	return c.ModelType == SequenceClassificationModelType
+}
+
+// OOMRetry reloads the model with reduced settings when its backend runs out of memory: each attempt halves the
+// GPU layers, the context size and the batch size, down to the floors
+type OOMRetry struct {
+	Enabled        bool `yaml:"enabled"`
+	Attempts       int  `yaml:"attempts"`         // 3 by default
+	MinGPULayers   int  `yaml:"min_gpu_layers"`   // 0 by default, running the model on the CPU
+	MinContextSize int  `yaml:"min_context_size"` // 512 by default
+	MinBatch       int  `yaml:"min_batch"`        // 32 by default
+}
+
+// DefaultBatch is the batch size of the backends when the config doesn't set it
+const DefaultBatch = 512
+
+const (
+	defaultOOMRetryAttempts    = 3
+	defaultOOMRetryContextSize = 512
+	defaultOOMRetryBatch       = 32
+	// oomRetryAllLayers is the number of GPU layers kept by the first attempt when many more are offloaded, e.g.
+	// all of them by default: the number of layers of the model isn't known
+	oomRetryAllLayers = 32
+)
+
+// MaxAttempts returns the number of times the model is reloaded with reduced settings
+func (r OOMRetry) MaxAttempts() int {
+	if !r.Enabled {
+		return 0
+	}
+	if r.Attempts <= 0 {
+		return defaultOOMRetryAttempts
+	}
+	return r.Attempts
+}
+
+// Reduce halves the GPU layers, the context size and the batch size of the config, down to the floors, and returns
+// the changes. It returns none when the settings are all at their floors
+func (r OOMRetry) Reduce(c *BackendConfig) []string {
+	minContextSize, minBatch := r.MinContextSize, r.MinBatch
+	if minContextSize <= 0 {
+		minContextSize = defaultOOMRetryContextSize
+	}
+	if minBatch <= 0 {
+		minBatch = defaultOOMRetryBatch
+	}
+
+	changes := []string{}
+	if c.NGPULayers != nil && *c.NGPULayers > r.MinGPULayers {
+		layers := *c.NGPULayers / 2
+		if *c.NGPULayers > 2*oomRetryAllLayers {
+			layers = oomRetryAllLayers
+		}
+		layers = max(layers, r.MinGPULayers)
+		changes = append(changes, fmt.Sprintf("gpu_layers reduced to %d", layers))
+		c.NGPULayers = &layers
+	}
+	if c.ContextSize != nil && *c.ContextSize > minContextSize {
+		contextSize := max(*c.ContextSize/2, minContextSize)
+		changes = append(changes, fmt.Sprintf("context_size reduced to %d", contextSize))
+		c.ContextSize = &contextSize
+	}
+	batch := c.Batch
+	if batch == 0 {
+		batch = DefaultBatch
+	}
+	if batch > minBatch {
+		c.Batch = max(batch/2, minBatch)
+		changes = append(changes, fmt.Sprintf("batch reduced to %d", c.Batch))
+	}
+	return changes
+}
+
 type GRPC struct {
 	Attempts          int `yaml:"attempts"`
 	AttemptsSleepTime int `yaml:"attempts_sleep_time"`
@@ -109,7 +376,8 @@ type Diffusers struct {
 // generic for most of the LLM backends.
 type LLMConfig struct {
 	SystemPrompt    string   `yaml:"system_prompt"`
-	TensorSplit     string   `yaml:"tensor_split"`
+	ForceLanguage   string   `yaml:"force_language"` // ISO 639-1 code of the language of the answers, e.g. it
+	TensorSplit     string   `yaml:"tensor_split"`   // ratios of the layers on each GPU (e.g. 3,1), or auto to split them by free VRAM
 	MainGPU         string   `yaml:"main_gpu"`
 	RMSNormEps      float32  `yaml:"rms_norm_eps"`
 	NGQA            int32    `yaml:"ngqa"`
@@ -131,6 +399,9 @@ type LLMConfig struct {
 
 	ContextSize          *int    `yaml:"context_size"`
 	NUMA                 bool    `yaml:"numa"`
+	NUMAStrategy         string  `yaml:"numa_strategy"` // distribute, isolate or numactl (llama.cpp), numa alone is distribute
+	CPUs                 string  `yaml:"cpus"`          // CPUs the backend process is pinned to, as a cpuset list (e.g. 0-15,32-47)
+	NUMANode             string  `yaml:"numa_node"`     // NUMA node the backend runs on with its memory, or auto to spread the backends on the nodes
 	LoraAdapter          string  `yaml:"lora_adapter"`
 	LoraBase             string  `yaml:"lora_base"`
 	LoraScale            float32 `yaml:"lora_scale"`
@@ -146,8 +417,12 @@ type LLMConfig struct {
 	TensorParallelSize   int     `yaml:"tensor_parallel_size"`   // vLLM
 	MMProj               string  `yaml:"mmproj"`
 
-	FlashAttention bool `yaml:"flash_attention"`
-	NoKVOffloading bool `yaml:"no_kv_offloading"`
+	FlashAttention bool   `yaml:"flash_attention"`
+	NoKVOffloading bool   `yaml:"no_kv_offloading"`
+	CacheTypeK     string `yaml:"cache_type_k"`  // KV cache quantization, e.g. q8_0 or q4_0 (llama.cpp)
+	CacheTypeV     string `yaml:"cache_type_v"`  // quantizing the V cache requires flash_attention (llama.cpp)
+	ContextShift   bool   `yaml:"context_shift"` // keep generating when the context is full, dropping the oldest tokens (llama.cpp)
+	WarmRestart    bool   `yaml:"warm_restart"`  // save the sessions when the backend is restarted, and restore them once it's reloaded (llama.cpp)
 
 	RopeScaling string `yaml:"rope_scaling"`
 	ModelType   string `yaml:"type"`
@@ -156,6 +431,22 @@ type LLMConfig struct {
 	YarnAttnFactor float32 `yaml:"yarn_attn_factor"`
 	YarnBetaFast   float32 `yaml:"yarn_beta_fast"`
 	YarnBetaSlow   float32 `yaml:"yarn_beta_slow"`
+
+	// FirstTokenTimeout is the maximum time (e.g. "30s") to wait for the first token:
+	// if exceeded, the backend is restarted and the request retried once
+	FirstTokenTimeout string `yaml:"first_token_timeout"`
+
+	// OOMRetry reloads the model with reduced settings when its backend runs out of memory
+	OOMRetry OOMRetry `yaml:"oom_retry"`
+
+	// LatencySLO is the time (e.g. "30s") the chat and completion requests should complete within: the requests
+	// which can't meet it at the current load are queued, then refused. --latency-slo by default
+	LatencySLO string `yaml:"latency_slo"`
+
+	// Validators check the answers, which are retried ValidationRetries times (1 by default) with the errors
+	// when they are not valid
+	Validators        []schema.ResponseValidator `yaml:"validators"`
+	ValidationRetries *int                       `yaml:"validation_retries"`
 }
 
 // AutoGPTQ is a struct that holds the configuration specific to the AutoGPTQ backend
@@ -183,6 +474,9 @@ type TemplateConfig struct {
 	// Functions is the template used when tools are present in the client requests
 	Functions string `yaml:"function"`
 
+	// Response is the template used to post-process the responses of the model
+	Response string `yaml:"response"`
+
 	// UseTokenizerTemplate is a flag that indicates if the tokenizer template should be used.
 	// Note: this is mostly consumed for backends such as vllm and transformers
 	// that can use the tokenizers specified in the JSON config files of the models
@@ -191,6 +485,9 @@ type TemplateConfig struct {
 	// JoinChatMessagesByCharacter is a string that will be used to join chat messages together.
 	// It defaults to \n
 	JoinChatMessagesByCharacter *string `yaml:"join_chat_messages_by_character"`
+
+	// Tests are the prompts expected from the templates, checked by `local-ai util test-templates`
+	Tests []TemplateTest `yaml:"tests"`
 }
 
 func (c *BackendConfig) SetFunctionCallString(s string) {
@@ -406,6 +703,67 @@ func (c *BackendConfig) Validate() bool {
 	return true
 }
 
+// ValidateGPUOptions checks the syntax of tensor_split, main_gpu, numa_strategy and of the CPU pinning
+func (c *BackendConfig) ValidateGPUOptions() error {
+	if c.TensorSplit != "" && c.TensorSplit != TensorSplitAuto {
+		if _, err := ParseTensorSplit(c.TensorSplit); err != nil {
+			return err
+		}
+	}
+	// other backends than llama.cpp take device names (e.g. cuda.0)
+	if gpu, err := strconv.Atoi(c.MainGPU); err == nil && gpu < 0 {
+		return fmt.Errorf("main_gpu cannot be negative")
+	}
+	if c.NUMAStrategy != "" && !slices.Contains(numaStrategies, c.NUMAStrategy) {
+		return fmt.Errorf("unknown numa_strategy %q, the strategies are %s", c.NUMAStrategy, strings.Join(numaStrategies, ", "))
+	}
+	if c.CPUs != "" {
+		if _, err := xsysinfo.ParseCPUList(c.CPUs); err != nil {
+			return fmt.Errorf("invalid cpus: %w", err)
+		}
+	}
+	if c.NUMANode != "" && c.NUMANode != NUMANodeAuto {
+		if node, err := strconv.Atoi(c.NUMANode); err != nil || node < 0 {
+			return fmt.Errorf("invalid numa_node %q, it's a node number or auto", c.NUMANode)
+		}
+	}
+	return nil
+}
+
+// CheckGPUCount checks that tensor_split and main_gpu don't refer to more GPUs than the ones detected
+func (c *BackendConfig) CheckGPUCount(gpus int) error {
+	if c.TensorSplit != "" && c.TensorSplit != TensorSplitAuto {
+		if ratios, err := ParseTensorSplit(c.TensorSplit); err == nil && len(ratios) > gpus {
+			return fmt.Errorf("tensor_split has %d ratios, but %d GPUs are detected", len(ratios), gpus)
+		}
+	}
+	if gpu, err := strconv.Atoi(c.MainGPU); err == nil && gpu >= gpus {
+		return fmt.Errorf("main_gpu is %d, but %d GPUs are detected", gpu, gpus)
+	}
+	return nil
+}
+
+// ParseTensorSplit returns the ratios of a tensor_split, separated by commas or slashes like llama.cpp does
+func ParseTensorSplit(split string) ([]float64, error) {
+	ratios := []float64{}
+	total := 0.0
+	for _, r := range strings.FieldsFunc(split, func(r rune) bool { return r == ',' || r == '/' }) {
+		ratio, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+		if err != nil || ratio < 0 {
+			return nil, fmt.Errorf("invalid tensor_split %q: the ratios must be positive numbers, or auto", split)
+		}
+		ratios = append(ratios, ratio)
+		total += ratio
+	}
+	switch {
+	case len(ratios) > maxTensorSplitDevices:
+		return nil, fmt.Errorf("invalid tensor_split %q: at most %d GPUs are supported", split, maxTensorSplitDevices)
+	case total == 0:
+		return nil, fmt.Errorf("invalid tensor_split %q: at least a ratio must be above 0", split)
+	}
+	return ratios, nil
+}
+
 func (c *BackendConfig) HasTemplate() bool {
 	return c.TemplateConfig.Completion != "" || c.TemplateConfig.Edit != "" || c.TemplateConfig.Chat != "" || c.TemplateConfig.ChatMessage != ""
 }
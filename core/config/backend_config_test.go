@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -61,4 +62,165 @@ parameters:
 			Expect(config.Validate()).To(BeTrue())
 		})
 	})
+	Context("Deprecation", func() {
+		It("reads the deprecation and builds the warning", func() {
+			tmp, err := os.CreateTemp("", "config.yaml")
+			Expect(err).To(BeNil())
+			defer os.Remove(tmp.Name())
+			_, err = tmp.WriteString(
+				`name: old-model
+parameters:
+  model: "foo-bar"
+deprecation:
+  deprecated: true
+  replacement: new-model
+  sunset: 2025-06-30`)
+			Expect(err).ToNot(HaveOccurred())
+			config, err := readBackendConfigFromFile(tmp.Name())
+			Expect(err).To(BeNil())
+			Expect(config.Deprecation.Deprecated).To(BeTrue())
+
+			sunset, ok := config.Deprecation.SunsetTime()
+			Expect(ok).To(BeTrue())
+			Expect(sunset.Format("2006-01-02")).To(Equal("2025-06-30"))
+			Expect(config.Deprecation.Warning(config.Name)).To(Equal("the model old-model is deprecated and will be removed on 2025-06-30, use new-model instead"))
+		})
+		It("omits the invalid sunset dates", func() {
+			d := Deprecation{Deprecated: true, Sunset: "next year"}
+			_, ok := d.SunsetTime()
+			Expect(ok).To(BeFalse())
+			Expect(d.Warning("old-model")).To(Equal("the model old-model is deprecated"))
+		})
+	})
+	Context("Maintenance", func() {
+		It("reads the maintenance mode and builds the error", func() {
+			tmp, err := os.CreateTemp("", "config.yaml")
+			Expect(err).To(BeNil())
+			defer os.Remove(tmp.Name())
+			_, err = tmp.WriteString(
+				`name: busy-model
+parameters:
+  model: "foo-bar"
+maintenance_mode:
+  enabled: true
+  message: re-quantization
+  until: 2025-06-30T18:00:00+02:00`)
+			Expect(err).ToNot(HaveOccurred())
+			config, err := readBackendConfigFromFile(tmp.Name())
+			Expect(err).To(BeNil())
+			Expect(config.Maintenance.Enabled).To(BeTrue())
+
+			until, ok := config.Maintenance.UntilTime()
+			Expect(ok).To(BeTrue())
+			Expect(until.UTC().Format(time.RFC3339)).To(Equal("2025-06-30T16:00:00Z"))
+			Expect(config.Maintenance.Error(config.Name)).To(Equal("the model busy-model is under maintenance until 2025-06-30T16:00:00Z: re-quantization"))
+		})
+		It("accepts dates and omits the invalid ones", func() {
+			m := Maintenance{Enabled: true, Until: "2025-06-30"}
+			Expect(m.Error("busy-model")).To(Equal("the model busy-model is under maintenance until 2025-06-30T00:00:00Z"))
+
+			m.Until = "tomorrow"
+			_, ok := m.UntilTime()
+			Expect(ok).To(BeFalse())
+			Expect(m.Error("busy-model")).To(Equal("the model busy-model is under maintenance"))
+		})
+	})
+	Context("GPU options", func() {
+		It("parses the tensor split like llama.cpp", func() {
+			Expect(ParseTensorSplit("3,1")).To(Equal([]float64{3, 1}))
+			Expect(ParseTensorSplit("0.5/0.25/0.25")).To(Equal([]float64{0.5, 0.25, 0.25}))
+		})
+		DescribeTable("validates tensor_split, main_gpu and numa_strategy", func(c LLMConfig, message string) {
+			err := (&BackendConfig{LLMConfig: c}).ValidateGPUOptions()
+			if message == "" {
+				Expect(err).ToNot(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			}
+		},
+			Entry("auto split", LLMConfig{TensorSplit: TensorSplitAuto, MainGPU: "1"}, ""),
+			Entry("device name", LLMConfig{MainGPU: "cuda.0"}, ""),
+			Entry("numa strategy", LLMConfig{NUMA: true, NUMAStrategy: "isolate"}, ""),
+			Entry("invalid ratio", LLMConfig{TensorSplit: "3,one"}, "the ratios must be positive numbers"),
+			Entry("negative ratio", LLMConfig{TensorSplit: "-1,1"}, "the ratios must be positive numbers"),
+			Entry("no ratio", LLMConfig{TensorSplit: "0,0"}, "at least a ratio must be above 0"),
+			Entry("negative main gpu", LLMConfig{MainGPU: "-1"}, "main_gpu cannot be negative"),
+			Entry("unknown numa strategy", LLMConfig{NUMAStrategy: "interleave"}, `unknown numa_strategy "interleave"`),
+			Entry("cpu pinning", LLMConfig{CPUs: "0-15,32-47", NUMANode: "1"}, ""),
+			Entry("auto numa node", LLMConfig{NUMANode: NUMANodeAuto}, ""),
+			Entry("invalid cpus", LLMConfig{CPUs: "15-0"}, "invalid cpus"),
+			Entry("invalid numa node", LLMConfig{NUMANode: "first"}, `invalid numa_node "first"`),
+		)
+		It("checks the options against the detected GPUs", func() {
+			c := &BackendConfig{LLMConfig: LLMConfig{TensorSplit: "2,1,1", MainGPU: "0"}}
+			Expect(c.CheckGPUCount(3)).To(Succeed())
+			Expect(c.CheckGPUCount(2)).To(MatchError("tensor_split has 3 ratios, but 2 GPUs are detected"))
+
+			c = &BackendConfig{LLMConfig: LLMConfig{TensorSplit: TensorSplitAuto, MainGPU: "2"}}
+			Expect(c.CheckGPUCount(2)).To(MatchError("main_gpu is 2, but 2 GPUs are detected"))
+		})
+	})
+	Context("Capabilities", func() {
+		embeddings := true
+		DescribeTable("derives the capabilities from the backend and the config", func(c BackendConfig, capabilities []string) {
+			Expect(c.ModelCapabilities()).To(Equal(capabilities))
+		},
+			Entry("llm", BackendConfig{Backend: "llama-cpp"}, []string{CapabilityChat, CapabilityCompletion}),
+			Entry("llm with mmproj and tools", BackendConfig{
+				TemplateConfig: TemplateConfig{UseTokenizerTemplate: true},
+				LLMConfig:      LLMConfig{MMProj: "mmproj.gguf"},
+			}, []string{CapabilityChat, CapabilityCompletion, CapabilityVision, CapabilityTools}),
+			Entry("embeddings model", BackendConfig{Backend: "llama-cpp", Embeddings: &embeddings}, []string{CapabilityEmbeddings}),
+			Entry("tts", BackendConfig{Backend: "piper"}, []string{CapabilityTTS}),
+			Entry("transcription", BackendConfig{Backend: "whisper"}, []string{CapabilityTranscription}),
+			Entry("rerank", BackendConfig{Backend: "rerankers"}, []string{CapabilityRerank}),
+			Entry("image", BackendConfig{Backend: "diffusers"}, []string{CapabilityImage}),
+			Entry("translation model", BackendConfig{Backend: "transformers", LLMConfig: LLMConfig{ModelType: Seq2SeqModelType}}, []string{CapabilityTranslation}),
+			Entry("llm translating", BackendConfig{Backend: "llama-cpp", Translation: Translation{Enabled: true}}, []string{CapabilityChat, CapabilityCompletion, CapabilityTranslation}),
+			Entry("classifier model", BackendConfig{Backend: "transformers", LLMConfig: LLMConfig{ModelType: SequenceClassificationModelType}}, []string{CapabilityModeration}),
+			Entry("llm judging the moderations", BackendConfig{Backend: "llama-cpp", Moderation: Moderation{Enabled: true}}, []string{CapabilityChat, CapabilityCompletion, CapabilityModeration}),
+			Entry("overridden", BackendConfig{Backend: "llama-cpp", Capabilities: []string{CapabilityChat}}, []string{CapabilityChat}),
+		)
+	})
+	Context("OOM retry", func() {
+		It("halves the settings down to the floors", func() {
+			layers, contextSize := 99999999, 4096
+			c := &BackendConfig{LLMConfig: LLMConfig{NGPULayers: &layers, ContextSize: &contextSize}}
+			r := OOMRetry{Enabled: true, MinGPULayers: 10, MinContextSize: 1024}
+
+			Expect(r.Reduce(c)).To(Equal([]string{"gpu_layers reduced to 32", "context_size reduced to 2048", "batch reduced to 256"}))
+			Expect(r.Reduce(c)).To(Equal([]string{"gpu_layers reduced to 16", "context_size reduced to 1024", "batch reduced to 128"}))
+			Expect(r.Reduce(c)).To(Equal([]string{"gpu_layers reduced to 10", "batch reduced to 64"}))
+			Expect(r.Reduce(c)).To(Equal([]string{"batch reduced to 32"}))
+			Expect(r.Reduce(c)).To(BeEmpty())
+			Expect(*c.NGPULayers).To(Equal(10))
+			Expect(*c.ContextSize).To(Equal(1024))
+			Expect(c.Batch).To(Equal(32))
+		})
+		It("uses the default floors and leaves the unset settings alone", func() {
+			c := &BackendConfig{}
+			c.Batch = 40
+			r := OOMRetry{Enabled: true}
+
+			Expect(r.Reduce(c)).To(Equal([]string{"batch reduced to 32"}))
+			Expect(r.Reduce(c)).To(BeEmpty())
+			Expect(c.NGPULayers).To(BeNil())
+			Expect(c.ContextSize).To(BeNil())
+		})
+		It("doesn't raise the settings below the floors", func() {
+			layers, contextSize := 4, 256
+			c := &BackendConfig{LLMConfig: LLMConfig{NGPULayers: &layers, ContextSize: &contextSize}}
+			c.Batch = 16
+
+			Expect(OOMRetry{Enabled: true, MinGPULayers: 8}.Reduce(c)).To(BeEmpty())
+			Expect(*c.NGPULayers).To(Equal(4))
+			Expect(*c.ContextSize).To(Equal(256))
+			Expect(c.Batch).To(Equal(16))
+		})
+		It("retries only when enabled", func() {
+			Expect(OOMRetry{}.MaxAttempts()).To(Equal(0))
+			Expect(OOMRetry{Enabled: true}.MaxAttempts()).To(Equal(3))
+			Expect(OOMRetry{Enabled: true, Attempts: 5}.MaxAttempts()).To(Equal(5))
+		})
+	})
 })
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// The capability tags of the models, listed in /v1/models
+const (
+	CapabilityChat          = "chat"
+	CapabilityCompletion    = "completion"
+	CapabilityEmbeddings    = "embeddings"
+	CapabilityVision        = "vision"
+	CapabilityTools         = "tools"
+	CapabilityTTS           = "tts"
+	CapabilityTranscription = "transcription"
+	CapabilityRerank        = "rerank"
+	CapabilityImage         = "image"
+	CapabilityTranslation   = "translation"
+	CapabilityModeration    = "moderation"
+)
+
+var (
+	ttsBackends           = []string{"piper", "bark", "coqui", "vall-e-x", "parler-tts", "openvoice", "transformers-musicgen"}
+	transcriptionBackends = []string{"whisper"}
+	rerankBackends        = []string{"rerankers"}
+	imageBackends         = []string{"stablediffusion", "tinydream", "diffusers"}
+	embeddingsBackends    = []string{"bert-embeddings", "sentencetransformers", "sentence-transformers"}
+)
+
+// ModelCapabilities returns the capability tags of the model: the ones of its config when it sets them, else the
+// ones derived from its backend, its templates and its options
+func (c *BackendConfig) ModelCapabilities() []string {
+	if len(c.Capabilities) > 0 {
+		return c.Capabilities
+	}
+
+	if c.IsTranslationModel() {
+		return []string{CapabilityTranslation}
+	}
+	if c.IsClassifierModel() {
+		return []string{CapabilityModeration}
+	}
+
+	backend := strings.ToLower(c.Backend)
+	switch {
+	case slices.Contains(ttsBackends, backend):
+		return []string{CapabilityTTS}
+	case slices.Contains(transcriptionBackends, backend):
+		return []string{CapabilityTranscription}
+	case slices.Contains(rerankBackends, backend):
+		return []string{CapabilityRerank}
+	case slices.Contains(imageBackends, backend):
+		return []string{CapabilityImage}
+	case slices.Contains(embeddingsBackends, backend):
+		return []string{CapabilityEmbeddings}
+	}
+
+	// the other backends generate text, an embeddings model without templates only computes embeddings
+	templated := c.TemplateConfig.Chat != "" || c.TemplateConfig.ChatMessage != "" || c.TemplateConfig.Completion != "" ||
+		c.TemplateConfig.UseTokenizerTemplate
+	if c.Embeddings != nil && *c.Embeddings && !templated {
+		return []string{CapabilityEmbeddings}
+	}
+
+	capabilities := []string{CapabilityChat, CapabilityCompletion}
+	if c.Embeddings != nil && *c.Embeddings {
+		capabilities = append(capabilities, CapabilityEmbeddings)
+	}
+	if c.MMProj != "" {
+		capabilities = append(capabilities, CapabilityVision)
+	}
+	if c.TemplateConfig.Functions != "" || c.TemplateConfig.UseTokenizerTemplate ||
+		len(c.FunctionsConfig.ResponseRegex) > 0 || len(c.FunctionsConfig.JSONRegexMatch) > 0 {
+		capabilities = append(capabilities, CapabilityTools)
+	}
+	if c.Translation.Enabled {
+		capabilities = append(capabilities, CapabilityTranslation)
+	}
+	if c.Moderation.Enabled {
+		capabilities = append(capabilities, CapabilityModeration)
+	}
+	return capabilities
+}
+
+// HasCapability returns whether the model has the capability tag
+func (c *BackendConfig) HasCapability(capability string) bool {
+	return slices.Contains(c.ModelCapabilities(), capability)
+}
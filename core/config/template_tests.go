@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// TemplateTest is a test case of the templates of a model: the prompt rendered from its messages must contain the
+// substrings of Contains and match the regular expressions of Matches
+type TemplateTest struct {
+	Name     string                `yaml:"name"`
+	Messages []TemplateTestMessage `yaml:"messages"`
+	Contains []string              `yaml:"contains"`
+	Matches  []string              `yaml:"matches"`
+}
+
+type TemplateTestMessage struct {
+	Role    string `yaml:"role"`
+	Content string `yaml:"content"`
+}
+
+// Check returns the failures of the test for the rendered prompt, with the diff between the expected substring and
+// the prompt. It's empty when the test passes
+func (t TemplateTest) Check(prompt string) []string {
+	failures := []string{}
+	for _, expected := range t.Contains {
+		if strings.Contains(prompt, expected) {
+			continue
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(expected),
+			B:        difflib.SplitLines(prompt),
+			FromFile: "expected",
+			ToFile:   "rendered",
+			Context:  3,
+		})
+		if err != nil {
+			diff = err.Error()
+		}
+		failures = append(failures, fmt.Sprintf("the prompt doesn't contain %q:\n%s", expected, diff))
+	}
+	for _, expression := range t.Matches {
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("invalid regular expression %q: %v", expression, err))
+			continue
+		}
+		if !re.MatchString(prompt) {
+			failures = append(failures, fmt.Sprintf("the prompt doesn't match %q:\n%s", expression, prompt))
+		}
+	}
+	return failures
+}
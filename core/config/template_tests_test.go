@@ -0,0 +1,33 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Template tests", func() {
+	prompt := "<|user|>\nHello\n<|assistant|>\n"
+
+	It("passes when the prompt contains the substrings and matches the expressions", func() {
+		test := TemplateTest{Contains: []string{"<|user|>\nHello"}, Matches: []string{`<\|assistant\|>\n$`}}
+		Expect(test.Check(prompt)).To(BeEmpty())
+	})
+
+	It("fails with the diff of a missing substring", func() {
+		test := TemplateTest{Contains: []string{"<|user|>\nHello\n<|end|>"}}
+		failures := test.Check(prompt)
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0]).To(ContainSubstring("--- expected"))
+		Expect(failures[0]).To(ContainSubstring("+++ rendered"))
+		Expect(failures[0]).To(ContainSubstring("-<|end|>"))
+		Expect(failures[0]).To(ContainSubstring("+<|assistant|>"))
+	})
+
+	It("fails on unmatched and invalid expressions", func() {
+		test := TemplateTest{Matches: []string{`^<\|system\|>`, `(`}}
+		failures := test.Check(prompt)
+		Expect(failures).To(HaveLen(2))
+		Expect(failures[0]).To(ContainSubstring("doesn't match"))
+		Expect(failures[1]).To(ContainSubstring("invalid regular expression"))
+	})
+})
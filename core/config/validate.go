@@ -0,0 +1,267 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// yamlErrorLine matches the line number in the errors of the YAML parser
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// ConfigIssue is a problem found in a model configuration file
+type ConfigIssue struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i ConfigIssue) String() string {
+	location := i.File
+	if i.Line > 0 {
+		location += ":" + strconv.Itoa(i.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, i.Severity, i.Message)
+}
+
+// ConfigValidator checks the model configuration files without loading the models: YAML and type errors, unknown
+// fields, files missing from the models path, model names defined more than once, and GPU options which don't match
+// the GPUs of the machine
+type ConfigValidator struct {
+	modelPath string
+	names     map[string]string // location of the first definition of each model
+	gpus      *int              // number of NVIDIA GPUs, detected once needed
+	Issues    []ConfigIssue
+}
+
+func NewConfigValidator(modelPath string) *ConfigValidator {
+	return &ConfigValidator{modelPath: modelPath, names: map[string]string{}}
+}
+
+// Errors returns the number of issues which prevent a model from being loaded
+func (v *ConfigValidator) Errors() int {
+	n := 0
+	for _, i := range v.Issues {
+		if i.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// ValidateFile checks a file with the configuration of a model
+func (v *ConfigValidator) ValidateFile(file string) {
+	v.validate(file, false)
+}
+
+// ValidateMultipleFile checks a file with a list of model configurations, e.g. the --models-config-file
+func (v *ConfigValidator) ValidateMultipleFile(file string) {
+	v.validate(file, true)
+}
+
+func (v *ConfigValidator) validate(file string, multiple bool) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		v.add(file, 0, "", SeverityError, err.Error())
+		return
+	}
+
+	root := yaml.Node{}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		line, msg := splitYAMLError(err.Error())
+		v.add(file, line, "", SeverityError, msg)
+		return
+	}
+	if len(root.Content) == 0 {
+		v.add(file, 0, "", SeverityError, "the file is empty")
+		return
+	}
+
+	docs := []*yaml.Node{root.Content[0]}
+	expected := yaml.MappingNode
+	if multiple {
+		docs = root.Content[0].Content
+		expected = yaml.SequenceNode
+	}
+	if root.Content[0].Kind != expected {
+		what := "a model configuration"
+		if multiple {
+			what = "a list of model configurations"
+		}
+		v.add(file, root.Content[0].Line, "", SeverityError, "expected "+what)
+		return
+	}
+
+	// the decoder reports all the unknown fields and type errors, and decodes the rest
+	configs := []*BackendConfig{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if multiple {
+		err = dec.Decode(&configs)
+	} else {
+		c := &BackendConfig{}
+		err = dec.Decode(c)
+		configs = append(configs, c)
+	}
+	var typeErr *yaml.TypeError
+	switch {
+	case errors.As(err, &typeErr):
+		for _, e := range typeErr.Errors {
+			line, msg := splitYAMLError(e)
+			if strings.Contains(msg, "not found in type") {
+				v.add(file, line, "", SeverityWarning, "unknown "+strings.SplitN(msg, " not found", 2)[0]+", it's ignored")
+				continue
+			}
+			v.add(file, line, "", SeverityError, msg)
+		}
+	case err != nil:
+		line, msg := splitYAMLError(err.Error())
+		v.add(file, line, "", SeverityError, msg)
+		return
+	}
+
+	for i, c := range configs {
+		if c != nil && i < len(docs) {
+			v.check(file, docs[i], c)
+		}
+	}
+}
+
+// check validates a decoded configuration, node being its YAML mapping
+func (v *ConfigValidator) check(file string, node *yaml.Node, c *BackendConfig) {
+	if c.Name == "" {
+		v.add(file, node.Line, "", SeverityError, "the model has no name")
+	} else {
+		location := fmt.Sprintf("%s:%d", file, nodeLine(node, "name"))
+		if first, exists := v.names[c.Name]; exists {
+			v.add(file, nodeLine(node, "name"), c.Name, SeverityError, fmt.Sprintf("the model %s is already defined in %s", c.Name, first))
+		} else {
+			v.names[c.Name] = location
+		}
+	}
+
+	if !c.Validate() {
+		v.add(file, node.Line, c.Name, SeverityError, "invalid configuration: the backend name has special characters, or a file path is absolute or contains '..'")
+	}
+
+	if err := c.ValidateGPUOptions(); err != nil {
+		v.add(file, node.Line, c.Name, SeverityError, err.Error())
+	} else if (c.TensorSplit != "" || c.MainGPU != "") && v.detectedGPUs() > 0 {
+		if err := c.CheckGPUCount(v.detectedGPUs()); err != nil {
+			v.add(file, nodeLine(node, "tensor_split"), c.Name, SeverityWarning, err.Error())
+		}
+	}
+
+	v.checkFile(file, nodeLine(node, "parameters", "model"), c.Name, "model", c.Model)
+	v.checkFile(file, nodeLine(node, "mmproj"), c.Name, "mmproj", c.MMProj)
+	downloads := child(node, "download_files")
+	for i, f := range c.DownloadFiles {
+		line := node.Line
+		if downloads != nil && downloads.Kind == yaml.SequenceNode && i < len(downloads.Content) {
+			line = nodeLine(downloads.Content[i], "uri")
+		}
+		if f.Filename == "" {
+			v.add(file, line, c.Name, SeverityError, fmt.Sprintf("the download file %d has no filename", i+1))
+		}
+		if f.URI == "" {
+			v.add(file, line, c.Name, SeverityError, fmt.Sprintf("the download file %d has no uri", i+1))
+			continue
+		}
+		v.checkURI(file, line, c.Name, string(f.URI))
+	}
+}
+
+// detectedGPUs returns the number of NVIDIA GPUs of the machine, 0 when they can't be listed
+func (v *ConfigValidator) detectedGPUs() int {
+	if v.gpus == nil {
+		gpus, _ := xsysinfo.GPUsMemory()
+		n := len(gpus)
+		v.gpus = &n
+	}
+	return *v.gpus
+}
+
+// checkFile checks that a file referenced by the configuration exists, remote ones are downloaded when the model is loaded
+func (v *ConfigValidator) checkFile(file string, line int, model, field, value string) {
+	if value == "" {
+		return
+	}
+	if strings.Contains(value, "://") || downloader.URI(value).LooksLikeURL() {
+		v.checkURI(file, line, model, value)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(v.modelPath, value)); err != nil {
+		// some backends (e.g. transformers, vllm) take a repository name instead of a file
+		v.add(file, line, model, SeverityWarning, fmt.Sprintf("the %s %s is not in the models path", field, value))
+	}
+}
+
+// checkURI checks that the local files referenced with file:// exist
+func (v *ConfigValidator) checkURI(file string, line int, model, uri string) {
+	if !strings.HasPrefix(uri, downloader.LocalPrefix) {
+		return
+	}
+	path := strings.TrimPrefix(uri, downloader.LocalPrefix)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(v.modelPath, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		v.add(file, line, model, SeverityError, fmt.Sprintf("the file %s does not exist", path))
+	}
+}
+
+func (v *ConfigValidator) add(file string, line int, model, severity, msg string) {
+	v.Issues = append(v.Issues, ConfigIssue{File: file, Line: line, Model: model, Severity: severity, Message: msg})
+}
+
+// splitYAMLError returns the line and the message of an error of the YAML parser
+func splitYAMLError(err string) (int, string) {
+	m := yamlErrorLine.FindStringSubmatch(err)
+	if m == nil {
+		return 0, strings.TrimPrefix(err, "yaml: ")
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line, m[2]
+}
+
+// child returns the value of the key in the YAML mapping, nil if missing
+func child(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nodeLine returns the line of the value at the path of keys in the YAML mapping, or the line of the closest parent
+func nodeLine(n *yaml.Node, keys ...string) int {
+	line := n.Line
+	for _, k := range keys {
+		n = child(n, k)
+		if n == nil {
+			break
+		}
+		line = n.Line
+	}
+	return line
+}
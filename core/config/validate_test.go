@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config validation", func() {
+	var (
+		modelPath string
+		validator *ConfigValidator
+	)
+
+	write := func(name, content string) string {
+		file := filepath.Join(modelPath, name)
+		Expect(os.WriteFile(file, []byte(content), 0600)).To(Succeed())
+		return file
+	}
+
+	BeforeEach(func() {
+		var err error
+		modelPath, err = os.MkdirTemp("", "validate")
+		Expect(err).ToNot(HaveOccurred())
+		validator = NewConfigValidator(modelPath)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(modelPath)
+	})
+
+	It("accepts a valid configuration", func() {
+		write("model.bin", "")
+		validator.ValidateFile(write("model.yaml", `name: foo
+backend: llama-cpp
+parameters:
+  model: model.bin
+`))
+		Expect(validator.Issues).To(BeEmpty())
+	})
+
+	It("reports the YAML errors with their line", func() {
+		file := write("model.yaml", `name: foo
+parameters:
+  model: [
+`)
+		validator.ValidateFile(file)
+		Expect(validator.Issues).To(HaveLen(1))
+		Expect(validator.Issues[0].Severity).To(Equal(SeverityError))
+		Expect(validator.Issues[0].Line).To(BeNumerically(">", 0))
+		Expect(validator.Errors()).To(Equal(1))
+	})
+
+	It("reports the unknown fields and the type errors", func() {
+		validator.ValidateFile(write("model.yaml", `name: foo
+backend: llama-cpp
+context_sise: 4096
+threads: many
+`))
+		Expect(validator.Issues).To(ContainElement(ConfigIssue{
+			File: filepath.Join(modelPath, "model.yaml"), Line: 3, Severity: SeverityWarning,
+			Message: "unknown field context_sise, it's ignored",
+		}))
+		Expect(validator.Errors()).To(Equal(1))
+		Expect(validator.Issues[len(validator.Issues)-1].Line).To(Equal(4))
+	})
+
+	It("reports the missing files", func() {
+		validator.ValidateFile(write("model.yaml", `name: foo
+parameters:
+  model: missing.gguf
+download_files:
+- filename: other.gguf
+  uri: file://other.gguf
+`))
+		Expect(validator.Issues).To(HaveLen(2))
+		Expect(validator.Issues[0].Severity).To(Equal(SeverityWarning))
+		Expect(validator.Issues[0].Line).To(Equal(3))
+		Expect(validator.Issues[1].Severity).To(Equal(SeverityError))
+		Expect(validator.Issues[1].Line).To(Equal(6))
+	})
+
+	It("skips the remote files", func() {
+		validator.ValidateFile(write("model.yaml", `name: foo
+parameters:
+  model: huggingface://TheBloke/foo/foo.gguf
+`))
+		Expect(validator.Issues).To(BeEmpty())
+	})
+
+	It("reports the duplicate model names", func() {
+		first := write("a.yaml", "name: foo\n")
+		validator.ValidateFile(first)
+		validator.ValidateMultipleFile(write("models.yaml", `- name: bar
+- name: foo
+`))
+		Expect(validator.Issues).To(HaveLen(1))
+		Expect(validator.Issues[0].Line).To(Equal(2))
+		Expect(validator.Issues[0].Message).To(ContainSubstring(first + ":1"))
+	})
+
+	It("reports the configurations without a name", func() {
+		validator.ValidateFile(write("model.yaml", "backend: llama-cpp\n"))
+		Expect(validator.Errors()).To(Equal(1))
+		Expect(validator.Issues[0].Message).To(Equal("the model has no name"))
+	})
+})
@@ -0,0 +1,292 @@
+package gallery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mholt/archiver/v3"
+	lconfig "github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/utils"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	exportManifestFile = "localai-export.yaml"
+	// exportWeightsDir is the directory of the archive holding the weights, when they are included
+	exportWeightsDir = "weights"
+)
+
+// ExportManifest describes an exported model. Weights are referenced by URI and checksum and
+// downloaded again on import, unless they are part of the archive
+type ExportManifest struct {
+	Name   string   `yaml:"name"`
+	Config string   `yaml:"config"`
+	Assets []string `yaml:"assets,omitempty"`
+	Files  []File   `yaml:"files,omitempty"`
+	// Weights are the files of the model included in the archive, relative to the models path
+	Weights []string `yaml:"weights,omitempty"`
+}
+
+// ExportModel writes an archive to dst with the YAML config of the model and the template and
+// grammar files it uses, along with the URLs and checksums of its weights. With withWeights, the
+// weight files found in basePath are included as well, to import the model without network access.
+// The format of the archive is given by the extension of dst (e.g. .tar.gz, .tar)
+func ExportModel(basePath, name, dst string, withWeights bool) error {
+	configFile := name + ".yaml"
+	if err := utils.VerifyPath(configFile, basePath); err != nil {
+		return err
+	}
+
+	dat, err := os.ReadFile(filepath.Join(basePath, configFile))
+	if err != nil {
+		return fmt.Errorf("failed to read config of model %q: %w", name, err)
+	}
+
+	backendConfig := lconfig.BackendConfig{}
+	if err := yaml.Unmarshal(dat, &backendConfig); err != nil {
+		return fmt.Errorf("failed to parse config of model %q: %w", name, err)
+	}
+
+	manifest := ExportManifest{
+		Name:   name,
+		Config: configFile,
+	}
+
+	// Templates are referenced without extension, grammars by file name
+	candidates := []string{}
+	for _, t := range []string{
+		backendConfig.TemplateConfig.Chat,
+		backendConfig.TemplateConfig.ChatMessage,
+		backendConfig.TemplateConfig.Completion,
+		backendConfig.TemplateConfig.Edit,
+		backendConfig.TemplateConfig.Functions,
+	} {
+		if t != "" {
+			candidates = append(candidates, t+".tmpl")
+		}
+	}
+	if backendConfig.Grammar != "" {
+		candidates = append(candidates, backendConfig.Grammar)
+	}
+
+	for _, c := range candidates {
+		if utils.VerifyPath(c, basePath) != nil {
+			continue
+		}
+		if fi, err := os.Stat(filepath.Join(basePath, c)); err == nil && !fi.IsDir() {
+			manifest.Assets = append(manifest.Assets, c)
+		}
+	}
+
+	// Weights installed from a gallery are tracked in the gallery file
+	if galleryConfig, err := ReadConfigFile(filepath.Join(basePath, galleryFileName(name))); err == nil {
+		manifest.Files = append(manifest.Files, galleryConfig.Files...)
+	}
+	for _, f := range backendConfig.DownloadFiles {
+		manifest.Files = append(manifest.Files, File{Filename: f.Filename, SHA256: f.SHA256, URI: string(f.URI)})
+	}
+
+	if withWeights {
+		weights := []string{backendConfig.ModelFileName(), backendConfig.MMProjFileName(), backendConfig.DraftModel}
+		for _, f := range manifest.Files {
+			weights = append(weights, f.Filename)
+		}
+		for _, w := range weights {
+			if w == "" || slices.Contains(manifest.Weights, w) || utils.VerifyPath(w, basePath) != nil {
+				continue
+			}
+			// the symlinks of the models imported from the disk are followed
+			if fi, err := os.Stat(filepath.Join(basePath, w)); err == nil && fi.Mode().IsRegular() {
+				manifest.Weights = append(manifest.Weights, w)
+			}
+		}
+		if len(manifest.Weights) == 0 {
+			log.Warn().Str("model", name).Msg("no weight file of the model found, they are downloaded on import")
+		}
+	}
+
+	manifestData, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	format, err := archiver.ByExtension(dst)
+	if err != nil {
+		return err
+	}
+	w, ok := format.(archiver.Writer)
+	if !ok {
+		return fmt.Errorf("%s is not an archive format which can be written", filepath.Base(dst))
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := w.Create(out); err != nil {
+		return err
+	}
+	err = writeExport(w, basePath, manifest, manifestData)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeExport writes the manifest and the files of an export to the archive
+func writeExport(w archiver.Writer, basePath string, manifest ExportManifest, manifestData []byte) error {
+	if err := w.Write(archiver.File{
+		FileInfo:   archiver.FileInfo{FileInfo: manifestFileInfo{size: int64(len(manifestData))}, CustomName: exportManifestFile},
+		ReadCloser: io.NopCloser(bytes.NewReader(manifestData)),
+	}); err != nil {
+		return err
+	}
+	for _, a := range append([]string{manifest.Config}, manifest.Assets...) {
+		if err := writeArchiveFile(w, filepath.Join(basePath, a), filepath.Base(a)); err != nil {
+			return err
+		}
+	}
+	for _, weight := range manifest.Weights {
+		if err := writeArchiveFile(w, filepath.Join(basePath, weight), path.Join(exportWeightsDir, filepath.ToSlash(weight))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeArchiveFile adds a file to the archive with the given name
+func writeArchiveFile(w archiver.Writer, src, name string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return w.Write(archiver.File{FileInfo: archiver.FileInfo{FileInfo: fi, CustomName: name}, ReadCloser: f})
+}
+
+// manifestFileInfo describes the manifest, written to the archive from memory
+type manifestFileInfo struct {
+	size int64
+}
+
+func (m manifestFileInfo) Name() string       { return exportManifestFile }
+func (m manifestFileInfo) Size() int64        { return m.size }
+func (m manifestFileInfo) Mode() os.FileMode  { return 0600 }
+func (m manifestFileInfo) ModTime() time.Time { return time.Now() }
+func (m manifestFileInfo) IsDir() bool        { return false }
+func (m manifestFileInfo) Sys() interface{}   { return nil }
+
+// ImportModel installs a model exported with ExportModel into basePath, moving there the weights
+// included in the archive and downloading the ones which are missing or don't match the checksum.
+// An installed model with the same name is only overwritten with force
+func ImportModel(archive, basePath string, downloadStatus func(string, string, string, float64), enforceScan, force bool) (string, error) {
+	if err := os.MkdirAll(basePath, 0750); err != nil {
+		return "", fmt.Errorf("failed to create base path: %v", err)
+	}
+
+	// extracted in the models path, so that the weights are moved rather than copied
+	tmpDir, err := os.MkdirTemp(basePath, ".localai-import")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := utils.ExtractArchive(archive, tmpDir); err != nil {
+		return "", fmt.Errorf("failed to extract %q: %w", archive, err)
+	}
+
+	dat, err := os.ReadFile(filepath.Join(tmpDir, exportManifestFile))
+	if err != nil {
+		return "", fmt.Errorf("%q is not a LocalAI model export: %w", archive, err)
+	}
+
+	manifest := ExportManifest{}
+	if err := yaml.Unmarshal(dat, &manifest); err != nil {
+		return "", err
+	}
+
+	// the archive is untrusted, its name is written in the models path as well as its files
+	if manifest.Name == "" {
+		return "", fmt.Errorf("%q has no model name", archive)
+	}
+	for _, f := range []string{manifest.Config, galleryFileName(manifest.Name)} {
+		if err := utils.VerifyPath(f, basePath); err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(filepath.Join(basePath, f)); err == nil && !force {
+			return "", fmt.Errorf("model %q already exists", manifest.Name)
+		}
+	}
+
+	for _, f := range append([]string{manifest.Config}, manifest.Assets...) {
+		if err := utils.VerifyPath(f, basePath); err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(filepath.Join(tmpDir, filepath.Base(f)))
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(basePath, f)), 0750); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(basePath, f), content, 0600); err != nil {
+			return "", err
+		}
+	}
+
+	for _, w := range manifest.Weights {
+		if err := utils.VerifyPath(w, basePath); err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(basePath, w)), 0750); err != nil {
+			return "", err
+		}
+		if err := os.Rename(filepath.Join(tmpDir, exportWeightsDir, w), filepath.Join(basePath, w)); err != nil {
+			return "", fmt.Errorf("failed to import the weights %s: %w", w, err)
+		}
+	}
+
+	for i, file := range manifest.Files {
+		if err := utils.VerifyPath(file.Filename, basePath); err != nil {
+			return "", err
+		}
+
+		// the weights of the archive are only verified against the checksum
+		if enforceScan && !slices.Contains(manifest.Weights, file.Filename) {
+			scanResults, err := downloader.HuggingFaceScan(downloader.URI(file.URI))
+			if err != nil && errors.Is(err, downloader.ErrUnsafeFilesFound) {
+				log.Error().Str("model", manifest.Name).Strs("clamAV", scanResults.ClamAVInfectedFiles).Strs("pickles", scanResults.DangerousPickles).Msg("Contains unsafe file(s)!")
+				return "", err
+			}
+		}
+
+		if err := downloader.DownloadFileWithMirrors(file.URIs(), filepath.Join(basePath, file.Filename), file.SHA256, i, len(manifest.Files), downloadStatus); err != nil {
+			return "", err
+		}
+	}
+
+	// Keep track of the files, as it happens when installing from a gallery
+	galleryData, err := yaml.Marshal(Config{Name: manifest.Name, Files: manifest.Files})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(basePath, galleryFileName(manifest.Name)), galleryData, 0600); err != nil {
+		return "", err
+	}
+
+	return manifest.Name, nil
+}
@@ -0,0 +1,149 @@
+package gallery_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mholt/archiver/v3"
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Model export", func() {
+	It("exports and imports a model config with its templates", func() {
+		src, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(src)
+		dst, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dst)
+
+		config := `name: foo
+backend: llama-cpp
+parameters:
+  model: foo.gguf
+template:
+  chat: foo-chat
+`
+		Expect(os.WriteFile(filepath.Join(src, "foo.yaml"), []byte(config), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "foo-chat.tmpl"), []byte("{{.Input}}"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "foo.gguf"), []byte("weights"), 0600)).To(Succeed())
+
+		archive := filepath.Join(src, "foo.tar.gz")
+		Expect(ExportModel(src, "foo", archive, false)).To(Succeed())
+
+		name, err := ImportModel(archive, dst, func(string, string, string, float64) {}, false, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("foo"))
+
+		dat, err := os.ReadFile(filepath.Join(dst, "foo.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal(config))
+
+		dat, err = os.ReadFile(filepath.Join(dst, "foo-chat.tmpl"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal("{{.Input}}"))
+
+		// weights are not part of the archive
+		_, err = os.Stat(filepath.Join(dst, "foo.gguf"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("includes the weights in the archive when asked", func() {
+		src, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(src)
+		dst, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dst)
+
+		config := `name: foo
+backend: llama-cpp
+mmproj: vision/foo-mmproj.gguf
+parameters:
+  model: foo.gguf
+`
+		Expect(os.WriteFile(filepath.Join(src, "foo.yaml"), []byte(config), 0600)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(src, "vision"), 0750)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "vision", "foo-mmproj.gguf"), []byte("projector"), 0600)).To(Succeed())
+		// models imported from the disk are symlinks
+		Expect(os.WriteFile(filepath.Join(src, "foo.bin"), []byte("weights"), 0600)).To(Succeed())
+		Expect(os.Symlink(filepath.Join(src, "foo.bin"), filepath.Join(src, "foo.gguf"))).To(Succeed())
+
+		archive := filepath.Join(src, "foo.tar")
+		Expect(ExportModel(src, "foo", archive, true)).To(Succeed())
+
+		name, err := ImportModel(archive, dst, func(string, string, string, float64) {}, false, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("foo"))
+
+		dat, err := os.ReadFile(filepath.Join(dst, "foo.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal("weights"))
+		fi, err := os.Lstat(filepath.Join(dst, "foo.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fi.Mode().IsRegular()).To(BeTrue())
+
+		dat, err = os.ReadFile(filepath.Join(dst, "vision", "foo-mmproj.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal("projector"))
+
+		// the extraction directory is removed
+		entries, err := os.ReadDir(dst)
+		Expect(err).ToNot(HaveOccurred())
+		for _, e := range entries {
+			Expect(e.Name()).ToNot(HavePrefix(".localai-import"))
+		}
+	})
+
+	It("refuses to overwrite an installed model unless forced", func() {
+		src, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(src)
+		dst, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dst)
+
+		Expect(os.WriteFile(filepath.Join(src, "foo.yaml"), []byte("name: foo\nbackend: llama-cpp\n"), 0600)).To(Succeed())
+		archive := filepath.Join(src, "foo.tar.gz")
+		Expect(ExportModel(src, "foo", archive, false)).To(Succeed())
+
+		Expect(os.WriteFile(filepath.Join(dst, "foo.yaml"), []byte("name: foo\n"), 0600)).To(Succeed())
+		_, err = ImportModel(archive, dst, func(string, string, string, float64) {}, false, false)
+		Expect(err).To(HaveOccurred())
+		dat, err := os.ReadFile(filepath.Join(dst, "foo.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal("name: foo\n"))
+
+		_, err = ImportModel(archive, dst, func(string, string, string, float64) {}, false, true)
+		Expect(err).ToNot(HaveOccurred())
+		dat, err = os.ReadFile(filepath.Join(dst, "foo.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal("name: foo\nbackend: llama-cpp\n"))
+	})
+
+	It("refuses archives with a name outside of the models path", func() {
+		src, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(src)
+		dst, err := os.MkdirTemp("", "test")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dst)
+
+		Expect(os.WriteFile(filepath.Join(src, "localai-export.yaml"), []byte("name: ../../../x\nconfig: x.yaml\n"), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(src, "x.yaml"), []byte("name: x\n"), 0600)).To(Succeed())
+		archive := filepath.Join(dst, "x.tar")
+		Expect(archiver.Archive([]string{filepath.Join(src, "localai-export.yaml"), filepath.Join(src, "x.yaml")}, archive)).To(Succeed())
+
+		_, err = ImportModel(archive, filepath.Join(dst, "models"), func(string, string, string, float64) {}, false, false)
+		Expect(err).To(HaveOccurred())
+		_, err = os.Stat(filepath.Join(dst, "x.yaml"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("refuses archives which are not model exports", func() {
+		_, err := ImportModel(filepath.Join(os.TempDir(), "does-not-exist.tar.gz"), os.TempDir(), nil, false, false)
+		Expect(err).To(HaveOccurred())
+	})
+})
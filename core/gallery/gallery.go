@@ -27,7 +27,7 @@ func InstallModelFromGallery(galleries []config.Gallery, name string, basePath s
 			var err error
 			config, err = GetGalleryConfigFromURL(model.URL, basePath)
 			if err != nil {
-				return err
+				return fmt.Errorf("gallery model %s: %w", model.ID(), err)
 			}
 		} else if len(model.ConfigFile) > 0 {
 			// TODO: is this worse than using the override method with a blank cfg yaml?
@@ -135,7 +135,7 @@ func findGalleryURLFromReferenceURL(url string, basePath string) (string, error)
 	err := uri.DownloadAndUnmarshal(basePath, func(url string, d []byte) error {
 		refFile = string(d)
 		if len(refFile) == 0 {
-			return fmt.Errorf("invalid reference file at url %s: %s", url, d)
+			return fmt.Errorf("%w: invalid reference file at url %s: %s", ErrInvalidGalleryIndex, url, d)
 		}
 		cutPoint := strings.LastIndex(url, "/")
 		refFile = url[:cutPoint+1] + refFile
@@ -144,30 +144,42 @@ func findGalleryURLFromReferenceURL(url string, basePath string) (string, error)
 	return refFile, err
 }
 
+// getGalleryModels returns the valid models of the index of the gallery, logging the invalid entries
 func getGalleryModels(gallery config.Gallery, basePath string) ([]*GalleryModel, error) {
-	var models []*GalleryModel = []*GalleryModel{}
+	index, err := getGalleryIndex(gallery, basePath)
+	if err != nil {
+		return []*GalleryModel{}, err
+	}
+	for _, e := range index.entryErrors {
+		log.Warn().Str("gallery", gallery.Name).Int("entry", e.Index).Int("line", e.Line).Str("name", e.Name).Str("error", e.Error).Msg("skipping the invalid gallery entry")
+	}
+	return index.models, nil
+}
 
+// getGalleryIndex returns the valid models of the index of the gallery, and the errors of the invalid entries. The
+// index is parsed again only when its content changed since the last download
+func getGalleryIndex(gallery config.Gallery, basePath string) (*galleryIndex, error) {
 	if strings.HasSuffix(gallery.URL, ".ref") {
 		var err error
 		gallery.URL, err = findGalleryURLFromReferenceURL(gallery.URL, basePath)
 		if err != nil {
-			return models, err
+			return nil, err
 		}
 	}
 	uri := downloader.URI(gallery.URL)
 
+	var index *galleryIndex
 	err := uri.DownloadAndUnmarshal(basePath, func(url string, d []byte) error {
-		return yaml.Unmarshal(d, &models)
+		var err error
+		index, err = parseGalleryIndexOnce(gallery.URL, d)
+		return err
 	})
 	if err != nil {
-		if yamlErr, ok := err.(*yaml.TypeError); ok {
-			log.Debug().Msgf("YAML errors: %s\n\nwreckage of models: %+v", strings.Join(yamlErr.Errors, "\n"), models)
-		}
-		return models, err
+		return nil, err
 	}
 
 	// Add gallery to models
-	for _, model := range models {
+	for _, model := range index.models {
 		model.Gallery = gallery
 		// we check if the model was already installed by checking if the config file exists
 		// TODO: (what to do if the model doesn't install a config file?)
@@ -175,7 +187,7 @@ func getGalleryModels(gallery config.Gallery, basePath string) ([]*GalleryModel,
 			model.Installed = true
 		}
 	}
-	return models, nil
+	return index, nil
 }
 
 func GetLocalModelConfiguration(basePath string, name string) (*Config, error) {
@@ -234,6 +246,11 @@ func DeleteModelFromSystem(basePath string, name string, additionalFiles []strin
 		}
 	}
 
+	// the blobs of the files are removed once no other model shares them
+	if _, e := downloader.GarbageCollectBlobs(); e != nil {
+		log.Error().Err(e).Msg("failed to remove the unused blobs")
+	}
+
 	return err
 }
 
@@ -0,0 +1,119 @@
+package gallery
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// ErrInvalidGalleryIndex is returned when the index of a gallery was downloaded, but can't be parsed
+var ErrInvalidGalleryIndex = errors.New("invalid gallery index")
+
+const (
+	GalleryStatusOK          = "ok"
+	GalleryStatusUnreachable = "unreachable"
+	GalleryStatusInvalid     = "invalid"
+	GalleryStatusEmpty       = "empty"
+	GalleryStatusTimeout     = "timeout"
+)
+
+// GalleryHealth is the result of the health check of a gallery
+type GalleryHealth struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Entries is the number of entries in the index, and InvalidEntries the ones which can't be parsed
+	Entries        int `json:"entries"`
+	InvalidEntries int `json:"invalid_entries,omitempty"`
+	// EntriesDelta is the change of Entries since the previous successful check, set by the caller
+	EntriesDelta *int `json:"entries_delta,omitempty"`
+
+	LatencyMS float64   `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Healthy returns whether the models of the gallery can be installed
+func (h GalleryHealth) Healthy() bool {
+	return h.Status == GalleryStatusOK
+}
+
+// CheckGallery downloads and parses the index of the gallery, telling an unreachable host from an invalid index
+func CheckGallery(gallery config.Gallery, basePath string) GalleryHealth {
+	h := GalleryHealth{Name: gallery.Name, URL: gallery.URL, CheckedAt: time.Now()}
+
+	index, err := getGalleryIndex(gallery, basePath)
+	h.LatencyMS = float64(time.Since(h.CheckedAt).Microseconds()) / 1000
+	switch {
+	case errors.Is(err, ErrInvalidGalleryIndex):
+		h.Status, h.Error = GalleryStatusInvalid, err.Error()
+		return h
+	case err != nil:
+		h.Status, h.Error = GalleryStatusUnreachable, err.Error()
+		return h
+	}
+
+	h.Entries = len(index.models) + len(index.entryErrors)
+	h.InvalidEntries = len(index.entryErrors)
+	switch {
+	case h.Entries == 0:
+		h.Status = GalleryStatusEmpty
+	case h.InvalidEntries > 0:
+		h.Status, h.Error = GalleryStatusInvalid, fmt.Sprintf("%d entries can't be parsed, they're skipped", h.InvalidEntries)
+	default:
+		h.Status = GalleryStatusOK
+	}
+	return h
+}
+
+// CheckGalleries checks all the galleries in parallel. The ones whose check didn't complete within the timeout
+// are reported with the timeout status
+func CheckGalleries(galleries []config.Gallery, basePath string, timeout time.Duration) []GalleryHealth {
+	results := make([]GalleryHealth, len(galleries))
+	done := make([]bool, len(galleries))
+	mu := sync.Mutex{}
+
+	wg := sync.WaitGroup{}
+	for i, g := range galleries {
+		wg.Add(1)
+		go func(i int, g config.Gallery) {
+			defer wg.Done()
+			h := CheckGallery(g, basePath)
+			mu.Lock()
+			defer mu.Unlock()
+			results[i], done[i] = h, true
+		}(i, g)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	health := make([]GalleryHealth, len(galleries))
+	for i, g := range galleries {
+		if done[i] {
+			health[i] = results[i]
+			continue
+		}
+		health[i] = GalleryHealth{
+			Name:      g.Name,
+			URL:       g.URL,
+			Status:    GalleryStatusTimeout,
+			Error:     fmt.Sprintf("no answer within %s", timeout),
+			LatencyMS: float64(timeout.Milliseconds()),
+			CheckedAt: time.Now(),
+		}
+	}
+	return health
+}
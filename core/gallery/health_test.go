@@ -0,0 +1,66 @@
+package gallery_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gallery health", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	gallery := func(name, content string) config.Gallery {
+		path := filepath.Join(dir, name+".yaml")
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return config.Gallery{Name: name, URL: "file://" + path}
+	}
+
+	It("counts the entries of a valid index", func() {
+		h := CheckGallery(gallery("valid", "- name: foo\n  url: foo.yaml\n- name: bar\n  url: bar.yaml\n"), dir)
+		Expect(h.Status).To(Equal(GalleryStatusOK))
+		Expect(h.Healthy()).To(BeTrue())
+		Expect(h.Entries).To(Equal(2))
+		Expect(h.Error).To(BeEmpty())
+	})
+
+	It("tells an invalid index from an unreachable gallery", func() {
+		h := CheckGallery(gallery("invalid", "name: foo\n"), dir)
+		Expect(h.Status).To(Equal(GalleryStatusInvalid))
+		Expect(h.Error).To(ContainSubstring("invalid gallery index"))
+
+		h = CheckGallery(config.Gallery{Name: "down", URL: "http://127.0.0.1:1/index.yaml"}, dir)
+		Expect(h.Status).To(Equal(GalleryStatusUnreachable))
+		Expect(h.Healthy()).To(BeFalse())
+	})
+
+	It("reports the empty indexes and the entries without name", func() {
+		Expect(CheckGallery(gallery("empty", ""), dir).Status).To(Equal(GalleryStatusEmpty))
+
+		h := CheckGallery(gallery("unnamed", "- name: foo\n- url: bar.yaml\n"), dir)
+		Expect(h.Status).To(Equal(GalleryStatusInvalid))
+		Expect(h.Entries).To(Equal(2))
+		Expect(h.InvalidEntries).To(Equal(1))
+	})
+
+	It("checks the galleries in parallel, keeping their order", func() {
+		galleries := []config.Gallery{
+			gallery("first", "- name: foo\n"),
+			{Name: "missing", URL: "file://" + filepath.Join(dir, "missing.yaml")},
+		}
+		health := CheckGalleries(galleries, dir, time.Minute)
+		Expect(health).To(HaveLen(2))
+		Expect(health[0].Name).To(Equal("first"))
+		Expect(health[0].Status).To(Equal(GalleryStatusOK))
+		Expect(health[1].Name).To(Equal("missing"))
+		Expect(health[1].Status).To(Equal(GalleryStatusUnreachable))
+	})
+})
@@ -0,0 +1,269 @@
+package gallery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/config"
+	"gopkg.in/yaml.v3"
+)
+
+// EntryError is the error of an entry of a gallery index which can't be parsed. The entry is skipped, the other
+// models of the gallery staying available
+type EntryError struct {
+	// Index is the position of the entry in the index, from 0, and Line its line in the index when known
+	Index int    `json:"index"`
+	Line  int    `json:"line,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// ConfigURL is set when the entry is valid, but the configuration file it points to with url: isn't
+	ConfigURL string `json:"config_url,omitempty"`
+	Error     string `json:"error"`
+}
+
+// GalleryErrors is the report of the errors of the index of a gallery
+type GalleryErrors struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Error is set when the whole index can't be downloaded or parsed, no entry being read
+	Error string `json:"error,omitempty"`
+	// Entries is the number of entries of the index, valid or not
+	Entries int          `json:"entries"`
+	Errors  []EntryError `json:"errors"`
+}
+
+// the line of the errors of the entries is reported apart
+var yamlLineRe = regexp.MustCompile(`^(yaml: )?line \d+: `)
+
+// CheckGalleryEntries downloads and parses the index of the gallery, reporting the entries which can't be parsed,
+// and the valid ones whose configuration file can't be downloaded or parsed
+func CheckGalleryEntries(gallery config.Gallery, basePath string) GalleryErrors {
+	report := GalleryErrors{Name: gallery.Name, URL: gallery.URL, Errors: []EntryError{}}
+	index, err := getGalleryIndex(gallery, basePath)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Entries = len(index.models) + len(index.entryErrors)
+	report.Errors = append(report.Errors, index.entryErrors...)
+
+	// the entries of a gallery often share their configuration file, which is checked once
+	configErrors := map[string]error{}
+	for i, m := range index.models {
+		if m.URL == "" {
+			continue
+		}
+		err, checked := configErrors[m.URL]
+		if !checked {
+			_, err = GetGalleryConfigFromURL(m.URL, basePath)
+			configErrors[m.URL] = err
+		}
+		if err != nil {
+			pos := index.positions[i]
+			report.Errors = append(report.Errors, EntryError{Index: pos.index, Line: pos.line, Name: m.Name, ConfigURL: m.URL, Error: err.Error()})
+		}
+	}
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Index < report.Errors[j].Index })
+	return report
+}
+
+// galleryIndex is a parsed gallery index: its valid models, with their position in the index, and the errors of
+// the invalid entries
+type galleryIndex struct {
+	models      []*GalleryModel
+	positions   []entryPosition
+	entryErrors []EntryError
+}
+
+type entryPosition struct {
+	index, line int
+}
+
+// the indexes already parsed by gallery URL, parsed again only when their content changes
+var parsedIndexes = struct {
+	sync.Mutex
+	indexes map[string]parsedIndex
+}{indexes: map[string]parsedIndex{}}
+
+type parsedIndex struct {
+	digest [sha256.Size]byte
+	index  *galleryIndex
+	err    error
+}
+
+// parseGalleryIndexOnce is parseGalleryIndex, caching the result for the url. The models returned are copies, the
+// callers setting their gallery
+func parseGalleryIndexOnce(url string, d []byte) (*galleryIndex, error) {
+	digest := sha256.Sum256(d)
+	parsedIndexes.Lock()
+	parsed, ok := parsedIndexes.indexes[url]
+	parsedIndexes.Unlock()
+	if !ok || parsed.digest != digest {
+		parsed.digest = digest
+		parsed.index, parsed.err = parseGalleryIndex(d)
+		parsedIndexes.Lock()
+		parsedIndexes.indexes[url] = parsed
+		parsedIndexes.Unlock()
+	}
+	if parsed.err != nil {
+		return nil, parsed.err
+	}
+
+	index := &galleryIndex{
+		models:      make([]*GalleryModel, len(parsed.index.models)),
+		positions:   parsed.index.positions,
+		entryErrors: append([]EntryError(nil), parsed.index.entryErrors...),
+	}
+	for i, m := range parsed.index.models {
+		model := *m
+		index.models[i] = &model
+	}
+	return index, nil
+}
+
+// parseGalleryIndex parses the models of a gallery index one entry at a time, so that the invalid entries are
+// reported and skipped instead of failing the whole index. The index itself must be a YAML list
+func parseGalleryIndex(d []byte) (*galleryIndex, error) {
+	entries, err := indexEntries(d)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidGalleryIndex, err)
+	}
+
+	index := &galleryIndex{models: []*GalleryModel{}}
+	for i, entry := range entries {
+		err := entry.err
+		if err == nil {
+			var model *GalleryModel
+			if model, err = parseGalleryEntry(entry.node); err == nil {
+				index.models = append(index.models, model)
+				index.positions = append(index.positions, entryPosition{index: i, line: entry.line})
+				continue
+			}
+		}
+		e := EntryError{Index: i, Line: entry.line, Name: entry.name, Error: yamlLineRe.ReplaceAllString(err.Error(), "")}
+		if entry.node != nil {
+			named := struct {
+				Name string `yaml:"name"`
+			}{}
+			if entry.node.Decode(&named) == nil {
+				e.Name = named.Name
+			}
+		}
+		index.entryErrors = append(index.entryErrors, e)
+	}
+	return index, nil
+}
+
+// indexEntry is an entry of an index, its node being nil when its YAML is invalid
+type indexEntry struct {
+	node *yaml.Node
+	line int
+	name string
+	err  error
+}
+
+// indexEntries returns the entries of an index. When the YAML of the index is invalid, the entries are split at
+// the items of the top-level list and parsed one at a time, the invalid entries being returned with their error
+func indexEntries(d []byte) ([]indexEntry, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(d, &root)
+	if err == nil {
+		if len(root.Content) == 0 {
+			return nil, nil
+		}
+		if root.Content[0].Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("expected a list of models, got %s", root.Content[0].ShortTag())
+		}
+		entries := make([]indexEntry, len(root.Content[0].Content))
+		for i, node := range root.Content[0].Content {
+			entries[i] = indexEntry{node: node, line: node.Line}
+		}
+		return entries, nil
+	}
+
+	chunks, lines := splitIndex(d)
+	if len(chunks) == 0 {
+		return nil, err
+	}
+	entries := make([]indexEntry, len(chunks))
+	// the entries may refer to the anchors of the entries before them, which are parsed along
+	anchors := map[string]int{}
+	for i, chunk := range chunks {
+		entries[i].line = lines[i]
+		var doc []byte
+		defs := map[int]bool{}
+		for _, alias := range yamlAliasRe.FindAllSubmatch(chunk, -1) {
+			if def, ok := anchors[string(alias[1])]; ok && !defs[def] {
+				defs[def] = true
+				doc = append(doc, chunks[def]...)
+			}
+		}
+		doc = append(doc, chunk...)
+
+		var root yaml.Node
+		if err := yaml.Unmarshal(doc, &root); err != nil {
+			entries[i].err = err
+			if name := yamlNameRe.FindSubmatch(chunk); name != nil {
+				entries[i].name = strings.Trim(string(name[1]), `"'`)
+			}
+			continue
+		}
+		items := root.Content[0].Content
+		entries[i].node = items[len(items)-1]
+		for _, anchor := range yamlAnchorRe.FindAllSubmatch(chunk, -1) {
+			anchors[string(anchor[1])] = i
+		}
+	}
+	return entries, nil
+}
+
+var (
+	yamlAnchorRe = regexp.MustCompile(`&([^\s,\[\]{}]+)`)
+	yamlAliasRe  = regexp.MustCompile(`\*([^\s,\[\]{}]+)`)
+	yamlNameRe   = regexp.MustCompile(`(?m)^[-\s]*name:\s*(.+?)\s*$`)
+)
+
+// splitIndex splits an index at the items of its top-level list, returning the text of each item and its line
+func splitIndex(d []byte) ([][]byte, []int) {
+	var chunks [][]byte
+	var lines []int
+	for i, line := range bytes.SplitAfter(d, []byte("\n")) {
+		item := bytes.HasPrefix(line, []byte("- ")) || bytes.Equal(bytes.TrimRight(line, "\r\n"), []byte("-"))
+		switch {
+		case item:
+			chunks, lines = append(chunks, append([]byte{}, line...)), append(lines, i+1)
+		case len(chunks) > 0:
+			chunks[len(chunks)-1] = append(chunks[len(chunks)-1], line...)
+		}
+	}
+	return chunks, lines
+}
+
+// parseGalleryEntry decodes an entry of an index, resolving its aliases and merge keys
+func parseGalleryEntry(entry *yaml.Node) (*GalleryModel, error) {
+	if entry.Kind == yaml.AliasNode {
+		entry = entry.Alias
+	}
+	if entry.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a model, got %s", entry.ShortTag())
+	}
+	model := &GalleryModel{}
+	if err := entry.Decode(model); err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			msgs := make([]string, len(typeErr.Errors))
+			for i, msg := range typeErr.Errors {
+				msgs[i] = yamlLineRe.ReplaceAllString(msg, "")
+			}
+			return nil, fmt.Errorf("%s", strings.Join(msgs, "; "))
+		}
+		return nil, err
+	}
+	if model.Name == "" {
+		return nil, fmt.Errorf("the model has no name")
+	}
+	return model, nil
+}
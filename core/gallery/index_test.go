@@ -0,0 +1,148 @@
+package gallery_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gallery index errors", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	gallery := func(name, content string) config.Gallery {
+		path := filepath.Join(dir, name+".yaml")
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return config.Gallery{Name: name, URL: "file://" + path}
+	}
+
+	// the entries point to the configuration files written in dir
+	configs := func(index string) string {
+		Expect(os.WriteFile(filepath.Join(dir, "foo.yaml"), []byte("name: foo\n"), 0600)).To(Succeed())
+		return strings.ReplaceAll(index, "url: ", "url: file://"+dir+"/")
+	}
+
+	index := `- &base
+  name: foo
+  url: foo.yaml
+  tags: [llm]
+- name: bar
+  tags: chat
+- url: baz.yaml
+- !!merge <<: *base
+  name: qux
+`
+
+	It("reports the invalid entries with their line", func() {
+		report := CheckGalleryEntries(gallery("broken", configs(index)), dir)
+		Expect(report.Error).To(BeEmpty())
+		Expect(report.Entries).To(Equal(4))
+		Expect(report.Errors).To(HaveLen(2))
+
+		Expect(report.Errors[0].Index).To(Equal(1))
+		Expect(report.Errors[0].Line).To(Equal(5))
+		Expect(report.Errors[0].Name).To(Equal("bar"))
+		Expect(report.Errors[0].Error).To(ContainSubstring("cannot unmarshal !!str `chat`"))
+		Expect(report.Errors[0].Error).ToNot(ContainSubstring("line"))
+
+		Expect(report.Errors[1].Index).To(Equal(2))
+		Expect(report.Errors[1].Line).To(Equal(7))
+		Expect(report.Errors[1].Error).To(Equal("the model has no name"))
+	})
+
+	It("keeps the valid entries", func() {
+		g := gallery("broken", configs(index))
+		models, err := AvailableGalleryModels([]config.Gallery{g}, dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(2))
+		Expect(models[0].Name).To(Equal("foo"))
+		Expect(models[1].Name).To(Equal("qux"))
+		Expect(models[1].URL).To(Equal("file://" + dir + "/foo.yaml"))
+		Expect(models[1].Tags).To(Equal([]string{"llm"}))
+		Expect(models[1].Gallery.Name).To(Equal("broken"))
+
+		h := CheckGallery(g, dir)
+		Expect(h.Status).To(Equal(GalleryStatusInvalid))
+		Expect(h.Entries).To(Equal(4))
+		Expect(h.InvalidEntries).To(Equal(2))
+	})
+
+	It("reports the indexes which aren't a list", func() {
+		report := CheckGalleryEntries(gallery("invalid", "name: foo\n"), dir)
+		Expect(report.Error).To(ContainSubstring("invalid gallery index"))
+		Expect(report.Errors).To(BeEmpty())
+	})
+
+	It("reports the entries whose YAML is invalid, keeping the other ones", func() {
+		g := gallery("syntax", configs(`- &base
+  name: foo
+  url: foo.yaml
+- name: bar
+  description: "unterminated
+  url: foo.yaml
+- <<: *base
+  name: qux
+`))
+		report := CheckGalleryEntries(g, dir)
+		Expect(report.Error).To(BeEmpty())
+		Expect(report.Entries).To(Equal(3))
+		Expect(report.Errors).To(HaveLen(1))
+		Expect(report.Errors[0].Index).To(Equal(1))
+		Expect(report.Errors[0].Line).To(Equal(4))
+		Expect(report.Errors[0].Name).To(Equal("bar"))
+
+		models, err := AvailableGalleryModels([]config.Gallery{g}, dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(2))
+		Expect(models[1].Name).To(Equal("qux"))
+		Expect(models[1].URL).To(Equal("file://" + dir + "/foo.yaml"))
+	})
+
+	It("reports the configuration files which can't be read", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "invalid.yaml"), []byte("name: [\n"), 0600)).To(Succeed())
+		report := CheckGalleryEntries(gallery("configs", configs(`- name: foo
+  url: foo.yaml
+- name: bar
+  url: missing.yaml
+- name: baz
+  url: invalid.yaml
+- name: qux
+  url: invalid.yaml
+`)), dir)
+		Expect(report.Error).To(BeEmpty())
+		Expect(report.Entries).To(Equal(4))
+		Expect(report.Errors).To(HaveLen(3))
+		for i, name := range []string{"bar", "baz", "qux"} {
+			Expect(report.Errors[i].Index).To(Equal(i + 1))
+			Expect(report.Errors[i].Line).To(Equal(2*i + 3))
+			Expect(report.Errors[i].Name).To(Equal(name))
+			Expect(report.Errors[i].ConfigURL).ToNot(BeEmpty())
+		}
+		Expect(report.Errors[1].Error).To(ContainSubstring("invalid gallery config"))
+	})
+
+	It("parses the index again only when it changes", func() {
+		g := gallery("cached", "- name: foo\n")
+		models, err := AvailableGalleryModels([]config.Gallery{g}, dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(1))
+		// the models returned are copies of the parsed ones
+		models[0].Name = "changed"
+		models, err = AvailableGalleryModels([]config.Gallery{g}, dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models[0].Name).To(Equal("foo"))
+
+		gallery("cached", "- name: foo\n- name: bar\n")
+		models, err = AvailableGalleryModels([]config.Gallery{g}, dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(HaveLen(2))
+	})
+})
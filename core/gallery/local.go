@@ -0,0 +1,129 @@
+package gallery
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/mudler/LocalAI/pkg/utils"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LinkMode is how a local file is brought into the models directory
+type LinkMode string
+
+const (
+	LinkModeSymlink  LinkMode = "symlink"
+	LinkModeHardlink LinkMode = "hardlink"
+	LinkModeCopy     LinkMode = "copy"
+	LinkModeMove     LinkMode = "move"
+)
+
+// ImportLocalModel registers a model file which is already on disk, e.g. on a NAS, without downloading it again.
+// The file is linked, copied or moved into basePath according to mode and a config named name is generated,
+// name defaults to the file name without extension. It returns the name of the model.
+func ImportLocalModel(basePath, src, name string, mode LinkMode) (string, error) {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q: %w", src, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("%q is not a regular file", src)
+	}
+
+	fileName := filepath.Base(src)
+	if name == "" {
+		name = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	configFile := name + ".yaml"
+	for _, f := range []string{fileName, configFile} {
+		if err := utils.VerifyPath(f, basePath); err != nil {
+			return "", err
+		}
+	}
+
+	dst := filepath.Join(basePath, fileName)
+	if _, err := os.Lstat(dst); err == nil {
+		return "", fmt.Errorf("%q already exists in the models directory", fileName)
+	}
+	if _, err := os.Stat(filepath.Join(basePath, configFile)); err == nil {
+		return "", fmt.Errorf("model %q already exists", name)
+	}
+
+	if err := os.MkdirAll(basePath, 0750); err != nil {
+		return "", fmt.Errorf("failed to create base path: %v", err)
+	}
+
+	switch mode {
+	case LinkModeSymlink, "":
+		err = os.Symlink(src, dst)
+	case LinkModeHardlink:
+		err = os.Link(src, dst)
+	case LinkModeCopy:
+		err = copyFile(src, dst)
+	case LinkModeMove:
+		err = os.Rename(src, dst)
+		// Renames can't cross filesystems
+		if errors.Is(err, syscall.EXDEV) {
+			if err = copyFile(src, dst); err == nil {
+				err = os.Remove(src)
+			}
+		}
+	default:
+		return "", fmt.Errorf("unknown mode %q, expected one of symlink, hardlink, copy or move", mode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to %s %q to the models directory: %w", mode, src, err)
+	}
+
+	// The backend is guessed when the config is loaded
+	dat, err := yaml.Marshal(map[string]interface{}{
+		"name": name,
+		"parameters": map[string]interface{}{
+			"model": fileName,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(basePath, configFile), dat, 0600); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// copyFile copies src to dst through a partial file next to dst, so no other temporary copy is made
+// and an interrupted copy is never mistaken for the model
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	partial := dst + ".partial"
+	out, err := os.OpenFile(partial, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(partial)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, dst)
+}
@@ -0,0 +1,83 @@
+package gallery_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/core/gallery"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = Describe("Local model import", func() {
+	var src, dst string
+
+	BeforeEach(func() {
+		src = GinkgoT().TempDir()
+		dst = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(src, "foo.gguf"), []byte("weights"), 0600)).To(Succeed())
+	})
+
+	expectConfig := func(name, file string) {
+		dat, err := os.ReadFile(filepath.Join(dst, name+".yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		config := map[string]interface{}{}
+		Expect(yaml.Unmarshal(dat, &config)).To(Succeed())
+		Expect(config["name"]).To(Equal(name))
+		Expect(config["parameters"]).To(HaveKeyWithValue("model", file))
+	}
+
+	It("symlinks the file and generates a config", func() {
+		name, err := ImportLocalModel(dst, filepath.Join(src, "foo.gguf"), "", LinkModeSymlink)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("foo"))
+
+		target, err := os.Readlink(filepath.Join(dst, "foo.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(target).To(Equal(filepath.Join(src, "foo.gguf")))
+		expectConfig("foo", "foo.gguf")
+	})
+
+	It("hardlinks and copies the file", func() {
+		_, err := ImportLocalModel(dst, filepath.Join(src, "foo.gguf"), "", LinkModeHardlink)
+		Expect(err).ToNot(HaveOccurred())
+		srcInfo, err := os.Stat(filepath.Join(src, "foo.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		dstInfo, err := os.Stat(filepath.Join(dst, "foo.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.SameFile(srcInfo, dstInfo)).To(BeTrue())
+
+		other := GinkgoT().TempDir()
+		_, err = ImportLocalModel(other, filepath.Join(src, "foo.gguf"), "bar", LinkModeCopy)
+		Expect(err).ToNot(HaveOccurred())
+		dat, err := os.ReadFile(filepath.Join(other, "foo.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal("weights"))
+		_, err = os.Stat(filepath.Join(other, "foo.gguf.partial"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("moves the file", func() {
+		name, err := ImportLocalModel(dst, filepath.Join(src, "foo.gguf"), "bar", LinkModeMove)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(name).To(Equal("bar"))
+
+		_, err = os.Stat(filepath.Join(src, "foo.gguf"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		dat, err := os.ReadFile(filepath.Join(dst, "foo.gguf"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(dat)).To(Equal("weights"))
+		expectConfig("bar", "foo.gguf")
+	})
+
+	It("refuses to replace existing models and unknown modes", func() {
+		_, err := ImportLocalModel(dst, filepath.Join(src, "foo.gguf"), "", LinkModeSymlink)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ImportLocalModel(dst, filepath.Join(src, "foo.gguf"), "", LinkModeCopy)
+		Expect(err).To(HaveOccurred())
+
+		_, err = ImportLocalModel(GinkgoT().TempDir(), filepath.Join(src, "foo.gguf"), "", LinkMode("reflink"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -59,6 +59,17 @@ type File struct {
 	Filename string `yaml:"filename" json:"filename"`
 	SHA256   string `yaml:"sha256" json:"sha256"`
 	URI      string `yaml:"uri" json:"uri"`
+	// Mirrors are alternative URIs of the same file, tried in order when the download from URI fails
+	Mirrors []string `yaml:"mirrors,omitempty" json:"mirrors,omitempty"`
+}
+
+// URIs returns the URI of the file followed by its mirrors
+func (f File) URIs() []downloader.URI {
+	uris := []downloader.URI{downloader.URI(f.URI)}
+	for _, m := range f.Mirrors {
+		uris = append(uris, downloader.URI(m))
+	}
+	return uris
 }
 
 type PromptTemplate struct {
@@ -70,7 +81,10 @@ func GetGalleryConfigFromURL(url string, basePath string) (Config, error) {
 	var config Config
 	uri := downloader.URI(url)
 	err := uri.DownloadAndUnmarshal(basePath, func(url string, d []byte) error {
-		return yaml.Unmarshal(d, &config)
+		if err := yaml.Unmarshal(d, &config); err != nil {
+			return fmt.Errorf("invalid gallery config at %s: %w", url, err)
+		}
+		return nil
 	})
 	if err != nil {
 		log.Error().Err(err).Str("url", url).Msg("failed to get gallery config for url")
@@ -125,8 +139,7 @@ func InstallModel(basePath, nameOverride string, config *Config, configOverrides
 				return err
 			}
 		}
-		uri := downloader.URI(file.URI)
-		if err := uri.DownloadFile(filePath, file.SHA256, i, len(config.Files), downloadStatus); err != nil {
+		if err := downloader.DownloadFileWithMirrors(file.URIs(), filePath, file.SHA256, i, len(config.Files), downloadStatus); err != nil {
 			return err
 		}
 	}
@@ -2,6 +2,7 @@ package gallery
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/mudler/LocalAI/core/config"
@@ -50,6 +51,106 @@ func (gm GalleryModels) Search(term string) GalleryModels {
 	return filteredModels
 }
 
+// SearchFilter narrows down the gallery models, the empty fields match any model
+type SearchFilter struct {
+	// Tags are all required
+	Tags []string
+	// Backend is matched against the backend of the configuration, the base configuration and the tags,
+	// e.g. llama, whisper or piper
+	Backend string
+	License string
+	// Quantization is matched against the model files, e.g. q4_k_m
+	Quantization string
+}
+
+// Filter returns the models matching all the fields of the filter, case insensitively
+func (gm GalleryModels) Filter(f SearchFilter) GalleryModels {
+	var filteredModels GalleryModels
+	for _, m := range gm {
+		if f.matches(m) {
+			filteredModels = append(filteredModels, m)
+		}
+	}
+	return filteredModels
+}
+
+func (f SearchFilter) matches(m *GalleryModel) bool {
+	for _, tag := range f.Tags {
+		if !containsFold(m.Tags, tag) {
+			return false
+		}
+	}
+	if f.License != "" && !strings.Contains(strings.ToLower(m.License), strings.ToLower(f.License)) {
+		return false
+	}
+	if f.Backend != "" && !anyContainsFold(m.backendHints(), f.Backend) {
+		return false
+	}
+	if f.Quantization != "" && !anyContainsFold(m.fileNames(), f.Quantization) {
+		return false
+	}
+	return true
+}
+
+// backendHints returns what tells the backend of the model: most of the gallery models set it in their base configuration
+func (m *GalleryModel) backendHints() []string {
+	hints := append([]string{}, m.Tags...)
+	for _, cfg := range []map[string]interface{}{m.Overrides, m.ConfigFile} {
+		if backend, ok := cfg["backend"].(string); ok {
+			hints = append(hints, backend)
+		}
+	}
+	if m.URL != "" {
+		base := path.Base(strings.SplitN(m.URL, "@", 2)[0])
+		hints = append(hints, strings.TrimSuffix(base, path.Ext(base)))
+	}
+	for _, name := range m.fileNames() {
+		if strings.HasSuffix(strings.ToLower(name), ".gguf") {
+			hints = append(hints, "llama-cpp")
+			break
+		}
+	}
+	return hints
+}
+
+func (m *GalleryModel) fileNames() []string {
+	names := []string{m.Name}
+	for _, f := range m.AdditionalFiles {
+		names = append(names, f.Filename)
+	}
+	// the galleries are decoded with yaml.v2, the nested maps are keyed by interface{}
+	switch params := m.Overrides["parameters"].(type) {
+	case map[string]interface{}:
+		if model, ok := params["model"].(string); ok {
+			names = append(names, model)
+		}
+	case map[interface{}]interface{}:
+		if model, ok := params["model"].(string); ok {
+			names = append(names, model)
+		}
+	}
+	return names
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyContainsFold(values []string, s string) bool {
+	s = strings.ToLower(s)
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
 func (gm GalleryModels) FindByName(name string) *GalleryModel {
 	for _, m := range gm {
 		if strings.EqualFold(m.Name, name) {
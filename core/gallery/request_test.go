@@ -15,4 +15,55 @@ var _ = Describe("Gallery API tests", func() {
 			Expect(e.Name).To(Equal("gpt4all-j"))
 		})
 	})
+
+	Context("filters", func() {
+		models := GalleryModels{
+			{
+				Name:    "smollm-1.7b-instruct",
+				URL:     "github:mudler/LocalAI/gallery/chatml.yaml@master",
+				License: "Apache-2.0",
+				Tags:    []string{"llm", "gguf", "cpu"},
+				Overrides: map[string]interface{}{
+					"parameters": map[interface{}]interface{}{"model": "SmolLM-1.7B-Instruct.Q4_K_M.gguf"},
+				},
+			},
+			{
+				Name:            "whisper-base-q5_1",
+				URL:             "github:mudler/LocalAI/gallery/whisper-base.yaml@master",
+				License:         "MIT",
+				AdditionalFiles: []File{{Filename: "ggml-model-whisper-base-q5_1.bin"}},
+			},
+			{
+				Name:       "voice-en-us-amy-low",
+				URL:        "github:mudler/LocalAI/gallery/piper.yaml@master",
+				License:    "MIT",
+				Tags:       []string{"tts", "cpu"},
+				ConfigFile: map[string]interface{}{"backend": "piper"},
+			},
+		}
+		names := func(gm GalleryModels) []string {
+			n := []string{}
+			for _, m := range gm {
+				n = append(n, m.Name)
+			}
+			return n
+		}
+
+		It("matches all the tags", func() {
+			Expect(names(models.Filter(SearchFilter{Tags: []string{"CPU"}}))).To(Equal([]string{"smollm-1.7b-instruct", "voice-en-us-amy-low"}))
+			Expect(names(models.Filter(SearchFilter{Tags: []string{"cpu", "tts"}}))).To(Equal([]string{"voice-en-us-amy-low"}))
+		})
+		It("matches the backend", func() {
+			Expect(names(models.Filter(SearchFilter{Backend: "llama"}))).To(Equal([]string{"smollm-1.7b-instruct"}))
+			Expect(names(models.Filter(SearchFilter{Backend: "whisper"}))).To(Equal([]string{"whisper-base-q5_1"}))
+			Expect(names(models.Filter(SearchFilter{Backend: "piper"}))).To(Equal([]string{"voice-en-us-amy-low"}))
+		})
+		It("matches the license and the quantization", func() {
+			Expect(names(models.Filter(SearchFilter{License: "mit", Quantization: "Q5_1"}))).To(Equal([]string{"whisper-base-q5_1"}))
+			Expect(names(models.Filter(SearchFilter{Quantization: "q4_k_m"}))).To(Equal([]string{"smollm-1.7b-instruct"}))
+		})
+		It("matches any model with an empty filter", func() {
+			Expect(models.Filter(SearchFilter{})).To(HaveLen(3))
+		})
+	})
 })
@@ -0,0 +1,281 @@
+package grpcapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	pb "github.com/mudler/LocalAI/core/grpcapi/proto"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	marshalOptions   = protojson.MarshalOptions{UseProtoNames: true}
+	unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// Server exposes the OpenAI API over gRPC. Every call is translated to its REST counterpart and
+// served in process by the HTTP application, so both APIs share the same behavior, authentication included.
+type Server struct {
+	pb.UnimplementedOpenAIServer
+
+	client *http.Client
+}
+
+// NewServer returns a Server forwarding the calls to app through an in-memory listener,
+// which is closed when ctx is done
+func NewServer(ctx context.Context, app *fiber.App, bodyLimit int) *Server {
+	ln := fasthttputil.NewInmemoryListener()
+	server := &fasthttp.Server{
+		Handler:            app.Handler(),
+		MaxRequestBodySize: bodyLimit,
+	}
+	go func() {
+		if err := server.Serve(ln); err != nil {
+			log.Error().Err(err).Msg("gRPC API: in-memory HTTP server stopped")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	return &Server{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return ln.Dial()
+				},
+			},
+		},
+	}
+}
+
+// Serve serves the gRPC API on address until ctx is done, over TLS when tlsConfig is set like the HTTP API
+func Serve(ctx context.Context, address string, app *fiber.App, bodyLimit int, tlsConfig *tls.Config) error {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(bodyLimit)}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterOpenAIServer(s, NewServer(ctx, app, bodyLimit))
+
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+
+	log.Info().Msgf("gRPC API listening on %s", lis.Addr().String())
+	return s.Serve(lis)
+}
+
+func (s *Server) ChatCompletion(ctx context.Context, in *pb.ChatCompletionRequest) (*pb.OpenAIResponse, error) {
+	out := &pb.OpenAIResponse{}
+	return out, s.call(ctx, "/v1/chat/completions", in, out)
+}
+
+func (s *Server) ChatCompletionStream(in *pb.ChatCompletionRequest, stream pb.OpenAI_ChatCompletionStreamServer) error {
+	return s.stream(stream.Context(), "/v1/chat/completions", in, stream.Send)
+}
+
+func (s *Server) Completion(ctx context.Context, in *pb.CompletionRequest) (*pb.OpenAIResponse, error) {
+	out := &pb.OpenAIResponse{}
+	return out, s.call(ctx, "/v1/completions", in, out)
+}
+
+func (s *Server) CompletionStream(in *pb.CompletionRequest, stream pb.OpenAI_CompletionStreamServer) error {
+	return s.stream(stream.Context(), "/v1/completions", in, stream.Send)
+}
+
+func (s *Server) Embedding(ctx context.Context, in *pb.EmbeddingRequest) (*pb.OpenAIResponse, error) {
+	out := &pb.OpenAIResponse{}
+	return out, s.call(ctx, "/v1/embeddings", in, out)
+}
+
+func (s *Server) ListModels(ctx context.Context, in *pb.ListModelsRequest) (*pb.ListModelsResponse, error) {
+	query := url.Values{}
+	query.Set("filter", in.GetFilter())
+	query.Set("excludeConfigured", strconv.FormatBool(in.GetExcludeConfigured()))
+
+	res, err := s.do(ctx, http.MethodGet, "/v1/models?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	out := &pb.ListModelsResponse{}
+	return out, unmarshalBody(res.Body, out)
+}
+
+// call forwards a unary call to the REST endpoint at path
+func (s *Server) call(ctx context.Context, path string, in, out proto.Message) error {
+	body, err := requestBody(in, false)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return unmarshalBody(res.Body, out)
+}
+
+// stream forwards a streaming call to the REST endpoint at path, and sends back every server-sent event
+func (s *Server) stream(ctx context.Context, path string, in proto.Message, send func(*pb.OpenAIResponse) error) error {
+	body, err := requestBody(in, true)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data: ")
+		if !found || strings.TrimSpace(data) == "" {
+			continue
+		}
+		if strings.TrimSpace(data) == "[DONE]" {
+			return nil
+		}
+
+		ev := &pb.OpenAIResponse{}
+		if err := unmarshalOptions.Unmarshal([]byte(data), ev); err != nil {
+			return status.Errorf(codes.Internal, "invalid event: %s", err)
+		}
+		if err := send(ev); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// do performs the REST request in process, forwarding the credentials of the gRPC call
+func (s *Server) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://localai"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if auth := md.Get("authorization"); len(auth) > 0 {
+			req.Header.Set("Authorization", auth[0])
+		}
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	if res.StatusCode >= 400 {
+		defer res.Body.Close()
+		return nil, restError(res)
+	}
+
+	return res, nil
+}
+
+// requestBody returns the JSON body of the REST request for the message
+func requestBody(in proto.Message, stream bool) ([]byte, error) {
+	dat, err := marshalOptions.Marshal(in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if !stream {
+		return dat, nil
+	}
+
+	request := map[string]any{}
+	if err := json.Unmarshal(dat, &request); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	request["stream"] = true
+	return json.Marshal(request)
+}
+
+func unmarshalBody(body io.Reader, out proto.Message) error {
+	dat, err := io.ReadAll(body)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if err := unmarshalOptions.Unmarshal(dat, out); err != nil {
+		return status.Errorf(codes.Internal, "invalid response: %s", err)
+	}
+	return nil
+}
+
+// restError maps the error response of the REST API to a gRPC status
+func restError(res *http.Response) error {
+	dat, _ := io.ReadAll(res.Body)
+
+	message := strings.TrimSpace(string(dat))
+	errorResponse := struct {
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(dat, &errorResponse); err == nil {
+		switch {
+		case errorResponse.Error != nil && errorResponse.Error.Message != "":
+			message = errorResponse.Error.Message
+		case errorResponse.Message != "":
+			message = errorResponse.Message
+		}
+	}
+	if message == "" {
+		message = fmt.Sprintf("request failed with status %d", res.StatusCode)
+	}
+
+	code := codes.Internal
+	switch res.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+
+	return status.Error(code, message)
+}
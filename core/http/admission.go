@@ -0,0 +1,114 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/rs/zerolog/log"
+)
+
+// charsPerToken estimates the prompt tokens of the requests before they are tokenized
+const charsPerToken = 4
+
+// isGenerationRequest tells whether a request is a chat, a completion or an edit, which are admitted by the
+// latency SLO of their model
+func isGenerationRequest(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPost && (strings.HasSuffix(c.Path(), "/completions") || strings.HasSuffix(c.Path(), "/edits"))
+}
+
+// latencySLO returns the latency SLO of the model, 0 when its requests are not admission controlled
+func latencySLO(cfg *config.BackendConfig, appConfig *config.ApplicationConfig) time.Duration {
+	if cfg.LatencySLO == "" {
+		return appConfig.LatencySLO
+	}
+	slo, err := time.ParseDuration(cfg.LatencySLO)
+	if err != nil {
+		log.Warn().Err(err).Str("model", cfg.Name).Msg("invalid latency_slo, ignoring it")
+		return appConfig.LatencySLO
+	}
+	return slo
+}
+
+// admitRequests queues the generation requests of the local models which can't complete within the latency SLO of
+// their model at the current load, and refuses them with 503 when they still can't after the queue timeout. The
+// requests admitted are passed to next
+func admitRequests(cl *config.BackendConfigLoader, admission *services.AdmissionService, appConfig *config.ApplicationConfig, next fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !isGenerationRequest(c) {
+			return next(c)
+		}
+		modelName, prompt := auditRequest(c)
+		cfg, exists := cl.GetBackendConfig(modelName)
+		if !exists || cfg.Backend == config.ProxyBackend {
+			return next(c)
+		}
+		slo := latencySLO(&cfg, appConfig)
+		if slo <= 0 {
+			return next(c)
+		}
+
+		cost := services.AdmissionCost{PromptTokens: len(prompt) / charsPerToken}
+		request := struct {
+			MaxTokens *int `json:"max_tokens"`
+		}{}
+		if json.Unmarshal(c.Body(), &request) == nil && request.MaxTokens != nil {
+			cost.MaxTokens = *request.MaxTokens
+		} else if cfg.Maxtokens != nil {
+			cost.MaxTokens = *cfg.Maxtokens
+		}
+
+		done, err := admission.Admit(c.UserContext(), cfg.Name, cost, slo, appConfig.AdmissionQueueTimeout)
+		if err != nil {
+			capacityErr, ok := err.(*services.CapacityError)
+			if !ok {
+				return err
+			}
+			return capacityError(c, capacityErr)
+		}
+
+		// the streamed responses are done at the end of the stream, with the usage of their last event
+		end, _ := c.Locals(fiberContext.StreamEndKey).(func(schema.OpenAIUsage))
+		c.Locals(fiberContext.StreamEndKey, func(usage schema.OpenAIUsage) {
+			done(usage)
+			if end != nil {
+				end(usage)
+			}
+		})
+		err = next(c)
+		if _, pending := c.Locals(fiberContext.StreamEndKey).(func(schema.OpenAIUsage)); pending {
+			c.Locals(fiberContext.StreamEndKey, end)
+			done(auditUsage(c))
+		}
+		return err
+	}
+}
+
+// capacityError answers a request refused by the admission control
+func capacityError(c *fiber.Ctx, err *services.CapacityError) error {
+	retryAfter := int(math.Ceil(err.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return c.Status(fiber.StatusServiceUnavailable).JSON(schema.CapacityErrorResponse{
+		Error: &schema.CapacityError{
+			Code:                    fiber.StatusServiceUnavailable,
+			Message:                 fmt.Sprintf("The request can't be served within the latency SLO: %s", err),
+			Type:                    "capacity_exceeded",
+			Model:                   err.Model,
+			RequestsInProgress:      err.Requests,
+			EstimatedLatencySeconds: err.EstimatedLatency.Seconds(),
+			LatencySLOSeconds:       err.LatencySLO.Seconds(),
+			RetryAfterSeconds:       retryAfter,
+		},
+	})
+}
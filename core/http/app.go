@@ -1,21 +1,28 @@
 package http
 
 import (
+	"context"
 	"embed"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/mudler/LocalAI/pkg/utils"
 
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/http/endpoints/openai"
 	"github.com/mudler/LocalAI/core/http/routes"
 
+	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/oidc"
 
 	"github.com/gofiber/contrib/fiberzerolog"
 	"github.com/gofiber/fiber/v2"
@@ -44,6 +51,15 @@ func readAuthHeader(c *fiber.Ctx) string {
 		authHeader = "Bearer " + xApiKey
 	}
 
+	// gemini
+	xApiKey = c.Get("x-goog-api-key")
+	if xApiKey == "" {
+		xApiKey = c.Query("key")
+	}
+	if xApiKey != "" {
+		authHeader = "Bearer " + xApiKey
+	}
+
 	return authHeader
 }
 
@@ -68,8 +84,14 @@ var embedDirStatic embed.FS
 func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) (*fiber.App, error) {
 
 	fiberCfg := fiber.Config{
-		Views:     renderEngine(),
-		BodyLimit: appConfig.UploadLimitMB * 1024 * 1024, // this is the default limit of 4MB
+		Views: renderEngine(),
+		// The bodies larger than the buffer are streamed to the handlers instead of kept in memory, they are
+		// limited to the upload limit by limitRequestBody
+		BodyLimit:                    requestBufferSize,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		ReadTimeout:                  appConfig.ReadTimeout,
+		WriteTimeout:                 appConfig.WriteTimeout,
 		// We disable the Fiber startup message as it does not conform to structured logging.
 		// We register a startup log line with connection information in the OnListen hook to keep things user friendly though
 		DisableStartupMessage: true,
@@ -103,6 +125,10 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 	}
 
 	app := fiber.New(fiberCfg)
+	// the uploads and the streamed answers have their own timeouts
+	app.Server().HeaderReceived = requestTimeouts(appConfig)
+	// the body is checked before any middleware reads it
+	app.Use(limitRequestBody(appConfig.UploadLimitMB * 1024 * 1024))
 
 	app.Hooks().OnListen(func(listenData fiber.ListenData) error {
 		scheme := "http"
@@ -125,12 +151,62 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 		app.Use(recover.New())
 	}
 
+	if appConfig.AutoShutdownAfter > 0 {
+		app.Use(autoShutdown(app, appConfig.AutoShutdownAfter))
+	}
+
+	if appConfig.OTelEndpoint != "" {
+		shutdownTracing, err := services.StartTracing(appConfig.OTelEndpoint, appConfig.NetworkGuard)
+		if err != nil {
+			return nil, err
+		}
+		app.Use(tracing())
+		app.Hooks().OnShutdown(func() error {
+			return shutdownTracing(context.Background())
+		})
+	}
+
+	recorders := []func(schema.AuditEntry){}
+	if appConfig.AuditLog != "" {
+		auditLogService, err := services.NewAuditLogService(appConfig.AuditLog, appConfig.AuditLogMaxSizeMB, appConfig.AuditLogMaxBackups, appConfig.AuditLogRedact)
+		if err != nil {
+			return nil, err
+		}
+		recorders = append(recorders, auditLogService.Log)
+		app.Hooks().OnShutdown(func() error {
+			return auditLogService.Close()
+		})
+	}
+
+	usageService := services.NewUsageService(appConfig)
+	if !appConfig.DisableUsageAccounting {
+		usageService.Start(appConfig.Context)
+		recorders = append(recorders, usageService.Record)
+		app.Hooks().OnShutdown(func() error {
+			usageService.Save()
+			return nil
+		})
+	}
+	if len(recorders) > 0 {
+		app.Use(recordRequests(recorders...))
+	}
+
 	metricsService, err := services.NewLocalAIMetricsService()
 	if err != nil {
 		return nil, err
 	}
 
 	if metricsService != nil {
+		if err := metricsService.RegisterBackendProcessMetrics(ml); err != nil {
+			log.Error().Err(err).Msg("failed registering backend process metrics")
+		}
+		if appConfig.ModelMetrics {
+			if err := metricsService.RegisterModelMetrics(ml); err != nil {
+				log.Error().Err(err).Msg("failed registering the model metrics")
+			} else {
+				backend.SetInferenceMetrics(metricsService)
+			}
+		}
 		app.Use(localai.LocalAIMetricsAPIMiddleware(metricsService))
 		app.Hooks().OnShutdown(func() error {
 			return metricsService.Shutdown()
@@ -138,13 +214,62 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 	}
 
 	// Auth middleware checking if API key is valid. If no API key is set, no auth is required.
-	auth := func(c *fiber.Ctx) error {
-		if len(appConfig.ApiKeys) == 0 {
-			return c.Next()
+	rateLimiter := services.NewRateLimiter()
+	var inferenceQueue *services.InferenceQueue
+	if appConfig.MaxConcurrentInferences > 0 {
+		inferenceQueue = services.NewInferenceQueue(appConfig.MaxConcurrentInferences, appConfig.MaxQueuedInferences)
+	}
+	admit := admitRequests(cl, services.NewAdmissionService(), appConfig, queueInferences(inferenceQueue))
+	// next limits the requests of each end user, identified by the user field of the requests, paces their streamed
+	// responses, admits them by the latency SLO of their model and queues them for an inference slot once the API key
+	// is accepted
+	next := func(c *fiber.Ctx) error {
+		if appConfig.UserRateLimit > 0 {
+			if user := requestUser(c); user != "" {
+				if allowed, wait := rateLimiter.AllowUser(fiberContext.APIKeyIDFromContext(c), user, appConfig.UserRateLimit); !allowed {
+					c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+					return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"message": "Rate limit of the user exceeded"})
+				}
+			}
 		}
-
-		if len(appConfig.ApiKeys) == 0 {
-			return c.Next()
+		rate, err := streamPacing(c)
+		if err != nil {
+			return err
+		}
+		if rate > 0 {
+			c.Locals(fiberContext.StreamPacingKey, rate)
+		}
+		return admit(c)
+	}
+	// accept authorizes the requests of the keys of api_keys.json and of the OIDC tokens
+	accept := func(c *fiber.Ctx, entry *config.APIKey) error {
+		if entry.Expired() {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "API key expired"})
+		}
+		if scope := requiredScope(c.Path()); !entry.AllowsScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": fmt.Sprintf("The API key requires the %s scope to use %s", scope, c.Path())})
+		}
+		if entry.RateLimit > 0 {
+			if allowed, wait := rateLimiter.Allow(entry.ID(), entry.RateLimit); !allowed {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"message": "Rate limit of the API key exceeded"})
+			}
+		}
+		c.Locals(fiberContext.APIKeyEntryKey, entry)
+		if entry.HasScope(config.APIKeyScopeAdmin) {
+			c.Locals(fiberContext.AdminKey, true)
+		}
+		return next(c)
+	}
+	oidcVerifier, err := newOIDCVerifier(appConfig)
+	if err != nil {
+		return nil, err
+	}
+	auth := func(c *fiber.Ctx) error {
+		// without authentication, every request is trusted
+		if len(appConfig.ApiKeys) == 0 && len(appConfig.AdminApiKeys) == 0 && appConfig.ApiKeyStore.Len() == 0 && oidcVerifier == nil {
+			c.Locals(fiberContext.AdminKey, true)
+			return next(c)
 		}
 
 		authHeader := readAuthHeader(c)
@@ -159,12 +284,37 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 		}
 
 		apiKey := authHeaderParts[1]
+		c.Locals(fiberContext.APIKey, apiKey)
+		for _, key := range appConfig.AdminApiKeys {
+			if apiKey == key {
+				c.Locals(fiberContext.AdminKey, true)
+				return next(c)
+			}
+		}
 		for _, key := range appConfig.ApiKeys {
 			if apiKey == key {
-				return c.Next()
+				return next(c)
 			}
 		}
 
+		// the keys of api_keys.json may expire, and be restricted to some models and to a number of requests
+		if entry, ok := appConfig.ApiKeyStore.Lookup(apiKey); ok {
+			return accept(c, entry)
+		}
+
+		// the JWTs of the OIDC issuer are accepted like the keys of api_keys.json, with the scopes of their claims
+		if oidcVerifier != nil && oidc.IsJWT(apiKey) {
+			claims, err := oidcVerifier.Verify(c.UserContext(), apiKey)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": fmt.Sprintf("Invalid token: %s", err)})
+			}
+			entry, err := oidcAPIKey(claims, appConfig)
+			if err != nil {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+			}
+			return accept(c, entry)
+		}
+
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid API key"})
 	}
 
@@ -184,21 +334,50 @@ func App(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *confi
 		app.Use(csrf.New())
 	}
 
+	faultInjectionService := services.NewFaultInjectionService()
+	if appConfig.FaultInjection {
+		log.Warn().Msg("Fault injection is enabled: the requests may be delayed, failed or truncated by the rules set through /faults")
+		app.Use(faultInjection(faultInjectionService))
+	}
+
 	// Load config jsons
 	utils.LoadConfig(appConfig.UploadDir, openai.UploadedFilesFile, &openai.UploadedFiles)
 	utils.LoadConfig(appConfig.ConfigsDir, openai.AssistantsConfigFile, &openai.Assistants)
 	utils.LoadConfig(appConfig.ConfigsDir, openai.AssistantsFileConfigFile, &openai.AssistantFiles)
+	localai.LoadImagePresets(appConfig)
+	openai.LoadBatches(appConfig)
+	openai.LoadThreads(appConfig)
+	openai.LoadResponses(appConfig)
 
 	galleryService := services.NewGalleryService(appConfig)
 	galleryService.Start(appConfig.Context, cl)
 
 	routes.RegisterElevenLabsRoutes(app, cl, ml, appConfig, auth)
-	routes.RegisterLocalAIRoutes(app, cl, ml, appConfig, galleryService, auth)
-	routes.RegisterOpenAIRoutes(app, cl, ml, appConfig, auth)
+	// Stores are loaded in their own model loader, shared by the stores API and by file_search
+	sl := model.NewModelLoader("")
+
+	memoryService := services.NewMemoryService(cl, ml, appConfig)
+
+	diagnosticsService := services.NewDiagnosticsService(cl, appConfig)
+	if appConfig.DiagnosticsDir != "" {
+		ml.SetCrashHandler(diagnosticsService.OnBackendCrash)
+	}
+
+	schedulerService := services.NewSchedulerService(cl, ml, appConfig)
+	schedulerService.Start(appConfig.Context)
+
+	recommendationService := services.NewRecommendationService(cl, ml, appConfig, usageService)
+
+	routes.RegisterLocalAIRoutes(app, cl, ml, sl, appConfig, galleryService, memoryService, diagnosticsService, schedulerService, faultInjectionService, usageService, recommendationService, auth)
+	storedCompletionsService := services.NewStoredCompletionsService(appConfig)
+	tokenBudgetService := services.NewTokenBudgetService(appConfig)
+	routes.RegisterOpenAIRoutes(app, cl, ml, sl, appConfig, memoryService, storedCompletionsService, tokenBudgetService, auth)
 	if !appConfig.DisableWebUI {
 		routes.RegisterUIRoutes(app, cl, ml, appConfig, galleryService, auth)
 	}
 	routes.RegisterJINARoutes(app, cl, ml, appConfig, auth)
+	routes.RegisterGeminiRoutes(app, cl, ml, appConfig, auth)
+	routes.RegisterTEIRoutes(app, cl, ml, appConfig, auth)
 
 	httpFS := http.FS(embedDirStatic)
 
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// recordRequests passes an entry of the audit log for each API request to the recorders, e.g. the audit log and the
+// usage accounting. The entries of the streamed responses are recorded when the stream ends, with the usage of its
+// last event. The health checks and the metrics are left alone
+func recordRequests(recorders ...func(schema.AuditEntry)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/healthz", "/readyz", "/metrics":
+			return c.Next()
+		}
+
+		start := time.Now()
+		entry := schema.AuditEntry{
+			Time:     start,
+			Method:   c.Method(),
+			Endpoint: c.Path(),
+		}
+		entry.Model, entry.Prompt = auditRequest(c)
+
+		finish := func(status int, usage schema.OpenAIUsage) {
+			entry.Status = status
+			entry.PromptTokens = usage.PromptTokens
+			entry.CompletionTokens = usage.CompletionTokens
+			entry.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+			for _, record := range recorders {
+				record(entry)
+			}
+		}
+		c.Locals(fiberContext.StreamEndKey, func(usage schema.OpenAIUsage) {
+			finish(fiber.StatusOK, usage)
+		})
+
+		err := c.Next()
+
+		entry.APIKeyID = fiberContext.APIKeyIDFromContext(c)
+		entry.User = fiberContext.UserFromContext(c)
+		// StreamWriter took the function over, the entry is written at the end of the stream
+		if _, pending := c.Locals(fiberContext.StreamEndKey).(func(schema.OpenAIUsage)); !pending {
+			entry.Streamed = true
+			return err
+		}
+		c.Locals(fiberContext.StreamEndKey, nil)
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+		finish(status, auditUsage(c))
+		return err
+	}
+}
+
+// auditRequest returns the model and the prompt of a request: the messages of the chats, the prompt of the
+// completions and the images, or the input of the embeddings and of the speech
+func auditRequest(c *fiber.Ctx) (string, string) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return c.FormValue("model"), c.FormValue("prompt")
+	}
+	request := struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+		Prompt json.RawMessage `json:"prompt"`
+		Input  json.RawMessage `json:"input"`
+	}{}
+	if json.Unmarshal(c.Body(), &request) != nil {
+		return "", ""
+	}
+
+	prompt := []string{}
+	for _, m := range request.Messages {
+		prompt = append(prompt, fmt.Sprintf("%s: %s", m.Role, auditText(m.Content)))
+	}
+	for _, raw := range []json.RawMessage{request.Prompt, request.Input} {
+		if text := auditText(raw); text != "" {
+			prompt = append(prompt, text)
+		}
+	}
+	return request.Model, strings.Join(prompt, "\n")
+}
+
+// auditText returns a JSON string as is, and the other values in JSON
+func auditText(raw json.RawMessage) string {
+	text := ""
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
+
+// auditUsage returns the token usage of a JSON response, the streamed ones being read by StreamWriter
+func auditUsage(c *fiber.Ctx) schema.OpenAIUsage {
+	response := struct {
+		Usage schema.OpenAIUsage `json:"usage"`
+	}{}
+	if !c.Response().IsBodyStream() && strings.HasPrefix(string(c.Response().Header.ContentType()), fiber.MIMEApplicationJSON) {
+		json.Unmarshal(c.Response().Body(), &response)
+	}
+	return response.Usage
+}
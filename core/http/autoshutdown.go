@@ -0,0 +1,51 @@
+package http
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// autoShutdownDrainTimeout is how long the requests still running (e.g. streams) have to complete
+const autoShutdownDrainTimeout = time.Minute
+
+// autoShutdown shuts the app down once no request arrived for the given duration, and none is running.
+// Health checks and metrics scraping don't count as activity.
+func autoShutdown(app *fiber.App, after time.Duration) fiber.Handler {
+	var running atomic.Int64
+	var lastActivity atomic.Int64
+	lastActivity.Store(time.Now().UnixNano())
+
+	go func() {
+		ticker := time.NewTicker(min(after/10+time.Second, time.Minute))
+		defer ticker.Stop()
+		for range ticker.C {
+			if running.Load() > 0 || time.Since(time.Unix(0, lastActivity.Load())) < after {
+				continue
+			}
+
+			log.Info().Msgf("No requests in the last %s, shutting down", after)
+			if err := app.ShutdownWithTimeout(autoShutdownDrainTimeout); err != nil {
+				log.Error().Err(err).Msg("error while shutting down")
+			}
+			return
+		}
+	}()
+
+	return func(c *fiber.Ctx) error {
+		switch c.Path() {
+		case "/healthz", "/readyz", "/metrics":
+			return c.Next()
+		}
+
+		running.Add(1)
+		lastActivity.Store(time.Now().UnixNano())
+		defer func() {
+			running.Add(-1)
+			lastActivity.Store(time.Now().UnixNano())
+		}()
+		return c.Next()
+	}
+}
@@ -1,16 +1,270 @@
 package fiberContext
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/scanner"
+	"github.com/mudler/LocalAI/pkg/storage"
 	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
 )
 
+// UserKey is the key of the fiber locals holding the end-user of the request (the OpenAI `user` field)
+const UserKey = "localai_user"
+
+// UserFromContext returns the end-user set in the request, if any
+func UserFromContext(ctx *fiber.Ctx) string {
+	user, _ := ctx.Locals(UserKey).(string)
+	return user
+}
+
+// AdminKey is the key of the fiber locals set when the request is authenticated with an admin API key
+const AdminKey = "localai_admin"
+
+// IsAdmin returns whether the request may use the admin-scoped fields
+func IsAdmin(ctx *fiber.Ctx) bool {
+	admin, _ := ctx.Locals(AdminKey).(bool)
+	return admin
+}
+
+// APIKey is the key of the fiber locals holding the API key the request is authenticated with
+const APIKey = "localai_api_key"
+
+// APIKeyFromContext returns the API key of the request, empty when the authentication is disabled
+func APIKeyFromContext(ctx *fiber.Ctx) string {
+	key, _ := ctx.Locals(APIKey).(string)
+	return key
+}
+
+// PriorityHeader sets the priority of an inference request, up to the priority of its API key
+const PriorityHeader = "X-LocalAI-Priority"
+
+// TokensPerSecondHeader paces the streamed response of a request, up to the rate of its API key
+const TokensPerSecondHeader = "X-LocalAI-Tokens-Per-Second"
+
+// APIKeyEntryKey is the key of the fiber locals holding the entry of api_keys.json the request is authenticated with
+const APIKeyEntryKey = "localai_api_key_entry"
+
+// APIKeyEntryFromContext returns the entry of api_keys.json of the request, nil for the other keys
+func APIKeyEntryFromContext(ctx *fiber.Ctx) *config.APIKey {
+	entry, _ := ctx.Locals(APIKeyEntryKey).(*config.APIKey)
+	return entry
+}
+
+// APIKeyIDFromContext identifies the API key of the request, by its name or by the prefix of its hash
+func APIKeyIDFromContext(ctx *fiber.Ctx) string {
+	if entry := APIKeyEntryFromContext(ctx); entry != nil {
+		return entry.ID()
+	}
+	if key := APIKeyFromContext(ctx); key != "" {
+		return (&config.APIKey{Key: key}).ID()
+	}
+	return ""
+}
+
+// CheckModelAccess returns an error when the API key of the request is not allowed to use the model
+func CheckModelAccess(ctx *fiber.Ctx, cfg *config.BackendConfig) error {
+	entry := APIKeyEntryFromContext(ctx)
+	if entry == nil {
+		return nil
+	}
+	name := ""
+	if cfg != nil {
+		name = cfg.Name
+	}
+	if !entry.AllowsModel(name) {
+		log.Debug().Str("key", entry.ID()).Str("model", name).Msg("model not allowed for the API key")
+		return fiber.NewError(fiber.StatusForbidden, fmt.Sprintf("the API key is not allowed to use the model %s", name))
+	}
+	return nil
+}
+
+// CheckBackendOverride returns an error when the request forces the backend of the model without being admin
+func CheckBackendOverride(ctx *fiber.Ctx, backend string) error {
+	if backend != "" && !IsAdmin(ctx) {
+		return fiber.NewError(fiber.StatusForbidden, "overriding the backend requires an admin API key")
+	}
+	return nil
+}
+
+// FaultTruncateKey is the key of the fiber locals holding the number of events after which the fault injection
+// truncates the streamed response
+const FaultTruncateKey = "localai_fault_truncate"
+
+var errStreamTruncated = errors.New("stream truncated by the fault injection")
+
+// truncatingWriter passes the events of a server-sent events stream until the limit is reached, then fails
+// like a client which went away
+type truncatingWriter struct {
+	w         *bufio.Writer
+	remaining int
+	newline   bool
+}
+
+func (t *truncatingWriter) Write(p []byte) (int, error) {
+	if t.remaining <= 0 {
+		return 0, errStreamTruncated
+	}
+	for i, b := range p {
+		switch b {
+		case '\r':
+			continue
+		case '\n':
+			if t.newline {
+				t.remaining--
+				if t.remaining == 0 {
+					n, _ := t.w.Write(p[:i+1])
+					t.w.Flush()
+					return n, errStreamTruncated
+				}
+			}
+			t.newline = true
+		default:
+			t.newline = false
+		}
+	}
+	n, err := t.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, t.w.Flush()
+}
+
+// StreamPacingKey is the key of the fiber locals holding the tokens per second the streamed response is paced to
+const StreamPacingKey = "localai_stream_pacing"
+
+// pacingWriter passes the events of a server-sent events stream at most once per interval. The streamed completions
+// send a token per event, the interval paces their tokens
+type pacingWriter struct {
+	w        *bufio.Writer
+	interval time.Duration
+	next     time.Time
+	newline  bool
+}
+
+func (p *pacingWriter) Write(b []byte) (int, error) {
+	written := 0
+	for i, c := range b {
+		switch c {
+		case '\r':
+			continue
+		case '\n':
+			if !p.newline {
+				p.newline = true
+				continue
+			}
+			// the end of an event, sent once its interval is over
+			p.newline = false
+			if wait := time.Until(p.next); wait > 0 {
+				time.Sleep(wait)
+			}
+			p.next = time.Now().Add(p.interval)
+			n, err := p.w.Write(b[written : i+1])
+			written += n
+			if err != nil {
+				return written, err
+			}
+			if err := p.w.Flush(); err != nil {
+				return written, err
+			}
+		default:
+			p.newline = false
+		}
+	}
+	n, err := p.w.Write(b[written:])
+	written += n
+	if err != nil {
+		return written, err
+	}
+	return written, p.w.Flush()
+}
+
+// StreamEndKey is the key of the fiber locals holding the function called when a streamed response ends, with the
+// usage of its last event. StreamWriter clears it when it takes it over
+const StreamEndKey = "localai_stream_end"
+
+// maxUsageLine bounds the events buffered to read the usage of a streamed response
+const maxUsageLine = 1 << 20
+
+// usageWriter passes a server-sent events stream, keeping the last usage sent in its events
+type usageWriter struct {
+	w     *bufio.Writer
+	line  []byte
+	usage schema.OpenAIUsage
+}
+
+func (u *usageWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b != '\n' {
+			if len(u.line) < maxUsageLine {
+				u.line = append(u.line, b)
+			}
+			continue
+		}
+		if data, ok := strings.CutPrefix(strings.TrimSpace(string(u.line)), "data:"); ok {
+			event := struct {
+				Usage *schema.OpenAIUsage `json:"usage"`
+			}{}
+			if json.Unmarshal([]byte(data), &event) == nil && event.Usage != nil && event.Usage.TotalTokens > 0 {
+				u.usage = *event.Usage
+			}
+		}
+		u.line = u.line[:0]
+	}
+	n, err := u.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, u.w.Flush()
+}
+
+// StreamWriter wraps the writer of a streamed response, to pace it to the tokens per second of StreamPacingKey, to
+// truncate it when the fault injection requires it, and to tell the end of the stream to the function of StreamEndKey
+func StreamWriter(ctx *fiber.Ctx, sw fasthttp.StreamWriter) fasthttp.StreamWriter {
+	if rate, _ := ctx.Locals(StreamPacingKey).(int); rate > 0 {
+		paced := sw
+		sw = func(w *bufio.Writer) {
+			bw := bufio.NewWriter(&pacingWriter{w: w, interval: time.Second / time.Duration(rate)})
+			paced(bw)
+			bw.Flush()
+		}
+	}
+	if events, _ := ctx.Locals(FaultTruncateKey).(int); events > 0 {
+		truncated := sw
+		sw = func(w *bufio.Writer) {
+			truncated(bufio.NewWriter(&truncatingWriter{w: w, remaining: events}))
+		}
+	}
+
+	end, _ := ctx.Locals(StreamEndKey).(func(schema.OpenAIUsage))
+	if end == nil {
+		return sw
+	}
+	ctx.Locals(StreamEndKey, nil)
+	return func(w *bufio.Writer) {
+		u := &usageWriter{w: w}
+		bw := bufio.NewWriter(u)
+		sw(bw)
+		bw.Flush()
+		end(u.usage)
+	}
+}
+
 // ModelFromContext returns the model from the context
 // If no model is specified, it will take the first available
 // Takes a model string as input which should be the one received from the user request.
@@ -43,3 +297,131 @@ func ModelFromContext(ctx *fiber.Ctx, cl *config.BackendConfigLoader, loader *mo
 	}
 	return modelInput, nil
 }
+
+// SetDeprecation sets the Deprecation and Sunset headers when the model of the request is deprecated,
+// and returns the warning to add to the response
+func SetDeprecation(ctx *fiber.Ctx, cfg *config.BackendConfig) string {
+	if cfg == nil || !cfg.Deprecation.Deprecated {
+		return ""
+	}
+
+	ctx.Set("Deprecation", "true")
+	if sunset, ok := cfg.Deprecation.SunsetTime(); ok {
+		ctx.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	warning := cfg.Deprecation.Warning(cfg.Name)
+	log.Warn().Str("model", cfg.Name).Str("replacement", cfg.Deprecation.Replacement).Msg("request to a deprecated model")
+	return warning
+}
+
+// CheckMaintenance fails the requests to a model under maintenance with 503, telling in the Retry-After
+// header when it's back
+func CheckMaintenance(ctx *fiber.Ctx, cfg *config.BackendConfig) error {
+	if cfg == nil || !cfg.Maintenance.Enabled {
+		return nil
+	}
+
+	if until, ok := cfg.Maintenance.UntilTime(); ok {
+		ctx.Set("Retry-After", until.UTC().Format(http.TimeFormat))
+	}
+	log.Debug().Str("model", cfg.Name).Msg("request to a model under maintenance")
+	return &MaintenanceError{err: fiber.NewError(fiber.StatusServiceUnavailable, cfg.Maintenance.Error(cfg.Name))}
+}
+
+// MaintenanceError is the 503 of the requests to a model under maintenance, which the chats and the completions
+// answer with the canned response of the model instead
+type MaintenanceError struct {
+	err *fiber.Error
+}
+
+func (e *MaintenanceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *MaintenanceError) Unwrap() error {
+	return e.err
+}
+
+// CheckModel runs the checks of the model of a request, once its configuration is loaded: the API key must be
+// allowed to use it, the Deprecation headers are set when it's deprecated, and it must not be under maintenance.
+// It returns the deprecation warning to add to the response
+func CheckModel(ctx *fiber.Ctx, cfg *config.BackendConfig) (string, error) {
+	if err := CheckModelAccess(ctx, cfg); err != nil {
+		return "", err
+	}
+	warning := SetDeprecation(ctx, cfg)
+	return warning, CheckMaintenance(ctx, cfg)
+}
+
+// ScanUpload runs the upload scanner on a file received by an endpoint, before it's stored or processed.
+// The flagged files are rejected, and kept in quarantine when configured. The files which could not be
+// scanned are rejected as well
+func ScanUpload(ctx *fiber.Ctx, appConfig *config.ApplicationConfig, kind, name string, data []byte) error {
+	if appConfig.UploadScanner == nil {
+		return nil
+	}
+
+	upload := scanner.NewUpload(kind, name, data)
+	verdict, err := appConfig.UploadScanner.Scan(ctx.Context(), upload)
+	if err != nil {
+		log.Error().Err(err).Str("file", name).Str("kind", kind).Msg("unable to scan the upload")
+		return fiber.NewError(fiber.StatusServiceUnavailable, "unable to scan the uploaded file")
+	}
+	if verdict.Clean {
+		return nil
+	}
+
+	l := log.Warn().Str("file", name).Str("kind", kind).Str("content_type", upload.ContentType).Str("reason", verdict.Reason).Str("ip", ctx.IP())
+	if appConfig.UploadQuarantineDir != "" {
+		path, err := scanner.Quarantine(appConfig.UploadQuarantineDir, upload, verdict)
+		if err != nil {
+			log.Error().Err(err).Str("file", name).Msg("unable to quarantine the upload")
+		}
+		l = l.Str("quarantine", path)
+	}
+	l.Msg("upload rejected by the scanner")
+	return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("the file %s was rejected by the upload scanner: %s", name, verdict.Reason))
+}
+
+// ScanFormFile is ScanUpload for a file of a multipart form
+func ScanFormFile(ctx *fiber.Ctx, appConfig *config.ApplicationConfig, kind string, file *multipart.FileHeader) error {
+	if appConfig.UploadScanner == nil {
+		return nil
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return ScanUpload(ctx, appConfig, kind, file.Filename, data)
+}
+
+// SendGeneratedAudio sends an audio file generated by a backend. With an object storage the file is
+// moved to the storage, and its URL is set in the Content-Location header
+func SendGeneratedAudio(ctx *fiber.Ctx, appConfig *config.ApplicationConfig, filePath string) error {
+	if appConfig.ObjectStorage == nil {
+		return ctx.Download(filePath)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	key := filepath.Base(filePath)
+	if err := storage.PutFile(ctx.Context(), appConfig.AudioStorage(), key, filePath); err != nil {
+		return err
+	}
+	url, err := appConfig.AudioStorage().URL(key)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentLocation, url)
+	ctx.Attachment(key)
+	return ctx.Send(data)
+}
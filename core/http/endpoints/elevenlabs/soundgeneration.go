@@ -46,6 +46,9 @@ func SoundGenerationEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoad
 			}
 		}
 		log.Debug().Str("modelFile", "modelFile").Str("backend", cfg.Backend).Msg("Sound Generation Request about to be sent to backend")
+		if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+			return err
+		}
 
 		if input.Duration != nil {
 			log.Debug().Float32("duration", *input.Duration).Msg("duration set")
@@ -59,7 +62,7 @@ func SoundGenerationEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoad
 		if err != nil {
 			return err
 		}
-		return c.Download(filePath)
+		return fiberContext.SendGeneratedAudio(c, appConfig, filePath)
 
 	}
 }
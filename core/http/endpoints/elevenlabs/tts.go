@@ -51,11 +51,14 @@ func TTSEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfi
 			}
 		}
 		log.Debug().Msgf("Request for model: %s", modelFile)
+		if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+			return err
+		}
 
 		filePath, _, err := backend.ModelTTS(cfg.Backend, input.Text, modelFile, "", voiceID, ml, appConfig, *cfg)
 		if err != nil {
 			return err
 		}
-		return c.Download(filePath)
+		return fiberContext.SendGeneratedAudio(c, appConfig, filePath)
 	}
 }
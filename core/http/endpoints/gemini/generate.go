@@ -0,0 +1,332 @@
+package gemini
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/reasoning"
+	"github.com/mudler/LocalAI/pkg/scanner"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	generateContentMethod       = "generateContent"
+	streamGenerateContentMethod = "streamGenerateContent"
+
+	finishReasonStop = "STOP"
+)
+
+// GenerateContentEndpoint acts like the Gemini generateContent and streamGenerateContent APIs
+// (https://ai.google.dev/api/generate-content), on top of the chat models
+// @Summary Generates a response from the model given an input GeminiRequest.
+// @Param model path string true "model name, followed by :generateContent or :streamGenerateContent"
+// @Param request body schema.GeminiRequest true "query params"
+// @Success 200 {object} schema.GeminiResponse "Response"
+// @Router /v1beta/models/{model} [post]
+func GenerateContentEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		// the model is followed by the method, e.g. /v1beta/models/gemma:generateContent
+		path := c.Params("*")
+		i := strings.LastIndex(path, ":")
+		if i < 0 {
+			return fiber.ErrNotFound
+		}
+		modelName, method := path[:i], path[i+1:]
+		if method != generateContentMethod && method != streamGenerateContentMethod {
+			return fiber.ErrNotFound
+		}
+
+		req := new(schema.GeminiRequest)
+		if err := c.BodyParser(req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+
+		modelFile, err := fiberContext.ModelFromContext(c, cl, ml, modelName, false)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
+			config.LoadOptionDebug(appConfig.Debug),
+			config.LoadOptionThreads(appConfig.Threads),
+			config.LoadOptionContextSize(appConfig.ContextSize),
+			config.LoadOptionF16(appConfig.F16),
+			config.ModelPath(ml.ModelPath),
+		)
+		if err != nil {
+			return err
+		}
+		if !cfg.Validate() {
+			return fmt.Errorf("failed to validate config")
+		}
+		applyGenerationConfig(cfg, req.GenerationConfig)
+		if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+			return err
+		}
+
+		input, err := chatRequest(c, modelName, req, appConfig)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(appConfig.Context)
+		input.Context = ctx
+		input.Cancel = cancel
+
+		predInput := openai.TemplateMessages(cfg, input, ml, nil, false)
+		log.Debug().Msgf("Prompt (after templating): %s", predInput)
+
+		if method == streamGenerateContentMethod {
+			return streamContent(c, input, predInput, cfg, ml, appConfig)
+		}
+		defer cancel()
+
+		result, tokenUsage, err := openai.ComputeChoices(input, predInput, cfg, appConfig, ml, func(s string, c *[]schema.Choice) {
+			*c = append(*c, schema.Choice{FinishReason: "stop", Index: len(*c), Message: &schema.Message{Role: "assistant", Content: &s}})
+		}, nil)
+		if err != nil {
+			return err
+		}
+
+		resp := schema.GeminiResponse{
+			ModelVersion:  modelName,
+			UsageMetadata: usageMetadata(tokenUsage),
+		}
+		for _, choice := range result {
+			content := ""
+			if s, ok := choice.Message.Content.(*string); ok && s != nil {
+				content = *s
+			}
+			resp.Candidates = append(resp.Candidates, schema.GeminiCandidate{
+				Content:      modelContent(choice.Message.ReasoningContent, content),
+				FinishReason: finishReasonStop,
+				Index:        choice.Index,
+			})
+		}
+		return c.JSON(resp)
+	}
+}
+
+// streamContent streams the response as server-sent events with ?alt=sse, as the Gemini SDKs request it,
+// or as a JSON array otherwise
+func streamContent(c *fiber.Ctx, input *schema.OpenAIRequest, predInput string, cfg *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig) error {
+	sse := c.Query("alt") == "sse"
+	if sse {
+		c.Context().SetContentType("text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+	} else {
+		c.Context().SetContentType(fiber.MIMEApplicationJSON)
+	}
+	c.Set("Transfer-Encoding", "chunked")
+
+	modelName := input.Model
+	responses := make(chan schema.GeminiResponse)
+	go func() {
+		defer close(responses)
+
+		var splitter *reasoning.Splitter
+		if cfg.Reasoning.Enabled() {
+			splitter = reasoning.NewSplitter(cfg.Reasoning)
+		}
+		send := func(thought, content string, usage backend.TokenUsage) {
+			if cfg.Reasoning.Mode != reasoning.ModeSeparate {
+				thought = ""
+			}
+			if thought == "" && content == "" {
+				return
+			}
+			responses <- schema.GeminiResponse{
+				Candidates:    []schema.GeminiCandidate{{Content: modelContent(thought, content)}},
+				UsageMetadata: usageMetadata(usage),
+				ModelVersion:  modelName,
+			}
+		}
+
+		lastUsage := backend.TokenUsage{}
+		_, tokenUsage, err := openai.ComputeChoices(input, predInput, cfg, appConfig, ml, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+			lastUsage = usage
+			if splitter == nil {
+				send("", s, usage)
+				return true
+			}
+			thought, content := splitter.Feed(s)
+			send(thought, content, usage)
+			return true
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("failed generating the content")
+			return
+		}
+		if splitter != nil {
+			thought, content := splitter.Flush()
+			send(thought, content, lastUsage)
+		}
+
+		responses <- schema.GeminiResponse{
+			Candidates:    []schema.GeminiCandidate{{Content: modelContent("", ""), FinishReason: finishReasonStop}},
+			UsageMetadata: usageMetadata(tokenUsage),
+			ModelVersion:  modelName,
+		}
+	}()
+
+	c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+		defer input.Cancel()
+		writeResponses(w, responses, sse, input.Cancel)
+	}))
+	return nil
+}
+
+// writeResponses writes the responses as server-sent events, or as the elements of a JSON array, until responses is
+// closed. cancel is called when the client is gone, the responses being drained until the generation stops
+func writeResponses(w *bufio.Writer, responses <-chan schema.GeminiResponse, sse bool, cancel func()) {
+	if !sse {
+		w.WriteString("[")
+	}
+	first := true
+	for ev := range responses {
+		dat, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+		if sse {
+			_, err = fmt.Fprintf(w, "data: %s\r\n\r\n", dat)
+		} else {
+			if !first {
+				w.WriteString(",\r\n")
+			}
+			_, err = w.Write(dat)
+		}
+		first = false
+		if err != nil {
+			log.Debug().Msgf("Sending chunk failed: %v", err)
+			cancel()
+		}
+		w.Flush()
+	}
+	if !sse {
+		w.WriteString("]")
+	}
+	w.Flush()
+}
+
+// chatRequest translates the Gemini contents into the messages of a chat request
+func chatRequest(c *fiber.Ctx, modelName string, req *schema.GeminiRequest, appConfig *config.ApplicationConfig) (*schema.OpenAIRequest, error) {
+	input := &schema.OpenAIRequest{}
+	input.Model = modelName
+	input.N = req.GenerationConfig.CandidateCount
+
+	if req.SystemInstruction != nil {
+		system := geminiMessage("system", *req.SystemInstruction)
+		input.Messages = append(input.Messages, system)
+	}
+
+	index := 0
+	for _, content := range req.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		m := geminiMessage(role, content)
+		for _, part := range content.Parts {
+			if part.InlineData == nil {
+				continue
+			}
+			if !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+				return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unsupported inline data of type %q", part.InlineData.MimeType))
+			}
+			if appConfig.UploadScanner != nil {
+				data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+				if err != nil {
+					return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid image: %s", err.Error()))
+				}
+				if err := fiberContext.ScanUpload(c, appConfig, scanner.KindImage, fmt.Sprintf("image-%d", index), data); err != nil {
+					return nil, err
+				}
+			}
+			img, err := utils.PreprocessBase64Image(part.InlineData.Data, appConfig.ImageLimits())
+			if err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid image: %s", err.Error()))
+			}
+			m.StringImages = append(m.StringImages, img)
+			// set a placeholder for each image, as in the chat completions
+			m.StringContent = fmt.Sprintf("[img-%d]", index) + m.StringContent
+			m.Content = m.StringContent
+			index++
+		}
+		input.Messages = append(input.Messages, m)
+	}
+
+	if len(input.Messages) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "contents must not be empty")
+	}
+	return input, nil
+}
+
+// geminiMessage returns the chat message with the text parts of content
+func geminiMessage(role string, content schema.GeminiContent) schema.Message {
+	text := []string{}
+	for _, part := range content.Parts {
+		if part.Text != "" {
+			text = append(text, part.Text)
+		}
+	}
+	s := strings.Join(text, "\n")
+	return schema.Message{Role: role, Content: s, StringContent: s}
+}
+
+// modelContent returns the content of a candidate, with the reasoning of the model as a thought part
+func modelContent(thought, content string) schema.GeminiContent {
+	parts := []schema.GeminiPart{}
+	if thought != "" {
+		parts = append(parts, schema.GeminiPart{Text: thought, Thought: true})
+	}
+	if content != "" || len(parts) == 0 {
+		parts = append(parts, schema.GeminiPart{Text: content})
+	}
+	return schema.GeminiContent{Role: "model", Parts: parts}
+}
+
+func applyGenerationConfig(cfg *config.BackendConfig, g schema.GeminiGenerationConfig) {
+	if g.Temperature != nil {
+		cfg.Temperature = g.Temperature
+	}
+	if g.TopP != nil {
+		cfg.TopP = g.TopP
+	}
+	if g.TopK != nil {
+		cfg.TopK = g.TopK
+	}
+	if g.MaxOutputTokens != nil {
+		cfg.Maxtokens = g.MaxOutputTokens
+	}
+	if g.Seed != nil {
+		cfg.Seed = g.Seed
+	}
+	if g.PresencePenalty != 0 {
+		cfg.PresencePenalty = g.PresencePenalty
+	}
+	if g.FrequencyPenalty != 0 {
+		cfg.FrequencyPenalty = g.FrequencyPenalty
+	}
+	cfg.StopWords = append(cfg.StopWords, g.StopSequences...)
+}
+
+func usageMetadata(usage backend.TokenUsage) schema.GeminiUsageMetadata {
+	return schema.GeminiUsageMetadata{
+		PromptTokenCount:     usage.Prompt,
+		CandidatesTokenCount: usage.Completion,
+		TotalTokenCount:      usage.Prompt + usage.Completion,
+	}
+}
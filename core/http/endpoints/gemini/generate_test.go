@@ -0,0 +1,168 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatRequest(t *testing.T) {
+	appConfig := &config.ApplicationConfig{}
+
+	t.Run("maps the contents and their roles to the messages", func(t *testing.T) {
+		req := &schema.GeminiRequest{
+			Contents: []schema.GeminiContent{
+				{Role: "user", Parts: []schema.GeminiPart{{Text: "Hello"}, {Text: "How are you?"}}},
+				{Role: "model", Parts: []schema.GeminiPart{{Text: "Fine"}}},
+				{Parts: []schema.GeminiPart{{Text: "Good"}}},
+			},
+			GenerationConfig: schema.GeminiGenerationConfig{CandidateCount: 2},
+		}
+		input, err := chatRequest(nil, "gemma", req, appConfig)
+		require.NoError(t, err)
+		assert.Equal(t, "gemma", input.Model)
+		assert.Equal(t, 2, input.N)
+		require.Len(t, input.Messages, 3)
+		assert.Equal(t, schema.Message{Role: "user", Content: "Hello\nHow are you?", StringContent: "Hello\nHow are you?"}, input.Messages[0])
+		assert.Equal(t, "assistant", input.Messages[1].Role)
+		assert.Equal(t, "Fine", input.Messages[1].StringContent)
+		assert.Equal(t, "user", input.Messages[2].Role, "the role is user by default")
+	})
+
+	t.Run("adds the system instruction first", func(t *testing.T) {
+		req := &schema.GeminiRequest{
+			SystemInstruction: &schema.GeminiContent{Parts: []schema.GeminiPart{{Text: "You are a pirate."}}},
+			Contents:          []schema.GeminiContent{{Role: "user", Parts: []schema.GeminiPart{{Text: "Hello"}}}},
+		}
+		input, err := chatRequest(nil, "gemma", req, appConfig)
+		require.NoError(t, err)
+		require.Len(t, input.Messages, 2)
+		assert.Equal(t, "system", input.Messages[0].Role)
+		assert.Equal(t, "You are a pirate.", input.Messages[0].StringContent)
+		assert.Equal(t, "user", input.Messages[1].Role)
+	})
+
+	t.Run("adds the inline images with a placeholder", func(t *testing.T) {
+		var img bytes.Buffer
+		require.NoError(t, png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+		data := base64.StdEncoding.EncodeToString(img.Bytes())
+
+		req := &schema.GeminiRequest{Contents: []schema.GeminiContent{{Role: "user", Parts: []schema.GeminiPart{
+			{Text: "What is it?"},
+			{InlineData: &schema.GeminiBlob{MimeType: "image/png", Data: data}},
+		}}}}
+		input, err := chatRequest(nil, "gemma", req, appConfig)
+		require.NoError(t, err)
+		require.Len(t, input.Messages, 1)
+		assert.Equal(t, "[img-0]What is it?", input.Messages[0].StringContent)
+		assert.Len(t, input.Messages[0].StringImages, 1)
+
+		req.Contents[0].Parts[1].InlineData.MimeType = "audio/wav"
+		_, err = chatRequest(nil, "gemma", req, appConfig)
+		var fiberErr *fiber.Error
+		require.ErrorAs(t, err, &fiberErr)
+		assert.Equal(t, fiber.StatusBadRequest, fiberErr.Code)
+	})
+
+	t.Run("refuses the requests without contents", func(t *testing.T) {
+		_, err := chatRequest(nil, "gemma", &schema.GeminiRequest{}, appConfig)
+		var fiberErr *fiber.Error
+		require.ErrorAs(t, err, &fiberErr)
+		assert.Equal(t, fiber.StatusBadRequest, fiberErr.Code)
+	})
+}
+
+func TestApplyGenerationConfig(t *testing.T) {
+	temperature, topP, topK, maxTokens, seed := 0.2, 0.9, 40, 128, 7
+	cfg := &config.BackendConfig{}
+	cfg.StopWords = []string{"</s>"}
+	applyGenerationConfig(cfg, schema.GeminiGenerationConfig{
+		StopSequences:    []string{"END"},
+		MaxOutputTokens:  &maxTokens,
+		Temperature:      &temperature,
+		TopP:             &topP,
+		TopK:             &topK,
+		Seed:             &seed,
+		PresencePenalty:  0.5,
+		FrequencyPenalty: 0.3,
+	})
+	assert.Equal(t, 0.2, *cfg.Temperature)
+	assert.Equal(t, 0.9, *cfg.TopP)
+	assert.Equal(t, 40, *cfg.TopK)
+	assert.Equal(t, 128, *cfg.Maxtokens)
+	assert.Equal(t, 7, *cfg.Seed)
+	assert.Equal(t, 0.5, cfg.PresencePenalty)
+	assert.Equal(t, 0.3, cfg.FrequencyPenalty)
+	assert.Equal(t, []string{"</s>", "END"}, cfg.StopWords)
+
+	// the settings of the model are kept when the request doesn't set them
+	applyGenerationConfig(cfg, schema.GeminiGenerationConfig{})
+	assert.Equal(t, 0.2, *cfg.Temperature)
+	assert.Equal(t, 0.5, cfg.PresencePenalty)
+	assert.Equal(t, []string{"</s>", "END"}, cfg.StopWords)
+}
+
+func TestWriteResponses(t *testing.T) {
+	write := func(sse bool) string {
+		responses := make(chan schema.GeminiResponse, 2)
+		responses <- schema.GeminiResponse{Candidates: []schema.GeminiCandidate{{Content: modelContent("", "Hel")}}}
+		responses <- schema.GeminiResponse{Candidates: []schema.GeminiCandidate{{Content: modelContent("", "lo"), FinishReason: finishReasonStop}}}
+		close(responses)
+
+		var out bytes.Buffer
+		writeResponses(bufio.NewWriter(&out), responses, sse, func() { t.Error("the stream was cancelled") })
+		return out.String()
+	}
+
+	t.Run("streams server-sent events with alt=sse", func(t *testing.T) {
+		events := strings.Split(strings.TrimSuffix(write(true), "\r\n\r\n"), "\r\n\r\n")
+		require.Len(t, events, 2)
+		for _, ev := range events {
+			require.True(t, strings.HasPrefix(ev, "data: "), ev)
+		}
+		resp := schema.GeminiResponse{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(events[1], "data: ")), &resp))
+		assert.Equal(t, "lo", resp.Candidates[0].Content.Parts[0].Text)
+		assert.Equal(t, finishReasonStop, resp.Candidates[0].FinishReason)
+	})
+
+	t.Run("streams a JSON array otherwise", func(t *testing.T) {
+		resps := []schema.GeminiResponse{}
+		require.NoError(t, json.Unmarshal([]byte(write(false)), &resps))
+		require.Len(t, resps, 2)
+		assert.Equal(t, "Hel", resps[0].Candidates[0].Content.Parts[0].Text)
+		assert.Equal(t, "model", resps[0].Candidates[0].Content.Role)
+	})
+}
+
+func TestModelContent(t *testing.T) {
+	assert.Equal(t, schema.GeminiContent{Role: "model", Parts: []schema.GeminiPart{{Text: "Hi"}}}, modelContent("", "Hi"))
+	assert.Equal(t, schema.GeminiContent{Role: "model", Parts: []schema.GeminiPart{{Text: "hmm", Thought: true}, {Text: "Hi"}}}, modelContent("hmm", "Hi"))
+	assert.Equal(t, schema.GeminiContent{Role: "model", Parts: []schema.GeminiPart{{Text: ""}}}, modelContent("", ""))
+}
+
+func TestGenerateContentEndpointMethods(t *testing.T) {
+	app := fiber.New()
+	app.Post("/v1beta/models/*", GenerateContentEndpoint(nil, nil, &config.ApplicationConfig{}))
+
+	for _, path := range []string{"/v1beta/models/gemma:countTokens", "/v1beta/models/gemma"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"contents": [{"parts": [{"text": "Hello"}]}]}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode, path)
+	}
+}
@@ -53,7 +53,13 @@ func JINARerankEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			modelFile = cfg.Model
 		}
 		log.Debug().Msgf("Request for model: %s", modelFile)
+		if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+			return err
+		}
 
+		if err := fiberContext.CheckBackendOverride(c, input.Backend); err != nil {
+			return err
+		}
 		if input.Backend != "" {
 			cfg.Backend = input.Backend
 		}
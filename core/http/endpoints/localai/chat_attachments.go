@@ -0,0 +1,125 @@
+package localai
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/scanner"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// chatAttachmentsDir is the directory, in the upload one, holding a directory for each conversation
+const chatAttachmentsDir = "chat"
+
+var validConversationID = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+func conversationDir(appConfig *config.ApplicationConfig, c *fiber.Ctx) (string, error) {
+	id := c.Params("conversation_id")
+	if !validConversationID.MatchString(id) {
+		return "", fiber.NewError(fiber.StatusBadRequest, "invalid conversation id")
+	}
+	return filepath.Join(appConfig.UploadDir, chatAttachmentsDir, id), nil
+}
+
+// UploadChatAttachmentEndpoint stores a file attached to a message of a conversation of the WebUI chat
+// @Summary Attach an image or an audio file to a chat conversation
+// @Param conversation_id path string true "Conversation ID"
+// @Param file formData file true "file"
+// @Success 200 {object} schema.ChatAttachment "Response"
+// @Router /chat/attachments/{conversation_id} [post]
+func UploadChatAttachmentEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		dir, err := conversationDir(appConfig, c)
+		if err != nil {
+			return err
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "file is required")
+		}
+		if file.Size > int64(appConfig.UploadLimitMB*1024*1024) {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("File size %d exceeds upload limit %d", file.Size, appConfig.UploadLimitMB))
+		}
+
+		contentType := file.Header.Get("Content-Type")
+		attachmentType, _, _ := strings.Cut(contentType, "/")
+		if attachmentType != "image" && attachmentType != "audio" {
+			return c.Status(fiber.StatusBadRequest).SendString("only images and audio files can be attached")
+		}
+		kind := scanner.KindImage
+		if attachmentType == "audio" {
+			kind = scanner.KindAudio
+		}
+		if err := fiberContext.ScanFormFile(c, appConfig, kind, file); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return err
+		}
+
+		attachment := schema.ChatAttachment{
+			ID:             uuid.New().String() + strings.ToLower(filepath.Ext(utils.SanitizeFileName(file.Filename))),
+			ConversationID: c.Params("conversation_id"),
+			Name:           file.Filename,
+			Type:           attachmentType,
+			ContentType:    contentType,
+			Bytes:          file.Size,
+		}
+		if err := c.SaveFile(file, filepath.Join(dir, attachment.ID)); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Failed to save file: " + err.Error())
+		}
+
+		return c.JSON(attachment)
+	}
+}
+
+// GetChatAttachmentEndpoint returns a file attached to a conversation of the WebUI chat
+// @Summary Get a file attached to a chat conversation
+// @Param conversation_id path string true "Conversation ID"
+// @Param attachment_id path string true "Attachment ID"
+// @Router /chat/attachments/{conversation_id}/{attachment_id} [get]
+func GetChatAttachmentEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		dir, err := conversationDir(appConfig, c)
+		if err != nil {
+			return err
+		}
+
+		name := c.Params("attachment_id")
+		if err := utils.VerifyPath(name, dir); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid attachment id")
+		}
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil || fi.IsDir() {
+			return fiber.NewError(fiber.StatusNotFound, "attachment not found")
+		}
+
+		return c.SendFile(filepath.Join(dir, name))
+	}
+}
+
+// DeleteChatAttachmentsEndpoint removes the files attached to a conversation of the WebUI chat
+// @Summary Delete the files attached to a chat conversation
+// @Param conversation_id path string true "Conversation ID"
+// @Router /chat/attachments/{conversation_id} [delete]
+func DeleteChatAttachmentsEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		dir, err := conversationDir(appConfig, c)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
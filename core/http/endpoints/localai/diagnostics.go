@@ -0,0 +1,47 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// ListDiagnosticsEndpoint returns the diagnostics bundles collected when backends crashed
+// @Summary List the diagnostics bundles of the backend crashes, the most recent first
+// @Success 200 {object} []schema.DiagnosticsBundle "Response"
+// @Router /diagnostics [get]
+func ListDiagnosticsEndpoint(diagnostics *services.DiagnosticsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		bundles, err := diagnostics.List()
+		if err != nil {
+			return err
+		}
+		return c.JSON(bundles)
+	}
+}
+
+// GetDiagnosticsEndpoint downloads a diagnostics bundle
+// @Summary Download a diagnostics bundle, to attach it to a bug report
+// @Param name path string true "Bundle name"
+// @Router /diagnostics/{name} [get]
+func GetDiagnosticsEndpoint(diagnostics *services.DiagnosticsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		path, err := diagnostics.Path(c.Params("name"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return c.Download(path)
+	}
+}
+
+// DeleteDiagnosticsEndpoint removes a diagnostics bundle
+// @Summary Delete a diagnostics bundle
+// @Param name path string true "Bundle name"
+// @Router /diagnostics/{name} [delete]
+func DeleteDiagnosticsEndpoint(diagnostics *services.DiagnosticsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if err := diagnostics.Delete(c.Params("name")); err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,73 @@
+package localai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/rs/zerolog/log"
+)
+
+// eventsKeepAlive is the interval of the comments keeping the event streams open through the proxies
+const eventsKeepAlive = 15 * time.Second
+
+// EventsEndpoint streams the events of LocalAI as server-sent events until the client disconnects: the models
+// loaded and unloaded, the watchdog actions, the gallery jobs, the config reloads and the p2p nodes. The types
+// parameter keeps the events of a comma-separated list of types or groups, e.g. model,job.failed
+// @Summary Streams the events of LocalAI as server-sent events.
+// @Param types query string false "comma-separated types or groups of the events"
+// @Success 200 {object} events.Event "Event"
+// @Router /events [get]
+func EventsEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		types := []string{}
+		for _, t := range strings.Split(c.Query("types"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		subscription, unsubscribe := appConfig.Events.Subscribe()
+		c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+			defer unsubscribe()
+			keepAlive := time.NewTicker(eventsKeepAlive)
+			defer keepAlive.Stop()
+
+			fmt.Fprint(w, ": connected\n\n")
+			for {
+				if err := w.Flush(); err != nil {
+					log.Debug().Err(err).Msg("event stream closed by the client")
+					return
+				}
+				select {
+				case e, ok := <-subscription:
+					if !ok {
+						return
+					}
+					if !e.Matches(types) {
+						continue
+					}
+					data, err := json.Marshal(e)
+					if err != nil {
+						log.Error().Err(err).Str("type", e.Type).Msg("unable to encode the event")
+						continue
+					}
+					fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+				case <-keepAlive.C:
+					fmt.Fprint(w, ": keep-alive\n\n")
+				case <-appConfig.Context.Done():
+					return
+				}
+			}
+		}))
+		return nil
+	}
+}
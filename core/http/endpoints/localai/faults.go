@@ -0,0 +1,80 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+func checkFaultsAdmin(c *fiber.Ctx) error {
+	if !fiberContext.IsAdmin(c) {
+		return fiber.NewError(fiber.StatusForbidden, "managing the fault rules requires an admin API key")
+	}
+	return nil
+}
+
+// ListFaultRulesEndpoint returns the fault rules, in the order they are matched
+// @Summary List the fault injection rules
+// @Success 200 {object} []schema.FaultRule "Response"
+// @Router /faults [get]
+func ListFaultRulesEndpoint(faults *services.FaultInjectionService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if err := checkFaultsAdmin(c); err != nil {
+			return err
+		}
+		return c.JSON(faults.List())
+	}
+}
+
+// AddFaultRuleEndpoint adds a fault rule, matched after the existing ones
+// @Summary Inject faults in the matching requests
+// @Param request body schema.FaultRule true "query params"
+// @Success 200 {object} schema.FaultRule "Response"
+// @Router /faults [post]
+func AddFaultRuleEndpoint(faults *services.FaultInjectionService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if err := checkFaultsAdmin(c); err != nil {
+			return err
+		}
+
+		request := schema.FaultRule{}
+		if err := c.BodyParser(&request); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Cannot parse JSON")
+		}
+		rule, err := faults.Add(request)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return c.JSON(rule)
+	}
+}
+
+// DeleteFaultRuleEndpoint removes a fault rule
+// @Summary Remove a fault injection rule
+// @Param id path string true "Rule ID"
+// @Router /faults/{id} [delete]
+func DeleteFaultRuleEndpoint(faults *services.FaultInjectionService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if err := checkFaultsAdmin(c); err != nil {
+			return err
+		}
+		if err := faults.Delete(c.Params("id")); err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// ClearFaultRulesEndpoint removes all the fault rules
+// @Summary Remove all the fault injection rules
+// @Router /faults [delete]
+func ClearFaultRulesEndpoint(faults *services.FaultInjectionService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if err := checkFaultsAdmin(c); err != nil {
+			return err
+		}
+		faults.Clear()
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -149,6 +149,43 @@ func (mgs *ModelGalleryEndpointService) ListModelGalleriesEndpoint() func(c *fib
 	}
 }
 
+// GalleriesHealthEndpoint checks the configured galleries in parallel: whether they are reachable, their index
+// is valid, and how many entries they gained or lost since the previous check
+// @Summary Check the health of the galleries
+// @Success 200 {object} map[string]interface{} "Whether all the galleries are healthy, and their []gallery.GalleryHealth"
+// @Router /models/galleries/health [get]
+func (mgs *ModelGalleryEndpointService) GalleriesHealthEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		health := mgs.galleryApplier.CheckGalleries(mgs.galleries)
+		healthy := true
+		for _, h := range health {
+			if !h.Healthy() {
+				log.Warn().Str("gallery", h.Name).Str("status", h.Status).Str("error", h.Error).Msg("gallery health check failed")
+				healthy = false
+			}
+		}
+		return c.JSON(fiber.Map{"healthy": healthy, "galleries": health})
+	}
+}
+
+// GalleryErrorsEndpoint reports the entries of the index of a gallery which can't be parsed, and are skipped
+// @Summary Report the invalid entries of a gallery
+// @Param name path string true "Gallery name"
+// @Success 200 {object} gallery.GalleryErrors "Response"
+// @Router /models/galleries/{name}/errors [get]
+func (mgs *ModelGalleryEndpointService) GalleryErrorsEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		i := slices.IndexFunc(mgs.galleries, func(g config.Gallery) bool {
+			return g.Name == name
+		})
+		if i < 0 {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find gallery %q", name))
+		}
+		return c.JSON(gallery.CheckGalleryEntries(mgs.galleries[i], mgs.modelPath))
+	}
+}
+
 // AddModelGalleryEndpoint adds a gallery in LocalAI
 // @Summary Adds a gallery in LocalAI
 // @Param request body config.Gallery true "Gallery details"
@@ -0,0 +1,21 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/functions"
+)
+
+// ListGrammarsEndpoint returns the grammars requests can reference by name
+// @Summary List the built-in grammars and the custom ones registered in the configs directory
+// @Success 200 {object} []functions.Grammar "Response"
+// @Router /v1/grammars [get]
+func ListGrammarsEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		grammars, err := functions.ListGrammars(appConfig.ConfigsDir)
+		if err != nil {
+			return err
+		}
+		return c.JSON(grammars)
+	}
+}
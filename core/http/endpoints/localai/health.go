@@ -0,0 +1,25 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// ReadyzEndpoint is the readiness probe. Models failing to preload do not
+// prevent LocalAI from being ready, but they are reported in the response
+// @Summary Readiness probe, with the models which failed to preload
+// @Success 200 {object} schema.ReadyzResponse "Response"
+// @Router /readyz [get]
+func ReadyzEndpoint(appConfig *config.ApplicationConfig) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if len(appConfig.PreloadFailures) == 0 {
+			return c.SendStatus(200)
+		}
+
+		return c.JSON(schema.ReadyzResponse{
+			Status:          "ok",
+			PreloadFailures: appConfig.PreloadFailures,
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package localai
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+var (
+	ImagePresets           = []schema.ImagePreset{}
+	ImagePresetsConfigFile = "image_presets.json"
+
+	imagePresetsMu sync.Mutex
+	imageSizeRe    = regexp.MustCompile(`^\d+x\d+$`)
+)
+
+// ListImagePresetsEndpoint returns the image generation presets
+// @Summary List the image generation presets, optionally filtered by model
+// @Param model query string false "Model name"
+// @Success 200 {object} []schema.ImagePreset "Response"
+// @Router /image/presets [get]
+func ListImagePresetsEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		modelName := c.Query("model")
+
+		imagePresetsMu.Lock()
+		defer imagePresetsMu.Unlock()
+
+		presets := []schema.ImagePreset{}
+		for _, p := range ImagePresets {
+			if modelName == "" || p.Model == modelName {
+				presets = append(presets, p)
+			}
+		}
+
+		return c.JSON(presets)
+	}
+}
+
+// SaveImagePresetEndpoint creates or replaces an image generation preset
+// @Summary Create or update an image generation preset for a model
+// @Param request body schema.ImagePreset true "query params"
+// @Success 200 {object} schema.ImagePreset "Response"
+// @Router /image/presets [post]
+func SaveImagePresetEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		preset := new(schema.ImagePreset)
+		if err := c.BodyParser(preset); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		if preset.Name == "" || preset.Model == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("name and model are required")
+		}
+		if preset.Size != "" && !imageSizeRe.MatchString(preset.Size) {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("invalid size %q, expected WIDTHxHEIGHT", preset.Size))
+		}
+		if preset.Step < 0 {
+			return c.Status(fiber.StatusBadRequest).SendString("step cannot be negative")
+		}
+
+		imagePresetsMu.Lock()
+		defer imagePresetsMu.Unlock()
+
+		replaced := false
+		for i, p := range ImagePresets {
+			if p.Model == preset.Model && p.Name == preset.Name {
+				ImagePresets[i] = *preset
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			ImagePresets = append(ImagePresets, *preset)
+		}
+
+		saveImagePresets(appConfig)
+		return c.JSON(preset)
+	}
+}
+
+// DeleteImagePresetEndpoint deletes an image generation preset
+// @Summary Delete an image generation preset
+// @Param model path string true "Model name"
+// @Param name path string true "Preset name"
+// @Success 200 {object} schema.ImagePreset "Response"
+// @Router /image/presets/{model}/{name} [delete]
+func DeleteImagePresetEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		modelName := c.Params("model")
+		name := c.Params("name")
+
+		imagePresetsMu.Lock()
+		defer imagePresetsMu.Unlock()
+
+		for i, p := range ImagePresets {
+			if p.Model == modelName && p.Name == name {
+				ImagePresets = append(ImagePresets[:i], ImagePresets[i+1:]...)
+				saveImagePresets(appConfig)
+				return c.JSON(p)
+			}
+		}
+
+		return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find preset %q for model %q", name, modelName))
+	}
+}
+
+// LoadImagePresets reads the presets of image_presets.json in the dynamic configuration directory
+func LoadImagePresets(appConfig *config.ApplicationConfig) {
+	if appConfig.DynamicConfigsDir != "" {
+		utils.LoadConfig(appConfig.DynamicConfigsDir, ImagePresetsConfigFile, &ImagePresets)
+	}
+}
+
+// saveImagePresets writes the presets in image_presets.json of the dynamic configuration directory, so that they're
+// kept across the restarts
+func saveImagePresets(appConfig *config.ApplicationConfig) {
+	if appConfig.DynamicConfigsDir != "" {
+		utils.SaveConfig(appConfig.DynamicConfigsDir, ImagePresetsConfigFile, ImagePresets)
+	}
+}
@@ -0,0 +1,134 @@
+package localai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startUpImagePresetsApp(t *testing.T) (*fiber.App, *config.ApplicationConfig) {
+	ImagePresets = []schema.ImagePreset{}
+	t.Cleanup(func() { ImagePresets = []schema.ImagePreset{} })
+
+	appConfig := &config.ApplicationConfig{DynamicConfigsDir: t.TempDir()}
+	app := fiber.New()
+	app.Get("/image/presets", ListImagePresetsEndpoint())
+	app.Post("/image/presets", SaveImagePresetEndpoint(appConfig))
+	app.Delete("/image/presets/:model/:name", DeleteImagePresetEndpoint(appConfig))
+	return app, appConfig
+}
+
+func savePreset(t *testing.T, app *fiber.App, body string) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, "/image/presets", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func listPresets(t *testing.T, app *fiber.App, query string) []schema.ImagePreset {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/image/presets"+query, nil), -1)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	presets := []schema.ImagePreset{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&presets))
+	return presets
+}
+
+func TestImagePresets(t *testing.T) {
+	t.Run("creates and replaces the presets in the dynamic configuration directory", func(t *testing.T) {
+		app, appConfig := startUpImagePresetsApp(t)
+
+		resp := savePreset(t, app, `{"name":"fast","model":"sd","step":10,"size":"512x512"}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		resp = savePreset(t, app, `{"name":"fast","model":"sd","step":5,"size":"256x256"}`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		presets := listPresets(t, app, "")
+		require.Len(t, presets, 1)
+		assert.Equal(t, 5, presets[0].Step)
+		assert.Equal(t, "256x256", presets[0].Size)
+
+		dat, err := os.ReadFile(filepath.Join(appConfig.DynamicConfigsDir, ImagePresetsConfigFile))
+		require.NoError(t, err)
+		saved := []schema.ImagePreset{}
+		require.NoError(t, json.Unmarshal(dat, &saved))
+		assert.Equal(t, presets, saved)
+
+		// the presets are loaded back at startup
+		ImagePresets = []schema.ImagePreset{}
+		LoadImagePresets(appConfig)
+		assert.Equal(t, presets, ImagePresets)
+	})
+
+	t.Run("refuses the invalid presets", func(t *testing.T) {
+		app, _ := startUpImagePresetsApp(t)
+
+		for _, body := range []string{
+			`{"model":"sd"}`,
+			`{"name":"fast"}`,
+			`{"name":"fast","model":"sd","size":"large"}`,
+			`{"name":"fast","model":"sd","step":-1}`,
+		} {
+			resp := savePreset(t, app, body)
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, body)
+		}
+		assert.Empty(t, listPresets(t, app, ""))
+	})
+
+	t.Run("lists the presets of the model compared in the grid", func(t *testing.T) {
+		app, _ := startUpImagePresetsApp(t)
+
+		savePreset(t, app, `{"name":"fast","model":"sd","step":10}`)
+		savePreset(t, app, `{"name":"detailed","model":"sd","step":50,"seed":42,"negative_prompt":"blurry"}`)
+		savePreset(t, app, `{"name":"fast","model":"flux","step":4}`)
+
+		presets := listPresets(t, app, "?model=sd")
+		require.Len(t, presets, 2)
+		assert.Equal(t, "fast", presets[0].Name)
+		assert.Equal(t, "detailed", presets[1].Name)
+		// the grid generates every preset with the same seed, unless the preset sets its own
+		require.NotNil(t, presets[1].Seed)
+		assert.Equal(t, 42, *presets[1].Seed)
+		assert.Equal(t, "blurry", presets[1].NegativePrompt)
+
+		assert.Len(t, listPresets(t, app, ""), 3)
+		assert.Empty(t, listPresets(t, app, "?model=unknown"))
+	})
+
+	t.Run("deletes a preset", func(t *testing.T) {
+		app, appConfig := startUpImagePresetsApp(t)
+
+		savePreset(t, app, `{"name":"fast","model":"sd"}`)
+		savePreset(t, app, `{"name":"fast","model":"flux"}`)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodDelete, "/image/presets/sd/fast", nil), -1)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		presets := listPresets(t, app, "")
+		require.Len(t, presets, 1)
+		assert.Equal(t, "flux", presets[0].Model)
+
+		dat, err := os.ReadFile(filepath.Join(appConfig.DynamicConfigsDir, ImagePresetsConfigFile))
+		require.NoError(t, err)
+		assert.NotContains(t, string(dat), `"sd"`)
+
+		resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/image/presets/sd/fast", nil), -1)
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "Unable to find preset")
+	})
+}
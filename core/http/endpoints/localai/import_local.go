@@ -0,0 +1,43 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// ImportLocalModelEndpoint registers a model file which is already on disk, without downloading it
+// @Summary Install a model from a file on the LocalAI host, linking, copying or moving it into the models directory
+// @Param request body schema.ImportLocalModelRequest true "query params"
+// @Success 200 {object} config.BackendConfig "Response"
+// @Router /models/import-local [post]
+func ImportLocalModelEndpoint(cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.ImportLocalModelRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+		if input.Path == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "path is required")
+		}
+
+		name, err := gallery.ImportLocalModel(appConfig.ModelPath, input.Path, input.Name, gallery.LinkMode(input.Mode))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		if err := cl.LoadBackendConfigsFromPath(appConfig.ModelPath); err != nil {
+			return err
+		}
+		if err := cl.Preload(appConfig.ModelPath); err != nil {
+			return err
+		}
+
+		backendConfig, exists := cl.GetBackendConfig(name)
+		if !exists {
+			return fiber.NewError(fiber.StatusInternalServerError, "model imported but its config could not be loaded")
+		}
+		return c.JSON(backendConfig)
+	}
+}
@@ -0,0 +1,138 @@
+package localai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/scanner"
+	"github.com/rs/zerolog/log"
+)
+
+// MeetingNotesEndpoint transcribes the recording of a meeting and takes its notes with an LLM in a single request,
+// streaming the transcription and the notes as server-sent events as they complete when stream is true
+// @Summary Transcribes a meeting and writes its summary and action items.
+// @accept multipart/form-data
+// @Param model formData string true "transcription model"
+// @Param notes_model formData string true "model taking the notes"
+// @Param file formData file true "file"
+// @Success 200 {object} schema.MeetingNotesResponse "Response"
+// @Router /v1/audio/notes [post]
+func MeetingNotesEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	notesService := services.NewMeetingNotesService(ml, appConfig)
+	return func(c *fiber.Ctx) error {
+		transcriptionConfig, err := meetingNotesModel(c, cl, appConfig, "model")
+		if err != nil {
+			return err
+		}
+		notesConfig, err := meetingNotesModel(c, cl, appConfig, "notes_model")
+		if err != nil {
+			return err
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			return err
+		}
+		if err := fiberContext.ScanFormFile(c, appConfig, scanner.KindAudio, file); err != nil {
+			return err
+		}
+		dir, err := os.MkdirTemp("", "meeting")
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, path.Base(file.Filename))
+		if err := c.SaveFile(file, dst); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+		language := c.FormValue("language")
+
+		if c.FormValue("stream") != "true" {
+			defer os.RemoveAll(dir)
+			transcription, err := backend.ModelTranscription(dst, language, false, ml, *transcriptionConfig, appConfig)
+			if err != nil {
+				return err
+			}
+			notes, err := notesService.Notes(c.UserContext(), notesConfig, transcription)
+			if err != nil {
+				return err
+			}
+			return c.JSON(schema.MeetingNotesResponse{
+				Model:         transcriptionConfig.Name,
+				NotesModel:    notesConfig.Name,
+				Transcription: transcription,
+				Notes:         notes,
+			})
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+			defer os.RemoveAll(dir)
+			send := func(event schema.MeetingNotesEvent) bool {
+				data, err := json.Marshal(event)
+				if err != nil {
+					return false
+				}
+				if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+					log.Debug().Err(err).Msg("meeting notes stream closed by the client")
+					return false
+				}
+				return w.Flush() == nil
+			}
+
+			transcription, err := backend.ModelTranscription(dst, language, false, ml, *transcriptionConfig, appConfig)
+			if err != nil {
+				send(schema.MeetingNotesEvent{Type: "error", Error: err.Error()})
+				return
+			}
+			if !send(schema.MeetingNotesEvent{Type: "transcription", Transcription: transcription}) {
+				return
+			}
+			notes, err := notesService.Notes(appConfig.Context, notesConfig, transcription)
+			if err != nil {
+				send(schema.MeetingNotesEvent{Type: "error", Error: err.Error()})
+				return
+			}
+			if !send(schema.MeetingNotesEvent{Type: "notes", Notes: notes}) {
+				return
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			w.Flush()
+		}))
+		return nil
+	}
+}
+
+// meetingNotesModel returns the config of the model of the form field, checking that the request may use it
+func meetingNotesModel(c *fiber.Ctx, cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig, field string) (*config.BackendConfig, error) {
+	name := c.FormValue(field)
+	if name == "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s is required", field))
+	}
+	cfg, err := cl.LoadBackendConfigFileByName(name, appConfig.ModelPath,
+		config.LoadOptionDebug(appConfig.Debug),
+		config.LoadOptionThreads(appConfig.Threads),
+		config.LoadOptionContextSize(appConfig.ContextSize),
+		config.LoadOptionF16(appConfig.F16),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,91 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// ListMemoriesEndpoint returns the memories of a user
+// @Summary List the facts remembered about a user
+// @Param user query string true "User"
+// @Success 200 {object} []schema.Memory "Response"
+// @Router /memories [get]
+func ListMemoriesEndpoint(memories *services.MemoryService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		user := c.Query("user")
+		if user == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("user is required")
+		}
+
+		return c.JSON(memories.List(user))
+	}
+}
+
+// CreateMemoryEndpoint stores a new memory for a user
+// @Summary Remember a fact about a user
+// @Param request body schema.MemoryRequest true "query params"
+// @Success 200 {object} schema.Memory "Response"
+// @Router /memories [post]
+func CreateMemoryEndpoint(memories *services.MemoryService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(schema.MemoryRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		if request.User == "" || request.Content == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("user and content are required")
+		}
+
+		return c.JSON(memories.Add(request.User, request.Content))
+	}
+}
+
+// ModifyMemoryEndpoint edits a memory of a user
+// @Summary Edit a fact remembered about a user
+// @Param memory_id path string true "Memory ID"
+// @Param request body schema.MemoryRequest true "query params"
+// @Success 200 {object} schema.Memory "Response"
+// @Router /memories/{memory_id} [post]
+func ModifyMemoryEndpoint(memories *services.MemoryService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(schema.MemoryRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		if request.User == "" || request.Content == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("user and content are required")
+		}
+
+		m, err := memories.Update(request.User, c.Params("memory_id"), request.Content)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+
+		return c.JSON(m)
+	}
+}
+
+// DeleteMemoryEndpoint deletes a memory of a user
+// @Summary Forget a fact remembered about a user
+// @Param memory_id path string true "Memory ID"
+// @Param user query string true "User"
+// @Success 200 {object} schema.Memory "Response"
+// @Router /memories/{memory_id} [delete]
+func DeleteMemoryEndpoint(memories *services.MemoryService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		user := c.Query("user")
+		if user == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("user is required")
+		}
+
+		m, err := memories.Delete(user, c.Params("memory_id"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+
+		return c.JSON(m)
+	}
+}
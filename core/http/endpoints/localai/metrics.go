@@ -5,6 +5,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -40,7 +41,7 @@ func LocalAIMetricsAPIMiddleware(metrics *services.LocalAIMetricsService) fiber.
 		start := time.Now()
 		err := c.Next()
 		elapsed := float64(time.Since(start)) / float64(time.Second)
-		cfg.metricsService.ObserveAPICall(method, path, elapsed)
+		cfg.metricsService.ObserveAPICall(method, path, fiberContext.UserFromContext(c), elapsed)
 		return err
 	}
 }
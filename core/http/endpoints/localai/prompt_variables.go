@@ -0,0 +1,113 @@
+package localai
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+var (
+	PromptVariablesConfigFile = "prompt_variables.json"
+
+	promptVariablesMu sync.Mutex
+	variableNameRe    = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+// ListPromptVariablesEndpoint returns the prompt variables
+// @Summary List the prompt variables
+// @Success 200 {object} []schema.PromptVariable "Response"
+// @Router /variables [get]
+func ListPromptVariablesEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		variables := []schema.PromptVariable{}
+		for name, value := range templates.Variables() {
+			variables = append(variables, schema.PromptVariable{Name: name, Value: value})
+		}
+		slices.SortFunc(variables, func(a, b schema.PromptVariable) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		return c.JSON(variables)
+	}
+}
+
+// GetPromptVariableEndpoint returns a prompt variable
+// @Summary Get a prompt variable
+// @Param name path string true "Variable name"
+// @Success 200 {object} schema.PromptVariable "Response"
+// @Router /variables/{name} [get]
+func GetPromptVariableEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		value, err := templates.Variable(name)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return c.JSON(schema.PromptVariable{Name: name, Value: value})
+	}
+}
+
+// SetPromptVariableEndpoint creates or replaces a prompt variable, used by the next requests
+// @Summary Create or update a prompt variable
+// @Param request body schema.PromptVariable true "query params"
+// @Success 200 {object} schema.PromptVariable "Response"
+// @Router /variables [post]
+func SetPromptVariableEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		variable := new(schema.PromptVariable)
+		if err := c.BodyParser(variable); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+		if !variableNameRe.MatchString(variable.Name) {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("invalid name %q, the names are made of letters, digits, '_', '.' and '-'", variable.Name))
+		}
+
+		promptVariablesMu.Lock()
+		defer promptVariablesMu.Unlock()
+
+		variables := templates.Variables()
+		variables[variable.Name] = variable.Value
+		templates.SetVariables(variables)
+		savePromptVariables(appConfig, variables)
+		return c.JSON(variable)
+	}
+}
+
+// DeletePromptVariableEndpoint deletes a prompt variable
+// @Summary Delete a prompt variable
+// @Param name path string true "Variable name"
+// @Success 200 {object} schema.PromptVariable "Response"
+// @Router /variables/{name} [delete]
+func DeletePromptVariableEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+
+		promptVariablesMu.Lock()
+		defer promptVariablesMu.Unlock()
+
+		variables := templates.Variables()
+		value, exists := variables[name]
+		if !exists {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find variable %q", name))
+		}
+		delete(variables, name)
+		templates.SetVariables(variables)
+		savePromptVariables(appConfig, variables)
+		return c.JSON(schema.PromptVariable{Name: name, Value: value})
+	}
+}
+
+// savePromptVariables writes the variables in prompt_variables.json of the dynamic configuration directory, so that
+// they're kept across the restarts
+func savePromptVariables(appConfig *config.ApplicationConfig, variables map[string]string) {
+	if appConfig.DynamicConfigsDir != "" {
+		utils.SaveConfig(appConfig.DynamicConfigsDir, PromptVariablesConfigFile, variables)
+	}
+}
@@ -0,0 +1,17 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// RecommendationsEndpoint returns the actions suggested on the installed models from their usage and the hardware,
+// each with the API call taking it
+// @Summary Get the suggested actions on the installed models
+// @Success 200 {object} []schema.Recommendation "Response"
+// @Router /models/recommendations [get]
+func RecommendationsEndpoint(rs *services.RecommendationService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(rs.Recommendations())
+	}
+}
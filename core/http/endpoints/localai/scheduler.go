@@ -0,0 +1,49 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// ListScheduledTasksEndpoint returns the scheduled tasks, with their next and last runs
+// @Summary List the scheduled tasks
+// @Success 200 {object} []schema.ScheduledTaskStatus "Response"
+// @Router /jobs/scheduled [get]
+func ListScheduledTasksEndpoint(scheduler *services.SchedulerService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(scheduler.Tasks())
+	}
+}
+
+// GetScheduledTaskRunsEndpoint returns the history of the runs of a scheduled task
+// @Summary List the runs of a scheduled task, the most recent first
+// @Param name path string true "Task name"
+// @Success 200 {object} []schema.ScheduledTaskRun "Response"
+// @Router /jobs/scheduled/{name} [get]
+func GetScheduledTaskRunsEndpoint(scheduler *services.SchedulerService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		runs, err := scheduler.Runs(c.Params("name"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return c.JSON(runs)
+	}
+}
+
+// RunScheduledTaskEndpoint runs a scheduled task now, without waiting for its schedule
+// @Summary Run a scheduled task now
+// @Param name path string true "Task name"
+// @Router /jobs/scheduled/{name}/run [post]
+func RunScheduledTaskEndpoint(scheduler *services.SchedulerService, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("name")
+		if _, err := scheduler.Runs(name); err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		if err := scheduler.Trigger(appConfig.Context, name); err != nil {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		return c.SendStatus(fiber.StatusAccepted)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/model"
-	"github.com/mudler/LocalAI/pkg/store"
 )
 
 func StoresSetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
@@ -17,7 +16,7 @@ func StoresSetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store)
+		sd, err := backend.StoreDriver(sl, appConfig, input.Store)
 		if err != nil {
 			return err
 		}
@@ -27,7 +26,7 @@ func StoresSetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 			vals[i] = []byte(v)
 		}
 
-		err = store.SetCols(c.Context(), sb, input.Keys, vals)
+		err = sd.Set(c.Context(), input.Keys, vals)
 		if err != nil {
 			return err
 		}
@@ -44,12 +43,12 @@ func StoresDeleteEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationCo
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store)
+		sd, err := backend.StoreDriver(sl, appConfig, input.Store)
 		if err != nil {
 			return err
 		}
 
-		if err := store.DeleteCols(c.Context(), sb, input.Keys); err != nil {
+		if err := sd.Delete(c.Context(), input.Keys); err != nil {
 			return err
 		}
 
@@ -65,12 +64,12 @@ func StoresGetEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConfi
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store)
+		sd, err := backend.StoreDriver(sl, appConfig, input.Store)
 		if err != nil {
 			return err
 		}
 
-		keys, vals, err := store.GetCols(c.Context(), sb, input.Keys)
+		keys, vals, err := sd.Get(c.Context(), input.Keys)
 		if err != nil {
 			return err
 		}
@@ -96,12 +95,12 @@ func StoresFindEndpoint(sl *model.ModelLoader, appConfig *config.ApplicationConf
 			return err
 		}
 
-		sb, err := backend.StoreBackend(sl, appConfig, input.Store)
+		sd, err := backend.StoreDriver(sl, appConfig, input.Store)
 		if err != nil {
 			return err
 		}
 
-		keys, vals, similarities, err := store.Find(c.Context(), sb, input.Key, input.Topk)
+		keys, vals, similarities, err := sd.Find(c.Context(), input.Key, input.Topk)
 		if err != nil {
 			return err
 		}
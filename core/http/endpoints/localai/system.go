@@ -0,0 +1,26 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/internal"
+)
+
+// SystemEndpoint describes the instance, with the outbound connections blocked by the privacy mode
+// @Summary Show the version and the privacy mode of LocalAI
+// @Success 200 {object} schema.SystemResponse "Response"
+// @Router /system [get]
+func SystemEndpoint(appConfig *config.ApplicationConfig) func(*fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		resp := schema.SystemResponse{
+			Version:     internal.PrintableVersion(),
+			PrivacyMode: appConfig.NetworkGuard != nil,
+		}
+		if appConfig.NetworkGuard != nil {
+			report := appConfig.NetworkGuard.Report()
+			resp.NetworkAudit = &report
+		}
+		return c.JSON(resp)
+	}
+}
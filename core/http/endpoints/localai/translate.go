@@ -0,0 +1,72 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// TranslateEndpoint translates texts, routed to a model translating their language pair when no model is requested
+// @Summary Translates the input texts into the target language.
+// @Param request body schema.TranslationRequest true "query params"
+// @Success 200 {object} schema.TranslationResponse "Response"
+// @Router /v1/translate [post]
+func TranslateEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	translationService := services.NewTranslationService(cl, ml, appConfig)
+	return func(c *fiber.Ctx) error {
+		input := new(schema.TranslationRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+		if input.TargetLanguage == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "target_language is required")
+		}
+
+		var texts []string
+		switch i := input.Input.(type) {
+		case string:
+			texts = []string{i}
+		case []interface{}:
+			for _, t := range i {
+				text, ok := t.(string)
+				if !ok {
+					return fiber.NewError(fiber.StatusBadRequest, "input must be a text or a list of texts")
+				}
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "input is required")
+		}
+
+		response := schema.TranslationResponse{Object: "list"}
+		for index, text := range texts {
+			source, detected := services.DetectSource(text, input.SourceLanguage)
+			cfg, err := translationService.Route(input.Model, source, input.TargetLanguage)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+				return err
+			}
+
+			log.Debug().Str("model", cfg.Name).Str("source", source).Str("target", input.TargetLanguage).Msg("translation request")
+			translation, err := translationService.Translate(c.UserContext(), cfg, text, source, input.TargetLanguage, input.Glossary)
+			if err != nil {
+				return err
+			}
+			response.Data = append(response.Data, schema.Translation{
+				Index:                  index,
+				Text:                   translation,
+				Model:                  cfg.Name,
+				SourceLanguage:         source,
+				DetectedSourceLanguage: detected,
+			})
+		}
+		return c.JSON(response)
+	}
+}
@@ -1,10 +1,14 @@
 package localai
 
 import (
+	"bufio"
+	"fmt"
+
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/sound"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/core/schema"
@@ -50,7 +54,13 @@ func TTSEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfi
 			modelFile = cfg.Model
 		}
 		log.Debug().Msgf("Request for model: %s", modelFile)
+		if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+			return err
+		}
 
+		if err := fiberContext.CheckBackendOverride(c, input.Backend); err != nil {
+			return err
+		}
 		if input.Backend != "" {
 			cfg.Backend = input.Backend
 		}
@@ -63,10 +73,54 @@ func TTSEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfi
 			cfg.Voice = input.Voice
 		}
 
+		if input.Stream {
+			return streamTTS(c, input, modelFile, ml, appConfig, *cfg)
+		}
+		if input.ResponseFormat == "pcm" {
+			return fiber.NewError(fiber.StatusBadRequest, "the pcm response format requires stream")
+		}
+
 		filePath, _, err := backend.ModelTTS(cfg.Backend, input.Input, modelFile, cfg.Voice, cfg.Language, ml, appConfig, *cfg)
 		if err != nil {
 			return err
 		}
-		return c.Download(filePath)
+		return fiberContext.SendGeneratedAudio(c, appConfig, filePath)
+	}
+}
+
+// streamTTS sends the audio of the request while it's synthesized, each chunk being flushed as soon as the backend
+// returns it, so that the clients start the playback before the end of the synthesis
+func streamTTS(c *fiber.Ctx, input *schema.TTSRequest, modelFile string, ml *model.ModelLoader, appConfig *config.ApplicationConfig, cfg config.BackendConfig) error {
+	switch input.ResponseFormat {
+	case "", "wav":
+		c.Set("Content-Type", "audio/wav")
+	case "pcm":
+		c.Set("Content-Type", "audio/pcm")
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unsupported response format %q for the streamed audio, use wav or pcm", input.ResponseFormat))
 	}
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Transfer-Encoding", "chunked")
+
+	c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+		headerSent := input.ResponseFormat == "pcm"
+		err := backend.ModelTTSStream(appConfig.Context, cfg.Backend, input.Input, modelFile, cfg.Voice, cfg.Language, ml, appConfig, cfg,
+			func(audio []byte, sampleRate int) error {
+				// the sample rate is only known with the first chunk
+				if !headerSent {
+					if _, err := w.Write(sound.WAVStreamHeader(sampleRate)); err != nil {
+						return err
+					}
+					headerSent = true
+				}
+				if _, err := w.Write(audio); err != nil {
+					return err
+				}
+				return w.Flush()
+			})
+		if err != nil {
+			log.Error().Err(err).Str("model", modelFile).Msg("audio stream interrupted")
+		}
+	}))
+	return nil
 }
@@ -0,0 +1,44 @@
+package localai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// UsageEndpoint returns the requests and the tokens used per API key and per model. The admin keys get the usage of
+// all the keys, the other keys only their own
+// @Summary Get the token usage per API key and per model
+// @Param api_key query string false "ID of the API key, as in the audit log (admin keys only)"
+// @Param model query string false "Only the usage of this model"
+// @Param since query string false "Start of the period: unix seconds, RFC3339 or YYYY-MM-DD"
+// @Param until query string false "End of the period, excluded: unix seconds, RFC3339 or YYYY-MM-DD"
+// @Param window query string false "Split the usage by hour, day or month (UTC)"
+// @Success 200 {object} schema.UsageResponse "Response"
+// @Router /v1/usage [get]
+func UsageEndpoint(usage *services.UsageService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		filter := services.UsageFilter{
+			APIKey: c.Query("api_key"),
+			Model:  c.Query("model"),
+			Window: c.Query("window"),
+		}
+		if !fiberContext.IsAdmin(c) {
+			filter.APIKey = fiberContext.APIKeyIDFromContext(c)
+		}
+		var err error
+		if filter.Since, err = services.ParseTimeBound(c.Query("since")); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if filter.Until, err = services.ParseTimeBound(c.Query("until")); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		entries, err := usage.Usage(filter)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return c.JSON(schema.UsageResponse{Object: "list", Data: entries})
+	}
+}
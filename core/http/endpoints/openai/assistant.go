@@ -1,7 +1,9 @@
 package openai
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"sort"
 	"strconv"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
@@ -24,6 +27,7 @@ type ToolType string
 const (
 	CodeInterpreter ToolType = "code_interpreter"
 	Retrieval       ToolType = "retrieval"
+	FileSearch      ToolType = "file_search"
 	Function        ToolType = "function"
 
 	MaxCharacterInstructions  = 32768
@@ -39,18 +43,29 @@ type Tool struct {
 	Type ToolType `json:"type"`
 }
 
+// ToolResources are the resources used by the assistant tools
+type ToolResources struct {
+	FileSearch *FileSearchResources `json:"file_search,omitempty"`
+}
+
+type FileSearchResources struct {
+	// VectorStoreIDs are the names of the stores (internal or external vector databases) where the files are indexed
+	VectorStoreIDs []string `json:"vector_store_ids,omitempty"`
+}
+
 // Assistant represents the structure of an assistant object from the OpenAI API.
 type Assistant struct {
-	ID           string            `json:"id"`                     // The unique identifier of the assistant.
-	Object       string            `json:"object"`                 // Object type, which is "assistant".
-	Created      int64             `json:"created"`                // The time at which the assistant was created.
-	Model        string            `json:"model"`                  // The model ID used by the assistant.
-	Name         string            `json:"name,omitempty"`         // The name of the assistant.
-	Description  string            `json:"description,omitempty"`  // The description of the assistant.
-	Instructions string            `json:"instructions,omitempty"` // The system instructions that the assistant uses.
-	Tools        []Tool            `json:"tools,omitempty"`        // A list of tools enabled on the assistant.
-	FileIDs      []string          `json:"file_ids,omitempty"`     // A list of file IDs attached to this assistant.
-	Metadata     map[string]string `json:"metadata,omitempty"`     // Set of key-value pairs attached to the assistant.
+	ID            string            `json:"id"`                       // The unique identifier of the assistant.
+	Object        string            `json:"object"`                   // Object type, which is "assistant".
+	Created       int64             `json:"created"`                  // The time at which the assistant was created.
+	Model         string            `json:"model"`                    // The model ID used by the assistant.
+	Name          string            `json:"name,omitempty"`           // The name of the assistant.
+	Description   string            `json:"description,omitempty"`    // The description of the assistant.
+	Instructions  string            `json:"instructions,omitempty"`   // The system instructions that the assistant uses.
+	Tools         []Tool            `json:"tools,omitempty"`          // A list of tools enabled on the assistant.
+	FileIDs       []string          `json:"file_ids,omitempty"`       // A list of file IDs attached to this assistant.
+	Metadata      map[string]string `json:"metadata,omitempty"`       // Set of key-value pairs attached to the assistant.
+	ToolResources *ToolResources    `json:"tool_resources,omitempty"` // Resources used by the assistant's tools.
 }
 
 var (
@@ -59,13 +74,14 @@ var (
 )
 
 type AssistantRequest struct {
-	Model        string            `json:"model"`
-	Name         string            `json:"name,omitempty"`
-	Description  string            `json:"description,omitempty"`
-	Instructions string            `json:"instructions,omitempty"`
-	Tools        []Tool            `json:"tools,omitempty"`
-	FileIDs      []string          `json:"file_ids,omitempty"`
-	Metadata     map[string]string `json:"metadata,omitempty"`
+	Model         string            `json:"model"`
+	Name          string            `json:"name,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	Instructions  string            `json:"instructions,omitempty"`
+	Tools         []Tool            `json:"tools,omitempty"`
+	FileIDs       []string          `json:"file_ids,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	ToolResources *ToolResources    `json:"tool_resources,omitempty"`
 }
 
 // CreateAssistantEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/assistants/createAssistant
@@ -101,16 +117,17 @@ func CreateAssistantEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoad
 		id := "asst_" + strconv.FormatInt(generateRandomID(), 10)
 
 		assistant := Assistant{
-			ID:           id,
-			Object:       "assistant",
-			Created:      time.Now().Unix(),
-			Model:        request.Model,
-			Name:         request.Name,
-			Description:  request.Description,
-			Instructions: request.Instructions,
-			Tools:        request.Tools,
-			FileIDs:      request.FileIDs,
-			Metadata:     request.Metadata,
+			ID:            id,
+			Object:        "assistant",
+			Created:       time.Now().Unix(),
+			Model:         request.Model,
+			Name:          request.Name,
+			Description:   request.Description,
+			Instructions:  request.Instructions,
+			Tools:         request.Tools,
+			FileIDs:       request.FileIDs,
+			Metadata:      request.Metadata,
+			ToolResources: request.ToolResources,
 		}
 
 		Assistants = append(Assistants, assistant)
@@ -304,7 +321,7 @@ var (
 	AssistantsFileConfigFile = "assistantsFile.json"
 )
 
-func CreateAssistantFileEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func CreateAssistantFileEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
 		request := new(schema.AssistantFileRequest)
 		if err := c.BodyParser(request); err != nil {
@@ -324,6 +341,10 @@ func CreateAssistantFileEndpoint(cl *config.BackendConfigLoader, ml *model.Model
 
 				for _, file := range UploadedFiles {
 					if file.ID == request.FileID {
+						if err := indexAssistantFile(c.Context(), cl, ml, sl, appConfig, assistant, file); err != nil {
+							return c.Status(fiber.StatusInternalServerError).SendString(fmt.Sprintf("Unable to index file_id %s: %v", file.ID, err))
+						}
+
 						assistant.FileIDs = append(assistant.FileIDs, request.FileID)
 						assistantFile := AssistantFile{
 							ID:          file.ID,
@@ -345,6 +366,41 @@ func CreateAssistantFileEndpoint(cl *config.BackendConfigLoader, ml *model.Model
 	}
 }
 
+// indexAssistantFile indexes the file in the assistant's vector stores when file_search is enabled
+func indexAssistantFile(ctx context.Context, cl *config.BackendConfigLoader, ml *model.ModelLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig, assistant Assistant, file schema.File) error {
+	if assistant.ToolResources == nil || assistant.ToolResources.FileSearch == nil {
+		return nil
+	}
+
+	enabled := false
+	for _, tool := range assistant.Tools {
+		if tool.Type == FileSearch || tool.Type == Retrieval {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return nil
+	}
+
+	r, err := appConfig.UploadStorage().Get(ctx, utils.SanitizeFileName(file.Filename))
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, storeName := range assistant.ToolResources.FileSearch.VectorStoreIDs {
+		if err := backend.FileSearchIngest(ctx, sl, ml, cl, appConfig, storeName, file.ID, content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func ListAssistantFilesEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	type ListAssistantFiles struct {
 		Data   []schema.File
@@ -423,16 +479,17 @@ func ModifyAssistantEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoad
 		for i, assistant := range Assistants {
 			if assistant.ID == assistantID {
 				newAssistant := Assistant{
-					ID:           assistantID,
-					Object:       assistant.Object,
-					Created:      assistant.Created,
-					Model:        request.Model,
-					Name:         request.Name,
-					Description:  request.Description,
-					Instructions: request.Instructions,
-					Tools:        request.Tools,
-					FileIDs:      request.FileIDs, // todo: should probably verify fileids exist
-					Metadata:     request.Metadata,
+					ID:            assistantID,
+					Object:        assistant.Object,
+					Created:       assistant.Created,
+					Model:         request.Model,
+					Name:          request.Name,
+					Description:   request.Description,
+					Instructions:  request.Instructions,
+					Tools:         request.Tools,
+					FileIDs:       request.FileIDs, // todo: should probably verify fileids exist
+					Metadata:      request.Metadata,
+					ToolResources: request.ToolResources,
 				}
 
 				// Remove old one and replace with new one
@@ -0,0 +1,705 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+const BatchesConfigFile = "batches.json"
+
+// The statuses of the batches, https://platform.openai.com/docs/guides/batch#4-check-the-status-of-a-batch
+const (
+	BatchValidating = "validating"
+	BatchFailed     = "failed"
+	BatchInProgress = "in_progress"
+	BatchFinalizing = "finalizing"
+	BatchCompleted  = "completed"
+	BatchExpired    = "expired"
+	BatchCancelling = "cancelling"
+	BatchCancelled  = "cancelled"
+)
+
+const (
+	// batchCompletionWindow is the only completion window of the batches, after which the requests not run are expired
+	batchCompletionWindow = "24h"
+	batchWindow           = 24 * time.Hour
+	// batchMaxLine is the size of the largest line of the input files
+	batchMaxLine = 16 * 1024 * 1024
+	// batchPurpose is the purpose of the input files of the batches, and of their output files
+	batchPurpose       = "batch"
+	batchOutputPurpose = "batch_output"
+)
+
+// batchCheckpointEvery is the number of requests after which the results of a batch are checkpointed
+var batchCheckpointEvery = 100
+
+// batchEndpoints are the endpoints the requests of the batches can be sent to
+var batchEndpoints = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings"}
+
+var (
+	Batches      []schema.Batch
+	batchesMu    sync.Mutex
+	batchCancels = map[string]context.CancelFunc{}
+
+	// batchSlots bounds the requests running at once across all the batches, sized on the first batch
+	batchSlots     chan struct{}
+	batchSlotsOnce sync.Once
+)
+
+// batchInterrupted is the error of the batches stopped with LocalAI, which can be resumed
+const batchInterrupted = "interrupted"
+
+// LoadBatches loads the batches of batches.json. The batches still running when LocalAI stopped are failed, keeping
+// their checkpoint: they are resumed with /v1/batches/{batch_id}/resume, as their requests are sent with the API key
+// of the client, which isn't saved
+func LoadBatches(appConfig *config.ApplicationConfig) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	utils.LoadConfig(appConfig.ConfigsDir, BatchesConfigFile, &Batches)
+	for i, b := range Batches {
+		switch b.Status {
+		case BatchValidating, BatchInProgress, BatchFinalizing, BatchCancelling:
+			interruptBatch(&Batches[i])
+		}
+	}
+	utils.SaveConfig(appConfig.ConfigsDir, BatchesConfigFile, Batches)
+}
+
+func interruptBatch(b *schema.Batch) {
+	b.Status = BatchFailed
+	b.FailedAt = time.Now().Unix()
+	b.Errors = &schema.BatchErrors{Object: "list", Data: []schema.BatchError{
+		{Code: batchInterrupted, Message: "LocalAI was stopped while the batch was running"},
+	}}
+}
+
+// batchResumable tells whether the batch was cancelled or interrupted
+func batchResumable(b schema.Batch) bool {
+	return b.Status == BatchCancelled ||
+		b.Status == BatchFailed && b.Errors != nil && len(b.Errors.Data) == 1 && b.Errors.Data[0].Code == batchInterrupted
+}
+
+// updateBatch changes the batch with fn and saves the batches, returning the batch changed
+func updateBatch(appConfig *config.ApplicationConfig, id string, fn func(b *schema.Batch)) (schema.Batch, bool) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	for i := range Batches {
+		if Batches[i].ID == id {
+			fn(&Batches[i])
+			utils.SaveConfig(appConfig.ConfigsDir, BatchesConfigFile, Batches)
+			return Batches[i], true
+		}
+	}
+	return schema.Batch{}, false
+}
+
+func getBatch(id string) (schema.Batch, bool) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	for _, b := range Batches {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return schema.Batch{}, false
+}
+
+func findUploadedFile(id string) (*schema.File, bool) {
+	for _, f := range UploadedFiles {
+		if f.ID == id {
+			return &f, true
+		}
+	}
+	return nil, false
+}
+
+// CreateBatchEndpoint is the OpenAI API endpoint to create batches https://platform.openai.com/docs/api-reference/batch/create
+// @Summary Runs the requests of a JSONL file in the background.
+// @Param request body schema.BatchRequest true "query params"
+// @Success 200 {object} schema.Batch "Response"
+// @Router /v1/batches [post]
+func CreateBatchEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(schema.BatchRequest)
+		if err := c.BodyParser(request); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %v", err))
+		}
+		if !slices.Contains(batchEndpoints, request.Endpoint) {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unsupported endpoint %q, expected one of %v", request.Endpoint, batchEndpoints))
+		}
+		if request.CompletionWindow != batchCompletionWindow {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unsupported completion_window %q, expected %s", request.CompletionWindow, batchCompletionWindow))
+		}
+		file, found := findUploadedFile(request.InputFileID)
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("unable to find file id %s", request.InputFileID))
+		}
+		if file.Purpose != batchPurpose {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("file %s has purpose %q, expected %q", file.ID, file.Purpose, batchPurpose))
+		}
+
+		now := time.Now()
+		batch := schema.Batch{
+			ID:               "batch_" + uuid.New().String(),
+			Object:           "batch",
+			Endpoint:         request.Endpoint,
+			InputFileID:      file.ID,
+			CompletionWindow: request.CompletionWindow,
+			Status:           BatchValidating,
+			CreatedAt:        now.Unix(),
+			ExpiresAt:        now.Add(batchWindow).Unix(),
+			Metadata:         request.Metadata,
+		}
+
+		// the requests are sent to the app like the ones of the client, behind the interactive ones
+		r := &batchRunner{
+			appConfig: appConfig,
+			client:    newInternalClient(c, config.RequestPriorityLow),
+			file:      *file,
+		}
+
+		ctx, cancel := context.WithDeadline(appConfig.Context, now.Add(batchWindow))
+		batchesMu.Lock()
+		Batches = append(Batches, batch)
+		batchCancels[batch.ID] = cancel
+		utils.SaveConfig(appConfig.ConfigsDir, BatchesConfigFile, Batches)
+		batchesMu.Unlock()
+
+		go r.run(ctx, batch.ID)
+		return c.JSON(batch)
+	}
+}
+
+// ListBatchesEndpoint is the OpenAI API endpoint to list batches https://platform.openai.com/docs/api-reference/batch/list
+// @Summary Lists the batches, the most recent first.
+// @Param limit query int false "number of batches, 20 by default"
+// @Param after query string false "id of the batch after which the batches are listed"
+// @Success 200 {object} schema.ListBatches "Response"
+// @Router /v1/batches [get]
+func ListBatchesEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		limit := c.QueryInt("limit", 20)
+		if limit < 1 || limit > 100 {
+			return fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 100")
+		}
+
+		// the batches are kept in the order they were created
+		batchesMu.Lock()
+		batches := make([]schema.Batch, len(Batches))
+		copy(batches, Batches)
+		batchesMu.Unlock()
+		slices.Reverse(batches)
+
+		if after := c.Query("after"); after != "" {
+			for i, b := range batches {
+				if b.ID == after {
+					batches = batches[i+1:]
+					break
+				}
+			}
+		}
+
+		list := schema.ListBatches{Object: "list", Data: []schema.Batch{}}
+		if len(batches) > limit {
+			batches, list.HasMore = batches[:limit], true
+		}
+		list.Data = append(list.Data, batches...)
+		if len(batches) > 0 {
+			list.FirstID, list.LastID = batches[0].ID, batches[len(batches)-1].ID
+		}
+		return c.JSON(list)
+	}
+}
+
+// GetBatchEndpoint is the OpenAI API endpoint to get batches https://platform.openai.com/docs/api-reference/batch/retrieve
+// @Summary Returns a batch and its progress.
+// @Success 200 {object} schema.Batch "Response"
+// @Router /v1/batches/{batch_id} [get]
+func GetBatchEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		batch, found := getBatch(c.Params("batch_id"))
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("unable to find batch id %s", c.Params("batch_id")))
+		}
+		return c.JSON(batch)
+	}
+}
+
+// CancelBatchEndpoint is the OpenAI API endpoint to cancel batches https://platform.openai.com/docs/api-reference/batch/cancel
+// @Summary Cancels a batch, the requests running are completed and the results so far written to its files.
+// @Success 200 {object} schema.Batch "Response"
+// @Router /v1/batches/{batch_id}/cancel [post]
+func CancelBatchEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("batch_id")
+		batch, found := getBatch(id)
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("unable to find batch id %s", id))
+		}
+		if batch.Status != BatchValidating && batch.Status != BatchInProgress {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("batch %s is %s and can't be cancelled", id, batch.Status))
+		}
+
+		batch, _ = updateBatch(appConfig, id, func(b *schema.Batch) {
+			b.Status = BatchCancelling
+			b.CancellingAt = time.Now().Unix()
+		})
+		batchesMu.Lock()
+		if cancel, ok := batchCancels[id]; ok {
+			cancel()
+		}
+		batchesMu.Unlock()
+		return c.JSON(batch)
+	}
+}
+
+// ResumeBatchEndpoint resumes the cancelled and the interrupted batches
+// @Summary Resumes a cancelled batch, or one interrupted by a restart of LocalAI, from the last checkpoint of its results.
+// @Success 200 {object} schema.Batch "Response"
+// @Router /v1/batches/{batch_id}/resume [post]
+func ResumeBatchEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("batch_id")
+		batch, found := getBatch(id)
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("unable to find batch id %s", id))
+		}
+		// the parameters of fiber are only valid during the request
+		id = batch.ID
+		if time.Now().Unix() >= batch.ExpiresAt {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("the completion window of batch %s is over", id))
+		}
+		file, found := findUploadedFile(batch.InputFileID)
+		if !found {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("unable to find file id %s", batch.InputFileID))
+		}
+
+		r := &batchRunner{
+			appConfig: appConfig,
+			client:    newInternalClient(c, config.RequestPriorityLow),
+			file:      *file,
+		}
+
+		// the batch is checked again while it's changed, so that it's resumed only once
+		ctx, cancel := context.WithDeadline(appConfig.Context, time.Unix(batch.ExpiresAt, 0))
+		batchesMu.Lock()
+		i := slices.IndexFunc(Batches, func(b schema.Batch) bool { return b.ID == id })
+		if !batchResumable(Batches[i]) {
+			batchesMu.Unlock()
+			cancel()
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("batch %s is %s and can't be resumed", id, Batches[i].Status))
+		}
+		b := &Batches[i]
+		b.Status = BatchValidating
+		b.Errors = nil
+		b.FailedAt, b.CancellingAt, b.CancelledAt = 0, 0, 0
+		batch = *b
+		batchCancels[id] = cancel
+		utils.SaveConfig(appConfig.ConfigsDir, BatchesConfigFile, Batches)
+		batchesMu.Unlock()
+
+		go r.run(ctx, id)
+		return c.JSON(batch)
+	}
+}
+
+// batchRunner runs the requests of the input file of a batch against the app, as if they were sent by the client
+// which created it
+type batchRunner struct {
+	appConfig *config.ApplicationConfig
+	client    *internalClient
+	file      schema.File
+}
+
+func (r *batchRunner) run(ctx context.Context, id string) {
+	defer func() {
+		batchesMu.Lock()
+		if cancel, ok := batchCancels[id]; ok {
+			cancel()
+			delete(batchCancels, id)
+		}
+		batchesMu.Unlock()
+	}()
+
+	fail := func(lineErrors []schema.BatchError) {
+		updateBatch(r.appConfig, id, func(b *schema.Batch) {
+			b.Status = BatchFailed
+			b.FailedAt = time.Now().Unix()
+			b.Errors = &schema.BatchErrors{Object: "list", Data: lineErrors}
+		})
+	}
+
+	batch, _ := getBatch(id)
+	lines, lineErrors, err := r.read(ctx, batch.Endpoint)
+	if err == nil && len(lineErrors) > 0 {
+		err = errors.New("invalid input file")
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("batch", id).Msg("batch failed validation")
+		if len(lineErrors) == 0 {
+			lineErrors = []schema.BatchError{{Code: "invalid_file", Message: err.Error()}}
+		}
+		fail(lineErrors)
+		return
+	}
+
+	// a resumed batch starts after the requests of its checkpoint
+	results := make([]*schema.BatchOutputLine, len(lines))
+	offset := 0
+	if batch.Checkpoint != nil {
+		if err := r.restore(ctx, batch.Checkpoint, lines, results); err != nil {
+			log.Warn().Err(err).Str("batch", id).Msg("unable to resume the batch")
+			fail([]schema.BatchError{{Code: "invalid_checkpoint", Message: err.Error()}})
+			return
+		}
+		offset = batch.Checkpoint.Offset
+	}
+
+	updateBatch(r.appConfig, id, func(b *schema.Batch) {
+		if b.Status == BatchValidating {
+			b.Status = BatchInProgress
+		}
+		b.InProgressAt = time.Now().Unix()
+		b.RequestCounts = schema.BatchRequestCounts{Total: len(lines)}
+		for _, result := range results[:offset] {
+			if result.Error != nil {
+				b.RequestCounts.Failed++
+			} else {
+				b.RequestCounts.Completed++
+			}
+		}
+	})
+
+	r.send(ctx, id, lines, results, offset)
+
+	updateBatch(r.appConfig, id, func(b *schema.Batch) {
+		if b.Status == BatchInProgress {
+			b.Status = BatchFinalizing
+		}
+		b.FinalizingAt = time.Now().Unix()
+	})
+	outputFileID, errorFileID, err := r.write(id, results)
+	if err != nil {
+		log.Error().Err(err).Str("batch", id).Msg("unable to write the results of the batch")
+	}
+
+	// the checkpoint is kept until the batch can't be resumed anymore
+	var checkpoints []string
+	updateBatch(r.appConfig, id, func(b *schema.Batch) {
+		b.OutputFileID, b.ErrorFileID = outputFileID, errorFileID
+		now := time.Now().Unix()
+		switch {
+		case b.Status == BatchCancelling:
+			b.Status, b.CancelledAt = BatchCancelled, now
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			b.Status, b.ExpiredAt = BatchExpired, now
+		case ctx.Err() != nil:
+			interruptBatch(b)
+		case err != nil:
+			b.Status, b.FailedAt = BatchFailed, now
+		default:
+			b.Status, b.CompletedAt = BatchCompleted, now
+		}
+		if (b.Status == BatchCompleted || b.Status == BatchExpired) && b.Checkpoint != nil {
+			checkpoints = b.Checkpoint.Files
+			b.Checkpoint = nil
+		}
+	})
+	for _, key := range checkpoints {
+		if err := r.appConfig.UploadStorage().Delete(r.appConfig.Context, key); err != nil {
+			log.Warn().Err(err).Str("batch", id).Str("checkpoint", key).Msg("unable to delete the checkpoint of the batch")
+		}
+	}
+	log.Info().Str("batch", id).Int("requests", len(lines)).Msg("batch done")
+}
+
+// read reads and validates the requests of the input file
+func (r *batchRunner) read(ctx context.Context, endpoint string) ([]schema.BatchInputLine, []schema.BatchError, error) {
+	f, err := r.appConfig.UploadStorage().Get(ctx, r.file.Filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the input file %s: %w", r.file.ID, err)
+	}
+	defer f.Close()
+	lines, lineErrors := parseBatchInput(f, endpoint)
+	return lines, lineErrors, nil
+}
+
+// parseBatchInput parses the requests of an input file, one JSON object per line, all sent to endpoint
+func parseBatchInput(input io.Reader, endpoint string) ([]schema.BatchInputLine, []schema.BatchError) {
+	lines := []schema.BatchInputLine{}
+	lineErrors := []schema.BatchError{}
+	ids := map[string]bool{}
+
+	s := bufio.NewScanner(input)
+	s.Buffer(make([]byte, 64*1024), batchMaxLine)
+	for n := 1; s.Scan(); n++ {
+		text := bytes.TrimSpace(s.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		fail := func(code, message string) {
+			lineErrors = append(lineErrors, schema.BatchError{Code: code, Message: message, Line: n})
+		}
+
+		var line schema.BatchInputLine
+		switch err := json.Unmarshal(text, &line); {
+		case err != nil:
+			fail("invalid_json", err.Error())
+		case line.CustomID == "":
+			fail("missing_custom_id", "custom_id is required")
+		case ids[line.CustomID]:
+			fail("duplicate_custom_id", fmt.Sprintf("custom_id %q is used by an earlier request", line.CustomID))
+		case line.Method != fiber.MethodPost:
+			fail("invalid_method", fmt.Sprintf("method %q is not supported, expected POST", line.Method))
+		case line.URL != endpoint:
+			fail("invalid_url", fmt.Sprintf("url %q doesn't match the endpoint %s of the batch", line.URL, endpoint))
+		case len(line.Body) == 0 || line.Body[0] != '{':
+			fail("invalid_body", "body must be a JSON object")
+		default:
+			ids[line.CustomID] = true
+			lines = append(lines, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		lineErrors = append(lineErrors, schema.BatchError{Code: "invalid_file", Message: err.Error()})
+	}
+	if len(lines) == 0 && len(lineErrors) == 0 {
+		lineErrors = append(lineErrors, schema.BatchError{Code: "empty_file", Message: "the input file has no requests"})
+	}
+	return lines, lineErrors
+}
+
+// send runs the requests from offset, BatchConcurrency at once across the batches, until they are all done or ctx is,
+// and sets their results. The results of the requests not run stay nil. The results of the first requests are
+// checkpointed every batchCheckpointEvery requests, and when the batch is stopped
+func (r *batchRunner) send(ctx context.Context, id string, lines []schema.BatchInputLine, results []*schema.BatchOutputLine, offset int) {
+	batchSlotsOnce.Do(func() {
+		batchSlots = make(chan struct{}, max(r.appConfig.BatchConcurrency, 1))
+	})
+
+	// done is the number of the first requests which ran, saved the ones already checkpointed
+	var mu sync.Mutex
+	done, saved := offset, offset
+	checkpoint := func(every int) {
+		for done < len(results) && results[done] != nil {
+			done++
+		}
+		if done-saved < max(every, 1) {
+			return
+		}
+		if err := r.checkpoint(id, results[saved:done], done); err != nil {
+			log.Warn().Err(err).Str("batch", id).Msg("unable to checkpoint the batch")
+			return
+		}
+		saved = done
+	}
+
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := offset; i < len(lines); i++ {
+			select {
+			case next <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < cap(batchSlots); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				select {
+				case batchSlots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				result := r.do(lines[i])
+				<-batchSlots
+
+				mu.Lock()
+				results[i] = result
+				updateBatch(r.appConfig, id, func(b *schema.Batch) {
+					if result.Error != nil {
+						b.RequestCounts.Failed++
+					} else {
+						b.RequestCounts.Completed++
+					}
+				})
+				checkpoint(batchCheckpointEvery)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		checkpoint(1)
+	}
+}
+
+// checkpoint stores the results of the requests of the batch before offset, the ones of the previous checkpoints
+// excluded, and saves the offset in the batch
+func (r *batchRunner) checkpoint(id string, results []*schema.BatchOutputLine, offset int) error {
+	var content bytes.Buffer
+	for _, result := range results {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		content.Write(append(data, '\n'))
+	}
+	// the batch is also checkpointed when LocalAI stops
+	key := fmt.Sprintf("%s_checkpoint_%d.jsonl", id, offset)
+	if err := r.appConfig.UploadStorage().Put(context.WithoutCancel(r.appConfig.Context), key, &content, int64(content.Len())); err != nil {
+		return fmt.Errorf("unable to store %s: %w", key, err)
+	}
+	updateBatch(r.appConfig, id, func(b *schema.Batch) {
+		if b.Checkpoint == nil {
+			b.Checkpoint = &schema.BatchCheckpoint{}
+		}
+		b.Checkpoint.Offset = offset
+		b.Checkpoint.Files = append(b.Checkpoint.Files, key)
+	})
+	return nil
+}
+
+// restore reads the results of the checkpoint of the batch, which must be the ones of the first requests of lines
+func (r *batchRunner) restore(ctx context.Context, checkpoint *schema.BatchCheckpoint, lines []schema.BatchInputLine, results []*schema.BatchOutputLine) error {
+	n := 0
+	for _, key := range checkpoint.Files {
+		f, err := r.appConfig.UploadStorage().Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("unable to read the checkpoint %s: %w", key, err)
+		}
+		s := bufio.NewScanner(f)
+		s.Buffer(make([]byte, 64*1024), batchMaxLine)
+		for ; s.Scan(); n++ {
+			result := &schema.BatchOutputLine{}
+			if err := json.Unmarshal(s.Bytes(), result); err != nil {
+				f.Close()
+				return fmt.Errorf("invalid checkpoint %s: %w", key, err)
+			}
+			if n >= len(lines) || result.CustomID != lines[n].CustomID {
+				f.Close()
+				return fmt.Errorf("the checkpoint %s doesn't match the requests of the input file", key)
+			}
+			results[n] = result
+		}
+		err = s.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to read the checkpoint %s: %w", key, err)
+		}
+	}
+	if n != checkpoint.Offset {
+		return fmt.Errorf("the checkpoint has %d results, expected %d", n, checkpoint.Offset)
+	}
+	return nil
+}
+
+// do sends a request to the app and returns its result. The replies with an error status are failed
+func (r *batchRunner) do(line schema.BatchInputLine) *schema.BatchOutputLine {
+	ctx := r.client.post(line.URL, line.Body)
+	body := ctx.Response.Body()
+	if ctx.Response.IsBodyStream() {
+		var err error
+		body, err = io.ReadAll(ctx.Response.BodyStream())
+		ctx.Response.CloseBodyStream()
+		if err != nil {
+			log.Debug().Err(err).Str("custom_id", line.CustomID).Msg("unable to read the reply of a batch request")
+		}
+	}
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+
+	status := ctx.Response.StatusCode()
+	result := &schema.BatchOutputLine{
+		ID:       "batch_req_" + uuid.New().String(),
+		CustomID: line.CustomID,
+		Response: &schema.BatchLineResponse{
+			StatusCode: status,
+			RequestID:  "req_" + uuid.New().String(),
+			Body:       body,
+		},
+	}
+	if status >= fiber.StatusBadRequest {
+		result.Error = &schema.BatchError{Code: fmt.Sprint(status), Message: fasthttp.StatusMessage(status)}
+	}
+	return result
+}
+
+// write stores the successful results in the output file and the failed ones in the error file, and returns their
+// ids, empty when they have no results
+func (r *batchRunner) write(id string, results []*schema.BatchOutputLine) (string, string, error) {
+	var output, failed bytes.Buffer
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return "", "", err
+		}
+		if result.Error != nil {
+			failed.Write(append(data, '\n'))
+		} else {
+			output.Write(append(data, '\n'))
+		}
+	}
+
+	outputFileID, err := r.store(id+"_output.jsonl", &output)
+	if err != nil {
+		return "", "", err
+	}
+	errorFileID, err := r.store(id+"_error.jsonl", &failed)
+	if err != nil {
+		return outputFileID, "", err
+	}
+	return outputFileID, errorFileID, nil
+}
+
+// store adds the content to the files, as downloaded through /v1/files/{file_id}/content
+func (r *batchRunner) store(filename string, content *bytes.Buffer) (string, error) {
+	if content.Len() == 0 {
+		return "", nil
+	}
+	size := content.Len()
+	if err := r.appConfig.UploadStorage().Put(r.appConfig.Context, filename, content, int64(size)); err != nil {
+		return "", fmt.Errorf("unable to store %s: %w", filename, err)
+	}
+
+	f := schema.File{
+		ID:        fmt.Sprintf("file-%d", getNextFileId()),
+		Object:    "file",
+		Bytes:     size,
+		CreatedAt: time.Now(),
+		Filename:  filename,
+		Purpose:   batchOutputPurpose,
+	}
+	batchesMu.Lock()
+	UploadedFiles = append(UploadedFiles, f)
+	utils.SaveConfig(r.appConfig.UploadDir, UploadedFilesFile, UploadedFiles)
+	batchesMu.Unlock()
+	return f.ID, nil
+}
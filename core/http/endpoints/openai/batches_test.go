@@ -0,0 +1,290 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	input := strings.Join([]string{
+		`{"custom_id": "a", "method": "POST", "url": "/v1/embeddings", "body": {"model": "bert", "input": "a"}}`,
+		``,
+		`{"custom_id": "b", "method": "POST", "url": "/v1/embeddings", "body": {"model": "bert", "input": "b"}}`,
+	}, "\n")
+	lines, lineErrors := parseBatchInput(strings.NewReader(input), "/v1/embeddings")
+	assert.Empty(t, lineErrors)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "b", lines[1].CustomID)
+	assert.JSONEq(t, `{"model": "bert", "input": "b"}`, string(lines[1].Body))
+
+	input = strings.Join([]string{
+		`not json`,
+		`{"method": "POST", "url": "/v1/embeddings", "body": {}}`,
+		`{"custom_id": "a", "method": "POST", "url": "/v1/embeddings", "body": {}}`,
+		`{"custom_id": "a", "method": "POST", "url": "/v1/embeddings", "body": {}}`,
+		`{"custom_id": "b", "method": "GET", "url": "/v1/embeddings", "body": {}}`,
+		`{"custom_id": "c", "method": "POST", "url": "/v1/completions", "body": {}}`,
+		`{"custom_id": "d", "method": "POST", "url": "/v1/embeddings", "body": "text"}`,
+	}, "\n")
+	lines, lineErrors = parseBatchInput(strings.NewReader(input), "/v1/embeddings")
+	assert.Len(t, lines, 1)
+	codes := []string{}
+	for _, e := range lineErrors {
+		codes = append(codes, fmt.Sprintf("%d:%s", e.Line, e.Code))
+	}
+	assert.Equal(t, []string{"1:invalid_json", "2:missing_custom_id", "4:duplicate_custom_id", "5:invalid_method", "6:invalid_url", "7:invalid_body"}, codes)
+
+	_, lineErrors = parseBatchInput(strings.NewReader("\n\n"), "/v1/embeddings")
+	require.Len(t, lineErrors, 1)
+	assert.Equal(t, "empty_file", lineErrors[0].Code)
+}
+
+func TestBatch(t *testing.T) {
+	Batches = nil
+	UploadedFiles = nil
+	option := &config.ApplicationConfig{
+		Context:          context.Background(),
+		UploadLimitMB:    10,
+		UploadDir:        t.TempDir(),
+		ConfigsDir:       t.TempDir(),
+		BatchConcurrency: 2,
+	}
+	loader := &config.BackendConfigLoader{}
+
+	app := fiber.New()
+	app.Post("/files", UploadFilesEndpoint(loader, option))
+	app.Get("/files/:file_id/content", GetFilesContentsEndpoint(loader, option))
+	app.Post("/v1/batches", CreateBatchEndpoint(option))
+	app.Get("/v1/batches", ListBatchesEndpoint(option))
+	app.Get("/v1/batches/:batch_id", GetBatchEndpoint(option))
+	// a fake embeddings endpoint, failing the requests without input
+	app.Post("/v1/embeddings", func(c *fiber.Ctx) error {
+		request := schema.OpenAIRequest{}
+		if err := c.BodyParser(&request); err != nil {
+			return err
+		}
+		if request.Input == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "input is required")
+		}
+		assert.Equal(t, config.RequestPriorityLow, c.Get("X-LocalAI-Priority"))
+		return c.JSON(fiber.Map{"model": request.Model, "input": request.Input})
+	})
+
+	input := filepath.Join(t.TempDir(), "requests.jsonl")
+	requests := []string{}
+	for i := 0; i < 5; i++ {
+		requests = append(requests, fmt.Sprintf(`{"custom_id": "req-%d", "method": "POST", "url": "/v1/embeddings", "body": {"model": "bert", "input": "text %d"}}`, i, i))
+	}
+	requests = append(requests, `{"custom_id": "no-input", "method": "POST", "url": "/v1/embeddings", "body": {"model": "bert"}}`)
+	require.NoError(t, os.WriteFile(input, []byte(strings.Join(requests, "\n")), 0600))
+	body, writer := newMultipartFile(input, "file", "batch")
+	req := httptest.NewRequest(http.MethodPost, "/files", body)
+	req.Header.Set(fiber.HeaderContentType, writer.FormDataContentType())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	file := responseToFile(t, resp)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id": "`+file.ID+`", "endpoint": "/v1/moderations", "completion_window": "24h"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, "the endpoint is not supported")
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id": "`+file.ID+`", "endpoint": "/v1/embeddings", "completion_window": "24h", "metadata": {"corpus": "docs"}}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	batch := schema.Batch{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&batch))
+	assert.Equal(t, "docs", batch.Metadata["corpus"])
+
+	assert.Eventually(t, func() bool {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/v1/batches/"+batch.ID, nil))
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&batch))
+		return batch.Status == BatchCompleted
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, schema.BatchRequestCounts{Total: 6, Completed: 5, Failed: 1}, batch.RequestCounts)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/files/"+batch.OutputFileID+"/content", nil))
+	require.NoError(t, err)
+	output := strings.Split(strings.TrimSpace(bodyToString(resp, t)), "\n")
+	require.Len(t, output, 5)
+	line := schema.BatchOutputLine{}
+	require.NoError(t, json.Unmarshal([]byte(output[0]), &line))
+	assert.Equal(t, "req-0", line.CustomID)
+	assert.Equal(t, fiber.StatusOK, line.Response.StatusCode)
+	assert.JSONEq(t, `{"model": "bert", "input": "text 0"}`, string(line.Response.Body))
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/files/"+batch.ErrorFileID+"/content", nil))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(bodyToString(resp, t)), &line))
+	assert.Equal(t, "no-input", line.CustomID)
+	assert.Equal(t, fiber.StatusBadRequest, line.Response.StatusCode)
+	require.NotNil(t, line.Error)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/v1/batches?limit=1", nil))
+	require.NoError(t, err)
+	list := schema.ListBatches{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, batch.ID, list.Data[0].ID)
+	assert.False(t, list.HasMore)
+}
+
+func TestBatchResume(t *testing.T) {
+	Batches = nil
+	UploadedFiles = nil
+	batchCheckpointEvery = 1
+	defer func() { batchCheckpointEvery = 100 }()
+	option := &config.ApplicationConfig{
+		Context:          context.Background(),
+		UploadLimitMB:    10,
+		UploadDir:        t.TempDir(),
+		ConfigsDir:       t.TempDir(),
+		BatchConcurrency: 2,
+	}
+	loader := &config.BackendConfigLoader{}
+
+	// a fake embeddings endpoint, holding the requests after the third one until released
+	var mu sync.Mutex
+	calls := map[string]int{}
+	release := make(chan struct{})
+	app := fiber.New()
+	app.Post("/files", UploadFilesEndpoint(loader, option))
+	app.Get("/files/:file_id/content", GetFilesContentsEndpoint(loader, option))
+	app.Post("/v1/batches", CreateBatchEndpoint(option))
+	app.Get("/v1/batches/:batch_id", GetBatchEndpoint(option))
+	app.Post("/v1/batches/:batch_id/cancel", CancelBatchEndpoint(option))
+	app.Post("/v1/batches/:batch_id/resume", ResumeBatchEndpoint(option))
+	app.Post("/v1/embeddings", func(c *fiber.Ctx) error {
+		request := schema.OpenAIRequest{}
+		if err := c.BodyParser(&request); err != nil {
+			return err
+		}
+		input := request.Input.(string)
+		mu.Lock()
+		calls[input]++
+		mu.Unlock()
+		if input >= "text 3" {
+			<-release
+		}
+		return c.JSON(fiber.Map{"input": input})
+	})
+
+	input := filepath.Join(t.TempDir(), "requests.jsonl")
+	requests := []string{}
+	for i := 0; i < 6; i++ {
+		requests = append(requests, fmt.Sprintf(`{"custom_id": "req-%d", "method": "POST", "url": "/v1/embeddings", "body": {"model": "bert", "input": "text %d"}}`, i, i))
+	}
+	require.NoError(t, os.WriteFile(input, []byte(strings.Join(requests, "\n")), 0600))
+	body, writer := newMultipartFile(input, "file", "batch")
+	req := httptest.NewRequest(http.MethodPost, "/files", body)
+	req.Header.Set(fiber.HeaderContentType, writer.FormDataContentType())
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	file := responseToFile(t, resp)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(`{"input_file_id": "`+file.ID+`", "endpoint": "/v1/embeddings", "completion_window": "24h"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	batch := schema.Batch{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&batch))
+
+	getBatch := func() schema.Batch {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/v1/batches/"+batch.ID, nil))
+		require.NoError(t, err)
+		b := schema.Batch{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&b))
+		return b
+	}
+	assert.Eventually(t, func() bool {
+		b := getBatch()
+		return b.Checkpoint != nil && b.Checkpoint.Offset == 3
+	}, 10*time.Second, 50*time.Millisecond)
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/v1/batches/"+batch.ID+"/resume", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode, "the batch is running")
+
+	// the checkpoint is kept when the batch is cancelled, with the results of the requests which were running
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/v1/batches/"+batch.ID+"/cancel", nil))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	assert.Eventually(t, func() bool {
+		batch = getBatch()
+		return batch.Status == BatchCancelled
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NotNil(t, batch.Checkpoint)
+	offset := batch.Checkpoint.Offset
+	assert.GreaterOrEqual(t, offset, 3)
+	assert.Equal(t, offset, batch.RequestCounts.Completed)
+
+	// the requests of the checkpoint are not sent again
+	resp, err = app.Test(httptest.NewRequest(http.MethodPost, "/v1/batches/"+batch.ID+"/resume", nil))
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Eventually(t, func() bool {
+		batch = getBatch()
+		return batch.Status == BatchCompleted
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, schema.BatchRequestCounts{Total: 6, Completed: 6}, batch.RequestCounts)
+	assert.Nil(t, batch.Checkpoint)
+	mu.Lock()
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, 1, calls[fmt.Sprintf("text %d", i)], "text %d", i)
+	}
+	mu.Unlock()
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/files/"+batch.OutputFileID+"/content", nil))
+	require.NoError(t, err)
+	output := strings.Split(strings.TrimSpace(bodyToString(resp, t)), "\n")
+	require.Len(t, output, 6)
+	for i, data := range output {
+		line := schema.BatchOutputLine{}
+		require.NoError(t, json.Unmarshal([]byte(data), &line))
+		assert.Equal(t, fmt.Sprintf("req-%d", i), line.CustomID)
+	}
+	checkpoints, err := filepath.Glob(filepath.Join(option.UploadDir, "*_checkpoint_*"))
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints)
+}
+
+func TestLoadBatches(t *testing.T) {
+	option := &config.ApplicationConfig{ConfigsDir: t.TempDir()}
+	checkpoint := &schema.BatchCheckpoint{Offset: 2, Files: []string{"batch_1_checkpoint_2.jsonl"}}
+	Batches = []schema.Batch{
+		{ID: "batch_1", Status: BatchInProgress, ExpiresAt: time.Now().Add(time.Hour).Unix(), Checkpoint: checkpoint},
+		{ID: "batch_2", Status: BatchCompleted},
+	}
+	utils.SaveConfig(option.ConfigsDir, BatchesConfigFile, Batches)
+	Batches = nil
+
+	LoadBatches(option)
+	require.Len(t, Batches, 2)
+	assert.Equal(t, BatchFailed, Batches[0].Status)
+	assert.Equal(t, checkpoint, Batches[0].Checkpoint)
+	assert.True(t, batchResumable(Batches[0]))
+	assert.Equal(t, BatchCompleted, Batches[1].Status)
+	assert.False(t, batchResumable(Batches[1]))
+}
@@ -12,11 +12,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/functions/grammars"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/reasoning"
 	"github.com/rs/zerolog/log"
-	"github.com/valyala/fasthttp"
 )
 
 // ChatEndpoint is the OpenAI Completion API endpoint https://platform.openai.com/docs/api-reference/chat/create
@@ -24,7 +27,7 @@ import (
 // @Param request body schema.OpenAIRequest true "query params"
 // @Success 200 {object} schema.OpenAIResponse "Response"
 // @Router /v1/chat/completions [post]
-func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startupOptions *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, memories *services.MemoryService, storedCompletions *services.StoredCompletionsService, budgets *services.TokenBudgetService, startupOptions *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	var id, textContentToReturn string
 	var created int
 
@@ -38,12 +41,30 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 		}
 		responses <- initialMessage
 
-		ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
-			resp := schema.OpenAIResponse{
+		var splitter *reasoning.Splitter
+		if config.Reasoning.Enabled() {
+			splitter = reasoning.NewSplitter(config.Reasoning)
+		}
+		send := func(thought, content string, usage backend.TokenUsage) {
+			delta := &schema.Message{}
+			if content != "" {
+				delta.Content = &content
+			}
+			if config.Reasoning.Mode == reasoning.ModeSeparate {
+				delta.ReasoningContent = thought
+			}
+			if delta.Content == nil && delta.ReasoningContent == "" && usage.Logprobs == nil {
+				return
+			}
+			choice := schema.Choice{Delta: delta, Index: 0}
+			if usage.Logprobs != nil {
+				choice.Logprobs = &schema.Logprobs{Content: usage.Logprobs}
+			}
+			responses <- schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{{Delta: &schema.Message{Content: &s}, Index: 0}},
+				Choices: []schema.Choice{choice},
 				Object:  "chat.completion.chunk",
 				Usage: schema.OpenAIUsage{
 					PromptTokens:     usage.Prompt,
@@ -51,10 +72,24 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 					TotalTokens:      usage.Prompt + usage.Completion,
 				},
 			}
+		}
 
-			responses <- resp
+		lastUsage := backend.TokenUsage{}
+		ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+			// the log probabilities are sent once, with the output they came with
+			lastUsage = backend.TokenUsage{Prompt: usage.Prompt, Completion: usage.Completion}
+			if splitter == nil {
+				send("", s, usage)
+				return true
+			}
+			thought, content := splitter.Feed(s)
+			send(thought, content, usage)
 			return true
 		})
+		if splitter != nil {
+			thought, content := splitter.Flush()
+			send(thought, content, lastUsage)
+		}
 		close(responses)
 	}
 	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse) {
@@ -66,9 +101,17 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 			return true
 		})
 
+		thought := ""
+		if config.Reasoning.Enabled() {
+			thought, result = reasoning.Split(config.Reasoning, result)
+		}
+		if config.Reasoning.Mode != reasoning.ModeSeparate {
+			thought = ""
+		}
+
 		textContentToReturn = functions.ParseTextContent(result, config.FunctionsConfig)
 		result = functions.CleanupLLMResult(result, config.FunctionsConfig)
-		results := functions.ParseFunctionCall(result, config.FunctionsConfig)
+		results := parallelToolCalls(req, functions.ParseFunctionCall(result, config.FunctionsConfig))
 		log.Debug().Msgf("Text content to return: %s", textContentToReturn)
 		noActionToRun := len(results) > 0 && results[0].Name == noAction || len(results) == 0
 
@@ -78,7 +121,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn}}},
+				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn, ReasoningContent: thought}}},
 				Object:  "chat.completion.chunk",
 			}
 			responses <- initialMessage
@@ -105,6 +148,20 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 			responses <- resp
 
 		default:
+			// the text and the reasoning come first, then each call is streamed with its name, then its arguments
+			if thought != "" || textContentToReturn != "" {
+				delta := &schema.Message{Role: "assistant", ReasoningContent: thought}
+				if textContentToReturn != "" {
+					delta.Content = &textContentToReturn
+				}
+				responses <- schema.OpenAIResponse{
+					ID:      id,
+					Created: created,
+					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+					Choices: []schema.Choice{{Delta: delta}},
+					Object:  "chat.completion.chunk",
+				}
+			}
 			for i, ss := range results {
 				name, args := ss.Name, ss.Arguments
 
@@ -118,7 +175,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 							ToolCalls: []schema.ToolCall{
 								{
 									Index: i,
-									ID:    id,
+									ID:    toolCallID(id, i),
 									Type:  "function",
 									FunctionCall: schema.FunctionCall{
 										Name: name,
@@ -136,13 +193,9 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
 					Choices: []schema.Choice{{
 						Delta: &schema.Message{
-							Role:    "assistant",
-							Content: &textContentToReturn,
 							ToolCalls: []schema.ToolCall{
 								{
 									Index: i,
-									ID:    id,
-									Type:  "function",
 									FunctionCall: schema.FunctionCall{
 										Arguments: args,
 									},
@@ -158,6 +211,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 	}
 
 	return func(c *fiber.Ctx) error {
+		started := time.Now()
 		textContentToReturn = ""
 		id = uuid.New().String()
 		created = int(time.Now().Unix())
@@ -171,6 +225,43 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
+		// the messages are always rendered with the templates of the model, raw prompts are for the completions
+		config.Raw = false
+		warning, err := fiberContext.CheckModel(c, config)
+		if err != nil {
+			return maintenanceResponse(c, err, config, input, id, created, true)
+		}
+		apiKey := fiberContext.APIKeyFromContext(c)
+		budget, err := beginTokenBudget(budgets, apiKey, config, input)
+		if err != nil {
+			return err
+		}
+
+		if err := preprocessImages(c, input, startupOptions); err != nil {
+			return err
+		}
+
+		// the messages are stored as sent, before the memories are injected
+		requestMessages := append([]schema.Message{}, input.Messages...)
+		apiKeyID := fiberContext.APIKeyIDFromContext(c)
+
+		// Long-term memory is scoped by the user of the request
+		remember := memories.Enabled() && input.User != ""
+		if remember {
+			input.Messages = memories.Inject(input.User, input.Messages, config.SystemPrompt)
+		}
+
+		// The language of the answer is enforced with an instruction, and checked when not streamed
+		languageMessages := input.Messages
+		if config.ForceLanguage != "" {
+			input.Messages = enforceLanguage(languageMessages, config.ForceLanguage, false)
+		}
+
+		// The answers are checked by the validators when not streamed
+		validators, err := compileValidators(config.Validators)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 		log.Debug().Msgf("Configuration read: %+v", config)
 
 		funcs := input.Functions
@@ -196,6 +287,9 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 			noActionDescription = config.FunctionsConfig.NoActionDescriptionName
 		}
 
+		// a strict JSON schema of the response format refuses the answers which don't match it
+		var formatValidator responseValidator
+		strictFormat := false
 		if config.ResponseFormatMap != nil {
 			d := schema.ChatCompletionResponseFormat{}
 			dat, err := json.Marshal(config.ResponseFormatMap)
@@ -218,13 +312,20 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 				if err != nil {
 					return err
 				}
-				fs := &functions.JSONFunctionStructure{
-					AnyOf: []functions.Item{d.JsonSchema.Schema},
+				if d.JsonSchema.Schema == nil {
+					return fiber.NewError(fiber.StatusBadRequest, "response_format: the json_schema has no schema")
 				}
-				g, err := fs.Grammar(config.FunctionsConfig.GrammarOptions()...)
-				if err == nil {
-					input.Grammar = g
+				// the schema constrains the generation with its grammar, and the answer is checked against it
+				g, err := grammars.NewJSONSchemaConverter(config.FunctionsConfig.GrammarConfig.PropOrder).Grammar(d.JsonSchema.Schema)
+				if err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("response_format: the JSON schema can't be compiled to a grammar: %s", err))
 				}
+				input.Grammar = g
+				if formatValidator, err = jsonSchemaValidator(d.JsonSchema.Schema); err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, "response_format: "+err.Error())
+				}
+				validators = append(validators, formatValidator)
+				strictFormat = d.JsonSchema.Strict
 			}
 		}
 
@@ -283,156 +384,8 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 
 		log.Debug().Msgf("Parameters: %+v", config)
 
-		var predInput string
-
-		// If we are using the tokenizer template, we don't need to process the messages
-		// unless we are processing functions
-		if !config.TemplateConfig.UseTokenizerTemplate || shouldUseFn {
-			suppressConfigSystemPrompt := false
-			mess := []string{}
-			for messageIndex, i := range input.Messages {
-				var content string
-				role := i.Role
-
-				// if function call, we might want to customize the role so we can display better that the "assistant called a json action"
-				// if an "assistant_function_call" role is defined, we use it, otherwise we use the role that is passed by in the request
-				if (i.FunctionCall != nil || i.ToolCalls != nil) && i.Role == "assistant" {
-					roleFn := "assistant_function_call"
-					r := config.Roles[roleFn]
-					if r != "" {
-						role = roleFn
-					}
-				}
-				r := config.Roles[role]
-				contentExists := i.Content != nil && i.StringContent != ""
-
-				fcall := i.FunctionCall
-				if len(i.ToolCalls) > 0 {
-					fcall = i.ToolCalls
-				}
-
-				// First attempt to populate content via a chat message specific template
-				if config.TemplateConfig.ChatMessage != "" {
-					chatMessageData := model.ChatMessageTemplateData{
-						SystemPrompt: config.SystemPrompt,
-						Role:         r,
-						RoleName:     role,
-						Content:      i.StringContent,
-						FunctionCall: fcall,
-						FunctionName: i.Name,
-						LastMessage:  messageIndex == (len(input.Messages) - 1),
-						Function:     config.Grammar != "" && (messageIndex == (len(input.Messages) - 1)),
-						MessageIndex: messageIndex,
-					}
-					templatedChatMessage, err := ml.EvaluateTemplateForChatMessage(config.TemplateConfig.ChatMessage, chatMessageData)
-					if err != nil {
-						log.Error().Err(err).Interface("message", chatMessageData).Str("template", config.TemplateConfig.ChatMessage).Msg("error processing message with template, skipping")
-					} else {
-						if templatedChatMessage == "" {
-							log.Warn().Msgf("template \"%s\" produced blank output for %+v. Skipping!", config.TemplateConfig.ChatMessage, chatMessageData)
-							continue // TODO: This continue is here intentionally to skip over the line `mess = append(mess, content)` below, and to prevent the sprintf
-						}
-						log.Debug().Msgf("templated message for chat: %s", templatedChatMessage)
-						content = templatedChatMessage
-					}
-				}
-
-				marshalAnyRole := func(f any) {
-					j, err := json.Marshal(f)
-					if err == nil {
-						if contentExists {
-							content += "\n" + fmt.Sprint(r, " ", string(j))
-						} else {
-							content = fmt.Sprint(r, " ", string(j))
-						}
-					}
-				}
-				marshalAny := func(f any) {
-					j, err := json.Marshal(f)
-					if err == nil {
-						if contentExists {
-							content += "\n" + string(j)
-						} else {
-							content = string(j)
-						}
-					}
-				}
-				// If this model doesn't have such a template, or if that template fails to return a value, template at the message level.
-				if content == "" {
-					if r != "" {
-						if contentExists {
-							content = fmt.Sprint(r, i.StringContent)
-						}
-
-						if i.FunctionCall != nil {
-							marshalAnyRole(i.FunctionCall)
-						}
-						if i.ToolCalls != nil {
-							marshalAnyRole(i.ToolCalls)
-						}
-					} else {
-						if contentExists {
-							content = fmt.Sprint(i.StringContent)
-						}
-						if i.FunctionCall != nil {
-							marshalAny(i.FunctionCall)
-						}
-						if i.ToolCalls != nil {
-							marshalAny(i.ToolCalls)
-						}
-					}
-					// Special Handling: System. We care if it was printed at all, not the r branch, so check seperately
-					if contentExists && role == "system" {
-						suppressConfigSystemPrompt = true
-					}
-				}
-
-				mess = append(mess, content)
-			}
-
-			joinCharacter := "\n"
-			if config.TemplateConfig.JoinChatMessagesByCharacter != nil {
-				joinCharacter = *config.TemplateConfig.JoinChatMessagesByCharacter
-			}
-
-			predInput = strings.Join(mess, joinCharacter)
-			log.Debug().Msgf("Prompt (before templating): %s", predInput)
-
-			templateFile := ""
-
-			// A model can have a "file.bin.tmpl" file associated with a prompt template prefix
-			if ml.ExistsInModelPath(fmt.Sprintf("%s.tmpl", config.Model)) {
-				templateFile = config.Model
-			}
-
-			if config.TemplateConfig.Chat != "" && !shouldUseFn {
-				templateFile = config.TemplateConfig.Chat
-			}
-
-			if config.TemplateConfig.Functions != "" && shouldUseFn {
-				templateFile = config.TemplateConfig.Functions
-			}
-
-			if templateFile != "" {
-				templatedInput, err := ml.EvaluateTemplateForPrompt(model.ChatPromptTemplate, templateFile, model.PromptTemplateData{
-					SystemPrompt:         config.SystemPrompt,
-					SuppressSystemPrompt: suppressConfigSystemPrompt,
-					Input:                predInput,
-					Functions:            funcs,
-				})
-				if err == nil {
-					predInput = templatedInput
-					log.Debug().Msgf("Template found, input modified to: %s", predInput)
-				} else {
-					log.Debug().Msgf("Template failed loading: %s", err.Error())
-				}
-			}
-
-			log.Debug().Msgf("Prompt (after templating): %s", predInput)
-			if shouldUseFn && config.Grammar != "" {
-				log.Debug().Msgf("Grammar: %+v", config.Grammar)
-			}
-		}
+		predInput := TemplateMessages(config, input, ml, funcs, shouldUseFn)
+		fingerprint := services.SystemFingerprint(*config, ml)
 
 		switch {
 		case toStream:
@@ -453,14 +406,21 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 				go processTools(noActionName, predInput, input, config, ml, responses)
 			}
 
-			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
 				usage := &schema.OpenAIUsage{}
 				toolsCalled := false
+				reply := strings.Builder{}
+				toolCalls := []schema.ToolCall{}
 				for ev := range responses {
 					usage = &ev.Usage // Copy a pointer to the latest usage chunk so that the stop message can reference it
 					if len(ev.Choices[0].Delta.ToolCalls) > 0 {
 						toolsCalled = true
+						toolCalls = mergeToolCallDeltas(toolCalls, ev.Choices[0].Delta.ToolCalls)
 					}
+					if s, ok := ev.Choices[0].Delta.Content.(*string); ok && s != nil {
+						reply.WriteString(*s)
+					}
+					ev.SystemFingerprint = fingerprint
 					var buf bytes.Buffer
 					enc := json.NewEncoder(&buf)
 					enc.Encode(ev)
@@ -488,16 +448,44 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 						{
 							FinishReason: finishReason,
 							Index:        0,
-							Delta:        &schema.Message{Content: &textContentToReturn},
+							// the content was streamed with the first chunks
+							Delta: &schema.Message{},
 						}},
-					Object: "chat.completion.chunk",
-					Usage:  *usage,
+					Object:            "chat.completion.chunk",
+					Usage:             *usage,
+					SystemFingerprint: fingerprint,
+					Warning:           responseWarning(warning, input),
+					Timings:           requestTimings(config, input, started),
+				}
+				if budget != nil {
+					budgets.Consume(apiKey, budget, usage.TotalTokens)
+					resp.TokenBudget = budget
 				}
 				respData, _ := json.Marshal(resp)
 
 				w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
 				w.WriteString("data: [DONE]\n\n")
 				w.Flush()
+
+				if remember {
+					go learnMemories(memories, startupOptions, input, reply.String())
+				}
+
+				if input.Store {
+					content := reply.String()
+					message := &schema.Message{Role: "assistant", Content: &content}
+					if len(toolCalls) > 0 {
+						message.ToolCalls = toolCalls
+					}
+					storeCompletion(storedCompletions, apiKeyID, input, requestMessages, schema.OpenAIResponse{
+						ID:      id,
+						Created: created,
+						Model:   input.Model,
+						Choices: []schema.Choice{{FinishReason: finishReason, Index: 0, Message: message}},
+						Object:  "chat.completion",
+						Usage:   *usage,
+					})
+				}
 			}))
 			return nil
 
@@ -512,7 +500,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 
 				textContentToReturn = functions.ParseTextContent(s, config.FunctionsConfig)
 				s = functions.CleanupLLMResult(s, config.FunctionsConfig)
-				results := functions.ParseFunctionCall(s, config.FunctionsConfig)
+				results := parallelToolCalls(input, functions.ParseFunctionCall(s, config.FunctionsConfig))
 				log.Debug().Msgf("Text content to return: %s", textContentToReturn)
 				noActionsToRun := len(results) > 0 && results[0].Name == noActionName || len(results) == 0
 
@@ -536,7 +524,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 						toolChoice.FinishReason = "tool_calls"
 					}
 
-					for _, ss := range results {
+					for i, ss := range results {
 						name, args := ss.Name, ss.Arguments
 						if len(input.Tools) > 0 {
 							// If we are using tools, we condense the function calls into
@@ -544,8 +532,9 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 							toolChoice.Message.Content = textContentToReturn
 							toolChoice.Message.ToolCalls = append(toolChoice.Message.ToolCalls,
 								schema.ToolCall{
-									ID:   id,
-									Type: "function",
+									Index: i,
+									ID:    toolCallID(id, i),
+									Type:  "function",
 									FunctionCall: schema.FunctionCall{
 										Name:      name,
 										Arguments: args,
@@ -579,27 +568,290 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, startup
 				return err
 			}
 
+			// retryChoices computes the answer again with other messages, the messages of the request are left alone
+			retryChoices := func(messages []schema.Message) ([]schema.Choice, backend.TokenUsage, error) {
+				retry := *input
+				retry.Messages = messages
+				return ComputeChoices(&retry, TemplateMessages(config, &retry, ml, funcs, shouldUseFn), config, startupOptions, ml, func(s string, c *[]schema.Choice) {
+					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: &schema.Message{Role: "assistant", Content: &s}})
+				}, nil)
+			}
+
+			// the messages the answer was computed with, the validation retries continue them
+			messages := input.Messages
+			if config.ForceLanguage != "" && !shouldUseFn && !answeredIn(result, config.ForceLanguage) {
+				log.Debug().Str("language", config.ForceLanguage).Msg("the answer is not in the forced language, retrying")
+				messages = enforceLanguage(languageMessages, config.ForceLanguage, true)
+				retried, retryUsage, err := retryChoices(messages)
+				if err != nil {
+					return err
+				}
+				result = retried
+				tokenUsage.Prompt += retryUsage.Prompt
+				tokenUsage.Completion += retryUsage.Completion
+			}
+
+			var validation *schema.Validation
+			if len(validators) > 0 && !shouldUseFn {
+				var validationUsage backend.TokenUsage
+				result, validationUsage, validation, err = validateResponse(validators, validationRetries(config.ValidationRetries), messages, result,
+					func(messages []schema.Message) ([]schema.Choice, backend.TokenUsage, error) {
+						log.Debug().Msg("the answer is not valid, retrying")
+						return retryChoices(messages)
+					})
+				if err != nil {
+					return err
+				}
+				tokenUsage.Prompt += validationUsage.Prompt
+				tokenUsage.Completion += validationUsage.Completion
+				if strictFormat && !validation.Valid {
+					refuseInvalidChoices(formatValidator, result)
+				}
+			}
+
 			resp := &schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
 				Choices: result,
 				Object:  "chat.completion",
+				Warning: responseWarning(warning, input),
+				Timings: requestTimings(config, input, started),
 				Usage: schema.OpenAIUsage{
 					PromptTokens:     tokenUsage.Prompt,
 					CompletionTokens: tokenUsage.Completion,
 					TotalTokens:      tokenUsage.Prompt + tokenUsage.Completion,
 				},
+				Validation:        validation,
+				SystemFingerprint: fingerprint,
+			}
+			if budget != nil {
+				budgets.Consume(apiKey, budget, resp.Usage.TotalTokens)
+				resp.TokenBudget = budget
 			}
 			respData, _ := json.Marshal(resp)
 			log.Debug().Msgf("Response: %s", respData)
 
+			if remember && len(result) > 0 && result[0].Message != nil {
+				if s, ok := result[0].Message.Content.(*string); ok && s != nil {
+					go learnMemories(memories, startupOptions, input, *s)
+				}
+			}
+
+			if input.Store {
+				storeCompletion(storedCompletions, apiKeyID, input, requestMessages, *resp)
+			}
+
 			// Return the prediction in the response body
 			return c.JSON(resp)
 		}
 	}
 }
 
+// TemplateMessages renders the messages of the request into the prompt of the model, with the templates of its config.
+// The prompt is empty when the backend applies the chat template of the tokenizer itself.
+func TemplateMessages(config *config.BackendConfig, input *schema.OpenAIRequest, ml *model.ModelLoader, funcs functions.Functions, shouldUseFn bool) string {
+	var predInput string
+
+	// If we are using the tokenizer template, we don't need to process the messages
+	// unless we are processing functions
+	if !config.TemplateConfig.UseTokenizerTemplate || shouldUseFn {
+		suppressConfigSystemPrompt := false
+		mess := []string{}
+		for messageIndex, i := range input.Messages {
+			var content string
+			role := i.Role
+
+			// if function call, we might want to customize the role so we can display better that the "assistant called a json action"
+			// if an "assistant_function_call" role is defined, we use it, otherwise we use the role that is passed by in the request
+			if (i.FunctionCall != nil || i.ToolCalls != nil) && i.Role == "assistant" {
+				roleFn := "assistant_function_call"
+				r := config.Roles[roleFn]
+				if r != "" {
+					role = roleFn
+				}
+			}
+			r := config.Roles[role]
+			contentExists := i.Content != nil && i.StringContent != ""
+
+			fcall := i.FunctionCall
+			if len(i.ToolCalls) > 0 {
+				fcall = i.ToolCalls
+			}
+
+			// First attempt to populate content via a chat message specific template
+			if config.TemplateConfig.ChatMessage != "" {
+				chatMessageData := model.ChatMessageTemplateData{
+					SystemPrompt: config.SystemPrompt,
+					Role:         r,
+					RoleName:     role,
+					Content:      i.StringContent,
+					FunctionCall: fcall,
+					FunctionName: i.Name,
+					LastMessage:  messageIndex == (len(input.Messages) - 1),
+					Function:     config.Grammar != "" && (messageIndex == (len(input.Messages) - 1)),
+					MessageIndex: messageIndex,
+				}
+				templatedChatMessage, err := ml.EvaluateTemplateForChatMessage(config.TemplateConfig.ChatMessage, chatMessageData)
+				if err != nil {
+					log.Error().Err(err).Interface("message", chatMessageData).Str("template", config.TemplateConfig.ChatMessage).Msg("error processing message with template, skipping")
+				} else {
+					if templatedChatMessage == "" {
+						log.Warn().Msgf("template \"%s\" produced blank output for %+v. Skipping!", config.TemplateConfig.ChatMessage, chatMessageData)
+						continue // TODO: This continue is here intentionally to skip over the line `mess = append(mess, content)` below, and to prevent the sprintf
+					}
+					log.Debug().Msgf("templated message for chat: %s", templatedChatMessage)
+					content = templatedChatMessage
+				}
+			}
+
+			marshalAnyRole := func(f any) {
+				j, err := json.Marshal(f)
+				if err == nil {
+					if contentExists {
+						content += "\n" + fmt.Sprint(r, " ", string(j))
+					} else {
+						content = fmt.Sprint(r, " ", string(j))
+					}
+				}
+			}
+			marshalAny := func(f any) {
+				j, err := json.Marshal(f)
+				if err == nil {
+					if contentExists {
+						content += "\n" + string(j)
+					} else {
+						content = string(j)
+					}
+				}
+			}
+			// If this model doesn't have such a template, or if that template fails to return a value, template at the message level.
+			if content == "" {
+				if r != "" {
+					if contentExists {
+						content = fmt.Sprint(r, i.StringContent)
+					}
+
+					if i.FunctionCall != nil {
+						marshalAnyRole(i.FunctionCall)
+					}
+					if i.ToolCalls != nil {
+						marshalAnyRole(i.ToolCalls)
+					}
+				} else {
+					if contentExists {
+						content = fmt.Sprint(i.StringContent)
+					}
+					if i.FunctionCall != nil {
+						marshalAny(i.FunctionCall)
+					}
+					if i.ToolCalls != nil {
+						marshalAny(i.ToolCalls)
+					}
+				}
+				// Special Handling: System. We care if it was printed at all, not the r branch, so check seperately
+				if contentExists && role == "system" {
+					suppressConfigSystemPrompt = true
+				}
+			}
+
+			mess = append(mess, content)
+		}
+
+		joinCharacter := "\n"
+		if config.TemplateConfig.JoinChatMessagesByCharacter != nil {
+			joinCharacter = *config.TemplateConfig.JoinChatMessagesByCharacter
+		}
+
+		predInput = strings.Join(mess, joinCharacter)
+		log.Debug().Msgf("Prompt (before templating): %s", predInput)
+
+		templateFile := ""
+
+		// A model can have a "file.bin.tmpl" file associated with a prompt template prefix
+		if ml.ExistsInModelPath(fmt.Sprintf("%s.tmpl", config.Model)) {
+			templateFile = config.Model
+		}
+
+		if config.TemplateConfig.Chat != "" && !shouldUseFn {
+			templateFile = config.TemplateConfig.Chat
+		}
+
+		if config.TemplateConfig.Functions != "" && shouldUseFn {
+			templateFile = config.TemplateConfig.Functions
+		}
+
+		if templateFile != "" {
+			templatedInput, err := ml.EvaluateTemplateForPrompt(model.ChatPromptTemplate, templateFile, model.PromptTemplateData{
+				SystemPrompt:         config.SystemPrompt,
+				SuppressSystemPrompt: suppressConfigSystemPrompt,
+				Input:                predInput,
+				Functions:            funcs,
+			})
+			if err == nil {
+				predInput = templatedInput
+				log.Debug().Msgf("Template found, input modified to: %s", predInput)
+			} else {
+				log.Debug().Msgf("Template failed loading: %s", err.Error())
+			}
+		}
+
+		log.Debug().Msgf("Prompt (after templating): %s", predInput)
+		if shouldUseFn && config.Grammar != "" {
+			log.Debug().Msgf("Grammar: %+v", config.Grammar)
+		}
+	}
+
+	return predInput
+}
+
+// storeCompletion persists a chat completion requested with `store: true`
+func storeCompletion(storedCompletions *services.StoredCompletionsService, apiKeyID string, input *schema.OpenAIRequest, messages []schema.Message, resp schema.OpenAIResponse) {
+	if err := storedCompletions.Store(schema.StoredCompletion{OpenAIResponse: resp, Metadata: input.Metadata}, messages, apiKeyID); err != nil {
+		log.Error().Err(err).Str("id", resp.ID).Msg("failed to store the chat completion")
+	}
+}
+
+// toolCallID returns the ID of a call of the answer, the same in all its chunks
+func toolCallID(completionID string, index int) string {
+	return fmt.Sprintf("call_%s_%d", completionID, index)
+}
+
+// parallelToolCalls keeps the first call of the answer when the request disables the parallel calls
+func parallelToolCalls(input *schema.OpenAIRequest, results []functions.FuncCallResults) []functions.FuncCallResults {
+	if input.ParallelToolCalls != nil && !*input.ParallelToolCalls && len(results) > 1 {
+		return results[:1]
+	}
+	return results
+}
+
+// mergeToolCallDeltas accumulates the streamed tool call chunks into complete tool calls
+func mergeToolCallDeltas(toolCalls []schema.ToolCall, deltas []schema.ToolCall) []schema.ToolCall {
+	for _, d := range deltas {
+		if d.Index >= len(toolCalls) {
+			toolCalls = append(toolCalls, make([]schema.ToolCall, d.Index-len(toolCalls)+1)...)
+		}
+		tc := &toolCalls[d.Index]
+		tc.Index = d.Index
+		if d.ID != "" {
+			tc.ID = d.ID
+		}
+		if d.Type != "" {
+			tc.Type = d.Type
+		}
+		tc.FunctionCall.Name += d.FunctionCall.Name
+		tc.FunctionCall.Arguments += d.FunctionCall.Arguments
+	}
+	return toolCalls
+}
+
+// learnMemories stores the facts worth remembering from the last exchange, it's meant to run in background
+func learnMemories(memories *services.MemoryService, o *config.ApplicationConfig, input *schema.OpenAIRequest, reply string) {
+	if err := memories.Learn(o.Context, input.User, input.Messages, reply); err != nil {
+		log.Error().Err(err).Str("user", input.User).Msg("failed to learn memories from the conversation")
+	}
+}
+
 func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, ml *model.ModelLoader, o *config.ApplicationConfig, funcResults []functions.FuncCallResults, result, prompt string) (string, error) {
 
 	if len(funcResults) == 0 && result != "" {
@@ -624,7 +876,7 @@ func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, m
 		case string:
 			if message != "" {
 				log.Debug().Msgf("Reply received from LLM: %s", message)
-				message = backend.Finetune(*config, prompt, message)
+				message = backend.TransformResponse(ml, *config, prompt, backend.Finetune(*config, prompt, message))
 				log.Debug().Msgf("Reply received from LLM(finetuned): %s", message)
 
 				return message, nil
@@ -652,5 +904,5 @@ func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, m
 		log.Error().Err(err).Msg("prediction failed")
 		return "", err
 	}
-	return backend.Finetune(*config, prompt, prediction.Response), nil
+	return backend.TransformResponse(ml, *config, prompt, backend.Finetune(*config, prompt, prediction.Response)), nil
 }
@@ -0,0 +1,83 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelToolCalls(t *testing.T) {
+	results := []functions.FuncCallResults{{Name: "add", Arguments: `{"x":1}`}, {Name: "subtract", Arguments: `{"x":2}`}}
+	enabled, disabled := true, false
+
+	assert.Len(t, parallelToolCalls(&schema.OpenAIRequest{}, results), 2)
+	assert.Len(t, parallelToolCalls(&schema.OpenAIRequest{ParallelToolCalls: &enabled}, results), 2)
+	assert.Equal(t, results[:1], parallelToolCalls(&schema.OpenAIRequest{ParallelToolCalls: &disabled}, results))
+}
+
+func TestMergeToolCallDeltas(t *testing.T) {
+	// the deltas of two calls: the first of each has the ID, the type and the name, the next ones the arguments
+	deltas := []schema.ToolCall{
+		{Index: 0, ID: toolCallID("chatcmpl-1", 0), Type: "function", FunctionCall: schema.FunctionCall{Name: "add"}},
+		{Index: 0, FunctionCall: schema.FunctionCall{Arguments: `{"x":1}`}},
+		{Index: 1, ID: toolCallID("chatcmpl-1", 1), Type: "function", FunctionCall: schema.FunctionCall{Name: "subtract"}},
+		{Index: 1, FunctionCall: schema.FunctionCall{Arguments: `{"x":2}`}},
+	}
+	toolCalls := []schema.ToolCall{}
+	for _, d := range deltas {
+		toolCalls = mergeToolCallDeltas(toolCalls, []schema.ToolCall{d})
+	}
+
+	assert.Equal(t, []schema.ToolCall{
+		{Index: 0, ID: "call_chatcmpl-1_0", Type: "function", FunctionCall: schema.FunctionCall{Name: "add", Arguments: `{"x":1}`}},
+		{Index: 1, ID: "call_chatcmpl-1_1", Type: "function", FunctionCall: schema.FunctionCall{Name: "subtract", Arguments: `{"x":2}`}},
+	}, toolCalls)
+
+	// the deltas of the arguments don't repeat the ID, which the clients would concatenate
+	delta, err := json.Marshal(deltas[1])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"index": 0, "function": {"arguments": "{\"x\":1}"}}`, string(delta))
+}
+
+func TestTemplateMessages(t *testing.T) {
+	ml := model.NewModelLoader(t.TempDir())
+	message := func(role, content string) schema.Message {
+		return schema.Message{Role: role, Content: content, StringContent: content}
+	}
+	conversation := []schema.Message{message("user", "Hi"), message("assistant", "Hello")}
+
+	cfg := &config.BackendConfig{Roles: map[string]string{"system": "<|system|>", "user": "<|user|>", "assistant": "<|assistant|>"}}
+	cfg.SystemPrompt = "You are helpful."
+	cfg.TemplateConfig.Chat = "{{if not .SuppressSystemPrompt}}{{.SystemPrompt}}\n{{end}}{{.Input}}\n<|assistant|>"
+
+	t.Run("prefixes the messages with their role and renders the chat template", func(t *testing.T) {
+		prompt := TemplateMessages(cfg, &schema.OpenAIRequest{Messages: conversation}, ml, nil, false)
+		assert.Equal(t, "You are helpful.\n<|user|>Hi\n<|assistant|>Hello\n<|assistant|>", prompt)
+	})
+
+	t.Run("replaces the system prompt of the config by the one of the messages", func(t *testing.T) {
+		messages := append([]schema.Message{message("system", "Be brief.")}, conversation...)
+		prompt := TemplateMessages(cfg, &schema.OpenAIRequest{Messages: messages}, ml, nil, false)
+		assert.Equal(t, "<|system|>Be brief.\n<|user|>Hi\n<|assistant|>Hello\n<|assistant|>", prompt)
+	})
+
+	t.Run("renders each message with the chat message template", func(t *testing.T) {
+		join := ""
+		cfg := &config.BackendConfig{}
+		cfg.TemplateConfig.ChatMessage = "<{{.RoleName}}{{if .LastMessage}} last{{end}}>{{.Content}}</{{.RoleName}}>"
+		cfg.TemplateConfig.JoinChatMessagesByCharacter = &join
+		prompt := TemplateMessages(cfg, &schema.OpenAIRequest{Messages: conversation}, ml, nil, false)
+		assert.Equal(t, "<user>Hi</user><assistant last>Hello</assistant>", prompt)
+	})
+
+	t.Run("leaves the messages to the tokenizer template", func(t *testing.T) {
+		cfg := &config.BackendConfig{}
+		cfg.TemplateConfig.UseTokenizerTemplate = true
+		assert.Empty(t, TemplateMessages(cfg, &schema.OpenAIRequest{Messages: conversation}, ml, nil, false))
+	})
+}
@@ -0,0 +1,245 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/websocket"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// chatWebSocketMessage is a message sent by the client, of type cancel to stop the generation in progress
+type chatWebSocketMessage struct {
+	Type string `json:"type"`
+}
+
+const chatWebSocketCancel = "cancel"
+
+var chatWebSocketUpgrader = websocket.Upgrader{}
+
+// ChatWebSocketEndpoint offers the chat completions over a WebSocket, for the clients handling them better than SSE.
+// Every message sent by the client is a chat completion request, which is answered with the same chunks the
+// streaming endpoint sends, one per message, followed by [DONE]. Sending {"type": "cancel"} stops the generation.
+// Requests which aren't WebSocket upgrades are passed to the next handler.
+// @Summary Stream chat completions over a WebSocket.
+// @Param request body schema.OpenAIRequest true "query params"
+// @Success 200 {object} schema.OpenAIResponse "Response"
+// @Router /v1/chat/completions [get]
+func ChatWebSocketEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if !isWebSocketUpgrade(c) {
+			return c.Next()
+		}
+
+		// The fiber context isn't usable once the connection is hijacked: copy what's needed
+		forwarded := http.Header{}
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			if k := string(key); !isWebSocketHeader(k) {
+				forwarded.Add(k, string(value))
+			}
+		})
+		handler := c.App().Handler()
+		path := c.Path()
+
+		return hijackWebSocket(c, &chatWebSocketUpgrader, func(conn *websocket.Conn, remoteAddr net.Addr) {
+			serveChatWebSocket(conn, remoteAddr, handler, path, forwarded)
+		})
+	}
+}
+
+// hijackWebSocket upgrades the request to a WebSocket served by serve, which gets what it needs from the context
+// beforehand: the context isn't usable once the connection is hijacked
+func hijackWebSocket(c *fiber.Ctx, upgrader *websocket.Upgrader, serve func(conn *websocket.Conn, remoteAddr net.Addr)) error {
+	if c.Get("Sec-WebSocket-Version") != "13" || c.Get("Sec-WebSocket-Key") == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "unsupported websocket handshake")
+	}
+
+	upgrade := &http.Request{
+		Method: http.MethodGet,
+		Host:   string(c.Request().Host()),
+		URL:    &url.URL{Path: c.Path()},
+		Header: http.Header{},
+	}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		upgrade.Header.Add(string(key), string(value))
+	})
+
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(netConn net.Conn) {
+		w := &hijackedResponseWriter{conn: netConn, header: http.Header{}}
+		conn, err := upgrader.Upgrade(w, upgrade, nil)
+		if err != nil {
+			log.Debug().Err(err).Msg("websocket upgrade failed")
+			return
+		}
+		defer conn.Close()
+
+		serve(conn, netConn.RemoteAddr())
+	})
+	return nil
+}
+
+// serveChatWebSocket runs the chat completion requests received on conn through the app,
+// and forwards the chunks of their SSE response
+func serveChatWebSocket(conn *websocket.Conn, remoteAddr net.Addr, handler fasthttp.RequestHandler, path string, header http.Header) {
+	var (
+		writeMu sync.Mutex
+		mu      sync.Mutex
+		stream  *fasthttp.Response
+		wg      sync.WaitGroup
+	)
+
+	send := func(data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+	sendError := func(code int, message string) {
+		data, _ := json.Marshal(schema.ErrorResponse{Error: &schema.APIError{Message: message, Code: code}})
+		send(data)
+	}
+	cancel := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if stream != nil {
+			// The chat endpoint stops generating as soon as it fails writing to the stream
+			stream.CloseBodyStream()
+			stream = nil
+		}
+	}
+
+	defer wg.Wait()
+	defer cancel()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		msg := chatWebSocketMessage{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			sendError(fiber.StatusBadRequest, "failed parsing message: "+err.Error())
+			continue
+		}
+		if msg.Type == chatWebSocketCancel {
+			cancel()
+			continue
+		}
+
+		mu.Lock()
+		busy := stream != nil
+		mu.Unlock()
+		if busy {
+			sendError(fiber.StatusConflict, "a completion is already in progress, cancel it first")
+			continue
+		}
+
+		body, err := withStream(data)
+		if err != nil {
+			sendError(fiber.StatusBadRequest, "failed parsing request: "+err.Error())
+			continue
+		}
+
+		req := fasthttp.Request{}
+		req.Header.SetMethod(fiber.MethodPost)
+		req.SetRequestURI(path)
+		for k, values := range header {
+			for _, v := range values {
+				req.Header.Add(k, v)
+			}
+		}
+		req.Header.SetContentType(fiber.MIMEApplicationJSON)
+		req.SetBody(body)
+
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Init(&req, remoteAddr, nil)
+		handler(ctx)
+
+		if !ctx.Response.IsBodyStream() {
+			// Errors and non-streamed replies come as a single message
+			send(ctx.Response.Body())
+			continue
+		}
+
+		reader := ctx.Response.BodyStream()
+		mu.Lock()
+		stream = &ctx.Response
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				if stream == &ctx.Response {
+					ctx.Response.CloseBodyStream()
+					stream = nil
+				}
+				mu.Unlock()
+			}()
+
+			r := bufio.NewReader(reader)
+			for {
+				line, err := r.ReadBytes('\n')
+				if data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: ")); ok {
+					if err := send(data); err != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// withStream returns the request with streaming enabled, as chunks are what the WebSocket carries
+func withStream(data []byte) ([]byte, error) {
+	request := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, err
+	}
+	request["stream"] = json.RawMessage("true")
+	return json.Marshal(request)
+}
+
+func isWebSocketUpgrade(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") &&
+		strings.Contains(strings.ToLower(c.Get(fiber.HeaderConnection)), "upgrade")
+}
+
+func isWebSocketHeader(key string) bool {
+	key = strings.ToLower(key)
+	return key == "connection" || key == "upgrade" || key == "content-length" || strings.HasPrefix(key, "sec-websocket-")
+}
+
+// hijackedResponseWriter hands the connection hijacked from fasthttp to the websocket upgrader
+type hijackedResponseWriter struct {
+	conn   net.Conn
+	header http.Header
+}
+
+func (w *hijackedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *hijackedResponseWriter) Write(b []byte) (int, error) {
+	return w.conn.Write(b)
+}
+
+func (w *hijackedResponseWriter) WriteHeader(int) {}
+
+func (w *hijackedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, bufio.NewReadWriter(bufio.NewReader(w.conn), bufio.NewWriter(w.conn)), nil
+}
@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestChatWebSocketEndpoint(t *testing.T) {
+	var receivedAuth string
+	var receivedStream bool
+	cancelled := make(chan struct{}, 1)
+
+	app := fiber.New()
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		receivedAuth = c.Get("Authorization")
+		request := struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}{}
+		if err := c.BodyParser(&request); err != nil {
+			return err
+		}
+		receivedStream = request.Stream
+		if request.Model == "missing" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "model not found"})
+		}
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			for i := 0; request.Model == "endless" || i < 2; i++ {
+				fmt.Fprintf(w, "data: {\"id\":\"%d\"}\n\n", i)
+				if err := w.Flush(); err != nil {
+					cancelled <- struct{}{}
+					return
+				}
+				if request.Model == "endless" {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			w.WriteString("data: [DONE]\n\n")
+			w.Flush()
+		}))
+		return nil
+	})
+	app.Get("/v1/chat/completions", ChatWebSocketEndpoint(), func(c *fiber.Ctx) error {
+		return c.SendString("list")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	url := "ws://" + ln.Addr().String() + "/v1/chat/completions"
+	dial := func(t *testing.T) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": []string{"Bearer secret"}})
+		assert.NoError(t, err)
+		return conn
+	}
+	read := func(t *testing.T, conn *websocket.Conn) string {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("passes plain requests to the next handler", func(t *testing.T) {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/v1/chat/completions")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("streams the chunks of the completion", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"foo","messages":[]}`)))
+		assert.Equal(t, `{"id":"0"}`, read(t, conn))
+		assert.Equal(t, `{"id":"1"}`, read(t, conn))
+		assert.Equal(t, `[DONE]`, read(t, conn))
+		assert.Equal(t, "Bearer secret", receivedAuth)
+		assert.True(t, receivedStream)
+	})
+
+	t.Run("sends the errors as a single message", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"missing"}`)))
+		response := map[string]string{}
+		assert.NoError(t, json.Unmarshal([]byte(read(t, conn)), &response))
+		assert.Equal(t, "model not found", response["error"])
+	})
+
+	t.Run("cancels the generation in progress", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"endless"}`)))
+		assert.Equal(t, `{"id":"0"}`, read(t, conn))
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"cancel"}`)))
+
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the generation was not cancelled")
+		}
+	})
+}
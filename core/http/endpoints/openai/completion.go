@@ -10,14 +10,15 @@ import (
 
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/pkg/functions"
 	model "github.com/mudler/LocalAI/pkg/model"
 	"github.com/rs/zerolog/log"
-	"github.com/valyala/fasthttp"
 )
 
 // CompletionEndpoint is the OpenAI Completion API endpoint https://platform.openai.com/docs/api-reference/completions
@@ -25,20 +26,26 @@ import (
 // @Param request body schema.OpenAIRequest true "query params"
 // @Success 200 {object} schema.OpenAIResponse "Response"
 // @Router /v1/completions [post]
-func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, budgets *services.TokenBudgetService, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	id := uuid.New().String()
 	created := int(time.Now().Unix())
 
 	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse) {
+		offset := 0
 		ComputeChoices(req, s, config, appConfig, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+			logprobs := completionLogprobs(usage.Logprobs, offset)
+			for _, token := range usage.Logprobs {
+				offset += len(token.Token)
+			}
 			resp := schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
 				Choices: []schema.Choice{
 					{
-						Index: 0,
-						Text:  s,
+						Index:    0,
+						Text:     s,
+						Logprobs: logprobs,
 					},
 				},
 				Object: "text_completion",
@@ -57,6 +64,7 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 	}
 
 	return func(c *fiber.Ctx) error {
+		started := time.Now()
 		modelFile, input, err := readRequest(c, cl, ml, appConfig, true)
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
@@ -68,6 +76,15 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
+		warning, err := fiberContext.CheckModel(c, config)
+		if err != nil {
+			return maintenanceResponse(c, err, config, input, id, created, false)
+		}
+		apiKey := fiberContext.APIKeyFromContext(c)
+		budget, err := beginTokenBudget(budgets, apiKey, config, input)
+		if err != nil {
+			return err
+		}
 
 		if config.ResponseFormatMap != nil {
 			d := schema.ChatCompletionResponseFormat{}
@@ -81,6 +98,7 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		config.Grammar = input.Grammar
 
 		log.Debug().Msgf("Parameter Config: %+v", config)
+		fingerprint := services.SystemFingerprint(*config, ml)
 
 		if input.Stream {
 			log.Debug().Msgf("Stream request received")
@@ -103,6 +121,11 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			templateFile = config.TemplateConfig.Completion
 		}
 
+		// raw prompts are sent as they were given
+		if config.Raw {
+			templateFile = ""
+		}
+
 		if input.Stream {
 			if len(config.PromptStrings) > 1 {
 				return errors.New("cannot handle more than 1 `PromptStrings` when Streaming")
@@ -125,9 +148,11 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 
 			go process(predInput, input, config, ml, responses)
 
-			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
-
+			c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+				usage := schema.OpenAIUsage{}
 				for ev := range responses {
+					usage = ev.Usage
+					ev.SystemFingerprint = fingerprint
 					var buf bytes.Buffer
 					enc := json.NewEncoder(&buf)
 					enc.Encode(ev)
@@ -147,7 +172,14 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 							FinishReason: "stop",
 						},
 					},
-					Object: "text_completion",
+					Object:            "text_completion",
+					SystemFingerprint: fingerprint,
+					Warning:           responseWarning(warning, input),
+					Timings:           requestTimings(config, input, started),
+				}
+				if budget != nil {
+					budgets.Consume(apiKey, budget, usage.TotalTokens)
+					resp.TokenBudget = budget
 				}
 				respData, _ := json.Marshal(resp)
 
@@ -186,6 +218,11 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			totalTokenUsage.Prompt += tokenUsage.Prompt
 			totalTokenUsage.Completion += tokenUsage.Completion
 
+			for i := range r {
+				if r[i].Logprobs != nil {
+					r[i].Logprobs = completionLogprobs(r[i].Logprobs.Content, 0)
+				}
+			}
 			result = append(result, r...)
 		}
 
@@ -195,11 +232,18 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
 			Choices: result,
 			Object:  "text_completion",
+			Warning: responseWarning(warning, input),
+			Timings: requestTimings(config, input, started),
 			Usage: schema.OpenAIUsage{
 				PromptTokens:     totalTokenUsage.Prompt,
 				CompletionTokens: totalTokenUsage.Completion,
 				TotalTokens:      totalTokenUsage.Prompt + totalTokenUsage.Completion,
 			},
+			SystemFingerprint: fingerprint,
+		}
+		if budget != nil {
+			budgets.Consume(apiKey, budget, resp.Usage.TotalTokens)
+			resp.TokenBudget = budget
 		}
 
 		jsonResult, _ := json.Marshal(resp)
@@ -209,3 +253,24 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		return c.JSON(resp)
 	}
 }
+
+// completionLogprobs returns the log probabilities of the tokens in the format of the completions, the text offsets
+// starting at offset. It returns nil when there are no tokens
+func completionLogprobs(content []schema.LogprobContent, offset int) *schema.Logprobs {
+	if len(content) == 0 {
+		return nil
+	}
+	logprobs := &schema.Logprobs{}
+	for _, token := range content {
+		top := map[string]float64{}
+		for _, t := range token.TopLogprobs {
+			top[t.Token] = t.Logprob
+		}
+		logprobs.Tokens = append(logprobs.Tokens, token.Token)
+		logprobs.TokenLogprobs = append(logprobs.TokenLogprobs, token.Logprob)
+		logprobs.TopLogprobs = append(logprobs.TopLogprobs, top)
+		logprobs.TextOffset = append(logprobs.TextOffset, offset)
+		offset += len(token.Token)
+	}
+	return logprobs
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -23,6 +25,7 @@ import (
 // @Router /v1/edits [post]
 func EditEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
+		started := time.Now()
 		modelFile, input, err := readRequest(c, cl, ml, appConfig, true)
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
@@ -32,6 +35,10 @@ func EditEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConf
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
+		warning, err := fiberContext.CheckModel(c, config)
+		if err != nil {
+			return err
+		}
 
 		log.Debug().Msgf("Parameter Config: %+v", config)
 
@@ -83,11 +90,14 @@ func EditEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConf
 			Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
 			Choices: result,
 			Object:  "edit",
+			Warning: responseWarning(warning, input),
+			Timings: requestTimings(config, input, started),
 			Usage: schema.OpenAIUsage{
 				PromptTokens:     totalTokenUsage.Prompt,
 				CompletionTokens: totalTokenUsage.Completion,
 				TotalTokens:      totalTokenUsage.Prompt + totalTokenUsage.Completion,
 			},
+			SystemFingerprint: services.SystemFingerprint(*config, ml),
 		}
 
 		jsonResult, _ := json.Marshal(resp)
@@ -3,10 +3,12 @@ package openai
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/pkg/model"
 
 	"github.com/google/uuid"
@@ -23,45 +25,29 @@ import (
 // @Router /v1/embeddings [post]
 func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
+		started := time.Now()
 		model, input, err := readRequest(c, cl, ml, appConfig, true)
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
 
+		if len(input.Models) > 0 {
+			return multiModelEmbeddings(c, cl, ml, appConfig, input)
+		}
+
 		config, input, err := mergeRequestWithConfig(model, input, cl, ml, appConfig.Debug, appConfig.Threads, appConfig.ContextSize, appConfig.F16)
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
-
-		log.Debug().Msgf("Parameter Config: %+v", config)
-		items := []schema.Item{}
-
-		for i, s := range config.InputToken {
-			// get the model function to call for the result
-			embedFn, err := backend.ModelEmbedding("", s, ml, *config, appConfig)
-			if err != nil {
-				return err
-			}
-
-			embeddings, err := embedFn()
-			if err != nil {
-				return err
-			}
-			items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
+		warning, err := fiberContext.CheckModel(c, config)
+		if err != nil {
+			return err
 		}
 
-		for i, s := range config.InputStrings {
-			// get the model function to call for the result
-			embedFn, err := backend.ModelEmbedding(s, []int{}, ml, *config, appConfig)
-			if err != nil {
-				return err
-			}
-
-			embeddings, err := embedFn()
-			if err != nil {
-				return err
-			}
-			items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
+		log.Debug().Msgf("Parameter Config: %+v", config)
+		items, err := embeddings(config, ml, appConfig)
+		if err != nil {
+			return err
 		}
 
 		id := uuid.New().String()
@@ -72,6 +58,8 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
 			Data:    items,
 			Object:  "list",
+			Warning: warning,
+			Timings: requestTimings(config, input, started),
 		}
 
 		jsonResult, _ := json.Marshal(resp)
@@ -81,3 +69,97 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		return c.JSON(resp)
 	}
 }
+
+// embeddings returns the embeddings of the inputs of the config, the tokens first
+func embeddings(config *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig) ([]schema.Item, error) {
+	items := []schema.Item{}
+
+	for i, s := range config.InputToken {
+		// get the model function to call for the result
+		embedFn, err := backend.ModelEmbedding("", s, ml, *config, appConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		embeddings, err := embedFn()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
+	}
+
+	for i, s := range config.InputStrings {
+		// get the model function to call for the result
+		embedFn, err := backend.ModelEmbedding(s, []int{}, ml, *config, appConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		embeddings, err := embedFn()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
+	}
+
+	return items, nil
+}
+
+// multiModelEmbeddings answers a request with several models, with the embeddings of its inputs grouped by model.
+// The models compute them at once, unless a single backend can be loaded at a time
+func multiModelEmbeddings(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, input *schema.OpenAIRequest) error {
+	configs := make([]*config.BackendConfig, len(input.Models))
+	requests := make([]*schema.OpenAIRequest, len(input.Models))
+	groups := make([]schema.ModelEmbeddings, len(input.Models))
+	for i, name := range input.Models {
+		request := *input
+		request.Model = name
+		cfg, _, err := mergeRequestWithConfig(name, &request, cl, ml, appConfig.Debug, appConfig.Threads, appConfig.ContextSize, appConfig.F16)
+		if err != nil {
+			return fmt.Errorf("failed reading parameters of model %s from request:%w", name, err)
+		}
+		if cfg.Backend == config.ProxyBackend {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the proxied model %s can't be used in models", name))
+		}
+		groups[i].Warning, err = fiberContext.CheckModel(c, cfg)
+		if err != nil {
+			return err
+		}
+		configs[i], requests[i] = cfg, &request
+	}
+
+	errs := make([]error, len(configs))
+	embed := func(i int) {
+		started := time.Now()
+		items, err := embeddings(configs[i], ml, appConfig)
+		groups[i].Model, groups[i].Data, groups[i].Timings = input.Models[i], items, requestTimings(configs[i], requests[i], started)
+		errs[i] = err
+	}
+	if appConfig.SingleBackend {
+		for i := range configs {
+			embed(i)
+		}
+	} else {
+		wg := sync.WaitGroup{}
+		for i := range configs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				embed(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed computing the embeddings of model %s: %w", input.Models[i], err)
+		}
+	}
+
+	return c.JSON(&schema.OpenAIResponse{
+		ID:      uuid.New().String(),
+		Created: int(time.Now().Unix()),
+		Object:  "list",
+		Models:  groups,
+	})
+}
@@ -3,15 +3,17 @@ package openai
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"sync/atomic"
 	"time"
 
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/schema"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/pkg/scanner"
 	"github.com/mudler/LocalAI/pkg/utils"
 )
 
@@ -40,14 +42,23 @@ func UploadFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Appli
 		// Sanitize the filename to prevent directory traversal
 		filename := utils.SanitizeFileName(file.Filename)
 
-		savePath := filepath.Join(appConfig.UploadDir, filename)
-
 		// Check if file already exists
-		if _, err := os.Stat(savePath); !os.IsNotExist(err) {
+		if existing, err := appConfig.UploadStorage().Get(c.Context(), filename); err == nil {
+			existing.Close()
 			return c.Status(fiber.StatusBadRequest).SendString("File already exists")
 		}
 
-		err = c.SaveFile(file, savePath)
+		if err := fiberContext.ScanFormFile(c, appConfig, scanner.KindFile, file); err != nil {
+			return err
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Failed to save file: " + err.Error())
+		}
+		defer src.Close()
+
+		err = appConfig.UploadStorage().Put(c.Context(), filename, src, file.Size)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString("Failed to save file: " + err.Error())
 		}
@@ -146,7 +157,7 @@ func DeleteFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Appli
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
 
-		err = os.Remove(filepath.Join(appConfig.UploadDir, file.Filename))
+		err = appConfig.UploadStorage().Delete(c.Context(), file.Filename)
 		if err != nil {
 			// If the file doesn't exist then we should just continue to remove it
 			if !errors.Is(err, os.ErrNotExist) {
@@ -183,7 +194,13 @@ func GetFilesContentsEndpoint(cm *config.BackendConfigLoader, appConfig *config.
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
 
-		fileContents, err := os.ReadFile(filepath.Join(appConfig.UploadDir, file.Filename))
+		r, err := appConfig.UploadStorage().Get(c.Context(), file.Filename)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
+		}
+		defer r.Close()
+
+		fileContents, err := io.ReadAll(r)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).SendString(err.Error())
 		}
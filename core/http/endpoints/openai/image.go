@@ -15,12 +15,15 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/schema"
 
 	"github.com/mudler/LocalAI/core/backend"
 
 	"github.com/gofiber/fiber/v2"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/storage"
+	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -80,6 +83,10 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
+		warning, err := fiberContext.CheckModel(c, config)
+		if err != nil {
+			return err
+		}
 
 		src := ""
 		if input.File != "" {
@@ -108,6 +115,11 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 				}
 			}
 
+			fileData, err = utils.PreprocessImage(fileData, appConfig.ImageLimits())
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid source image: %s", err.Error()))
+			}
+
 			// Create a temporary file
 			outputFile, err := os.CreateTemp(appConfig.ImageDir, "b64")
 			if err != nil {
@@ -218,7 +230,17 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 					item.B64JSON = base64.StdEncoding.EncodeToString(data)
 				} else {
 					base := filepath.Base(output)
-					item.URL = baseURL + "/generated-images/" + base
+					if err := storage.PutFile(c.Context(), appConfig.ImageStorage(), base, output); err != nil {
+						return err
+					}
+					url, err := appConfig.ImageStorage().URL(base)
+					if err != nil {
+						return err
+					}
+					if strings.HasPrefix(url, "/") {
+						url = baseURL + url
+					}
+					item.URL = url
 				}
 
 				result = append(result, *item)
@@ -231,6 +253,7 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 			ID:      id,
 			Created: created,
 			Data:    result,
+			Warning: warning,
 		}
 
 		jsonResult, _ := json.Marshal(resp)
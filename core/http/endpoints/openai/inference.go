@@ -6,6 +6,7 @@ import (
 
 	"github.com/mudler/LocalAI/core/schema"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/reasoning"
 )
 
 func ComputeChoices(
@@ -28,16 +29,28 @@ func ComputeChoices(
 		images = append(images, m.StringImages...)
 	}
 
+	// The response template can only be applied to the whole response: when the model has one,
+	// the tokens are buffered and the transformed response is streamed as a single chunk
+	bufferResponse := tokenCallback != nil && config.TemplateConfig.Response != ""
+	streamCallback := tokenCallback
+	if bufferResponse {
+		streamCallback = nil
+	}
+
 	// get the model function to call for the result
-	predFunc, err := backend.ModelInference(req.Context, predInput, req.Messages, images, loader, *config, o, tokenCallback)
+	predFunc, err := backend.ModelInference(req.Context, predInput, req.Messages, images, loader, *config, o, streamCallback)
 	if err != nil {
 		return result, backend.TokenUsage{}, err
 	}
 
 	tokenUsage := backend.TokenUsage{}
+	// the warnings of the predictions add up, only the new ones are reported
+	warnings := 0
 
 	for i := 0; i < n; i++ {
 		prediction, err := predFunc()
+		req.Warnings = append(req.Warnings, prediction.Warnings[warnings:]...)
+		warnings = len(prediction.Warnings)
 		if err != nil {
 			return result, backend.TokenUsage{}, err
 		}
@@ -45,9 +58,34 @@ func ComputeChoices(
 		tokenUsage.Prompt += prediction.Usage.Prompt
 		tokenUsage.Completion += prediction.Usage.Completion
 
-		finetunedResponse := backend.Finetune(*config, predInput, prediction.Response)
+		response, thought := prediction.Response, ""
+		if config.Reasoning.Enabled() {
+			thought, response = reasoning.Split(config.Reasoning, response)
+		}
+
+		finetunedResponse := backend.TransformResponse(loader, *config, predInput, backend.Finetune(*config, predInput, response))
+		if bufferResponse {
+			usage := prediction.Usage
+			usage.Logprobs = prediction.Logprobs
+			tokenCallback(finetunedResponse, usage)
+		}
+		choices := len(result)
 		cb(finetunedResponse, &result)
 
+		if prediction.Logprobs != nil {
+			for i := choices; i < len(result); i++ {
+				result[i].Logprobs = &schema.Logprobs{Content: prediction.Logprobs}
+			}
+		}
+
+		if config.Reasoning.Mode == reasoning.ModeSeparate {
+			for _, choice := range result[choices:] {
+				if choice.Message != nil {
+					choice.Message.ReasoningContent = thought
+				}
+			}
+		}
+
 		//result = append(result, Choice{Text: prediction})
 
 	}
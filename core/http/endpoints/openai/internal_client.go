@@ -0,0 +1,79 @@
+package openai
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net"
+
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/valyala/fasthttp"
+)
+
+// internalClient sends requests to the app itself, through its middlewares, as the client of the request it was
+// created from: with its API key, and its address
+type internalClient struct {
+	handler    fasthttp.RequestHandler
+	remoteAddr net.Addr
+	header     map[string]string
+}
+
+// newInternalClient returns the client sending the requests as the client of c. The requests have the priority
+// given, or the default priority of the key when it's empty
+func newInternalClient(c *fiber.Ctx, priority string) *internalClient {
+	header := map[string]string{}
+	if priority != "" {
+		header[fiberContext.PriorityHeader] = priority
+	}
+	if key := fiberContext.APIKeyFromContext(c); key != "" {
+		header[fiber.HeaderAuthorization] = "Bearer " + key
+	}
+	return &internalClient{
+		handler:    c.App().Handler(),
+		remoteAddr: c.Context().RemoteAddr(),
+		header:     header,
+	}
+}
+
+// post sends the JSON body to path. The response is the one of the returned context, its body may be a stream
+func (ic *internalClient) post(path string, body []byte) *fasthttp.RequestCtx {
+	return ic.send(path, fiber.MIMEApplicationJSON, body)
+}
+
+// postFile sends the fields and the file to path as a multipart form
+func (ic *internalClient) postFile(path string, fields map[string]string, filename string, data []byte) (*fasthttp.RequestCtx, error) {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	for k, v := range fields {
+		if err := form.WriteField(k, v); err != nil {
+			return nil, err
+		}
+	}
+	file, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(data); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+	return ic.send(path, form.FormDataContentType(), body.Bytes()), nil
+}
+
+func (ic *internalClient) send(path, contentType string, body []byte) *fasthttp.RequestCtx {
+	req := fasthttp.Request{}
+	req.Header.SetMethod(fiber.MethodPost)
+	req.SetRequestURI(path)
+	for k, v := range ic.header {
+		req.Header.Set(k, v)
+	}
+	req.Header.SetContentType(contentType)
+	req.SetBody(body)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&req, ic.remoteAddr, nil)
+	ic.handler(ctx)
+	return ctx
+}
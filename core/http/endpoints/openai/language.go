@@ -0,0 +1,55 @@
+package openai
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/language"
+)
+
+// languageInstruction returns the instruction to answer in the language, strict is used
+// when retrying after an answer in another language
+func languageInstruction(code string, strict bool) string {
+	name := language.Name(code)
+	if strict {
+		return fmt.Sprintf("IMPORTANT: answer only in %s. Do not use any other language, even if the question or the context are in another language.", name)
+	}
+	return fmt.Sprintf("Answer in %s.", name)
+}
+
+// enforceLanguage returns the messages with the instruction to answer in the language appended to the last user message
+func enforceLanguage(messages []schema.Message, code string, strict bool) []schema.Message {
+	instruction := languageInstruction(code, strict)
+	messages = append([]schema.Message{}, messages...)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		content := strings.TrimSpace(messages[i].StringContent + "\n\n" + instruction)
+		messages[i].StringContent = content
+		messages[i].Content = content
+		return messages
+	}
+	return append(messages, schema.Message{Role: "system", Content: instruction, StringContent: instruction})
+}
+
+// answeredIn returns false when the language of an answer is detected, and it's not the one given
+func answeredIn(choices []schema.Choice, code string) bool {
+	if !language.Supported(code) {
+		return true
+	}
+	for _, c := range choices {
+		if c.Message == nil {
+			continue
+		}
+		s, ok := c.Message.Content.(*string)
+		if !ok || s == nil {
+			continue
+		}
+		if detected, ok := language.Detect(*s); ok && detected != strings.ToLower(code) {
+			return false
+		}
+	}
+	return true
+}
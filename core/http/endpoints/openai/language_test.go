@@ -0,0 +1,40 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceLanguage(t *testing.T) {
+	messages := []schema.Message{
+		{Role: "system", StringContent: "You are a helpful assistant."},
+		{Role: "user", StringContent: "What is the capital of France?"},
+	}
+
+	enforced := enforceLanguage(messages, "it", false)
+	assert.Equal(t, "What is the capital of France?\n\nAnswer in Italian.", enforced[1].StringContent)
+	assert.Equal(t, enforced[1].StringContent, enforced[1].Content)
+	// the messages of the request are kept, to retry with another instruction
+	assert.Equal(t, "What is the capital of France?", messages[1].StringContent)
+
+	enforced = enforceLanguage(messages[:1], "it", true)
+	assert.Len(t, enforced, 2)
+	assert.Equal(t, "system", enforced[1].Role)
+	assert.Contains(t, enforced[1].StringContent, "answer only in Italian")
+}
+
+func TestAnsweredIn(t *testing.T) {
+	english := "The capital of France is Paris, and it is also the largest city of the country."
+	italian := "La capitale della Francia è Parigi, ed è anche la città più grande del paese, con una storia che non ha eguali."
+	choices := func(s string) []schema.Choice {
+		return []schema.Choice{{Message: &schema.Message{Role: "assistant", Content: &s}}}
+	}
+
+	assert.False(t, answeredIn(choices(english), "it"))
+	assert.True(t, answeredIn(choices(italian), "it"))
+	// short answers and unknown languages can't be checked
+	assert.True(t, answeredIn(choices("Parigi."), "it"))
+	assert.True(t, answeredIn(choices(english), "tlh"))
+}
@@ -1,6 +1,13 @@
 package openai
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
@@ -10,6 +17,7 @@ import (
 
 // ListModelsEndpoint is the OpenAI Models API endpoint https://platform.openai.com/docs/api-reference/models
 // @Summary List and describe the various models available in the API.
+// @Param capability query string false "Only list the models with this capability tag (e.g. vision)"
 // @Success 200 {object} schema.ModelsDataResponse "Response"
 // @Router /v1/models [get]
 func ListModelsEndpoint(bcl *config.BackendConfigLoader, ml *model.ModelLoader) func(ctx *fiber.Ctx) error {
@@ -24,10 +32,40 @@ func ListModelsEndpoint(bcl *config.BackendConfigLoader, ml *model.ModelLoader)
 		if err != nil {
 			return err
 		}
-		return c.JSON(schema.ModelsDataResponse{
+		if capability := c.Query("capability"); capability != "" {
+			dataModels = withCapability(dataModels, capability)
+		}
+		resp := schema.ModelsDataResponse{
 			Object: "list",
 			Data:   dataModels,
-		})
+		}
+		dat, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(dat)
+		return sendWithETag(c, hex.EncodeToString(sum[:]), resp)
+	}
+}
+
+// GetModelEndpoint is the OpenAI Models API endpoint https://platform.openai.com/docs/api-reference/models/retrieve
+// @Summary Describe a model, its ETag is the digest of the model.
+// @Param model path string true "Model name"
+// @Success 200 {object} schema.OpenAIModel "Response"
+// @Router /v1/models/{model} [get]
+func GetModelEndpoint(bcl *config.BackendConfigLoader, ml *model.ModelLoader) func(ctx *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		name := c.Params("model")
+		dataModels, err := modelList(bcl, ml, "", true)
+		if err != nil {
+			return err
+		}
+		for _, m := range dataModels {
+			if m.ID == name {
+				return sendWithETag(c, m.Digest, m)
+			}
+		}
+		return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("model %q not found", name))
 	}
 }
 
@@ -42,8 +80,63 @@ func modelList(bcl *config.BackendConfigLoader, ml *model.ModelLoader, filter st
 
 	// Then iterate through the loose files:
 	for _, m := range models {
-		dataModels = append(dataModels, schema.OpenAIModel{ID: m, Object: "model"})
+		digest := services.DigestModel(bcl, ml, m)
+		entry := schema.OpenAIModel{
+			ID:         m,
+			Object:     "model",
+			Digest:     digest.Digest,
+			Checksum:   digest.Checksum,
+			ConfigHash: digest.ConfigHash,
+		}
+		if cfg, exists := bcl.GetBackendConfig(m); exists {
+			if cfg.Deprecation.Deprecated {
+				entry.Deprecated = true
+				entry.Replacement = cfg.Deprecation.Replacement
+				entry.Sunset = cfg.Deprecation.Sunset
+			}
+			if cfg.Maintenance.Enabled {
+				entry.Maintenance = true
+				entry.MaintenanceUntil = cfg.Maintenance.Until
+			}
+			entry.Capabilities = cfg.ModelCapabilities()
+			if reduced, ok := ml.ReducedSettings(cfg.Model); ok {
+				entry.ReducedSettings = reduced.Changes()
+			}
+		}
+		dataModels = append(dataModels, entry)
 	}
 
 	return dataModels, nil
 }
+
+// withCapability returns the models with the capability tag
+func withCapability(models []schema.OpenAIModel, capability string) []schema.OpenAIModel {
+	filtered := []schema.OpenAIModel{}
+	for _, m := range models {
+		if slices.Contains(m.Capabilities, capability) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// sendWithETag sends data with its ETag, or answers 304 when the client already has it
+func sendWithETag(c *fiber.Ctx, etag string, data interface{}) error {
+	etag = `"` + etag + `"`
+	c.Set(fiber.HeaderETag, etag)
+	if ifNoneMatch(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(data)
+}
+
+// ifNoneMatch returns whether the If-None-Match header matches etag, with the weak comparison of RFC 9110
+func ifNoneMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIfNoneMatch(t *testing.T) {
+	etag := `"abc"`
+
+	assert.True(t, ifNoneMatch(`"abc"`, etag))
+	assert.True(t, ifNoneMatch(`W/"abc"`, etag))
+	assert.True(t, ifNoneMatch(`"def", "abc"`, etag))
+	assert.True(t, ifNoneMatch(`*`, etag))
+
+	assert.False(t, ifNoneMatch(``, etag))
+	assert.False(t, ifNoneMatch(`"def"`, etag))
+	assert.False(t, ifNoneMatch(`abc`, etag))
+}
+
+func TestWithCapability(t *testing.T) {
+	models := []schema.OpenAIModel{
+		{ID: "llava", Capabilities: []string{"chat", "completion", "vision"}},
+		{ID: "whisper", Capabilities: []string{"transcription"}},
+		{ID: "loose-file.bin"},
+	}
+
+	assert.Equal(t, []schema.OpenAIModel{models[0]}, withCapability(models, "vision"))
+	assert.Empty(t, withCapability(models, "rerank"))
+}
@@ -0,0 +1,62 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// maintenanceResponse answers a chat or completion request to a model under maintenance with its canned
+// response, without loading the backend, err being the error of CheckModel. The models without a canned
+// response fail with 503, and the other errors are returned as they are
+func maintenanceResponse(c *fiber.Ctx, err error, cfg *config.BackendConfig, input *schema.OpenAIRequest, id string, created int, chat bool) error {
+	var maintenance *fiberContext.MaintenanceError
+	if !errors.As(err, &maintenance) || cfg.Maintenance.Response == "" {
+		return err
+	}
+
+	resp := cannedResponse(cfg, input, id, created, chat)
+	if !input.Stream {
+		return c.JSON(resp)
+	}
+
+	// the canned response is streamed as a single chunk
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	return c.SendString(fmt.Sprintf("data: %s\n\ndata: [DONE]\n\n", data))
+}
+
+func cannedResponse(cfg *config.BackendConfig, input *schema.OpenAIRequest, id string, created int, chat bool) schema.OpenAIResponse {
+	text := cfg.Maintenance.Response
+	resp := schema.OpenAIResponse{
+		ID:      id,
+		Created: created,
+		Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
+		Object:  "text_completion",
+		Choices: []schema.Choice{{Index: 0, FinishReason: "stop", Text: text}},
+		Warning: cfg.Maintenance.Error(cfg.Name),
+	}
+	if !chat {
+		return resp
+	}
+
+	message := &schema.Message{Role: "assistant", Content: &text}
+	resp.Choices[0].Text = ""
+	if input.Stream {
+		resp.Object = "chat.completion.chunk"
+		resp.Choices[0].Delta = message
+	} else {
+		resp.Object = "chat.completion"
+		resp.Choices[0].Message = message
+	}
+	return resp
+}
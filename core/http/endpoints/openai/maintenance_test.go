@@ -0,0 +1,80 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCannedResponse(t *testing.T) {
+	cfg := &config.BackendConfig{Name: "busy-model", Maintenance: config.Maintenance{Enabled: true, Response: "Back soon"}}
+	input := &schema.OpenAIRequest{}
+	input.Model = "busy-model"
+
+	resp := cannedResponse(cfg, input, "id", 1, false)
+	assert.Equal(t, "text_completion", resp.Object)
+	assert.Equal(t, "Back soon", resp.Choices[0].Text)
+	assert.Equal(t, "the model busy-model is under maintenance", resp.Warning)
+
+	resp = cannedResponse(cfg, input, "id", 1, true)
+	assert.Equal(t, "chat.completion", resp.Object)
+	assert.Empty(t, resp.Choices[0].Text)
+	assert.Equal(t, "Back soon", *resp.Choices[0].Message.Content.(*string))
+
+	input.Stream = true
+	resp = cannedResponse(cfg, input, "id", 1, true)
+	assert.Equal(t, "chat.completion.chunk", resp.Object)
+	assert.Nil(t, resp.Choices[0].Message)
+	assert.Equal(t, "Back soon", *resp.Choices[0].Delta.Content.(*string))
+}
+
+func TestMaintenanceResponse(t *testing.T) {
+	cfg := &config.BackendConfig{Name: "busy-model", Maintenance: config.Maintenance{Enabled: true, Response: "Back soon"}}
+	cfg.Deprecation.Deprecated = true
+	request := func(entry *config.APIKey) *http.Response {
+		app := fiber.New()
+		app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+			if entry != nil {
+				c.Locals(fiberContext.APIKeyEntryKey, entry)
+			}
+			input := &schema.OpenAIRequest{}
+			input.Model = cfg.Name
+			if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+				return maintenanceResponse(c, err, cfg, input, "id", 1, true)
+			}
+			return c.SendStatus(fiber.StatusNoContent)
+		})
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("answers with the canned response", func(t *testing.T) {
+		resp := request(nil)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+		out := schema.OpenAIResponse{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		assert.Equal(t, "chat.completion", out.Object)
+	})
+
+	t.Run("checks the access of the API key first", func(t *testing.T) {
+		resp := request(&config.APIKey{Key: "k", Models: []string{"other"}})
+		assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Deprecation"))
+	})
+
+	t.Run("fails with 503 without a canned response", func(t *testing.T) {
+		cfg.Maintenance.Response = ""
+		resp := request(nil)
+		assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+	})
+}
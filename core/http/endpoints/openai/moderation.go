@@ -0,0 +1,88 @@
+package openai
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// ModerationEndpoint is the OpenAI Moderation API endpoint https://platform.openai.com/docs/api-reference/moderations
+// @Summary Classifies whether the texts are potentially harmful.
+// @Param request body schema.ModerationRequest true "query params"
+// @Success 200 {object} schema.ModerationResponse "Response"
+// @Router /v1/moderations [post]
+func ModerationEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	moderationService := services.NewModerationService(cl, ml, appConfig)
+	return func(c *fiber.Ctx) error {
+		input := new(schema.ModerationRequest)
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+		texts, err := moderationTexts(input.Input)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		cfg, err := moderationService.Route(input.Model)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+			return err
+		}
+
+		log.Debug().Str("model", cfg.Name).Int("texts", len(texts)).Msg("moderation request")
+		results, err := moderationService.Moderate(c.UserContext(), cfg, texts)
+		if err != nil {
+			return err
+		}
+		return c.JSON(schema.ModerationResponse{
+			ID:      "modr-" + uuid.New().String(),
+			Model:   cfg.Name,
+			Results: results,
+		})
+	}
+}
+
+// moderationTexts returns the texts of the input: a text, a list of texts, or a list of text parts judged together
+// as a single text
+func moderationTexts(input interface{}) ([]string, error) {
+	texts := []string{}
+	switch i := input.(type) {
+	case string:
+		texts = append(texts, i)
+	case []interface{}:
+		parts := ""
+		for _, item := range i {
+			switch v := item.(type) {
+			case string:
+				texts = append(texts, v)
+			case map[string]interface{}:
+				if v["type"] != "text" {
+					return nil, fmt.Errorf("only the text inputs can be moderated, got %v", v["type"])
+				}
+				text, _ := v["text"].(string)
+				if parts != "" {
+					parts += "\n"
+				}
+				parts += text
+			default:
+				return nil, fmt.Errorf("input must be a text, a list of texts or a list of text parts")
+			}
+		}
+		if parts != "" {
+			texts = append(texts, parts)
+		}
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("input is required")
+	}
+	return texts, nil
+}
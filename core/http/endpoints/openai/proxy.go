@@ -0,0 +1,210 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// ProxyMiddleware forwards the requests for models configured with `backend: proxy`
+// to their OpenAI-compatible upstream. Requests for any other model are handled locally.
+// The proxied models go through the same checks as the local ones: the models allowed to the API key,
+// the deprecation, the maintenance and the token budget of the conversation.
+func ProxyMiddleware(cl *config.BackendConfigLoader, budgets *services.TokenBudgetService, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	client := &http.Client{}
+
+	return func(c *fiber.Ctx) error {
+		input := proxyRequestInfo(c)
+		modelName := input.Model
+		if modelName == "" {
+			return c.Next()
+		}
+
+		cfg, exists := cl.GetBackendConfig(modelName)
+		if !exists || cfg.Backend != config.ProxyBackend {
+			return c.Next()
+		}
+
+		if cfg.Proxy.URL == "" {
+			return fmt.Errorf("model %q has no proxy url configured", modelName)
+		}
+
+		if input.User != "" {
+			c.Locals(fiberContext.UserKey, input.User)
+			log.Debug().Str("user", input.User).Str("ip", c.IP()).Str("path", c.Path()).Str("model", modelName).Msg("request")
+		}
+
+		if _, err := fiberContext.CheckModel(c, &cfg); err != nil {
+			// the chats and the completions get the canned response of the maintenance
+			if strings.HasSuffix(c.Path(), "/completions") && isJSONRequest(c) {
+				return maintenanceResponse(c, err, &cfg, input, uuid.New().String(), int(time.Now().Unix()), strings.HasSuffix(c.Path(), "/chat/completions"))
+			}
+			return err
+		}
+		apiKey := fiberContext.APIKeyFromContext(c)
+		budget, err := beginTokenBudget(budgets, apiKey, &cfg, input)
+		if err != nil {
+			return err
+		}
+
+		body := c.Body()
+		if isJSONRequest(c) {
+			fields := map[string]interface{}{}
+			if cfg.Proxy.Model != "" {
+				fields["model"] = cfg.Proxy.Model
+			}
+			if budget != nil && budget.Truncated {
+				fields["max_tokens"] = *cfg.Maxtokens
+			}
+			if len(fields) > 0 {
+				if body, err = rewriteRequest(body, fields); err != nil {
+					return fmt.Errorf("failed parsing request body: %w", err)
+				}
+			}
+		}
+
+		// The upstream URL already includes the API version
+		target := strings.TrimSuffix(cfg.Proxy.URL, "/") + strings.TrimPrefix(c.Path(), "/v1")
+
+		log.Debug().Str("model", modelName).Str("upstream", target).Msg("Forwarding request to proxy upstream")
+
+		// the upstream request is cancelled when the client goes away, or with the application
+		ctx, cancel := context.WithCancel(c.UserContext())
+		stop := context.AfterFunc(appConfig.Context, cancel)
+		release := func() {
+			stop()
+			cancel()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, c.Method(), target, bytes.NewReader(body))
+		if err != nil {
+			release()
+			return err
+		}
+		req.Header.Set("Content-Type", string(c.Request().Header.ContentType()))
+		if accept := c.Get(fiber.HeaderAccept); accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		// The API key may reference environment variables, e.g. ${OPENAI_API_KEY}
+		if key := os.ExpandEnv(cfg.Proxy.APIKey); key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			release()
+			return fmt.Errorf("proxy upstream for model %q unreachable: %w", modelName, err)
+		}
+
+		c.Status(resp.StatusCode)
+		c.Set(fiber.HeaderContentType, resp.Header.Get("Content-Type"))
+
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+			defer release()
+			defer resp.Body.Close()
+			dat, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return err
+			}
+			budgets.Consume(apiKey, budget, proxyUsage(dat))
+			return c.Send(dat)
+		}
+
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer release()
+			defer resp.Body.Close()
+			tokens := 0
+			scanner := bufio.NewScanner(resp.Body)
+			scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+			for scanner.Scan() {
+				// the usage is in the last chunk, when the client asked for it with stream_options
+				if data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data: ")); ok {
+					if usage := proxyUsage(data); usage > 0 {
+						tokens = usage
+					}
+				}
+				fmt.Fprintf(w, "%s\n", scanner.Bytes())
+				if err := w.Flush(); err != nil {
+					log.Debug().Msgf("Proxy stream closed by the client: %v", err)
+					break
+				}
+			}
+			if err := scanner.Err(); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Str("model", modelName).Msg("Error reading proxy upstream stream")
+			}
+			budgets.Consume(apiKey, budget, tokens)
+		}))
+
+		return nil
+	}
+}
+
+// proxyRequestInfo returns the fields of the request the checks need, without consuming the body
+func proxyRequestInfo(c *fiber.Ctx) *schema.OpenAIRequest {
+	if isJSONRequest(c) {
+		input := struct {
+			Model          string `json:"model"`
+			User           string `json:"user"`
+			Stream         bool   `json:"stream"`
+			ConversationID string `json:"conversation_id"`
+			TokenBudget    int    `json:"token_budget"`
+		}{}
+		if err := json.Unmarshal(c.Body(), &input); err != nil {
+			return &schema.OpenAIRequest{}
+		}
+		request := &schema.OpenAIRequest{User: input.User, Stream: input.Stream, ConversationID: input.ConversationID, TokenBudget: input.TokenBudget}
+		request.Model = input.Model
+		return request
+	}
+
+	request := &schema.OpenAIRequest{User: c.FormValue("user")}
+	request.Model = c.FormValue("model")
+	return request
+}
+
+func isJSONRequest(c *fiber.Ctx) bool {
+	return strings.HasPrefix(string(c.Request().Header.ContentType()), fiber.MIMEApplicationJSON)
+}
+
+// rewriteRequest sets fields of a JSON request body, like the model to the upstream model name
+func rewriteRequest(body []byte, fields map[string]interface{}) ([]byte, error) {
+	input := map[string]interface{}{}
+	if err := json.Unmarshal(body, &input); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		input[k] = v
+	}
+	return json.Marshal(input)
+}
+
+// proxyUsage returns the total tokens of the usage of an upstream response, 0 when it has none
+func proxyUsage(data []byte) int {
+	resp := struct {
+		Usage *schema.OpenAIUsage `json:"usage"`
+	}{}
+	if err := json.Unmarshal(data, &resp); err != nil || resp.Usage == nil {
+		return 0
+	}
+	return resp.Usage.TotalTokens
+}
@@ -0,0 +1,134 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyMiddleware(t *testing.T) {
+	var received map[string]interface{}
+	var receivedAuth, receivedPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedAuth = r.Header.Get("Authorization")
+		receivedPath = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"chat.completion","usage":{"total_tokens":30}}`))
+	}))
+	defer upstream.Close()
+
+	modelDir := t.TempDir()
+	configFile := filepath.Join(modelDir, "remote.yaml")
+	err := os.WriteFile(configFile, []byte(`name: remote
+backend: proxy
+proxy:
+  url: `+upstream.URL+`/v1
+  api_key: secret
+  model: gpt-4o
+`), 0600)
+	assert.NoError(t, err)
+	downFile := filepath.Join(modelDir, "down.yaml")
+	err = os.WriteFile(downFile, []byte(`name: down
+backend: proxy
+proxy:
+  url: `+upstream.URL+`/v1
+maintenance_mode:
+  enabled: true
+  response: Back soon
+`), 0600)
+	assert.NoError(t, err)
+
+	loader := config.NewBackendConfigLoader(modelDir)
+	assert.NoError(t, loader.LoadBackendConfig(configFile))
+	assert.NoError(t, loader.LoadBackendConfig(downFile))
+
+	appConfig := &config.ApplicationConfig{Context: context.Background()}
+	budgets := services.NewTokenBudgetService(appConfig)
+
+	local := func(c *fiber.Ctx) error {
+		return c.SendString("local")
+	}
+	app := fiber.New()
+	app.Post("/v1/chat/completions", ProxyMiddleware(loader, budgets, appConfig), local)
+
+	post := func(app *fiber.App, body string) (int, string) {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	t.Run("forwards proxy models to the upstream", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"remote","messages":[]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, `{"object":"chat.completion","usage":{"total_tokens":30}}`, string(body))
+		assert.Equal(t, "Bearer secret", receivedAuth)
+		assert.Equal(t, "/v1/chat/completions", receivedPath)
+		assert.Equal(t, "gpt-4o", received["model"])
+	})
+
+	t.Run("handles other models locally", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"local-model"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "local", string(body))
+	})
+
+	t.Run("applies the models allowed to the API key", func(t *testing.T) {
+		restricted := fiber.New()
+		restricted.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+			c.Locals(fiberContext.APIKeyEntryKey, &config.APIKey{Key: "key", Models: []string{"other"}})
+			return c.Next()
+		}, ProxyMiddleware(loader, budgets, appConfig), local)
+
+		received = nil
+		status, _ := post(restricted, `{"model":"remote","messages":[]}`)
+		assert.Equal(t, fiber.StatusForbidden, status)
+		assert.Nil(t, received)
+	})
+
+	t.Run("answers the models under maintenance without forwarding", func(t *testing.T) {
+		received = nil
+		status, body := post(app, `{"model":"down","messages":[]}`)
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Contains(t, body, "Back soon")
+		assert.Nil(t, received)
+	})
+
+	t.Run("consumes the token budget of the conversation", func(t *testing.T) {
+		request := `{"model":"remote","messages":[],"conversation_id":"proxied","token_budget":50}`
+		status, _ := post(app, request)
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, float64(50), received["max_tokens"])
+
+		status, _ = post(app, request)
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Equal(t, float64(20), received["max_tokens"])
+
+		status, _ = post(app, request)
+		assert.Equal(t, fiber.StatusTooManyRequests, status)
+	})
+}
@@ -0,0 +1,734 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/sound"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// realtimeSampleRate is the sample rate of the pcm16 audio sent and received by the clients
+	realtimeSampleRate = 24000
+	// transcriptionSampleRate is the sample rate the transcription models expect
+	transcriptionSampleRate = 16000
+	// realtimeAudioChunk is the duration of the audio sent in each response.audio.delta
+	realtimeAudioChunk = 500 * time.Millisecond
+)
+
+// The statuses of the realtime responses, https://platform.openai.com/docs/api-reference/realtime-server-events/response/done
+const (
+	RealtimeInProgress = "in_progress"
+	RealtimeCompleted  = "completed"
+	RealtimeCancelled  = "cancelled"
+	RealtimeIncomplete = "incomplete"
+	RealtimeFailed     = "failed"
+)
+
+// realtimeUpgrader accepts the subprotocol of the browser clients of OpenAI
+var realtimeUpgrader = websocket.Upgrader{Subprotocols: []string{"realtime"}}
+
+// sentenceEnd matches the ends of sentences, where the answers are cut to be spoken while they're generated
+var sentenceEnd = regexp.MustCompile(`[.!?;:]["')\]]*\s|\n`)
+
+// RealtimeSession is the configuration of a realtime session, https://platform.openai.com/docs/api-reference/realtime-client-events/session/update
+type RealtimeSession struct {
+	ID           string   `json:"id"`
+	Object       string   `json:"object"`
+	Model        string   `json:"model"`
+	Modalities   []string `json:"modalities"`
+	Instructions string   `json:"instructions"`
+	Voice        string   `json:"voice"`
+	// SpeechModel is the model of /v1/audio/speech speaking the answers
+	SpeechModel             string                 `json:"speech_model"`
+	InputAudioFormat        string                 `json:"input_audio_format"`
+	OutputAudioFormat       string                 `json:"output_audio_format"`
+	InputAudioTranscription *RealtimeTranscription `json:"input_audio_transcription"`
+	TurnDetection           *RealtimeTurnDetection `json:"turn_detection"`
+	Temperature             *float64               `json:"temperature,omitempty"`
+	// MaxResponseOutputTokens is a number of tokens or "inf"
+	MaxResponseOutputTokens any `json:"max_response_output_tokens,omitempty"`
+}
+
+// RealtimeTranscription is the model of /v1/audio/transcriptions transcribing the audio of the user, for the chat
+// model to read it
+type RealtimeTranscription struct {
+	Model    string `json:"model"`
+	Language string `json:"language,omitempty"`
+}
+
+// RealtimeTurnDetection is the configuration of the VAD committing the audio buffer when the user stops speaking.
+// Only server_vad is supported
+type RealtimeTurnDetection struct {
+	Type              string  `json:"type"`
+	Threshold         float64 `json:"threshold"`
+	PrefixPaddingMS   int     `json:"prefix_padding_ms"`
+	SilenceDurationMS int     `json:"silence_duration_ms"`
+	CreateResponse    *bool   `json:"create_response,omitempty"`
+}
+
+// RealtimeItem is an item of the conversation, only messages are supported
+type RealtimeItem struct {
+	ID      string            `json:"id"`
+	Object  string            `json:"object"`
+	Type    string            `json:"type"`
+	Status  string            `json:"status"`
+	Role    string            `json:"role"`
+	Content []RealtimeContent `json:"content"`
+}
+
+// RealtimeContent is a part of the content of a message: input_text, input_audio, text or audio. The audio of the
+// input_audio parts is base64-encoded pcm16
+type RealtimeContent struct {
+	Type       string `json:"type"`
+	Text       string `json:"text,omitempty"`
+	Audio      string `json:"audio,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+// RealtimeResponse is a response of the assistant
+type RealtimeResponse struct {
+	ID            string         `json:"id"`
+	Object        string         `json:"object"`
+	Status        string         `json:"status"`
+	StatusDetails *fiber.Map     `json:"status_details"`
+	Output        []RealtimeItem `json:"output"`
+	Usage         *RealtimeUsage `json:"usage"`
+}
+
+// RealtimeUsage is the tokens used by a response
+type RealtimeUsage struct {
+	TotalTokens  int `json:"total_tokens"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// realtimeClientEvent is an event sent by the client, https://platform.openai.com/docs/api-reference/realtime-client-events
+type realtimeClientEvent struct {
+	EventID string          `json:"event_id"`
+	Type    string          `json:"type"`
+	Session json.RawMessage `json:"session"`
+	Audio   string          `json:"audio"`
+	Item    *RealtimeItem   `json:"item"`
+	// Response overrides the configuration of the session for a response
+	Response json.RawMessage `json:"response"`
+}
+
+// RealtimeEndpoint is the OpenAI Realtime API https://platform.openai.com/docs/guides/realtime, answering the voice
+// of the user with the one of the assistant: the audio is transcribed, answered by the chat model of the session and
+// spoken by its speech model, sentence by sentence. The turns are detected by the level of the audio
+// @Summary Converse with a model by voice over a WebSocket.
+// @Param model query string true "the chat model"
+// @Router /v1/realtime [get]
+func RealtimeEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		if !isWebSocketUpgrade(c) {
+			return fiber.NewError(fiber.StatusUpgradeRequired, "the realtime API is served over a WebSocket")
+		}
+		modelName := c.Query("model")
+		if modelName == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "model is required")
+		}
+		if !modelExists(cl, ml, modelName) {
+			return fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("model %s not found", modelName))
+		}
+
+		client := newInternalClient(c, "")
+		return hijackWebSocket(c, &realtimeUpgrader, func(conn *websocket.Conn, _ net.Addr) {
+			r := newRealtimeConn(conn, client, modelName)
+			r.serve()
+		})
+	}
+}
+
+// realtimeConn is a realtime session. The events of the client are read in serve, and the changes of the
+// conversation and the responses run in order in work, so that reading the audio isn't blocked by them
+type realtimeConn struct {
+	conn    *websocket.Conn
+	client  *internalClient
+	writeMu sync.Mutex
+
+	// used by serve
+	session     RealtimeSession
+	vad         *sound.VAD
+	audio       []int16 // the input audio buffer
+	received    int     // the samples received since the VAD started
+	speechID    string  // the item of the speech in progress
+	speechStart int     // the sample where the speech in progress started
+
+	// used by work
+	jobs  chan func()
+	items []RealtimeItem
+
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc // cancels the response in progress
+}
+
+func newRealtimeConn(conn *websocket.Conn, client *internalClient, modelName string) *realtimeConn {
+	r := &realtimeConn{
+		conn:   conn,
+		client: client,
+		session: RealtimeSession{
+			ID:                      "sess_" + uuid.New().String(),
+			Object:                  "realtime.session",
+			Model:                   modelName,
+			Modalities:              []string{"text", "audio"},
+			SpeechModel:             "tts-1",
+			InputAudioFormat:        "pcm16",
+			OutputAudioFormat:       "pcm16",
+			InputAudioTranscription: &RealtimeTranscription{Model: "whisper-1"},
+			TurnDetection:           &RealtimeTurnDetection{Type: "server_vad", Threshold: 0.5, PrefixPaddingMS: 300, SilenceDurationMS: 500},
+		},
+		jobs: make(chan func(), 16),
+	}
+	r.configureVAD()
+	return r
+}
+
+func (r *realtimeConn) serve() {
+	go r.work()
+	defer close(r.jobs)
+	defer r.cancelResponse("client_cancelled")
+
+	r.send("session.created", fiber.Map{"session": r.session})
+	for {
+		_, data, err := r.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		event := realtimeClientEvent{}
+		if err := json.Unmarshal(data, &event); err != nil {
+			r.sendError("invalid_request_error", "failed parsing event: "+err.Error(), "")
+			continue
+		}
+		if err := r.handle(event); err != nil {
+			r.sendError("invalid_request_error", err.Error(), event.EventID)
+		}
+	}
+}
+
+func (r *realtimeConn) work() {
+	for job := range r.jobs {
+		job()
+	}
+}
+
+func (r *realtimeConn) handle(event realtimeClientEvent) error {
+	switch event.Type {
+	case "session.update":
+		session := r.session.clone()
+		if err := json.Unmarshal(event.Session, &session); err != nil {
+			return fmt.Errorf("invalid session: %w", err)
+		}
+		if err := validateRealtimeSession(session); err != nil {
+			return err
+		}
+		session.ID, session.Object = r.session.ID, r.session.Object
+		if session.InputAudioTranscription == nil {
+			// the chat model reads the transcripts of the audio
+			session.InputAudioTranscription = &RealtimeTranscription{Model: "whisper-1"}
+		}
+		changed := !reflect.DeepEqual(session.TurnDetection, r.session.TurnDetection)
+		r.session = session
+		if changed {
+			r.configureVAD()
+		}
+		r.send("session.updated", fiber.Map{"session": r.session})
+	case "input_audio_buffer.append":
+		data, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err != nil {
+			return fmt.Errorf("invalid audio: %w", err)
+		}
+		r.appendAudio(sound.BytesToInt16(data))
+	case "input_audio_buffer.commit":
+		if len(r.audio) == 0 {
+			return fmt.Errorf("the input audio buffer is empty")
+		}
+		r.commit(r.audio, r.speechID)
+		r.audio = nil
+	case "input_audio_buffer.clear":
+		r.audio = nil
+		r.send("input_audio_buffer.cleared", nil)
+	case "conversation.item.create":
+		if event.Item == nil || event.Item.Type != "message" {
+			return fmt.Errorf("only the message items are supported")
+		}
+		if !slices.Contains([]string{"user", "assistant", "system"}, event.Item.Role) {
+			return fmt.Errorf("invalid role %q", event.Item.Role)
+		}
+		item := *event.Item
+		if item.ID == "" {
+			item.ID = "item_" + uuid.New().String()
+		}
+		item.Object, item.Status = "realtime.item", RealtimeCompleted
+		transcription := *r.session.InputAudioTranscription
+		r.jobs <- func() { r.addItem(item, transcription) }
+	case "response.create":
+		session := r.session.clone()
+		if len(event.Response) > 0 {
+			if err := json.Unmarshal(event.Response, &session); err != nil {
+				return fmt.Errorf("invalid response: %w", err)
+			}
+			if err := validateRealtimeSession(session); err != nil {
+				return err
+			}
+		}
+		r.jobs <- func() { r.respond(session) }
+	case "response.cancel":
+		r.cancelResponse("client_cancelled")
+	default:
+		return fmt.Errorf("unsupported event type %q", event.Type)
+	}
+	return nil
+}
+
+// clone returns a copy of the session, which can be updated without changing it
+func (s RealtimeSession) clone() RealtimeSession {
+	s.Modalities = slices.Clone(s.Modalities)
+	if s.InputAudioTranscription != nil {
+		transcription := *s.InputAudioTranscription
+		s.InputAudioTranscription = &transcription
+	}
+	if s.TurnDetection != nil {
+		turnDetection := *s.TurnDetection
+		s.TurnDetection = &turnDetection
+	}
+	return s
+}
+
+func validateRealtimeSession(session RealtimeSession) error {
+	if session.InputAudioFormat != "pcm16" || session.OutputAudioFormat != "pcm16" {
+		return fmt.Errorf("only the pcm16 audio format is supported")
+	}
+	for _, modality := range session.Modalities {
+		if modality != "text" && modality != "audio" {
+			return fmt.Errorf("invalid modality %q", modality)
+		}
+	}
+	if session.TurnDetection != nil && session.TurnDetection.Type != "server_vad" {
+		return fmt.Errorf("unsupported turn detection %q, only server_vad is supported", session.TurnDetection.Type)
+	}
+	return nil
+}
+
+// configureVAD detects the turns with the configuration of the session, or leaves the commits to the client
+func (r *realtimeConn) configureVAD() {
+	if r.session.TurnDetection == nil {
+		r.vad = nil
+		return
+	}
+	r.vad = &sound.VAD{
+		Threshold:       r.session.TurnDetection.Threshold,
+		SilenceDuration: time.Duration(r.session.TurnDetection.SilenceDurationMS) * time.Millisecond,
+		SampleRate:      realtimeSampleRate,
+	}
+	// the times of the VAD start from the audio received next
+	r.received, r.speechID = 0, ""
+}
+
+// appendAudio adds the samples to the input audio buffer. With server_vad, the buffer is committed when the user
+// stops speaking, from the prefix padding before the speech started
+func (r *realtimeConn) appendAudio(samples []int16) {
+	r.audio = append(r.audio, samples...)
+	r.received += len(samples)
+	if r.vad == nil {
+		return
+	}
+
+	turnDetection := *r.session.TurnDetection
+	padding := r.sample(time.Duration(turnDetection.PrefixPaddingMS) * time.Millisecond)
+	for _, event := range r.vad.Process(samples) {
+		at := r.sample(event.At)
+		if event.Speaking {
+			// the user interrupts the assistant
+			r.cancelResponse("turn_detected")
+			r.speechID, r.speechStart = "item_"+uuid.New().String(), at
+			r.send("input_audio_buffer.speech_started", fiber.Map{"audio_start_ms": event.At.Milliseconds(), "item_id": r.speechID})
+			continue
+		}
+
+		r.send("input_audio_buffer.speech_stopped", fiber.Map{"audio_end_ms": event.At.Milliseconds(), "item_id": r.speechID})
+		// the buffer holds the last samples received
+		bufferStart := r.received - len(r.audio)
+		start := min(max(r.speechStart-padding-bufferStart, 0), len(r.audio))
+		end := min(max(at-bufferStart, start), len(r.audio))
+		r.commit(r.audio[start:end], r.speechID)
+		r.audio = slices.Clone(r.audio[end:])
+		if turnDetection.CreateResponse == nil || *turnDetection.CreateResponse {
+			session := r.session.clone()
+			r.jobs <- func() { r.respond(session) }
+		}
+	}
+
+	// out of the speech, only the prefix padding of the next one is kept
+	if r.speechID == "" && len(r.audio) > padding {
+		r.audio = append(r.audio[:0], r.audio[len(r.audio)-padding:]...)
+	}
+}
+
+func (r *realtimeConn) sample(d time.Duration) int {
+	return int(int64(d) * realtimeSampleRate / int64(time.Second))
+}
+
+// commit adds the audio to the conversation as a message of the user, transcribed for the chat model
+func (r *realtimeConn) commit(audio []int16, itemID string) {
+	if itemID == "" {
+		itemID = "item_" + uuid.New().String()
+	}
+	r.speechID = ""
+	item := RealtimeItem{
+		ID:      itemID,
+		Object:  "realtime.item",
+		Type:    "message",
+		Status:  RealtimeCompleted,
+		Role:    "user",
+		Content: []RealtimeContent{{Type: "input_audio", Audio: base64.StdEncoding.EncodeToString(sound.Int16ToBytes(audio))}},
+	}
+	transcription := *r.session.InputAudioTranscription
+	r.jobs <- func() {
+		r.send("input_audio_buffer.committed", fiber.Map{"previous_item_id": r.lastItemID(), "item_id": item.ID})
+		r.addItem(item, transcription)
+	}
+}
+
+func (r *realtimeConn) lastItemID() any {
+	if len(r.items) == 0 {
+		return nil
+	}
+	return r.items[len(r.items)-1].ID
+}
+
+// addItem adds the item to the conversation, its audio being transcribed
+func (r *realtimeConn) addItem(item RealtimeItem, transcription RealtimeTranscription) {
+	audio := map[int][]byte{}
+	for i, content := range item.Content {
+		if content.Type == "input_audio" && content.Audio != "" {
+			data, err := base64.StdEncoding.DecodeString(content.Audio)
+			if err != nil {
+				r.sendError("invalid_request_error", "invalid audio: "+err.Error(), "")
+				return
+			}
+			audio[i] = data
+			// the audio isn't sent back
+			item.Content[i].Audio = ""
+		}
+	}
+	r.send("conversation.item.created", fiber.Map{"previous_item_id": r.lastItemID(), "item": item})
+	r.items = append(r.items, item)
+	index := len(r.items) - 1
+
+	for i, data := range audio {
+		transcript, err := r.transcribe(sound.BytesToInt16(data), transcription)
+		if err != nil {
+			r.send("conversation.item.input_audio_transcription.failed", fiber.Map{
+				"item_id":       item.ID,
+				"content_index": i,
+				"error":         fiber.Map{"type": "transcription_error", "message": err.Error()},
+			})
+			continue
+		}
+		r.items[index].Content[i].Transcript = transcript
+		r.send("conversation.item.input_audio_transcription.completed", fiber.Map{"item_id": item.ID, "content_index": i, "transcript": transcript})
+	}
+}
+
+// transcribe returns the text of the audio, transcribed through /v1/audio/transcriptions
+func (r *realtimeConn) transcribe(audio []int16, transcription RealtimeTranscription) (string, error) {
+	wav := sound.EncodeWAV(sound.Resample(audio, realtimeSampleRate, transcriptionSampleRate), transcriptionSampleRate)
+	fields := map[string]string{"model": transcription.Model}
+	if transcription.Language != "" {
+		fields["language"] = transcription.Language
+	}
+	response, err := r.client.postFile("/v1/audio/transcriptions", fields, "audio.wav", wav)
+	if err != nil {
+		return "", err
+	}
+	if response.Response.StatusCode() != fiber.StatusOK {
+		return "", fmt.Errorf("the transcription failed with status %d: %s", response.Response.StatusCode(), response.Response.Body())
+	}
+	result := schema.TranscriptionResult{}
+	if err := json.Unmarshal(response.Response.Body(), &result); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// cancelResponse cancels the response in progress, for the reason given
+func (r *realtimeConn) cancelResponse(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel(errors.New(reason))
+	}
+}
+
+// respond answers the conversation with the configuration of the session: the text of the chat model is streamed,
+// and spoken a sentence at a time by the speech model when the session has the audio modality
+func (r *realtimeConn) respond(session RealtimeSession) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.cancel = nil
+		r.mu.Unlock()
+		cancel(nil)
+	}()
+
+	response := RealtimeResponse{ID: "resp_" + uuid.New().String(), Object: "realtime.response", Status: RealtimeInProgress, Output: []RealtimeItem{}}
+	r.send("response.created", fiber.Map{"response": response})
+
+	withAudio := slices.Contains(session.Modalities, "audio")
+	part := RealtimeContent{Type: "text"}
+	if withAudio {
+		part.Type = "audio"
+	}
+	item := RealtimeItem{
+		ID:      "item_" + uuid.New().String(),
+		Object:  "realtime.item",
+		Type:    "message",
+		Status:  RealtimeInProgress,
+		Role:    "assistant",
+		Content: []RealtimeContent{},
+	}
+	ids := fiber.Map{"response_id": response.ID, "item_id": item.ID, "output_index": 0, "content_index": 0}
+	r.send("response.output_item.added", fiber.Map{"response_id": response.ID, "output_index": 0, "item": item})
+	r.send("conversation.item.created", fiber.Map{"previous_item_id": r.lastItemID(), "item": item})
+	r.send("response.content_part.added", fiber.Map{"response_id": response.ID, "item_id": item.ID, "output_index": 0, "content_index": 0, "part": part})
+
+	var speaker *realtimeSpeaker
+	if withAudio {
+		speaker = r.speak(ctx, session, ids)
+	}
+	text, usage, err := r.chat(ctx, session, func(delta string) {
+		if withAudio {
+			r.send("response.audio_transcript.delta", with(ids, "delta", delta))
+			speaker.write(delta)
+		} else {
+			r.send("response.text.delta", with(ids, "delta", delta))
+		}
+	})
+	if withAudio {
+		if speakErr := speaker.close(); err == nil {
+			err = speakErr
+		}
+		r.send("response.audio.done", ids)
+		r.send("response.audio_transcript.done", with(ids, "transcript", text))
+		part.Transcript = text
+	} else {
+		r.send("response.text.done", with(ids, "text", text))
+		part.Text = text
+	}
+
+	response.Status, item.Status = RealtimeCompleted, RealtimeCompleted
+	switch {
+	case ctx.Err() != nil:
+		response.Status, item.Status = RealtimeCancelled, RealtimeIncomplete
+		response.StatusDetails = &fiber.Map{"type": RealtimeCancelled, "reason": context.Cause(ctx).Error()}
+	case err != nil:
+		log.Debug().Err(err).Msg("realtime response failed")
+		r.sendError("server_error", err.Error(), "")
+		response.Status, item.Status = RealtimeFailed, RealtimeIncomplete
+		response.StatusDetails = &fiber.Map{"type": RealtimeFailed, "error": fiber.Map{"type": "server_error", "message": err.Error()}}
+	}
+	item.Content = []RealtimeContent{part}
+	r.items = append(r.items, item)
+	response.Output, response.Usage = []RealtimeItem{item}, usage
+
+	r.send("response.content_part.done", fiber.Map{"response_id": response.ID, "item_id": item.ID, "output_index": 0, "content_index": 0, "part": part})
+	r.send("response.output_item.done", fiber.Map{"response_id": response.ID, "output_index": 0, "item": item})
+	r.send("response.done", fiber.Map{"response": response})
+}
+
+// chat streams the answer of the chat model to the conversation through /v1/chat/completions, and returns its text
+func (r *realtimeConn) chat(ctx context.Context, session RealtimeSession, onDelta func(delta string)) (string, *RealtimeUsage, error) {
+	messages := []fiber.Map{}
+	if session.Instructions != "" {
+		messages = append(messages, fiber.Map{"role": "system", "content": session.Instructions})
+	}
+	for _, item := range r.items {
+		texts := []string{}
+		for _, content := range item.Content {
+			if text := content.Text + content.Transcript; text != "" {
+				texts = append(texts, text)
+			}
+		}
+		if len(texts) > 0 {
+			messages = append(messages, fiber.Map{"role": item.Role, "content": strings.Join(texts, "\n")})
+		}
+	}
+	chat := fiber.Map{"model": session.Model, "messages": messages, "stream": true}
+	if session.Temperature != nil {
+		chat["temperature"] = *session.Temperature
+	}
+	if maxTokens, ok := session.MaxResponseOutputTokens.(float64); ok {
+		chat["max_tokens"] = int(maxTokens)
+	}
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return "", nil, err
+	}
+
+	response := r.client.post("/v1/chat/completions", body)
+	if !response.Response.IsBodyStream() {
+		return "", nil, fmt.Errorf("the chat completion failed with status %d: %s", response.Response.StatusCode(), response.Response.Body())
+	}
+	// the chat endpoint stops generating as soon as it fails writing to the stream
+	stop := context.AfterFunc(ctx, func() { response.Response.CloseBodyStream() })
+	defer func() {
+		if stop() {
+			response.Response.CloseBodyStream()
+		}
+	}()
+
+	text := strings.Builder{}
+	var usage *RealtimeUsage
+	reader := bufio.NewReader(response.Response.BodyStream())
+	for ctx.Err() == nil {
+		line, readErr := reader.ReadBytes('\n')
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+		if ok && !bytes.Equal(data, []byte("[DONE]")) {
+			chunk := schema.OpenAIResponse{}
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				return text.String(), usage, fmt.Errorf("invalid chunk of the chat completion: %w", err)
+			}
+			if chunk.Usage.TotalTokens > 0 {
+				usage = &RealtimeUsage{TotalTokens: chunk.Usage.TotalTokens, InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+				if content, _ := chunk.Choices[0].Delta.Content.(string); content != "" {
+					text.WriteString(content)
+					onDelta(content)
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	return text.String(), usage, nil
+}
+
+// realtimeSpeaker speaks the text written to it a sentence at a time, while the rest of the text is generated
+type realtimeSpeaker struct {
+	pending   string
+	sentences chan string
+	done      chan error
+}
+
+// speak returns the speaker sending the audio of the sentences to the client as response.audio.delta events
+func (r *realtimeConn) speak(ctx context.Context, session RealtimeSession, ids fiber.Map) *realtimeSpeaker {
+	s := &realtimeSpeaker{sentences: make(chan string, 64), done: make(chan error, 1)}
+	go func() {
+		var err error
+		for sentence := range s.sentences {
+			if err != nil || ctx.Err() != nil {
+				continue
+			}
+			var audio []int16
+			if audio, err = r.synthesize(session, sentence); err != nil {
+				continue
+			}
+			chunk := r.sample(realtimeAudioChunk)
+			for len(audio) > 0 && ctx.Err() == nil {
+				n := min(chunk, len(audio))
+				r.send("response.audio.delta", with(ids, "delta", base64.StdEncoding.EncodeToString(sound.Int16ToBytes(audio[:n]))))
+				audio = audio[n:]
+			}
+		}
+		s.done <- err
+	}()
+	return s
+}
+
+func (s *realtimeSpeaker) write(text string) {
+	s.pending += text
+	if ends := sentenceEnd.FindAllStringIndex(s.pending, -1); len(ends) > 0 {
+		end := ends[len(ends)-1][1]
+		s.say(s.pending[:end])
+		s.pending = s.pending[end:]
+	}
+}
+
+func (s *realtimeSpeaker) say(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		s.sentences <- text
+	}
+}
+
+// close speaks the rest of the text, and returns once all of it is spoken
+func (s *realtimeSpeaker) close() error {
+	s.say(s.pending)
+	close(s.sentences)
+	return <-s.done
+}
+
+// synthesize returns the audio of the text at the sample rate of the session, spoken through /v1/audio/speech
+func (r *realtimeConn) synthesize(session RealtimeSession, text string) ([]int16, error) {
+	body, err := json.Marshal(schema.TTSRequest{Model: session.SpeechModel, Input: text, Voice: session.Voice})
+	if err != nil {
+		return nil, err
+	}
+	response := r.client.post("/v1/audio/speech", body)
+	if response.Response.StatusCode() != fiber.StatusOK {
+		return nil, fmt.Errorf("the speech failed with status %d: %s", response.Response.StatusCode(), response.Response.Body())
+	}
+	audio, sampleRate, err := sound.DecodeWAV(response.Response.Body())
+	if err != nil {
+		return nil, fmt.Errorf("failed reading the speech: %w", err)
+	}
+	return sound.Resample(audio, sampleRate, realtimeSampleRate), nil
+}
+
+// send sends the event of the type to the client, with the fields given
+func (r *realtimeConn) send(eventType string, fields fiber.Map) {
+	event := fiber.Map{"event_id": "event_" + uuid.New().String(), "type": eventType}
+	for k, v := range fields {
+		event[k] = v
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Error().Err(err).Str("type", eventType).Msg("failed marshalling realtime event")
+		return
+	}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+	r.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (r *realtimeConn) sendError(errorType, message, eventID string) {
+	apiError := fiber.Map{"type": errorType, "message": message}
+	if eventID != "" {
+		apiError["event_id"] = eventID
+	}
+	r.send("error", fiber.Map{"error": apiError})
+}
+
+// with returns the fields with another one
+func with(fields fiber.Map, key string, value any) fiber.Map {
+	result := fiber.Map{key: value}
+	for k, v := range fields {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,233 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/websocket"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/sound"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startUpRealtimeApp returns the address of the app with a fake transcription answering "Hello", a fake chat
+// completion answering "Hi there. How are you?" and a fake speech of 16kHz audio, and the chat requests it received
+func startUpRealtimeApp(t *testing.T) (string, chan schema.OpenAIRequest) {
+	modelsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelsDir, "phi-2"), []byte{}, 0600))
+	cl := &config.BackendConfigLoader{}
+	ml := model.NewModelLoader(modelsDir)
+
+	chats := make(chan schema.OpenAIRequest, 4)
+	app := fiber.New()
+	app.Get("/v1/realtime", RealtimeEndpoint(cl, ml))
+	app.Post("/v1/audio/transcriptions", func(c *fiber.Ctx) error {
+		assert.Equal(t, "whisper-1", c.FormValue("model"))
+		file, err := c.FormFile("file")
+		if err != nil {
+			return err
+		}
+		f, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		audio, sampleRate, err := sound.DecodeWAV(data)
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, transcriptionSampleRate, sampleRate)
+		assert.NotEmpty(t, audio)
+		return c.JSON(schema.TranscriptionResult{Text: " Hello"})
+	})
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		request := schema.OpenAIRequest{}
+		if err := c.BodyParser(&request); err != nil {
+			return err
+		}
+		chats <- request
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			for _, content := range []string{"Hi ", "there. ", "How are", " you?"} {
+				chunk, _ := json.Marshal(schema.OpenAIResponse{Choices: []schema.Choice{{Delta: &schema.Message{Content: content}}}})
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+				w.Flush()
+			}
+			chunk, _ := json.Marshal(schema.OpenAIResponse{Usage: schema.OpenAIUsage{PromptTokens: 12, CompletionTokens: 6, TotalTokens: 18}})
+			fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", chunk)
+			w.Flush()
+		})
+		return nil
+	})
+	app.Post("/v1/audio/speech", func(c *fiber.Ctx) error {
+		request := schema.TTSRequest{}
+		if err := c.BodyParser(&request); err != nil {
+			return err
+		}
+		assert.Equal(t, "tts-1", request.Model)
+		// a second of audio for each sentence
+		return c.Send(sound.EncodeWAV(make([]int16, 16000), 16000))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return ln.Addr().String(), chats
+}
+
+type realtimeTestEvent struct {
+	Type       string           `json:"type"`
+	Delta      string           `json:"delta"`
+	Transcript string           `json:"transcript"`
+	Text       string           `json:"text"`
+	Item       RealtimeItem     `json:"item"`
+	Response   RealtimeResponse `json:"response"`
+}
+
+func dialRealtime(t *testing.T, addr string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/v1/realtime?model=phi-2", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	assert.Equal(t, "session.created", readRealtime(t, conn).Type)
+	return conn
+}
+
+func sendRealtime(t *testing.T, conn *websocket.Conn, event string) {
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(event)))
+}
+
+func readRealtime(t *testing.T, conn *websocket.Conn) realtimeTestEvent {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	event := realtimeTestEvent{}
+	require.NoError(t, conn.ReadJSON(&event))
+	return event
+}
+
+// readRealtimeUntil returns the events up to the one of the type, without the deltas
+func readRealtimeUntil(t *testing.T, conn *websocket.Conn, eventType string) ([]string, []realtimeTestEvent) {
+	types, deltas := []string{}, []realtimeTestEvent{}
+	for {
+		event := readRealtime(t, conn)
+		if event.Delta != "" {
+			deltas = append(deltas, event)
+		} else {
+			types = append(types, event.Type)
+		}
+		if event.Type == eventType {
+			return types, append(deltas, event)
+		}
+	}
+}
+
+func TestRealtimeVoice(t *testing.T) {
+	addr, chats := startUpRealtimeApp(t)
+	conn := dialRealtime(t, addr)
+
+	sendRealtime(t, conn, `{"type": "session.update", "session": {"instructions": "Be brief.", "turn_detection": {"silence_duration_ms": 200}}}`)
+	assert.Equal(t, "session.updated", readRealtime(t, conn).Type)
+
+	// silence, a second of speech, and silence in chunks of 100ms
+	audio := make([]int16, 24000*2)
+	for i := 12000; i < 36000; i++ {
+		audio[i] = int16(0.3 * math.MaxInt16 * math.Sin(2*math.Pi*440*float64(i)/24000))
+	}
+	for len(audio) > 0 {
+		chunk, _ := json.Marshal(fiber.Map{"type": "input_audio_buffer.append", "audio": base64.StdEncoding.EncodeToString(sound.Int16ToBytes(audio[:2400]))})
+		sendRealtime(t, conn, string(chunk))
+		audio = audio[2400:]
+	}
+
+	types, events := readRealtimeUntil(t, conn, "response.done")
+	assert.Equal(t, []string{
+		"input_audio_buffer.speech_started",
+		"input_audio_buffer.speech_stopped",
+		"input_audio_buffer.committed",
+		"conversation.item.created",
+		"conversation.item.input_audio_transcription.completed",
+		"response.created",
+		"response.output_item.added",
+		"conversation.item.created",
+		"response.content_part.added",
+		"response.audio.done",
+		"response.audio_transcript.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.done",
+	}, types)
+
+	chat := <-chats
+	assert.Equal(t, "phi-2", chat.Model)
+	assert.True(t, chat.Stream)
+	require.Len(t, chat.Messages, 2)
+	assert.Equal(t, "Be brief.", chat.Messages[0].Content)
+	assert.Equal(t, schema.Message{Role: "user", Content: "Hello"}, chat.Messages[1])
+
+	// the transcript is streamed, and each sentence is spoken once it's complete
+	transcript, audioSamples := "", 0
+	for _, event := range events {
+		switch event.Type {
+		case "response.audio_transcript.delta":
+			transcript += event.Delta
+		case "response.audio.delta":
+			data, err := base64.StdEncoding.DecodeString(event.Delta)
+			require.NoError(t, err)
+			audioSamples += len(data) / 2
+		}
+	}
+	assert.Equal(t, "Hi there. How are you?", transcript)
+	assert.Equal(t, 2*realtimeSampleRate, audioSamples)
+
+	response := events[len(events)-1].Response
+	assert.Equal(t, RealtimeCompleted, response.Status)
+	require.Len(t, response.Output, 1)
+	assert.Equal(t, []RealtimeContent{{Type: "audio", Transcript: "Hi there. How are you?"}}, response.Output[0].Content)
+	assert.Equal(t, &RealtimeUsage{TotalTokens: 18, InputTokens: 12, OutputTokens: 6}, response.Usage)
+}
+
+func TestRealtimeText(t *testing.T) {
+	addr, chats := startUpRealtimeApp(t)
+	conn := dialRealtime(t, addr)
+
+	sendRealtime(t, conn, `{"type": "session.update", "session": {"modalities": ["text"], "turn_detection": null}}`)
+	assert.Equal(t, "session.updated", readRealtime(t, conn).Type)
+	sendRealtime(t, conn, `{"type": "session.update", "session": {"input_audio_format": "g711_ulaw"}}`)
+	assert.Equal(t, "error", readRealtime(t, conn).Type)
+
+	sendRealtime(t, conn, `{"type": "conversation.item.create", "item": {"type": "message", "role": "user", "content": [{"type": "input_text", "text": "Hello"}]}}`)
+	assert.Equal(t, "conversation.item.created", readRealtime(t, conn).Type)
+	sendRealtime(t, conn, `{"type": "response.create"}`)
+
+	types, events := readRealtimeUntil(t, conn, "response.done")
+	assert.NotContains(t, types, "response.audio.done")
+	assert.Contains(t, types, "response.text.done")
+	text := ""
+	for _, event := range events {
+		assert.NotEqual(t, "response.audio.delta", event.Type)
+		if event.Type == "response.text.delta" {
+			text += event.Delta
+		}
+	}
+	assert.Equal(t, "Hi there. How are you?", text)
+	assert.Equal(t, []RealtimeContent{{Type: "text", Text: "Hi there. How are you?"}}, events[len(events)-1].Response.Output[0].Content)
+
+	chat := <-chats
+	assert.Equal(t, []schema.Message{{Role: "user", Content: "Hello"}}, chat.Messages)
+}
@@ -2,8 +2,11 @@ package openai
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/core/config"
@@ -11,10 +14,21 @@ import (
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/functions"
 	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/scanner"
+	"github.com/mudler/LocalAI/pkg/templates"
 	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/mudler/LocalAI/core/http/endpoints/openai")
+
+// maxTopLogprobs is the number of the most likely tokens which can be returned with each token, as OpenAI
+const maxTopLogprobs = 20
+
 func readRequest(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, o *config.ApplicationConfig, firstModel bool) (string, *schema.OpenAIRequest, error) {
 	input := new(schema.OpenAIRequest)
 
@@ -23,9 +37,69 @@ func readRequest(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLo
 		return "", nil, fmt.Errorf("failed parsing request body: %w", err)
 	}
 
+	// Expand the preset: the fields set in the request take precedence over it
+	if presetName := input.Preset; presetName != "" {
+		preset, exists := o.GenerationPresets[presetName]
+		if !exists {
+			return "", nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("unknown preset %q", presetName))
+		}
+
+		input = new(schema.OpenAIRequest)
+		if err := json.Unmarshal(preset, input); err != nil {
+			return "", nil, fmt.Errorf("failed parsing preset %q: %w", presetName, err)
+		}
+		if err := c.BodyParser(input); err != nil {
+			return "", nil, fmt.Errorf("failed parsing request body: %w", err)
+		}
+	}
+
+	// The texts of the request can reference the prompt variables
+	if err := expandVariables(input); err != nil {
+		return "", nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	// Forcing the backend of a model is restricted to the admin API keys
+	if err := fiberContext.CheckBackendOverride(c, input.Backend); err != nil {
+		return "", nil, err
+	}
+
+	// Grammars can be referenced by name from the grammar library
+	if functions.IsGrammarName(input.Grammar) {
+		grammar, err := functions.ResolveGrammar(o.ConfigsDir, input.Grammar)
+		if err != nil {
+			return "", nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		input.Grammar = grammar
+	}
+
+	// guided_choice is a shorthand for the grammar of its choices
+	if input.GuidedChoice != nil {
+		if input.Grammar != "" {
+			return "", nil, fiber.NewError(fiber.StatusBadRequest, "guided_choice and grammar can't be used together")
+		}
+		grammar, err := functions.ChoiceGrammar(input.GuidedChoice)
+		if err != nil {
+			return "", nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		input.Grammar = grammar
+	}
+
+	// top_logprobs returns the most likely tokens with the log probabilities of the tokens
+	if input.TopLogprobs != nil {
+		if !input.Logprobs.Enabled {
+			return "", nil, fiber.NewError(fiber.StatusBadRequest, "logprobs must be true when top_logprobs is used")
+		}
+		input.Logprobs.Top = *input.TopLogprobs
+	}
+	if input.Logprobs.Top < 0 || input.Logprobs.Top > maxTopLogprobs {
+		return "", nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("the number of the most likely tokens of logprobs and top_logprobs must be between 0 and %d", maxTopLogprobs))
+	}
+
 	received, _ := json.Marshal(input)
 
-	ctx, cancel := context.WithCancel(o.Context)
+	// the request is cancelled with the application, and its spans continue the trace of the HTTP request
+	ctx := trace.ContextWithSpan(utils.ContextWithUser(o.Context, input.User), trace.SpanFromContext(c.UserContext()))
+	ctx, cancel := context.WithCancel(ctx)
 	input.Context = ctx
 	input.Cancel = cancel
 
@@ -33,9 +107,103 @@ func readRequest(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLo
 
 	modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.Model, firstModel)
 
+	if input.User != "" {
+		c.Locals(fiberContext.UserKey, input.User)
+		log.Debug().Str("user", input.User).Str("ip", c.IP()).Str("path", c.Path()).Str("model", modelFile).Msg("request")
+	}
+
 	return modelFile, input, err
 }
 
+// requestTimings returns the timings metadata of a request started at started
+func requestTimings(config *config.BackendConfig, input *schema.OpenAIRequest, started time.Time) *schema.Timings {
+	return &schema.Timings{
+		Backend:         config.Backend,
+		BackendOverride: input.Backend != "",
+		TotalMS:         float64(time.Since(started).Microseconds()) / 1000,
+	}
+}
+
+// responseWarning returns the warning of a response: the warning of the model, followed by the warnings of the
+// inference
+func responseWarning(warning string, input *schema.OpenAIRequest) string {
+	warnings := []string{}
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	return strings.Join(append(warnings, input.Warnings...), "; ")
+}
+
+// expandVariables replaces the references to the prompt variables in the texts of the messages, in the prompt and in
+// the instruction of the request
+func expandVariables(input *schema.OpenAIRequest) error {
+	var err error
+	for i := range input.Messages {
+		if input.Messages[i].Content, err = expandContent(input.Messages[i].Content); err != nil {
+			return err
+		}
+	}
+	if input.Prompt, err = expandContent(input.Prompt); err != nil {
+		return err
+	}
+	input.Instruction, err = templates.ExpandVariables(input.Instruction)
+	return err
+}
+
+// expandContent replaces the references to the prompt variables in a text, in a list of texts, or in the text parts
+// of the content of a message
+func expandContent(content interface{}) (interface{}, error) {
+	switch content := content.(type) {
+	case string:
+		return templates.ExpandVariables(content)
+	case []interface{}:
+		for i, item := range content {
+			switch item := item.(type) {
+			case string:
+				text, err := templates.ExpandVariables(item)
+				if err != nil {
+					return nil, err
+				}
+				content[i] = text
+			case map[string]interface{}:
+				if text, ok := item["text"].(string); ok {
+					expanded, err := templates.ExpandVariables(text)
+					if err != nil {
+						return nil, err
+					}
+					item["text"] = expanded
+				}
+			}
+		}
+	}
+	return content, nil
+}
+
+// preprocessImages scans the images of the request messages, and normalizes them (orientation, size and format)
+// before they are passed to the backend
+func preprocessImages(c *fiber.Ctx, input *schema.OpenAIRequest, o *config.ApplicationConfig) error {
+	for i, m := range input.Messages {
+		for j, img := range m.StringImages {
+			if o.UploadScanner != nil {
+				data, err := base64.StdEncoding.DecodeString(img)
+				if err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid image in message %d: %s", i, err.Error()))
+				}
+				if err := fiberContext.ScanUpload(c, o, scanner.KindImage, fmt.Sprintf("message-%d-image-%d", i, j), data); err != nil {
+					return err
+				}
+			}
+
+			processed, err := utils.PreprocessBase64Image(img, o.ImageLimits())
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid image in message %d: %s", i, err.Error()))
+			}
+			input.Messages[i].StringImages[j] = processed
+		}
+	}
+	return nil
+}
+
 func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIRequest) {
 	if input.Echo {
 		config.Echo = input.Echo
@@ -83,6 +251,18 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 		config.Grammar = input.Grammar
 	}
 
+	if input.ForceLanguage != "" {
+		config.ForceLanguage = input.ForceLanguage
+	}
+
+	if len(input.Validators) > 0 {
+		config.Validators = input.Validators
+	}
+
+	if input.ValidationRetries != nil {
+		config.ValidationRetries = input.ValidationRetries
+	}
+
 	if input.Temperature != nil {
 		config.Temperature = input.Temperature
 	}
@@ -134,6 +314,10 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 		}
 	}
 
+	if input.ParallelToolCalls != nil {
+		config.FunctionsConfig.GrammarConfig.ParallelCalls = *input.ParallelToolCalls
+	}
+
 	// Decode each request's message content
 	index := 0
 	for i, m := range input.Messages {
@@ -187,6 +371,14 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 		config.IgnoreEOS = input.IgnoreEOS
 	}
 
+	if input.Raw {
+		config.Raw = input.Raw
+	}
+
+	if input.Logprobs.Enabled {
+		config.Logprobs = input.Logprobs
+	}
+
 	if input.Seed != nil {
 		config.Seed = input.Seed
 	}
@@ -246,6 +438,9 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 }
 
 func mergeRequestWithConfig(modelFile string, input *schema.OpenAIRequest, cm *config.BackendConfigLoader, loader *model.ModelLoader, debug bool, threads, ctx int, f16 bool) (*config.BackendConfig, *schema.OpenAIRequest, error) {
+	_, span := tracer.Start(input.Context, "config.resolve", trace.WithAttributes(attribute.String("model", modelFile)))
+	defer span.End()
+
 	cfg, err := cm.LoadBackendConfigFileByName(modelFile, loader.ModelPath,
 		config.LoadOptionDebug(debug),
 		config.LoadOptionThreads(threads),
@@ -257,7 +452,15 @@ func mergeRequestWithConfig(modelFile string, input *schema.OpenAIRequest, cm *c
 	// Set the parameters for the language model prediction
 	updateRequestConfig(cfg, input)
 
+	// The system prompt of the model can reference the prompt variables
+	if cfg.SystemPrompt, err = templates.ExpandVariables(cfg.SystemPrompt); err != nil {
+		return nil, nil, fmt.Errorf("failed expanding the system prompt of the model: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("backend", cfg.Backend))
+
 	if !cfg.Validate() {
+		span.SetStatus(codes.Error, "invalid config")
 		return nil, nil, fmt.Errorf("failed to validate config")
 	}
 
@@ -0,0 +1,93 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandVariables(t *testing.T) {
+	templates.SetVariables(map[string]string{"company": "ACME"})
+	defer templates.SetVariables(nil)
+
+	input := &schema.OpenAIRequest{
+		Messages: []schema.Message{
+			{Role: "system", Content: `You work for {{variable "company"}}.`},
+			{Role: "user", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": `Who is {{variable "company"}}?`},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "http://example.com/a.png"}},
+			}},
+		},
+		Prompt:      []interface{}{`{{variable "company"}} is`, "plain"},
+		Instruction: `Mention {{variable "company"}}`,
+	}
+	assert.NoError(t, expandVariables(input))
+
+	assert.Equal(t, "You work for ACME.", input.Messages[0].Content)
+	assert.Equal(t, "Who is ACME?", input.Messages[1].Content.([]interface{})[0].(map[string]interface{})["text"])
+	assert.Equal(t, []interface{}{"ACME is", "plain"}, input.Prompt)
+	assert.Equal(t, "Mention ACME", input.Instruction)
+
+	input = &schema.OpenAIRequest{Messages: []schema.Message{{Role: "user", Content: `{{variable "unknown"}}`}}}
+	assert.EqualError(t, expandVariables(input), `unknown variable "unknown"`)
+}
+
+func TestReadRequestPreset(t *testing.T) {
+	appConfig := &config.ApplicationConfig{
+		Context: context.Background(),
+		GenerationPresets: map[string]json.RawMessage{
+			"precise": json.RawMessage(`{"temperature": 0.1, "top_k": 10, "stop": "###"}`),
+		},
+	}
+	app := fiber.New()
+	app.Post("/", func(c *fiber.Ctx) error {
+		_, input, err := readRequest(c, nil, nil, appConfig, false)
+		if err != nil {
+			return err
+		}
+		return c.JSON(input)
+	})
+
+	read := func(body string) (int, *schema.OpenAIRequest) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		input := &schema.OpenAIRequest{}
+		if resp.StatusCode == fiber.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(input))
+		}
+		return resp.StatusCode, input
+	}
+
+	t.Run("the fields of the request override the preset", func(t *testing.T) {
+		status, input := read(`{"model": "foo", "preset": "precise", "temperature": 0.7}`)
+		require.Equal(t, fiber.StatusOK, status)
+		require.NotNil(t, input.Temperature)
+		assert.Equal(t, 0.7, *input.Temperature)
+	})
+
+	t.Run("the preset fills the fields missing from the request", func(t *testing.T) {
+		status, input := read(`{"model": "foo", "preset": "precise", "temperature": 0.7}`)
+		require.Equal(t, fiber.StatusOK, status)
+		require.NotNil(t, input.TopK)
+		assert.Equal(t, 10, *input.TopK)
+		assert.Equal(t, "###", input.Stop)
+		assert.Equal(t, "foo", input.Model)
+	})
+
+	t.Run("an unknown preset is a bad request", func(t *testing.T) {
+		status, _ := read(`{"model": "foo", "preset": "unknown"}`)
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+}
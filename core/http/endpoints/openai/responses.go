@@ -0,0 +1,799 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// The statuses of the responses and of their output items, https://platform.openai.com/docs/api-reference/responses/object
+const (
+	ResponseInProgress = "in_progress"
+	ResponseCompleted  = "completed"
+	ResponseIncomplete = "incomplete"
+	ResponseFailed     = "failed"
+)
+
+// ResponseContent is a part of the content of a message: input_text, input_image or output_text
+type ResponseContent struct {
+	Type        string               `json:"type"`
+	Text        string               `json:"text,omitempty"`
+	ImageURL    string               `json:"image_url,omitempty"`
+	Annotations []ResponseAnnotation `json:"annotations,omitempty"`
+}
+
+// ResponseAnnotation cites the file of a chunk found by file_search at the index of the text
+type ResponseAnnotation struct {
+	Type   string `json:"type"`
+	Index  int    `json:"index"`
+	FileID string `json:"file_id"`
+}
+
+// ResponseContents is the content of a message, given as a text or as a list of parts
+type ResponseContents []ResponseContent
+
+func (rc *ResponseContents) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*rc = ResponseContents{{Type: "input_text", Text: text}}
+		return nil
+	}
+	var parts []ResponseContent
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("the content must be a text or a list of parts: %w", err)
+	}
+	*rc = parts
+	return nil
+}
+
+// Text returns the text parts of the content
+func (rc ResponseContents) Text() string {
+	texts := []string{}
+	for _, c := range rc {
+		if c.Text != "" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type FileSearchCallResult struct {
+	FileID string  `json:"file_id"`
+	Text   string  `json:"text"`
+	Score  float32 `json:"score"`
+}
+
+// ResponseItem is an item of the input or of the output of a response: a message, a call of a function and its
+// output, or a search of the vector stores with file_search
+type ResponseItem struct {
+	Type    string           `json:"type"`
+	ID      string           `json:"id,omitempty"`
+	Status  string           `json:"status,omitempty"`
+	Role    string           `json:"role,omitempty"`
+	Content ResponseContents `json:"content,omitempty"`
+
+	// function_call and function_call_output
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+
+	// file_search_call
+	Queries []string               `json:"queries,omitempty"`
+	Results []FileSearchCallResult `json:"results,omitempty"`
+}
+
+// ResponseTool is a function the model may call, or the built-in file_search tool searching the vector stores
+type ResponseTool struct {
+	Type           string                 `json:"type"`
+	Name           string                 `json:"name,omitempty"`
+	Description    string                 `json:"description,omitempty"`
+	Parameters     map[string]interface{} `json:"parameters,omitempty"`
+	Strict         bool                   `json:"strict,omitempty"`
+	VectorStoreIDs []string               `json:"vector_store_ids,omitempty"`
+	MaxNumResults  int                    `json:"max_num_results,omitempty"`
+}
+
+// ResponseTextFormat is the format of the text of the output: text, json_object or json_schema
+type ResponseTextFormat struct {
+	Type   string                 `json:"type"`
+	Name   string                 `json:"name,omitempty"`
+	Schema map[string]interface{} `json:"schema,omitempty"`
+	Strict bool                   `json:"strict,omitempty"`
+}
+
+type ResponseText struct {
+	Format ResponseTextFormat `json:"format"`
+}
+
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ResponseIncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+type ResponseUsage struct {
+	InputTokens         int            `json:"input_tokens"`
+	InputTokensDetails  map[string]int `json:"input_tokens_details"`
+	OutputTokens        int            `json:"output_tokens"`
+	OutputTokensDetails map[string]int `json:"output_tokens_details"`
+	TotalTokens         int            `json:"total_tokens"`
+}
+
+// Response is the answer of a model to input items, https://platform.openai.com/docs/api-reference/responses/object
+type Response struct {
+	ID                 string                     `json:"id"`
+	Object             string                     `json:"object"`
+	CreatedAt          int64                      `json:"created_at"`
+	Status             string                     `json:"status"`
+	Error              *ResponseError             `json:"error"`
+	IncompleteDetails  *ResponseIncompleteDetails `json:"incomplete_details"`
+	Instructions       string                     `json:"instructions,omitempty"`
+	MaxOutputTokens    *int                       `json:"max_output_tokens"`
+	Model              string                     `json:"model"`
+	Output             []ResponseItem             `json:"output"`
+	PreviousResponseID string                     `json:"previous_response_id,omitempty"`
+	Store              bool                       `json:"store"`
+	Temperature        *float64                   `json:"temperature"`
+	Text               ResponseText               `json:"text"`
+	ToolChoice         interface{}                `json:"tool_choice"`
+	Tools              []ResponseTool             `json:"tools"`
+	TopP               *float64                   `json:"top_p"`
+	Usage              *ResponseUsage             `json:"usage"`
+	Metadata           map[string]string          `json:"metadata"`
+}
+
+// ResponseRequest creates a response, https://platform.openai.com/docs/api-reference/responses/create
+type ResponseRequest struct {
+	Model              string            `json:"model"`
+	Input              json.RawMessage   `json:"input"` // A text, or a list of input items
+	Instructions       string            `json:"instructions,omitempty"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Tools              []ResponseTool    `json:"tools,omitempty"`
+	ToolChoice         interface{}       `json:"tool_choice,omitempty"`
+	Temperature        *float64          `json:"temperature,omitempty"`
+	TopP               *float64          `json:"top_p,omitempty"`
+	MaxOutputTokens    *int              `json:"max_output_tokens,omitempty"`
+	Text               *ResponseText     `json:"text,omitempty"`
+	Include            []string          `json:"include,omitempty"` // file_search_call.results adds the chunks found to the output
+	Stream             bool              `json:"stream,omitempty"`
+	Store              *bool             `json:"store,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// storedResponse is a response with its input items, the conversation going on with previous_response_id
+type storedResponse struct {
+	Response Response       `json:"response"`
+	Input    []ResponseItem `json:"input"`
+}
+
+var (
+	Responses           = []storedResponse{}
+	ResponsesConfigFile = "responses.json"
+
+	responsesMu sync.Mutex
+)
+
+// LoadResponses loads the stored responses
+func LoadResponses(appConfig *config.ApplicationConfig) {
+	responsesMu.Lock()
+	defer responsesMu.Unlock()
+	utils.LoadConfig(appConfig.ConfigsDir, ResponsesConfigFile, &Responses)
+}
+
+func findResponse(id string) (int, bool) {
+	for i, r := range Responses {
+		if r.Response.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// responseHistory returns the items of the conversation up to the response: the input and the output of the
+// responses it follows, the first one first. The caller holds responsesMu
+func responseHistory(id string) ([]ResponseItem, error) {
+	chain := []storedResponse{}
+	for seen := map[string]bool{}; id != "" && !seen[id]; {
+		seen[id] = true
+		i, found := findResponse(id)
+		if !found {
+			return nil, fmt.Errorf("previous response %s not found", id)
+		}
+		chain = append(chain, Responses[i])
+		id = Responses[i].Response.PreviousResponseID
+	}
+	items := []ResponseItem{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		items = append(items, chain[i].Input...)
+		items = append(items, chain[i].Response.Output...)
+	}
+	return items, nil
+}
+
+// parseResponseInput returns the input items of the request, a text being a message of the user
+func parseResponseInput(input json.RawMessage) ([]ResponseItem, error) {
+	var text string
+	if err := json.Unmarshal(input, &text); err == nil {
+		return []ResponseItem{{Type: "message", Role: "user", Content: ResponseContents{{Type: "input_text", Text: text}}}}, nil
+	}
+	items := []ResponseItem{}
+	if err := json.Unmarshal(input, &items); err != nil {
+		return nil, fmt.Errorf("input must be a text or a list of input items: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("input is required")
+	}
+	for i := range items {
+		if items[i].Type == "" && items[i].Role != "" {
+			items[i].Type = "message"
+		}
+		switch items[i].Type {
+		case "message":
+			if !slices.Contains([]string{"user", "assistant", "system", "developer"}, items[i].Role) {
+				return nil, fmt.Errorf("invalid role %q of input item %d", items[i].Role, i)
+			}
+		case "function_call", "function_call_output":
+			if items[i].CallID == "" {
+				return nil, fmt.Errorf("input item %d has no call_id", i)
+			}
+		case "file_search_call":
+		default:
+			return nil, fmt.Errorf("unsupported type %q of input item %d", items[i].Type, i)
+		}
+	}
+	return items, nil
+}
+
+// responseMessages returns the chat messages of the items of the conversation, after the instructions
+func responseMessages(instructions string, items []ResponseItem) ([]schema.Message, error) {
+	messages := []schema.Message{}
+	if instructions != "" {
+		messages = append(messages, schema.Message{Role: "system", Content: instructions})
+	}
+	functionNames := map[string]string{}
+	for _, item := range items {
+		switch item.Type {
+		case "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			content, err := responseMessageContent(item.Content)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, schema.Message{Role: role, Content: content})
+		case "function_call":
+			functionNames[item.CallID] = item.Name
+			call := schema.ToolCall{ID: item.CallID, Type: "function", FunctionCall: schema.FunctionCall{Name: item.Name, Arguments: item.Arguments}}
+			// the calls of a turn are in the same message of the assistant
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" {
+				call.Index = len(messages[last].ToolCalls)
+				messages[last].ToolCalls = append(messages[last].ToolCalls, call)
+				continue
+			}
+			messages = append(messages, schema.Message{Role: "assistant", Content: "", ToolCalls: []schema.ToolCall{call}})
+		case "function_call_output":
+			messages = append(messages, schema.Message{Role: "tool", Name: functionNames[item.CallID], Content: item.Output})
+		}
+	}
+	return messages, nil
+}
+
+// responseMessageContent returns the content of a chat message: its text, or its parts when it has images
+func responseMessageContent(content ResponseContents) (interface{}, error) {
+	parts := []fiber.Map{}
+	hasImages := false
+	for _, c := range content {
+		switch c.Type {
+		case "input_text", "output_text", "text":
+			parts = append(parts, fiber.Map{"type": "text", "text": c.Text})
+		case "input_image":
+			hasImages = true
+			parts = append(parts, fiber.Map{"type": "image_url", "image_url": fiber.Map{"url": c.ImageURL}})
+		default:
+			return nil, fmt.Errorf("unsupported content type %q", c.Type)
+		}
+	}
+	if !hasImages {
+		return content.Text(), nil
+	}
+	return parts, nil
+}
+
+// chatTools returns the tools and the tool choice of the chat request of the response
+func chatTools(tools []ResponseTool, toolChoice interface{}) ([]functions.Tool, interface{}, error) {
+	chat := []functions.Tool{}
+	for _, t := range tools {
+		switch t.Type {
+		case "function":
+			chat = append(chat, functions.Tool{Type: "function", Function: functions.Function{
+				Name: t.Name, Description: t.Description, Parameters: t.Parameters, Strict: t.Strict,
+			}})
+		case string(FileSearch):
+		default:
+			return nil, nil, fmt.Errorf("the tool %q is not supported", t.Type)
+		}
+	}
+	switch choice := toolChoice.(type) {
+	case string:
+		if choice == "none" {
+			return nil, nil, nil
+		}
+	case map[string]interface{}:
+		if choice["type"] == "function" {
+			return chat, fiber.Map{"type": "function", "function": fiber.Map{"name": choice["name"]}}, nil
+		}
+	}
+	return chat, nil, nil
+}
+
+// CreateResponseEndpoint is the OpenAI Responses API endpoint https://platform.openai.com/docs/api-reference/responses/create
+// @Summary Create a model response to input items, streaming its events with stream.
+// @Param request body ResponseRequest true "query params"
+// @Success 200 {object} Response "Response"
+// @Router /v1/responses [post]
+func CreateResponseEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ResponseRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+		if !modelExists(cl, ml, request.Model) {
+			return c.Status(fiber.StatusBadRequest).SendString("Model " + request.Model + " not found")
+		}
+		input, err := parseResponseInput(request.Input)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		if _, _, err := chatTools(request.Tools, request.ToolChoice); err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+
+		responsesMu.Lock()
+		history, err := responseHistory(request.PreviousResponseID)
+		responsesMu.Unlock()
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+
+		if request.Metadata == nil {
+			request.Metadata = map[string]string{}
+		}
+		if request.Tools == nil {
+			request.Tools = []ResponseTool{}
+		}
+		text := ResponseText{Format: ResponseTextFormat{Type: "text"}}
+		if request.Text != nil {
+			text = *request.Text
+		}
+		toolChoice := request.ToolChoice
+		if toolChoice == nil {
+			toolChoice = "auto"
+		}
+		response := &Response{
+			ID:                 "resp_" + uuid.New().String(),
+			Object:             "response",
+			CreatedAt:          time.Now().Unix(),
+			Status:             ResponseInProgress,
+			Instructions:       request.Instructions,
+			MaxOutputTokens:    request.MaxOutputTokens,
+			Model:              request.Model,
+			Output:             []ResponseItem{},
+			PreviousResponseID: request.PreviousResponseID,
+			Store:              request.Store == nil || *request.Store,
+			Temperature:        request.Temperature,
+			Text:               text,
+			ToolChoice:         toolChoice,
+			Tools:              request.Tools,
+			TopP:               request.TopP,
+			Metadata:           request.Metadata,
+		}
+
+		// the response answers like a chat request of the client, which is queued as any inference
+		r := &responseRunner{
+			cl:        cl,
+			ml:        ml,
+			sl:        sl,
+			appConfig: appConfig,
+			client:    newInternalClient(c, ""),
+			request:   request,
+			response:  response,
+			emit:      func(string, fiber.Map) {},
+		}
+		conversation := append(history, input...)
+		if !request.Stream {
+			r.run(c.UserContext(), conversation)
+			r.store(input)
+			return c.JSON(response)
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+			// the response is generated to be stored when the client disconnects, without its events
+			closed := false
+			r.emit = func(event string, data fiber.Map) {
+				if closed {
+					return
+				}
+				payload, err := json.Marshal(data)
+				if err != nil {
+					log.Error().Err(err).Str("event", event).Msg("unable to encode the event of the response")
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+				if err := w.Flush(); err != nil {
+					log.Debug().Err(err).Str("response", response.ID).Msg("response stream closed by the client")
+					closed = true
+				}
+			}
+			r.run(appConfig.Context, conversation)
+			r.store(input)
+		}))
+		return nil
+	}
+}
+
+// GetResponseEndpoint is the OpenAI Responses API endpoint https://platform.openai.com/docs/api-reference/responses/get
+// @Summary Get a stored response.
+// @Success 200 {object} Response "Response"
+// @Router /v1/responses/{response_id} [get]
+func GetResponseEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		responsesMu.Lock()
+		defer responsesMu.Unlock()
+		i, found := findResponse(c.Params("response_id"))
+		if !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find response with id: %s", c.Params("response_id")))
+		}
+		return c.JSON(Responses[i].Response)
+	}
+}
+
+// DeleteResponseEndpoint is the OpenAI Responses API endpoint https://platform.openai.com/docs/api-reference/responses/delete
+// @Summary Delete a stored response.
+// @Success 200 {object} schema.DeleteAssistantResponse "Response"
+// @Router /v1/responses/{response_id} [delete]
+func DeleteResponseEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("response_id")
+		responsesMu.Lock()
+		defer responsesMu.Unlock()
+		i, found := findResponse(id)
+		if !found {
+			return c.Status(fiber.StatusNotFound).JSON(schema.DeleteAssistantResponse{ID: id, Object: "response.deleted", Deleted: false})
+		}
+		Responses = slices.Delete(Responses, i, i+1)
+		utils.SaveConfig(appConfig.ConfigsDir, ResponsesConfigFile, Responses)
+		return c.JSON(schema.DeleteAssistantResponse{ID: id, Object: "response.deleted", Deleted: true})
+	}
+}
+
+// ListResponseInputItemsEndpoint is the OpenAI Responses API endpoint https://platform.openai.com/docs/api-reference/responses/input-items
+// @Summary List the input items of a stored response.
+// @Param limit query int false "Limit the number of items returned"
+// @Param order query string false "Order of items returned"
+// @Param after query string false "Return items after the given ID"
+// @Param before query string false "Return items before the given ID"
+// @Success 200 {object} []ResponseItem "Response"
+// @Router /v1/responses/{response_id}/input_items [get]
+func ListResponseInputItemsEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		responsesMu.Lock()
+		defer responsesMu.Unlock()
+		i, found := findResponse(c.Params("response_id"))
+		if !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find response with id: %s", c.Params("response_id")))
+		}
+		page, err := listPage(c, Responses[i].Input, func(item ResponseItem) string { return item.ID })
+		if err != nil {
+			return err
+		}
+		return c.JSON(page)
+	}
+}
+
+// responseRunner generates a response: it searches the vector stores with file_search, then streams the answer of
+// the model in the output items of the response, its text and its calls of functions
+type responseRunner struct {
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+	sl        *model.ModelLoader
+	appConfig *config.ApplicationConfig
+	client    *internalClient
+	request   *ResponseRequest
+	response  *Response
+
+	// emit sends the events of the response to the client streaming them
+	emit     func(event string, data fiber.Map)
+	sequence int
+}
+
+// send emits the event, numbered in the order of the events of the response
+func (r *responseRunner) send(event string, data fiber.Map) {
+	data["type"] = event
+	data["sequence_number"] = r.sequence
+	r.sequence++
+	r.emit(event, data)
+}
+
+// store saves the response with its input items, unless it's not to be stored
+func (r *responseRunner) store(input []ResponseItem) {
+	if !r.response.Store {
+		return
+	}
+	for i := range input {
+		if input[i].ID == "" {
+			input[i].ID = "msg_" + uuid.New().String()
+		}
+	}
+	responsesMu.Lock()
+	defer responsesMu.Unlock()
+	Responses = append(Responses, storedResponse{Response: *r.response, Input: input})
+	utils.SaveConfig(r.appConfig.ConfigsDir, ResponsesConfigFile, Responses)
+}
+
+func (r *responseRunner) run(ctx context.Context, conversation []ResponseItem) {
+	r.send("response.created", fiber.Map{"response": r.response})
+	r.send("response.in_progress", fiber.Map{"response": r.response})
+
+	finishReason, err := r.answer(ctx, conversation)
+	switch {
+	case err != nil:
+		r.response.Status = ResponseFailed
+		r.response.Error = &ResponseError{Code: "server_error", Message: err.Error()}
+		log.Warn().Err(err).Str("response", r.response.ID).Msg("response failed")
+	case finishReason == "length":
+		r.response.Status = ResponseIncomplete
+		r.response.IncompleteDetails = &ResponseIncompleteDetails{Reason: "max_output_tokens"}
+	default:
+		r.response.Status = ResponseCompleted
+	}
+	r.send("response."+r.response.Status, fiber.Map{"response": r.response})
+}
+
+// addItem adds the item to the output of the response and returns its index
+func (r *responseRunner) addItem(item ResponseItem) int {
+	r.response.Output = append(r.response.Output, item)
+	index := len(r.response.Output) - 1
+	r.send("response.output_item.added", fiber.Map{"output_index": index, "item": item})
+	return index
+}
+
+// answer streams the answer of the model to the conversation, with the chunks found by file_search. It returns the
+// reason the model stopped
+func (r *responseRunner) answer(ctx context.Context, conversation []ResponseItem) (string, error) {
+	results, err := r.fileSearch(ctx, conversation)
+	if err != nil {
+		return "", fmt.Errorf("file_search failed: %w", err)
+	}
+
+	instructions := r.request.Instructions
+	if len(results) > 0 {
+		excerpts := []string{fileSearchPrompt}
+		for i, result := range results {
+			excerpts = append(excerpts, fmt.Sprintf("[%d] %s", i+1, result.Content))
+		}
+		instructions = strings.TrimSpace(instructions + "\n\n" + strings.Join(excerpts, "\n\n"))
+	}
+	messages, err := responseMessages(instructions, conversation)
+	if err != nil {
+		return "", err
+	}
+	tools, toolChoice, err := chatTools(r.request.Tools, r.request.ToolChoice)
+	if err != nil {
+		return "", err
+	}
+	chat := fiber.Map{"model": r.request.Model, "messages": messages, "stream": true}
+	if len(tools) > 0 {
+		chat["tools"] = tools
+	}
+	if toolChoice != nil {
+		chat["tool_choice"] = toolChoice
+	}
+	if r.request.Temperature != nil {
+		chat["temperature"] = *r.request.Temperature
+	}
+	if r.request.TopP != nil {
+		chat["top_p"] = *r.request.TopP
+	}
+	if r.request.MaxOutputTokens != nil {
+		chat["max_tokens"] = *r.request.MaxOutputTokens
+	}
+	switch format := r.response.Text.Format; format.Type {
+	case "json_object":
+		chat["response_format"] = fiber.Map{"type": "json_object"}
+	case "json_schema":
+		jsonSchema := schema.JsonSchema{Name: format.Name, Strict: format.Strict, Schema: format.Schema}
+		chat["response_format"] = fiber.Map{"type": "json_schema", "json_schema": jsonSchema}
+	}
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return "", err
+	}
+
+	response := r.client.post("/v1/chat/completions", body)
+	if !response.Response.IsBodyStream() {
+		return "", fmt.Errorf("the chat completion failed with status %d: %s", response.Response.StatusCode(), response.Response.Body())
+	}
+	defer response.Response.CloseBodyStream()
+
+	// the message and the calls of functions are output items added with their first delta
+	messageIndex := -1
+	text := strings.Builder{}
+	toolCalls := []schema.ToolCall{}
+	callIndexes := map[int]int{}
+	finishReason := ""
+	reader := bufio.NewReader(response.Response.BodyStream())
+	for ctx.Err() == nil {
+		line, readErr := reader.ReadBytes('\n')
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+		if ok && !bytes.Equal(data, []byte("[DONE]")) {
+			chunk := schema.OpenAIResponse{}
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				return "", fmt.Errorf("invalid chunk of the chat completion: %w", err)
+			}
+			if chunk.Usage.TotalTokens > 0 {
+				r.response.Usage = &ResponseUsage{
+					InputTokens:         chunk.Usage.PromptTokens,
+					InputTokensDetails:  map[string]int{"cached_tokens": 0},
+					OutputTokens:        chunk.Usage.CompletionTokens,
+					OutputTokensDetails: map[string]int{"reasoning_tokens": 0},
+					TotalTokens:         chunk.Usage.TotalTokens,
+				}
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != "" {
+				finishReason = chunk.Choices[0].FinishReason
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+				delta := chunk.Choices[0].Delta
+				if content, _ := delta.Content.(string); content != "" && len(toolCalls) == 0 {
+					if messageIndex < 0 {
+						messageIndex = r.addItem(ResponseItem{Type: "message", ID: "msg_" + uuid.New().String(), Status: ResponseInProgress, Role: "assistant", Content: ResponseContents{}})
+						r.send("response.content_part.added", fiber.Map{
+							"item_id": r.response.Output[messageIndex].ID, "output_index": messageIndex, "content_index": 0,
+							"part": ResponseContent{Type: "output_text"},
+						})
+					}
+					text.WriteString(content)
+					r.send("response.output_text.delta", fiber.Map{
+						"item_id": r.response.Output[messageIndex].ID, "output_index": messageIndex, "content_index": 0, "delta": content,
+					})
+				}
+				toolCalls = mergeToolCallDeltas(toolCalls, delta.ToolCalls)
+				for _, d := range delta.ToolCalls {
+					index, added := callIndexes[d.Index]
+					if !added {
+						callID := d.ID
+						if callID == "" {
+							callID = "call_" + uuid.New().String()
+						}
+						index = r.addItem(ResponseItem{Type: "function_call", ID: "fc_" + uuid.New().String(), Status: ResponseInProgress, CallID: callID})
+						callIndexes[d.Index] = index
+					}
+					r.response.Output[index].Name = toolCalls[d.Index].FunctionCall.Name
+					if d.FunctionCall.Arguments != "" {
+						r.response.Output[index].Arguments += d.FunctionCall.Arguments
+						r.send("response.function_call_arguments.delta", fiber.Map{
+							"item_id": r.response.Output[index].ID, "output_index": index, "delta": d.FunctionCall.Arguments,
+						})
+					}
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	status := ResponseCompleted
+	if ctx.Err() != nil || finishReason == "length" {
+		status = ResponseIncomplete
+	}
+	for index := range r.response.Output {
+		item := &r.response.Output[index]
+		if item.Status != ResponseInProgress {
+			continue
+		}
+		item.Status = status
+		switch item.Type {
+		case "message":
+			part := ResponseContent{Type: "output_text", Text: text.String(), Annotations: responseCitations(text.String(), results)}
+			item.Content = ResponseContents{part}
+			r.send("response.output_text.done", fiber.Map{"item_id": item.ID, "output_index": index, "content_index": 0, "text": part.Text})
+			r.send("response.content_part.done", fiber.Map{"item_id": item.ID, "output_index": index, "content_index": 0, "part": part})
+		case "function_call":
+			r.send("response.function_call_arguments.done", fiber.Map{"item_id": item.ID, "output_index": index, "arguments": item.Arguments})
+		}
+		r.send("response.output_item.done", fiber.Map{"output_index": index, "item": *item})
+	}
+	return finishReason, ctx.Err()
+}
+
+// fileSearch returns the chunks of the vector stores of the file_search tools the most similar to the last message
+// of the user, adding the search to the output of the response
+func (r *responseRunner) fileSearch(ctx context.Context, conversation []ResponseItem) ([]backend.FileSearchResult, error) {
+	stores := []string{}
+	limit := fileSearchResults
+	for _, t := range r.request.Tools {
+		if t.Type == string(FileSearch) {
+			stores = append(stores, t.VectorStoreIDs...)
+			if t.MaxNumResults > 0 {
+				limit = t.MaxNumResults
+			}
+		}
+	}
+	query := ""
+	for _, item := range conversation {
+		if item.Type == "message" && item.Role == "user" {
+			query = item.Content.Text()
+		}
+	}
+	if len(stores) == 0 || query == "" {
+		return nil, nil
+	}
+
+	index := r.addItem(ResponseItem{Type: "file_search_call", ID: "fs_" + uuid.New().String(), Status: ResponseInProgress, Queries: []string{query}})
+	id := r.response.Output[index].ID
+	r.send("response.file_search_call.in_progress", fiber.Map{"item_id": id, "output_index": index})
+	r.send("response.file_search_call.searching", fiber.Map{"item_id": id, "output_index": index})
+
+	slices.Sort(stores)
+	results := []backend.FileSearchResult{}
+	for _, store := range slices.Compact(stores) {
+		found, err := backend.FileSearch(ctx, r.sl, r.ml, r.cl, r.appConfig, store, query, limit)
+		if err != nil {
+			r.response.Output[index].Status = ResponseFailed
+			return nil, err
+		}
+		results = append(results, found...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	item := &r.response.Output[index]
+	item.Status = ResponseCompleted
+	if slices.Contains(r.request.Include, "file_search_call.results") {
+		for _, result := range results {
+			item.Results = append(item.Results, FileSearchCallResult{FileID: result.FileID, Text: result.Content, Score: result.Score})
+		}
+	}
+	r.send("response.file_search_call.completed", fiber.Map{"item_id": id, "output_index": index})
+	r.send("response.output_item.done", fiber.Map{"output_index": index, "item": *item})
+	return results, nil
+}
+
+// responseCitations returns the annotations of the excerpts of results cited in text
+func responseCitations(text string, results []backend.FileSearchResult) []ResponseAnnotation {
+	annotations := []ResponseAnnotation{}
+	for _, a := range fileCitations(text, results) {
+		annotations = append(annotations, ResponseAnnotation{Type: "file_citation", Index: a.StartIndex, FileID: a.FileCitation.FileID})
+	}
+	return annotations
+}
@@ -0,0 +1,189 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startUpResponsesApp returns the app with a fake chat completion, calling the function when the request has tools
+// and answering "Paris" otherwise, and the chat requests it received
+func startUpResponsesApp(t *testing.T) (*fiber.App, *[]schema.OpenAIRequest) {
+	Responses = []storedResponse{}
+	t.Cleanup(func() { Responses = []storedResponse{} })
+
+	modelsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(modelsDir, "phi-2"), []byte{}, 0600))
+	cl := &config.BackendConfigLoader{}
+	ml := model.NewModelLoader(modelsDir)
+	appConfig := &config.ApplicationConfig{Context: context.Background(), ConfigsDir: t.TempDir()}
+
+	chats := []schema.OpenAIRequest{}
+	app := fiber.New()
+	app.Post("/v1/responses", CreateResponseEndpoint(cl, ml, ml, appConfig))
+	app.Get("/v1/responses/:response_id", GetResponseEndpoint(appConfig))
+	app.Delete("/v1/responses/:response_id", DeleteResponseEndpoint(appConfig))
+	app.Get("/v1/responses/:response_id/input_items", ListResponseInputItemsEndpoint(appConfig))
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		request := schema.OpenAIRequest{}
+		if err := c.BodyParser(&request); err != nil {
+			return err
+		}
+		assert.Equal(t, "phi-2", request.Model)
+		assert.True(t, request.Stream)
+		chats = append(chats, request)
+
+		chunks := []schema.Choice{{Delta: &schema.Message{Content: "Par"}}, {Delta: &schema.Message{Content: "is"}}, {FinishReason: "stop"}}
+		if len(request.Tools) > 0 && request.Messages[len(request.Messages)-1].Role == "user" {
+			chunks = []schema.Choice{
+				{Delta: &schema.Message{ToolCalls: []schema.ToolCall{{ID: "call_chatcmpl-1_0", Type: "function", FunctionCall: schema.FunctionCall{Name: "get_weather"}}}}},
+				{Delta: &schema.Message{ToolCalls: []schema.ToolCall{{FunctionCall: schema.FunctionCall{Arguments: `{"city": `}}}}},
+				{Delta: &schema.Message{ToolCalls: []schema.ToolCall{{FunctionCall: schema.FunctionCall{Arguments: `"Paris"}`}}}}},
+				{FinishReason: "tool_calls"},
+			}
+		}
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			for _, choice := range chunks {
+				chunk, _ := json.Marshal(schema.OpenAIResponse{Choices: []schema.Choice{choice}})
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+				w.Flush()
+			}
+			chunk, _ := json.Marshal(schema.OpenAIResponse{Usage: schema.OpenAIUsage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}})
+			fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", chunk)
+			w.Flush()
+		})
+		return nil
+	})
+	return app, &chats
+}
+
+func TestResponse(t *testing.T) {
+	app, chats := startUpResponsesApp(t)
+
+	response := Response{}
+	postJSON(t, app, "/v1/responses", `{"model": "phi-2", "instructions": "Answer in one word.", "input": "The capital of France?"}`, &response)
+	assert.Equal(t, "response", response.Object)
+	assert.Equal(t, ResponseCompleted, response.Status)
+	require.Len(t, response.Output, 1)
+	assert.Equal(t, "message", response.Output[0].Type)
+	assert.Equal(t, "assistant", response.Output[0].Role)
+	assert.Equal(t, "Paris", response.Output[0].Content.Text())
+	require.NotNil(t, response.Usage)
+	assert.Equal(t, 14, response.Usage.TotalTokens)
+
+	require.Len(t, *chats, 1)
+	messages := (*chats)[0].Messages
+	require.Len(t, messages, 2)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "Answer in one word.", messages[0].Content)
+	assert.Equal(t, "The capital of France?", messages[1].Content)
+
+	// the next response goes on with the conversation of the previous one
+	next := Response{}
+	postJSON(t, app, "/v1/responses", `{"model": "phi-2", "previous_response_id": "`+response.ID+`", "input": [{"role": "user", "content": [{"type": "input_text", "text": "And of Italy?"}]}]}`, &next)
+	assert.Equal(t, response.ID, next.PreviousResponseID)
+	require.Len(t, *chats, 2)
+	messages = (*chats)[1].Messages
+	require.Len(t, messages, 3)
+	assert.Equal(t, "The capital of France?", messages[0].Content)
+	assert.Equal(t, "assistant", messages[1].Role)
+	assert.Equal(t, "Paris", messages[1].Content)
+	assert.Equal(t, "And of Italy?", messages[2].Content)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/v1/responses/"+next.ID+"/input_items", nil))
+	require.NoError(t, err)
+	page := struct {
+		Data []ResponseItem `json:"data"`
+	}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "And of Italy?", page.Data[0].Content.Text())
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodDelete, "/v1/responses/"+response.ID, nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/v1/responses/"+response.ID, nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	resp = postJSON(t, app, "/v1/responses", `{"model": "phi-2", "input": "Hi", "tools": [{"type": "web_search_preview"}]}`, nil)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestResponseFunctionCall(t *testing.T) {
+	app, chats := startUpResponsesApp(t)
+	tools := `"tools": [{"type": "function", "name": "get_weather", "parameters": {"type": "object", "properties": {"city": {"type": "string"}}}}]`
+
+	response := Response{}
+	postJSON(t, app, "/v1/responses", `{"model": "phi-2", "input": "The weather in Paris?", `+tools+`}`, &response)
+	require.Len(t, response.Output, 1)
+	call := response.Output[0]
+	assert.Equal(t, "function_call", call.Type)
+	assert.Equal(t, "get_weather", call.Name)
+	assert.Equal(t, `{"city": "Paris"}`, call.Arguments)
+	assert.Equal(t, "call_chatcmpl-1_0", call.CallID)
+	require.Len(t, (*chats)[0].Tools, 1)
+	assert.Equal(t, "get_weather", (*chats)[0].Tools[0].Function.Name)
+
+	// the output of the function is sent back with the call it answers
+	output := Response{}
+	postJSON(t, app, "/v1/responses", `{"model": "phi-2", "previous_response_id": "`+response.ID+`", "input": [{"type": "function_call_output", "call_id": "`+call.CallID+`", "output": "Sunny"}], `+tools+`}`, &output)
+	assert.Equal(t, "Paris", output.Output[0].Content.Text())
+	messages := (*chats)[1].Messages
+	require.Len(t, messages, 3)
+	assert.Equal(t, "assistant", messages[1].Role)
+	require.Len(t, messages[1].ToolCalls, 1)
+	assert.Equal(t, call.CallID, messages[1].ToolCalls[0].ID)
+	assert.Equal(t, "tool", messages[2].Role)
+	assert.Equal(t, "get_weather", messages[2].Name)
+	assert.Equal(t, "Sunny", messages[2].Content)
+}
+
+func TestResponseStream(t *testing.T) {
+	app, _ := startUpResponsesApp(t)
+
+	resp := postJSON(t, app, "/v1/responses", `{"model": "phi-2", "input": "The capital of France?", "stream": true, "store": false}`, nil)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	events := []string{}
+	text := ""
+	for _, line := range strings.Split(string(body), "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			event := struct {
+				Type           string `json:"type"`
+				SequenceNumber int    `json:"sequence_number"`
+				Delta          string `json:"delta"`
+			}{}
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			assert.Equal(t, len(events), event.SequenceNumber)
+			events = append(events, event.Type)
+			text += event.Delta
+		}
+	}
+	assert.Equal(t, []string{
+		"response.created", "response.in_progress",
+		"response.output_item.added", "response.content_part.added",
+		"response.output_text.delta", "response.output_text.delta",
+		"response.output_text.done", "response.content_part.done", "response.output_item.done",
+		"response.completed",
+	}, events)
+	assert.Equal(t, "Paris", text)
+	assert.Empty(t, Responses)
+}
@@ -0,0 +1,179 @@
+package openai
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/rs/zerolog/log"
+)
+
+// ListStoredCompletionsEndpoint is the OpenAI API endpoint to list the stored chat completions https://platform.openai.com/docs/api-reference/chat/list
+// @Summary List the chat completions stored with `store: true`.
+// @Param model query string false "Model"
+// @Param after query string false "ID of the last completion of the previous page"
+// @Param limit query int false "Number of completions to return"
+// @Param order query string false "asc or desc"
+// @Success 200 {object} schema.StoredCompletionsList "Response"
+// @Router /v1/chat/completions [get]
+func ListStoredCompletionsEndpoint(completions *services.StoredCompletionsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		filter := services.StoredCompletionsFilter{
+			Model:    c.Query("model"),
+			After:    c.Query("after"),
+			Limit:    c.QueryInt("limit"),
+			Order:    c.Query("order"),
+			Metadata: metadataQuery(c),
+		}
+
+		data, hasMore := completions.List(filter)
+		list := schema.StoredCompletionsList{
+			Object:  "list",
+			Data:    data,
+			HasMore: hasMore,
+		}
+		if len(data) > 0 {
+			list.FirstID = data[0].ID
+			list.LastID = data[len(data)-1].ID
+		}
+		return c.JSON(list)
+	}
+}
+
+// GetStoredCompletionEndpoint is the OpenAI API endpoint to retrieve a stored chat completion https://platform.openai.com/docs/api-reference/chat/get
+// @Summary Get a chat completion stored with `store: true`.
+// @Param completion_id path string true "Completion ID"
+// @Success 200 {object} schema.StoredCompletion "Response"
+// @Router /v1/chat/completions/{completion_id} [get]
+func GetStoredCompletionEndpoint(completions *services.StoredCompletionsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		completion, err := completions.Get(c.Params("completion_id"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return c.JSON(completion)
+	}
+}
+
+// GetStoredCompletionMessagesEndpoint is the OpenAI API endpoint to retrieve the messages of a stored chat completion https://platform.openai.com/docs/api-reference/chat/getMessages
+// @Summary Get the messages of the request of a chat completion stored with `store: true`.
+// @Param completion_id path string true "Completion ID"
+// @Success 200 {object} schema.StoredCompletionMessages "Response"
+// @Router /v1/chat/completions/{completion_id}/messages [get]
+func GetStoredCompletionMessagesEndpoint(completions *services.StoredCompletionsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		messages, err := completions.Messages(c.Params("completion_id"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return c.JSON(schema.StoredCompletionMessages{
+			Object: "list",
+			Data:   messages,
+		})
+	}
+}
+
+// UpdateStoredCompletionEndpoint is the OpenAI API endpoint to modify the metadata of a stored chat completion https://platform.openai.com/docs/api-reference/chat/update
+// @Summary Replace the metadata of a chat completion stored with `store: true`.
+// @Param completion_id path string true "Completion ID"
+// @Param request body schema.StoredCompletionUpdateRequest true "query params"
+// @Success 200 {object} schema.StoredCompletion "Response"
+// @Router /v1/chat/completions/{completion_id} [post]
+func UpdateStoredCompletionEndpoint(completions *services.StoredCompletionsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(schema.StoredCompletionUpdateRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		completion, err := completions.Update(c.Params("completion_id"), request.Metadata)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return c.JSON(completion)
+	}
+}
+
+// DeleteStoredCompletionEndpoint is the OpenAI API endpoint to delete a stored chat completion https://platform.openai.com/docs/api-reference/chat/delete
+// @Summary Delete a chat completion stored with `store: true`.
+// @Param completion_id path string true "Completion ID"
+// @Success 200 {object} schema.DeleteAssistantResponse "Response"
+// @Router /v1/chat/completions/{completion_id} [delete]
+func DeleteStoredCompletionEndpoint(completions *services.StoredCompletionsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("completion_id")
+		if err := completions.Delete(id); err != nil {
+			return c.Status(fiber.StatusNotFound).SendString(err.Error())
+		}
+		return c.JSON(schema.DeleteAssistantResponse{
+			ID:      id,
+			Object:  "chat.completion.deleted",
+			Deleted: true,
+		})
+	}
+}
+
+// ExportStoredCompletionsEndpoint exports the stored chat completions as a fine-tuning dataset
+// @Summary Export the chat completions stored with `store: true` as a JSONL fine-tuning dataset in the OpenAI chat format.
+// @Param model query string false "Model"
+// @Param api_key query string false "ID of the API key: its name, or the prefix of its hash shown in the audit log"
+// @Param min_rating query number false "Minimum rating, from the `rating` metadata of the completions"
+// @Param since query string false "Oldest creation date: unix seconds, RFC3339 or YYYY-MM-DD"
+// @Param until query string false "Creation date the completions are older than: unix seconds, RFC3339 or YYYY-MM-DD"
+// @Param redact query string false "Comma separated redactors of the personal information: email, phone, ip, credit_card"
+// @Success 200 {string} binary "Dataset"
+// @Router /datasets/export [get]
+func ExportStoredCompletionsEndpoint(completions *services.StoredCompletionsService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		filter := services.DatasetFilter{
+			Model:    c.Query("model"),
+			APIKey:   c.Query("api_key"),
+			Metadata: metadataQuery(c),
+		}
+		var err error
+		if rating := c.Query("min_rating"); rating != "" {
+			if filter.MinRating, err = strconv.ParseFloat(rating, 64); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid min_rating %q", rating))
+			}
+		}
+		if filter.Since, err = services.ParseTimeBound(c.Query("since")); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if filter.Until, err = services.ParseTimeBound(c.Query("until")); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		redactors, err := services.Redactors(strings.Split(c.Query("redact"), ","))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		c.Set(fiber.HeaderContentType, "application/jsonl")
+		c.Attachment("dataset.jsonl")
+		// the stored traffic grows without limit, the examples are streamed as they are encoded
+		c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+			if _, err := completions.ExportDataset(w, filter, redactors...); err != nil {
+				log.Debug().Err(err).Msg("dataset export closed by the client")
+				return
+			}
+			w.Flush()
+		}))
+		return nil
+	}
+}
+
+// metadataQuery returns the metadata the stored completions are filtered with, passed as metadata[key]=value
+func metadataQuery(c *fiber.Ctx) map[string]string {
+	metadata := map[string]string{}
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		k := string(key)
+		if strings.HasPrefix(k, "metadata[") && strings.HasSuffix(k, "]") {
+			metadata[strings.TrimSuffix(strings.TrimPrefix(k, "metadata["), "]")] = string(value)
+		}
+	})
+	return metadata
+}
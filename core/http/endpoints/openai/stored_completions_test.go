@@ -0,0 +1,194 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func startUpStoredCompletionsApp(configsDir string) (*fiber.App, *services.StoredCompletionsService) {
+	completions := services.NewStoredCompletionsService(&config.ApplicationConfig{ConfigsDir: configsDir})
+
+	app := fiber.New()
+	app.Get("/chat/completions", ListStoredCompletionsEndpoint(completions))
+	app.Get("/chat/completions/:completion_id", GetStoredCompletionEndpoint(completions))
+	app.Get("/chat/completions/:completion_id/messages", GetStoredCompletionMessagesEndpoint(completions))
+	app.Post("/chat/completions/:completion_id", UpdateStoredCompletionEndpoint(completions))
+	app.Delete("/chat/completions/:completion_id", DeleteStoredCompletionEndpoint(completions))
+
+	return app, completions
+}
+
+func storeTestCompletion(t *testing.T, completions *services.StoredCompletionsService, id, model string, created int, metadata map[string]string) {
+	reply := "Hello!"
+	err := completions.Store(schema.StoredCompletion{
+		OpenAIResponse: schema.OpenAIResponse{
+			ID:      id,
+			Created: created,
+			Model:   model,
+			Object:  "chat.completion",
+			Choices: []schema.Choice{{FinishReason: "stop", Message: &schema.Message{Role: "assistant", Content: &reply}}},
+		},
+		Metadata: metadata,
+	}, []schema.Message{{Role: "user", Content: "Hi", StringContent: "Hi", StringImages: []string{"aGk="}}}, "")
+	assert.NoError(t, err)
+}
+
+func callStoredCompletions(t *testing.T, app *fiber.App, method, target, body string, out any) int {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode == fiber.StatusOK {
+		data, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(data, out))
+	}
+	return resp.StatusCode
+}
+
+func TestStoredCompletions(t *testing.T) {
+	configsDir := t.TempDir()
+	app, completions := startUpStoredCompletionsApp(configsDir)
+	storeTestCompletion(t, completions, "first", "model-a", 1, map[string]string{"team": "eval"})
+	storeTestCompletion(t, completions, "second", "model-b", 2, map[string]string{"team": "eval"})
+	storeTestCompletion(t, completions, "third", "model-a", 3, nil)
+
+	t.Run("ListStoredCompletionsEndpoint filters by model and metadata", func(t *testing.T) {
+		var list schema.StoredCompletionsList
+		assert.Equal(t, fiber.StatusOK, callStoredCompletions(t, app, "GET", "/chat/completions?model=model-a", "", &list))
+		assert.Equal(t, []string{"first", "third"}, storedCompletionIDs(list))
+
+		list = schema.StoredCompletionsList{}
+		assert.Equal(t, fiber.StatusOK, callStoredCompletions(t, app, "GET", "/chat/completions?metadata[team]=eval&order=desc", "", &list))
+		assert.Equal(t, []string{"second", "first"}, storedCompletionIDs(list))
+	})
+
+	t.Run("ListStoredCompletionsEndpoint paginates", func(t *testing.T) {
+		var list schema.StoredCompletionsList
+		assert.Equal(t, fiber.StatusOK, callStoredCompletions(t, app, "GET", "/chat/completions?limit=1&after=first", "", &list))
+		assert.Equal(t, []string{"second"}, storedCompletionIDs(list))
+		assert.True(t, list.HasMore)
+		assert.Equal(t, "second", list.LastID)
+	})
+
+	t.Run("GetStoredCompletionMessagesEndpoint returns the request messages", func(t *testing.T) {
+		var messages schema.StoredCompletionMessages
+		assert.Equal(t, fiber.StatusOK, callStoredCompletions(t, app, "GET", "/chat/completions/first/messages", "", &messages))
+		assert.Len(t, messages.Data, 1)
+		assert.Equal(t, "Hi", messages.Data[0].Content)
+		assert.Empty(t, messages.Data[0].StringImages)
+	})
+
+	t.Run("UpdateStoredCompletionEndpoint replaces the metadata", func(t *testing.T) {
+		var completion schema.StoredCompletion
+		assert.Equal(t, fiber.StatusOK, callStoredCompletions(t, app, "POST", "/chat/completions/third", `{"metadata": {"team": "distill"}}`, &completion))
+		assert.Equal(t, map[string]string{"team": "distill"}, completion.Metadata)
+	})
+
+	t.Run("DeleteStoredCompletionEndpoint removes the completion", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusOK, callStoredCompletions(t, app, "DELETE", "/chat/completions/second", "", nil))
+		assert.Equal(t, fiber.StatusNotFound, callStoredCompletions(t, app, "GET", "/chat/completions/second", "", nil))
+	})
+
+	t.Run("stored completions are reloaded", func(t *testing.T) {
+		reloaded := services.NewStoredCompletionsService(&config.ApplicationConfig{ConfigsDir: configsDir})
+		list, _ := reloaded.List(services.StoredCompletionsFilter{})
+		assert.Len(t, list, 2)
+	})
+}
+
+func storedCompletionIDs(list schema.StoredCompletionsList) []string {
+	ids := []string{}
+	for _, c := range list.Data {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestExportStoredCompletions(t *testing.T) {
+	completions := services.NewStoredCompletionsService(&config.ApplicationConfig{ConfigsDir: t.TempDir()})
+	app := fiber.New()
+	app.Get("/datasets/export", ExportStoredCompletionsEndpoint(completions))
+
+	store := func(id, model string, created int, rating, apiKey, prompt string) {
+		reply := "Write to support@example.com"
+		err := completions.Store(schema.StoredCompletion{
+			OpenAIResponse: schema.OpenAIResponse{
+				ID:      id,
+				Created: created,
+				Model:   model,
+				Choices: []schema.Choice{{FinishReason: "stop", Message: &schema.Message{Role: "assistant", Content: &reply}}},
+			},
+			Metadata: map[string]string{"rating": rating},
+		}, []schema.Message{{Role: "user", Content: prompt}}, apiKey)
+		assert.NoError(t, err)
+	}
+	store("first", "model-a", 1717200000, "5", "alice", "I'm jane@doe.org, call me at +1 555 123 4567")
+	store("second", "model-a", 1719800000, "2", "alice", "Hi")
+	store("third", "model-b", 1719800000, "5", "bob", "Hi")
+
+	export := func(query string) (int, []services.DatasetExample) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/datasets/export"+query, nil))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		examples := []services.DatasetExample{}
+		data, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		if resp.StatusCode == fiber.StatusOK {
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				if line == "" {
+					continue
+				}
+				var example services.DatasetExample
+				assert.NoError(t, json.Unmarshal([]byte(line), &example))
+				examples = append(examples, example)
+			}
+		}
+		return resp.StatusCode, examples
+	}
+
+	t.Run("exports the messages followed by the answer", func(t *testing.T) {
+		status, examples := export("")
+		assert.Equal(t, fiber.StatusOK, status)
+		assert.Len(t, examples, 3)
+		assert.Equal(t, "user", examples[0].Messages[0].Role)
+		assert.Equal(t, "assistant", examples[0].Messages[1].Role)
+		assert.Equal(t, "Write to support@example.com", examples[0].Messages[1].Content)
+	})
+
+	t.Run("filters by model, API key, rating and date", func(t *testing.T) {
+		_, examples := export("?model=model-a&api_key=alice&min_rating=4")
+		assert.Len(t, examples, 1)
+
+		_, examples = export("?api_key=bob")
+		assert.Len(t, examples, 1)
+
+		_, examples = export("?since=2024-07-01&until=2024-08-01")
+		assert.Len(t, examples, 2)
+	})
+
+	t.Run("redacts the personal information", func(t *testing.T) {
+		_, examples := export("?model=model-a&min_rating=4&redact=email,phone")
+		assert.Len(t, examples, 1)
+		assert.Equal(t, "I'm [EMAIL], call me at [PHONE]", examples[0].Messages[0].Content)
+		assert.Equal(t, "Write to [EMAIL]", examples[0].Messages[1].Content)
+	})
+
+	t.Run("rejects the invalid filters", func(t *testing.T) {
+		status, _ := export("?redact=names")
+		assert.Equal(t, fiber.StatusBadRequest, status)
+		status, _ = export("?since=yesterday")
+		assert.Equal(t, fiber.StatusBadRequest, status)
+	})
+}
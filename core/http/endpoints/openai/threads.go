@@ -0,0 +1,891 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// The statuses of the runs, https://platform.openai.com/docs/assistants/deep-dive#runs-and-run-steps
+const (
+	RunQueued     = "queued"
+	RunInProgress = "in_progress"
+	RunCancelling = "cancelling"
+	RunCancelled  = "cancelled"
+	RunFailed     = "failed"
+	RunCompleted  = "completed"
+)
+
+const (
+	// fileSearchResults is the number of chunks of the vector stores given to the model by file_search
+	fileSearchResults = 5
+	// fileSearchPrompt introduces the chunks found by file_search in the instructions of the run
+	fileSearchPrompt = "Answer using the following excerpts of the files when they are relevant, and cite the excerpts you use with their number in brackets, e.g. [1]."
+)
+
+// Thread is a conversation between a user and the assistants, https://platform.openai.com/docs/api-reference/threads/object
+type Thread struct {
+	ID            string            `json:"id"`
+	Object        string            `json:"object"`
+	CreatedAt     int64             `json:"created_at"`
+	Metadata      map[string]string `json:"metadata"`
+	ToolResources *ToolResources    `json:"tool_resources,omitempty"` // The vector stores searched in addition to the ones of the assistant
+}
+
+type FileCitation struct {
+	FileID string `json:"file_id"`
+	Quote  string `json:"quote,omitempty"`
+}
+
+// MessageAnnotation cites the file of a chunk found by file_search in the text of a message
+type MessageAnnotation struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text"`
+	StartIndex   int           `json:"start_index"`
+	EndIndex     int           `json:"end_index"`
+	FileCitation *FileCitation `json:"file_citation,omitempty"`
+}
+
+type MessageText struct {
+	Value       string              `json:"value"`
+	Annotations []MessageAnnotation `json:"annotations"`
+}
+
+type MessageContent struct {
+	Type string      `json:"type"`
+	Text MessageText `json:"text"`
+}
+
+// ThreadMessage is a message of a thread, https://platform.openai.com/docs/api-reference/messages/object
+type ThreadMessage struct {
+	ID          string            `json:"id"`
+	Object      string            `json:"object"`
+	CreatedAt   int64             `json:"created_at"`
+	ThreadID    string            `json:"thread_id"`
+	Status      string            `json:"status"`
+	CompletedAt int64             `json:"completed_at,omitempty"`
+	Role        string            `json:"role"`
+	Content     []MessageContent  `json:"content"`
+	AssistantID string            `json:"assistant_id,omitempty"`
+	RunID       string            `json:"run_id,omitempty"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// Text returns the text of the message
+func (m ThreadMessage) Text() string {
+	texts := []string{}
+	for _, c := range m.Content {
+		texts = append(texts, c.Text.Value)
+	}
+	return strings.Join(texts, "\n")
+}
+
+type RunError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Run is an assistant answering the messages of a thread, https://platform.openai.com/docs/api-reference/runs/object
+type Run struct {
+	ID           string              `json:"id"`
+	Object       string              `json:"object"`
+	CreatedAt    int64               `json:"created_at"`
+	ThreadID     string              `json:"thread_id"`
+	AssistantID  string              `json:"assistant_id"`
+	Status       string              `json:"status"`
+	StartedAt    int64               `json:"started_at,omitempty"`
+	CancelledAt  int64               `json:"cancelled_at,omitempty"`
+	FailedAt     int64               `json:"failed_at,omitempty"`
+	CompletedAt  int64               `json:"completed_at,omitempty"`
+	LastError    *RunError           `json:"last_error,omitempty"`
+	Model        string              `json:"model"`
+	Instructions string              `json:"instructions"`
+	Tools        []Tool              `json:"tools"`
+	Usage        *schema.OpenAIUsage `json:"usage,omitempty"`
+	Metadata     map[string]string   `json:"metadata"`
+}
+
+// done returns whether the run reached a final status
+func (r Run) done() bool {
+	return r.Status == RunCancelled || r.Status == RunFailed || r.Status == RunCompleted
+}
+
+type ThreadMessageRequest struct {
+	Role     string            `json:"role"`
+	Content  string            `json:"content"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type ThreadRequest struct {
+	Messages      []ThreadMessageRequest `json:"messages,omitempty"`
+	Metadata      map[string]string      `json:"metadata,omitempty"`
+	ToolResources *ToolResources         `json:"tool_resources,omitempty"`
+}
+
+type RunRequest struct {
+	AssistantID            string                 `json:"assistant_id"`
+	Model                  string                 `json:"model,omitempty"`        // Overrides the model of the assistant
+	Instructions           string                 `json:"instructions,omitempty"` // Overrides the instructions of the assistant
+	AdditionalInstructions string                 `json:"additional_instructions,omitempty"`
+	AdditionalMessages     []ThreadMessageRequest `json:"additional_messages,omitempty"`
+	Tools                  []Tool                 `json:"tools,omitempty"` // Overrides the tools of the assistant
+	Stream                 bool                   `json:"stream,omitempty"`
+	Metadata               map[string]string      `json:"metadata,omitempty"`
+}
+
+var (
+	Threads        = []Thread{}
+	ThreadMessages = []ThreadMessage{}
+	Runs           = []Run{}
+
+	ThreadsConfigFile        = "threads.json"
+	ThreadMessagesConfigFile = "threadMessages.json"
+	RunsConfigFile           = "runs.json"
+
+	threadsMu  sync.Mutex
+	runCancels = map[string]context.CancelFunc{}
+)
+
+// LoadThreads loads the threads, their messages and their runs. The runs not done when LocalAI stopped are failed
+func LoadThreads(appConfig *config.ApplicationConfig) {
+	threadsMu.Lock()
+	defer threadsMu.Unlock()
+	utils.LoadConfig(appConfig.ConfigsDir, ThreadsConfigFile, &Threads)
+	utils.LoadConfig(appConfig.ConfigsDir, ThreadMessagesConfigFile, &ThreadMessages)
+	utils.LoadConfig(appConfig.ConfigsDir, RunsConfigFile, &Runs)
+	for i, r := range Runs {
+		if !r.done() {
+			Runs[i].Status = RunFailed
+			Runs[i].FailedAt = time.Now().Unix()
+			Runs[i].LastError = &RunError{Code: "server_error", Message: "LocalAI was stopped while the run was in progress"}
+		}
+	}
+	saveThreads(appConfig)
+}
+
+// saveThreads saves the threads, their messages and their runs. The caller holds threadsMu
+func saveThreads(appConfig *config.ApplicationConfig) {
+	utils.SaveConfig(appConfig.ConfigsDir, ThreadsConfigFile, Threads)
+	utils.SaveConfig(appConfig.ConfigsDir, ThreadMessagesConfigFile, ThreadMessages)
+	utils.SaveConfig(appConfig.ConfigsDir, RunsConfigFile, Runs)
+}
+
+func findThread(id string) (int, bool) {
+	for i, t := range Threads {
+		if t.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func findAssistant(id string) (Assistant, bool) {
+	for _, a := range Assistants {
+		if a.ID == id {
+			return a, true
+		}
+	}
+	return Assistant{}, false
+}
+
+// newThreadMessage returns a text message of the thread, sent by the user or added as the answer of an assistant
+func newThreadMessage(threadID string, request ThreadMessageRequest) (ThreadMessage, error) {
+	if request.Role != "user" && request.Role != "assistant" {
+		return ThreadMessage{}, fmt.Errorf("invalid role %q, expected user or assistant", request.Role)
+	}
+	if request.Metadata == nil {
+		request.Metadata = map[string]string{}
+	}
+	now := time.Now().Unix()
+	return ThreadMessage{
+		ID:          "msg_" + uuid.New().String(),
+		Object:      "thread.message",
+		CreatedAt:   now,
+		ThreadID:    threadID,
+		Status:      "completed",
+		CompletedAt: now,
+		Role:        request.Role,
+		Content:     []MessageContent{{Type: "text", Text: MessageText{Value: request.Content, Annotations: []MessageAnnotation{}}}},
+		Metadata:    request.Metadata,
+	}, nil
+}
+
+// listPage returns the page of the items listed by the limit, order, after and before query parameters, the items
+// being in the order they were created
+func listPage[T any](c *fiber.Ctx, items []T, id func(T) string) (fiber.Map, error) {
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "limit must be between 1 and 100")
+	}
+	items = slices.Clone(items)
+	if c.Query("order", "desc") == "desc" {
+		slices.Reverse(items)
+	}
+	if after := c.Query("after"); after != "" {
+		if i := slices.IndexFunc(items, func(item T) bool { return id(item) == after }); i >= 0 {
+			items = items[i+1:]
+		}
+	}
+	if before := c.Query("before"); before != "" {
+		if i := slices.IndexFunc(items, func(item T) bool { return id(item) == before }); i >= 0 {
+			items = items[:i]
+		}
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	page := fiber.Map{"object": "list", "data": items, "has_more": hasMore, "first_id": "", "last_id": ""}
+	if len(items) > 0 {
+		page["first_id"], page["last_id"] = id(items[0]), id(items[len(items)-1])
+	}
+	return page, nil
+}
+
+// CreateThreadEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/threads/createThread
+// @Summary Create a thread, with its first messages.
+// @Param request body ThreadRequest true "query params"
+// @Success 200 {object} Thread "Response"
+// @Router /v1/threads [post]
+func CreateThreadEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ThreadRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+		if request.Metadata == nil {
+			request.Metadata = map[string]string{}
+		}
+
+		thread := Thread{
+			ID:            "thread_" + uuid.New().String(),
+			Object:        "thread",
+			CreatedAt:     time.Now().Unix(),
+			Metadata:      request.Metadata,
+			ToolResources: request.ToolResources,
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		messages := []ThreadMessage{}
+		for _, m := range request.Messages {
+			message, err := newThreadMessage(thread.ID, m)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			messages = append(messages, message)
+		}
+		Threads = append(Threads, thread)
+		ThreadMessages = append(ThreadMessages, messages...)
+		saveThreads(appConfig)
+		return c.JSON(thread)
+	}
+}
+
+// GetThreadEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/threads/getThread
+// @Summary Get a thread.
+// @Success 200 {object} Thread "Response"
+// @Router /v1/threads/{thread_id} [get]
+func GetThreadEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		i, found := findThread(c.Params("thread_id"))
+		if !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find thread with id: %s", c.Params("thread_id")))
+		}
+		return c.JSON(Threads[i])
+	}
+}
+
+// ModifyThreadEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/threads/modifyThread
+// @Summary Modify the metadata and the tool resources of a thread.
+// @Param request body ThreadRequest true "query params"
+// @Success 200 {object} Thread "Response"
+// @Router /v1/threads/{thread_id} [post]
+func ModifyThreadEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ThreadRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		i, found := findThread(c.Params("thread_id"))
+		if !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find thread with id: %s", c.Params("thread_id")))
+		}
+		if request.Metadata != nil {
+			Threads[i].Metadata = request.Metadata
+		}
+		if request.ToolResources != nil {
+			Threads[i].ToolResources = request.ToolResources
+		}
+		saveThreads(appConfig)
+		return c.JSON(Threads[i])
+	}
+}
+
+// DeleteThreadEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/threads/deleteThread
+// @Summary Delete a thread, with its messages and its runs.
+// @Success 200 {object} schema.DeleteAssistantResponse "Response"
+// @Router /v1/threads/{thread_id} [delete]
+func DeleteThreadEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("thread_id")
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		i, found := findThread(id)
+		if !found {
+			return c.Status(fiber.StatusNotFound).JSON(schema.DeleteAssistantResponse{ID: id, Object: "thread.deleted", Deleted: false})
+		}
+		for _, r := range Runs {
+			if r.ThreadID == id && !r.done() {
+				return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Thread %s has the run %s in progress", id, r.ID))
+			}
+		}
+
+		Threads = slices.Delete(Threads, i, i+1)
+		ThreadMessages = slices.DeleteFunc(ThreadMessages, func(m ThreadMessage) bool { return m.ThreadID == id })
+		Runs = slices.DeleteFunc(Runs, func(r Run) bool { return r.ThreadID == id })
+		saveThreads(appConfig)
+		return c.JSON(schema.DeleteAssistantResponse{ID: id, Object: "thread.deleted", Deleted: true})
+	}
+}
+
+// CreateMessageEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/messages/createMessage
+// @Summary Add a message to a thread.
+// @Param request body ThreadMessageRequest true "query params"
+// @Success 200 {object} ThreadMessage "Response"
+// @Router /v1/threads/{thread_id}/messages [post]
+func CreateMessageEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ThreadMessageRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		if _, found := findThread(c.Params("thread_id")); !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find thread with id: %s", c.Params("thread_id")))
+		}
+		message, err := newThreadMessage(strings.Clone(c.Params("thread_id")), *request)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+		}
+		ThreadMessages = append(ThreadMessages, message)
+		saveThreads(appConfig)
+		return c.JSON(message)
+	}
+}
+
+// ListMessagesEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/messages/listMessages
+// @Summary List the messages of a thread.
+// @Param limit query int false "Limit the number of messages returned"
+// @Param order query string false "Order of messages returned"
+// @Param after query string false "Return messages after the given ID"
+// @Param before query string false "Return messages before the given ID"
+// @Param run_id query string false "Return the messages of the given run"
+// @Success 200 {object} []ThreadMessage "Response"
+// @Router /v1/threads/{thread_id}/messages [get]
+func ListMessagesEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id, runID := c.Params("thread_id"), c.Query("run_id")
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		if _, found := findThread(id); !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find thread with id: %s", id))
+		}
+		messages := []ThreadMessage{}
+		for _, m := range ThreadMessages {
+			if m.ThreadID == id && (runID == "" || m.RunID == runID) {
+				messages = append(messages, m)
+			}
+		}
+		page, err := listPage(c, messages, func(m ThreadMessage) string { return m.ID })
+		if err != nil {
+			return err
+		}
+		return c.JSON(page)
+	}
+}
+
+// GetMessageEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/messages/getMessage
+// @Summary Get a message of a thread.
+// @Success 200 {object} ThreadMessage "Response"
+// @Router /v1/threads/{thread_id}/messages/{message_id} [get]
+func GetMessageEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		for _, m := range ThreadMessages {
+			if m.ThreadID == c.Params("thread_id") && m.ID == c.Params("message_id") {
+				return c.JSON(m)
+			}
+		}
+		return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find message with id: %s", c.Params("message_id")))
+	}
+}
+
+// CreateRunEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/runs/createRun
+// @Summary Run an assistant on a thread, streaming the events of the run with stream.
+// @Param request body RunRequest true "query params"
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/{thread_id}/runs [post]
+func CreateRunEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(RunRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+		assistant, found := findAssistant(request.AssistantID)
+		if !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find assistant with id: %s", request.AssistantID))
+		}
+		if request.Model == "" {
+			request.Model = assistant.Model
+		} else if !modelExists(cl, ml, request.Model) {
+			return c.Status(fiber.StatusBadRequest).SendString("Model " + request.Model + " not found")
+		}
+		if request.Instructions == "" {
+			request.Instructions = assistant.Instructions
+		}
+		if request.AdditionalInstructions != "" {
+			request.Instructions = strings.TrimSpace(request.Instructions + "\n\n" + request.AdditionalInstructions)
+		}
+		if request.Tools == nil {
+			request.Tools = assistant.Tools
+		}
+		if request.Metadata == nil {
+			request.Metadata = map[string]string{}
+		}
+
+		threadID := strings.Clone(c.Params("thread_id")) // the run outlives the request
+		run := Run{
+			ID:           "run_" + uuid.New().String(),
+			Object:       "thread.run",
+			CreatedAt:    time.Now().Unix(),
+			ThreadID:     threadID,
+			AssistantID:  assistant.ID,
+			Status:       RunQueued,
+			Model:        request.Model,
+			Instructions: request.Instructions,
+			Tools:        request.Tools,
+			Metadata:     request.Metadata,
+		}
+
+		threadsMu.Lock()
+		if _, found := findThread(threadID); !found {
+			threadsMu.Unlock()
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find thread with id: %s", threadID))
+		}
+		for _, r := range Runs {
+			if r.ThreadID == threadID && !r.done() {
+				threadsMu.Unlock()
+				return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Thread %s already has the run %s in progress", threadID, r.ID))
+			}
+		}
+		for _, m := range request.AdditionalMessages {
+			message, err := newThreadMessage(threadID, m)
+			if err != nil {
+				threadsMu.Unlock()
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			ThreadMessages = append(ThreadMessages, message)
+		}
+		ctx, cancel := context.WithCancel(appConfig.Context)
+		Runs = append(Runs, run)
+		runCancels[run.ID] = cancel
+		saveThreads(appConfig)
+		threadsMu.Unlock()
+
+		// the run answers like a chat request of the client, which is queued as any inference
+		r := &threadRunner{
+			cl:        cl,
+			ml:        ml,
+			sl:        sl,
+			appConfig: appConfig,
+			client:    newInternalClient(c, ""),
+			assistant: assistant,
+			emit:      func(string, any) {},
+		}
+		if !request.Stream {
+			go r.run(ctx, run.ID)
+			return c.JSON(run)
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+			// the run goes on when the client disconnects, without its events
+			closed := false
+			r.emit = func(event string, data any) {
+				if closed {
+					return
+				}
+				payload, err := json.Marshal(data)
+				if err != nil {
+					log.Error().Err(err).Str("event", event).Msg("unable to encode the event of the run")
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+				if err := w.Flush(); err != nil {
+					log.Debug().Err(err).Str("run", run.ID).Msg("run stream closed by the client")
+					closed = true
+				}
+			}
+			r.emit("thread.run.created", run)
+			r.emit("thread.run.queued", run)
+			r.run(ctx, run.ID)
+			if !closed {
+				fmt.Fprint(w, "event: done\ndata: [DONE]\n\n")
+				w.Flush()
+			}
+		}))
+		return nil
+	}
+}
+
+// ListRunsEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/runs/listRuns
+// @Summary List the runs of a thread.
+// @Param limit query int false "Limit the number of runs returned"
+// @Param order query string false "Order of runs returned"
+// @Param after query string false "Return runs after the given ID"
+// @Param before query string false "Return runs before the given ID"
+// @Success 200 {object} []Run "Response"
+// @Router /v1/threads/{thread_id}/runs [get]
+func ListRunsEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("thread_id")
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		if _, found := findThread(id); !found {
+			return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find thread with id: %s", id))
+		}
+		runs := []Run{}
+		for _, r := range Runs {
+			if r.ThreadID == id {
+				runs = append(runs, r)
+			}
+		}
+		page, err := listPage(c, runs, func(r Run) string { return r.ID })
+		if err != nil {
+			return err
+		}
+		return c.JSON(page)
+	}
+}
+
+// GetRunEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/runs/getRun
+// @Summary Get a run, polled until its status is completed, failed or cancelled.
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/{thread_id}/runs/{run_id} [get]
+func GetRunEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		for _, r := range Runs {
+			if r.ThreadID == c.Params("thread_id") && r.ID == c.Params("run_id") {
+				return c.JSON(r)
+			}
+		}
+		return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find run with id: %s", c.Params("run_id")))
+	}
+}
+
+// CancelRunEndpoint is the OpenAI Assistant API endpoint https://platform.openai.com/docs/api-reference/runs/cancelRun
+// @Summary Cancel a run in progress.
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/{thread_id}/runs/{run_id}/cancel [post]
+func CancelRunEndpoint(appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+		for i, r := range Runs {
+			if r.ThreadID != c.Params("thread_id") || r.ID != c.Params("run_id") {
+				continue
+			}
+			if r.done() {
+				return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Run %s is %s and can't be cancelled", r.ID, r.Status))
+			}
+			Runs[i].Status = RunCancelling
+			saveThreads(appConfig)
+			if cancel, ok := runCancels[r.ID]; ok {
+				cancel()
+			}
+			return c.JSON(Runs[i])
+		}
+		return c.Status(fiber.StatusNotFound).SendString(fmt.Sprintf("Unable to find run with id: %s", c.Params("run_id")))
+	}
+}
+
+// threadRunner runs an assistant on a thread: it searches the vector stores with file_search, then streams the
+// answer of the model in a new message of the thread
+type threadRunner struct {
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+	sl        *model.ModelLoader
+	appConfig *config.ApplicationConfig
+	client    *internalClient
+	assistant Assistant
+
+	// emit sends the events of the run to the client streaming them
+	emit func(event string, data any)
+}
+
+// update changes the run with fn, saves it and sends the event of its new status
+func (r *threadRunner) update(id string, fn func(run *Run)) Run {
+	threadsMu.Lock()
+	defer threadsMu.Unlock()
+	for i := range Runs {
+		if Runs[i].ID == id {
+			fn(&Runs[i])
+			saveThreads(r.appConfig)
+			r.emit("thread.run."+Runs[i].Status, Runs[i])
+			return Runs[i]
+		}
+	}
+	return Run{}
+}
+
+// updateMessage changes the message with fn, saves it and sends event
+func (r *threadRunner) updateMessage(id, event string, fn func(m *ThreadMessage)) {
+	threadsMu.Lock()
+	defer threadsMu.Unlock()
+	for i := range ThreadMessages {
+		if ThreadMessages[i].ID == id {
+			fn(&ThreadMessages[i])
+			saveThreads(r.appConfig)
+			r.emit(event, ThreadMessages[i])
+			return
+		}
+	}
+}
+
+func (r *threadRunner) run(ctx context.Context, id string) {
+	defer func() {
+		threadsMu.Lock()
+		if cancel, ok := runCancels[id]; ok {
+			cancel()
+			delete(runCancels, id)
+		}
+		threadsMu.Unlock()
+	}()
+
+	run := r.update(id, func(run *Run) {
+		if run.Status == RunQueued {
+			run.Status = RunInProgress
+		}
+		run.StartedAt = time.Now().Unix()
+	})
+
+	threadsMu.Lock()
+	var thread Thread
+	if i, found := findThread(run.ThreadID); found {
+		thread = Threads[i]
+	}
+	history := []ThreadMessage{}
+	for _, m := range ThreadMessages {
+		if m.ThreadID == run.ThreadID {
+			history = append(history, m)
+		}
+	}
+	message := ThreadMessage{
+		ID:          "msg_" + uuid.New().String(),
+		Object:      "thread.message",
+		CreatedAt:   time.Now().Unix(),
+		ThreadID:    run.ThreadID,
+		Status:      "in_progress",
+		Role:        "assistant",
+		Content:     []MessageContent{},
+		AssistantID: run.AssistantID,
+		RunID:       run.ID,
+		Metadata:    map[string]string{},
+	}
+	ThreadMessages = append(ThreadMessages, message)
+	saveThreads(r.appConfig)
+	threadsMu.Unlock()
+	r.emit("thread.message.created", message)
+	r.emit("thread.message.in_progress", message)
+
+	text, usage, results, err := r.answer(ctx, run, thread, history, message.ID)
+	annotations := fileCitations(text, results)
+	r.updateMessage(message.ID, "thread.message.completed", func(m *ThreadMessage) {
+		m.Content = []MessageContent{{Type: "text", Text: MessageText{Value: text, Annotations: annotations}}}
+		m.Status = "completed"
+		if err != nil {
+			m.Status = "incomplete"
+		}
+		m.CompletedAt = time.Now().Unix()
+	})
+
+	r.update(id, func(run *Run) {
+		now := time.Now().Unix()
+		run.Usage = usage
+		switch {
+		case run.Status == RunCancelling || ctx.Err() != nil:
+			run.Status, run.CancelledAt = RunCancelled, now
+		case err != nil:
+			run.Status, run.FailedAt = RunFailed, now
+			run.LastError = &RunError{Code: "server_error", Message: err.Error()}
+		default:
+			run.Status, run.CompletedAt = RunCompleted, now
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Warn().Err(err).Str("run", id).Msg("run failed")
+	}
+}
+
+// answer returns the answer of the model to the messages, with the chunks found by file_search
+func (r *threadRunner) answer(ctx context.Context, run Run, thread Thread, history []ThreadMessage, messageID string) (string, *schema.OpenAIUsage, []backend.FileSearchResult, error) {
+	results, err := r.fileSearch(ctx, run, thread, history)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("file_search failed: %w", err)
+	}
+
+	instructions := run.Instructions
+	if len(results) > 0 {
+		excerpts := []string{fileSearchPrompt}
+		for i, result := range results {
+			excerpts = append(excerpts, fmt.Sprintf("[%d] %s", i+1, result.Content))
+		}
+		instructions = strings.TrimSpace(instructions + "\n\n" + strings.Join(excerpts, "\n\n"))
+	}
+	messages := []schema.Message{}
+	if instructions != "" {
+		messages = append(messages, schema.Message{Role: "system", Content: instructions})
+	}
+	for _, m := range history {
+		messages = append(messages, schema.Message{Role: m.Role, Content: m.Text()})
+	}
+	body, err := json.Marshal(map[string]any{"model": run.Model, "messages": messages, "stream": true})
+	if err != nil {
+		return "", nil, results, err
+	}
+
+	response := r.client.post("/v1/chat/completions", body)
+	if !response.Response.IsBodyStream() {
+		return "", nil, results, fmt.Errorf("the chat completion failed with status %d: %s", response.Response.StatusCode(), response.Response.Body())
+	}
+	defer response.Response.CloseBodyStream()
+
+	text := strings.Builder{}
+	usage := &schema.OpenAIUsage{}
+	reader := bufio.NewReader(response.Response.BodyStream())
+	for ctx.Err() == nil {
+		line, readErr := reader.ReadBytes('\n')
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+		if ok && !bytes.Equal(data, []byte("[DONE]")) {
+			chunk := schema.OpenAIResponse{}
+			if err := json.Unmarshal(data, &chunk); err != nil {
+				return text.String(), usage, results, fmt.Errorf("invalid chunk of the chat completion: %w", err)
+			}
+			if chunk.Usage.TotalTokens > 0 {
+				*usage = chunk.Usage
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+				if delta, _ := chunk.Choices[0].Delta.Content.(string); delta != "" {
+					text.WriteString(delta)
+					r.emit("thread.message.delta", fiber.Map{
+						"id":     messageID,
+						"object": "thread.message.delta",
+						"delta": fiber.Map{"content": []fiber.Map{
+							{"index": 0, "type": "text", "text": fiber.Map{"value": delta}},
+						}},
+					})
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	return text.String(), usage, results, ctx.Err()
+}
+
+// fileSearch returns the chunks of the vector stores of the assistant and of the thread the most similar to the last
+// message of the user, when the run has the file_search tool
+func (r *threadRunner) fileSearch(ctx context.Context, run Run, thread Thread, history []ThreadMessage) ([]backend.FileSearchResult, error) {
+	enabled := slices.ContainsFunc(run.Tools, func(t Tool) bool { return t.Type == FileSearch || t.Type == Retrieval })
+	if !enabled {
+		return nil, nil
+	}
+	stores := []string{}
+	for _, resources := range []*ToolResources{r.assistant.ToolResources, thread.ToolResources} {
+		if resources != nil && resources.FileSearch != nil {
+			stores = append(stores, resources.FileSearch.VectorStoreIDs...)
+		}
+	}
+	query := ""
+	for _, m := range history {
+		if m.Role == "user" {
+			query = m.Text()
+		}
+	}
+	if len(stores) == 0 || query == "" {
+		return nil, nil
+	}
+
+	slices.Sort(stores)
+	results := []backend.FileSearchResult{}
+	for _, store := range slices.Compact(stores) {
+		found, err := backend.FileSearch(ctx, r.sl, r.ml, r.cl, r.appConfig, store, query, fileSearchResults)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, found...)
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > fileSearchResults {
+		results = results[:fileSearchResults]
+	}
+	return results, nil
+}
+
+// citation matches the numbers of the excerpts cited by the model, e.g. [2]
+var citation = regexp.MustCompile(`\[(\d+)\]`)
+
+// fileCitations returns the annotations of the excerpts of results cited in text, their indexes are in characters
+func fileCitations(text string, results []backend.FileSearchResult) []MessageAnnotation {
+	annotations := []MessageAnnotation{}
+	for _, m := range citation.FindAllStringSubmatchIndex(text, -1) {
+		n, err := strconv.Atoi(text[m[2]:m[3]])
+		if err != nil || n < 1 || n > len(results) {
+			continue
+		}
+		start := utf8.RuneCountInString(text[:m[0]])
+		annotations = append(annotations, MessageAnnotation{
+			Type:         "file_citation",
+			Text:         text[m[0]:m[1]],
+			StartIndex:   start,
+			EndIndex:     start + utf8.RuneCountInString(text[m[0]:m[1]]),
+			FileCitation: &FileCitation{FileID: results[n-1].FileID},
+		})
+	}
+	return annotations
+}
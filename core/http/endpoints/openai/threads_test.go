@@ -0,0 +1,157 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startUpThreadsApp(t *testing.T) *fiber.App {
+	Assistants = []Assistant{{ID: "asst_1", Object: "assistant", Model: "phi-2", Instructions: "Answer in one word."}}
+	Threads, ThreadMessages, Runs = []Thread{}, []ThreadMessage{}, []Run{}
+	t.Cleanup(func() { Assistants = []Assistant{} })
+
+	cl := &config.BackendConfigLoader{}
+	ml := model.NewModelLoader("")
+	appConfig := &config.ApplicationConfig{Context: context.Background(), ConfigsDir: t.TempDir()}
+
+	app := fiber.New()
+	app.Post("/v1/threads", CreateThreadEndpoint(appConfig))
+	app.Get("/v1/threads/:thread_id", GetThreadEndpoint(appConfig))
+	app.Delete("/v1/threads/:thread_id", DeleteThreadEndpoint(appConfig))
+	app.Post("/v1/threads/:thread_id/messages", CreateMessageEndpoint(appConfig))
+	app.Get("/v1/threads/:thread_id/messages", ListMessagesEndpoint(appConfig))
+	app.Post("/v1/threads/:thread_id/runs", CreateRunEndpoint(cl, ml, ml, appConfig))
+	app.Get("/v1/threads/:thread_id/runs/:run_id", GetRunEndpoint(appConfig))
+	// a fake chat completion, streaming the answer in two chunks after checking the messages of the thread
+	app.Post("/v1/chat/completions", func(c *fiber.Ctx) error {
+		request := schema.OpenAIRequest{}
+		if err := c.BodyParser(&request); err != nil {
+			return err
+		}
+		assert.Equal(t, "phi-2", request.Model)
+		assert.True(t, request.Stream)
+		require.Len(t, request.Messages, 2)
+		assert.Equal(t, "system", request.Messages[0].Role)
+		assert.Equal(t, "Answer in one word.", request.Messages[0].Content)
+		assert.Equal(t, "user", request.Messages[1].Role)
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			for _, delta := range []string{"Par", "is"} {
+				chunk, _ := json.Marshal(schema.OpenAIResponse{Choices: []schema.Choice{{Delta: &schema.Message{Content: delta}}}})
+				fmt.Fprintf(w, "data: %s\n\n", chunk)
+				w.Flush()
+			}
+			chunk, _ := json.Marshal(schema.OpenAIResponse{Usage: schema.OpenAIUsage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}})
+			fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", chunk)
+			w.Flush()
+		})
+		return nil
+	})
+	return app
+}
+
+func postJSON(t *testing.T, app *fiber.App, path, body string, out any) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	if out != nil {
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp
+}
+
+func TestThreadRun(t *testing.T) {
+	app := startUpThreadsApp(t)
+
+	thread := Thread{}
+	postJSON(t, app, "/v1/threads", `{"messages": [{"role": "user", "content": "The capital of France?"}]}`, &thread)
+	run := Run{}
+	postJSON(t, app, "/v1/threads/"+thread.ID+"/runs", `{"assistant_id": "asst_1"}`, &run)
+	assert.Equal(t, RunQueued, run.Status)
+
+	assert.Eventually(t, func() bool {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/v1/threads/"+thread.ID+"/runs/"+run.ID, nil))
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+		return run.Status == RunCompleted
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NotNil(t, run.Usage)
+	assert.Equal(t, 14, run.Usage.TotalTokens)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/v1/threads/"+thread.ID+"/messages?order=asc", nil))
+	require.NoError(t, err)
+	page := struct {
+		Data    []ThreadMessage `json:"data"`
+		HasMore bool            `json:"has_more"`
+	}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page.Data, 2)
+	assert.Equal(t, "The capital of France?", page.Data[0].Text())
+	assert.Equal(t, "assistant", page.Data[1].Role)
+	assert.Equal(t, "Paris", page.Data[1].Text())
+	assert.Equal(t, run.ID, page.Data[1].RunID)
+	assert.Equal(t, "completed", page.Data[1].Status)
+
+	resp = postJSON(t, app, "/v1/threads/"+thread.ID+"/messages", `{"role": "system", "content": "..."}`, nil)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestThreadRunStream(t *testing.T) {
+	app := startUpThreadsApp(t)
+
+	thread := Thread{}
+	postJSON(t, app, "/v1/threads", `{}`, &thread)
+	resp := postJSON(t, app, "/v1/threads/"+thread.ID+"/runs", `{"assistant_id": "asst_1", "stream": true, "additional_messages": [{"role": "user", "content": "The capital of France?"}]}`, nil)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	events := []string{}
+	for _, line := range strings.Split(string(body), "\n") {
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+		}
+	}
+	assert.Equal(t, []string{
+		"thread.run.created", "thread.run.queued", "thread.run.in_progress",
+		"thread.message.created", "thread.message.in_progress", "thread.message.delta", "thread.message.delta",
+		"thread.message.completed", "thread.run.completed", "done",
+	}, events)
+	require.Len(t, Runs, 1)
+	assert.Equal(t, RunCompleted, Runs[0].Status)
+
+	resp = postJSON(t, app, "/v1/threads/"+thread.ID+"/runs", `{"assistant_id": "asst_2"}`, nil)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestFileCitations(t *testing.T) {
+	results := []backend.FileSearchResult{
+		{FileSearchChunk: backend.FileSearchChunk{FileID: "file-1"}},
+		{FileSearchChunk: backend.FileSearchChunk{FileID: "file-2"}},
+	}
+	annotations := fileCitations("Set the GPU layers [2], then restart [1] — not [3].", results)
+	require.Len(t, annotations, 2)
+	assert.Equal(t, MessageAnnotation{
+		Type: "file_citation", Text: "[2]", StartIndex: 19, EndIndex: 22, FileCitation: &FileCitation{FileID: "file-2"},
+	}, annotations[0])
+	assert.Equal(t, "file-1", annotations[1].FileCitation.FileID)
+	assert.Equal(t, 37, annotations[1].StartIndex)
+}
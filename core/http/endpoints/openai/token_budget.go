@@ -0,0 +1,34 @@
+package openai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/rs/zerolog/log"
+)
+
+// beginTokenBudget checks the token budget of the conversation of the request, rejecting it with 429 once exhausted,
+// and lowers max_tokens to what remains of it. The budget is nil when the conversation has none
+func beginTokenBudget(budgets *services.TokenBudgetService, apiKey string, config *config.BackendConfig, input *schema.OpenAIRequest) (*schema.TokenBudget, error) {
+	conversation := input.ConversationID
+	if conversation == "" {
+		conversation = input.User
+	}
+
+	budget, err := budgets.Begin(apiKey, conversation, input.TokenBudget)
+	if err != nil {
+		log.Info().Str("conversation", conversation).Int("limit", budget.Limit).Msg("token budget exhausted")
+		return nil, fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	}
+	if budget == nil {
+		return nil, nil
+	}
+
+	if config.Maxtokens == nil || *config.Maxtokens <= 0 || *config.Maxtokens > budget.Remaining {
+		remaining := budget.Remaining
+		config.Maxtokens = &remaining
+		budget.Truncated = true
+	}
+	return budget, nil
+}
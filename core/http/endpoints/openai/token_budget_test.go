@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBudget(t *testing.T) {
+	appConfig := &config.ApplicationConfig{TokenBudget: 100, ApiKeyTokenBudgets: map[string]int{"kiosk": 30}}
+	budgets := services.NewTokenBudgetService(appConfig)
+
+	t.Run("the requests without conversation have no budget", func(t *testing.T) {
+		budget, err := beginTokenBudget(budgets, "", &config.BackendConfig{}, &schema.OpenAIRequest{})
+		assert.NoError(t, err)
+		assert.Nil(t, budget)
+	})
+
+	t.Run("the requests may only lower the budget of their key", func(t *testing.T) {
+		assert.Equal(t, 100, budgets.Limit("", 0))
+		assert.Equal(t, 50, budgets.Limit("", 50))
+		assert.Equal(t, 100, budgets.Limit("", 500))
+		assert.Equal(t, 30, budgets.Limit("kiosk", 0))
+		assert.Equal(t, 30, budgets.Limit("kiosk", 500))
+	})
+
+	t.Run("max_tokens is lowered to the remaining budget, and the exhausted conversations are rejected", func(t *testing.T) {
+		maxTokens := 20
+		cfg := &config.BackendConfig{}
+		cfg.Maxtokens = &maxTokens
+		input := &schema.OpenAIRequest{User: "visitor"}
+
+		budget, err := beginTokenBudget(budgets, "kiosk", cfg, input)
+		assert.NoError(t, err)
+		assert.Equal(t, "visitor", budget.ConversationID)
+		assert.Equal(t, 30, budget.Remaining)
+		assert.False(t, budget.Truncated)
+		assert.Equal(t, 20, *cfg.Maxtokens)
+		budgets.Consume("kiosk", budget, 25)
+		assert.Equal(t, 25, budget.Used)
+		assert.Equal(t, 5, budget.Remaining)
+
+		budget, err = beginTokenBudget(budgets, "kiosk", cfg, input)
+		assert.NoError(t, err)
+		assert.True(t, budget.Truncated)
+		assert.Equal(t, 5, *cfg.Maxtokens)
+		budgets.Consume("kiosk", budget, 10)
+		assert.Equal(t, 0, budget.Remaining)
+
+		_, err = beginTokenBudget(budgets, "kiosk", cfg, input)
+		assert.ErrorContains(t, err, "the token budget of the conversation visitor is exhausted: 35 tokens used out of 30")
+
+		// the conversations are scoped by API key
+		budget, err = beginTokenBudget(budgets, "", cfg, input)
+		assert.NoError(t, err)
+		assert.Equal(t, 100, budget.Remaining)
+	})
+}
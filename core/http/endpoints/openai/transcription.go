@@ -10,7 +10,9 @@ import (
 
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/scanner"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
@@ -34,11 +36,17 @@ func TranscriptEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request: %w", err)
 		}
+		if _, err := fiberContext.CheckModel(c, config); err != nil {
+			return err
+		}
 		// retrieve the file data from the request
 		file, err := c.FormFile("file")
 		if err != nil {
 			return err
 		}
+		if err := fiberContext.ScanFormFile(c, appConfig, scanner.KindAudio, file); err != nil {
+			return err
+		}
 		f, err := file.Open()
 		if err != nil {
 			return err
@@ -0,0 +1,177 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	ValidatorJSON       = "json"
+	ValidatorJSONSchema = "json_schema"
+	ValidatorRegex      = "regex"
+	ValidatorMaxLength  = "max_length"
+
+	defaultValidationRetries = 1
+	maxValidationRetries     = 3
+)
+
+// responseValidator returns the errors of an output, none when it's valid
+type responseValidator func(output string) []string
+
+// compileValidators checks the validators of a request or of a model, and prepares them
+func compileValidators(validators []schema.ResponseValidator) ([]responseValidator, error) {
+	compiled := []responseValidator{}
+	for i, v := range validators {
+		switch v.Type {
+		case ValidatorJSON:
+			compiled = append(compiled, func(output string) []string {
+				if !json.Valid([]byte(output)) {
+					return []string{"the answer is not valid JSON"}
+				}
+				return nil
+			})
+		case ValidatorJSONSchema:
+			if v.Schema == nil {
+				return nil, fmt.Errorf("validator %d: schema is required", i)
+			}
+			validate, err := jsonSchemaValidator(v.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("validator %d: %w", i, err)
+			}
+			compiled = append(compiled, validate)
+		case ValidatorRegex:
+			re, err := regexp.Compile(v.Pattern)
+			if err != nil || v.Pattern == "" {
+				return nil, fmt.Errorf("validator %d: invalid pattern %q", i, v.Pattern)
+			}
+			compiled = append(compiled, func(output string) []string {
+				if !re.MatchString(output) {
+					return []string{fmt.Sprintf("the answer does not match the regular expression %s", v.Pattern)}
+				}
+				return nil
+			})
+		case ValidatorMaxLength:
+			if v.MaxLength <= 0 {
+				return nil, fmt.Errorf("validator %d: max_length must be positive", i)
+			}
+			compiled = append(compiled, func(output string) []string {
+				if n := utf8.RuneCountInString(output); n > v.MaxLength {
+					return []string{fmt.Sprintf("the answer is %d characters long, it must be at most %d", n, v.MaxLength)}
+				}
+				return nil
+			})
+		default:
+			return nil, fmt.Errorf("validator %d: unknown type %q, expected one of json, json_schema, regex and max_length", i, v.Type)
+		}
+	}
+	return compiled, nil
+}
+
+// jsonSchemaValidator returns the validator of the answers matching the JSON schema
+func jsonSchemaValidator(jsonSchema map[string]interface{}) (responseValidator, error) {
+	s, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(jsonSchema))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return func(output string) []string {
+		result, err := s.Validate(gojsonschema.NewStringLoader(output))
+		if err != nil {
+			return []string{"the answer is not valid JSON"}
+		}
+		errors := []string{}
+		for _, e := range result.Errors() {
+			errors = append(errors, "the answer does not match the JSON schema: "+e.String())
+		}
+		return errors
+	}, nil
+}
+
+// refuseInvalidChoices replaces the content of the choices the validator rejects with a refusal, a strict JSON schema
+// of the response format guaranteeing the content matches it when there is one
+func refuseInvalidChoices(validate responseValidator, choices []schema.Choice) {
+	for i := range choices {
+		errors := validateChoices([]responseValidator{validate}, choices[i:i+1])
+		if len(errors) == 0 || choices[i].Message == nil {
+			continue
+		}
+		choices[i].Message.Content = nil
+		choices[i].Message.Refusal = "The model could not answer matching the JSON schema of the response format: " + strings.Join(errors, "; ")
+	}
+}
+
+// validationRetries returns the number of retries of the invalid answers, bounded to maxValidationRetries
+func validationRetries(retries *int) int {
+	if retries == nil {
+		return defaultValidationRetries
+	}
+	return min(max(*retries, 0), maxValidationRetries)
+}
+
+// validateChoices returns the errors of the content of the choices
+func validateChoices(validators []responseValidator, choices []schema.Choice) []string {
+	errors := []string{}
+	for i, c := range choices {
+		output := ""
+		if c.Message != nil {
+			if s, ok := c.Message.Content.(*string); ok && s != nil {
+				output = *s
+			}
+		}
+		for _, validate := range validators {
+			for _, e := range validate(strings.TrimSpace(output)) {
+				if len(choices) > 1 {
+					e = fmt.Sprintf("choice %d: %s", i, e)
+				}
+				errors = append(errors, e)
+			}
+		}
+	}
+	return errors
+}
+
+// correctionMessages returns the messages retrying an invalid answer: the answer, then the instruction to fix its errors
+func correctionMessages(messages []schema.Message, choices []schema.Choice, errors []string) []schema.Message {
+	messages = append([]schema.Message{}, messages...)
+	if len(choices) > 0 && choices[0].Message != nil {
+		if s, ok := choices[0].Message.Content.(*string); ok && s != nil {
+			messages = append(messages, schema.Message{Role: "assistant", Content: *s, StringContent: *s})
+		}
+	}
+	instruction := fmt.Sprintf("Your answer is not valid: %s. Answer again, fixing these errors, and reply only with the corrected answer.", strings.Join(errors, "; "))
+	return append(messages, schema.Message{Role: "user", Content: instruction, StringContent: instruction})
+}
+
+// validateResponse checks the choices, and retries with the errors until they are valid or the retries are used up.
+// retry computes the choices of the given messages
+func validateResponse(validators []responseValidator, retries int, messages []schema.Message, choices []schema.Choice,
+	retry func(messages []schema.Message) ([]schema.Choice, backend.TokenUsage, error)) ([]schema.Choice, backend.TokenUsage, *schema.Validation, error) {
+	validation := &schema.Validation{}
+	usage := backend.TokenUsage{}
+	for attempt := 1; ; attempt++ {
+		errors := validateChoices(validators, choices)
+		validation.Attempts = append(validation.Attempts, schema.ValidationAttempt{Attempt: attempt, Errors: errors})
+		if len(errors) == 0 {
+			validation.Valid = true
+			return choices, usage, validation, nil
+		}
+		if attempt > retries {
+			return choices, usage, validation, nil
+		}
+
+		messages = correctionMessages(messages, choices, errors)
+		retried, retryUsage, err := retry(messages)
+		if err != nil {
+			return choices, usage, validation, err
+		}
+		choices = retried
+		usage.Prompt += retryUsage.Prompt
+		usage.Completion += retryUsage.Completion
+	}
+}
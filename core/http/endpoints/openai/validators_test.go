@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileValidators(t *testing.T) {
+	validators, err := compileValidators([]schema.ResponseValidator{
+		{Type: ValidatorJSONSchema, Schema: map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"city"},
+		}},
+		{Type: ValidatorRegex, Pattern: `"city":\s*"[A-Z]`},
+		{Type: ValidatorMaxLength, MaxLength: 30},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, validators, 3)
+
+	choices := func(s string) []schema.Choice {
+		return []schema.Choice{{Message: &schema.Message{Role: "assistant", Content: &s}}}
+	}
+	assert.Empty(t, validateChoices(validators, choices(`{"city": "Paris"}`)))
+	assert.Len(t, validateChoices(validators, choices(`{"town": "paris"}`)), 2)
+	assert.Equal(t, []string{"the answer is not valid JSON", "the answer does not match the regular expression \"city\":\\s*\"[A-Z]"},
+		validateChoices(validators, choices("The city is Paris")))
+
+	for _, invalid := range []schema.ResponseValidator{
+		{Type: "xml"},
+		{Type: ValidatorRegex, Pattern: "("},
+		{Type: ValidatorMaxLength},
+		{Type: ValidatorJSONSchema},
+	} {
+		_, err := compileValidators([]schema.ResponseValidator{invalid})
+		assert.Error(t, err, invalid.Type)
+	}
+}
+
+func TestValidationRetries(t *testing.T) {
+	retries := func(n int) *int { return &n }
+	assert.Equal(t, 1, validationRetries(nil))
+	assert.Equal(t, 0, validationRetries(retries(-1)))
+	assert.Equal(t, 2, validationRetries(retries(2)))
+	assert.Equal(t, maxValidationRetries, validationRetries(retries(10)))
+}
+
+func TestValidateResponse(t *testing.T) {
+	validators, err := compileValidators([]schema.ResponseValidator{{Type: ValidatorJSON}})
+	assert.NoError(t, err)
+	answer := func(s string) []schema.Choice {
+		return []schema.Choice{{Message: &schema.Message{Role: "assistant", Content: &s}}}
+	}
+	messages := []schema.Message{{Role: "user", StringContent: "Give me a JSON object"}}
+
+	calls := [][]schema.Message{}
+	retry := func(answers ...string) func([]schema.Message) ([]schema.Choice, backend.TokenUsage, error) {
+		return func(m []schema.Message) ([]schema.Choice, backend.TokenUsage, error) {
+			calls = append(calls, m)
+			return answer(answers[len(calls)-1]), backend.TokenUsage{Prompt: 10, Completion: 5}, nil
+		}
+	}
+
+	choices, usage, validation, err := validateResponse(validators, 2, messages, answer("not json"), retry("{}"))
+	assert.NoError(t, err)
+	assert.True(t, validation.Valid)
+	assert.Len(t, validation.Attempts, 2)
+	assert.Equal(t, []string{"the answer is not valid JSON"}, validation.Attempts[0].Errors)
+	assert.Empty(t, validation.Attempts[1].Errors)
+	assert.Equal(t, "{}", *choices[0].Message.Content.(*string))
+	assert.Equal(t, backend.TokenUsage{Prompt: 10, Completion: 5}, usage)
+	// the invalid answer is sent back, with the errors to fix
+	assert.Len(t, calls[0], 3)
+	assert.Equal(t, "not json", calls[0][1].StringContent)
+	assert.Contains(t, calls[0][2].StringContent, "the answer is not valid JSON")
+	assert.Len(t, messages, 1)
+
+	// the last answer is returned once the retries are used up
+	calls = nil
+	choices, _, validation, err = validateResponse(validators, 1, messages, answer("not json"), retry("still not json"))
+	assert.NoError(t, err)
+	assert.False(t, validation.Valid)
+	assert.Len(t, validation.Attempts, 2)
+	assert.Equal(t, "still not json", *choices[0].Message.Content.(*string))
+}
+
+func TestRefuseInvalidChoices(t *testing.T) {
+	validate, err := jsonSchemaValidator(map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"city"},
+	})
+	assert.NoError(t, err)
+	valid, invalid := `{"city": "Paris"}`, `{"town": "Paris"}`
+	choices := []schema.Choice{
+		{Message: &schema.Message{Role: "assistant", Content: &valid}},
+		{Message: &schema.Message{Role: "assistant", Content: &invalid}},
+	}
+
+	refuseInvalidChoices(validate, choices)
+	assert.Equal(t, valid, *choices[0].Message.Content.(*string))
+	assert.Empty(t, choices[0].Message.Refusal)
+	assert.Nil(t, choices[1].Message.Content)
+	assert.Contains(t, choices[1].Message.Refusal, "city is required")
+
+	_, err = jsonSchemaValidator(map[string]interface{}{"type": 1})
+	assert.Error(t, err)
+}
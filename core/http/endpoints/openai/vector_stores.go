@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+)
+
+// VectorStoreSearchEndpoint searches the chunks of the files indexed for file_search
+// @Summary Search a vector store for relevant chunks based on a query.
+// @Param vector_store_id path string true "Vector store name"
+// @Param request body schema.VectorStoreSearchRequest true "query params"
+// @Success 200 {object} schema.VectorStoreSearchResponse "Response"
+// @Router /v1/vector_stores/{vector_store_id}/search [post]
+func VectorStoreSearchEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, sl *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(schema.VectorStoreSearchRequest)
+		if err := c.BodyParser(request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		storeName := c.Params("vector_store_id")
+		if storeName == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter vector_store_id is required")
+		}
+
+		if request.MaxNumResults <= 0 {
+			request.MaxNumResults = 10
+		}
+
+		results, err := backend.FileSearch(c.Context(), sl, ml, cl, appConfig, storeName, request.Query, request.MaxNumResults)
+		if err != nil {
+			return err
+		}
+
+		res := schema.VectorStoreSearchResponse{
+			Object: "vector_store.search_results.page",
+			Query:  request.Query,
+			Data:   []schema.VectorStoreSearchResult{},
+		}
+		for _, r := range results {
+			res.Data = append(res.Data, schema.VectorStoreSearchResult{
+				FileID:  r.FileID,
+				Score:   r.Score,
+				Content: r.Content,
+			})
+		}
+
+		return c.JSON(res)
+	}
+}
@@ -0,0 +1,95 @@
+package tei
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// EmbedEndpoint acts like the /embed endpoint of Hugging Face text-embeddings-inference
+// (https://huggingface.github.io/text-embeddings-inference/)
+// @Summary Get the embeddings of a text or of a list of texts.
+// @Param request body schema.TEIEmbedRequest true "query params"
+// @Success 200 {object} [][]float32 "Response"
+// @Router /embed [post]
+func EmbedEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		req := new(schema.TEIEmbedRequest)
+		if err := c.BodyParser(req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+
+		inputs, err := embedInputs(req.Inputs)
+		if err != nil {
+			return err
+		}
+		if err := checkBatchSize(len(inputs)); err != nil {
+			return err
+		}
+
+		cfg, err := modelConfig(c, cl, ml, appConfig, req.Model, isEmbeddingModel)
+		if err != nil {
+			return err
+		}
+
+		embeddings := make([][]float32, 0, len(inputs))
+		for _, s := range inputs {
+			embedFn, err := modelEmbedding(s, []int{}, ml, *cfg, appConfig)
+			if err != nil {
+				return err
+			}
+			embedding, err := embedFn()
+			if err != nil {
+				return err
+			}
+			if req.Normalize == nil || *req.Normalize {
+				normalize(embedding)
+			}
+			embeddings = append(embeddings, embedding)
+		}
+
+		return c.JSON(embeddings)
+	}
+}
+
+// embedInputs returns the texts of the inputs of a request, a text or a list of texts
+func embedInputs(inputs interface{}) ([]string, error) {
+	switch v := inputs.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, i := range v {
+			s, ok := i.(string)
+			if !ok {
+				return nil, fiber.NewError(fiber.StatusUnprocessableEntity, "inputs must be a text or a list of texts")
+			}
+			texts = append(texts, s)
+		}
+		if len(texts) == 0 {
+			return nil, fiber.NewError(fiber.StatusUnprocessableEntity, "inputs cannot be empty")
+		}
+		return texts, nil
+	default:
+		return nil, fiber.NewError(fiber.StatusUnprocessableEntity, "inputs must be a text or a list of texts")
+	}
+}
+
+// normalize scales the embedding to a unit length, like TEI does by default
+func normalize(embedding []float32) {
+	var norm float64
+	for _, v := range embedding {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+	norm = math.Sqrt(norm)
+	for i := range embedding {
+		embedding[i] = float32(float64(embedding[i]) / norm)
+	}
+}
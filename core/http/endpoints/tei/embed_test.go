@@ -0,0 +1,93 @@
+package tei
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startUpTEIApp serves the TEI endpoints with an embeddings model, bert, and a reranker
+func startUpTEIApp(t *testing.T) *fiber.App {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bert.yaml"), []byte(`name: bert
+backend: bert-embeddings
+embeddings: true
+f16: true
+context_size: 512
+parameters:
+  model: bert.bin
+`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "reranker.yaml"), []byte(`name: reranker
+backend: rerankers
+parameters:
+  model: cross-encoder
+`), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+	ml := model.NewModelLoader(dir)
+	appConfig := &config.ApplicationConfig{Context: context.Background(), ModelPath: dir}
+
+	app := fiber.New()
+	app.Post("/embed", EmbedEndpoint(cl, ml, appConfig))
+	app.Post("/rerank", RerankEndpoint(cl, ml, appConfig))
+	app.Get("/info", InfoEndpoint(cl, ml, appConfig))
+	return app
+}
+
+func postJSON(t *testing.T, app *fiber.App, path, body string, out interface{}) int {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	if resp.StatusCode == fiber.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func TestEmbed(t *testing.T) {
+	app := startUpTEIApp(t)
+
+	embedded := []string{}
+	modelEmbedding = func(s string, tokens []int, loader *model.ModelLoader, cfg config.BackendConfig, appConfig *config.ApplicationConfig) (func() ([]float32, error), error) {
+		assert.Equal(t, "bert", cfg.Name)
+		embedded = append(embedded, s)
+		return func() ([]float32, error) { return []float32{3, float32(len(s))}, nil }, nil
+	}
+	t.Cleanup(func() { modelEmbedding = backend.ModelEmbedding })
+
+	t.Run("embeds a text, normalized by default", func(t *testing.T) {
+		embeddings := [][]float32{}
+		require.Equal(t, fiber.StatusOK, postJSON(t, app, "/embed", `{"inputs": "four"}`, &embeddings))
+		require.Len(t, embeddings, 1)
+		assert.InDeltaSlice(t, []float32{0.6, 0.8}, embeddings[0], 1e-6)
+	})
+
+	t.Run("embeds a list of texts", func(t *testing.T) {
+		embedded = embedded[:0]
+		embeddings := [][]float32{}
+		require.Equal(t, fiber.StatusOK, postJSON(t, app, "/embed", `{"inputs": ["four", "a"], "normalize": false}`, &embeddings))
+		assert.Equal(t, []string{"four", "a"}, embedded)
+		assert.Equal(t, [][]float32{{3, 4}, {3, 1}}, embeddings)
+	})
+
+	t.Run("refuses the invalid inputs", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusUnprocessableEntity, postJSON(t, app, "/embed", `{"inputs": []}`, nil))
+		assert.Equal(t, fiber.StatusUnprocessableEntity, postJSON(t, app, "/embed", `{"inputs": [1, 2]}`, nil))
+		texts, _ := json.Marshal(make([]string, maxClientBatchSize+1))
+		assert.Equal(t, fiber.StatusRequestEntityTooLarge, postJSON(t, app, "/embed", `{"inputs": `+string(texts)+`}`, nil))
+	})
+}
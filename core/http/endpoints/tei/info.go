@@ -0,0 +1,48 @@
+package tei
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// InfoEndpoint acts like the /info endpoint of Hugging Face text-embeddings-inference, describing the model served
+// by /embed, or by /rerank when no model has embeddings enabled. The model can be chosen with the model query parameter
+// @Summary Describes the model served by the TEI endpoints.
+// @Param model query string false "model name"
+// @Success 200 {object} schema.TEIInfo "Response"
+// @Router /info [get]
+func InfoEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		cfg, err := modelConfig(c, cl, ml, appConfig, c.Query("model"), func(cfg config.BackendConfig) bool {
+			return isEmbeddingModel(cfg) || isRerankModel(cfg)
+		})
+		if err != nil {
+			return err
+		}
+
+		info := schema.TEIInfo{
+			ModelID:            cfg.Name,
+			ModelDType:         "float32",
+			ModelType:          map[string]interface{}{"embedding": map[string]interface{}{"pooling": "mean"}},
+			MaxClientBatchSize: maxClientBatchSize,
+			Version:            internal.PrintableVersion(),
+		}
+		if cfg.F16 != nil && *cfg.F16 {
+			info.ModelDType = "float16"
+		}
+		if cfg.ContextSize != nil {
+			info.MaxInputLength = *cfg.ContextSize
+		}
+		if isRerankModel(*cfg) {
+			info.ModelType = map[string]interface{}{"reranker": map[string]interface{}{
+				"id2label": map[string]string{"0": "LABEL_0"},
+				"label2id": map[string]int{"LABEL_0": 0},
+			}}
+		}
+
+		return c.JSON(info)
+	}
+}
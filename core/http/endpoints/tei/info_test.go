@@ -0,0 +1,42 @@
+package tei
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfo(t *testing.T) {
+	app := startUpTEIApp(t)
+
+	info := func(query string) schema.TEIInfo {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/info"+query, nil))
+		require.NoError(t, err)
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		info := schema.TEIInfo{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		return info
+	}
+
+	t.Run("describes the embeddings model by default", func(t *testing.T) {
+		i := info("")
+		assert.Equal(t, "bert", i.ModelID)
+		assert.Equal(t, "float16", i.ModelDType)
+		assert.Equal(t, 512, i.MaxInputLength)
+		assert.Equal(t, maxClientBatchSize, i.MaxClientBatchSize)
+		assert.Contains(t, i.ModelType, "embedding")
+	})
+
+	t.Run("describes the model of the query", func(t *testing.T) {
+		i := info("?model=reranker")
+		assert.Equal(t, "reranker", i.ModelID)
+		assert.Equal(t, "float32", i.ModelDType)
+		assert.Contains(t, i.ModelType, "reranker")
+	})
+}
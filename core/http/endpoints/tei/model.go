@@ -0,0 +1,73 @@
+package tei
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// maxClientBatchSize is the maximum number of texts of a request, the default of TEI
+const maxClientBatchSize = 32
+
+const rerankersBackend = "rerankers"
+
+// the calls to the backends, replaced in the tests
+var (
+	modelEmbedding = backend.ModelEmbedding
+	rerank         = backend.Rerank
+)
+
+func isEmbeddingModel(cfg config.BackendConfig) bool {
+	return cfg.Embeddings != nil && *cfg.Embeddings
+}
+
+func isRerankModel(cfg config.BackendConfig) bool {
+	return cfg.Backend == rerankersBackend
+}
+
+// modelConfig returns the configuration of the model serving a TEI request. TEI serves a single model, so when the
+// request doesn't name one (in the body or in the bearer token) it's the first model matching the usecase
+func modelConfig(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, name string, usecase func(config.BackendConfig) bool) (*config.BackendConfig, error) {
+	modelName, err := fiberContext.ModelFromContext(c, cl, ml, name, false)
+	if err != nil {
+		return nil, err
+	}
+	if modelName == "" {
+		for _, cfg := range cl.GetAllBackendConfigs() {
+			if usecase(cfg) {
+				modelName = cfg.Name
+				break
+			}
+		}
+	}
+	if modelName == "" {
+		return nil, fiber.NewError(fiber.StatusNotFound, "no model is configured for this endpoint")
+	}
+
+	cfg, err := cl.LoadBackendConfigFileByName(modelName, appConfig.ModelPath,
+		config.LoadOptionDebug(appConfig.Debug),
+		config.LoadOptionThreads(appConfig.Threads),
+		config.LoadOptionContextSize(appConfig.ContextSize),
+		config.LoadOptionF16(appConfig.F16),
+		config.ModelPath(ml.ModelPath),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fiberContext.CheckModel(c, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// checkBatchSize rejects the requests with more texts than TEI accepts
+func checkBatchSize(n int) error {
+	if n > maxClientBatchSize {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, fmt.Sprintf("batch size %d > maximum allowed batch size %d", n, maxClientBatchSize))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package tei
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// RerankEndpoint acts like the /rerank endpoint of Hugging Face text-embeddings-inference
+// (https://huggingface.github.io/text-embeddings-inference/)
+// @Summary Ranks a list of texts by relevance to a query, from the most relevant.
+// @Param request body schema.TEIRerankRequest true "query params"
+// @Success 200 {object} []schema.TEIRank "Response"
+// @Router /rerank [post]
+func RerankEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		req := new(schema.TEIRerankRequest)
+		if err := c.BodyParser(req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+		if len(req.Texts) == 0 {
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "texts cannot be empty")
+		}
+		if err := checkBatchSize(len(req.Texts)); err != nil {
+			return err
+		}
+
+		cfg, err := modelConfig(c, cl, ml, appConfig, req.Model, isRerankModel)
+		if err != nil {
+			return err
+		}
+
+		request := &proto.RerankRequest{
+			Query:     req.Query,
+			TopN:      int32(len(req.Texts)),
+			Documents: req.Texts,
+		}
+		results, err := rerank(cfg.Backend, cfg.Model, request, ml, appConfig, *cfg)
+		if err != nil {
+			return err
+		}
+
+		ranks := make([]schema.TEIRank, 0, len(results.Results))
+		for _, r := range results.Results {
+			rank := schema.TEIRank{Index: int(r.Index), Score: float64(r.RelevanceScore)}
+			if req.ReturnText {
+				rank.Text = r.Text
+				if rank.Index >= 0 && rank.Index < len(req.Texts) {
+					rank.Text = req.Texts[rank.Index]
+				}
+			}
+			ranks = append(ranks, rank)
+		}
+		sort.SliceStable(ranks, func(i, j int) bool {
+			return ranks[i].Score > ranks[j].Score
+		})
+
+		return c.JSON(ranks)
+	}
+}
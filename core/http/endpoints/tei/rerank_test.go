@@ -0,0 +1,55 @@
+package tei
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRerank(t *testing.T) {
+	app := startUpTEIApp(t)
+
+	rerank = func(backendName, modelFile string, request *proto.RerankRequest, loader *model.ModelLoader, appConfig *config.ApplicationConfig, cfg config.BackendConfig) (*proto.RerankResult, error) {
+		assert.Equal(t, "reranker", cfg.Name)
+		assert.Equal(t, "Where is Paris?", request.Query)
+		assert.Equal(t, int32(len(request.Documents)), request.TopN)
+		// the scores of the backend, in the order of the texts
+		result := &proto.RerankResult{}
+		for i, score := range []float32{0.1, 0.9, 0.5} {
+			result.Results = append(result.Results, &proto.DocumentResult{Index: int32(i), RelevanceScore: score})
+		}
+		return result, nil
+	}
+	t.Cleanup(func() { rerank = backend.Rerank })
+
+	body := `{"query": "Where is Paris?", "texts": ["Berlin is in Germany", "Paris is in France", "France is in Europe"]%s}`
+
+	t.Run("ranks the texts from the most relevant", func(t *testing.T) {
+		ranks := []schema.TEIRank{}
+		require.Equal(t, fiber.StatusOK, postJSON(t, app, "/rerank", fmt.Sprintf(body, ""), &ranks))
+		require.Len(t, ranks, 3)
+		assert.Equal(t, []int{1, 2, 0}, []int{ranks[0].Index, ranks[1].Index, ranks[2].Index})
+		assert.InDelta(t, 0.9, ranks[0].Score, 1e-6)
+		assert.Empty(t, ranks[0].Text)
+	})
+
+	t.Run("returns the texts with return_text", func(t *testing.T) {
+		ranks := []schema.TEIRank{}
+		require.Equal(t, fiber.StatusOK, postJSON(t, app, "/rerank", fmt.Sprintf(body, `, "return_text": true`), &ranks))
+		require.Len(t, ranks, 3)
+		assert.Equal(t, "Paris is in France", ranks[0].Text)
+		assert.Equal(t, "Berlin is in Germany", ranks[2].Text)
+	})
+
+	t.Run("refuses the requests without texts", func(t *testing.T) {
+		assert.Equal(t, fiber.StatusUnprocessableEntity, postJSON(t, app, "/rerank", `{"query": "Where is Paris?", "texts": []}`, nil))
+	})
+}
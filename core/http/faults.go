@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/rs/zerolog/log"
+)
+
+// faultInjection injects in the requests the faults of the first rule matching them: a delay, an error, or the
+// truncation of the streamed response. The health checks, the metrics and the rules management are left alone
+func faultInjection(faults *services.FaultInjectionService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+		switch {
+		case path == "/healthz", path == "/readyz", path == "/metrics", strings.HasPrefix(path, "/faults"):
+			return c.Next()
+		}
+
+		fault := faults.Match(path, requestModel(c))
+		if fault == nil {
+			return c.Next()
+		}
+
+		log.Debug().Str("rule", fault.Rule).Str("path", path).Msg("injecting a fault in the request")
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-c.Context().Done():
+				return nil
+			}
+		}
+		if fault.ErrorStatus != 0 {
+			return fiber.NewError(fault.ErrorStatus, fmt.Sprintf("fault injected by the rule %s", fault.Rule))
+		}
+		if fault.TruncateAfter > 0 {
+			c.Locals(fiberContext.FaultTruncateKey, fault.TruncateAfter)
+		}
+		return c.Next()
+	}
+}
+
+// requestModel returns the model of a request, from its JSON body, its form or its query
+func requestModel(c *fiber.Ctx) string {
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		request := struct {
+			Model string `json:"model"`
+		}{}
+		json.Unmarshal(c.Body(), &request)
+		return request.Model
+	}
+	return c.FormValue("model")
+}
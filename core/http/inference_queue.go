@@ -0,0 +1,112 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+)
+
+// inferenceScopes are the scopes of the endpoints running the models, whose requests take a slot of the inference
+// queue
+var inferenceScopes = []string{config.APIKeyScopeChat, config.APIKeyScopeCompletion, config.APIKeyScopeEmbeddings,
+	config.APIKeyScopeImages, config.APIKeyScopeAudio, config.APIKeyScopeRerank}
+
+// delegatedPaths are the endpoints sending their inferences to the app as requests of their own, which are queued
+// instead: queueing both would deadlock with a single slot
+var delegatedPaths = []string{"/v1/threads", "/threads", "/v1/responses", "/responses"}
+
+func isInferenceRequest(c *fiber.Ctx) bool {
+	for _, prefix := range delegatedPaths {
+		if strings.HasPrefix(c.Path(), prefix) {
+			return false
+		}
+	}
+	return c.Method() == fiber.MethodPost && slices.Contains(inferenceScopes, requiredScope(c.Path()))
+}
+
+// queueInferences bounds the inference requests running at once, queueing the others by priority and refusing them
+// with 429 once the queue is full. All the requests are let through without a queue
+func queueInferences(queue *services.InferenceQueue) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if queue == nil || !isInferenceRequest(c) {
+			return c.Next()
+		}
+		priority, err := requestPriority(c)
+		if err != nil {
+			return err
+		}
+		release, err := queue.Acquire(c.UserContext(), priority)
+		if err != nil {
+			fullErr, ok := err.(*services.QueueFullError)
+			if !ok {
+				return fiber.NewError(fiber.StatusServiceUnavailable, fmt.Sprintf("The request left the inference queue: %s", err))
+			}
+			return queueFull(c, fullErr)
+		}
+
+		// the streamed responses release their slot at the end of the stream
+		end, _ := c.Locals(fiberContext.StreamEndKey).(func(schema.OpenAIUsage))
+		c.Locals(fiberContext.StreamEndKey, func(usage schema.OpenAIUsage) {
+			release()
+			if end != nil {
+				end(usage)
+			}
+		})
+		err = c.Next()
+		if _, pending := c.Locals(fiberContext.StreamEndKey).(func(schema.OpenAIUsage)); pending {
+			c.Locals(fiberContext.StreamEndKey, end)
+			release()
+		}
+		return err
+	}
+}
+
+// requestPriority returns the priority of the request: the priority of the header, which the requests of the keys
+// of api_keys.json can't raise above the priority of their key, or else the priority of the key. The admin keys,
+// and all the requests without authentication, may use any priority
+func requestPriority(c *fiber.Ctx) (services.Priority, error) {
+	priority := services.PriorityNormal
+	if entry := fiberContext.APIKeyEntryFromContext(c); entry != nil && entry.Priority != "" {
+		priority, _ = services.ParsePriority(entry.Priority)
+	}
+	header := c.Get(fiberContext.PriorityHeader)
+	if header == "" {
+		return priority, nil
+	}
+	requested, ok := services.ParsePriority(header)
+	if !ok {
+		return 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid %s %q, the priorities are high, normal and low", fiberContext.PriorityHeader, header))
+	}
+	if fiberContext.IsAdmin(c) {
+		return requested, nil
+	}
+	return min(requested, priority), nil
+}
+
+// queueFull answers a request refused by the inference queue
+func queueFull(c *fiber.Ctx, err *services.QueueFullError) error {
+	retryAfter := int(math.Ceil(err.EstimatedWait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	return c.Status(fiber.StatusTooManyRequests).JSON(schema.QueueFullErrorResponse{
+		Error: &schema.QueueFullError{
+			Code:                 fiber.StatusTooManyRequests,
+			Message:              fmt.Sprintf("The inference queue is full: %s", err),
+			Type:                 "queue_full",
+			InferencesRunning:    err.Running,
+			InferencesQueued:     err.Queued,
+			EstimatedWaitSeconds: err.EstimatedWait.Seconds(),
+			RetryAfterSeconds:    retryAfter,
+		},
+	})
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/oidc"
+)
+
+// newOIDCVerifier returns the verifier of the JWTs of the OIDC issuer, nil when they aren't accepted
+func newOIDCVerifier(appConfig *config.ApplicationConfig) (*oidc.Verifier, error) {
+	if appConfig.OIDCIssuer == "" {
+		return nil, nil
+	}
+	// without audience, the tokens the issuer delivers to any of its clients would be accepted
+	if appConfig.OIDCAudience == "" {
+		return nil, fmt.Errorf("the OIDC audience is required with the OIDC issuer")
+	}
+	for value, scopes := range appConfig.OIDCScopeMap {
+		for _, scope := range strings.Split(scopes, ",") {
+			if !config.IsAPIKeyScope(strings.TrimSpace(scope)) {
+				return nil, fmt.Errorf("the OIDC scope map grants the unknown scope %q to %q", scope, value)
+			}
+		}
+	}
+	return oidc.NewVerifier(appConfig.OIDCIssuer, appConfig.OIDCAudience, appConfig.OIDCJWKSURL), nil
+}
+
+// oidcAPIKey returns the API key of a verified token: named after its subject, with the scopes the scope map grants
+// to the values of its scopes claim, its expiration being checked by the verifier. The values which are scopes of
+// LocalAI are only granted as is with OIDCPassthroughScopes. The tokens granting no scope are refused, not to open all
+// the endpoints to every user of the issuer like the keys without scopes
+func oidcAPIKey(claims oidc.Claims, appConfig *config.ApplicationConfig) (*config.APIKey, error) {
+	claim := appConfig.OIDCScopesClaim
+	if claim == "" {
+		claim = "scope"
+	}
+
+	scopes := []string{}
+	for _, value := range claims.Strings(claim) {
+		granted, mapped := appConfig.OIDCScopeMap[value]
+		if !mapped {
+			if !appConfig.OIDCPassthroughScopes || !config.IsAPIKeyScope(value) {
+				continue
+			}
+			granted = value
+		}
+		for _, scope := range strings.Split(granted, ",") {
+			if scope = strings.TrimSpace(scope); !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("The token grants no scope of LocalAI in its %s claim", claim)
+	}
+
+	return &config.APIKey{
+		Name:   "oidc:" + claims.String("sub"),
+		Scopes: scopes,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/oidc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OIDC tokens", func() {
+	appConfig := &config.ApplicationConfig{
+		OIDCScopesClaim: "groups",
+		OIDCScopeMap:    map[string]string{"ml-admins": "admin", "ml-users": "chat, embeddings"},
+	}
+
+	It("grants the scopes mapped from the claim", func() {
+		key, err := oidcAPIKey(oidc.Claims{"sub": "alice", "groups": []interface{}{"ml-users", "staff", "images", "admin"}}, appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key.Name).To(Equal("oidc:alice"))
+		Expect(key.Scopes).To(Equal([]string{config.APIKeyScopeChat, config.APIKeyScopeEmbeddings}))
+		Expect(key.AllowsScope(config.APIKeyScopeAdmin)).To(BeFalse())
+	})
+
+	It("grants the scopes of LocalAI as is with the pass-through", func() {
+		passthrough := *appConfig
+		passthrough.OIDCPassthroughScopes = true
+		key, err := oidcAPIKey(oidc.Claims{"sub": "alice", "groups": []interface{}{"ml-users", "staff", "images", "chat"}}, &passthrough)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key.Scopes).To(Equal([]string{config.APIKeyScopeChat, config.APIKeyScopeEmbeddings, config.APIKeyScopeImages}))
+	})
+
+	It("reads the scope claim by default", func() {
+		key, err := oidcAPIKey(oidc.Claims{"sub": "bob", "scope": "openid ml-admins"}, &config.ApplicationConfig{OIDCScopeMap: appConfig.OIDCScopeMap})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(key.HasScope(config.APIKeyScopeAdmin)).To(BeTrue())
+	})
+
+	It("refuses the tokens granting no scope", func() {
+		_, err := oidcAPIKey(oidc.Claims{"sub": "carol", "groups": []interface{}{"staff"}}, appConfig)
+		Expect(err).To(HaveOccurred())
+		_, err = oidcAPIKey(oidc.Claims{"sub": "carol"}, appConfig)
+		Expect(err).To(HaveOccurred())
+		_, err = oidcAPIKey(oidc.Claims{"sub": "carol", "scope": "openid admin"}, &config.ApplicationConfig{})
+		Expect(err).To(HaveOccurred(), "the scopes of LocalAI aren't granted as is by default")
+	})
+
+	It("refuses the scope maps granting unknown scopes", func() {
+		_, err := newOIDCVerifier(&config.ApplicationConfig{OIDCIssuer: "https://sso.example.com", OIDCAudience: "localai", OIDCScopeMap: map[string]string{"ml-users": "chat,everything"}})
+		Expect(err).To(HaveOccurred())
+		verifier, err := newOIDCVerifier(&config.ApplicationConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verifier).To(BeNil())
+	})
+
+	It("requires an audience with the issuer", func() {
+		_, err := newOIDCVerifier(&config.ApplicationConfig{OIDCIssuer: "https://sso.example.com"})
+		Expect(err).To(MatchError(ContainSubstring("audience is required")))
+		verifier, err := newOIDCVerifier(&config.ApplicationConfig{OIDCIssuer: "https://sso.example.com", OIDCAudience: "localai"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verifier).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/valyala/fasthttp"
+)
+
+// requestBufferSize is the size up to which the request bodies are received before their handler is called. The
+// larger bodies are streamed to the handler: the files of their multipart forms are written to temporary files as
+// they are received, instead of being kept in memory
+const requestBufferSize = 4 * 1024 * 1024
+
+// streamPaths are the endpoints which, besides the inference endpoints, may take long to send their response: the
+// server-sent events and the files
+var streamPaths = []string{"/events", "/generated-images/", "/generated-audio/", "/v1/files/", "/files/"}
+
+// requestTimeouts returns the timeouts of a request from its header: the uploads may take long to be received, and
+// the streamed answers and the files to be sent, while the other calls are short. A zero timeout keeps the one of
+// the server
+func requestTimeouts(appConfig *config.ApplicationConfig) func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	return func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		if bytes.HasPrefix(header.ContentType(), []byte(fiber.MIMEMultipartForm)) {
+			return fasthttp.RequestConfig{ReadTimeout: appConfig.UploadTimeout}
+		}
+		path, _, _ := strings.Cut(string(header.RequestURI()), "?")
+		if isStreamPath(path) {
+			return fasthttp.RequestConfig{WriteTimeout: appConfig.StreamTimeout}
+		}
+		return fasthttp.RequestConfig{}
+	}
+}
+
+func isStreamPath(path string) bool {
+	for _, prefix := range streamPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return slices.Contains(inferenceScopes, requiredScope(path))
+}
+
+// limitRequestBody refuses the requests whose body is larger than limit with 413. The streamed bodies are checked
+// from their length before they are read, the ones without a length are read in memory up to the limit
+func limitRequestBody(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		size := c.Request().Header.ContentLength()
+		if size > limit {
+			// the body isn't read, the connection can't serve another request
+			c.Context().SetConnectionClose()
+			return fiber.ErrRequestEntityTooLarge
+		}
+		if size == -1 && c.Request().IsBodyStream() {
+			body, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), int64(limit)+1))
+			if err != nil {
+				return err
+			}
+			if len(body) > limit {
+				c.Context().SetConnectionClose()
+				return fiber.ErrRequestEntityTooLarge
+			}
+			c.Request().SetBody(body)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/valyala/fasthttp"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request limits", func() {
+	appConfig := &config.ApplicationConfig{UploadTimeout: 30 * time.Minute, StreamTimeout: time.Hour}
+
+	DescribeTable("gives their timeouts to the uploads and to the streams",
+		func(path, contentType string, expected fasthttp.RequestConfig) {
+			header := &fasthttp.RequestHeader{}
+			header.SetRequestURI(path)
+			header.SetContentType(contentType)
+			Expect(requestTimeouts(appConfig)(header)).To(Equal(expected))
+		},
+		Entry(nil, "/v1/audio/transcriptions", "multipart/form-data; boundary=x", fasthttp.RequestConfig{ReadTimeout: 30 * time.Minute}),
+		Entry(nil, "/v1/chat/completions", fiber.MIMEApplicationJSON, fasthttp.RequestConfig{WriteTimeout: time.Hour}),
+		Entry(nil, "/generated-audio/a.wav", "", fasthttp.RequestConfig{WriteTimeout: time.Hour}),
+		Entry(nil, "/events", "", fasthttp.RequestConfig{WriteTimeout: time.Hour}),
+		Entry(nil, "/models/apply", fiber.MIMEApplicationJSON, fasthttp.RequestConfig{}),
+		Entry(nil, "/system", "", fasthttp.RequestConfig{}),
+	)
+
+	It("refuses the bodies larger than the upload limit", func() {
+		app := fiber.New(fiber.Config{BodyLimit: 16, StreamRequestBody: true, DisablePreParseMultipartForm: true})
+		app.Use(limitRequestBody(32))
+		app.Post("/", func(c *fiber.Ctx) error {
+			return c.Send(c.Body())
+		})
+
+		for size, status := range map[int]int{8: fiber.StatusOK, 24: fiber.StatusOK, 40: fiber.StatusRequestEntityTooLarge} {
+			resp, err := app.Test(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", size))))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(status), "body of %d bytes", size)
+		}
+
+		// without a length, the body is read up to the limit
+		for size, status := range map[int]int{24: fiber.StatusOK, 40: fiber.StatusRequestEntityTooLarge} {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bytes.Repeat([]byte("a"), size)))
+			req.ContentLength = -1
+			req.TransferEncoding = []string{"chunked"}
+			resp, err := app.Test(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(status), "chunked body of %d bytes", size)
+		}
+	})
+})
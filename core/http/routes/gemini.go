@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/gemini"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+func RegisterGeminiRoutes(app *fiber.App,
+	cl *config.BackendConfigLoader,
+	ml *model.ModelLoader,
+	appConfig *config.ApplicationConfig,
+	auth func(*fiber.Ctx) error) {
+
+	// Gemini: /v1beta/models/{model}:generateContent and /v1beta/models/{model}:streamGenerateContent
+	app.Post("/v1beta/models/*", auth, gemini.GenerateContentEndpoint(cl, ml, appConfig))
+}
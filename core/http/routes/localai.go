@@ -14,8 +14,15 @@ import (
 func RegisterLocalAIRoutes(app *fiber.App,
 	cl *config.BackendConfigLoader,
 	ml *model.ModelLoader,
+	sl *model.ModelLoader,
 	appConfig *config.ApplicationConfig,
 	galleryService *services.GalleryService,
+	memoryService *services.MemoryService,
+	diagnosticsService *services.DiagnosticsService,
+	schedulerService *services.SchedulerService,
+	faultInjectionService *services.FaultInjectionService,
+	usageService *services.UsageService,
+	recommendationService *services.RecommendationService,
 	auth func(*fiber.Ctx) error) {
 
 	app.Get("/swagger/*", swagger.HandlerDefault) // default
@@ -28,28 +35,53 @@ func RegisterLocalAIRoutes(app *fiber.App,
 
 		app.Get("/models/available", auth, modelGalleryEndpointService.ListModelFromGalleryEndpoint())
 		app.Get("/models/galleries", auth, modelGalleryEndpointService.ListModelGalleriesEndpoint())
+		app.Get("/models/galleries/health", auth, modelGalleryEndpointService.GalleriesHealthEndpoint())
+		app.Get("/models/galleries/:name/errors", auth, modelGalleryEndpointService.GalleryErrorsEndpoint())
 		app.Post("/models/galleries", auth, modelGalleryEndpointService.AddModelGalleryEndpoint())
 		app.Delete("/models/galleries", auth, modelGalleryEndpointService.RemoveModelGalleryEndpoint())
 		app.Get("/models/jobs/:uuid", auth, modelGalleryEndpointService.GetOpStatusEndpoint())
 		app.Get("/models/jobs", auth, modelGalleryEndpointService.GetAllStatusEndpoint())
+		app.Post("/models/import-local", auth, localai.ImportLocalModelEndpoint(cl, appConfig))
+		app.Get("/models/recommendations", auth, localai.RecommendationsEndpoint(recommendationService))
 	}
 
 	app.Post("/tts", auth, localai.TTSEndpoint(cl, ml, appConfig))
+	app.Post("/v1/translate", auth, localai.TranslateEndpoint(cl, ml, appConfig))
+	app.Post("/v1/audio/notes", auth, localai.MeetingNotesEndpoint(cl, ml, appConfig))
+
+	// Image generation presets
+	app.Get("/image/presets", auth, localai.ListImagePresetsEndpoint())
+	app.Post("/image/presets", auth, localai.SaveImagePresetEndpoint(appConfig))
+	app.Delete("/image/presets/:model/:name", auth, localai.DeleteImagePresetEndpoint(appConfig))
+
+	// Prompt variables
+	app.Get("/variables", auth, localai.ListPromptVariablesEndpoint())
+	app.Get("/variables/:name", auth, localai.GetPromptVariableEndpoint())
+	app.Post("/variables", auth, localai.SetPromptVariableEndpoint(appConfig))
+	app.Delete("/variables/:name", auth, localai.DeletePromptVariableEndpoint(appConfig))
+
+	// Grammar library
+	app.Get("/v1/grammars", auth, localai.ListGrammarsEndpoint(appConfig))
 
 	// Stores
-	sl := model.NewModelLoader("")
 	app.Post("/stores/set", auth, localai.StoresSetEndpoint(sl, appConfig))
 	app.Post("/stores/delete", auth, localai.StoresDeleteEndpoint(sl, appConfig))
 	app.Post("/stores/get", auth, localai.StoresGetEndpoint(sl, appConfig))
 	app.Post("/stores/find", auth, localai.StoresFindEndpoint(sl, appConfig))
 
+	// Long-term memory
+	app.Get("/memories", auth, localai.ListMemoriesEndpoint(memoryService))
+	app.Post("/memories", auth, localai.CreateMemoryEndpoint(memoryService))
+	app.Post("/memories/:memory_id", auth, localai.ModifyMemoryEndpoint(memoryService))
+	app.Delete("/memories/:memory_id", auth, localai.DeleteMemoryEndpoint(memoryService))
+
 	// Kubernetes health checks
 	ok := func(c *fiber.Ctx) error {
 		return c.SendStatus(200)
 	}
 
 	app.Get("/healthz", ok)
-	app.Get("/readyz", ok)
+	app.Get("/readyz", localai.ReadyzEndpoint(appConfig))
 
 	app.Get("/metrics", auth, localai.LocalAIMetricsEndpoint())
 
@@ -58,12 +90,40 @@ func RegisterLocalAIRoutes(app *fiber.App,
 	app.Get("/backend/monitor", auth, localai.BackendMonitorEndpoint(backendMonitorService))
 	app.Post("/backend/shutdown", auth, localai.BackendShutdownEndpoint(backendMonitorService))
 
+	// Diagnostics of the backend crashes
+	app.Get("/diagnostics", auth, localai.ListDiagnosticsEndpoint(diagnosticsService))
+	app.Get("/diagnostics/:name", auth, localai.GetDiagnosticsEndpoint(diagnosticsService))
+	app.Delete("/diagnostics/:name", auth, localai.DeleteDiagnosticsEndpoint(diagnosticsService))
+
+	// Events of the models, the watchdog, the gallery jobs, the config reloads and the p2p nodes
+	app.Get("/events", auth, localai.EventsEndpoint(appConfig))
+
+	// Scheduled tasks
+	app.Get("/jobs/scheduled", auth, localai.ListScheduledTasksEndpoint(schedulerService))
+	app.Get("/jobs/scheduled/:name", auth, localai.GetScheduledTaskRunsEndpoint(schedulerService))
+	app.Post("/jobs/scheduled/:name/run", auth, localai.RunScheduledTaskEndpoint(schedulerService, appConfig))
+
+	// Fault injection rules, hidden unless enabled
+	if appConfig.FaultInjection {
+		app.Get("/faults", auth, localai.ListFaultRulesEndpoint(faultInjectionService))
+		app.Post("/faults", auth, localai.AddFaultRuleEndpoint(faultInjectionService))
+		app.Delete("/faults", auth, localai.ClearFaultRulesEndpoint(faultInjectionService))
+		app.Delete("/faults/:id", auth, localai.DeleteFaultRuleEndpoint(faultInjectionService))
+	}
+
 	// p2p
 	if p2p.IsP2PEnabled() {
 		app.Get("/api/p2p", auth, localai.ShowP2PNodes(appConfig))
 		app.Get("/api/p2p/token", auth, localai.ShowP2PToken(appConfig))
 	}
 
+	app.Get("/system", auth, localai.SystemEndpoint(appConfig))
+
+	// Token usage per API key and per model
+	if !appConfig.DisableUsageAccounting {
+		app.Get("/v1/usage", auth, localai.UsageEndpoint(usageService))
+	}
+
 	app.Get("/version", auth, func(c *fiber.Ctx) error {
 		return c.JSON(struct {
 			Version string `json:"version"`
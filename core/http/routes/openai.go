@@ -5,23 +5,46 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/pkg/model"
 )
 
 func RegisterOpenAIRoutes(app *fiber.App,
 	cl *config.BackendConfigLoader,
 	ml *model.ModelLoader,
+	sl *model.ModelLoader,
 	appConfig *config.ApplicationConfig,
+	memoryService *services.MemoryService,
+	storedCompletionsService *services.StoredCompletionsService,
+	tokenBudgetService *services.TokenBudgetService,
 	auth func(*fiber.Ctx) error) {
 	// openAI compatible API endpoint
 
+	// models with `backend: proxy` are forwarded to their upstream
+	proxy := openai.ProxyMiddleware(cl, tokenBudgetService, appConfig)
+
 	// chat
-	app.Post("/v1/chat/completions", auth, openai.ChatEndpoint(cl, ml, appConfig))
-	app.Post("/chat/completions", auth, openai.ChatEndpoint(cl, ml, appConfig))
+	app.Post("/v1/chat/completions", auth, proxy, openai.ChatEndpoint(cl, ml, memoryService, storedCompletionsService, tokenBudgetService, appConfig))
+	app.Post("/chat/completions", auth, proxy, openai.ChatEndpoint(cl, ml, memoryService, storedCompletionsService, tokenBudgetService, appConfig))
+
+	// stored chat completions, WebSocket upgrades are handled by the chat WebSocket transport
+	app.Get("/v1/chat/completions", auth, openai.ChatWebSocketEndpoint(), openai.ListStoredCompletionsEndpoint(storedCompletionsService))
+	app.Get("/chat/completions", auth, openai.ChatWebSocketEndpoint(), openai.ListStoredCompletionsEndpoint(storedCompletionsService))
+	app.Get("/v1/chat/completions/:completion_id", auth, openai.GetStoredCompletionEndpoint(storedCompletionsService))
+	app.Get("/chat/completions/:completion_id", auth, openai.GetStoredCompletionEndpoint(storedCompletionsService))
+	app.Get("/v1/chat/completions/:completion_id/messages", auth, openai.GetStoredCompletionMessagesEndpoint(storedCompletionsService))
+	app.Get("/chat/completions/:completion_id/messages", auth, openai.GetStoredCompletionMessagesEndpoint(storedCompletionsService))
+	app.Post("/v1/chat/completions/:completion_id", auth, openai.UpdateStoredCompletionEndpoint(storedCompletionsService))
+	app.Post("/chat/completions/:completion_id", auth, openai.UpdateStoredCompletionEndpoint(storedCompletionsService))
+	app.Delete("/v1/chat/completions/:completion_id", auth, openai.DeleteStoredCompletionEndpoint(storedCompletionsService))
+	app.Delete("/chat/completions/:completion_id", auth, openai.DeleteStoredCompletionEndpoint(storedCompletionsService))
+
+	// fine-tuning datasets exported from the stored chat completions
+	app.Get("/datasets/export", auth, openai.ExportStoredCompletionsEndpoint(storedCompletionsService))
 
 	// edit
-	app.Post("/v1/edits", auth, openai.EditEndpoint(cl, ml, appConfig))
-	app.Post("/edits", auth, openai.EditEndpoint(cl, ml, appConfig))
+	app.Post("/v1/edits", auth, proxy, openai.EditEndpoint(cl, ml, appConfig))
+	app.Post("/edits", auth, proxy, openai.EditEndpoint(cl, ml, appConfig))
 
 	// assistant
 	app.Get("/v1/assistants", auth, openai.ListAssistantsEndpoint(cl, ml, appConfig))
@@ -36,13 +59,51 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	app.Post("/assistants/:assistant_id", auth, openai.ModifyAssistantEndpoint(cl, ml, appConfig))
 	app.Get("/v1/assistants/:assistant_id/files", auth, openai.ListAssistantFilesEndpoint(cl, ml, appConfig))
 	app.Get("/assistants/:assistant_id/files", auth, openai.ListAssistantFilesEndpoint(cl, ml, appConfig))
-	app.Post("/v1/assistants/:assistant_id/files", auth, openai.CreateAssistantFileEndpoint(cl, ml, appConfig))
-	app.Post("/assistants/:assistant_id/files", auth, openai.CreateAssistantFileEndpoint(cl, ml, appConfig))
+	app.Post("/v1/assistants/:assistant_id/files", auth, openai.CreateAssistantFileEndpoint(cl, ml, sl, appConfig))
+	app.Post("/assistants/:assistant_id/files", auth, openai.CreateAssistantFileEndpoint(cl, ml, sl, appConfig))
 	app.Delete("/v1/assistants/:assistant_id/files/:file_id", auth, openai.DeleteAssistantFileEndpoint(cl, ml, appConfig))
 	app.Delete("/assistants/:assistant_id/files/:file_id", auth, openai.DeleteAssistantFileEndpoint(cl, ml, appConfig))
 	app.Get("/v1/assistants/:assistant_id/files/:file_id", auth, openai.GetAssistantFileEndpoint(cl, ml, appConfig))
 	app.Get("/assistants/:assistant_id/files/:file_id", auth, openai.GetAssistantFileEndpoint(cl, ml, appConfig))
 
+	// threads, their messages and their runs
+	app.Post("/v1/threads", auth, openai.CreateThreadEndpoint(appConfig))
+	app.Post("/threads", auth, openai.CreateThreadEndpoint(appConfig))
+	app.Get("/v1/threads/:thread_id", auth, openai.GetThreadEndpoint(appConfig))
+	app.Get("/threads/:thread_id", auth, openai.GetThreadEndpoint(appConfig))
+	app.Post("/v1/threads/:thread_id", auth, openai.ModifyThreadEndpoint(appConfig))
+	app.Post("/threads/:thread_id", auth, openai.ModifyThreadEndpoint(appConfig))
+	app.Delete("/v1/threads/:thread_id", auth, openai.DeleteThreadEndpoint(appConfig))
+	app.Delete("/threads/:thread_id", auth, openai.DeleteThreadEndpoint(appConfig))
+	app.Post("/v1/threads/:thread_id/messages", auth, openai.CreateMessageEndpoint(appConfig))
+	app.Post("/threads/:thread_id/messages", auth, openai.CreateMessageEndpoint(appConfig))
+	app.Get("/v1/threads/:thread_id/messages", auth, openai.ListMessagesEndpoint(appConfig))
+	app.Get("/threads/:thread_id/messages", auth, openai.ListMessagesEndpoint(appConfig))
+	app.Get("/v1/threads/:thread_id/messages/:message_id", auth, openai.GetMessageEndpoint(appConfig))
+	app.Get("/threads/:thread_id/messages/:message_id", auth, openai.GetMessageEndpoint(appConfig))
+	app.Post("/v1/threads/:thread_id/runs", auth, openai.CreateRunEndpoint(cl, ml, sl, appConfig))
+	app.Post("/threads/:thread_id/runs", auth, openai.CreateRunEndpoint(cl, ml, sl, appConfig))
+	app.Get("/v1/threads/:thread_id/runs", auth, openai.ListRunsEndpoint(appConfig))
+	app.Get("/threads/:thread_id/runs", auth, openai.ListRunsEndpoint(appConfig))
+	app.Get("/v1/threads/:thread_id/runs/:run_id", auth, openai.GetRunEndpoint(appConfig))
+	app.Get("/threads/:thread_id/runs/:run_id", auth, openai.GetRunEndpoint(appConfig))
+	app.Post("/v1/threads/:thread_id/runs/:run_id/cancel", auth, openai.CancelRunEndpoint(appConfig))
+	app.Post("/threads/:thread_id/runs/:run_id/cancel", auth, openai.CancelRunEndpoint(appConfig))
+
+	// responses
+	app.Post("/v1/responses", auth, openai.CreateResponseEndpoint(cl, ml, sl, appConfig))
+	app.Post("/responses", auth, openai.CreateResponseEndpoint(cl, ml, sl, appConfig))
+	app.Get("/v1/responses/:response_id", auth, openai.GetResponseEndpoint(appConfig))
+	app.Get("/responses/:response_id", auth, openai.GetResponseEndpoint(appConfig))
+	app.Delete("/v1/responses/:response_id", auth, openai.DeleteResponseEndpoint(appConfig))
+	app.Delete("/responses/:response_id", auth, openai.DeleteResponseEndpoint(appConfig))
+	app.Get("/v1/responses/:response_id/input_items", auth, openai.ListResponseInputItemsEndpoint(appConfig))
+	app.Get("/responses/:response_id/input_items", auth, openai.ListResponseInputItemsEndpoint(appConfig))
+
+	// vector stores (file_search)
+	app.Post("/v1/vector_stores/:vector_store_id/search", auth, openai.VectorStoreSearchEndpoint(cl, ml, sl, appConfig))
+	app.Post("/vector_stores/:vector_store_id/search", auth, openai.VectorStoreSearchEndpoint(cl, ml, sl, appConfig))
+
 	// files
 	app.Post("/v1/files", auth, openai.UploadFilesEndpoint(cl, appConfig))
 	app.Post("/files", auth, openai.UploadFilesEndpoint(cl, appConfig))
@@ -55,22 +116,40 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	app.Get("/v1/files/:file_id/content", auth, openai.GetFilesContentsEndpoint(cl, appConfig))
 	app.Get("/files/:file_id/content", auth, openai.GetFilesContentsEndpoint(cl, appConfig))
 
+	// batches
+	app.Post("/v1/batches", auth, openai.CreateBatchEndpoint(appConfig))
+	app.Post("/batches", auth, openai.CreateBatchEndpoint(appConfig))
+	app.Get("/v1/batches", auth, openai.ListBatchesEndpoint(appConfig))
+	app.Get("/batches", auth, openai.ListBatchesEndpoint(appConfig))
+	app.Get("/v1/batches/:batch_id", auth, openai.GetBatchEndpoint(appConfig))
+	app.Get("/batches/:batch_id", auth, openai.GetBatchEndpoint(appConfig))
+	app.Post("/v1/batches/:batch_id/cancel", auth, openai.CancelBatchEndpoint(appConfig))
+	app.Post("/batches/:batch_id/cancel", auth, openai.CancelBatchEndpoint(appConfig))
+	app.Post("/v1/batches/:batch_id/resume", auth, openai.ResumeBatchEndpoint(appConfig))
+	app.Post("/batches/:batch_id/resume", auth, openai.ResumeBatchEndpoint(appConfig))
+
 	// completion
-	app.Post("/v1/completions", auth, openai.CompletionEndpoint(cl, ml, appConfig))
-	app.Post("/completions", auth, openai.CompletionEndpoint(cl, ml, appConfig))
-	app.Post("/v1/engines/:model/completions", auth, openai.CompletionEndpoint(cl, ml, appConfig))
+	app.Post("/v1/completions", auth, proxy, openai.CompletionEndpoint(cl, ml, tokenBudgetService, appConfig))
+	app.Post("/completions", auth, proxy, openai.CompletionEndpoint(cl, ml, tokenBudgetService, appConfig))
+	app.Post("/v1/engines/:model/completions", auth, openai.CompletionEndpoint(cl, ml, tokenBudgetService, appConfig))
 
 	// embeddings
-	app.Post("/v1/embeddings", auth, openai.EmbeddingsEndpoint(cl, ml, appConfig))
-	app.Post("/embeddings", auth, openai.EmbeddingsEndpoint(cl, ml, appConfig))
+	app.Post("/v1/embeddings", auth, proxy, openai.EmbeddingsEndpoint(cl, ml, appConfig))
+	app.Post("/embeddings", auth, proxy, openai.EmbeddingsEndpoint(cl, ml, appConfig))
 	app.Post("/v1/engines/:model/embeddings", auth, openai.EmbeddingsEndpoint(cl, ml, appConfig))
 
+	// moderations
+	app.Post("/v1/moderations", auth, openai.ModerationEndpoint(cl, ml, appConfig))
+	app.Post("/moderations", auth, openai.ModerationEndpoint(cl, ml, appConfig))
+
 	// audio
-	app.Post("/v1/audio/transcriptions", auth, openai.TranscriptEndpoint(cl, ml, appConfig))
-	app.Post("/v1/audio/speech", auth, localai.TTSEndpoint(cl, ml, appConfig))
+	app.Post("/v1/audio/transcriptions", auth, proxy, openai.TranscriptEndpoint(cl, ml, appConfig))
+	app.Post("/v1/audio/speech", auth, proxy, localai.TTSEndpoint(cl, ml, appConfig))
+	app.Get("/v1/realtime", auth, openai.RealtimeEndpoint(cl, ml))
+	app.Get("/realtime", auth, openai.RealtimeEndpoint(cl, ml))
 
 	// images
-	app.Post("/v1/images/generations", auth, openai.ImageEndpoint(cl, ml, appConfig))
+	app.Post("/v1/images/generations", auth, proxy, openai.ImageEndpoint(cl, ml, appConfig))
 
 	if appConfig.ImageDir != "" {
 		app.Static("/generated-images", appConfig.ImageDir)
@@ -83,4 +162,5 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	// List models
 	app.Get("/v1/models", auth, openai.ListModelsEndpoint(cl, ml))
 	app.Get("/models", auth, openai.ListModelsEndpoint(cl, ml))
+	app.Get("/v1/models/:model", auth, openai.GetModelEndpoint(cl, ml))
 }
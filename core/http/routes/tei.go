@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/tei"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+func RegisterTEIRoutes(app *fiber.App,
+	cl *config.BackendConfigLoader,
+	ml *model.ModelLoader,
+	appConfig *config.ApplicationConfig,
+	auth func(*fiber.Ctx) error) {
+
+	// Hugging Face text-embeddings-inference
+	app.Post("/embed", auth, tei.EmbedEndpoint(cl, ml, appConfig))
+	app.Post("/rerank", auth, tei.RerankEndpoint(cl, ml, appConfig))
+	app.Get("/info", auth, tei.InfoEndpoint(cl, ml, appConfig))
+}
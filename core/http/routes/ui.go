@@ -55,6 +55,22 @@ func (m *modelOpCache) Exists(key string) bool {
 	return m.status.Exists(key)
 }
 
+// chatAttachmentModels returns the models the chat attachments are routed to:
+// the ones taking images (with a multimodal projector) and the ones transcribing audio
+func chatAttachmentModels(cl *config.BackendConfigLoader) (vision []string, transcription []string) {
+	for _, c := range cl.GetAllBackendConfigs() {
+		switch {
+		case c.MMProj != "":
+			vision = append(vision, c.Name)
+		case c.Backend == model.WhisperBackend:
+			transcription = append(transcription, c.Name)
+		}
+	}
+	sort.Strings(vision)
+	sort.Strings(transcription)
+	return
+}
+
 func RegisterUIRoutes(app *fiber.App,
 	cl *config.BackendConfigLoader,
 	ml *model.ModelLoader,
@@ -302,16 +318,24 @@ func RegisterUIRoutes(app *fiber.App,
 		})
 	}
 
+	// Files attached to the messages of the chat
+	app.Post("/chat/attachments/:conversation_id", auth, localai.UploadChatAttachmentEndpoint(appConfig))
+	app.Get("/chat/attachments/:conversation_id/:attachment_id", auth, localai.GetChatAttachmentEndpoint(appConfig))
+	app.Delete("/chat/attachments/:conversation_id", auth, localai.DeleteChatAttachmentsEndpoint(appConfig))
+
 	// Show the Chat page
 	app.Get("/chat/:model", auth, func(c *fiber.Ctx) error {
 		backendConfigs, _ := services.ListModels(cl, ml, "", true)
+		visionModels, transcriptionModels := chatAttachmentModels(cl)
 
 		summary := fiber.Map{
-			"Title":        "LocalAI - Chat with " + c.Params("model"),
-			"ModelsConfig": backendConfigs,
-			"Model":        c.Params("model"),
-			"Version":      internal.PrintableVersion(),
-			"IsP2PEnabled": p2p.IsP2PEnabled(),
+			"Title":               "LocalAI - Chat with " + c.Params("model"),
+			"ModelsConfig":        backendConfigs,
+			"Model":               c.Params("model"),
+			"VisionModels":        visionModels,
+			"TranscriptionModels": transcriptionModels,
+			"Version":             internal.PrintableVersion(),
+			"IsP2PEnabled":        p2p.IsP2PEnabled(),
 		}
 
 		// Render index
@@ -347,12 +371,16 @@ func RegisterUIRoutes(app *fiber.App,
 			return c.Redirect("/")
 		}
 
+		visionModels, transcriptionModels := chatAttachmentModels(cl)
+
 		summary := fiber.Map{
-			"Title":        "LocalAI - Chat with " + backendConfigs[0],
-			"ModelsConfig": backendConfigs,
-			"Model":        backendConfigs[0],
-			"Version":      internal.PrintableVersion(),
-			"IsP2PEnabled": p2p.IsP2PEnabled(),
+			"Title":               "LocalAI - Chat with " + backendConfigs[0],
+			"ModelsConfig":        backendConfigs,
+			"Model":               backendConfigs[0],
+			"VisionModels":        visionModels,
+			"TranscriptionModels": transcriptionModels,
+			"Version":             internal.PrintableVersion(),
+			"IsP2PEnabled":        p2p.IsP2PEnabled(),
 		}
 
 		// Render index
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// endpointScopes are the scopes of the API keys required by the endpoints, by path prefix. The endpoints neither
+// listed here nor in openEndpoints require the admin scope, so that a new endpoint isn't open to all the keys
+var endpointScopes = []struct {
+	prefix string
+	scope  string
+}{
+	{"/v1/chat/completions", config.APIKeyScopeChat},
+	{"/chat/completions", config.APIKeyScopeChat},
+	{"/v1/assistants", config.APIKeyScopeChat},
+	{"/assistants", config.APIKeyScopeChat},
+	{"/v1/threads", config.APIKeyScopeChat},
+	{"/threads", config.APIKeyScopeChat},
+	{"/v1/responses", config.APIKeyScopeChat},
+	{"/responses", config.APIKeyScopeChat},
+	{"/v1beta/models", config.APIKeyScopeChat},
+	{"/memories", config.APIKeyScopeChat},
+	{"/chat", config.APIKeyScopeChat},
+	{"/v1/completions", config.APIKeyScopeCompletion},
+	{"/completions", config.APIKeyScopeCompletion},
+	{"/v1/edits", config.APIKeyScopeCompletion},
+	{"/edits", config.APIKeyScopeCompletion},
+	{"/v1/translate", config.APIKeyScopeCompletion},
+	{"/v1/moderations", config.APIKeyScopeCompletion},
+	{"/moderations", config.APIKeyScopeCompletion},
+	{"/v1/embeddings", config.APIKeyScopeEmbeddings},
+	{"/embeddings", config.APIKeyScopeEmbeddings},
+	{"/embed", config.APIKeyScopeEmbeddings},
+	{"/info", config.APIKeyScopeEmbeddings},
+	{"/stores", config.APIKeyScopeEmbeddings},
+	{"/v1/vector_stores", config.APIKeyScopeEmbeddings},
+	{"/vector_stores", config.APIKeyScopeEmbeddings},
+	{"/v1/images", config.APIKeyScopeImages},
+	{"/image", config.APIKeyScopeImages},
+	{"/text2image", config.APIKeyScopeImages},
+	{"/v1/audio", config.APIKeyScopeAudio},
+	{"/v1/realtime", config.APIKeyScopeAudio},
+	{"/realtime", config.APIKeyScopeAudio},
+	{"/v1/text-to-speech", config.APIKeyScopeAudio},
+	{"/v1/sound-generation", config.APIKeyScopeAudio},
+	{"/tts", config.APIKeyScopeAudio},
+	{"/talk", config.APIKeyScopeAudio},
+	{"/v1/rerank", config.APIKeyScopeRerank},
+	{"/rerank", config.APIKeyScopeRerank},
+	{"/v1/files", config.APIKeyScopeFiles},
+	{"/files", config.APIKeyScopeFiles},
+	{"/v1/batches", config.APIKeyScopeFiles},
+	{"/batches", config.APIKeyScopeFiles},
+	{"/models/apply", config.APIKeyScopeGallery},
+	{"/models/delete", config.APIKeyScopeGallery},
+	{"/models/available", config.APIKeyScopeGallery},
+	{"/models/galleries", config.APIKeyScopeGallery},
+	{"/models/jobs", config.APIKeyScopeGallery},
+	{"/models/import-local", config.APIKeyScopeGallery},
+	{"/models/recommendations", config.APIKeyScopeGallery},
+	{"/browse", config.APIKeyScopeGallery},
+	{"/backend", config.APIKeyScopeAdmin},
+	{"/diagnostics", config.APIKeyScopeAdmin},
+	{"/jobs", config.APIKeyScopeAdmin},
+	{"/faults", config.APIKeyScopeAdmin},
+	{"/datasets", config.APIKeyScopeAdmin},
+	{"/variables", config.APIKeyScopeAdmin},
+	{"/api/p2p", config.APIKeyScopeAdmin},
+	{"/p2p", config.APIKeyScopeAdmin},
+	{"/system", config.APIKeyScopeAdmin},
+	{"/metrics", config.APIKeyScopeAdmin},
+	{"/events", config.APIKeyScopeAdmin},
+}
+
+// openEndpoints are the endpoints all the keys may request, whatever their scopes: the model listing, the version,
+// the grammars, the usage of the key and the welcome page. They match exactly, except the ones ending with a slash
+// matching by prefix
+var openEndpoints = []string{
+	"/",
+	"/v1/models",
+	"/v1/models/",
+	"/models",
+	"/version",
+	"/v1/grammars",
+	"/v1/usage",
+}
+
+// requiredScope returns the scope an API key needs to request the path, empty when all the keys may
+func requiredScope(path string) string {
+	// the legacy engine endpoints have the model in their path
+	if strings.HasPrefix(path, "/v1/engines/") {
+		if strings.HasSuffix(path, "/embeddings") {
+			return config.APIKeyScopeEmbeddings
+		}
+		return config.APIKeyScopeCompletion
+	}
+	for _, e := range endpointScopes {
+		if path == e.prefix || strings.HasPrefix(path, e.prefix+"/") {
+			return e.scope
+		}
+	}
+	for _, open := range openEndpoints {
+		if path == open || (open != "/" && strings.HasSuffix(open, "/") && strings.HasPrefix(path, open)) {
+			return ""
+		}
+	}
+	return config.APIKeyScopeAdmin
+}
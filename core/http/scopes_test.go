@@ -0,0 +1,98 @@
+package http
+
+import (
+	"github.com/mudler/LocalAI/core/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("API key scopes", func() {
+	DescribeTable("requires the scope of the registered routes",
+		func(path, scope string) {
+			Expect(requiredScope(path)).To(Equal(scope))
+		},
+		Entry(nil, "/", ""),
+		Entry(nil, "/v1/models", ""),
+		Entry(nil, "/v1/models/phi-2", ""),
+		Entry(nil, "/models", ""),
+		Entry(nil, "/version", ""),
+		Entry(nil, "/v1/grammars", ""),
+		Entry(nil, "/v1/usage", ""),
+		Entry(nil, "/v1/chat/completions", config.APIKeyScopeChat),
+		Entry(nil, "/v1/chat/completions/chatcmpl-1/messages", config.APIKeyScopeChat),
+		Entry(nil, "/chat/completions", config.APIKeyScopeChat),
+		Entry(nil, "/chat/phi-2", config.APIKeyScopeChat),
+		Entry(nil, "/chat/attachments/c1/a1", config.APIKeyScopeChat),
+		Entry(nil, "/v1/assistants/asst_1/files", config.APIKeyScopeChat),
+		Entry(nil, "/v1/threads/thread_1/runs", config.APIKeyScopeChat),
+		Entry(nil, "/threads", config.APIKeyScopeChat),
+		Entry(nil, "/v1/responses/resp_1/input_items", config.APIKeyScopeChat),
+		Entry(nil, "/assistants", config.APIKeyScopeChat),
+		Entry(nil, "/v1beta/models/gemini:generateContent", config.APIKeyScopeChat),
+		Entry(nil, "/memories/m1", config.APIKeyScopeChat),
+		Entry(nil, "/v1/completions", config.APIKeyScopeCompletion),
+		Entry(nil, "/completions", config.APIKeyScopeCompletion),
+		Entry(nil, "/v1/edits", config.APIKeyScopeCompletion),
+		Entry(nil, "/edits", config.APIKeyScopeCompletion),
+		Entry(nil, "/v1/translate", config.APIKeyScopeCompletion),
+		Entry(nil, "/v1/moderations", config.APIKeyScopeCompletion),
+		Entry(nil, "/v1/engines/phi-2/completions", config.APIKeyScopeCompletion),
+		Entry(nil, "/v1/engines/bert/embeddings", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/v1/embeddings", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/embeddings", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/embed", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/info", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/stores/find", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/v1/vector_stores/vs_1/search", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/vector_stores/vs_1/search", config.APIKeyScopeEmbeddings),
+		Entry(nil, "/v1/images/generations", config.APIKeyScopeImages),
+		Entry(nil, "/image/presets/sd/portrait", config.APIKeyScopeImages),
+		Entry(nil, "/text2image/sd", config.APIKeyScopeImages),
+		Entry(nil, "/v1/audio/speech", config.APIKeyScopeAudio),
+		Entry(nil, "/v1/audio/transcriptions", config.APIKeyScopeAudio),
+		Entry(nil, "/v1/realtime", config.APIKeyScopeAudio),
+		Entry(nil, "/v1/text-to-speech/voice-1", config.APIKeyScopeAudio),
+		Entry(nil, "/v1/sound-generation", config.APIKeyScopeAudio),
+		Entry(nil, "/tts", config.APIKeyScopeAudio),
+		Entry(nil, "/tts/piper", config.APIKeyScopeAudio),
+		Entry(nil, "/talk/", config.APIKeyScopeAudio),
+		Entry(nil, "/v1/rerank", config.APIKeyScopeRerank),
+		Entry(nil, "/rerank", config.APIKeyScopeRerank),
+		Entry(nil, "/v1/files/file-1/content", config.APIKeyScopeFiles),
+		Entry(nil, "/files", config.APIKeyScopeFiles),
+		Entry(nil, "/v1/batches/batch_1/cancel", config.APIKeyScopeFiles),
+		Entry(nil, "/batches", config.APIKeyScopeFiles),
+		Entry(nil, "/models/apply", config.APIKeyScopeGallery),
+		Entry(nil, "/models/delete/phi-2", config.APIKeyScopeGallery),
+		Entry(nil, "/models/available", config.APIKeyScopeGallery),
+		Entry(nil, "/models/galleries/health", config.APIKeyScopeGallery),
+		Entry(nil, "/models/galleries/localai/errors", config.APIKeyScopeGallery),
+		Entry(nil, "/models/jobs/uuid-1", config.APIKeyScopeGallery),
+		Entry(nil, "/models/import-local", config.APIKeyScopeGallery),
+		Entry(nil, "/models/recommendations", config.APIKeyScopeGallery),
+		Entry(nil, "/browse/install/model/phi-2", config.APIKeyScopeGallery),
+		Entry(nil, "/backend/shutdown", config.APIKeyScopeAdmin),
+		Entry(nil, "/diagnostics/goroutines", config.APIKeyScopeAdmin),
+		Entry(nil, "/jobs/scheduled/nightly/run", config.APIKeyScopeAdmin),
+		Entry(nil, "/faults/f1", config.APIKeyScopeAdmin),
+		Entry(nil, "/datasets/export", config.APIKeyScopeAdmin),
+		Entry(nil, "/variables/style-guide", config.APIKeyScopeAdmin),
+		Entry(nil, "/api/p2p/token", config.APIKeyScopeAdmin),
+		Entry(nil, "/p2p/ui/workers", config.APIKeyScopeAdmin),
+		Entry(nil, "/system", config.APIKeyScopeAdmin),
+		Entry(nil, "/metrics", config.APIKeyScopeAdmin),
+		Entry(nil, "/events", config.APIKeyScopeAdmin),
+	)
+
+	DescribeTable("requires the admin scope for the endpoints not listed",
+		func(path string) {
+			Expect(requiredScope(path)).To(Equal(config.APIKeyScopeAdmin))
+		},
+		Entry(nil, "/v1/new-endpoint"),
+		Entry(nil, "/models/new-action"),
+		Entry(nil, "/version/details"),
+		Entry(nil, "/v1/modelsx"),
+		Entry(nil, "/v1/grammars/json"),
+	)
+})
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// startupRetryAfter is the delay, in seconds, the clients are asked to wait while LocalAI starts
+const startupRetryAfter = 10
+
+// StartupProbes answers the health checks on address while LocalAI starts, downloading the models and extracting
+// the backend assets: /healthz tells that the process is alive, while /readyz and the other requests are answered
+// 503. They are served over TLS when tlsConfig is set, like the API. The returned function stops it, to hand the
+// address over to the API
+func StartupProbes(address string, tlsConfig *tls.Config) (func() error, error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(startupRetryAfter))
+		if c.Path() == "/readyz" {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(schema.ReadyzResponse{Status: "starting"})
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"message": "LocalAI is starting"})
+	})
+
+	go func() {
+		if err := app.Listener(ln); err != nil {
+			log.Error().Err(err).Msg("the startup probes stopped")
+		}
+	}()
+	return app.Shutdown, nil
+}
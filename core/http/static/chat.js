@@ -39,36 +39,129 @@ function submitSystemPrompt(event) {
   document.getElementById("systemPrompt").blur();
 }
 
-var image = "";
+// the attachments of a conversation are stored in the upload path under its id
+function newConversationId() {
+  if (window.crypto && crypto.randomUUID) {
+    return crypto.randomUUID();
+  }
+  return Date.now().toString(36) + "-" + Math.random().toString(36).substring(2);
+}
 
-function submitPrompt(event) {
-  event.preventDefault();
+var conversationId = newConversationId();
 
-  const input = document.getElementById("input").value;
-  Alpine.store("chat").add("user", input, image);
-  document.getElementById("input").value = "";
+function modelsOf(className) {
+  return Array.from(document.getElementsByClassName(className)).map((el) => el.value);
+}
+
+function clearChat() {
   const key = localStorage.getItem("key");
-  const systemPrompt = localStorage.getItem("system_prompt");
+  fetch(`/chat/attachments/${conversationId}`, {
+    method: "DELETE",
+    headers: { Authorization: `Bearer ${key}` },
+  });
+  conversationId = newConversationId();
+  Alpine.store("chat").clear();
+  Alpine.store("attachments").clear();
+}
 
-  promptGPT(systemPrompt, key, input);
+function readAsDataURL(file) {
+  return new Promise((resolve, reject) => {
+    const FR = new FileReader();
+    FR.addEventListener("load", (evt) => resolve(evt.target.result));
+    FR.addEventListener("error", () => reject(new Error(`Failed to read ${file.name}`)));
+    FR.readAsDataURL(file);
+  });
 }
 
-function readInputImage() {
+function readInputAttachments() {
+  if (!this.files) return;
+
+  for (const file of this.files) {
+    const type = file.type.split("/")[0];
+    if (type !== "image" && type !== "audio") continue;
+    Alpine.store("attachments").add({
+      file: file,
+      name: file.name,
+      type: type,
+      url: URL.createObjectURL(file),
+    });
+  }
+  this.value = null;
+}
 
-  if (!this.files || !this.files[0]) return;
+async function uploadAttachment(key, attachment) {
+  const body = new FormData();
+  body.append("file", attachment.file);
+  const response = await fetch(`/chat/attachments/${conversationId}`, {
+    method: "POST",
+    headers: { Authorization: `Bearer ${key}` },
+    body: body,
+  });
+  if (!response.ok) {
+    throw new Error(`POST /chat/attachments ${response.status}`);
+  }
+  return response.json();
+}
 
-  const FR = new FileReader();
+// audio attachments are transcribed, and the transcript is added to the message
+async function transcribeAttachment(key, attachment) {
+  const models = modelsOf("transcription-model");
+  if (models.length === 0) {
+    throw new Error("no model to transcribe the audio is installed");
+  }
 
-  FR.addEventListener("load", function(evt) {
-    image = evt.target.result;
+  const body = new FormData();
+  body.append("file", attachment.file);
+  body.append("model", models[0]);
+  const response = await fetch("/v1/audio/transcriptions", {
+    method: "POST",
+    headers: { Authorization: `Bearer ${key}` },
+    body: body,
   });
+  if (!response.ok) {
+    throw new Error(`POST /v1/audio/transcriptions ${response.status}`);
+  }
+  const result = await response.json();
+  return result.text.trim();
+}
+
+async function submitPrompt(event) {
+  event.preventDefault();
+
+  let input = document.getElementById("input").value;
+  document.getElementById("input").value = "";
+  const key = localStorage.getItem("key");
+  const systemPrompt = localStorage.getItem("system_prompt");
 
-  FR.readAsDataURL(this.files[0]);
+  const attachments = Alpine.store("attachments").take();
+  const images = [];
+  const audios = [];
+  try {
+    for (const attachment of attachments) {
+      await uploadAttachment(key, attachment);
+      if (attachment.type === "image") {
+        images.push(await readAsDataURL(attachment.file));
+      } else {
+        audios.push(attachment.url);
+        input += `\n\n[Transcript of ${attachment.name}]: ${await transcribeAttachment(key, attachment)}`;
+      }
+    }
+  } catch (error) {
+    Alpine.store("chat").add(
+      "assistant",
+      `<span class='error'>Error: ${error.message}</span>`,
+    );
+    return;
+  }
+
+  Alpine.store("chat").add("user", input, images, audios);
+
+  promptGPT(systemPrompt, key, input);
 }
 
 
   async function promptGPT(systemPrompt, key, input) {
-    const model = document.getElementById("chat-model").value;
+    let model = document.getElementById("chat-model").value;
     // Set class "loader" to the element with "loader" id
     //document.getElementById("loader").classList.add("loader");
     // Make the "loader" visible
@@ -87,8 +180,10 @@ function readInputImage() {
     }
 
     // loop all messages, and check if there are images. If there are, we need to change the content field
+    let hasImages = false;
     messages.forEach((message) => {
-      if (message.image) {
+      if (message.images && message.images.length > 0) {
+        hasImages = true;
         // The content field now becomes an array
         message.content = [
           {
@@ -96,51 +191,26 @@ function readInputImage() {
             "text": message.content
           }
         ]
-        message.content.push(
-          {
-            "type": "image_url",
-            "image_url": {
-              "url": message.image,
+        message.images.forEach((image) => {
+          message.content.push(
+            {
+              "type": "image_url",
+              "image_url": {
+                "url": image,
+              }
             }
-          }
-        );
-
-        // remove the image field
-        delete message.image;
+          );
+        });
       }
+      // remove the images field
+      delete message.images;
     });
 
-       // reset the form and the image
-       image = "";
-       document.getElementById("input_image").value = null;
-       document.getElementById("fileName").innerHTML = "";
-
-    // if (image) {
-    //   // take the last element content's and add the image
-    //   last_message = messages[messages.length - 1]
-    //   // The content field now becomes an array
-    //   last_message.content = [
-    //     {
-    //       "type": "text",
-    //       "text": last_message.content
-    //     }
-    //    ]
-    //   last_message.content.push(
-    //     {
-    //       "type": "image_url",
-    //       "image_url": {
-    //         "url": image,
-    //       }
-    //     }
-    //   );
-    //   // and we replace it in the messages array
-    //   messages[messages.length - 1] = last_message
-
-    //   // reset the form and the image
-    //   image = "";
-    //   document.getElementById("input_image").value = null;
-    //   document.getElementById("fileName").innerHTML = "";
-    // }
+    // conversations with images are routed to a vision model, when the selected one isn't
+    const visionModels = modelsOf("vision-model");
+    if (hasImages && visionModels.length > 0 && !visionModels.includes(model)) {
+      model = visionModels[0];
+    }
 
     // Source: https://stackoverflow.com/a/75751803/11386095
     const response = await fetch("/v1/chat/completions", {
@@ -179,7 +249,7 @@ function readInputImage() {
     // Function to add content to the chat and handle DOM updates efficiently
     const addToChat = (token) => {
       const chatStore = Alpine.store("chat");
-      chatStore.add("assistant", token);
+      chatStore.add("assistant", token, [], [], model);
       // Efficiently scroll into view without triggering multiple reflows
       const messages = document.getElementById('messages');
       messages.scrollTop = messages.scrollHeight;
@@ -259,7 +329,7 @@ function readInputImage() {
 
   document.getElementById("prompt").addEventListener("submit", submitPrompt);
   document.getElementById("input").focus();
-  document.getElementById("input_image").addEventListener("change", readInputImage);
+  document.getElementById("input_attachments").addEventListener("change", readInputAttachments);
 
   storeKey = localStorage.getItem("key");
   if (storeKey) {
@@ -41,28 +41,56 @@ function genImage(event) {
   promptDallE(key, input);
 
 }
-  
-async function promptDallE(key, input) {
-  document.getElementById("loader").style.display = "block";
-  document.getElementById("input").value = "";
-  document.getElementById("input").disabled = true;
 
-  const model = document.getElementById("image-model").value;
+function imageRequest(model, prompt, preset) {
+  const body = {
+    model: model,
+    step: 10,
+    prompt: prompt,
+    n: 1,
+    size: "512x512",
+  };
+  if (!preset) {
+    return body;
+  }
+  if (preset.step) {
+    body.step = preset.step;
+  }
+  if (preset.mode) {
+    body.mode = preset.mode;
+  }
+  if (preset.size) {
+    body.size = preset.size;
+  }
+  if (preset.seed !== undefined && preset.seed !== null) {
+    body.seed = preset.seed;
+  }
+  if (preset.negative_prompt) {
+    // negative prompts are separated by "|"
+    body.prompt = prompt + "|" + preset.negative_prompt;
+  }
+  return body;
+}
+
+async function generate(key, body) {
   const response = await fetch("/v1/images/generations", {
     method: "POST",
     headers: {
       Authorization: `Bearer ${key}`,
       "Content-Type": "application/json",
     },
-    body: JSON.stringify({
-      model: model,
-      steps: 10,
-      prompt: input,
-      n: 1,
-      size: "512x512",
-    }),
+    body: JSON.stringify(body),
   });
-  const json = await response.json();
+  return response.json();
+}
+
+async function promptDallE(key, input) {
+  document.getElementById("loader").style.display = "block";
+  document.getElementById("input").value = "";
+  document.getElementById("input").disabled = true;
+
+  const model = document.getElementById("image-model").value;
+  const json = await generate(key, imageRequest(model, input, null));
   if (json.error) {
     // Display error if there is one
     var div = document.getElementById('result');  // Get the div by its ID
@@ -84,10 +112,129 @@ async function promptDallE(key, input) {
   document.getElementById("input").focus();
 }
 
+let presets = [];
+
+async function loadPresets() {
+  const model = document.getElementById("image-model").value;
+  const key = localStorage.getItem("key");
+  const response = await fetch("/image/presets?model=" + encodeURIComponent(model), {
+    headers: { Authorization: `Bearer ${key}` },
+  });
+  presets = await response.json();
+
+  const container = document.getElementById("presets");
+  container.innerHTML = "";
+  presets.forEach((preset, i) => {
+    const label = document.createElement("label");
+    label.className = "flex items-center gap-1 bg-gray-700 rounded px-2 py-1 text-sm";
+    label.title = `steps: ${preset.step || "default"}, size: ${preset.size || "512x512"}, sampler: ${preset.mode || 0}`;
+
+    const checkbox = document.createElement("input");
+    checkbox.type = "checkbox";
+    checkbox.value = i;
+    checkbox.className = "preset-select";
+    label.appendChild(checkbox);
+    label.appendChild(document.createTextNode(preset.name));
+
+    const remove = document.createElement("button");
+    remove.type = "button";
+    remove.title = "Delete preset";
+    remove.innerHTML = '<i class="fas fa-trash-alt"></i>';
+    remove.addEventListener("click", () => deletePreset(preset));
+    label.appendChild(remove);
+
+    container.appendChild(label);
+  });
+}
+
+async function savePreset(event) {
+  event.preventDefault();
+  const key = localStorage.getItem("key");
+  const preset = {
+    name: document.getElementById("preset-name").value,
+    model: document.getElementById("image-model").value,
+    mode: parseInt(document.getElementById("preset-mode").value) || 0,
+    step: parseInt(document.getElementById("preset-step").value) || 0,
+    size: document.getElementById("preset-size").value,
+    negative_prompt: document.getElementById("preset-negative").value,
+  };
+  await fetch("/image/presets", {
+    method: "POST",
+    headers: {
+      Authorization: `Bearer ${key}`,
+      "Content-Type": "application/json",
+    },
+    body: JSON.stringify(preset),
+  });
+  document.getElementById("preset-form").reset();
+  loadPresets();
+}
+
+async function deletePreset(preset) {
+  const key = localStorage.getItem("key");
+  await fetch("/image/presets/" + encodeURIComponent(preset.model) + "/" + encodeURIComponent(preset.name), {
+    method: "DELETE",
+    headers: { Authorization: `Bearer ${key}` },
+  });
+  loadPresets();
+}
+
+// generateGrid generates the same prompt with every selected preset, using the same seed
+async function generateGrid() {
+  const input = document.getElementById("input").value;
+  if (!input) {
+    document.getElementById("input").focus();
+    return;
+  }
+  const selected = Array.from(document.querySelectorAll(".preset-select:checked")).map((c) => presets[c.value]);
+  if (selected.length === 0) {
+    return;
+  }
+
+  const key = localStorage.getItem("key");
+  const model = document.getElementById("image-model").value;
+  const seed = Math.floor(Math.random() * 2147483647);
+  const grid = document.getElementById("grid");
+  grid.innerHTML = "";
+  document.getElementById("loader").style.display = "block";
+
+  for (const preset of selected) {
+    const cell = document.createElement("div");
+    cell.className = "bg-gray-800 rounded p-2 text-center";
+    const title = document.createElement("p");
+    title.className = "mb-2 font-semibold";
+    title.textContent = preset.name;
+    cell.appendChild(title);
+    grid.appendChild(cell);
+
+    const body = imageRequest(model, input, preset);
+    if (body.seed === undefined) {
+      body.seed = seed;
+    }
+    const json = await generate(key, body);
+    if (json.error) {
+      const p = document.createElement("p");
+      p.style.color = "red";
+      p.textContent = json.error.message;
+      cell.appendChild(p);
+      continue;
+    }
+    const img = document.createElement("img");
+    img.src = json.data[0].url;
+    img.alt = preset.name;
+    cell.appendChild(img);
+  }
+
+  document.getElementById("loader").style.display = "none";
+}
+
 document.getElementById("key").addEventListener("submit", submitKey);
 document.getElementById("input").focus();
 document.getElementById("genimage").addEventListener("submit", genImage);
 document.getElementById("loader").style.display = "none";
+document.getElementById("preset-form").addEventListener("submit", savePreset);
+document.getElementById("generate-grid").addEventListener("click", generateGrid);
+loadPresets();
 
 const storeKey = localStorage.getItem("key");
 if (storeKey) {
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+)
+
+// streamPacing returns the tokens per second the streamed response of the request is paced to, 0 for no pacing: the
+// rate of the header, which the requests of the keys of api_keys.json can't raise above the rate of their key, or
+// else the rate of the key. The admin keys, and all the requests without authentication, may use any rate
+func streamPacing(c *fiber.Ctx) (int, error) {
+	rate := 0
+	if entry := fiberContext.APIKeyEntryFromContext(c); entry != nil {
+		rate = entry.TokensPerSecond
+	}
+	header := c.Get(fiberContext.TokensPerSecondHeader)
+	if header == "" {
+		return rate, nil
+	}
+	requested, err := strconv.Atoi(header)
+	if err != nil || requested < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid %s %q, expected a number of tokens per second", fiberContext.TokensPerSecondHeader, header))
+	}
+	if fiberContext.IsAdmin(c) || rate == 0 {
+		return requested, nil
+	}
+	if requested == 0 {
+		return rate, nil
+	}
+	return min(requested, rate), nil
+}
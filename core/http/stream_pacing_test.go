@@ -0,0 +1,87 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream pacing", func() {
+	pacing := func(entry *config.APIKey, admin bool, header string) (int, int) {
+		rate := 0
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			if entry != nil {
+				c.Locals(fiberContext.APIKeyEntryKey, entry)
+			}
+			c.Locals(fiberContext.AdminKey, admin)
+			var err error
+			rate, err = streamPacing(c)
+			return err
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(fiberContext.TokensPerSecondHeader, header)
+		}
+		resp, err := app.Test(req)
+		Expect(err).ToNot(HaveOccurred())
+		return resp.StatusCode, rate
+	}
+
+	DescribeTable("paces the streams to the rate of the key or of the header",
+		func(entry *config.APIKey, admin bool, header string, status, rate int) {
+			gotStatus, gotRate := pacing(entry, admin, header)
+			Expect(gotStatus).To(Equal(status))
+			if status == fiber.StatusOK {
+				Expect(gotRate).To(Equal(rate))
+			}
+		},
+		Entry("no pacing", nil, false, "", fiber.StatusOK, 0),
+		Entry("requested without key", nil, false, "20", fiber.StatusOK, 20),
+		Entry("rate of the key", &config.APIKey{TokensPerSecond: 10}, false, "", fiber.StatusOK, 10),
+		Entry("slower than the key", &config.APIKey{TokensPerSecond: 10}, false, "5", fiber.StatusOK, 5),
+		Entry("faster than the key", &config.APIKey{TokensPerSecond: 10}, false, "20", fiber.StatusOK, 10),
+		Entry("unpaced by a paced key", &config.APIKey{TokensPerSecond: 10}, false, "0", fiber.StatusOK, 10),
+		Entry("faster than the key by an admin", &config.APIKey{TokensPerSecond: 10}, true, "20", fiber.StatusOK, 20),
+		Entry("unpaced by an admin", &config.APIKey{TokensPerSecond: 10}, true, "0", fiber.StatusOK, 0),
+		Entry("key without pacing", &config.APIKey{}, false, "20", fiber.StatusOK, 20),
+		Entry("invalid", nil, false, "fast", fiber.StatusBadRequest, 0),
+		Entry("negative", nil, false, "-1", fiber.StatusBadRequest, 0),
+	)
+
+	It("sends the events of the paced streams once per interval", func() {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			c.Locals(fiberContext.StreamPacingKey, 20)
+			c.Context().SetBodyStreamWriter(fiberContext.StreamWriter(c, func(w *bufio.Writer) {
+				for i := 0; i < 5; i++ {
+					fmt.Fprintf(w, "data: %d\n\n", i)
+					w.Flush()
+				}
+			}))
+			return nil
+		})
+
+		start := time.Now()
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil), -1)
+		Expect(err).ToNot(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).ToNot(HaveOccurred())
+
+		// the first event is sent at once, the next ones after an interval each
+		Expect(time.Since(start)).To(BeNumerically(">=", 4*time.Second/20))
+		Expect(strings.Count(string(body), "data: ")).To(Equal(5))
+		Expect(string(body)).To(HavePrefix("data: 0\n\n"))
+	})
+})
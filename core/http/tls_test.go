@@ -0,0 +1,83 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// writeCertificate writes a self-signed certificate and its key in dir
+func writeCertificate(dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	cert, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	Expect(os.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).To(Succeed())
+	Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)).To(Succeed())
+	return cert, keyFile
+}
+
+var _ = Describe("TLS", func() {
+	var dir, cert, key string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		cert, key = writeCertificate(dir, "server")
+	})
+
+	It("serves HTTP without certificate", func() {
+		tlsConfig, err := TLSConfig("", "", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tlsConfig).To(BeNil())
+	})
+
+	It("serves HTTPS with the certificate", func() {
+		tlsConfig, err := TLSConfig(cert, key, "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tlsConfig.Certificates).To(HaveLen(1))
+		Expect(tlsConfig.ClientAuth).To(Equal(tls.NoClientCert))
+	})
+
+	It("requires the client certificates signed by the client CA", func() {
+		clientCA, _ := writeCertificate(dir, "clients")
+		tlsConfig, err := TLSConfig(cert, key, clientCA)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tlsConfig.ClientAuth).To(Equal(tls.RequireAndVerifyClientCert))
+		Expect(tlsConfig.ClientCAs).ToNot(BeNil())
+	})
+
+	It("refuses the incomplete configurations", func() {
+		_, err := TLSConfig(cert, "", "")
+		Expect(err).To(HaveOccurred())
+		_, err = TLSConfig("", "", cert)
+		Expect(err).To(HaveOccurred())
+		_, err = TLSConfig(cert, key, key)
+		Expect(err).To(HaveOccurred())
+	})
+})
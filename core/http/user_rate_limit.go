@@ -0,0 +1,26 @@
+package http
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestUser returns the end user of a request, from the user field of its JSON body or of its form, empty when
+// it has none
+func requestUser(c *fiber.Ctx) string {
+	if c.Method() != fiber.MethodPost {
+		return ""
+	}
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		return c.FormValue("user")
+	}
+	request := struct {
+		User string `json:"user"`
+	}{}
+	if json.Unmarshal(c.Body(), &request) != nil {
+		return ""
+	}
+	return request.User
+}
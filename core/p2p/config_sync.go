@@ -0,0 +1,148 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+const ConfigSyncID = "config_sync"
+
+// ConfigBundle is the configuration published by the leader node to the other nodes of the network
+type ConfigBundle struct {
+	Created time.Time `json:"created"`
+	// Models are the model configuration files, by file name
+	Models map[string]string `json:"models"`
+	// APIKeys are the entries of api_keys.json, the keys as strings or as objects with their metadata. They are
+	// written as they are by the other nodes, the entries holding a hash don't reveal the key
+	APIKeys json.RawMessage `json:"api_keys"`
+}
+
+// Digest identifies the content of the bundle, regardless of when it was created
+func (b ConfigBundle) Digest() string {
+	dat, _ := json.Marshal(struct {
+		Models  map[string]string
+		APIKeys json.RawMessage
+	}{b.Models, b.APIKeys})
+	hash := sha256.Sum256(dat)
+	return hex.EncodeToString(hash[:])
+}
+
+// SignedConfigBundle is a ConfigBundle signed by the leader, as shared in the ledger
+type SignedConfigBundle struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+func SignConfigBundle(b ConfigBundle, key ed25519.PrivateKey) (SignedConfigBundle, error) {
+	payload, err := json.Marshal(b)
+	if err != nil {
+		return SignedConfigBundle{}, err
+	}
+	return SignedConfigBundle{
+		Payload:   payload,
+		Signature: ed25519.Sign(key, payload),
+	}, nil
+}
+
+// Verify returns the bundle, if it was signed by the key of the leader
+func (s SignedConfigBundle) Verify(key ed25519.PublicKey) (ConfigBundle, error) {
+	if !ed25519.Verify(key, s.Payload, s.Signature) {
+		return ConfigBundle{}, fmt.Errorf("invalid signature")
+	}
+	b := ConfigBundle{}
+	err := json.Unmarshal(s.Payload, &b)
+	return b, err
+}
+
+// GenerateConfigSyncKeys returns a new key pair, base64 encoded: the private key signs the bundles of the leader,
+// the public key is trusted by the other nodes
+func GenerateConfigSyncKeys() (string, string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+func ParseConfigSyncPrivateKey(s string) (ed25519.PrivateKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid config sync private key")
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+func ParseConfigSyncPublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid config sync public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// isModelConfigFile returns whether name is a model configuration file, as read by the config loader
+func isModelConfigFile(name string) bool {
+	return (strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) && !strings.HasPrefix(name, ".")
+}
+
+// CollectConfigBundle returns the model configuration files in modelsPath and the entries of api_keys.json, to be
+// published
+func CollectConfigBundle(modelsPath string, apiKeys json.RawMessage) (ConfigBundle, error) {
+	entries, err := os.ReadDir(modelsPath)
+	if err != nil {
+		return ConfigBundle{}, err
+	}
+
+	b := ConfigBundle{
+		Created: time.Now().UTC(),
+		Models:  map[string]string{},
+		APIKeys: apiKeys,
+	}
+	for _, e := range entries {
+		if e.IsDir() || !isModelConfigFile(e.Name()) {
+			continue
+		}
+		dat, err := os.ReadFile(filepath.Join(modelsPath, e.Name()))
+		if err != nil {
+			return ConfigBundle{}, err
+		}
+		b.Models[e.Name()] = string(dat)
+	}
+	return b, nil
+}
+
+// ApplyConfigBundle writes the model configuration files to modelsPath, and the API keys to the api_keys.json
+// file of the dynamic configuration directory. The model configurations missing from the bundle are kept.
+func ApplyConfigBundle(b ConfigBundle, modelsPath, dynamicConfigsDir string) error {
+	for name, content := range b.Models {
+		if name != utils.SanitizeFileName(name) || !isModelConfigFile(name) {
+			return fmt.Errorf("invalid model configuration file name %q", name)
+		}
+		if err := os.WriteFile(filepath.Join(modelsPath, name), []byte(content), 0600); err != nil {
+			return err
+		}
+	}
+
+	if dynamicConfigsDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dynamicConfigsDir, 0750); err != nil {
+		return err
+	}
+	apiKeys := b.APIKeys
+	if len(apiKeys) == 0 {
+		apiKeys = json.RawMessage("[]")
+	}
+	return os.WriteFile(filepath.Join(dynamicConfigsDir, "api_keys.json"), apiKeys, 0600)
+}
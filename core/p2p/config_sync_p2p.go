@@ -0,0 +1,100 @@
+//go:build p2p
+// +build p2p
+
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"time"
+
+	"github.com/mudler/edgevpn/pkg/node"
+	"github.com/rs/zerolog/log"
+)
+
+const configSyncInterval = 20 * time.Second
+
+// PublishConfig announces in the ledger the configuration returned by collect, signed with the key of the leader
+func PublishConfig(ctx context.Context, n *node.Node, networkID string, key ed25519.PrivateKey, collect func() (ConfigBundle, error)) error {
+	ledger, err := n.Ledger()
+	if err != nil {
+		return err
+	}
+
+	ledger.Announce(
+		ctx,
+		configSyncInterval,
+		func() {
+			b, err := collect()
+			if err != nil {
+				log.Error().Err(err).Msg("failed collecting the configuration to publish")
+				return
+			}
+			signed, err := SignConfigBundle(b, key)
+			if err != nil {
+				log.Error().Err(err).Msg("failed signing the configuration to publish")
+				return
+			}
+			ledger.Add(NetworkID(networkID, ConfigSyncID), map[string]interface{}{
+				n.Host().ID().String(): signed,
+			})
+		},
+	)
+	return nil
+}
+
+// FollowConfig applies the configurations published in the ledger and signed by the key of the leader,
+// each time a newer one with a different content is published
+func FollowConfig(ctx context.Context, n *node.Node, networkID string, key ed25519.PublicKey, apply func(ConfigBundle) error) error {
+	ledger, err := n.Ledger()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		var lastCreated time.Time
+		lastDigest := ""
+
+		ticker := time.NewTicker(configSyncInterval / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var latest *ConfigBundle
+			for peer, v := range ledger.LastBlock().Storage[NetworkID(networkID, ConfigSyncID)] {
+				signed := SignedConfigBundle{}
+				if err := v.Unmarshal(&signed); err != nil {
+					continue
+				}
+				b, err := signed.Verify(key)
+				if err != nil {
+					log.Warn().Str("peer", peer).Msg("ignoring a configuration not signed by the trusted key")
+					continue
+				}
+				if latest == nil || b.Created.After(latest.Created) {
+					latest = &b
+				}
+			}
+			if latest == nil || !latest.Created.After(lastCreated) {
+				continue
+			}
+			lastCreated = latest.Created
+
+			digest := latest.Digest()
+			if digest == lastDigest {
+				continue
+			}
+			if err := apply(*latest); err != nil {
+				log.Error().Err(err).Msg("failed applying the configuration published by the leader")
+				continue
+			}
+			lastDigest = digest
+			log.Info().Int("models", len(latest.Models)).Msg("applied the configuration published by the leader")
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,102 @@
+package p2p_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigBundleRoundTrip(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret"))
+	keys, err := config.ParseAPIKeys([]byte(`["plain", {
+		"hash": "sha256:` + hex.EncodeToString(sum[:]) + `",
+		"name": "ci",
+		"scopes": ["chat", "embeddings"],
+		"expires_at": "2030-01-01T00:00:00Z",
+		"models": ["phi-2"],
+		"rate_limit": 10,
+		"priority": "low",
+		"tokens_per_second": 20
+	}, {"key": "app", "name": "app", "scopes": ["admin"]}]`))
+	require.NoError(t, err)
+
+	leaderModels := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(leaderModels, "phi-2.yaml"), []byte("name: phi-2\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(leaderModels, "phi-2.gguf"), []byte("weights"), 0600))
+
+	apiKeys, err := json.Marshal(keys)
+	require.NoError(t, err)
+	bundle, err := p2p.CollectConfigBundle(leaderModels, apiKeys)
+	require.NoError(t, err)
+
+	pub, priv, err := p2p.GenerateConfigSyncKeys()
+	require.NoError(t, err)
+	privKey, err := p2p.ParseConfigSyncPrivateKey(priv)
+	require.NoError(t, err)
+	pubKey, err := p2p.ParseConfigSyncPublicKey(pub)
+	require.NoError(t, err)
+
+	signed, err := p2p.SignConfigBundle(bundle, privKey)
+	require.NoError(t, err)
+	received, err := signed.Verify(pubKey)
+	require.NoError(t, err)
+	assert.Equal(t, bundle.Digest(), received.Digest())
+
+	followerModels, dynamicConfigsDir := t.TempDir(), t.TempDir()
+	require.NoError(t, p2p.ApplyConfigBundle(received, followerModels, dynamicConfigsDir))
+
+	dat, err := os.ReadFile(filepath.Join(followerModels, "phi-2.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "name: phi-2\n", string(dat))
+	assert.NoFileExists(t, filepath.Join(followerModels, "phi-2.gguf"))
+
+	// the followers load the same keys, with their metadata, and the hash-only entries don't reveal the key
+	dat, err = os.ReadFile(filepath.Join(dynamicConfigsDir, "api_keys.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(dat), "secret")
+	followerKeys, err := config.ParseAPIKeys(dat)
+	require.NoError(t, err)
+	assert.Equal(t, keys, followerKeys)
+
+	store := &config.APIKeyStore{}
+	store.Set(followerKeys)
+	entry, ok := store.Lookup("secret")
+	require.True(t, ok)
+	assert.Equal(t, "ci", entry.ID())
+	assert.Equal(t, []string{"phi-2"}, entry.Models)
+}
+
+func TestConfigBundleTampered(t *testing.T) {
+	_, priv, err := p2p.GenerateConfigSyncKeys()
+	require.NoError(t, err)
+	privKey, err := p2p.ParseConfigSyncPrivateKey(priv)
+	require.NoError(t, err)
+	other, _, err := p2p.GenerateConfigSyncKeys()
+	require.NoError(t, err)
+	otherKey, err := p2p.ParseConfigSyncPublicKey(other)
+	require.NoError(t, err)
+
+	signed, err := p2p.SignConfigBundle(p2p.ConfigBundle{APIKeys: json.RawMessage(`["plain"]`)}, privKey)
+	require.NoError(t, err)
+	_, err = signed.Verify(otherKey)
+	assert.Error(t, err)
+}
+
+func TestApplyConfigBundleWithoutKeys(t *testing.T) {
+	// the bundles of the leaders without keys clear the keys of the followers
+	dynamicConfigsDir := t.TempDir()
+	require.NoError(t, p2p.ApplyConfigBundle(p2p.ConfigBundle{}, t.TempDir(), dynamicConfigsDir))
+	dat, err := os.ReadFile(filepath.Join(dynamicConfigsDir, "api_keys.json"))
+	require.NoError(t, err)
+	keys, err := config.ParseAPIKeys(dat)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
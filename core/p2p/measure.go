@@ -0,0 +1,127 @@
+//go:build p2p
+// +build p2p
+
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	lp2pprotocol "github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/mudler/edgevpn/pkg/node"
+	"github.com/mudler/edgevpn/pkg/protocol"
+	"github.com/mudler/edgevpn/pkg/types"
+	zlog "github.com/rs/zerolog/log"
+)
+
+const (
+	bandwidthProtocolID = lp2pprotocol.ID("/localai/bandwidth/1.0.0")
+	bandwidthProbeSize  = 1 << 20
+	measureInterval     = 30 * time.Second
+)
+
+// bandwidthHandler drains the probe sent by measureThroughput and acknowledges it
+func bandwidthHandler(s network.Stream) {
+	defer s.Close()
+	if _, err := io.Copy(io.Discard, io.LimitReader(s, bandwidthProbeSize)); err != nil {
+		s.Reset()
+		return
+	}
+	s.Write([]byte{1})
+}
+
+// servicePeer returns the peer exposing the service of the node
+func servicePeer(n *node.Node, nd NodeData) (peer.ID, error) {
+	ledger, err := n.Ledger()
+	if err != nil {
+		return "", err
+	}
+	v, found := ledger.GetKey(protocol.ServicesLedgerKey, nd.Name)
+	if !found {
+		return "", fmt.Errorf("service %s not found on blockchain", nd.Name)
+	}
+	service := &types.Service{}
+	if err := v.Unmarshal(service); err != nil {
+		return "", err
+	}
+	return peer.Decode(service.PeerID)
+}
+
+func measureRTT(ctx context.Context, n *node.Node, p peer.ID) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	res := <-ping.Ping(ctx, n.Host(), p)
+	return res.RTT, res.Error
+}
+
+// measureThroughput sends a probe to the peer and returns the bytes per second
+func measureThroughput(ctx context.Context, n *node.Node, p peer.ID) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	s, err := n.Host().NewStream(ctx, p, bandwidthProtocolID)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	if _, err := io.Copy(s, bytes.NewReader(make([]byte, bandwidthProbeSize))); err != nil {
+		s.Reset()
+		return 0, err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return 0, err
+	}
+	if _, err := s.Read(make([]byte, 1)); err != nil {
+		return 0, err
+	}
+
+	return bandwidthProbeSize / time.Since(start).Seconds(), nil
+}
+
+// measureNodes periodically measures the latency and the bandwidth of the online nodes of the service
+func measureNodes(ctx context.Context, n *node.Node, servicesID string) {
+	ticker := time.NewTicker(measureInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, nd := range GetAvailableNodes(servicesID) {
+				if !nd.IsOnline() {
+					continue
+				}
+
+				p, err := servicePeer(n, nd)
+				if err != nil {
+					zlog.Debug().Err(err).Str("node", nd.ID).Msg("cannot resolve the node peer")
+					continue
+				}
+
+				rtt, err := measureRTT(ctx, n, p)
+				if err != nil {
+					zlog.Warn().Err(err).Str("node", nd.ID).Msg("node unreachable, it will be used last")
+					SetNodePerformance(servicesID, nd.ID, 0, 0)
+					continue
+				}
+
+				// workers running older versions don't answer the probe, rank them on the latency only
+				throughput, err := measureThroughput(ctx, n, p)
+				if err != nil {
+					zlog.Debug().Err(err).Str("node", nd.ID).Msg("cannot measure the node bandwidth")
+				}
+
+				zlog.Debug().Str("node", nd.ID).Dur("rtt", rtt).Float64("throughput", throughput).Msg("node performance measured")
+				SetNodePerformance(servicesID, nd.ID, rtt, throughput)
+			}
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package p2p
 
 import (
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,6 +18,37 @@ type NodeData struct {
 	TunnelAddress string
 	ServiceID     string
 	LastSeen      time.Time
+
+	// Measured from this node, not announced by the workers
+	RTT        time.Duration `json:",omitempty"`
+	Throughput float64       `json:",omitempty"` // bytes per second
+}
+
+// transferBlockSize is the size of the block of data used to rank the workers
+const transferBlockSize = 64 << 20
+
+// transferTime estimates the time to transfer a block of data to the node.
+// Nodes that were not measured (or failed the measurement) are ranked last.
+func (d NodeData) transferTime() time.Duration {
+	if d.RTT <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	t := d.RTT
+	if d.Throughput > 0 {
+		t += time.Duration(transferBlockSize / d.Throughput * float64(time.Second))
+	}
+	return t
+}
+
+// SortByPerformance orders the nodes by latency and bandwidth, the best ones first
+func SortByPerformance(nodes []NodeData) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		ti, tj := nodes[i].transferTime(), nodes[j].transferTime()
+		if ti == tj {
+			return nodes[i].ID < nodes[j].ID
+		}
+		return ti < tj
+	})
 }
 
 func (d NodeData) IsOnline() bool {
@@ -26,6 +59,23 @@ func (d NodeData) IsOnline() bool {
 
 var mu sync.Mutex
 var nodes = map[string]map[string]NodeData{}
+var onMembership func(node NodeData, joined bool)
+
+// SetMembershipHandler sets the function called when a node joins the network, and when it leaves it
+func SetMembershipHandler(fn func(node NodeData, joined bool)) {
+	mu.Lock()
+	defer mu.Unlock()
+	onMembership = fn
+}
+
+func membershipChanged(node NodeData, joined bool) {
+	mu.Lock()
+	fn := onMembership
+	mu.Unlock()
+	if fn != nil {
+		fn(node, joined)
+	}
+}
 
 func GetAvailableNodes(serviceID string) []NodeData {
 	if serviceID == "" {
@@ -62,5 +112,25 @@ func AddNode(serviceID string, node NodeData) {
 	if nodes[serviceID] == nil {
 		nodes[serviceID] = map[string]NodeData{}
 	}
+	// keep the last measurements
+	if old, exists := nodes[serviceID][node.ID]; exists && node.RTT == 0 {
+		node.RTT, node.Throughput = old.RTT, old.Throughput
+	}
 	nodes[serviceID][node.ID] = node
 }
+
+// SetNodePerformance records the latency and the bandwidth measured for a node.
+// A zero rtt marks the node as unreachable.
+func SetNodePerformance(serviceID, nodeID string, rtt time.Duration, throughput float64) {
+	if serviceID == "" {
+		serviceID = defaultServicesID
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	nd, exists := nodes[serviceID][nodeID]
+	if !exists {
+		return
+	}
+	nd.RTT, nd.Throughput = rtt, throughput
+	nodes[serviceID][nodeID] = nd
+}
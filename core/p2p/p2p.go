@@ -202,6 +202,11 @@ func ServiceDiscoverer(ctx context.Context, n *node.Node, token, servicesID stri
 		}
 	}()
 
+	// The tunnels to the workers are ranked by latency and bandwidth
+	if allocate {
+		go measureNodes(ctx, n, servicesID)
+	}
+
 	return nil
 }
 
@@ -291,6 +296,7 @@ func ensureService(ctx context.Context, n *node.Node, nd *NodeData, sserv string
 			NodeData:   *nd,
 			CancelFunc: cancel,
 		}
+		membershipChanged(*nd, true)
 	} else {
 		// Check if the service is still alive
 		// if not cancel the context
@@ -298,6 +304,7 @@ func ensureService(ctx context.Context, n *node.Node, nd *NodeData, sserv string
 			ndService.CancelFunc()
 			delete(service, nd.Name)
 			zlog.Info().Msgf("Node %s is offline, deleting", nd.ID)
+			membershipChanged(*nd, false)
 		} else if nd.IsOnline() {
 			// update last seen inside service
 			nd.TunnelAddress = ndService.NodeData.TunnelAddress
@@ -337,6 +344,9 @@ func ExposeService(ctx context.Context, host, port, token, servicesID string) (*
 		return n, fmt.Errorf("creating a new node: %w", err)
 	}
 
+	// answer the bandwidth probes of the nodes using this service
+	n.Host().SetStreamHandler(bandwidthProtocolID, bandwidthHandler)
+
 	ledger, err := n.Ledger()
 	if err != nil {
 		return n, fmt.Errorf("creating a new node: %w", err)
@@ -5,6 +5,7 @@ package p2p
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 
 	"github.com/mudler/edgevpn/pkg/node"
@@ -33,3 +34,11 @@ func IsP2PEnabled() bool {
 func NewNode(token string) (*node.Node, error) {
 	return nil, fmt.Errorf("not implemented")
 }
+
+func PublishConfig(ctx context.Context, n *node.Node, networkID string, key ed25519.PrivateKey, collect func() (ConfigBundle, error)) error {
+	return fmt.Errorf("not implemented")
+}
+
+func FollowConfig(ctx context.Context, n *node.Node, networkID string, key ed25519.PublicKey, apply func(ConfigBundle) error) error {
+	return fmt.Errorf("not implemented")
+}
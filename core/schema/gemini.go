@@ -0,0 +1,62 @@
+package schema
+
+// GeminiRequest is the request of the Gemini generateContent and streamGenerateContent APIs
+// https://ai.google.dev/api/generate-content
+type GeminiRequest struct {
+	Contents          []GeminiContent        `json:"contents"`
+	SystemInstruction *GeminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  GeminiGenerationConfig `json:"generationConfig"`
+	// SafetySettings are accepted for compatibility, and ignored
+	SafetySettings []interface{} `json:"safetySettings,omitempty"`
+}
+
+// GeminiContent is a message of the conversation, the role is either "user" or "model"
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is a part of a message: either text or inline data (e.g. an image).
+// Thought is set on the parts holding the reasoning of the model
+type GeminiPart struct {
+	Text       string      `json:"text,omitempty"`
+	Thought    bool        `json:"thought,omitempty"`
+	InlineData *GeminiBlob `json:"inlineData,omitempty"`
+}
+
+// GeminiBlob is base64 encoded data
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type GeminiGenerationConfig struct {
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	CandidateCount   int      `json:"candidateCount,omitempty"`
+	MaxOutputTokens  *int     `json:"maxOutputTokens,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"topP,omitempty"`
+	TopK             *int     `json:"topK,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  float64  `json:"presencePenalty,omitempty"`
+	FrequencyPenalty float64  `json:"frequencyPenalty,omitempty"`
+}
+
+// GeminiResponse is the response of the Gemini generateContent API, and each chunk of streamGenerateContent
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate   `json:"candidates"`
+	UsageMetadata GeminiUsageMetadata `json:"usageMetadata"`
+	ModelVersion  string              `json:"modelVersion,omitempty"`
+}
+
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
@@ -1,7 +1,12 @@
 package schema
 
 import (
+	"time"
+
 	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/xnet"
 	gopsutil "github.com/shirou/gopsutil/v3/process"
 )
 
@@ -15,6 +20,80 @@ type BackendMonitorResponse struct {
 	CPUPercent    float64
 }
 
+// BackendMonitorStatusResponse is the status reported by the backend, with the last sample of its process
+type BackendMonitorStatusResponse struct {
+	*proto.StatusResponse
+	Process *model.ProcessStats `json:"process,omitempty"`
+}
+
+type ReadyzResponse struct {
+	Status          string            `json:"status"`
+	PreloadFailures map[string]string `json:"preload_failures,omitempty"`
+}
+
+// SystemResponse describes the running instance
+type SystemResponse struct {
+	Version     string `json:"version"`
+	PrivacyMode bool   `json:"privacy_mode"`
+	// NetworkAudit holds the outbound connections blocked by the privacy mode
+	NetworkAudit *xnet.Report `json:"network_audit,omitempty"`
+}
+
+// ImagePreset is a named set of image generation parameters for a model
+type ImagePreset struct {
+	Name           string `json:"name"`
+	Model          string `json:"model"`
+	Mode           int    `json:"mode,omitempty"` // Sampling mode, as the `mode` of the image generation request
+	Step           int    `json:"step,omitempty"`
+	Size           string `json:"size,omitempty"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+	Seed           *int   `json:"seed,omitempty"`
+}
+
+// PromptVariable is a named text snippet the templates and the requests reference as {{variable "name"}}
+type PromptVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Memory is a fact learned about a user, recalled in their following conversations
+type Memory struct {
+	ID        string    `json:"id"`
+	User      string    `json:"user"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MemoryRequest struct {
+	User    string `json:"user"`
+	Content string `json:"content"`
+}
+
+// ChatAttachment is a file attached to a message in the chat of the WebUI
+type ChatAttachment struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	Name           string `json:"name"`
+	Type           string `json:"type"` // image or audio
+	ContentType    string `json:"content_type"`
+	Bytes          int64  `json:"bytes"`
+}
+
+// ImportLocalModelRequest registers a model file which is already on the LocalAI host
+type ImportLocalModelRequest struct {
+	Path string `json:"path"`
+	Name string `json:"name,omitempty"`
+	Mode string `json:"mode,omitempty"` // symlink (default), hardlink, copy or move
+}
+
+// DiagnosticsBundle is a zip collected when a backend crashed, to be attached to bug reports
+type DiagnosticsBundle struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Bytes   int64     `json:"bytes"`
+}
+
 type GalleryResponse struct {
 	ID        string `json:"uuid"`
 	StatusURL string `json:"status"`
@@ -27,6 +106,37 @@ type TTSRequest struct {
 	Voice    string `json:"voice" yaml:"voice"` // voice audio file or speaker id
 	Backend  string `json:"backend" yaml:"backend"`
 	Language string `json:"language,omitempty" yaml:"language,omitempty"` // (optional) language to use with TTS model
+	// Stream sends the audio while it's synthesized, as a WAV file of unknown length or as raw PCM16
+	Stream bool `json:"stream,omitempty" yaml:"stream,omitempty"`
+	// ResponseFormat is the format of the streamed audio, "wav" (default) or "pcm"
+	ResponseFormat string `json:"response_format,omitempty" yaml:"response_format,omitempty"`
+}
+
+// TranslationRequest is the request of /v1/translate
+type TranslationRequest struct {
+	// Model is optional, the request is routed to a model translating the language pair when empty
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+	// Input is a text or a list of texts
+	Input interface{} `json:"input" yaml:"input"`
+	// SourceLanguage is the ISO 639-1 code of the language of the input, detected when empty
+	SourceLanguage string `json:"source_language,omitempty" yaml:"source_language,omitempty"`
+	TargetLanguage string `json:"target_language" yaml:"target_language"`
+	// Glossary maps the terms of the source language to their translation
+	Glossary map[string]string `json:"glossary,omitempty" yaml:"glossary,omitempty"`
+}
+
+type Translation struct {
+	Index          int    `json:"index"`
+	Text           string `json:"text"`
+	Model          string `json:"model"`
+	SourceLanguage string `json:"source_language,omitempty"`
+	// DetectedSourceLanguage tells whether the source language was detected rather than given
+	DetectedSourceLanguage bool `json:"detected_source_language,omitempty"`
+}
+
+type TranslationResponse struct {
+	Object string        `json:"object"`
+	Data   []Translation `json:"data"`
 }
 
 type StoresSet struct {
@@ -70,3 +180,162 @@ type P2PNodesResponse struct {
 	Nodes          []p2p.NodeData `json:"nodes" yaml:"nodes"`
 	FederatedNodes []p2p.NodeData `json:"federated_nodes" yaml:"federated_nodes"`
 }
+
+// ScheduledTask is a recurring inference task, defined in the scheduled_tasks.yaml file of the configuration directory
+type ScheduledTask struct {
+	Name string `json:"name" yaml:"name"`
+	// Schedule is a cron expression, e.g. "0 2 * * *", @daily or "@every 1h"
+	Schedule string `json:"schedule" yaml:"schedule"`
+	Model    string `json:"model" yaml:"model"`
+	// Type is chat (default) or embeddings
+	Type   string `json:"type,omitempty" yaml:"type"`
+	Prompt string `json:"prompt,omitempty" yaml:"prompt"`
+	// Input is a file or a directory, each file is processed on its own with the prompt
+	Input  string            `json:"input,omitempty" yaml:"input"`
+	Output ScheduledTaskSink `json:"output" yaml:"output"`
+}
+
+// ScheduledTaskSink is where the results of the runs of a task are written
+type ScheduledTaskSink struct {
+	// Dir receives a JSON file for each run
+	Dir string `json:"dir,omitempty" yaml:"dir"`
+	// Webhook receives each run as a JSON POST
+	Webhook string `json:"webhook,omitempty" yaml:"webhook"`
+}
+
+type ScheduledTaskResult struct {
+	Input     string    `json:"input,omitempty"`
+	Output    string    `json:"output,omitempty"`
+	Embedding []float32 `json:"embedding,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type ScheduledTaskRun struct {
+	ID       string                `json:"id"`
+	Task     string                `json:"task"`
+	Status   string                `json:"status"` // running, succeeded or failed
+	Error    string                `json:"error,omitempty"`
+	Started  time.Time             `json:"started"`
+	Finished time.Time             `json:"finished,omitempty"`
+	Results  []ScheduledTaskResult `json:"results,omitempty"`
+}
+
+type ScheduledTaskStatus struct {
+	ScheduledTask
+	NextRun time.Time         `json:"next_run,omitempty"`
+	LastRun *ScheduledTaskRun `json:"last_run,omitempty"`
+}
+
+// FaultRule injects failures in the requests matching its model and endpoint, to test how the clients
+// handle them. Only available with --enable-fault-injection
+type FaultRule struct {
+	ID string `json:"id"`
+	// Model the rule applies to, all of them when empty
+	Model string `json:"model,omitempty"`
+	// Endpoint is the path prefix of the requests the rule applies to (e.g. /v1/chat/completions), all of them when empty
+	Endpoint string `json:"endpoint,omitempty"`
+	// Delay before the request is handled (e.g. 5s)
+	Delay string `json:"delay,omitempty"`
+	// ErrorRate is the fraction of the requests, between 0 and 1, failed with ErrorStatus
+	ErrorRate   float64 `json:"error_rate,omitempty"`
+	ErrorStatus int     `json:"error_status,omitempty"`
+	// TruncateAfter ends the streamed responses after this number of events, without the final ones
+	TruncateAfter int `json:"truncate_after,omitempty"`
+	// Times is the number of requests the rule applies to before it's removed, unlimited when 0
+	Times int `json:"times,omitempty"`
+}
+
+// UsageEntry is the usage of an API key and a model, during a window when the usage is split in windows
+type UsageEntry struct {
+	// StartTime and EndTime are the unix times of the window, unset for the whole period
+	StartTime int64 `json:"start_time,omitempty"`
+	EndTime   int64 `json:"end_time,omitempty"`
+	// APIKey identifies the API key without revealing it, empty without authentication
+	APIKey           string `json:"api_key,omitempty"`
+	Model            string `json:"model,omitempty"`
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+}
+
+type UsageResponse struct {
+	Object string       `json:"object"`
+	Data   []UsageEntry `json:"data"`
+}
+
+// The types of the recommendations
+const (
+	RecommendationUninstall = "uninstall"
+	RecommendationVariant   = "variant"
+)
+
+// Recommendation is an action suggested on a model from its usage and the hardware, taken by calling the API
+type Recommendation struct {
+	Type    string `json:"type"`
+	Model   string `json:"model"`
+	Message string `json:"message"`
+	// Size is the disk space freed by an uninstall, or the estimated size of a variant, in bytes
+	Size uint64 `json:"size"`
+	// Variant is the gallery ID of the variant suggested
+	Variant string               `json:"variant,omitempty"`
+	Action  RecommendationAction `json:"action"`
+}
+
+// RecommendationAction is the API call taking a recommendation
+type RecommendationAction struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+// CapacityError is the error of the requests refused by the admission control, which can't complete within the
+// latency SLO of their model at the current load
+type CapacityError struct {
+	Code                    int     `json:"code"`
+	Message                 string  `json:"message"`
+	Type                    string  `json:"type"`
+	Model                   string  `json:"model"`
+	RequestsInProgress      int     `json:"requests_in_progress"`
+	EstimatedLatencySeconds float64 `json:"estimated_latency_seconds"`
+	LatencySLOSeconds       float64 `json:"latency_slo_seconds"`
+	RetryAfterSeconds       int     `json:"retry_after_seconds"`
+}
+
+type CapacityErrorResponse struct {
+	Error *CapacityError `json:"error"`
+}
+
+// QueueFullError is the error of the requests refused because all the inference slots are busy and the inference
+// queue is full
+type QueueFullError struct {
+	Code                 int     `json:"code"`
+	Message              string  `json:"message"`
+	Type                 string  `json:"type"`
+	InferencesRunning    int     `json:"inferences_running"`
+	InferencesQueued     int     `json:"inferences_queued"`
+	EstimatedWaitSeconds float64 `json:"estimated_wait_seconds"`
+	RetryAfterSeconds    int     `json:"retry_after_seconds"`
+}
+
+type QueueFullErrorResponse struct {
+	Error *QueueFullError `json:"error"`
+}
+
+// AuditEntry is a line of the audit log, written for each API request
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// APIKeyID identifies the API key of the request without revealing it, empty without authentication
+	APIKeyID string `json:"api_key_id,omitempty"`
+	User     string `json:"user,omitempty"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	Model    string `json:"model,omitempty"`
+	// Prompt is the content of the request, replaced by its length when the audit log is redacted
+	Prompt           string  `json:"prompt,omitempty"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	LatencyMS        float64 `json:"latency_ms"`
+	Status           int     `json:"status"`
+	Streamed         bool    `json:"streamed,omitempty"`
+}
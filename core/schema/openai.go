@@ -2,6 +2,7 @@ package schema
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	functions "github.com/mudler/LocalAI/pkg/functions"
@@ -44,14 +45,104 @@ type OpenAIResponse struct {
 	Data    []Item   `json:"data,omitempty"`
 
 	Usage OpenAIUsage `json:"usage"`
+
+	// SystemFingerprint identifies the backend, the model and the sampling settings which generated the response:
+	// the requests with the same seed get the same generations as long as it stays the same
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+
+	// Warning is set when the model of the request is deprecated or under maintenance
+	Warning string `json:"warning,omitempty"`
+
+	// Timings tells which backend served the request, and how long it took
+	Timings *Timings `json:"timings,omitempty"`
+
+	// TokenBudget is the token budget of the conversation of the request, after it
+	TokenBudget *TokenBudget `json:"token_budget,omitempty"`
+
+	// Validation holds the checks of the response by the validators of the request, with the retries
+	Validation *Validation `json:"validation,omitempty"`
+
+	// Models are the embeddings grouped by model, when the request has several models
+	Models []ModelEmbeddings `json:"models,omitempty"`
+}
+
+// ModelEmbeddings are the embeddings of the inputs by one of the models of an embeddings request
+type ModelEmbeddings struct {
+	Model   string   `json:"model"`
+	Data    []Item   `json:"data"`
+	Warning string   `json:"warning,omitempty"`
+	Timings *Timings `json:"timings,omitempty"`
+}
+
+// ResponseValidator checks the final output of a model: json, json_schema, regex or max_length
+type ResponseValidator struct {
+	Type string `json:"type" yaml:"type"`
+	// Schema is the JSON schema of the json_schema validator
+	Schema map[string]interface{} `json:"schema,omitempty" yaml:"schema,omitempty"`
+	// Pattern is the regular expression the output of the regex validator must match
+	Pattern   string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	MaxLength int    `json:"max_length,omitempty" yaml:"max_length,omitempty"`
+}
+
+// Validation is the result of the validators, the request is retried with the errors until it's valid
+type Validation struct {
+	Valid    bool                `json:"valid"`
+	Attempts []ValidationAttempt `json:"attempts"`
+}
+
+type ValidationAttempt struct {
+	Attempt int      `json:"attempt"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// TokenBudget is the number of tokens a conversation may use, and how many it used
+type TokenBudget struct {
+	ConversationID string `json:"conversation_id"`
+	Limit          int    `json:"limit"`
+	Used           int    `json:"used"`
+	Remaining      int    `json:"remaining"`
+	// Truncated is set when max_tokens was lowered to the remaining budget
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type Timings struct {
+	Backend string `json:"backend,omitempty"`
+	// BackendOverride is set when the backend was forced by the request
+	BackendOverride bool    `json:"backend_override,omitempty"`
+	TotalMS         float64 `json:"total_ms"`
 }
 
 type Choice struct {
-	Index        int      `json:"index"`
-	FinishReason string   `json:"finish_reason"`
-	Message      *Message `json:"message,omitempty"`
-	Delta        *Message `json:"delta,omitempty"`
-	Text         string   `json:"text,omitempty"`
+	Index        int       `json:"index"`
+	FinishReason string    `json:"finish_reason"`
+	Message      *Message  `json:"message,omitempty"`
+	Delta        *Message  `json:"delta,omitempty"`
+	Text         string    `json:"text,omitempty"`
+	Logprobs     *Logprobs `json:"logprobs,omitempty"`
+}
+
+// Logprobs are the log probabilities of the tokens of a choice: in Content for the chat completions, in the
+// other fields for the completions
+type Logprobs struct {
+	Content []LogprobContent `json:"content,omitempty"`
+
+	Tokens        []string             `json:"tokens,omitempty"`
+	TokenLogprobs []float64            `json:"token_logprobs,omitempty"`
+	TopLogprobs   []map[string]float64 `json:"top_logprobs,omitempty"`
+	TextOffset    []int                `json:"text_offset,omitempty"`
+}
+
+// LogprobContent is the log probability of a token, with the most likely tokens in its place
+type LogprobContent struct {
+	TopLogprob
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	// Bytes are the UTF-8 bytes of the token
+	Bytes []int `json:"bytes"`
 }
 
 type Content struct {
@@ -74,6 +165,12 @@ type Message struct {
 	// The message content
 	Content interface{} `json:"content" yaml:"content"`
 
+	// Set instead of the content when the model can't answer, e.g. with the strict JSON schema of the request
+	Refusal string `json:"refusal,omitempty" yaml:"refusal,omitempty"`
+
+	// The reasoning of the model, split from the content (see the reasoning config of the model)
+	ReasoningContent string `json:"reasoning_content,omitempty" yaml:"reasoning_content,omitempty"`
+
 	StringContent string   `json:"string_content,omitempty" yaml:"string_content,omitempty"`
 	StringImages  []string `json:"string_images,omitempty" yaml:"string_images,omitempty"`
 
@@ -84,9 +181,10 @@ type Message struct {
 }
 
 type ToolCall struct {
-	Index        int          `json:"index"`
-	ID           string       `json:"id"`
-	Type         string       `json:"type"`
+	Index int `json:"index"`
+	// ID and Type are only in the first delta of a streamed call, the clients concatenate the deltas
+	ID           string       `json:"id,omitempty"`
+	Type         string       `json:"type,omitempty"`
 	FunctionCall FunctionCall `json:"function"`
 }
 
@@ -98,6 +196,29 @@ type FunctionCall struct {
 type OpenAIModel struct {
 	ID     string `json:"id"`
 	Object string `json:"object"`
+
+	// Set when the model is deprecated
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Sunset      string `json:"sunset,omitempty"`
+
+	// Set when the model is under maintenance
+	Maintenance      bool   `json:"maintenance,omitempty"`
+	MaintenanceUntil string `json:"maintenance_until,omitempty"`
+
+	// Capabilities are the tags of what the model can do (chat, completion, embeddings, vision, tools, tts,
+	// transcription, rerank, image), unknown for the files without a config
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// ReducedSettings are the settings the loaded model was reduced to after running out of memory, e.g.
+	// "gpu_layers 16", they are dropped when the model is unloaded
+	ReducedSettings []string `json:"reduced_settings,omitempty"`
+
+	// Digest changes with the configuration and the weights of the model, it's the ETag of the model
+	Digest string `json:"digest,omitempty"`
+	// Checksum is the sha256 of the model file, set once computed
+	Checksum   string `json:"checksum,omitempty"`
+	ConfigHash string `json:"config_hash,omitempty"`
 }
 
 type DeleteAssistantResponse struct {
@@ -131,6 +252,49 @@ type DeleteAssistantFileResponse struct {
 	Deleted bool   `json:"deleted"`
 }
 
+type VectorStoreSearchRequest struct {
+	Query         string `json:"query"`
+	MaxNumResults int    `json:"max_num_results,omitempty"`
+}
+
+type VectorStoreSearchResult struct {
+	FileID  string  `json:"file_id"`
+	Score   float32 `json:"score"`
+	Content string  `json:"content"`
+}
+
+type VectorStoreSearchResponse struct {
+	Object string                    `json:"object"`
+	Query  string                    `json:"search_query"`
+	Data   []VectorStoreSearchResult `json:"data"`
+}
+
+// StoredCompletion is a chat completion persisted because it was requested with `store: true`
+type StoredCompletion struct {
+	OpenAIResponse
+
+	Metadata map[string]string `json:"metadata"`
+}
+
+type StoredCompletionsList struct {
+	Object  string             `json:"object"`
+	Data    []StoredCompletion `json:"data"`
+	FirstID string             `json:"first_id"`
+	LastID  string             `json:"last_id"`
+	HasMore bool               `json:"has_more"`
+}
+
+// StoredCompletionMessages are the messages of the request of a stored chat completion
+type StoredCompletionMessages struct {
+	Object  string    `json:"object"`
+	Data    []Message `json:"data"`
+	HasMore bool      `json:"has_more"`
+}
+
+type StoredCompletionUpdateRequest struct {
+	Metadata map[string]string `json:"metadata"`
+}
+
 type ImageGenerationResponseFormat string
 
 type ChatCompletionResponseFormatType string
@@ -145,9 +309,10 @@ type JsonSchemaRequest struct {
 }
 
 type JsonSchema struct {
-	Name   string         `json:"name"`
-	Strict bool           `json:"strict"`
-	Schema functions.Item `json:"schema"`
+	Name   string `json:"name"`
+	Strict bool   `json:"strict"`
+	// Schema is the whole JSON schema of the answer, compiled to a grammar and checked on the answer
+	Schema map[string]interface{} `json:"schema"`
 }
 
 type OpenAIRequest struct {
@@ -155,6 +320,8 @@ type OpenAIRequest struct {
 
 	Context context.Context    `json:"-"`
 	Cancel  context.CancelFunc `json:"-"`
+	// Warnings are the warnings of the inference, added to the warning of the response
+	Warnings []string `json:"-"`
 
 	// whisper
 	File string `json:"file" validate:"required"`
@@ -169,6 +336,9 @@ type OpenAIRequest struct {
 	Instruction string      `json:"instruction" yaml:"instruction"`
 	Input       interface{} `json:"input" yaml:"input"`
 
+	// Models compute the embeddings of the same inputs, returned grouped by model (not supported by OpenAI)
+	Models []string `json:"models,omitempty" yaml:"models"`
+
 	Stop interface{} `json:"stop" yaml:"stop"`
 
 	// Messages is read only by chat/completion API calls
@@ -180,6 +350,9 @@ type OpenAIRequest struct {
 
 	Tools       []functions.Tool `json:"tools,omitempty" yaml:"tools"`
 	ToolsChoice interface{}      `json:"tool_choice,omitempty" yaml:"tool_choice"`
+	// ParallelToolCalls lets the model call several tools in the same answer, the parallel_calls of the grammar of the
+	// model by default
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty" yaml:"parallel_tool_calls"`
 
 	Stream bool `json:"stream"`
 
@@ -190,15 +363,151 @@ type OpenAIRequest struct {
 	// A grammar to constrain the LLM output
 	Grammar string `json:"grammar" yaml:"grammar"`
 
+	// GuidedChoice constrains the LLM output to exactly one of the strings
+	GuidedChoice []string `json:"guided_choice,omitempty" yaml:"guided_choice"`
+
 	JSONFunctionGrammarObject *functions.JSONFunctionStructure `json:"grammar_json_functions" yaml:"grammar_json_functions"`
 
 	Backend string `json:"backend" yaml:"backend"`
 
 	// AutoGPTQ
 	ModelBaseName string `json:"model_base_name" yaml:"model_base_name"`
+
+	// User is the identifier of the end-user, used for attribution in logs, metrics and diagnostics
+	User string `json:"user,omitempty" yaml:"user"`
+
+	// Preset is the name of a generation preset, its fields are applied before the ones of the request
+	Preset string `json:"preset,omitempty" yaml:"preset"`
+
+	// Store persists the chat completion, to be retrieved later from /v1/chat/completions
+	Store bool `json:"store,omitempty" yaml:"store"`
+	// Metadata is attached to the stored chat completion, to filter them
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata"`
+
+	// ForceLanguage is the ISO 639-1 code of the language the model must answer in
+	ForceLanguage string `json:"force_language,omitempty" yaml:"force_language"`
+
+	// ConversationID groups the requests of a conversation, for its token budget. Defaults to the user
+	ConversationID string `json:"conversation_id,omitempty" yaml:"conversation_id"`
+	// TokenBudget is the number of tokens the conversation may use, it can't exceed the configured one
+	TokenBudget int `json:"token_budget,omitempty" yaml:"token_budget"`
+
+	// Validators check the answer, replacing the ones of the model. ValidationRetries is the number of retries
+	// with the errors when the answer is not valid
+	Validators        []ResponseValidator `json:"validators,omitempty" yaml:"validators"`
+	ValidationRetries *int                `json:"validation_retries,omitempty" yaml:"validation_retries"`
 }
 
 type ModelsDataResponse struct {
 	Object string        `json:"object"`
 	Data   []OpenAIModel `json:"data"`
 }
+
+// BatchRequest creates a batch running the requests of a JSONL file of the files API
+type BatchRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+}
+
+type BatchErrors struct {
+	Object string       `json:"object"`
+	Data   []BatchError `json:"data"`
+}
+
+// Batch is a batch of requests run in the background, https://platform.openai.com/docs/api-reference/batch/object
+type Batch struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"`
+	Endpoint         string             `json:"endpoint"`
+	Errors           *BatchErrors       `json:"errors,omitempty"`
+	InputFileID      string             `json:"input_file_id"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	OutputFileID     string             `json:"output_file_id,omitempty"`
+	ErrorFileID      string             `json:"error_file_id,omitempty"`
+	CreatedAt        int64              `json:"created_at"`
+	InProgressAt     int64              `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64              `json:"expires_at,omitempty"`
+	FinalizingAt     int64              `json:"finalizing_at,omitempty"`
+	CompletedAt      int64              `json:"completed_at,omitempty"`
+	FailedAt         int64              `json:"failed_at,omitempty"`
+	ExpiredAt        int64              `json:"expired_at,omitempty"`
+	CancellingAt     int64              `json:"cancelling_at,omitempty"`
+	CancelledAt      int64              `json:"cancelled_at,omitempty"`
+	RequestCounts    BatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+	// Checkpoint is the progress saved while the batch runs, from which a cancelled or interrupted batch is resumed
+	Checkpoint *BatchCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// BatchCheckpoint is the results of the first requests of a batch, saved in files of the storage of the uploads
+type BatchCheckpoint struct {
+	// Offset is the number of requests of the input file, in order, whose results are in Files
+	Offset int      `json:"offset"`
+	Files  []string `json:"files"`
+}
+
+type ListBatches struct {
+	Object  string  `json:"object"`
+	Data    []Batch `json:"data"`
+	FirstID string  `json:"first_id,omitempty"`
+	LastID  string  `json:"last_id,omitempty"`
+	HasMore bool    `json:"has_more"`
+}
+
+// BatchInputLine is a line of the input file of a batch
+type BatchInputLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type BatchLineResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// BatchOutputLine is a line of the output and the error files of a batch
+type BatchOutputLine struct {
+	ID       string             `json:"id"`
+	CustomID string             `json:"custom_id"`
+	Response *BatchLineResponse `json:"response"`
+	Error    *BatchError        `json:"error"`
+}
+
+// ModerationRequest classifies texts as potentially harmful, https://platform.openai.com/docs/api-reference/moderations
+type ModerationRequest struct {
+	// Model is optional, the request is routed to a model answering the moderations when empty
+	Model string `json:"model,omitempty"`
+	// Input is a text, a list of texts, or a list of text parts judged together
+	Input interface{} `json:"input"`
+}
+
+// ModerationResult holds the categories of a text, and their scores
+type ModerationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
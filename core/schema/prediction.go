@@ -1,5 +1,10 @@
 package schema
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type PredictionOptions struct {
 
 	// Also part of the OpenAI official spec
@@ -25,6 +30,15 @@ type PredictionOptions struct {
 	Batch         int     `json:"batch" yaml:"batch"`
 	IgnoreEOS     bool    `json:"ignore_eos" yaml:"ignore_eos"`
 	RepeatPenalty float64 `json:"repeat_penalty" yaml:"repeat_penalty"`
+	// Raw sends the prompt of the completions as is: without the templates of the model, nor the BOS token
+	Raw bool `json:"raw" yaml:"raw"`
+
+	// Also part of the OpenAI official spec: a boolean for the chat completions, the number of the most likely
+	// tokens returned with each token for the completions
+	Logprobs LogprobsValue `json:"logprobs" yaml:"-"`
+	// Also part of the OpenAI official spec, the number of the most likely tokens returned with each token for the
+	// chat completions
+	TopLogprobs *int `json:"top_logprobs" yaml:"-"`
 
 	RepeatLastN int `json:"repeat_last_n" yaml:"repeat_last_n"`
 
@@ -50,3 +64,35 @@ type PredictionOptions struct {
 	// RWKV (?)
 	Tokenizer string `json:"tokenizer" yaml:"tokenizer"`
 }
+
+// LogprobsValue is the logprobs parameter, true or the number of the most likely tokens to return with each token
+type LogprobsValue struct {
+	Enabled bool
+	// Top is the number of the most likely tokens
+	Top int
+}
+
+func (l *LogprobsValue) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		*l = LogprobsValue{}
+	case bool:
+		*l = LogprobsValue{Enabled: v}
+	case float64:
+		*l = LogprobsValue{Enabled: true, Top: int(v)}
+	default:
+		return fmt.Errorf("logprobs must be a boolean or a number, not %s", string(data))
+	}
+	return nil
+}
+
+func (l LogprobsValue) MarshalJSON() ([]byte, error) {
+	if l.Enabled && l.Top > 0 {
+		return json.Marshal(l.Top)
+	}
+	return json.Marshal(l.Enabled)
+}
@@ -0,0 +1,38 @@
+package schema
+
+// TEIEmbedRequest is the request of the /embed endpoint of Hugging Face text-embeddings-inference
+type TEIEmbedRequest struct {
+	// Model is not part of TEI, which serves a single model: it's the first one with embeddings enabled when empty
+	Model string `json:"model,omitempty"`
+	// Inputs is a text or a list of texts
+	Inputs    interface{} `json:"inputs"`
+	Normalize *bool       `json:"normalize,omitempty"`
+}
+
+// TEIRerankRequest is the request of the /rerank endpoint of Hugging Face text-embeddings-inference
+type TEIRerankRequest struct {
+	// Model is not part of TEI, which serves a single model: it's the first one with the rerankers backend when empty
+	Model      string   `json:"model,omitempty"`
+	Query      string   `json:"query"`
+	Texts      []string `json:"texts"`
+	ReturnText bool     `json:"return_text,omitempty"`
+}
+
+// TEIRank is a text ranked by the /rerank endpoint
+type TEIRank struct {
+	Index int     `json:"index"`
+	Score float64 `json:"score"`
+	Text  string  `json:"text,omitempty"`
+}
+
+// TEIInfo is the response of the /info endpoint of Hugging Face text-embeddings-inference
+type TEIInfo struct {
+	ModelID            string                 `json:"model_id"`
+	ModelSHA           *string                `json:"model_sha"`
+	ModelDType         string                 `json:"model_dtype"`
+	ModelType          map[string]interface{} `json:"model_type"`
+	MaxInputLength     int                    `json:"max_input_length,omitempty"`
+	MaxClientBatchSize int                    `json:"max_client_batch_size"`
+	AutoTruncate       bool                   `json:"auto_truncate"`
+	Version            string                 `json:"version"`
+}
@@ -14,3 +14,24 @@ type TranscriptionResult struct {
 	Segments []Segment `json:"segments"`
 	Text     string    `json:"text"`
 }
+
+// MeetingNotes are the notes of a meeting taken from its transcript
+type MeetingNotes struct {
+	Summary     string   `json:"summary"`
+	ActionItems []string `json:"action_items"`
+}
+
+type MeetingNotesResponse struct {
+	Model         string               `json:"model"`
+	NotesModel    string               `json:"notes_model"`
+	Transcription *TranscriptionResult `json:"transcription"`
+	Notes         *MeetingNotes        `json:"notes"`
+}
+
+// MeetingNotesEvent is an event of the streamed meeting notes, sent as each stage completes
+type MeetingNotesEvent struct {
+	Type          string               `json:"type"` // transcription, notes or error
+	Transcription *TranscriptionResult `json:"transcription,omitempty"`
+	Notes         *MeetingNotes        `json:"notes,omitempty"`
+	Error         string               `json:"error,omitempty"`
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+const (
+	// admissionSmoothing is the weight of the last request in the measured speed of a model
+	admissionSmoothing = 0.3
+	// promptTokenCost is the cost of a prompt token relative to a generated one, the prompts being processed in
+	// batches
+	promptTokenCost = 0.1
+)
+
+// AdmissionCost is the estimated cost of a request
+type AdmissionCost struct {
+	PromptTokens int
+	// MaxTokens bounds the tokens generated, the average of the model is used when 0
+	MaxTokens int
+}
+
+// modelLoad is the load of a model and its measured speed
+type modelLoad struct {
+	pending    float64 // cost of the admitted requests which are not done
+	requests   int
+	rate       float64 // cost processed per second, 0 until a request is done
+	completion float64 // average tokens generated per request
+	lastDone   time.Time
+	changed    chan struct{} // closed when a request is done
+}
+
+func (m *modelLoad) cost(c AdmissionCost) float64 {
+	completion := float64(c.MaxTokens)
+	if completion == 0 {
+		completion = m.completion
+	}
+	return completion + float64(c.PromptTokens)*promptTokenCost
+}
+
+// estimate returns the time to complete a request of the cost, after the requests in progress
+func (m *modelLoad) estimate(cost float64) time.Duration {
+	return time.Duration((m.pending + cost) / m.rate * float64(time.Second))
+}
+
+// CapacityError is the error of the requests refused because they can't complete within the latency SLO
+type CapacityError struct {
+	Model            string
+	Requests         int // requests of the model in progress
+	EstimatedLatency time.Duration
+	LatencySLO       time.Duration
+	RetryAfter       time.Duration
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("the model %s is at capacity: the request would complete in about %s after the %d requests in progress, beyond the latency SLO of %s",
+		e.Model, e.EstimatedLatency.Round(time.Second), e.Requests, e.LatencySLO)
+}
+
+// AdmissionService admits the requests of the models as long as they can complete within their latency SLO. The
+// time of a request is estimated from its prompt and max_tokens, the requests in progress and the speed of the
+// model measured on the previous requests. The estimate assumes that a model processes its requests one after the
+// other
+type AdmissionService struct {
+	sync.Mutex
+	models map[string]*modelLoad
+
+	now func() time.Time
+}
+
+func NewAdmissionService() *AdmissionService {
+	return &AdmissionService{
+		models: map[string]*modelLoad{},
+		now:    time.Now,
+	}
+}
+
+func (as *AdmissionService) model(name string) *modelLoad {
+	m, exists := as.models[name]
+	if !exists {
+		m = &modelLoad{changed: make(chan struct{})}
+		as.models[name] = m
+	}
+	return m
+}
+
+// Admit admits a request of the model, waiting up to queueTimeout for the requests in progress to complete when it
+// can't meet the latency SLO yet. The requests are always admitted when the model is idle or its speed isn't known
+// yet. The returned function must be called with the usage of the request once it's done
+func (as *AdmissionService) Admit(ctx context.Context, modelName string, cost AdmissionCost, slo, queueTimeout time.Duration) (func(schema.OpenAIUsage), error) {
+	var arrival time.Time
+	for {
+		as.Lock()
+		if arrival.IsZero() {
+			arrival = as.now()
+		}
+		m := as.model(modelName)
+		c := m.cost(cost)
+		waited := as.now().Sub(arrival)
+		if m.requests == 0 || m.rate == 0 || waited+m.estimate(c) <= slo {
+			m.pending += c
+			m.requests++
+			admitted := as.now()
+			as.Unlock()
+
+			var done sync.Once
+			return func(usage schema.OpenAIUsage) {
+				done.Do(func() { as.done(modelName, c, admitted, usage) })
+			}, nil
+		}
+
+		estimate := waited + m.estimate(c)
+		err := &CapacityError{
+			Model:            modelName,
+			Requests:         m.requests,
+			EstimatedLatency: estimate,
+			LatencySLO:       slo,
+			RetryAfter:       estimate - slo,
+		}
+		changed := m.changed
+		as.Unlock()
+
+		remaining := queueTimeout - waited
+		if remaining <= 0 {
+			return nil, err
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+			return nil, err
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// done releases the cost of a request, and measures the speed of the model with its usage. The model is assumed
+// to start a request once the previous one is done
+func (as *AdmissionService) done(modelName string, cost float64, admitted time.Time, usage schema.OpenAIUsage) {
+	as.Lock()
+	defer as.Unlock()
+	m := as.model(modelName)
+	m.pending -= cost
+	m.requests--
+
+	now := as.now()
+	start := admitted
+	if m.lastDone.After(start) {
+		start = m.lastDone
+	}
+	if elapsed := now.Sub(start).Seconds(); usage.CompletionTokens > 0 && elapsed > 0 {
+		rate := (float64(usage.CompletionTokens) + float64(usage.PromptTokens)*promptTokenCost) / elapsed
+		if m.rate == 0 {
+			m.rate, m.completion = rate, float64(usage.CompletionTokens)
+		} else {
+			m.rate += admissionSmoothing * (rate - m.rate)
+			m.completion += admissionSmoothing * (float64(usage.CompletionTokens) - m.completion)
+		}
+	}
+	m.lastDone = now
+
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmissionService(t *testing.T) {
+	// newService returns a service with a model generating 10 tokens per second
+	newService := func(t *testing.T) (*AdmissionService, *time.Time) {
+		clock := time.Now()
+		as := NewAdmissionService()
+		as.now = func() time.Time { return clock }
+
+		done, err := as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 100}, time.Second, 0)
+		require.NoError(t, err)
+		clock = clock.Add(10 * time.Second)
+		done(schema.OpenAIUsage{CompletionTokens: 100})
+		return as, &clock
+	}
+
+	t.Run("admits the requests while the speed of the model is unknown", func(t *testing.T) {
+		as := NewAdmissionService()
+		for i := 0; i < 3; i++ {
+			_, err := as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 1000}, time.Second, 0)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("admits the requests meeting the SLO at the current load", func(t *testing.T) {
+		as, _ := newService(t)
+		_, err := as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 100}, 30*time.Second, 0)
+		require.NoError(t, err)
+		_, err = as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 100, PromptTokens: 500}, 30*time.Second, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("refuses the requests which can't meet the SLO", func(t *testing.T) {
+		as, _ := newService(t)
+		_, err := as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 200}, 30*time.Second, 0)
+		require.NoError(t, err)
+
+		_, err = as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 200}, 30*time.Second, 0)
+		var capacityErr *CapacityError
+		require.ErrorAs(t, err, &capacityErr)
+		assert.Equal(t, "phi-2", capacityErr.Model)
+		assert.Equal(t, 1, capacityErr.Requests)
+		assert.Equal(t, 40*time.Second, capacityErr.EstimatedLatency)
+		assert.Equal(t, 10*time.Second, capacityErr.RetryAfter)
+
+		// the other models are not loaded
+		_, err = as.Admit(context.Background(), "bert", AdmissionCost{MaxTokens: 200}, 30*time.Second, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("estimates the requests without max_tokens with the average of the model", func(t *testing.T) {
+		as, _ := newService(t)
+		_, err := as.Admit(context.Background(), "phi-2", AdmissionCost{}, 15*time.Second, 0)
+		require.NoError(t, err)
+		_, err = as.Admit(context.Background(), "phi-2", AdmissionCost{}, 15*time.Second, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("queues the requests until the requests in progress are done", func(t *testing.T) {
+		as, clock := newService(t)
+		done, err := as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 200}, 30*time.Second, 0)
+		require.NoError(t, err)
+
+		admitted := make(chan error)
+		go func() {
+			_, err := as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 200}, 30*time.Second, time.Minute)
+			admitted <- err
+		}()
+		select {
+		case <-admitted:
+			t.Fatal("the request was admitted before the request in progress was done")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		as.Lock()
+		*clock = clock.Add(20 * time.Second)
+		as.Unlock()
+		done(schema.OpenAIUsage{CompletionTokens: 200})
+		assert.NoError(t, <-admitted)
+	})
+
+	t.Run("refuses the queued requests after the queue timeout", func(t *testing.T) {
+		as, _ := newService(t)
+		_, err := as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 200}, 30*time.Second, 0)
+		require.NoError(t, err)
+
+		_, err = as.Admit(context.Background(), "phi-2", AdmissionCost{MaxTokens: 200}, 30*time.Second, 10*time.Millisecond)
+		var capacityErr *CapacityError
+		assert.ErrorAs(t, err, &capacityErr)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = as.Admit(ctx, "phi-2", AdmissionCost{MaxTokens: 200}, 30*time.Second, time.Minute)
+		assert.Error(t, err)
+	})
+}
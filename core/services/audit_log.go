@@ -0,0 +1,132 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// AuditLogService writes the audit log of the API requests, one JSON line per request
+type AuditLogService struct {
+	sync.Mutex
+	w      io.WriteCloser
+	redact bool
+}
+
+// NewAuditLogService opens the audit log: a file, "syslog" for the local syslog, or syslog://host:port (UDP) and
+// syslog+tcp://host:port for a remote one. The file is rotated once it reaches maxSizeMB, keeping the given number
+// of rotated files. When redact is set, the prompts are replaced by their length
+func NewAuditLogService(target string, maxSizeMB, backups int, redact bool) (*AuditLogService, error) {
+	var w io.WriteCloser
+	var err error
+	switch {
+	case target == "syslog":
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "local-ai")
+	case strings.HasPrefix(target, "syslog://"):
+		w, err = syslog.Dial("udp", strings.TrimPrefix(target, "syslog://"), syslog.LOG_INFO|syslog.LOG_DAEMON, "local-ai")
+	case strings.HasPrefix(target, "syslog+tcp://"):
+		w, err = syslog.Dial("tcp", strings.TrimPrefix(target, "syslog+tcp://"), syslog.LOG_INFO|syslog.LOG_DAEMON, "local-ai")
+	default:
+		w, err = openRotatingFile(target, int64(maxSizeMB)*1024*1024, backups)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit log %s: %w", target, err)
+	}
+	return &AuditLogService{w: w, redact: redact}, nil
+}
+
+// Log writes the entry of a request
+func (als *AuditLogService) Log(entry schema.AuditEntry) {
+	if als.redact && entry.Prompt != "" {
+		entry.Prompt = fmt.Sprintf("[redacted %d characters]", utf8.RuneCountInString(entry.Prompt))
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode the audit log entry")
+		return
+	}
+
+	als.Lock()
+	defer als.Unlock()
+	if _, err := als.w.Write(append(line, '\n')); err != nil {
+		log.Error().Err(err).Msg("failed to write the audit log")
+	}
+}
+
+func (als *AuditLogService) Close() error {
+	als.Lock()
+	defer als.Unlock()
+	return als.w.Close()
+}
+
+// rotatingFile is a file renamed to <path>.1 once it reaches its maximum size, the previous rotated files being
+// shifted to <path>.2 and so on
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	return r, r.open(os.O_APPEND)
+}
+
+func (r *rotatingFile) open(mode int) error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|mode, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	for i := r.backups; i > 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i-1), fmt.Sprintf("%s.%d", r.path, i))
+	}
+	if r.backups > 0 {
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	}
+	return r.open(os.O_TRUNC)
+}
+
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
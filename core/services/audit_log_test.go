@@ -0,0 +1,91 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.log")
+	r, err := openRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	// each line exceeds the size left in the file, the oldest one is dropped with 2 backups
+	read := func(name string) string {
+		dat, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return string(dat)
+	}
+	assert.Equal(t, "fourth\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+	assert.Equal(t, "second\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3")
+
+	// the file is appended to when reopened, until it reaches the maximum size
+	r, err = openRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	_, err = r.Write([]byte("5\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "fourth\n5\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+}
+
+func TestAuditLogRotationWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	r, err := openRotatingFile(path, 10, 0)
+	require.NoError(t, err)
+	for _, line := range []string{"first\n", "second\n"} {
+		_, err := r.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+
+	dat, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(dat))
+	assert.NoFileExists(t, path+".1")
+}
+
+func TestAuditLogRedaction(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		als, err := NewAuditLogService(path, 1, 1, redact)
+		require.NoError(t, err)
+		als.Log(schema.AuditEntry{APIKeyID: "ci", Method: "POST", Endpoint: "/v1/chat/completions", Model: "phi-2", Prompt: "héllo", Status: 200})
+		als.Log(schema.AuditEntry{Method: "GET", Endpoint: "/v1/models", Status: 200})
+		require.NoError(t, als.Close())
+
+		dat, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(dat), "\n"), "\n")
+		require.Len(t, lines, 2)
+
+		entry := schema.AuditEntry{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		assert.Equal(t, "ci", entry.APIKeyID)
+		assert.Equal(t, "phi-2", entry.Model)
+		if redact {
+			assert.Equal(t, "[redacted 5 characters]", entry.Prompt)
+			assert.NotContains(t, lines[0], "llo")
+		} else {
+			assert.Equal(t, "héllo", entry.Prompt)
+		}
+
+		// the entries without a prompt have nothing to redact
+		assert.NotContains(t, lines[1], `"prompt"`)
+	}
+}
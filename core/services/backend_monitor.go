@@ -101,7 +101,7 @@ func (bms *BackendMonitorService) SampleLocalBackendProcess(model string) (*sche
 	}, nil
 }
 
-func (bms BackendMonitorService) CheckAndSample(modelName string) (*proto.StatusResponse, error) {
+func (bms BackendMonitorService) CheckAndSample(modelName string) (*schema.BackendMonitorStatusResponse, error) {
 	backendId, err := bms.getModelLoaderIDFromModelName(modelName)
 	if err != nil {
 		return nil, err
@@ -111,6 +111,11 @@ func (bms BackendMonitorService) CheckAndSample(modelName string) (*proto.Status
 		return nil, fmt.Errorf("backend %s is not currently loaded", backendId)
 	}
 
+	res := &schema.BackendMonitorStatusResponse{}
+	if stats, exists := bms.modelLoader.ProcessStats(backendId); exists {
+		res.Process = &stats
+	}
+
 	status, rpcErr := modelAddr.GRPC(false, nil).Status(context.TODO())
 	if rpcErr != nil {
 		log.Warn().Msgf("backend %s experienced an error retrieving status info: %s", backendId, rpcErr.Error())
@@ -118,7 +123,7 @@ func (bms BackendMonitorService) CheckAndSample(modelName string) (*proto.Status
 		if slbErr != nil {
 			return nil, fmt.Errorf("backend %s experienced an error retrieving status info via rpc: %s, then failed local node process sample: %s", backendId, rpcErr.Error(), slbErr.Error())
 		}
-		return &proto.StatusResponse{
+		res.StatusResponse = &proto.StatusResponse{
 			State: proto.StatusResponse_ERROR,
 			Memory: &proto.MemoryUsageData{
 				Total: val.MemoryInfo.VMS,
@@ -126,9 +131,11 @@ func (bms BackendMonitorService) CheckAndSample(modelName string) (*proto.Status
 					"gopsutil-RSS": val.MemoryInfo.RSS,
 				},
 			},
-		}, nil
+		}
+		return res, nil
 	}
-	return status, nil
+	res.StatusResponse = status
+	return res, nil
 }
 
 func (bms BackendMonitorService) ShutdownModel(modelName string) error {
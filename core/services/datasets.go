@@ -0,0 +1,221 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// StoredCompletionRatingKey is the metadata key holding the rating of a stored chat completion, it's set with the
+// update of the metadata like the other keys
+const StoredCompletionRatingKey = "rating"
+
+// DatasetFilter selects the stored chat completions exported in a fine-tuning dataset
+type DatasetFilter struct {
+	Model     string
+	APIKey    string // ID of the API key, see config.APIKey.ID
+	MinRating float64
+	Since     time.Time
+	Until     time.Time
+	Metadata  map[string]string
+}
+
+// DatasetExample is a line of a fine-tuning dataset in the OpenAI chat format
+type DatasetExample struct {
+	Messages []schema.Message `json:"messages"`
+}
+
+// Redactor removes the personal information from a text of an exported dataset
+type Redactor func(string) string
+
+var (
+	redactorsMu      sync.RWMutex
+	datasetRedactors = map[string]Redactor{
+		"email":       regexpRedactor(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, "[EMAIL]"),
+		"credit_card": regexpRedactor(`\b(?:\d[ -]?){12,18}\d\b`, "[CREDIT_CARD]"),
+		"phone":       regexpRedactor(`(?:\+\d{1,3}[\s.-]?)?\(?\d{2,4}\)?[\s.-]?\d{3,4}[\s.-]?\d{3,4}\b`, "[PHONE]"),
+		"ip":          regexpRedactor(`\b(?:\d{1,3}\.){3}\d{1,3}\b`, "[IP]"),
+	}
+)
+
+func regexpRedactor(expr, replacement string) Redactor {
+	re := regexp.MustCompile(expr)
+	return func(s string) string {
+		return re.ReplaceAllString(s, replacement)
+	}
+}
+
+// RegisterRedactor adds a redactor the dataset exports can select by name, replacing the built-in one with the same name
+func RegisterRedactor(name string, r Redactor) {
+	redactorsMu.Lock()
+	defer redactorsMu.Unlock()
+	datasetRedactors[name] = r
+}
+
+// RedactorNames returns the names of the redactors, sorted
+func RedactorNames() []string {
+	redactorsMu.RLock()
+	defer redactorsMu.RUnlock()
+	return redactorNames()
+}
+
+func redactorNames() []string {
+	names := make([]string, 0, len(datasetRedactors))
+	for name := range datasetRedactors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Redactors returns the redactors with the names, in their order
+func Redactors(names []string) ([]Redactor, error) {
+	redactorsMu.RLock()
+	defer redactorsMu.RUnlock()
+	selected := []Redactor{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		r, ok := datasetRedactors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redactor %q, available: %s", name, strings.Join(redactorNames(), ", "))
+		}
+		selected = append(selected, r)
+	}
+	return selected, nil
+}
+
+// ParseTimeBound parses the bounds of the periods of the exports and of the usage: unix seconds, RFC3339 or
+// YYYY-MM-DD
+func ParseTimeBound(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected unix seconds, RFC3339 or YYYY-MM-DD", value)
+}
+
+// ExportDataset writes the stored chat completions matching the filter to w, oldest first, as a JSONL fine-tuning
+// dataset in the OpenAI chat format: the messages of each request followed by the answer. The texts go through the
+// redactors before being written. It returns the number of examples written
+func (scs *StoredCompletionsService) ExportDataset(w io.Writer, filter DatasetFilter, redactors ...Redactor) (int, error) {
+	scs.Lock()
+	selected := []storedCompletion{}
+	for _, sc := range scs.completions {
+		if filter.match(sc) {
+			selected = append(selected, sc)
+		}
+	}
+	scs.Unlock()
+
+	enc := json.NewEncoder(w)
+	written := 0
+	for _, sc := range selected {
+		example, ok := datasetExample(sc, redactors)
+		if !ok {
+			continue
+		}
+		if err := enc.Encode(example); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+func (f DatasetFilter) match(sc storedCompletion) bool {
+	if f.Model != "" && sc.Completion.Model != f.Model {
+		return false
+	}
+	if f.APIKey != "" && sc.APIKey != f.APIKey {
+		return false
+	}
+	created := time.Unix(int64(sc.Completion.Created), 0)
+	if !f.Since.IsZero() && created.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !created.Before(f.Until) {
+		return false
+	}
+	if f.MinRating != 0 {
+		// the completions not rated yet are left out
+		rating, err := strconv.ParseFloat(sc.Completion.Metadata[StoredCompletionRatingKey], 64)
+		if err != nil || rating < f.MinRating {
+			return false
+		}
+	}
+	return matchMetadata(sc.Completion.Metadata, f.Metadata)
+}
+
+// datasetExample returns the example of a stored chat completion, false when it has no answer to learn from
+func datasetExample(sc storedCompletion, redactors []Redactor) (DatasetExample, bool) {
+	if len(sc.Completion.Choices) == 0 || sc.Completion.Choices[0].Message == nil {
+		return DatasetExample{}, false
+	}
+
+	messages := append(append([]schema.Message{}, sc.Messages...), *sc.Completion.Choices[0].Message)
+	example := DatasetExample{Messages: make([]schema.Message, len(messages))}
+	for i, m := range messages {
+		// only the fields of the fine-tuning format are kept
+		em := schema.Message{
+			Role:    m.Role,
+			Name:    m.Name,
+			Content: redactContent(m.Content, redactors),
+		}
+		for _, tc := range m.ToolCalls {
+			tc.FunctionCall.Arguments = redact(tc.FunctionCall.Arguments, redactors)
+			em.ToolCalls = append(em.ToolCalls, tc)
+		}
+		example.Messages[i] = em
+	}
+	return example, true
+}
+
+// redactContent redacts the content of a message: a text or the text parts of a multimodal content
+func redactContent(content interface{}, redactors []Redactor) interface{} {
+	switch c := content.(type) {
+	case string:
+		return redact(c, redactors)
+	case *string:
+		if c == nil {
+			return nil
+		}
+		return redact(*c, redactors)
+	case []interface{}:
+		parts := make([]interface{}, 0, len(c))
+		for _, p := range c {
+			if part, ok := p.(map[string]interface{}); ok && part["type"] == "text" {
+				text, _ := part["text"].(string)
+				p = map[string]interface{}{"type": "text", "text": redact(text, redactors)}
+			}
+			parts = append(parts, p)
+		}
+		return parts
+	}
+	return content
+}
+
+func redact(s string, redactors []Redactor) string {
+	for _, r := range redactors {
+		s = r(s)
+	}
+	return s
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/rs/zerolog/log"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+	"gopkg.in/yaml.v3"
+)
+
+// DiagnosticsService collects a bundle of diagnostics when a backend crashes, to be attached to bug reports
+type DiagnosticsService struct {
+	dir string
+	cl  *config.BackendConfigLoader
+}
+
+type crashSummary struct {
+	Model          string `json:"model"`
+	Backend        string `json:"backend"`
+	Address        string `json:"address"`
+	Time           string `json:"time"`
+	LocalAIVersion string `json:"localai_version"`
+}
+
+type hostInfo struct {
+	OS              string   `json:"os"`
+	Arch            string   `json:"arch"`
+	CPUs            int      `json:"cpus"`
+	CPUModel        string   `json:"cpu_model,omitempty"`
+	MemoryTotal     uint64   `json:"memory_total,omitempty"`
+	MemoryAvailable uint64   `json:"memory_available,omitempty"`
+	GPUs            []string `json:"gpus,omitempty"`
+}
+
+func NewDiagnosticsService(cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) *DiagnosticsService {
+	return &DiagnosticsService{
+		dir: appConfig.DiagnosticsDir,
+		cl:  cl,
+	}
+}
+
+// Collect writes the diagnostics bundle of a backend crash, it returns the name of the bundle
+func (ds *DiagnosticsService) Collect(crash model.BackendCrash) (string, error) {
+	if err := os.MkdirAll(ds.dir, 0750); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s.zip", crash.Time.Format("20060102-150405"), utils.SanitizeFileName(filepath.Base(crash.ModelID)))
+	f, err := os.OpenFile(filepath.Join(ds.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	files := map[string]interface{}{
+		"crash.json": crashSummary{
+			Model:          crash.ModelID,
+			Backend:        crash.Backend,
+			Address:        crash.Address,
+			Time:           crash.Time.Format(time.RFC3339),
+			LocalAIVersion: internal.PrintableVersion(),
+		},
+		"host.json":  collectHostInfo(),
+		"stdout.log": strings.Join(crash.Stdout, "\n"),
+		"stderr.log": strings.Join(crash.Stderr, "\n"),
+	}
+	if opts := backend.LastRequest(crash.ModelID); opts != nil {
+		files["request.json"] = opts
+	}
+	for _, c := range ds.cl.GetAllBackendConfigs() {
+		if c.Model == crash.ModelID {
+			dat, err := yaml.Marshal(c)
+			if err != nil {
+				return "", err
+			}
+			files["model.yaml"] = string(dat)
+			break
+		}
+	}
+
+	zw := zip.NewWriter(f)
+	for fileName, content := range files {
+		w, err := zw.Create(fileName)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := content.(string); ok {
+			_, err = w.Write([]byte(s))
+		} else {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(content)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+func collectHostInfo() hostInfo {
+	info := hostInfo{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		CPUs: runtime.NumCPU(),
+	}
+	if cpus, err := cpu.Info(); err == nil && len(cpus) > 0 {
+		info.CPUModel = cpus[0].ModelName
+	}
+	if vm, err := mem.VirtualMemory(); err == nil {
+		info.MemoryTotal = vm.Total
+		info.MemoryAvailable = vm.Available
+	}
+	if gpus, err := xsysinfo.GPUs(); err == nil {
+		for _, g := range gpus {
+			if g.DeviceInfo != nil && g.DeviceInfo.Vendor != nil && g.DeviceInfo.Product != nil {
+				info.GPUs = append(info.GPUs, strings.TrimSpace(g.DeviceInfo.Vendor.Name+" "+g.DeviceInfo.Product.Name))
+			}
+		}
+	}
+	return info
+}
+
+// List returns the diagnostics bundles, the most recent first
+func (ds *DiagnosticsService) List() ([]schema.DiagnosticsBundle, error) {
+	entries, err := os.ReadDir(ds.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	bundles := []schema.DiagnosticsBundle{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".zip" {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		bundles = append(bundles, schema.DiagnosticsBundle{Name: e.Name(), Created: fi.ModTime(), Bytes: fi.Size()})
+	}
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].Created.After(bundles[j].Created) })
+	return bundles, nil
+}
+
+// Path returns the path of a diagnostics bundle
+func (ds *DiagnosticsService) Path(name string) (string, error) {
+	if filepath.Ext(name) != ".zip" || utils.VerifyPath(name, ds.dir) != nil {
+		return "", fmt.Errorf("invalid bundle name %q", name)
+	}
+	path := filepath.Join(ds.dir, name)
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+		return "", fmt.Errorf("bundle %q not found", name)
+	}
+	return path, nil
+}
+
+// Delete removes a diagnostics bundle
+func (ds *DiagnosticsService) Delete(name string) error {
+	path, err := ds.Path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// OnBackendCrash is the crash handler of the model loader
+func (ds *DiagnosticsService) OnBackendCrash(crash model.BackendCrash) {
+	name, err := ds.Collect(crash)
+	if err != nil {
+		log.Error().Err(err).Str("model", crash.ModelID).Msg("failed to collect the diagnostics of the backend crash")
+		return
+	}
+	log.Warn().Str("model", crash.ModelID).Str("bundle", filepath.Join(ds.dir, name)).Msg("diagnostics of the backend crash collected")
+}
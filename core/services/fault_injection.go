@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// Fault is what is injected in a request by a fault rule
+type Fault struct {
+	Rule  string
+	Delay time.Duration
+	// ErrorStatus is the status the request fails with, 0 when it doesn't fail
+	ErrorStatus   int
+	TruncateAfter int
+}
+
+type faultRule struct {
+	schema.FaultRule
+	delay time.Duration
+}
+
+// FaultInjectionService holds the fault rules, and picks the faults injected in the requests
+type FaultInjectionService struct {
+	sync.Mutex
+	rules []*faultRule
+}
+
+func NewFaultInjectionService() *FaultInjectionService {
+	return &FaultInjectionService{}
+}
+
+// Add validates a rule and adds it after the existing ones, the first matching rule of a request applies
+func (fis *FaultInjectionService) Add(rule schema.FaultRule) (schema.FaultRule, error) {
+	r := &faultRule{FaultRule: rule}
+	if rule.Delay != "" {
+		d, err := time.ParseDuration(rule.Delay)
+		if err != nil || d < 0 {
+			return rule, fmt.Errorf("invalid delay %q", rule.Delay)
+		}
+		r.delay = d
+	}
+	switch {
+	case rule.ErrorRate < 0 || rule.ErrorRate > 1:
+		return rule, fmt.Errorf("error_rate must be between 0 and 1")
+	case rule.ErrorStatus != 0 && (rule.ErrorStatus < 400 || rule.ErrorStatus > 599):
+		return rule, fmt.Errorf("error_status must be an HTTP error status")
+	case rule.TruncateAfter < 0 || rule.Times < 0:
+		return rule, fmt.Errorf("truncate_after and times cannot be negative")
+	case r.delay == 0 && rule.ErrorRate == 0 && rule.TruncateAfter == 0:
+		return rule, fmt.Errorf("one of delay, error_rate and truncate_after is required")
+	}
+	if r.ErrorStatus == 0 {
+		r.ErrorStatus = 500
+	}
+
+	fis.Lock()
+	defer fis.Unlock()
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	for _, existing := range fis.rules {
+		if existing.ID == r.ID {
+			return rule, fmt.Errorf("a rule with id %s already exists", r.ID)
+		}
+	}
+	fis.rules = append(fis.rules, r)
+	return r.FaultRule, nil
+}
+
+func (fis *FaultInjectionService) List() []schema.FaultRule {
+	fis.Lock()
+	defer fis.Unlock()
+	rules := []schema.FaultRule{}
+	for _, r := range fis.rules {
+		rules = append(rules, r.FaultRule)
+	}
+	return rules
+}
+
+func (fis *FaultInjectionService) Delete(id string) error {
+	fis.Lock()
+	defer fis.Unlock()
+	for i, r := range fis.rules {
+		if r.ID == id {
+			fis.rules = append(fis.rules[:i], fis.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no rule with id %s", id)
+}
+
+// Clear removes all the rules
+func (fis *FaultInjectionService) Clear() {
+	fis.Lock()
+	defer fis.Unlock()
+	fis.rules = nil
+}
+
+// Match returns the fault to inject in a request, nil when no rule matches it. The rules limited to a number of
+// requests are removed once they are used up
+func (fis *FaultInjectionService) Match(path, model string) *Fault {
+	fis.Lock()
+	defer fis.Unlock()
+	for i, r := range fis.rules {
+		if (r.Model != "" && r.Model != model) || !strings.HasPrefix(path, r.Endpoint) {
+			continue
+		}
+
+		if r.Times > 0 {
+			r.Times--
+			if r.Times == 0 {
+				fis.rules = append(fis.rules[:i], fis.rules[i+1:]...)
+			}
+		}
+		fault := &Fault{Rule: r.ID, Delay: r.delay, TruncateAfter: r.TruncateAfter}
+		if r.ErrorRate > 0 && rand.Float64() < r.ErrorRate {
+			fault.ErrorStatus = r.ErrorStatus
+		}
+		return fault
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultInjectionMatch(t *testing.T) {
+	t.Run("applies the first rule matching the model and the endpoint", func(t *testing.T) {
+		fis := NewFaultInjectionService()
+		_, err := fis.Add(schema.FaultRule{ID: "chat", Model: "phi-2", Endpoint: "/v1/chat/completions", Delay: "2s"})
+		require.NoError(t, err)
+		_, err = fis.Add(schema.FaultRule{ID: "any", TruncateAfter: 3})
+		require.NoError(t, err)
+
+		fault := fis.Match("/v1/chat/completions", "phi-2")
+		require.NotNil(t, fault)
+		assert.Equal(t, &Fault{Rule: "chat", Delay: 2 * time.Second}, fault)
+
+		// the other models and endpoints fall back to the rule without model nor endpoint
+		for _, req := range [][2]string{{"/v1/chat/completions", "llama"}, {"/v1/embeddings", "phi-2"}} {
+			fault := fis.Match(req[0], req[1])
+			require.NotNil(t, fault, req)
+			assert.Equal(t, "any", fault.Rule, req)
+			assert.Equal(t, 3, fault.TruncateAfter)
+		}
+
+		require.NoError(t, fis.Delete("any"))
+		assert.Nil(t, fis.Match("/v1/embeddings", "phi-2"))
+		assert.Error(t, fis.Delete("any"))
+
+		fis.Clear()
+		assert.Nil(t, fis.Match("/v1/chat/completions", "phi-2"))
+	})
+
+	t.Run("matches the endpoints by prefix", func(t *testing.T) {
+		fis := NewFaultInjectionService()
+		_, err := fis.Add(schema.FaultRule{ID: "audio", Endpoint: "/v1/audio", ErrorRate: 1, ErrorStatus: 503})
+		require.NoError(t, err)
+
+		fault := fis.Match("/v1/audio/transcriptions", "whisper-1")
+		require.NotNil(t, fault)
+		assert.Equal(t, 503, fault.ErrorStatus)
+		assert.Nil(t, fis.Match("/v1/chat/completions", "whisper-1"))
+	})
+
+	t.Run("removes the rules once used up", func(t *testing.T) {
+		fis := NewFaultInjectionService()
+		_, err := fis.Add(schema.FaultRule{ID: "twice", ErrorRate: 1, Times: 2})
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			fault := fis.Match("/v1/completions", "phi-2")
+			require.NotNil(t, fault)
+			assert.Equal(t, 500, fault.ErrorStatus, "the status is 500 by default")
+		}
+		assert.Nil(t, fis.Match("/v1/completions", "phi-2"))
+		assert.Empty(t, fis.List())
+	})
+
+	t.Run("fails the requests at the error rate", func(t *testing.T) {
+		fis := NewFaultInjectionService()
+		_, err := fis.Add(schema.FaultRule{ID: "flaky", Model: "flaky", ErrorRate: 0.5})
+		require.NoError(t, err)
+		_, err = fis.Add(schema.FaultRule{ID: "delay", Delay: "10ms"})
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			assert.Zero(t, fis.Match("/v1/completions", "phi-2").ErrorStatus)
+		}
+
+		failed := 0
+		for i := 0; i < 1000; i++ {
+			if fis.Match("/v1/completions", "flaky").ErrorStatus != 0 {
+				failed++
+			}
+		}
+		assert.InDelta(t, 500, failed, 100)
+	})
+}
+
+func TestFaultInjectionAdd(t *testing.T) {
+	fis := NewFaultInjectionService()
+	for _, rule := range []schema.FaultRule{
+		{},
+		{Delay: "soon"},
+		{Delay: "-1s"},
+		{ErrorRate: 1.5},
+		{ErrorRate: 1, ErrorStatus: 200},
+		{TruncateAfter: -1},
+		{Delay: "1s", Times: -1},
+	} {
+		_, err := fis.Add(rule)
+		assert.Error(t, err, rule)
+	}
+	assert.Empty(t, fis.List())
+
+	rule, err := fis.Add(schema.FaultRule{Delay: "1s"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, rule.ID)
+	_, err = fis.Add(schema.FaultRule{ID: rule.ID, Delay: "1s"})
+	assert.Error(t, err, "the ids are unique")
+	assert.Len(t, fis.List(), 1)
+}
@@ -7,9 +7,11 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/pkg/events"
 	"github.com/mudler/LocalAI/pkg/startup"
 	"github.com/mudler/LocalAI/pkg/utils"
 	"gopkg.in/yaml.v2"
@@ -20,13 +22,20 @@ type GalleryService struct {
 	sync.Mutex
 	C        chan gallery.GalleryOp
 	statuses map[string]*gallery.GalleryOpStatus
+	// galleryEntries is the number of entries of the galleries at their last successful health check
+	galleryEntries map[string]int
 }
 
+// galleryHealthTimeout bounds the health check of the galleries
+const galleryHealthTimeout = 30 * time.Second
+
 func NewGalleryService(appConfig *config.ApplicationConfig) *GalleryService {
 	return &GalleryService{
 		appConfig: appConfig,
 		C:         make(chan gallery.GalleryOp),
 		statuses:  make(map[string]*gallery.GalleryOpStatus),
+
+		galleryEntries: make(map[string]int),
 	}
 }
 
@@ -62,6 +71,26 @@ func (g *GalleryService) GetAllStatus() map[string]*gallery.GalleryOpStatus {
 	return g.statuses
 }
 
+// CheckGalleries checks the health of the galleries in parallel, with the change of their entries since
+// their previous successful check
+func (g *GalleryService) CheckGalleries(galleries []config.Gallery) []gallery.GalleryHealth {
+	health := gallery.CheckGalleries(galleries, g.appConfig.ModelPath, galleryHealthTimeout)
+
+	g.Lock()
+	defer g.Unlock()
+	for i, h := range health {
+		if h.Status != gallery.GalleryStatusOK && h.Status != gallery.GalleryStatusEmpty {
+			continue
+		}
+		if previous, exists := g.galleryEntries[h.URL]; exists {
+			delta := h.Entries - previous
+			health[i].EntriesDelta = &delta
+		}
+		g.galleryEntries[h.URL] = h.Entries
+	}
+	return health
+}
+
 func (g *GalleryService) Start(c context.Context, cl *config.BackendConfigLoader) {
 	go func() {
 		for {
@@ -72,16 +101,25 @@ func (g *GalleryService) Start(c context.Context, cl *config.BackendConfigLoader
 				utils.ResetDownloadTimers()
 
 				g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Message: "processing", Progress: 0})
+				jobEvent := func(eventType string, data map[string]any) {
+					data["id"] = op.Id
+					data["model"] = op.GalleryModelName
+					data["deletion"] = op.Delete
+					g.appConfig.Events.Publish(eventType, data)
+				}
+				jobEvent(events.JobStarted, map[string]any{})
 
 				// updates the status with an error
 				var updateError func(e error)
 				if !g.appConfig.OpaqueErrors {
 					updateError = func(e error) {
 						g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Error: e, Processed: true, Message: "error: " + e.Error()})
+						jobEvent(events.JobFailed, map[string]any{"error": e.Error()})
 					}
 				} else {
 					updateError = func(_ error) {
 						g.UpdateStatus(op.Id, &gallery.GalleryOpStatus{Error: fmt.Errorf("an error occurred"), Processed: true})
+						jobEvent(events.JobFailed, map[string]any{"error": "an error occurred"})
 					}
 				}
 
@@ -165,6 +203,7 @@ func (g *GalleryService) Start(c context.Context, cl *config.BackendConfigLoader
 						GalleryModelName: op.GalleryModelName,
 						Message:          "completed",
 						Progress:         100})
+				jobEvent(events.JobCompleted, map[string]any{})
 			}
 		}
 	}()
@@ -175,41 +214,29 @@ type galleryModel struct {
 	ID                   string           `json:"id"`
 }
 
-func processRequests(modelPath string, enforceScan bool, galleries []config.Gallery, requests []galleryModel) error {
-	var err error
-	for _, r := range requests {
-		utils.ResetDownloadTimers()
-		if r.ID == "" {
-			err = prepareModel(modelPath, r.GalleryModel, utils.DisplayDownloadFunction, enforceScan)
-
-		} else {
-			err = gallery.InstallModelFromGallery(
-				galleries, r.ID, modelPath, r.GalleryModel, utils.DisplayDownloadFunction, enforceScan)
-		}
-	}
-	return err
-}
-
-func ApplyGalleryFromFile(modelPath, s string, enforceScan bool, galleries []config.Gallery) error {
+// ApplyGalleryFromFile applies the models listed in the YAML file s, up to parallelism at a time.
+// It returns the models that failed to be applied, keyed by name.
+func ApplyGalleryFromFile(modelPath, s string, enforceScan bool, galleries []config.Gallery, parallelism int) (map[string]error, error) {
 	dat, err := os.ReadFile(s)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var requests []galleryModel
 
 	if err := yaml.Unmarshal(dat, &requests); err != nil {
-		return err
+		return nil, err
 	}
 
-	return processRequests(modelPath, enforceScan, galleries, requests)
+	return processRequests(modelPath, enforceScan, galleries, requests, parallelism), nil
 }
 
-func ApplyGalleryFromString(modelPath, s string, enforceScan bool, galleries []config.Gallery) error {
+// ApplyGalleryFromString is like ApplyGalleryFromFile, with the models listed as JSON in s
+func ApplyGalleryFromString(modelPath, s string, enforceScan bool, galleries []config.Gallery, parallelism int) (map[string]error, error) {
 	var requests []galleryModel
 	err := json.Unmarshal([]byte(s), &requests)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return processRequests(modelPath, enforceScan, galleries, requests)
+	return processRequests(modelPath, enforceScan, galleries, requests, parallelism), nil
 }
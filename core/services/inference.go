@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// predict runs a single prompt on the model, templated with its completion template,
+// and returns the finetuned response. It's replaced in the tests
+var predict = func(ctx context.Context, ml *model.ModelLoader, appConfig *config.ApplicationConfig, cfg *config.BackendConfig, prompt string) (string, error) {
+	predInput := prompt
+	if cfg.TemplateConfig.UseTokenizerTemplate && !cfg.Raw {
+		// let the backend apply the chat template to the messages
+		predInput = ""
+	} else if cfg.TemplateConfig.Completion != "" && !cfg.Raw {
+		templatedInput, err := ml.EvaluateTemplateForPrompt(model.CompletionPromptTemplate, cfg.TemplateConfig.Completion, model.PromptTemplateData{
+			SystemPrompt: cfg.SystemPrompt,
+			Input:        prompt,
+		})
+		if err == nil {
+			predInput = templatedInput
+		}
+	}
+
+	fn, err := backend.ModelInference(ctx, predInput, []schema.Message{{Role: "user", Content: prompt, StringContent: prompt}}, nil, ml, *cfg, appConfig, nil)
+	if err != nil {
+		return "", err
+	}
+	prediction, err := fn()
+	if err != nil {
+		return "", err
+	}
+	return backend.Finetune(*cfg, predInput, prediction.Response), nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+)
+
+// queueSmoothing is the weight of the last request in the average duration of the inferences
+const queueSmoothing = 0.2
+
+// QueueFullError is the error of the requests refused because all the inference slots are busy and the queue is
+// full
+type QueueFullError struct {
+	Running       int
+	Queued        int
+	EstimatedWait time.Duration // 0 while no inference is done
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("the server is busy: %d inferences are running and %d are queued", e.Running, e.Queued)
+}
+
+// Priority is the class of an inference request: the queued requests of higher priority take the free slots first
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+	priorities
+)
+
+// ParsePriority returns the priority of its name in the API keys and the requests
+func ParsePriority(name string) (Priority, bool) {
+	switch name {
+	case config.RequestPriorityLow:
+		return PriorityLow, true
+	case config.RequestPriorityNormal:
+		return PriorityNormal, true
+	case config.RequestPriorityHigh:
+		return PriorityHigh, true
+	}
+	return PriorityNormal, false
+}
+
+// queuedRequest is a request waiting for a slot, ready once it gets one or once it is refused
+type queuedRequest struct {
+	ready   chan struct{}
+	refused *QueueFullError // set when a request of higher priority took its place in the full queue
+}
+
+// InferenceQueue bounds the inferences running at once, queueing the requests beyond the limit up to a maximum.
+// The queued requests run by priority, then in their order of arrival
+type InferenceQueue struct {
+	sync.Mutex
+	maxRunning, maxQueued int
+	running               int
+	waiting               [priorities][]*queuedRequest
+	duration              float64 // average duration of the inferences, in seconds
+
+	now func() time.Time
+}
+
+func NewInferenceQueue(maxRunning, maxQueued int) *InferenceQueue {
+	return &InferenceQueue{
+		maxRunning: maxRunning,
+		maxQueued:  maxQueued,
+		now:        time.Now,
+	}
+}
+
+// Acquire takes an inference slot, waiting in the queue while they are all busy. When the queue is full, the
+// request takes the place of the last queued request of the lowest priority below its own, which is refused. It
+// fails with a QueueFullError when the queue is full of requests of its priority or higher, and when the context
+// is done while waiting. The returned function releases the slot
+func (q *InferenceQueue) Acquire(ctx context.Context, priority Priority) (func(), error) {
+	q.Lock()
+	if q.running < q.maxRunning && q.queued() == 0 {
+		q.running++
+		q.Unlock()
+		return q.release(q.now()), nil
+	}
+	if q.queued() >= q.maxQueued && !q.evict(priority) {
+		err := q.full(priority)
+		q.Unlock()
+		return nil, err
+	}
+	r := &queuedRequest{ready: make(chan struct{})}
+	q.waiting[priority] = append(q.waiting[priority], r)
+	q.Unlock()
+
+	select {
+	case <-r.ready:
+		if r.refused != nil {
+			return nil, r.refused
+		}
+		return q.release(q.now()), nil
+	case <-ctx.Done():
+		q.Lock()
+		defer q.Unlock()
+		for i, w := range q.waiting[priority] {
+			if w == r {
+				q.waiting[priority] = append(q.waiting[priority][:i], q.waiting[priority][i+1:]...)
+				return nil, ctx.Err()
+			}
+		}
+		// the slot was handed over meanwhile, it goes to the next request
+		if r.refused == nil {
+			q.next()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// release returns the function releasing a slot taken at start, which measures the duration of the inference
+func (q *InferenceQueue) release(start time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.Lock()
+			defer q.Unlock()
+			elapsed := q.now().Sub(start).Seconds()
+			if q.duration == 0 {
+				q.duration = elapsed
+			} else {
+				q.duration += queueSmoothing * (elapsed - q.duration)
+			}
+			q.next()
+		})
+	}
+}
+
+// next hands the slot released over to the first queued request of the highest priority
+func (q *InferenceQueue) next() {
+	for p := priorities - 1; p >= PriorityLow; p-- {
+		if len(q.waiting[p]) > 0 {
+			close(q.waiting[p][0].ready)
+			q.waiting[p] = q.waiting[p][1:]
+			return
+		}
+	}
+	q.running--
+}
+
+// evict refuses the last queued request of the lowest priority below priority, to free its place in the queue
+func (q *InferenceQueue) evict(priority Priority) bool {
+	for p := PriorityLow; p < priority; p++ {
+		if n := len(q.waiting[p]); n > 0 {
+			r := q.waiting[p][n-1]
+			q.waiting[p] = q.waiting[p][:n-1]
+			r.refused = q.full(p)
+			close(r.ready)
+			return true
+		}
+	}
+	return false
+}
+
+func (q *InferenceQueue) queued() int {
+	n := 0
+	for _, w := range q.waiting {
+		n += len(w)
+	}
+	return n
+}
+
+// full returns the error of a request of the priority refused, with its wait estimated from the average duration
+// of the inferences: the requests queued before it and the new one start as the running ones complete
+func (q *InferenceQueue) full(priority Priority) *QueueFullError {
+	ahead := 0
+	for p := priority; p < priorities; p++ {
+		ahead += len(q.waiting[p])
+	}
+	rounds := (ahead + q.maxRunning) / q.maxRunning
+	return &QueueFullError{
+		Running:       q.running,
+		Queued:        q.queued(),
+		EstimatedWait: time.Duration(float64(rounds) * q.duration * float64(time.Second)),
+	}
+}
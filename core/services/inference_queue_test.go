@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferenceQueue(t *testing.T) {
+	t.Run("runs the requests up to the limit", func(t *testing.T) {
+		q := NewInferenceQueue(2, 0)
+		_, err := q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+		release, err := q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+
+		_, err = q.Acquire(context.Background(), PriorityNormal)
+		var fullErr *QueueFullError
+		require.ErrorAs(t, err, &fullErr)
+		assert.Equal(t, 2, fullErr.Running)
+
+		release()
+		release()
+		_, err = q.Acquire(context.Background(), PriorityNormal)
+		assert.NoError(t, err)
+	})
+
+	t.Run("queues the requests in their order of arrival", func(t *testing.T) {
+		q := NewInferenceQueue(1, 2)
+		release, err := q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+
+		started := make(chan int, 2)
+		for i := 0; i < 2; i++ {
+			go func(i int) {
+				release, err := q.Acquire(context.Background(), PriorityNormal)
+				if assert.NoError(t, err) {
+					started <- i
+					release()
+				}
+			}(i)
+			// the requests are queued one after the other
+			require.Eventually(t, func() bool {
+				q.Lock()
+				defer q.Unlock()
+				return q.queued() == i+1
+			}, time.Second, time.Millisecond)
+		}
+
+		_, err = q.Acquire(context.Background(), PriorityNormal)
+		var fullErr *QueueFullError
+		require.ErrorAs(t, err, &fullErr)
+		assert.Equal(t, 2, fullErr.Queued)
+
+		release()
+		assert.Equal(t, 0, <-started)
+		assert.Equal(t, 1, <-started)
+	})
+
+	t.Run("estimates the wait from the duration of the inferences", func(t *testing.T) {
+		clock := time.Now()
+		q := NewInferenceQueue(2, 0)
+		q.now = func() time.Time { return clock }
+
+		release, err := q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+		clock = clock.Add(10 * time.Second)
+		release()
+
+		_, err = q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+		_, err = q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+		_, err = q.Acquire(context.Background(), PriorityNormal)
+		var fullErr *QueueFullError
+		require.ErrorAs(t, err, &fullErr)
+		assert.Equal(t, 10*time.Second, fullErr.EstimatedWait)
+	})
+
+	t.Run("leaves the queue when the request is canceled", func(t *testing.T) {
+		q := NewInferenceQueue(1, 1)
+		release, err := q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = q.Acquire(ctx, PriorityNormal)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		release()
+		_, err = q.Acquire(context.Background(), PriorityNormal)
+		assert.NoError(t, err)
+	})
+	t.Run("runs the requests of higher priority first", func(t *testing.T) {
+		q := NewInferenceQueue(1, 3)
+		release, err := q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+
+		started := make(chan Priority, 3)
+		for i, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+			go func(p Priority) {
+				release, err := q.Acquire(context.Background(), p)
+				if assert.NoError(t, err) {
+					started <- p
+					release()
+				}
+			}(p)
+			require.Eventually(t, func() bool {
+				q.Lock()
+				defer q.Unlock()
+				return q.queued() == i+1
+			}, time.Second, time.Millisecond)
+		}
+
+		release()
+		assert.Equal(t, PriorityHigh, <-started)
+		assert.Equal(t, PriorityNormal, <-started)
+		assert.Equal(t, PriorityLow, <-started)
+	})
+
+	t.Run("refuses the requests of lower priority to queue the higher ones", func(t *testing.T) {
+		q := NewInferenceQueue(1, 1)
+		release, err := q.Acquire(context.Background(), PriorityNormal)
+		require.NoError(t, err)
+
+		refused := make(chan error, 1)
+		go func() {
+			_, err := q.Acquire(context.Background(), PriorityLow)
+			refused <- err
+		}()
+		require.Eventually(t, func() bool {
+			q.Lock()
+			defer q.Unlock()
+			return q.queued() == 1
+		}, time.Second, time.Millisecond)
+
+		// the queue is full of requests of the same priority
+		_, err = q.Acquire(context.Background(), PriorityLow)
+		var fullErr *QueueFullError
+		require.ErrorAs(t, err, &fullErr)
+
+		started := make(chan struct{})
+		go func() {
+			release, err := q.Acquire(context.Background(), PriorityHigh)
+			if assert.NoError(t, err) {
+				close(started)
+				release()
+			}
+		}()
+		require.ErrorAs(t, <-refused, &fullErr)
+
+		release()
+		<-started
+	})
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+const (
+	// meetingNotesChars is the length of the parts of the transcripts summarized one by one, without a context size
+	meetingNotesChars = 8000
+	// meetingCharsPerToken estimates the tokens of the transcripts to fit them in the context of the model
+	meetingCharsPerToken = 4
+	noActionItems        = "NONE"
+)
+
+const meetingNotesPrompt = `You are taking the notes of a meeting. Write a short summary of the transcript below, followed by the action items decided in the meeting, with their owner when known.
+Reply in this format, in the language of the transcript:
+Summary:
+<the summary>
+Action items:
+- <an action item>
+If no action item was decided, reply with ` + noActionItems + ` in place of the action items.
+
+Transcript:
+%s`
+
+// MeetingNotesService takes the notes of the meetings from their transcripts: a summary and the action items
+// written by an LLM
+type MeetingNotesService struct {
+	appConfig *config.ApplicationConfig
+	ml        *model.ModelLoader
+}
+
+func NewMeetingNotesService(ml *model.ModelLoader, appConfig *config.ApplicationConfig) *MeetingNotesService {
+	return &MeetingNotesService{
+		appConfig: appConfig,
+		ml:        ml,
+	}
+}
+
+// Notes returns the notes of the transcript taken by the model. The transcripts exceeding the context of the model
+// are split by segments, whose notes are merged by the model
+func (ms *MeetingNotesService) Notes(ctx context.Context, cfg *config.BackendConfig, transcript *schema.TranscriptionResult) (*schema.MeetingNotes, error) {
+	parts := splitTranscript(transcript, transcriptPartChars(cfg))
+	notes := []*schema.MeetingNotes{}
+	for _, part := range parts {
+		n, err := ms.notes(ctx, cfg, part)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	if len(notes) == 1 {
+		return notes[0], nil
+	}
+
+	// the action items of the parts are kept as they are, only their summaries are merged
+	summaries := []string{}
+	merged := &schema.MeetingNotes{ActionItems: []string{}}
+	for _, n := range notes {
+		summaries = append(summaries, n.Summary)
+		merged.ActionItems = append(merged.ActionItems, n.ActionItems...)
+	}
+	summary, err := ms.notes(ctx, cfg, strings.Join(summaries, "\n\n"))
+	if err != nil {
+		return nil, err
+	}
+	merged.Summary = summary.Summary
+	return merged, nil
+}
+
+func (ms *MeetingNotesService) notes(ctx context.Context, cfg *config.BackendConfig, transcript string) (*schema.MeetingNotes, error) {
+	prediction, err := predict(ctx, ms.ml, ms.appConfig, cfg, fmt.Sprintf(meetingNotesPrompt, transcript))
+	if err != nil {
+		return nil, fmt.Errorf("failed to take the notes of the meeting: %w", err)
+	}
+	return parseMeetingNotes(prediction), nil
+}
+
+// transcriptPartChars returns the length of the parts of the transcripts fitting in half of the context of the
+// model, the other half being left to the prompt and the notes
+func transcriptPartChars(cfg *config.BackendConfig) int {
+	if cfg.ContextSize == nil || *cfg.ContextSize <= 0 {
+		return meetingNotesChars
+	}
+	return *cfg.ContextSize / 2 * meetingCharsPerToken
+}
+
+// splitTranscript splits the text of the transcript into parts of up to maxChars, between its segments
+func splitTranscript(transcript *schema.TranscriptionResult, maxChars int) []string {
+	if len(transcript.Segments) == 0 {
+		return []string{strings.TrimSpace(transcript.Text)}
+	}
+	parts := []string{}
+	var part strings.Builder
+	for _, s := range transcript.Segments {
+		text := strings.TrimSpace(s.Text)
+		if part.Len() > 0 && part.Len()+len(text)+1 > maxChars {
+			parts = append(parts, part.String())
+			part.Reset()
+		}
+		if part.Len() > 0 {
+			part.WriteString(" ")
+		}
+		part.WriteString(text)
+	}
+	return append(parts, part.String())
+}
+
+// parseMeetingNotes reads the summary and the action items of the answer of the model, which is the summary when
+// it doesn't follow the format
+func parseMeetingNotes(prediction string) *schema.MeetingNotes {
+	notes := &schema.MeetingNotes{ActionItems: []string{}}
+	prediction = strings.TrimSpace(prediction)
+	summary, actionItems, found := strings.Cut(prediction, "Action items:")
+	notes.Summary = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(summary), "Summary:"))
+	if !found {
+		return notes
+	}
+	for _, line := range strings.Split(actionItems, "\n") {
+		item := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+		if item != "" && item != noActionItems {
+			notes.ActionItems = append(notes.ActionItems, item)
+		}
+	}
+	return notes
+}
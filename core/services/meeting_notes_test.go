@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeetingNotes(t *testing.T) {
+	t.Run("reads the summary and the action items", func(t *testing.T) {
+		notes := parseMeetingNotes("Summary:\nThe team planned the release.\n\nAction items:\n- Alice writes the changelog\n* Bob tags the release\n")
+		assert.Equal(t, &schema.MeetingNotes{
+			Summary:     "The team planned the release.",
+			ActionItems: []string{"Alice writes the changelog", "Bob tags the release"},
+		}, notes)
+
+		notes = parseMeetingNotes("Summary: A short sync.\nAction items:\n" + noActionItems)
+		assert.Equal(t, "A short sync.", notes.Summary)
+		assert.Empty(t, notes.ActionItems)
+	})
+
+	t.Run("keeps the answers out of the format as summary", func(t *testing.T) {
+		notes := parseMeetingNotes("The team planned the release.")
+		assert.Equal(t, "The team planned the release.", notes.Summary)
+		assert.Empty(t, notes.ActionItems)
+	})
+
+	t.Run("splits the long transcripts between their segments", func(t *testing.T) {
+		transcript := &schema.TranscriptionResult{Segments: []schema.Segment{
+			{Text: " Hello everyone."}, {Text: " Let's start."}, {Text: " First, the release."},
+		}}
+		assert.Equal(t, []string{"Hello everyone. Let's start. First, the release."}, splitTranscript(transcript, 100))
+		assert.Equal(t, []string{"Hello everyone. Let's start.", "First, the release."}, splitTranscript(transcript, 30))
+
+		assert.Equal(t, []string{"Hello"}, splitTranscript(&schema.TranscriptionResult{Text: " Hello "}, 30))
+	})
+
+	t.Run("fits the parts in the context of the model", func(t *testing.T) {
+		contextSize := 4096
+		assert.Equal(t, 8192, transcriptPartChars(&config.BackendConfig{LLMConfig: config.LLMConfig{ContextSize: &contextSize}}))
+		assert.Equal(t, meetingNotesChars, transcriptPartChars(&config.BackendConfig{}))
+	})
+}
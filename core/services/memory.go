@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	MemoriesConfigFile = "memories.json"
+
+	// maxRecalledMemories is the number of memories injected in the system prompt
+	maxRecalledMemories = 10
+	noMemories          = "NONE"
+)
+
+const memoryExtractionPrompt = `You are the memory of an assistant. Extract from the last exchange of the conversation below the facts about the user that are worth remembering in future conversations: preferences, personal details, ongoing projects. Ignore small talk, one-off requests and the facts that are already known.
+Reply with one short fact per line, written in the third person, without numbering. If there is nothing new to remember, reply with ` + noMemories + `.
+
+Known facts:
+%s
+
+User: %s
+
+Assistant: %s
+
+New facts:`
+
+// MemoryService stores the facts learned about the users in their conversations,
+// and recalls the relevant ones in the following conversations
+type MemoryService struct {
+	appConfig *config.ApplicationConfig
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+
+	sync.Mutex
+	memories map[string][]schema.Memory // by user
+}
+
+func NewMemoryService(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) *MemoryService {
+	ms := &MemoryService{
+		appConfig: appConfig,
+		cl:        cl,
+		ml:        ml,
+		memories:  map[string][]schema.Memory{},
+	}
+	utils.LoadConfig(appConfig.ConfigsDir, MemoriesConfigFile, &ms.memories)
+	return ms
+}
+
+// Enabled returns true if the memories are learned and injected in the conversations
+func (ms *MemoryService) Enabled() bool {
+	return ms.appConfig.MemoryModel != ""
+}
+
+// List returns the memories of the user
+func (ms *MemoryService) List(user string) []schema.Memory {
+	ms.Lock()
+	defer ms.Unlock()
+	return append([]schema.Memory{}, ms.memories[user]...)
+}
+
+// Add stores a new memory for the user
+func (ms *MemoryService) Add(user, content string) schema.Memory {
+	ms.Lock()
+	defer ms.Unlock()
+	m := ms.add(user, content)
+	ms.save()
+	return m
+}
+
+// Update replaces the content of a memory of the user
+func (ms *MemoryService) Update(user, id, content string) (schema.Memory, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	for i, m := range ms.memories[user] {
+		if m.ID == id {
+			m.Content = content
+			m.UpdatedAt = time.Now()
+			ms.memories[user][i] = m
+			ms.save()
+			return m, nil
+		}
+	}
+	return schema.Memory{}, fmt.Errorf("unable to find memory %q for user %q", id, user)
+}
+
+// Delete forgets a memory of the user
+func (ms *MemoryService) Delete(user, id string) (schema.Memory, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	for i, m := range ms.memories[user] {
+		if m.ID == id {
+			ms.memories[user] = append(ms.memories[user][:i], ms.memories[user][i+1:]...)
+			if len(ms.memories[user]) == 0 {
+				delete(ms.memories, user)
+			}
+			ms.save()
+			return m, nil
+		}
+	}
+	return schema.Memory{}, fmt.Errorf("unable to find memory %q for user %q", id, user)
+}
+
+// Recall returns the memories of the user most relevant to the query:
+// the ones sharing more words with it first, then the most recent ones
+func (ms *MemoryService) Recall(user, query string) []schema.Memory {
+	memories := ms.List(user)
+
+	queryWords := memoryWords(query)
+	scores := make(map[string]int, len(memories))
+	for _, m := range memories {
+		for w := range memoryWords(m.Content) {
+			if queryWords[w] {
+				scores[m.ID]++
+			}
+		}
+	}
+
+	sort.SliceStable(memories, func(i, j int) bool {
+		if scores[memories[i].ID] != scores[memories[j].ID] {
+			return scores[memories[i].ID] > scores[memories[j].ID]
+		}
+		return memories[i].UpdatedAt.After(memories[j].UpdatedAt)
+	})
+
+	if len(memories) > maxRecalledMemories {
+		memories = memories[:maxRecalledMemories]
+	}
+	return memories
+}
+
+// Inject adds the memories of the user relevant to the last message to the system prompt of the conversation.
+// When the conversation has no system message, one is added with the system prompt of the model.
+func (ms *MemoryService) Inject(user string, messages []schema.Message, systemPrompt string) []schema.Message {
+	if len(messages) == 0 {
+		return messages
+	}
+
+	memories := ms.Recall(user, messages[len(messages)-1].StringContent)
+	if len(memories) == 0 {
+		return messages
+	}
+
+	facts := "Facts you remember about the user from previous conversations:"
+	for _, m := range memories {
+		facts += "\n- " + m.Content
+	}
+
+	for i, m := range messages {
+		if m.Role == "system" && m.StringContent != "" {
+			content := m.StringContent + "\n\n" + facts
+			messages[i].Content = content
+			messages[i].StringContent = content
+			return messages
+		}
+	}
+
+	content := facts
+	if systemPrompt != "" {
+		content = systemPrompt + "\n\n" + facts
+	}
+	return append([]schema.Message{{Role: "system", Content: content, StringContent: content}}, messages...)
+}
+
+// Learn extracts with the memory model the facts worth remembering from the last exchange
+// of the conversation, and stores them for the user
+func (ms *MemoryService) Learn(ctx context.Context, user string, messages []schema.Message, reply string) error {
+	var question string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			question = messages[i].StringContent
+			break
+		}
+	}
+	if question == "" || reply == "" {
+		return nil
+	}
+
+	cfg, err := ms.cl.LoadBackendConfigFileByName(ms.appConfig.MemoryModel, ms.appConfig.ModelPath, ms.appConfig.ToConfigLoaderOptions()...)
+	if err != nil {
+		return err
+	}
+
+	known := []string{}
+	for _, m := range ms.List(user) {
+		known = append(known, "- "+m.Content)
+	}
+	if len(known) == 0 {
+		known = append(known, noMemories)
+	}
+
+	prompt := fmt.Sprintf(memoryExtractionPrompt, strings.Join(known, "\n"), question, reply)
+	response, err := predict(ctx, ms.ml, ms.appConfig, cfg, prompt)
+	if err != nil {
+		return err
+	}
+
+	ms.Lock()
+	defer ms.Unlock()
+
+	existing := map[string]bool{}
+	for _, m := range ms.memories[user] {
+		existing[strings.ToLower(m.Content)] = true
+	}
+
+	learned := 0
+	for _, line := range strings.Split(response, "\n") {
+		fact := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+		if fact == "" || strings.EqualFold(fact, noMemories) || existing[strings.ToLower(fact)] {
+			continue
+		}
+		ms.add(user, fact)
+		existing[strings.ToLower(fact)] = true
+		learned++
+	}
+
+	if learned > 0 {
+		log.Debug().Str("user", user).Int("memories", learned).Msg("learned new memories")
+		ms.save()
+	}
+	return nil
+}
+
+func (ms *MemoryService) add(user, content string) schema.Memory {
+	now := time.Now()
+	m := schema.Memory{
+		ID:        uuid.New().String(),
+		User:      user,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	ms.memories[user] = append(ms.memories[user], m)
+	return m
+}
+
+func (ms *MemoryService) save() {
+	utils.SaveConfig(ms.appConfig.ConfigsDir, MemoriesConfigFile, ms.memories)
+}
+
+// memoryWords returns the lowercase words of s, skipping the shortest ones that are mostly stop words
+func memoryWords(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		if len(w) > 3 {
+			words[w] = true
+		}
+	}
+	return words
+}
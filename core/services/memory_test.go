@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRoundTrip(t *testing.T) {
+	appConfig := &config.ApplicationConfig{ConfigsDir: t.TempDir(), ModelPath: t.TempDir(), MemoryModel: "memory-model"}
+	ms := NewMemoryService(config.NewBackendConfigLoader(appConfig.ModelPath), nil, appConfig)
+	require.True(t, ms.Enabled())
+
+	prompts := []string{}
+	response := "- Alice works on a compiler written in Rust\n* Alice prefers short answers\n\nNONE\n"
+	original := predict
+	predict = func(_ context.Context, _ *model.ModelLoader, _ *config.ApplicationConfig, cfg *config.BackendConfig, prompt string) (string, error) {
+		assert.Equal(t, "memory-model", cfg.Model)
+		prompts = append(prompts, prompt)
+		return response, nil
+	}
+	t.Cleanup(func() { predict = original })
+
+	conversation := []schema.Message{
+		{Role: "user", StringContent: "I'm working on my Rust compiler, keep it short"},
+		{Role: "assistant", StringContent: "Sure."},
+		{Role: "user", StringContent: "How do I parse generics?"},
+	}
+	require.NoError(t, ms.Learn(context.Background(), "alice", conversation, "Use a Pratt parser."))
+	require.Len(t, prompts, 1)
+	assert.Contains(t, prompts[0], "User: How do I parse generics?")
+	assert.Contains(t, prompts[0], "Assistant: Use a Pratt parser.")
+	assert.Contains(t, prompts[0], "Known facts:\nNONE")
+
+	memories := ms.List("alice")
+	require.Len(t, memories, 2)
+	assert.Equal(t, "Alice works on a compiler written in Rust", memories[0].Content)
+	assert.Equal(t, "Alice prefers short answers", memories[1].Content)
+	assert.Empty(t, ms.List("bob"))
+
+	// the known facts are given to the model, and not stored twice
+	response = "alice prefers short answers\nAlice lives in Lyon"
+	require.NoError(t, ms.Learn(context.Background(), "alice", conversation, "Use a Pratt parser."))
+	assert.Contains(t, prompts[1], "Known facts:\n- Alice works on a compiler written in Rust\n- Alice prefers short answers")
+	require.Len(t, ms.List("alice"), 3)
+
+	// the memories are stored, and loaded back
+	ms = NewMemoryService(config.NewBackendConfigLoader(appConfig.ModelPath), nil, appConfig)
+	require.Len(t, ms.List("alice"), 3)
+
+	t.Run("injects the relevant memories in the system prompt", func(t *testing.T) {
+		messages := ms.Inject("alice", []schema.Message{{Role: "user", StringContent: "Which compiler should I write next?"}}, "You are helpful.")
+		require.Len(t, messages, 2)
+		assert.Equal(t, "system", messages[0].Role)
+		assert.Equal(t, "You are helpful.\n\nFacts you remember about the user from previous conversations:\n"+
+			"- Alice works on a compiler written in Rust\n- Alice lives in Lyon\n- Alice prefers short answers", messages[0].StringContent)
+		assert.Equal(t, messages[0].StringContent, messages[0].Content)
+
+		messages = ms.Inject("alice", []schema.Message{
+			{Role: "system", StringContent: "Be brief."},
+			{Role: "user", StringContent: "Hello"},
+		}, "You are helpful.")
+		require.Len(t, messages, 2)
+		assert.Contains(t, messages[0].StringContent, "Be brief.\n\nFacts you remember")
+		assert.NotContains(t, messages[0].StringContent, "You are helpful.")
+	})
+
+	t.Run("leaves the conversations of the users without memories", func(t *testing.T) {
+		messages := []schema.Message{{Role: "user", StringContent: "Hello"}}
+		assert.Equal(t, messages, ms.Inject("bob", messages, "You are helpful."))
+	})
+
+	t.Run("doesn't learn without an exchange", func(t *testing.T) {
+		require.NoError(t, ms.Learn(context.Background(), "bob", []schema.Message{{Role: "assistant", StringContent: "Hi"}}, "Hi"))
+		require.NoError(t, ms.Learn(context.Background(), "bob", conversation, ""))
+		assert.Len(t, prompts, 2)
+		assert.Empty(t, ms.List("bob"))
+	})
+}
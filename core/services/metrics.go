@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"sync"
+	"time"
 
+	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/prometheus"
@@ -10,19 +13,143 @@ import (
 	metricApi "go.opentelemetry.io/otel/sdk/metric"
 )
 
+// maxUserLabels bounds the cardinality of the user label: users beyond
+// the first maxUserLabels seen are reported as "other"
+const maxUserLabels = 100
+
 type LocalAIMetricsService struct {
 	Meter         metric.Meter
 	ApiTimeMetric metric.Float64Histogram
+
+	// per-model statistics, nil until RegisterModelMetrics is called
+	modelRequests   metric.Int64Counter
+	modelTokens     metric.Int64Counter
+	modelFirstToken metric.Float64Histogram
+	modelQueueDepth metric.Int64UpDownCounter
+	modelLoadEvents metric.Int64Counter
+
+	usersMu sync.Mutex
+	users   map[string]struct{}
 }
 
-func (m *LocalAIMetricsService) ObserveAPICall(method string, path string, duration float64) {
+func (m *LocalAIMetricsService) userLabel(user string) string {
+	if user == "" {
+		return ""
+	}
+
+	m.usersMu.Lock()
+	defer m.usersMu.Unlock()
+
+	if _, ok := m.users[user]; ok {
+		return user
+	}
+	if len(m.users) >= maxUserLabels {
+		return "other"
+	}
+	m.users[user] = struct{}{}
+	return user
+}
+
+func (m *LocalAIMetricsService) ObserveAPICall(method string, path string, user string, duration float64) {
 	opts := metric.WithAttributes(
 		attribute.String("method", method),
 		attribute.String("path", path),
+		attribute.String("user", m.userLabel(user)),
 	)
 	m.ApiTimeMetric.Record(context.Background(), duration, opts)
 }
 
+// RegisterBackendProcessMetrics exposes the last sample of the resources used by the backend processes
+func (m *LocalAIMetricsService) RegisterBackendProcessMetrics(ml *model.ModelLoader) error {
+	cpu, err := m.Meter.Float64ObservableGauge("backend_process_cpu_percent", metric.WithDescription("CPU usage of the backend process"))
+	if err != nil {
+		return err
+	}
+	rss, err := m.Meter.Int64ObservableGauge("backend_process_rss_bytes", metric.WithDescription("Resident memory of the backend process"))
+	if err != nil {
+		return err
+	}
+	fds, err := m.Meter.Int64ObservableGauge("backend_process_open_fds", metric.WithDescription("Open file descriptors of the backend process"))
+	if err != nil {
+		return err
+	}
+	uptime, err := m.Meter.Float64ObservableGauge("backend_process_uptime_seconds", metric.WithDescription("Uptime of the backend process"))
+	if err != nil {
+		return err
+	}
+	gpu, err := m.Meter.Float64ObservableGauge("backend_process_gpu_utilization_percent", metric.WithDescription("GPU utilization of the backend process"))
+	if err != nil {
+		return err
+	}
+	vram, err := m.Meter.Int64ObservableGauge("backend_process_vram_bytes", metric.WithDescription("GPU memory used by the backend process"))
+	if err != nil {
+		return err
+	}
+
+	_, err = m.Meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for modelName, s := range ml.AllProcessStats() {
+			opts := metric.WithAttributes(attribute.String("model", modelName))
+			o.ObserveFloat64(cpu, s.CPUPercent, opts)
+			o.ObserveInt64(rss, int64(s.RSS), opts)
+			o.ObserveInt64(fds, int64(s.OpenFDs), opts)
+			o.ObserveFloat64(uptime, s.UptimeSeconds, opts)
+			o.ObserveFloat64(gpu, s.GPUUtilization, opts)
+			o.ObserveInt64(vram, int64(s.VRAM), opts)
+		}
+		return nil
+	}, cpu, rss, fds, uptime, gpu, vram)
+	return err
+}
+
+// RegisterModelMetrics exposes the per-model statistics of the requests, and the models loaded and unloaded by
+// the model loader
+func (m *LocalAIMetricsService) RegisterModelMetrics(ml *model.ModelLoader) error {
+	var err error
+	if m.modelRequests, err = m.Meter.Int64Counter("model_requests", metric.WithDescription("Text generation requests of the model")); err != nil {
+		return err
+	}
+	if m.modelTokens, err = m.Meter.Int64Counter("model_generated_tokens", metric.WithDescription("Tokens generated by the model")); err != nil {
+		return err
+	}
+	if m.modelFirstToken, err = m.Meter.Float64Histogram("model_time_to_first_token_seconds",
+		metric.WithDescription("Time from the start of the request to the first token streamed by the model"),
+		metric.WithExplicitBucketBoundaries(0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60)); err != nil {
+		return err
+	}
+	if m.modelQueueDepth, err = m.Meter.Int64UpDownCounter("model_queue_depth", metric.WithDescription("Requests of the model waiting for their first token")); err != nil {
+		return err
+	}
+	if m.modelLoadEvents, err = m.Meter.Int64Counter("model_backend_events", metric.WithDescription("Models loaded and unloaded by the backends")); err != nil {
+		return err
+	}
+
+	ml.AddModelEventHandler(m.ObserveModelEvent)
+	return nil
+}
+
+func (m *LocalAIMetricsService) ObserveRequest(modelName string) {
+	m.modelRequests.Add(context.Background(), 1, metric.WithAttributes(attribute.String("model", modelName)))
+}
+
+func (m *LocalAIMetricsService) ObserveTokens(modelName string, tokens int) {
+	m.modelTokens.Add(context.Background(), int64(tokens), metric.WithAttributes(attribute.String("model", modelName)))
+}
+
+func (m *LocalAIMetricsService) ObserveTimeToFirstToken(modelName string, d time.Duration) {
+	m.modelFirstToken.Record(context.Background(), d.Seconds(), metric.WithAttributes(attribute.String("model", modelName)))
+}
+
+func (m *LocalAIMetricsService) AddQueued(modelName string, delta int) {
+	m.modelQueueDepth.Add(context.Background(), int64(delta), metric.WithAttributes(attribute.String("model", modelName)))
+}
+
+func (m *LocalAIMetricsService) ObserveModelEvent(modelID string, event model.ModelEvent) {
+	m.modelLoadEvents.Add(context.Background(), 1, metric.WithAttributes(
+		attribute.String("model", modelID),
+		attribute.String("event", string(event)),
+	))
+}
+
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
 // If it does not return an error, make sure to call shutdown for proper cleanup.
 func NewLocalAIMetricsService() (*LocalAIMetricsService, error) {
@@ -41,6 +168,7 @@ func NewLocalAIMetricsService() (*LocalAIMetricsService, error) {
 	return &LocalAIMetricsService{
 		Meter:         meter,
 		ApiTimeMetric: apiTimeMetric,
+		users:         make(map[string]struct{}),
 	}, nil
 }
 
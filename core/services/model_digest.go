@@ -0,0 +1,164 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// syncChecksumSize is the size of the largest model files hashed while listing the models,
+// the larger ones are hashed in background
+const syncChecksumSize = 64 * 1024 * 1024
+
+// ModelDigest describes the content of a model, to tell whether two nodes serve the same one
+type ModelDigest struct {
+	// Checksum is the sha256 of the model file, empty while it's computed or when the model is not a file
+	Checksum string
+	// ConfigHash is the sha256 of the configuration of the model
+	ConfigHash string
+	// Digest changes with the configuration and the weights of the model
+	Digest string
+}
+
+type fileChecksum struct {
+	size    int64
+	modTime time.Time
+	sum     string // empty while it's computed
+}
+
+var (
+	checksumsMutex sync.Mutex
+	checksums      = map[string]fileChecksum{}
+)
+
+// DigestModel returns the digest of a model, either configured or a loose file of the models path
+func DigestModel(bcl *config.BackendConfigLoader, ml *model.ModelLoader, name string) ModelDigest {
+	d := ModelDigest{}
+	file := name
+	if cfg, exists := bcl.GetBackendConfig(name); exists {
+		if dat, err := yaml.Marshal(cfg); err == nil {
+			sum := sha256.Sum256(dat)
+			d.ConfigHash = hex.EncodeToString(sum[:])
+		}
+		file = cfg.ModelFileName()
+	}
+	if file != "" {
+		d.Checksum = fileSHA256(filepath.Join(ml.ModelPath, file))
+	}
+
+	h := sha256.New()
+	h.Write([]byte(d.ConfigHash + ":" + d.Checksum))
+	d.Digest = hex.EncodeToString(h.Sum(nil))
+	return d
+}
+
+// SystemFingerprint returns the system_fingerprint of the responses of a model, a digest of the version of LocalAI
+// and of the backend, of the model file and of the sampling settings. The requests with the same seed get the same
+// generations as long as the fingerprint stays the same: a different fingerprint tells that the determinism is broken
+func SystemFingerprint(cfg config.BackendConfig, ml *model.ModelLoader) string {
+	settings, _ := json.Marshal(struct {
+		Temperature      *float64
+		TopP             *float64
+		TopK             *int
+		TypicalP         *float64
+		TFZ              *float64
+		Mirostat         *int
+		MirostatETA      *float64
+		MirostatTAU      *float64
+		RepeatPenalty    float64
+		RepeatLastN      int
+		FrequencyPenalty float64
+		PresencePenalty  float64
+		ContextSize      *int
+		Grammar          string
+	}{
+		cfg.Temperature, cfg.TopP, cfg.TopK, cfg.TypicalP, cfg.TFZ, cfg.LLMConfig.Mirostat, cfg.LLMConfig.MirostatETA,
+		cfg.LLMConfig.MirostatTAU, cfg.RepeatPenalty, cfg.RepeatLastN, cfg.FrequencyPenalty, cfg.PresencePenalty,
+		cfg.ContextSize, cfg.Grammar,
+	})
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s", internal.PrintableVersion(), cfg.Backend, modelFileChecksum(cfg, ml), settings)
+	return fmt.Sprintf("fp_%x", h.Sum(nil)[:5])
+}
+
+// modelFileChecksum identifies the content of the model file of a config: the sha256 of its download when the
+// config has it, else the one of the file, or its size and its modification time while the sha256 is computed
+func modelFileChecksum(cfg config.BackendConfig, ml *model.ModelLoader) string {
+	file := cfg.ModelFileName()
+	for _, f := range cfg.DownloadFiles {
+		if f.Filename == file && f.SHA256 != "" {
+			return f.SHA256
+		}
+	}
+	path := filepath.Join(ml.ModelPath, file)
+	if sum := fileSHA256(path); sum != "" {
+		return sum
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return file
+	}
+	return fmt.Sprintf("%s %d %d", file, fi.Size(), fi.ModTime().UnixNano())
+}
+
+// fileSHA256 returns the sha256 of the file at path, cached until the file changes
+func fileSHA256(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return ""
+	}
+
+	checksumsMutex.Lock()
+	cached, exists := checksums[path]
+	if exists && cached.size == fi.Size() && cached.modTime.Equal(fi.ModTime()) {
+		checksumsMutex.Unlock()
+		return cached.sum
+	}
+	checksums[path] = fileChecksum{size: fi.Size(), modTime: fi.ModTime()}
+	checksumsMutex.Unlock()
+
+	if fi.Size() <= syncChecksumSize {
+		return hashFile(path, fi)
+	}
+	go hashFile(path, fi)
+	return ""
+}
+
+func hashFile(path string, fi os.FileInfo) string {
+	sum := ""
+	f, err := os.Open(path)
+	if err == nil {
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err == nil {
+			sum = hex.EncodeToString(h.Sum(nil))
+		}
+		f.Close()
+	}
+
+	checksumsMutex.Lock()
+	defer checksumsMutex.Unlock()
+	if err != nil {
+		log.Warn().Err(err).Str("file", path).Msg("unable to compute the checksum of the model")
+		// it's tried again at the next listing
+		delete(checksums, path)
+		return ""
+	}
+	if cached := checksums[path]; cached.size == fi.Size() && cached.modTime.Equal(fi.ModTime()) {
+		cached.sum = sum
+		checksums[path] = cached
+	}
+	return sum
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemFingerprint(t *testing.T) {
+	ml := &model.ModelLoader{ModelPath: t.TempDir()}
+	assert.NoError(t, os.WriteFile(filepath.Join(ml.ModelPath, "model.gguf"), []byte("weights"), 0600))
+
+	temperature, seed, otherSeed := 0.7, 1, 2
+	fingerprint := func(change func(cfg *config.BackendConfig)) string {
+		cfg := config.BackendConfig{Backend: "llama-cpp"}
+		cfg.Model = "model.gguf"
+		cfg.Temperature = &temperature
+		cfg.Seed = &seed
+		change(&cfg)
+		return SystemFingerprint(cfg, ml)
+	}
+	base := fingerprint(func(cfg *config.BackendConfig) {})
+
+	assert.Regexp(t, `^fp_[0-9a-f]{10}$`, base)
+	assert.Equal(t, base, fingerprint(func(cfg *config.BackendConfig) {}))
+	// the seed gives other generations, but doesn't change the determinism of the system
+	assert.Equal(t, base, fingerprint(func(cfg *config.BackendConfig) { cfg.Seed = &otherSeed }))
+
+	otherTemperature := 0.2
+	assert.NotEqual(t, base, fingerprint(func(cfg *config.BackendConfig) { cfg.Temperature = &otherTemperature }))
+	assert.NotEqual(t, base, fingerprint(func(cfg *config.BackendConfig) { cfg.Backend = "vllm" }))
+
+	// a new version of the model file
+	assert.NoError(t, os.WriteFile(filepath.Join(ml.ModelPath, "model.gguf"), []byte("new weights"), 0600))
+	assert.NotEqual(t, base, fingerprint(func(cfg *config.BackendConfig) {}))
+
+	// the sha256 of the download identifies the model file
+	withSHA := func(sha string) func(cfg *config.BackendConfig) {
+		return func(cfg *config.BackendConfig) {
+			cfg.DownloadFiles = []config.File{{Filename: "model.gguf", SHA256: sha}}
+		}
+	}
+	assert.Equal(t, fingerprint(withSHA("abc")), fingerprint(withSHA("abc")))
+	assert.NotEqual(t, fingerprint(withSHA("abc")), fingerprint(withSHA("def")))
+}
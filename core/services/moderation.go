@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+)
+
+// ModerationCategories are the categories of the moderations of OpenAI, returned for every text
+var ModerationCategories = []string{
+	"harassment", "harassment/threatening", "hate", "hate/threatening", "illicit", "illicit/violent",
+	"self-harm", "self-harm/instructions", "self-harm/intent", "sexual", "sexual/minors", "violence", "violence/graphic",
+}
+
+// ModerationService classifies the texts as potentially harmful with the models answering the moderations: the
+// classifier models of the transformers backend, or the LLMs asked to judge the texts
+type ModerationService struct {
+	appConfig *config.ApplicationConfig
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+}
+
+func NewModerationService(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) *ModerationService {
+	return &ModerationService{
+		appConfig: appConfig,
+		cl:        cl,
+		ml:        ml,
+	}
+}
+
+// Route returns the model answering the moderations: the requested model when given, whatever its moderation
+// settings, or else the first classifier model, and the first judge otherwise
+func (ms *ModerationService) Route(modelName string) (*config.BackendConfig, error) {
+	if modelName != "" {
+		cfg, exists := ms.cl.GetBackendConfig(modelName)
+		if !exists {
+			return nil, fmt.Errorf("model %q not found", modelName)
+		}
+		return &cfg, nil
+	}
+	return routeModeration(ms.cl.GetAllBackendConfigs())
+}
+
+func routeModeration(configs []config.BackendConfig) (*config.BackendConfig, error) {
+	candidates := []config.BackendConfig{}
+	for _, cfg := range configs {
+		if cfg.Moderation.Enabled && !cfg.Maintenance.Enabled {
+			candidates = append(candidates, cfg)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no model answers the moderations, enable the moderation of a model")
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].IsClassifierModel() && !candidates[j].IsClassifierModel()
+	})
+	return &candidates[0], nil
+}
+
+// Moderate returns the categories of the texts, and their scores
+func (ms *ModerationService) Moderate(ctx context.Context, cfg *config.BackendConfig, texts []string) ([]schema.ModerationResult, error) {
+	results := []schema.ModerationResult{}
+	if cfg.IsClassifierModel() {
+		classifications, err := backend.ModelClassify(ctx, texts, ms.ml, ms.appConfig, *cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, classification := range classifications {
+			scores := map[string]float64{}
+			for _, l := range classification.Labels {
+				scores[l.Label] = float64(l.Score)
+			}
+			results = append(results, moderationResult(cfg.Moderation, scores))
+		}
+		return results, nil
+	}
+
+	for _, text := range texts {
+		scores, err := ms.judge(ctx, cfg, text)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, moderationResult(cfg.Moderation, scores))
+	}
+	return results, nil
+}
+
+// judge asks the model for the scores of the categories of the text, answered as a JSON object
+func (ms *ModerationService) judge(ctx context.Context, cfg *config.BackendConfig, text string) (map[string]float64, error) {
+	prompt := moderationPrompt(text)
+	if cfg.Moderation.Template != "" {
+		var err error
+		prompt, err = ms.ml.EvaluateTemplateForPrompt(model.ModerationPromptTemplate, cfg.Moderation.Template, model.PromptTemplateData{
+			SystemPrompt: cfg.SystemPrompt,
+			Input:        text,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render the moderation template: %w", err)
+		}
+	}
+
+	judgeConfig := *cfg
+	judgeConfig.Grammar = functions.JSONBNF
+	answer, err := predict(ctx, ms.ml, ms.appConfig, &judgeConfig, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to judge the text: %w", err)
+	}
+	return parseJudgeScores(answer)
+}
+
+// parseJudgeScores reads the scores of the JSON object answered by the judge. The booleans are scored 0 or 1, and
+// the scores are clamped between 0 and 1
+func parseJudgeScores(answer string) (map[string]float64, error) {
+	values := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(templates.ExtractJSON(answer)), &values); err != nil {
+		return nil, fmt.Errorf("the judge didn't answer with the scores of the categories: %q", answer)
+	}
+	scores := map[string]float64{}
+	for key, value := range values {
+		switch v := value.(type) {
+		case float64:
+			scores[key] = min(max(v, 0), 1)
+		case bool:
+			if v {
+				scores[key] = 1
+			} else {
+				scores[key] = 0
+			}
+		}
+	}
+	return scores, nil
+}
+
+// moderationResult returns the result of the scores of the labels of the model, mapped to their categories. A
+// category of several labels has the highest of their scores, and the labels mapped to no category are ignored
+func moderationResult(moderation config.Moderation, scores map[string]float64) schema.ModerationResult {
+	result := schema.ModerationResult{Categories: map[string]bool{}, CategoryScores: map[string]float64{}}
+	for _, category := range ModerationCategories {
+		result.CategoryScores[category] = 0
+	}
+	for label, score := range scores {
+		category := moderation.Category(label)
+		if category == "" {
+			continue
+		}
+		if current, exists := result.CategoryScores[category]; !exists || score > current {
+			result.CategoryScores[category] = score
+		}
+	}
+	for category, score := range result.CategoryScores {
+		result.Categories[category] = moderation.Flags(score)
+		result.Flagged = result.Flagged || result.Categories[category]
+	}
+	return result
+}
+
+// moderationPrompt asks a model to judge the text in the categories of OpenAI
+func moderationPrompt(text string) string {
+	var prompt strings.Builder
+	prompt.WriteString("Rate how much the following text falls into each of these content categories, with a score between 0 (not at all) and 1 (certainly):\n")
+	for _, category := range ModerationCategories {
+		fmt.Fprintf(&prompt, "- %s\n", category)
+	}
+	prompt.WriteString("Answer with a JSON object mapping each category to its score only.\n")
+	fmt.Fprintf(&prompt, "\nText:\n%s", text)
+	return prompt.String()
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModeration(t *testing.T) {
+	t.Run("routes to the classifier models first", func(t *testing.T) {
+		configs := []config.BackendConfig{
+			{Name: "llama", Moderation: config.Moderation{Enabled: true}},
+			{Name: "toxic-bert", LLMConfig: config.LLMConfig{ModelType: config.SequenceClassificationModelType}, Moderation: config.Moderation{Enabled: true}},
+			{Name: "phi-2"},
+		}
+		cfg, err := routeModeration(configs)
+		require.NoError(t, err)
+		assert.Equal(t, "toxic-bert", cfg.Name)
+
+		cfg, err = routeModeration([]config.BackendConfig{configs[0], configs[2]})
+		require.NoError(t, err)
+		assert.Equal(t, "llama", cfg.Name)
+
+		_, err = routeModeration(configs[2:])
+		assert.Error(t, err)
+	})
+
+	t.Run("skips the models in maintenance", func(t *testing.T) {
+		_, err := routeModeration([]config.BackendConfig{
+			{Name: "llama", Moderation: config.Moderation{Enabled: true}, Maintenance: config.Maintenance{Enabled: true}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("maps the labels to the categories", func(t *testing.T) {
+		moderation := config.Moderation{
+			Threshold:  0.7,
+			Categories: map[string]string{"toxic": "harassment", "insult": "harassment", "threat": "harassment/threatening", "obscene": ""},
+		}
+		result := moderationResult(moderation, map[string]float64{"toxic": 0.4, "insult": 0.75, "threat": 0.2, "obscene": 0.9, "spam": 0.8})
+		assert.True(t, result.Flagged)
+		assert.Equal(t, 0.75, result.CategoryScores["harassment"])
+		assert.True(t, result.Categories["harassment"])
+		assert.Equal(t, 0.2, result.CategoryScores["harassment/threatening"])
+		assert.False(t, result.Categories["harassment/threatening"])
+		assert.True(t, result.Categories["spam"], "the labels not mapped are categories")
+		assert.NotContains(t, result.CategoryScores, "obscene")
+		assert.NotContains(t, result.CategoryScores, "")
+		assert.Len(t, result.CategoryScores, len(ModerationCategories)+1)
+
+		result = moderationResult(config.Moderation{}, map[string]float64{"violence": 0.3})
+		assert.False(t, result.Flagged)
+		assert.False(t, result.Categories["violence"])
+	})
+
+	t.Run("reads the scores of the judge", func(t *testing.T) {
+		scores, err := parseJudgeScores("Here are the scores: {\"hate\": 0.2, \"violence\": 1.4, \"sexual\": true, \"note\": \"none\"}")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{"hate": 0.2, "violence": 1, "sexual": 1}, scores)
+
+		_, err = parseJudgeScores("the text is safe")
+		assert.Error(t, err)
+	})
+
+	t.Run("asks the judge for the categories of OpenAI", func(t *testing.T) {
+		prompt := moderationPrompt("Hello")
+		assert.Contains(t, prompt, "- self-harm/intent\n")
+		assert.Contains(t, prompt, "Answer with a JSON object")
+		assert.True(t, len(prompt) > 0 && prompt[len(prompt)-len("Text:\nHello"):] == "Text:\nHello")
+	})
+}
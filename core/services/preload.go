@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+func (r galleryModel) preloadName() string {
+	switch {
+	case r.Name != "":
+		return r.Name
+	case r.ID != "":
+		return r.ID
+	}
+	return r.URL
+}
+
+// provides returns the files (and the model name) that are installed by the request
+func (r galleryModel) provides() []string {
+	p := []string{}
+	if r.Name != "" {
+		p = append(p, r.Name)
+	}
+	for _, f := range r.AdditionalFiles {
+		p = append(p, filepath.Base(f.Filename))
+	}
+	return p
+}
+
+// references returns all the strings found in the overrides and in the config,
+// which are candidates to point to files installed by other requests
+func (r galleryModel) references() []string {
+	refs := []string{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			refs = append(refs, filepath.Base(t))
+		case map[string]interface{}:
+			for _, vv := range t {
+				walk(vv)
+			}
+		case map[interface{}]interface{}:
+			for _, vv := range t {
+				walk(vv)
+			}
+		case []interface{}:
+			for _, vv := range t {
+				walk(vv)
+			}
+		}
+	}
+	walk(r.Overrides)
+	walk(r.ConfigFile)
+	return refs
+}
+
+// preloadWaves orders the requests in waves: requests in a wave only depend on
+// requests from the previous waves, and can be applied concurrently.
+// It returns, for each request, the indexes of the requests it depends on.
+func preloadWaves(requests []galleryModel) ([][]int, map[int][]int) {
+	providers := map[string]int{}
+	for i, r := range requests {
+		for _, f := range r.provides() {
+			providers[f] = i
+		}
+	}
+
+	deps := map[int][]int{}
+	for i, r := range requests {
+		seen := map[int]bool{}
+		for _, ref := range r.references() {
+			if j, ok := providers[ref]; ok && j != i && !seen[j] {
+				seen[j] = true
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	waves := [][]int{}
+	done := map[int]bool{}
+	for len(done) < len(requests) {
+		wave := []int{}
+		for i := range requests {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, j := range deps[i] {
+				if !done[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, i)
+			}
+		}
+
+		if len(wave) == 0 {
+			// circular references: apply the remaining ones together
+			for i := range requests {
+				if !done[i] {
+					wave = append(wave, i)
+				}
+			}
+			log.Warn().Msg("[startup] circular dependencies found between preload models")
+		}
+
+		for _, i := range wave {
+			done[i] = true
+		}
+		waves = append(waves, wave)
+	}
+
+	return waves, deps
+}
+
+func processRequests(modelPath string, enforceScan bool, galleries []config.Gallery, requests []galleryModel, parallelism int) map[string]error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	failures := map[string]error{}
+	failed := make([]bool, len(requests))
+	var mu sync.Mutex
+
+	waves, deps := preloadWaves(requests)
+	for _, wave := range waves {
+		sem := make(chan struct{}, parallelism)
+		wg := sync.WaitGroup{}
+
+		for _, i := range wave {
+			r := requests[i]
+
+			mu.Lock()
+			var depErr error
+			for _, j := range deps[i] {
+				if failed[j] {
+					depErr = fmt.Errorf("dependency %q failed", requests[j].preloadName())
+				}
+			}
+			if depErr != nil {
+				failed[i] = true
+				failures[r.preloadName()] = depErr
+				mu.Unlock()
+				continue
+			}
+			mu.Unlock()
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, r galleryModel) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				log.Info().Str("model", r.preloadName()).Msg("[startup] applying preload model")
+
+				// the models are installed concurrently, each with the timers of its own progress
+				progress := utils.NewDownloadProgress()
+				var err error
+				if r.ID == "" {
+					err = prepareModel(modelPath, r.GalleryModel, progress, enforceScan)
+				} else {
+					err = gallery.InstallModelFromGallery(
+						galleries, r.ID, modelPath, r.GalleryModel, progress, enforceScan)
+				}
+
+				if err != nil {
+					log.Error().Err(err).Str("model", r.preloadName()).Msg("[startup] failed applying preload model")
+					mu.Lock()
+					failed[i] = true
+					failures[r.preloadName()] = err
+					mu.Unlock()
+				}
+			}(i, r)
+		}
+
+		wg.Wait()
+	}
+
+	return failures
+}
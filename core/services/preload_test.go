@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/stretchr/testify/assert"
+)
+
+func preloadRequest(name string, files []string, overrides map[string]interface{}) galleryModel {
+	r := galleryModel{GalleryModel: gallery.GalleryModel{Name: name, Overrides: overrides}}
+	for _, f := range files {
+		r.AdditionalFiles = append(r.AdditionalFiles, gallery.File{Filename: f})
+	}
+	return r
+}
+
+func TestPreloadWaves(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		requests []galleryModel
+		waves    [][]int
+		deps     map[int][]int
+	}{
+		{
+			name: "independent models are applied together",
+			requests: []galleryModel{
+				preloadRequest("a", nil, nil),
+				preloadRequest("b", nil, nil),
+			},
+			waves: [][]int{{0, 1}},
+			deps:  map[int][]int{},
+		},
+		{
+			name: "models wait for the files they reference",
+			requests: []galleryModel{
+				preloadRequest("chat", nil, map[string]interface{}{"mmproj": "models/projector.gguf"}),
+				preloadRequest("projector", []string{"projector.gguf"}, nil),
+				preloadRequest("draft", nil, map[string]interface{}{"draft_model": "chat"}),
+			},
+			waves: [][]int{{1}, {0}, {2}},
+			deps:  map[int][]int{0: {1}, 2: {0}},
+		},
+		{
+			name: "references to files of no request are not dependencies",
+			requests: []galleryModel{
+				preloadRequest("chat", nil, map[string]interface{}{"parameters": map[string]interface{}{"model": "missing.gguf"}}),
+			},
+			waves: [][]int{{0}},
+			deps:  map[int][]int{},
+		},
+		{
+			name: "models referencing themselves are not waiting",
+			requests: []galleryModel{
+				preloadRequest("chat", []string{"chat.gguf"}, map[string]interface{}{"parameters": map[string]interface{}{"model": "chat.gguf"}}),
+			},
+			waves: [][]int{{0}},
+			deps:  map[int][]int{},
+		},
+		{
+			name: "circular references are applied together after the others",
+			requests: []galleryModel{
+				preloadRequest("a", nil, map[string]interface{}{"ref": "b"}),
+				preloadRequest("b", nil, map[string]interface{}{"ref": "a"}),
+				preloadRequest("c", nil, nil),
+			},
+			waves: [][]int{{2}, {0, 1}},
+			deps:  map[int][]int{0: {1}, 1: {0}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			waves, deps := preloadWaves(tc.requests)
+			assert.Equal(t, tc.waves, waves)
+			assert.Equal(t, tc.deps, deps)
+		})
+	}
+}
+
+func TestProcessRequestsSkipsTheDependentsOfFailures(t *testing.T) {
+	requests := []galleryModel{
+		{GalleryModel: gallery.GalleryModel{Name: "base", URL: "file:///does-not-exist/base.yaml"}},
+		preloadRequest("derived", nil, map[string]interface{}{"ref": "base"}),
+	}
+
+	failures := processRequests(t.TempDir(), false, nil, requests, 2)
+	assert.Len(t, failures, 2)
+	assert.Error(t, failures["base"])
+	assert.EqualError(t, failures["derived"], `dependency "base" failed`)
+}
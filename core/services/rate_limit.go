@@ -0,0 +1,60 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimiter limits the number of requests per minute of the clients, over fixed windows of a minute
+type RateLimiter struct {
+	sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{windows: map[string]*rateWindow{}}
+}
+
+// Allow counts a request of the client, once its limit is reached it returns false and how long to wait
+func (rl *RateLimiter) Allow(client string, limit int) (bool, time.Duration) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+	rl.sweep(now)
+	w, exists := rl.windows[client]
+	if !exists || now.Sub(w.start) >= time.Minute {
+		w = &rateWindow{start: now}
+		rl.windows[client] = w
+	}
+	if w.count >= limit {
+		return false, w.start.Add(time.Minute).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// sweep forgets the windows which are over
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < time.Minute {
+		return
+	}
+	rl.lastSweep = now
+	for client, w := range rl.windows {
+		if now.Sub(w.start) >= time.Minute {
+			delete(rl.windows, client)
+		}
+	}
+}
+
+// AllowUser counts a request of an end user of the client, identified by the user field of the requests. The users
+// of the different clients are limited separately, and apart from the limit of their client
+func (rl *RateLimiter) AllowUser(client, user string, limit int) (bool, time.Duration) {
+	return rl.Allow("user\x00"+client+"\x00"+user, limit)
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterUsers(t *testing.T) {
+	rl := NewRateLimiter()
+
+	allowed, _ := rl.AllowUser("key", "alice", 1)
+	assert.True(t, allowed)
+	allowed, wait := rl.AllowUser("key", "alice", 1)
+	assert.False(t, allowed)
+	assert.Positive(t, wait)
+
+	// the other users, the same user of another key and the key itself have their own windows
+	allowed, _ = rl.AllowUser("key", "bob", 1)
+	assert.True(t, allowed)
+	allowed, _ = rl.AllowUser("other-key", "alice", 1)
+	assert.True(t, allowed)
+	allowed, _ = rl.Allow("key", 1)
+	assert.True(t, allowed)
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// unusedModelAge is how long a model has to be unused to be suggested for uninstall
+	unusedModelAge = 30 * 24 * time.Hour
+	// recommendationsGalleryTTL is how long the gallery models are cached to look for the variants of the models
+	recommendationsGalleryTTL = time.Hour
+	// vramOverhead is the memory a model takes on the GPUs relative to the size of its file, with its context
+	vramOverhead = 1.2
+)
+
+// quantizationBits are the bits per weight of the quantizations of llama.cpp, estimating the size of the variants
+// of a model from the size of the installed one
+var quantizationBits = map[string]float64{
+	"q2_k":   2.6,
+	"q3_k_s": 3.5,
+	"q3_k_m": 3.9,
+	"q3_k_l": 4.3,
+	"iq4_xs": 4.25,
+	"q4_0":   4.5,
+	"q4_k_s": 4.6,
+	"q4_k_m": 4.85,
+	"q5_0":   5.5,
+	"q5_k_s": 5.5,
+	"q5_k_m": 5.7,
+	"q6_k":   6.6,
+	"q8_0":   8.5,
+	"f16":    16,
+	"bf16":   16,
+	"f32":    32,
+}
+
+var (
+	// quantizationPattern matches the quantization in the name of a model or of its files, e.g. Q4_K_M in
+	// llama-3.2-1b-instruct-Q4_K_M.gguf
+	quantizationPattern = regexp.MustCompile(`(?i)(^|[^a-z0-9])(i?q[1-8](_[a-z0-9]+)*|bf16|f16|f32)([^a-z0-9]|$)`)
+	// quantizationDigit is the number of bits of the quantizations missing from quantizationBits
+	quantizationDigit = regexp.MustCompile(`^i?q([1-8])`)
+)
+
+// RecommendationService suggests actions on the installed models from their usage and the hardware: uninstalling
+// the models unused for a while, and installing the quantized variants of the models which fit the GPUs better.
+// The suggestions are taken through the gallery API
+type RecommendationService struct {
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+	appConfig *config.ApplicationConfig
+	usage     *UsageService
+
+	gpus          func() ([]xsysinfo.GPUMemory, error)
+	galleryModels func() ([]*gallery.GalleryModel, error)
+	now           func() time.Time
+
+	sync.Mutex
+	cachedModels []*gallery.GalleryModel
+	cachedAt     time.Time
+}
+
+func NewRecommendationService(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, usage *UsageService) *RecommendationService {
+	return &RecommendationService{
+		cl:        cl,
+		ml:        ml,
+		appConfig: appConfig,
+		usage:     usage,
+		gpus:      xsysinfo.GPUsMemory,
+		galleryModels: func() ([]*gallery.GalleryModel, error) {
+			return gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+		},
+		now: time.Now,
+	}
+}
+
+// Recommendations returns the actions suggested on the installed models, sorted by model
+func (rs *RecommendationService) Recommendations() []schema.Recommendation {
+	now := rs.now()
+	used, known := rs.usedModels(now)
+
+	var vram uint64
+	gpus, err := rs.gpus()
+	if err != nil {
+		log.Debug().Err(err).Msg("failed reading the memory of the GPUs for the recommendations")
+	}
+	for _, g := range gpus {
+		vram += g.Total
+	}
+
+	configs := rs.cl.GetAllBackendConfigs()
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	recommendations := []schema.Recommendation{}
+	for _, cfg := range configs {
+		size, installed := rs.modelFiles(cfg)
+		if size == 0 {
+			continue
+		}
+		if known && !used[cfg.Name] && installed.Before(now.Add(-unusedModelAge)) {
+			recommendations = append(recommendations, schema.Recommendation{
+				Type:    schema.RecommendationUninstall,
+				Model:   cfg.Name,
+				Message: fmt.Sprintf("%s is unused for %d days: uninstall it to free %s", cfg.Name, int(unusedModelAge.Hours()/24), formatGiB(size)),
+				Size:    size,
+				Action:  schema.RecommendationAction{Method: "POST", Path: "/models/delete/" + url.PathEscape(cfg.Name)},
+			})
+			continue
+		}
+		if vram > 0 {
+			if r, ok := rs.variant(cfg, vram); ok {
+				recommendations = append(recommendations, r)
+			}
+		}
+	}
+	return recommendations
+}
+
+// usedModels returns the models used within unusedModelAge, and whether the usage is known for all of that time
+func (rs *RecommendationService) usedModels(now time.Time) (map[string]bool, bool) {
+	used := map[string]bool{}
+	since := now.Add(-unusedModelAge)
+	if rs.appConfig.DisableUsageAccounting || rs.usage == nil {
+		return used, false
+	}
+	if oldest := rs.usage.Since(); oldest.IsZero() || oldest.After(since) {
+		return used, false
+	}
+	usage, err := rs.usage.Usage(UsageFilter{Since: since})
+	if err != nil {
+		return used, false
+	}
+	for _, u := range usage {
+		used[u.Model] = true
+	}
+	return used, true
+}
+
+// modelFiles returns the size of the files of a model and when its weights were installed, a zero size when they
+// aren't files of the models path
+func (rs *RecommendationService) modelFiles(cfg config.BackendConfig) (uint64, time.Time) {
+	var size uint64
+	var installed time.Time
+	for i, name := range []string{cfg.ModelFileName(), cfg.MMProjFileName()} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(rs.ml.ModelPath, name))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		size += uint64(info.Size())
+		if i == 0 {
+			installed = info.ModTime()
+		}
+	}
+	return size, installed
+}
+
+// variant suggests the gallery variant of a llama.cpp model with the most bits per weight which fits the GPUs: a
+// larger one when the model fits, or a smaller one when it doesn't
+func (rs *RecommendationService) variant(cfg config.BackendConfig, vram uint64) (schema.Recommendation, bool) {
+	file := cfg.ModelFileName()
+	if !strings.HasSuffix(strings.ToLower(file), ".gguf") {
+		return schema.Recommendation{}, false
+	}
+	current := modelQuantization(cfg.Name, file)
+	currentBits := quantizationBitsPerWeight(current)
+	info, err := os.Stat(filepath.Join(rs.ml.ModelPath, file))
+	if currentBits == 0 || err != nil {
+		return schema.Recommendation{}, false
+	}
+	fits := func(size uint64) bool { return float64(size)*vramOverhead <= float64(vram) }
+	size := uint64(info.Size())
+	estimate := func(bits float64) uint64 { return uint64(math.Round(float64(size) * bits / currentBits)) }
+
+	var best *gallery.GalleryModel
+	var bestQuantization string
+	var bestBits float64
+	family := modelFamily(cfg.Name)
+	for _, m := range rs.cachedGalleryModels() {
+		if m.Installed || m.Name == cfg.Name || modelFamily(m.Name) != family {
+			continue
+		}
+		names := []string{m.Name}
+		for _, f := range m.AdditionalFiles {
+			names = append(names, f.Filename)
+		}
+		q := modelQuantization(names...)
+		bits := quantizationBitsPerWeight(q)
+		if bits == 0 || bits <= bestBits || q == current || !fits(estimate(bits)) {
+			continue
+		}
+		if fits(size) && bits <= currentBits {
+			continue
+		}
+		best, bestQuantization, bestBits = m, q, bits
+	}
+	if best == nil {
+		return schema.Recommendation{}, false
+	}
+
+	variantSize := estimate(bestBits)
+	message := fmt.Sprintf("your GPUs can fit the %s variant of %s, of about %s", strings.ToUpper(bestQuantization), cfg.Name, formatGiB(variantSize))
+	if !fits(size) {
+		message = fmt.Sprintf("%s doesn't fit the %s of your GPUs, its %s variant of about %s does", cfg.Name, formatGiB(vram), strings.ToUpper(bestQuantization), formatGiB(variantSize))
+	}
+	return schema.Recommendation{
+		Type:    schema.RecommendationVariant,
+		Model:   cfg.Name,
+		Message: message,
+		Size:    variantSize,
+		Variant: best.ID(),
+		Action:  schema.RecommendationAction{Method: "POST", Path: "/models/apply", Body: map[string]string{"id": best.ID()}},
+	}, true
+}
+
+// cachedGalleryModels returns the models of the galleries, fetched at most every recommendationsGalleryTTL
+func (rs *RecommendationService) cachedGalleryModels() []*gallery.GalleryModel {
+	rs.Lock()
+	defer rs.Unlock()
+	if rs.cachedModels != nil && rs.now().Sub(rs.cachedAt) < recommendationsGalleryTTL {
+		return rs.cachedModels
+	}
+	models, err := rs.galleryModels()
+	if err != nil {
+		log.Debug().Err(err).Msg("failed listing the gallery models for the recommendations")
+		return nil
+	}
+	rs.cachedModels, rs.cachedAt = models, rs.now()
+	return models
+}
+
+// modelQuantization returns the quantization found first in the names, lowercased
+func modelQuantization(names ...string) string {
+	for _, name := range names {
+		if m := quantizationPattern.FindStringSubmatch(name); m != nil {
+			return strings.ToLower(m[2])
+		}
+	}
+	return ""
+}
+
+func quantizationBitsPerWeight(quantization string) float64 {
+	if bits, ok := quantizationBits[quantization]; ok {
+		return bits
+	}
+	if m := quantizationDigit.FindStringSubmatch(quantization); m != nil {
+		bits, _ := strconv.Atoi(m[1])
+		return float64(bits) + 0.5
+	}
+	return 0
+}
+
+// modelFamily returns the name of a model without its quantization, shared by its variants
+func modelFamily(name string) string {
+	return strings.Trim(strings.ToLower(quantizationPattern.ReplaceAllString(name, "$1$4")), "-_:. ")
+}
+
+func formatGiB(b uint64) string {
+	return fmt.Sprintf("%.1fGiB", float64(b)/(1024*1024*1024))
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendations(t *testing.T) {
+	now := time.Now()
+	modelsDir := t.TempDir()
+	install := func(name, file string, size int, installed time.Time) {
+		path := filepath.Join(modelsDir, file)
+		require.NoError(t, os.WriteFile(path, make([]byte, size), 0600))
+		require.NoError(t, os.Chtimes(path, installed, installed))
+		require.NoError(t, os.WriteFile(filepath.Join(modelsDir, name+".yaml"), []byte("name: "+name+"\nparameters:\n  model: "+file+"\n"), 0600))
+	}
+	install("old", "old.bin", 1000, now.AddDate(0, 0, -60))
+	install("recent", "recent.bin", 1000, now.AddDate(0, 0, -2))
+	install("llama-3.2-1b-instruct", "Llama-3.2-1B-Instruct-Q4_K_M.gguf", 4850, now.AddDate(0, 0, -60))
+	install("big:q8_0", "big-q8_0.gguf", 8500, now.AddDate(0, 0, -60))
+
+	cl := config.NewBackendConfigLoader(modelsDir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(modelsDir))
+	appConfig := &config.ApplicationConfig{ConfigsDir: t.TempDir()}
+	us := NewUsageService(appConfig)
+
+	rs := NewRecommendationService(cl, model.NewModelLoader(modelsDir), appConfig, us)
+	rs.now = func() time.Time { return now }
+	rs.gpus = func() ([]xsysinfo.GPUMemory, error) { return []xsysinfo.GPUMemory{{Total: 7000}}, nil }
+	rs.galleryModels = func() ([]*gallery.GalleryModel, error) {
+		models := []*gallery.GalleryModel{}
+		for _, name := range []string{"llama-3.2-1b-instruct:q5_k_m", "llama-3.2-1b-instruct:q8_0", "big:q4_k_m", "big:q6_k", "other:q5_k_m"} {
+			models = append(models, &gallery.GalleryModel{Name: name, Gallery: config.Gallery{Name: "localai"}})
+		}
+		return models, nil
+	}
+
+	t.Run("suggests the variants fitting the GPUs", func(t *testing.T) {
+		// the usage doesn't go back far enough to tell the unused models
+		us.Record(schema.AuditEntry{Time: now.AddDate(0, 0, -1), Model: "llama-3.2-1b-instruct", Status: 200})
+		recommendations := rs.Recommendations()
+		require.Len(t, recommendations, 2)
+
+		assert.Equal(t, schema.RecommendationVariant, recommendations[0].Type)
+		assert.Equal(t, "big:q8_0", recommendations[0].Model)
+		assert.Equal(t, "localai@big:q4_k_m", recommendations[0].Variant)
+		assert.Equal(t, uint64(4850), recommendations[0].Size)
+		assert.Equal(t, schema.RecommendationAction{Method: "POST", Path: "/models/apply", Body: map[string]string{"id": "localai@big:q4_k_m"}}, recommendations[0].Action)
+
+		assert.Equal(t, "llama-3.2-1b-instruct", recommendations[1].Model)
+		assert.Equal(t, "localai@llama-3.2-1b-instruct:q5_k_m", recommendations[1].Variant)
+		assert.Contains(t, recommendations[1].Message, "Q5_K_M variant")
+	})
+
+	t.Run("suggests to uninstall the models unused for long", func(t *testing.T) {
+		us.Record(schema.AuditEntry{Time: now.AddDate(0, 0, -40), Model: "old", Status: 200})
+		recommendations := rs.Recommendations()
+		require.Len(t, recommendations, 3)
+		assert.Equal(t, schema.Recommendation{
+			Type:    schema.RecommendationUninstall,
+			Model:   "big:q8_0",
+			Message: "big:q8_0 is unused for 30 days: uninstall it to free 0.0GiB",
+			Size:    8500,
+			Action:  schema.RecommendationAction{Method: "POST", Path: "/models/delete/big:q8_0"},
+		}, recommendations[0])
+		assert.Equal(t, "llama-3.2-1b-instruct", recommendations[1].Model)
+		assert.Equal(t, schema.RecommendationVariant, recommendations[1].Type)
+		assert.Equal(t, "old", recommendations[2].Model)
+		assert.Equal(t, schema.RecommendationUninstall, recommendations[2].Type)
+	})
+
+	t.Run("finds the quantization of the models", func(t *testing.T) {
+		assert.Equal(t, "q4_k_m", modelQuantization("llama-3.2-1b-instruct", "Llama-3.2-1B-Instruct-Q4_K_M.gguf"))
+		assert.Equal(t, "iq4_xs", modelQuantization("qwen2.5-7b:iq4_xs"))
+		assert.Equal(t, "", modelQuantization("qwen2.5-7b-instruct"))
+		assert.Equal(t, "llama-3.2-1b-instruct", modelFamily("Llama-3.2-1B-Instruct:Q8_0"))
+		assert.Equal(t, 3.5, quantizationBitsPerWeight("q3_0"))
+		assert.Equal(t, 0.0, quantizationBitsPerWeight(""))
+	})
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ScheduledTasksFile is the file of the dynamic configuration directory defining the scheduled tasks
+	ScheduledTasksFile = "scheduled_tasks.yaml"
+	// ScheduledRunsFile holds the history of the runs of the scheduled tasks
+	ScheduledRunsFile = "scheduled_runs.json"
+
+	// maxScheduledRuns is the number of runs kept in the history of each task
+	maxScheduledRuns  = 20
+	schedulerInterval = 15 * time.Second
+
+	ScheduledTaskChat       = "chat"
+	ScheduledTaskEmbeddings = "embeddings"
+
+	ScheduledRunRunning   = "running"
+	ScheduledRunSucceeded = "succeeded"
+	ScheduledRunFailed    = "failed"
+)
+
+type scheduledTask struct {
+	schema.ScheduledTask
+	schedule cron.Schedule
+	next     time.Time
+	running  bool
+}
+
+// SchedulerService runs the recurring inference tasks defined in the configuration directory,
+// and keeps the history of their runs
+type SchedulerService struct {
+	appConfig *config.ApplicationConfig
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+
+	sync.Mutex
+	tasks   map[string]*scheduledTask
+	modTime time.Time
+	runs    map[string][]schema.ScheduledTaskRun // by task, the most recent first
+}
+
+func NewSchedulerService(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) *SchedulerService {
+	ss := &SchedulerService{
+		appConfig: appConfig,
+		cl:        cl,
+		ml:        ml,
+		tasks:     map[string]*scheduledTask{},
+		runs:      map[string][]schema.ScheduledTaskRun{},
+	}
+	utils.LoadConfig(appConfig.ConfigsDir, ScheduledRunsFile, &ss.runs)
+	return ss
+}
+
+// Start runs the tasks when they are due, until the context is done. The tasks file is reloaded when it changes.
+func (ss *SchedulerService) Start(ctx context.Context) {
+	ss.reload()
+
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				ss.reload()
+				for _, name := range ss.due(now) {
+					go ss.run(ctx, name)
+				}
+			}
+		}
+	}()
+}
+
+// Tasks returns the scheduled tasks, with their next and last runs
+func (ss *SchedulerService) Tasks() []schema.ScheduledTaskStatus {
+	ss.Lock()
+	defer ss.Unlock()
+
+	tasks := []schema.ScheduledTaskStatus{}
+	for _, t := range ss.tasks {
+		status := schema.ScheduledTaskStatus{ScheduledTask: t.ScheduledTask, NextRun: t.next}
+		if runs := ss.runs[t.Name]; len(runs) > 0 {
+			last := runs[0]
+			status.LastRun = &last
+		}
+		tasks = append(tasks, status)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Name < tasks[j].Name })
+	return tasks
+}
+
+// Runs returns the history of the runs of a task, the most recent first
+func (ss *SchedulerService) Runs(name string) ([]schema.ScheduledTaskRun, error) {
+	ss.Lock()
+	defer ss.Unlock()
+	if _, exists := ss.tasks[name]; !exists {
+		return nil, fmt.Errorf("unable to find scheduled task %q", name)
+	}
+	return append([]schema.ScheduledTaskRun{}, ss.runs[name]...), nil
+}
+
+// Trigger runs a task now, in background
+func (ss *SchedulerService) Trigger(ctx context.Context, name string) error {
+	ss.Lock()
+	t, exists := ss.tasks[name]
+	if !exists {
+		ss.Unlock()
+		return fmt.Errorf("unable to find scheduled task %q", name)
+	}
+	if t.running {
+		ss.Unlock()
+		return fmt.Errorf("scheduled task %q is already running", name)
+	}
+	ss.Unlock()
+
+	go ss.run(ctx, name)
+	return nil
+}
+
+// reload reads the tasks file when it changed, keeping the next runs of the unchanged tasks
+func (ss *SchedulerService) reload() {
+	path := filepath.Join(ss.appConfig.DynamicConfigsDir, ScheduledTasksFile)
+	var modTime time.Time
+	if fi, err := os.Stat(path); err == nil {
+		modTime = fi.ModTime()
+	}
+
+	ss.Lock()
+	defer ss.Unlock()
+	if modTime.Equal(ss.modTime) {
+		return
+	}
+	ss.modTime = modTime
+
+	var defined []schema.ScheduledTask
+	if !modTime.IsZero() {
+		dat, err := os.ReadFile(path)
+		if err == nil {
+			err = yaml.Unmarshal(dat, &defined)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("failed to read the scheduled tasks")
+			return
+		}
+	}
+
+	now := time.Now()
+	tasks := map[string]*scheduledTask{}
+	for _, d := range defined {
+		if err := validateScheduledTask(d); err != nil {
+			log.Error().Err(err).Str("task", d.Name).Msg("skipping invalid scheduled task")
+			continue
+		}
+		schedule, _ := cron.ParseStandard(d.Schedule)
+		t := &scheduledTask{ScheduledTask: d, schedule: schedule, next: schedule.Next(now)}
+		if old, exists := ss.tasks[d.Name]; exists {
+			t.running = old.running
+			if old.Schedule == d.Schedule {
+				t.next = old.next
+			}
+		}
+		tasks[d.Name] = t
+	}
+	ss.tasks = tasks
+	log.Debug().Int("tasks", len(tasks)).Msg("loaded the scheduled tasks")
+}
+
+func validateScheduledTask(t schema.ScheduledTask) error {
+	if t.Name == "" || t.Name != utils.SanitizeFileName(t.Name) {
+		return fmt.Errorf("invalid name %q", t.Name)
+	}
+	if t.Model == "" {
+		return fmt.Errorf("the model is required")
+	}
+	switch t.Type {
+	case "", ScheduledTaskChat:
+		if t.Prompt == "" && t.Input == "" {
+			return fmt.Errorf("a prompt or an input is required")
+		}
+	case ScheduledTaskEmbeddings:
+		if t.Input == "" {
+			return fmt.Errorf("an input is required")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", t.Type)
+	}
+	if _, err := cron.ParseStandard(t.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", t.Schedule, err)
+	}
+	return nil
+}
+
+// due returns the tasks to run at now, and schedules their next run
+func (ss *SchedulerService) due(now time.Time) []string {
+	ss.Lock()
+	defer ss.Unlock()
+
+	names := []string{}
+	for _, t := range ss.tasks {
+		if t.next.IsZero() || now.Before(t.next) {
+			continue
+		}
+		t.next = t.schedule.Next(now)
+		if t.running {
+			log.Warn().Str("task", t.Name).Msg("skipping the scheduled task, the previous run is still running")
+			continue
+		}
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func (ss *SchedulerService) run(ctx context.Context, name string) {
+	ss.Lock()
+	t, exists := ss.tasks[name]
+	if !exists || t.running {
+		ss.Unlock()
+		return
+	}
+	t.running = true
+	task := t.ScheduledTask
+	ss.Unlock()
+
+	defer func() {
+		ss.Lock()
+		t.running = false
+		// the tasks may have been reloaded meanwhile
+		if current, exists := ss.tasks[name]; exists {
+			current.running = false
+		}
+		ss.Unlock()
+	}()
+
+	run := schema.ScheduledTaskRun{
+		ID:      uuid.New().String(),
+		Task:    task.Name,
+		Status:  ScheduledRunRunning,
+		Started: time.Now(),
+	}
+	ss.record(run)
+	log.Info().Str("task", task.Name).Str("run", run.ID).Msg("running the scheduled task")
+
+	results, err := ss.execute(ctx, task)
+	run.Results = results
+	run.Finished = time.Now()
+	run.Status = ScheduledRunSucceeded
+	for _, r := range results {
+		if r.Error != "" {
+			err = fmt.Errorf("%s: %s", r.Input, r.Error)
+			break
+		}
+	}
+	if err == nil {
+		err = writeScheduledRun(ctx, task.Output, run)
+	}
+	if err != nil {
+		run.Status = ScheduledRunFailed
+		run.Error = err.Error()
+		log.Error().Err(err).Str("task", task.Name).Str("run", run.ID).Msg("the scheduled task failed")
+	} else {
+		log.Info().Str("task", task.Name).Str("run", run.ID).Dur("duration", run.Finished.Sub(run.Started)).Msg("the scheduled task succeeded")
+	}
+
+	// the embeddings are only written to the output, not kept in the history
+	for i := range run.Results {
+		run.Results[i].Embedding = nil
+	}
+	ss.record(run)
+}
+
+// execute runs the task on each of its inputs
+func (ss *SchedulerService) execute(ctx context.Context, task schema.ScheduledTask) ([]schema.ScheduledTaskResult, error) {
+	cfg, err := ss.cl.LoadBackendConfigFileByName(task.Model, ss.appConfig.ModelPath, ss.appConfig.ToConfigLoaderOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := []string{""}
+	if task.Input != "" {
+		if inputs, err = scheduledTaskInputs(task.Input); err != nil {
+			return nil, err
+		}
+	}
+
+	results := []schema.ScheduledTaskResult{}
+	for _, input := range inputs {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+
+		result := schema.ScheduledTaskResult{Input: input}
+		text := task.Prompt
+		if input != "" {
+			dat, err := os.ReadFile(input)
+			if err != nil {
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+			text = strings.TrimSpace(task.Prompt + "\n\n" + string(dat))
+		}
+
+		switch task.Type {
+		case ScheduledTaskEmbeddings:
+			var fn func() ([]float32, error)
+			fn, err = backend.ModelEmbedding(text, nil, ss.ml, *cfg, ss.appConfig)
+			if err == nil {
+				result.Embedding, err = fn()
+			}
+		default:
+			result.Output, err = predict(ctx, ss.ml, ss.appConfig, cfg, text)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// scheduledTaskInputs returns the input file, or the files of the input directory
+func scheduledTaskInputs(input string) ([]string, error) {
+	fi, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{input}, nil
+	}
+
+	entries, err := os.ReadDir(input)
+	if err != nil {
+		return nil, err
+	}
+	inputs := []string{}
+	for _, e := range entries {
+		if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+			inputs = append(inputs, filepath.Join(input, e.Name()))
+		}
+	}
+	return inputs, nil
+}
+
+// writeScheduledRun writes the run to the output of the task
+func writeScheduledRun(ctx context.Context, output schema.ScheduledTaskSink, run schema.ScheduledTaskRun) error {
+	dat, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if output.Dir != "" {
+		if err := os.MkdirAll(output.Dir, 0750); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%s-%s.json", run.Task, run.Started.UTC().Format("20060102-150405"))
+		if err := os.WriteFile(filepath.Join(output.Dir, name), dat, 0600); err != nil {
+			return err
+		}
+	}
+
+	if output.Webhook != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, output.Webhook, bytes.NewReader(dat))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("the webhook answered with status %d", resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+// record adds or updates the run in the history of its task
+func (ss *SchedulerService) record(run schema.ScheduledTaskRun) {
+	ss.Lock()
+	defer ss.Unlock()
+
+	runs := ss.runs[run.Task]
+	if len(runs) > 0 && runs[0].ID == run.ID {
+		runs[0] = run
+	} else {
+		runs = append([]schema.ScheduledTaskRun{run}, runs...)
+	}
+	if len(runs) > maxScheduledRuns {
+		runs = runs[:maxScheduledRuns]
+	}
+	ss.runs[run.Task] = runs
+	utils.SaveConfig(ss.appConfig.ConfigsDir, ScheduledRunsFile, ss.runs)
+}
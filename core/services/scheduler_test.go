@@ -0,0 +1,70 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerReload(t *testing.T) {
+	appConfig := &config.ApplicationConfig{ConfigsDir: t.TempDir(), DynamicConfigsDir: t.TempDir()}
+	tasks := `- name: nightly
+  schedule: "0 2 * * *"
+  model: phi-2
+  prompt: Summarize the day
+- name: hourly
+  schedule: "@every 1h"
+  model: phi-2
+  prompt: Check the queue
+- name: never
+  schedule: "0 0 30 2 *"
+  model: phi-2
+  prompt: Never runs
+- name: invalid
+  schedule: "0 25 * * *"
+  model: phi-2
+  prompt: Skipped
+`
+	require.NoError(t, os.WriteFile(filepath.Join(appConfig.DynamicConfigsDir, ScheduledTasksFile), []byte(tasks), 0600))
+
+	ss := NewSchedulerService(nil, nil, appConfig)
+	before := time.Now()
+	ss.reload()
+
+	names := []string{}
+	for _, task := range ss.Tasks() {
+		names = append(names, task.Name)
+		switch task.Name {
+		case "nightly":
+			assert.Equal(t, 2, task.NextRun.Hour())
+			assert.Zero(t, task.NextRun.Minute())
+		case "hourly":
+			assert.WithinDuration(t, before.Add(time.Hour), task.NextRun, time.Minute)
+		case "never":
+			assert.True(t, task.NextRun.IsZero())
+		}
+	}
+	assert.Equal(t, []string{"hourly", "never", "nightly"}, names)
+
+	// the tasks are due once their next run is reached, and scheduled again
+	due := ss.due(before.Add(25 * time.Hour))
+	assert.ElementsMatch(t, []string{"hourly", "nightly"}, due)
+	assert.Empty(t, ss.due(before.Add(25*time.Hour)))
+}
+
+func TestValidateScheduledTask(t *testing.T) {
+	valid := schema.ScheduledTask{Name: "nightly", Schedule: "@daily", Model: "phi-2", Prompt: "Summarize the day"}
+	require.NoError(t, validateScheduledTask(valid))
+
+	for _, schedule := range []string{"", "* * * *", "60 * * * *", "0 5-2 * * *", "*/0 * * * *", "@every soon"} {
+		task := valid
+		task.Schedule = schedule
+		assert.ErrorContains(t, validateScheduledTask(task), "invalid schedule", schedule)
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// StoredCompletionsDir is the directory, in the configs one, holding a file for each stored chat completion
+	StoredCompletionsDir = "completions"
+
+	defaultStoredCompletionsLimit = 20
+)
+
+type storedCompletion struct {
+	Completion schema.StoredCompletion `json:"completion"`
+	Messages   []schema.Message        `json:"messages"`
+	// APIKey identifies the API key the completion was requested with, never the key itself
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// StoredCompletionsFilter selects the stored chat completions to list
+type StoredCompletionsFilter struct {
+	Model    string
+	Metadata map[string]string
+	After    string
+	Limit    int
+	Order    string // asc or desc, by creation time
+}
+
+// StoredCompletionsService persists the chat completions requested with `store: true`,
+// so the captured traffic can be used later, e.g. for evaluations or distillation
+type StoredCompletionsService struct {
+	dir string
+
+	sync.Mutex
+	completions []storedCompletion // oldest first
+}
+
+func NewStoredCompletionsService(appConfig *config.ApplicationConfig) *StoredCompletionsService {
+	scs := &StoredCompletionsService{
+		dir: filepath.Join(appConfig.ConfigsDir, StoredCompletionsDir),
+	}
+
+	entries, err := os.ReadDir(scs.dir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Str("dir", scs.dir).Msg("failed to read the stored completions")
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		var sc storedCompletion
+		utils.LoadConfig(scs.dir, e.Name(), &sc)
+		if sc.Completion.ID != "" {
+			scs.completions = append(scs.completions, sc)
+		}
+	}
+	sort.SliceStable(scs.completions, func(i, j int) bool {
+		return scs.completions[i].Completion.Created < scs.completions[j].Completion.Created
+	})
+
+	return scs
+}
+
+// Store persists a chat completion with the messages of its request and the ID of its API key
+func (scs *StoredCompletionsService) Store(completion schema.StoredCompletion, messages []schema.Message, apiKeyID string) error {
+	if err := os.MkdirAll(scs.dir, 0750); err != nil {
+		return err
+	}
+
+	// the images and the templated content are only needed to run the inference
+	stored := make([]schema.Message, len(messages))
+	for i, m := range messages {
+		m.StringContent = ""
+		m.StringImages = nil
+		stored[i] = m
+	}
+	if completion.Metadata == nil {
+		completion.Metadata = map[string]string{}
+	}
+
+	scs.Lock()
+	defer scs.Unlock()
+	sc := storedCompletion{Completion: completion, Messages: stored, APIKey: apiKeyID}
+	scs.completions = append(scs.completions, sc)
+	scs.save(sc)
+	return nil
+}
+
+// Get returns a stored chat completion
+func (scs *StoredCompletionsService) Get(id string) (schema.StoredCompletion, error) {
+	scs.Lock()
+	defer scs.Unlock()
+	i, err := scs.find(id)
+	if err != nil {
+		return schema.StoredCompletion{}, err
+	}
+	return scs.completions[i].Completion, nil
+}
+
+// Messages returns the messages of the request of a stored chat completion
+func (scs *StoredCompletionsService) Messages(id string) ([]schema.Message, error) {
+	scs.Lock()
+	defer scs.Unlock()
+	i, err := scs.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return append([]schema.Message{}, scs.completions[i].Messages...), nil
+}
+
+// List returns the stored chat completions matching the filter, and whether there are more of them
+func (scs *StoredCompletionsService) List(filter StoredCompletionsFilter) ([]schema.StoredCompletion, bool) {
+	scs.Lock()
+	defer scs.Unlock()
+
+	matching := []schema.StoredCompletion{}
+	for _, sc := range scs.completions {
+		if filter.Model != "" && sc.Completion.Model != filter.Model {
+			continue
+		}
+		if !matchMetadata(sc.Completion.Metadata, filter.Metadata) {
+			continue
+		}
+		matching = append(matching, sc.Completion)
+	}
+
+	if strings.EqualFold(filter.Order, "desc") {
+		for i, j := 0, len(matching)-1; i < j; i, j = i+1, j-1 {
+			matching[i], matching[j] = matching[j], matching[i]
+		}
+	}
+
+	if filter.After != "" {
+		for i, c := range matching {
+			if c.ID == filter.After {
+				matching = matching[i+1:]
+				break
+			}
+		}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultStoredCompletionsLimit
+	}
+	if len(matching) > limit {
+		return matching[:limit], true
+	}
+	return matching, false
+}
+
+// Update replaces the metadata of a stored chat completion
+func (scs *StoredCompletionsService) Update(id string, metadata map[string]string) (schema.StoredCompletion, error) {
+	scs.Lock()
+	defer scs.Unlock()
+	i, err := scs.find(id)
+	if err != nil {
+		return schema.StoredCompletion{}, err
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	scs.completions[i].Completion.Metadata = metadata
+	scs.save(scs.completions[i])
+	return scs.completions[i].Completion, nil
+}
+
+// Delete removes a stored chat completion
+func (scs *StoredCompletionsService) Delete(id string) error {
+	scs.Lock()
+	defer scs.Unlock()
+	i, err := scs.find(id)
+	if err != nil {
+		return err
+	}
+	scs.completions = append(scs.completions[:i], scs.completions[i+1:]...)
+	return os.Remove(filepath.Join(scs.dir, id+".json"))
+}
+
+func (scs *StoredCompletionsService) find(id string) (int, error) {
+	for i, sc := range scs.completions {
+		if sc.Completion.ID == id {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unable to find stored completion %q", id)
+}
+
+func (scs *StoredCompletionsService) save(sc storedCompletion) {
+	utils.SaveConfig(scs.dir, sc.Completion.ID+".json", sc)
+}
+
+func matchMetadata(metadata, filter map[string]string) bool {
+	for k, v := range filter {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+)
+
+// conversationBudgetTTL is how long the usage of an idle conversation is kept
+const conversationBudgetTTL = 24 * time.Hour
+
+type conversationUsage struct {
+	used     int
+	lastUsed time.Time
+}
+
+// TokenBudgetService tracks the tokens used by the conversations, to stop them once their budget is exhausted
+type TokenBudgetService struct {
+	appConfig *config.ApplicationConfig
+
+	sync.Mutex
+	conversations map[string]*conversationUsage // by API key and conversation
+	lastSweep     time.Time
+}
+
+func NewTokenBudgetService(appConfig *config.ApplicationConfig) *TokenBudgetService {
+	return &TokenBudgetService{
+		appConfig:     appConfig,
+		conversations: map[string]*conversationUsage{},
+	}
+}
+
+// Limit returns the budget of the conversations of the API key: the configured one, lowered by the requested one
+func (tbs *TokenBudgetService) Limit(apiKey string, requested int) int {
+	limit := tbs.appConfig.TokenBudget
+	if budget, exists := tbs.appConfig.ApiKeyTokenBudgets[apiKey]; exists {
+		limit = budget
+	}
+	if requested > 0 && (limit <= 0 || requested < limit) {
+		limit = requested
+	}
+	return limit
+}
+
+// Begin returns the budget of the conversation before a request, or nil when it has no budget. The error is set
+// when the budget is exhausted
+func (tbs *TokenBudgetService) Begin(apiKey, conversation string, requested int) (*schema.TokenBudget, error) {
+	limit := tbs.Limit(apiKey, requested)
+	if limit <= 0 || conversation == "" {
+		return nil, nil
+	}
+
+	tbs.Lock()
+	defer tbs.Unlock()
+	tbs.sweep()
+
+	used := 0
+	if u, exists := tbs.conversations[conversationKey(apiKey, conversation)]; exists {
+		used = u.used
+	}
+	budget := &schema.TokenBudget{ConversationID: conversation, Limit: limit, Used: used, Remaining: max(limit-used, 0)}
+	if budget.Remaining == 0 {
+		return budget, fmt.Errorf("the token budget of the conversation %s is exhausted: %d tokens used out of %d", conversation, used, limit)
+	}
+	return budget, nil
+}
+
+// Consume adds the tokens used by a request to its conversation, and updates its budget
+func (tbs *TokenBudgetService) Consume(apiKey string, budget *schema.TokenBudget, tokens int) {
+	if budget == nil {
+		return
+	}
+
+	tbs.Lock()
+	defer tbs.Unlock()
+	key := conversationKey(apiKey, budget.ConversationID)
+	u, exists := tbs.conversations[key]
+	if !exists {
+		u = &conversationUsage{}
+		tbs.conversations[key] = u
+	}
+	u.used += tokens
+	u.lastUsed = time.Now()
+
+	budget.Used = u.used
+	budget.Remaining = max(budget.Limit-u.used, 0)
+}
+
+// sweep forgets the conversations idle for longer than conversationBudgetTTL
+func (tbs *TokenBudgetService) sweep() {
+	if time.Since(tbs.lastSweep) < time.Minute {
+		return
+	}
+	tbs.lastSweep = time.Now()
+	for key, u := range tbs.conversations {
+		if time.Since(u.lastUsed) > conversationBudgetTTL {
+			delete(tbs.conversations, key)
+		}
+	}
+}
+
+// conversationKey scopes the conversations by API key, so that the clients can't use each other's budget
+func conversationKey(apiKey, conversation string) string {
+	return apiKey + "\x00" + conversation
+}
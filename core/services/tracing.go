@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/xnet"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const otlpTracesPath = "/v1/traces"
+
+// StartTracing exports the spans to the OTLP/HTTP endpoint of a collector, and propagates the trace context of the
+// requests. In privacy mode, the guard blocks the collectors which are not allowed. The returned function exports
+// the spans left and stops the export
+func StartTracing(endpoint string, guard *xnet.Guard) (func(context.Context) error, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expected an http:// or https:// URL", endpoint)
+	}
+	// the endpoints are given as the root of the collector, e.g. http://otel-collector:4318
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, otlpTracesPath) {
+		url += otlpTracesPath
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(url)}
+	if guard != nil {
+		// the exporter doesn't use the default transport, which the guard dials through
+		opts = append(opts, otlptracehttp.WithProxy(guard.Proxy(http.ProxyFromEnvironment)))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.New(context.Background(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			attribute.String("service.name", "localai"),
+			attribute.String("service.version", internal.PrintableVersion()),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/mudler/LocalAI/pkg/xnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestStartTracing(t *testing.T) {
+	_, err := StartTracing("otel-collector:4317", nil)
+	assert.Error(t, err, "the endpoints are HTTP URLs")
+
+	mu := sync.Mutex{}
+	paths, bodies := []string{}, []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	original := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+	shutdown, err := StartTracing(server.URL+"/", nil)
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "backend.predict")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"/v1/traces"}, paths)
+	assert.Contains(t, bodies[0], "backend.predict")
+	assert.Contains(t, bodies[0], "localai")
+}
+
+func TestStartTracingPrivacyMode(t *testing.T) {
+	guard, err := xnet.NewGuard(nil)
+	require.NoError(t, err)
+
+	original := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+	shutdown, err := StartTracing("http://otel-collector.example:4318", guard)
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(context.Background(), "backend.predict")
+	span.End()
+	// the errors of the export are logged by otel
+	require.NoError(t, shutdown(context.Background()))
+	assert.Equal(t, map[string]int{"otel-collector.example": 1}, guard.Report().BlockedHosts)
+}
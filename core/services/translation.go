@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/language"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// TranslationService translates the texts with the models translating their language pair: the dedicated
+// translation models of the transformers backend (NLLB, M2M100), or the LLMs instructed to translate
+type TranslationService struct {
+	appConfig *config.ApplicationConfig
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+}
+
+func NewTranslationService(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) *TranslationService {
+	return &TranslationService{
+		appConfig: appConfig,
+		cl:        cl,
+		ml:        ml,
+	}
+}
+
+// DetectSource returns the source language of the text when it's not given, and whether it was detected. It's
+// empty when the language of the text can't be told
+func DetectSource(text, source string) (string, bool) {
+	if source != "" {
+		return strings.ToLower(source), false
+	}
+	return language.Detect(text)
+}
+
+// Route returns the model translating from the source language, any when empty, into the target language: the
+// requested model when given, whatever its translation settings, or else the first dedicated translation model of
+// the pair, and the first instructed model otherwise
+func (ts *TranslationService) Route(modelName, source, target string) (*config.BackendConfig, error) {
+	if modelName != "" {
+		cfg, exists := ts.cl.GetBackendConfig(modelName)
+		if !exists {
+			return nil, fmt.Errorf("model %q not found", modelName)
+		}
+		return &cfg, nil
+	}
+	return routeTranslation(ts.cl.GetAllBackendConfigs(), source, target)
+}
+
+func routeTranslation(configs []config.BackendConfig, source, target string) (*config.BackendConfig, error) {
+	candidates := []config.BackendConfig{}
+	for _, cfg := range configs {
+		if cfg.Translation.Translates(source, target) && !cfg.Maintenance.Enabled {
+			candidates = append(candidates, cfg)
+		}
+	}
+	if len(candidates) == 0 {
+		if source == "" {
+			return nil, fmt.Errorf("no model translates into %q, enable the translation of a model", target)
+		}
+		return nil, fmt.Errorf("no model translates from %q into %q, enable the translation of a model", source, target)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].IsTranslationModel() && !candidates[j].IsTranslationModel()
+	})
+	return &candidates[0], nil
+}
+
+// Translate translates the text with the model from the source language, which only the instructed models may
+// translate without, into the target language. The terms of the glossary are translated as given
+func (ts *TranslationService) Translate(ctx context.Context, cfg *config.BackendConfig, text, source, target string, glossary map[string]string) (string, error) {
+	if source == target {
+		return text, nil
+	}
+	if cfg.IsTranslationModel() {
+		if source == "" {
+			return "", fmt.Errorf("the source language of the text can't be detected, set source_language")
+		}
+		return backend.ModelTranslate(ctx, applyGlossary(text, glossary), cfg.Translation.Code(source), cfg.Translation.Code(target), ts.ml, ts.appConfig, *cfg)
+	}
+	translation, err := predict(ctx, ts.ml, ts.appConfig, cfg, translationPrompt(text, source, target, glossary))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(translation), nil
+}
+
+// applyGlossary replaces the terms of the glossary in the text given to the dedicated translation models, which
+// can't be instructed, the longest terms first
+func applyGlossary(text string, glossary map[string]string) string {
+	terms := make([]string, 0, len(glossary))
+	for term := range glossary {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+	pairs := make([]string, 0, 2*len(terms))
+	for _, term := range terms {
+		pairs = append(pairs, term, glossary[term])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// translationPrompt instructs a model to translate the text
+func translationPrompt(text, source, target string, glossary map[string]string) string {
+	var prompt strings.Builder
+	if source == "" {
+		fmt.Fprintf(&prompt, "Translate the following text into %s.", language.Name(target))
+	} else {
+		fmt.Fprintf(&prompt, "Translate the following text from %s into %s.", language.Name(source), language.Name(target))
+	}
+	prompt.WriteString(" Answer with the translation only, keeping the formatting of the text.\n")
+	if len(glossary) > 0 {
+		terms := make([]string, 0, len(glossary))
+		for term := range glossary {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+		prompt.WriteString("\nTranslate these terms as follows:\n")
+		for _, term := range terms {
+			fmt.Fprintf(&prompt, "%s: %s\n", term, glossary[term])
+		}
+	}
+	fmt.Fprintf(&prompt, "\nText:\n%s", text)
+	return prompt.String()
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslation(t *testing.T) {
+	t.Run("matches the language pairs of the models", func(t *testing.T) {
+		translation := config.Translation{Enabled: true, Pairs: []string{"en-fr", "*-de"}}
+		assert.True(t, translation.Translates("en", "fr"))
+		assert.True(t, translation.Translates("it", "de"))
+		assert.True(t, translation.Translates("", "fr"))
+		assert.False(t, translation.Translates("fr", "en"))
+
+		assert.True(t, config.Translation{Enabled: true}.Translates("fr", "en"))
+		assert.False(t, config.Translation{Pairs: []string{"en-fr"}}.Translates("en", "fr"))
+	})
+
+	t.Run("routes to the dedicated translation models first", func(t *testing.T) {
+		configs := []config.BackendConfig{
+			{Name: "llama", Translation: config.Translation{Enabled: true}},
+			{Name: "nllb", LLMConfig: config.LLMConfig{ModelType: config.Seq2SeqModelType}, Translation: config.Translation{Enabled: true, Pairs: []string{"en-fr"}}},
+			{Name: "phi-2"},
+		}
+		cfg, err := routeTranslation(configs, "en", "fr")
+		require.NoError(t, err)
+		assert.Equal(t, "nllb", cfg.Name)
+
+		cfg, err = routeTranslation(configs, "fr", "en")
+		require.NoError(t, err)
+		assert.Equal(t, "llama", cfg.Name)
+
+		_, err = routeTranslation(configs[1:], "fr", "en")
+		assert.Error(t, err)
+	})
+
+	t.Run("skips the models in maintenance", func(t *testing.T) {
+		_, err := routeTranslation([]config.BackendConfig{
+			{Name: "llama", Translation: config.Translation{Enabled: true}, Maintenance: config.Maintenance{Enabled: true}},
+		}, "en", "fr")
+		assert.Error(t, err)
+	})
+
+	t.Run("replaces the terms of the glossary, the longest first", func(t *testing.T) {
+		glossary := map[string]string{"Local": "Locale", "LocalAI": "LocalAI", "model": "modèle"}
+		assert.Equal(t, "LocalAI runs the modèle", applyGlossary("LocalAI runs the model", glossary))
+		assert.Equal(t, "unchanged", applyGlossary("unchanged", nil))
+	})
+
+	t.Run("instructs the models to translate", func(t *testing.T) {
+		prompt := translationPrompt("Hello", "en", "fr", map[string]string{"Hello": "Salut"})
+		assert.Equal(t, "Translate the following text from English into French. Answer with the translation only, keeping the formatting of the text.\n"+
+			"\nTranslate these terms as follows:\nHello: Salut\n"+
+			"\nText:\nHello", prompt)
+		assert.Contains(t, translationPrompt("Hello", "", "fr", nil), "Translate the following text into French.")
+	})
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+const (
+	// UsageFile is the file, in the configs directory, holding the token usage counters
+	UsageFile = "usage.json"
+
+	// usageRetention is how long the hourly counters are kept
+	usageRetention = 400 * 24 * time.Hour
+	// usageSaveInterval is how often the counters are written to disk, they are written on shutdown too
+	usageSaveInterval = time.Minute
+)
+
+// Usage aggregation windows
+const (
+	UsageWindowHour  = "hour"
+	UsageWindowDay   = "day"
+	UsageWindowMonth = "month"
+)
+
+// usageCounter is the usage of an API key and a model during an hour
+type usageCounter struct {
+	Hour             int64  `json:"hour"` // unix time of the start of the hour
+	APIKey           string `json:"api_key,omitempty"`
+	Model            string `json:"model,omitempty"`
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+}
+
+type usageKey struct {
+	hour   int64
+	apiKey string
+	model  string
+}
+
+// UsageFilter selects and aggregates the usage counters
+type UsageFilter struct {
+	APIKey string // ID of the API key, see config.APIKey.ID
+	Model  string
+	Since  time.Time
+	Until  time.Time
+	// Window is the aggregation window: hour, day or month in UTC, the whole period when empty
+	Window string
+}
+
+// UsageService accounts the requests and the tokens used per API key and per model, in hourly counters persisted in
+// the configs directory, for billing or for monitoring the consumption
+type UsageService struct {
+	dir string
+
+	sync.Mutex
+	counters map[usageKey]*usageCounter
+	dirty    bool
+}
+
+func NewUsageService(appConfig *config.ApplicationConfig) *UsageService {
+	us := &UsageService{
+		dir:      appConfig.ConfigsDir,
+		counters: map[usageKey]*usageCounter{},
+	}
+
+	counters := []usageCounter{}
+	utils.LoadConfig(us.dir, UsageFile, &counters)
+	for _, c := range counters {
+		c := c
+		us.counters[usageKey{c.Hour, c.APIKey, c.Model}] = &c
+	}
+	return us
+}
+
+// Start writes the counters to disk periodically, until the context is done
+func (us *UsageService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(usageSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				us.Save()
+			}
+		}
+	}()
+}
+
+// Record counts the usage of an API request. The failed requests are not counted
+func (us *UsageService) Record(entry schema.AuditEntry) {
+	if entry.Status >= 400 {
+		return
+	}
+
+	us.Lock()
+	defer us.Unlock()
+	hour := entry.Time.UTC().Truncate(time.Hour).Unix()
+	key := usageKey{hour, entry.APIKeyID, entry.Model}
+	c, exists := us.counters[key]
+	if !exists {
+		c = &usageCounter{Hour: hour, APIKey: entry.APIKeyID, Model: entry.Model}
+		us.counters[key] = c
+	}
+	c.Requests++
+	c.PromptTokens += entry.PromptTokens
+	c.CompletionTokens += entry.CompletionTokens
+	us.dirty = true
+}
+
+// Save writes the counters to disk when they changed, forgetting the ones older than the retention
+func (us *UsageService) Save() {
+	us.Lock()
+	defer us.Unlock()
+	if !us.dirty {
+		return
+	}
+
+	oldest := time.Now().Add(-usageRetention).Unix()
+	counters := []usageCounter{}
+	for key, c := range us.counters {
+		if c.Hour < oldest {
+			delete(us.counters, key)
+			continue
+		}
+		counters = append(counters, *c)
+	}
+	sort.Slice(counters, func(i, j int) bool { return usageLess(counters[i], counters[j]) })
+	utils.SaveConfig(us.dir, UsageFile, counters)
+	us.dirty = false
+}
+
+// Since returns the start of the oldest hour counted, the zero time when nothing was counted
+func (us *UsageService) Since() time.Time {
+	us.Lock()
+	defer us.Unlock()
+	var oldest int64
+	for _, c := range us.counters {
+		if oldest == 0 || c.Hour < oldest {
+			oldest = c.Hour
+		}
+	}
+	if oldest == 0 {
+		return time.Time{}
+	}
+	return time.Unix(oldest, 0).UTC()
+}
+
+// Usage returns the usage matching the filter, by API key and model, and by window when the filter has one. The
+// entries are sorted by window, API key and model
+func (us *UsageService) Usage(filter UsageFilter) ([]schema.UsageEntry, error) {
+	window, err := usageWindow(filter.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	us.Lock()
+	defer us.Unlock()
+	entries := map[usageKey]*schema.UsageEntry{}
+	for _, c := range us.counters {
+		hour := time.Unix(c.Hour, 0).UTC()
+		if (filter.APIKey != "" && c.APIKey != filter.APIKey) || (filter.Model != "" && c.Model != filter.Model) ||
+			(!filter.Since.IsZero() && !hour.Add(time.Hour).After(filter.Since)) ||
+			(!filter.Until.IsZero() && !hour.Before(filter.Until)) {
+			continue
+		}
+
+		start, end := window(hour)
+		key := usageKey{start.Unix(), c.APIKey, c.Model}
+		e, exists := entries[key]
+		if !exists {
+			e = &schema.UsageEntry{APIKey: c.APIKey, Model: c.Model}
+			if !start.IsZero() {
+				e.StartTime, e.EndTime = start.Unix(), end.Unix()
+			}
+			entries[key] = e
+		}
+		e.Requests += c.Requests
+		e.PromptTokens += c.PromptTokens
+		e.CompletionTokens += c.CompletionTokens
+		e.TotalTokens += c.PromptTokens + c.CompletionTokens
+	}
+
+	usage := make([]schema.UsageEntry, 0, len(entries))
+	for _, e := range entries {
+		usage = append(usage, *e)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		a, b := usage[i], usage[j]
+		return usageLess(usageCounter{Hour: a.StartTime, APIKey: a.APIKey, Model: a.Model}, usageCounter{Hour: b.StartTime, APIKey: b.APIKey, Model: b.Model})
+	})
+	return usage, nil
+}
+
+func usageLess(a, b usageCounter) bool {
+	if a.Hour != b.Hour {
+		return a.Hour < b.Hour
+	}
+	if a.APIKey != b.APIKey {
+		return a.APIKey < b.APIKey
+	}
+	return a.Model < b.Model
+}
+
+// usageWindow returns the function giving the window of an hour, zero times when the usage isn't split in windows
+func usageWindow(window string) (func(hour time.Time) (time.Time, time.Time), error) {
+	switch window {
+	case "":
+		return func(time.Time) (time.Time, time.Time) { return time.Time{}, time.Time{} }, nil
+	case UsageWindowHour:
+		return func(hour time.Time) (time.Time, time.Time) { return hour, hour.Add(time.Hour) }, nil
+	case UsageWindowDay:
+		return func(hour time.Time) (time.Time, time.Time) {
+			day := time.Date(hour.Year(), hour.Month(), hour.Day(), 0, 0, 0, 0, time.UTC)
+			return day, day.AddDate(0, 0, 1)
+		}, nil
+	case UsageWindowMonth:
+		return func(hour time.Time) (time.Time, time.Time) {
+			month := time.Date(hour.Year(), hour.Month(), 1, 0, 0, 0, 0, time.UTC)
+			return month, month.AddDate(0, 1, 0)
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid window %q, expected %s, %s or %s", window, UsageWindowHour, UsageWindowDay, UsageWindowMonth)
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsage(t *testing.T) {
+	appConfig := &config.ApplicationConfig{ConfigsDir: t.TempDir()}
+	us := NewUsageService(appConfig)
+
+	// recent enough to be kept by the retention
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -3)
+	record := func(at time.Time, key, model string, prompt, completion, status int) {
+		us.Record(schema.AuditEntry{Time: at, APIKeyID: key, Model: model, PromptTokens: prompt, CompletionTokens: completion, Status: status})
+	}
+	record(day.Add(9*time.Hour), "team-a", "phi-2", 10, 20, 200)
+	record(day.Add(9*time.Hour+30*time.Minute), "team-a", "phi-2", 5, 5, 200)
+	record(day.Add(14*time.Hour), "team-a", "phi-2", 1, 1, 200)
+	record(day.Add(26*time.Hour), "team-b", "llama", 100, 50, 200)
+	record(day.Add(27*time.Hour), "team-b", "llama", 100, 50, 500)
+
+	t.Run("totals by key and model", func(t *testing.T) {
+		usage, err := us.Usage(UsageFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, []schema.UsageEntry{
+			{APIKey: "team-a", Model: "phi-2", Requests: 3, PromptTokens: 16, CompletionTokens: 26, TotalTokens: 42},
+			{APIKey: "team-b", Model: "llama", Requests: 1, PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150},
+		}, usage)
+	})
+
+	t.Run("splits the usage by window", func(t *testing.T) {
+		usage, err := us.Usage(UsageFilter{Window: UsageWindowDay})
+		require.NoError(t, err)
+		require.Len(t, usage, 2)
+		assert.Equal(t, day.Unix(), usage[0].StartTime)
+		assert.Equal(t, day.AddDate(0, 0, 1).Unix(), usage[0].EndTime)
+		assert.Equal(t, day.AddDate(0, 0, 1).Unix(), usage[1].StartTime)
+
+		usage, err = us.Usage(UsageFilter{APIKey: "team-a", Window: UsageWindowHour})
+		require.NoError(t, err)
+		require.Len(t, usage, 2)
+		assert.Equal(t, 2, usage[0].Requests)
+		assert.Equal(t, 1, usage[1].Requests)
+
+		_, err = us.Usage(UsageFilter{Window: "week"})
+		assert.Error(t, err)
+	})
+
+	t.Run("filters the period", func(t *testing.T) {
+		usage, err := us.Usage(UsageFilter{Since: day.Add(10 * time.Hour), Until: day.AddDate(0, 0, 1)})
+		require.NoError(t, err)
+		require.Len(t, usage, 1)
+		assert.Equal(t, 1, usage[0].Requests)
+		assert.Equal(t, 2, usage[0].TotalTokens)
+	})
+
+	t.Run("persists the counters", func(t *testing.T) {
+		us.Save()
+		usage, err := NewUsageService(appConfig).Usage(UsageFilter{Model: "llama"})
+		require.NoError(t, err)
+		assert.Equal(t, []schema.UsageEntry{{APIKey: "team-b", Model: "llama", Requests: 1, PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}}, usage)
+	})
+}
@@ -11,6 +11,10 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"dario.cat/mergo"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/events"
+	"github.com/mudler/LocalAI/pkg/store"
+	"github.com/mudler/LocalAI/pkg/templates"
 	"github.com/rs/zerolog/log"
 )
 
@@ -39,6 +43,18 @@ func newConfigFileHandler(appConfig *config.ApplicationConfig) configFileHandler
 	if err != nil {
 		log.Error().Err(err).Str("file", "external_backends.json").Msg("unable to register config file handler")
 	}
+	err = c.Register("vector_stores.json", readVectorStoresJson(*appConfig), true)
+	if err != nil {
+		log.Error().Err(err).Str("file", "vector_stores.json").Msg("unable to register config file handler")
+	}
+	err = c.Register("generation_presets.json", readGenerationPresetsJson, true)
+	if err != nil {
+		log.Error().Err(err).Str("file", "generation_presets.json").Msg("unable to register config file handler")
+	}
+	err = c.Register("prompt_variables.json", readPromptVariablesJson, true)
+	if err != nil {
+		log.Error().Err(err).Str("file", "prompt_variables.json").Msg("unable to register config file handler")
+	}
 	return c
 }
 
@@ -64,7 +80,9 @@ func (c *configFileHandler) callHandler(filename string, handler fileHandler) {
 
 	if err = handler(fileContent, c.appConfig); err != nil {
 		log.Error().Err(err).Msg("WatchConfigDirectory goroutine failed to update options")
+		return
 	}
+	c.appConfig.Events.Publish(events.ConfigReloaded, map[string]any{"source": "file", "file": filename})
 }
 
 func (c *configFileHandler) Watch() error {
@@ -133,21 +151,20 @@ func readApiKeysJson(startupAppConfig config.ApplicationConfig) fileHandler {
 		log.Trace().Int("numKeys", len(startupAppConfig.ApiKeys)).Msg("api keys provided at startup")
 
 		if len(fileContent) > 0 {
-			// Parse JSON content from the file
-			var fileKeys []string
-			err := json.Unmarshal(fileContent, &fileKeys)
+			// An invalid file keeps the keys loaded previously
+			fileKeys, err := config.ParseAPIKeys(fileContent)
 			if err != nil {
-				return err
+				return fmt.Errorf("invalid api_keys.json, keeping the previous API keys: %w", err)
 			}
 
 			log.Trace().Int("numKeys", len(fileKeys)).Msg("discovered API keys from api keys dynamic config dile")
 
-			appConfig.ApiKeys = append(startupAppConfig.ApiKeys, fileKeys...)
+			appConfig.ApiKeyStore.Set(fileKeys)
 		} else {
 			log.Trace().Msg("no API keys discovered from dynamic config file")
-			appConfig.ApiKeys = startupAppConfig.ApiKeys
+			appConfig.ApiKeyStore.Set(nil)
 		}
-		log.Trace().Int("numKeys", len(appConfig.ApiKeys)).Msg("total api keys after processing")
+		log.Trace().Int("numKeys", len(appConfig.ApiKeys)+appConfig.ApiKeyStore.Len()).Msg("total api keys after processing")
 		return nil
 	}
 
@@ -178,3 +195,69 @@ func readExternalBackendsJson(startupAppConfig config.ApplicationConfig) fileHan
 	}
 	return handler
 }
+
+func readVectorStoresJson(startupAppConfig config.ApplicationConfig) fileHandler {
+	handler := func(fileContent []byte, appConfig *config.ApplicationConfig) error {
+		log.Debug().Msg("processing vector_stores.json")
+
+		if len(fileContent) > 0 {
+			// Parse JSON content from the file
+			var fileStores map[string]store.Config
+			err := json.Unmarshal(fileContent, &fileStores)
+			if err != nil {
+				return err
+			}
+			stores := map[string]store.Config{}
+			for k, v := range startupAppConfig.VectorStores {
+				stores[k] = v
+			}
+			err = mergo.Merge(&stores, &fileStores, mergo.WithOverride)
+			if err != nil {
+				return err
+			}
+			appConfig.VectorStores = stores
+		} else {
+			appConfig.VectorStores = startupAppConfig.VectorStores
+		}
+		log.Debug().Msg("vector stores loaded from vector_stores.json")
+		return nil
+	}
+	return handler
+}
+
+// readGenerationPresetsJson replaces the generation presets by the ones of generation_presets.json, a map of their
+// names to the request fields they set. The previous presets are kept when one of them isn't a valid request
+func readGenerationPresetsJson(fileContent []byte, appConfig *config.ApplicationConfig) error {
+	log.Debug().Msg("processing generation_presets.json")
+
+	presets := map[string]json.RawMessage{}
+	if len(fileContent) > 0 {
+		if err := json.Unmarshal(fileContent, &presets); err != nil {
+			return err
+		}
+	}
+	for name, preset := range presets {
+		if err := json.Unmarshal(preset, &schema.OpenAIRequest{}); err != nil {
+			return fmt.Errorf("invalid generation preset %q: %w", name, err)
+		}
+	}
+	appConfig.GenerationPresets = presets
+	log.Debug().Int("presets", len(presets)).Msg("generation presets loaded from generation_presets.json")
+	return nil
+}
+
+// readPromptVariablesJson replaces the prompt variables by the ones of prompt_variables.json, a map of their names
+// to their values
+func readPromptVariablesJson(fileContent []byte, appConfig *config.ApplicationConfig) error {
+	log.Debug().Msg("processing prompt_variables.json")
+
+	variables := map[string]string{}
+	if len(fileContent) > 0 {
+		if err := json.Unmarshal(fileContent, &variables); err != nil {
+			return err
+		}
+	}
+	templates.SetVariables(variables)
+	log.Debug().Int("variables", len(variables)).Msg("prompt variables loaded from prompt_variables.json")
+	return nil
+}
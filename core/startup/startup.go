@@ -3,12 +3,17 @@ package startup
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/mudler/LocalAI/core"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/p2p"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/internal"
 	"github.com/mudler/LocalAI/pkg/assets"
+	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/mudler/LocalAI/pkg/events"
 	"github.com/mudler/LocalAI/pkg/library"
 	"github.com/mudler/LocalAI/pkg/model"
 	pkgStartup "github.com/mudler/LocalAI/pkg/startup"
@@ -21,6 +26,12 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 
 	log.Info().Msgf("Starting LocalAI using %d threads, with models path: %s", options.Threads, options.ModelPath)
 	log.Info().Msgf("LocalAI version: %s", internal.PrintableVersion())
+	if options.NetworkGuard != nil {
+		if err := options.NetworkGuard.Install(); err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to enable the privacy mode: %w", err)
+		}
+		log.Info().Msg("Privacy mode enabled: the outbound connections are blocked, except to the loopback and the allowed hosts")
+	}
 	caps, err := xsysinfo.CPUCapabilities()
 	if err == nil {
 		log.Debug().Msgf("CPU capabilities: %v", caps)
@@ -60,12 +71,32 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 		}
 	}
 
+	downloader.SetTorrentOptions(options.Context, options.TorrentOptions)
+	if !options.DisableBlobStore {
+		downloader.SetBlobStore(filepath.Join(options.ModelPath, downloader.BlobsDir))
+		if freed, err := downloader.GarbageCollectBlobs(); err != nil {
+			log.Error().Err(err).Msg("error removing the unused blobs")
+		} else if freed > 0 {
+			log.Info().Int64("bytes", freed).Msg("removed the unused blobs")
+		}
+	}
+
 	if err := pkgStartup.InstallModels(options.Galleries, options.ModelLibraryURL, options.ModelPath, options.EnforcePredownloadScans, nil, options.ModelsURL...); err != nil {
 		log.Error().Err(err).Msg("error installing models")
 	}
 
 	cl := config.NewBackendConfigLoader(options.ModelPath)
 	ml := model.NewModelLoader(options.ModelPath)
+	ml.AddModelEventHandler(func(modelID string, event model.ModelEvent) {
+		options.Events.Publish(modelEventTypes[event], map[string]any{"model": modelID})
+	})
+	p2p.SetMembershipHandler(func(node p2p.NodeData, joined bool) {
+		eventType := events.P2PNodeLeft
+		if joined {
+			eventType = events.P2PNodeJoined
+		}
+		options.Events.Publish(eventType, map[string]any{"id": node.ID, "name": node.Name, "service": node.ServiceID})
+	})
 
 	configLoaderOpts := options.ToConfigLoaderOptions()
 
@@ -83,16 +114,30 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 		log.Error().Err(err).Msg("error downloading models")
 	}
 
+	options.PreloadFailures = map[string]string{}
+
 	if options.PreloadJSONModels != "" {
-		if err := services.ApplyGalleryFromString(options.ModelPath, options.PreloadJSONModels, options.EnforcePredownloadScans, options.Galleries); err != nil {
+		failures, err := services.ApplyGalleryFromString(options.ModelPath, options.PreloadJSONModels, options.EnforcePredownloadScans, options.Galleries, options.PreloadParallelism)
+		if err != nil {
 			return nil, nil, nil, err
 		}
+		for m, e := range failures {
+			options.PreloadFailures[m] = e.Error()
+		}
 	}
 
 	if options.PreloadModelsFromPath != "" {
-		if err := services.ApplyGalleryFromFile(options.ModelPath, options.PreloadModelsFromPath, options.EnforcePredownloadScans, options.Galleries); err != nil {
+		failures, err := services.ApplyGalleryFromFile(options.ModelPath, options.PreloadModelsFromPath, options.EnforcePredownloadScans, options.Galleries, options.PreloadParallelism)
+		if err != nil {
 			return nil, nil, nil, err
 		}
+		for m, e := range failures {
+			options.PreloadFailures[m] = e.Error()
+		}
+	}
+
+	if len(options.PreloadFailures) > 0 {
+		log.Error().Int("failed", len(options.PreloadFailures)).Msg("some models failed to preload, continuing startup")
 	}
 
 	if options.Debug {
@@ -108,6 +153,13 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 		if err != nil {
 			log.Warn().Msgf("Failed extracting backend assets files: %s (might be required for some backends to work properly)", err)
 		}
+
+		// Tell early if the llama.cpp builds can't run on this CPU, instead of crashing on the first model load
+		if variant, err := model.LLamaCPPCPUVariant(options.AssetsDestination); err != nil {
+			log.Error().Err(err).Msg("llama.cpp models can't be loaded on this CPU")
+		} else if variant != "" {
+			log.Info().Msgf("llama.cpp will run with the %s variant on this CPU", variant)
+		}
 	}
 
 	if options.LibPath != "" {
@@ -128,6 +180,14 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 		}
 	}()
 
+	// sample the resources used by the backends, for the metrics and the watchdog
+	go ml.SampleProcesses(options.Context, 15*time.Second)
+
+	// with a single active backend, prefetching a model would stop the one in use
+	if options.PrefetchModels && !options.SingleBackend {
+		ml.EnablePrefetch()
+	}
+
 	if options.WatchDog {
 		wd := model.NewWatchDog(
 			ml,
@@ -135,6 +195,9 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 			options.WatchDogIdleTimeout,
 			options.WatchDogBusy,
 			options.WatchDogIdle)
+		wd.SetEventHandler(func(modelID string, event model.ModelEvent) {
+			options.Events.Publish(modelEventTypes[event], map[string]any{"model": modelID})
+		})
 		ml.SetWatchDog(wd)
 		go wd.Run()
 		go func() {
@@ -151,6 +214,15 @@ func Startup(opts ...config.AppOption) (*config.BackendConfigLoader, *model.Mode
 	return cl, ml, options, nil
 }
 
+// modelEventTypes are the types of the events of the model loader and the watchdog on the event bus
+var modelEventTypes = map[model.ModelEvent]string{
+	model.ModelLoaded:   events.ModelLoaded,
+	model.ModelUnloaded: events.ModelUnloaded,
+	model.ModelHung:     events.ModelHung,
+	model.ModelIdle:     events.WatchdogIdle,
+	model.ModelBusy:     events.WatchdogBusy,
+}
+
 func startWatcher(options *config.ApplicationConfig) {
 	if options.DynamicConfigsDir == "" {
 		// No need to start the watcher if the directory is not set
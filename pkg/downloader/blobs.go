@@ -0,0 +1,147 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BlobsDir is the directory of the models path storing the content of the files downloaded, named after their
+// SHA256: the model files are hard links to these blobs, so the models downloading the same file share one copy
+const BlobsDir = ".blobs"
+
+// minBlobSize is the size of the smallest file stored: sharing the small files, like the configs and the templates
+// edited in place, isn't worth it
+const minBlobSize = 1 << 20
+
+var blobs = struct {
+	sync.Mutex
+	dir string
+}{}
+
+// SetBlobStore stores the content of the files downloaded in dir, the files being hard links to it. An empty dir
+// disables the store, the files are then downloaded as is
+func SetBlobStore(dir string) {
+	blobs.Lock()
+	defer blobs.Unlock()
+	blobs.dir = dir
+}
+
+func blobPath(dir, sha string) string {
+	return filepath.Join(dir, "sha256", sha)
+}
+
+// linkBlob links filePath to the stored blob of sha, reporting whether there is one
+func linkBlob(filePath, sha string) bool {
+	blobs.Lock()
+	defer blobs.Unlock()
+	if blobs.dir == "" || sha == "" {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0750); err != nil {
+		return false
+	}
+	if err := os.Link(blobPath(blobs.dir, sha), filePath); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Debug().Err(err).Msgf("Failed linking %q to its blob", filePath)
+		}
+		return false
+	}
+	return true
+}
+
+// storeBlob moves the content of the file downloaded to the store, the file becoming a hard link to the blob of its
+// SHA. A file whose content is already stored is replaced by a link to the stored blob. The SHA is calculated when
+// missing, the files smaller than minBlobSize are left alone
+func storeBlob(filePath, sha string) error {
+	blobs.Lock()
+	enabled := blobs.dir != ""
+	blobs.Unlock()
+	if !enabled {
+		return nil
+	}
+	if info, err := os.Stat(filePath); err != nil || !info.Mode().IsRegular() || info.Size() < minBlobSize {
+		return err
+	}
+	// the file is hashed without holding the lock, not to block the other downloads meanwhile
+	if sha == "" {
+		var err error
+		if sha, err = calculateSHA(filePath); err != nil {
+			return fmt.Errorf("failed to calculate SHA for file %q: %v", filePath, err)
+		}
+	}
+
+	blobs.Lock()
+	defer blobs.Unlock()
+	if blobs.dir == "" {
+		return nil
+	}
+	blob := blobPath(blobs.dir, sha)
+	if err := os.MkdirAll(filepath.Dir(blob), 0750); err != nil {
+		return err
+	}
+	err := os.Link(filePath, blob)
+	if err == nil || !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if os.SameFile(stat(filePath), stat(blob)) {
+		return nil
+	}
+
+	// the content is already stored: the copy downloaded is replaced by a link to the blob
+	tmp := filePath + ".blob"
+	os.Remove(tmp)
+	if err := os.Link(blob, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filePath); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	log.Info().Msgf("File %q is already stored as blob %s, sharing it", filePath, sha)
+	return nil
+}
+
+func stat(path string) fs.FileInfo {
+	info, _ := os.Stat(path)
+	return info
+}
+
+// GarbageCollectBlobs removes the blobs which no file links to anymore, and returns the number of bytes freed
+func GarbageCollectBlobs() (int64, error) {
+	blobs.Lock()
+	defer blobs.Unlock()
+	if blobs.dir == "" {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(blobs.dir, "sha256"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var freed int64
+	for _, e := range entries {
+		info, infoErr := e.Info()
+		if infoErr != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if linkCount(info) != 1 {
+			continue
+		}
+		if rmErr := os.Remove(filepath.Join(blobs.dir, "sha256", e.Name())); rmErr != nil {
+			err = errors.Join(err, rmErr)
+			continue
+		}
+		log.Info().Msgf("Removed blob %s, unused", e.Name())
+		freed += info.Size()
+	}
+	return freed, err
+}
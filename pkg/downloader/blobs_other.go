@@ -0,0 +1,11 @@
+//go:build !unix
+
+package downloader
+
+import "io/fs"
+
+// linkCount returns 0, the number of hard links to the files can't be told on this platform: the blobs are then
+// never garbage collected
+func linkCount(info fs.FileInfo) uint64 {
+	return 0
+}
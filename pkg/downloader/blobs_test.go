@@ -0,0 +1,98 @@
+package downloader_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	. "github.com/mudler/LocalAI/pkg/downloader"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Blob store", func() {
+	// large enough to be stored
+	content := make([]byte, 2<<20)
+	sha := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	var dir string
+	var mirror *httptest.Server
+	var downloads atomic.Int32
+	noStatus := func(string, string, string, float64) {}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "blobs")
+		Expect(err).ToNot(HaveOccurred())
+		downloads.Store(0)
+		mirror = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			downloads.Add(1)
+			w.Write(content)
+		}))
+		SetBlobStore(filepath.Join(dir, BlobsDir))
+	})
+
+	AfterEach(func() {
+		SetBlobStore("")
+		mirror.Close()
+		os.RemoveAll(dir)
+	})
+
+	sameFile := func(a, b string) bool {
+		infoA, err := os.Stat(a)
+		Expect(err).ToNot(HaveOccurred())
+		infoB, err := os.Stat(b)
+		Expect(err).ToNot(HaveOccurred())
+		return os.SameFile(infoA, infoB)
+	}
+
+	It("shares the content downloaded by the models", func() {
+		first, second := filepath.Join(dir, "first.gguf"), filepath.Join(dir, "second.gguf")
+		Expect(URI(mirror.URL).DownloadFile(first, sha, 0, 1, noStatus)).To(Succeed())
+		Expect(URI(mirror.URL).DownloadFile(second, sha, 0, 1, noStatus)).To(Succeed())
+
+		Expect(downloads.Load()).To(Equal(int32(1)))
+		Expect(sameFile(first, second)).To(BeTrue())
+		Expect(sameFile(first, filepath.Join(dir, BlobsDir, "sha256", sha))).To(BeTrue())
+	})
+
+	It("stores the files downloaded without SHA", func() {
+		first, second := filepath.Join(dir, "first.gguf"), filepath.Join(dir, "second.gguf")
+		Expect(URI(mirror.URL).DownloadFile(first, "", 0, 1, noStatus)).To(Succeed())
+		Expect(URI(mirror.URL+"/other").DownloadFile(second, "", 0, 1, noStatus)).To(Succeed())
+
+		// downloaded twice, stored once
+		Expect(downloads.Load()).To(Equal(int32(2)))
+		Expect(sameFile(first, second)).To(BeTrue())
+		Expect(os.ReadFile(second)).To(Equal(content))
+	})
+
+	It("removes the blobs no model uses", func() {
+		first, second := filepath.Join(dir, "first.gguf"), filepath.Join(dir, "second.gguf")
+		Expect(URI(mirror.URL).DownloadFile(first, sha, 0, 1, noStatus)).To(Succeed())
+		Expect(URI(mirror.URL).DownloadFile(second, sha, 0, 1, noStatus)).To(Succeed())
+
+		Expect(os.Remove(first)).To(Succeed())
+		Expect(GarbageCollectBlobs()).To(BeZero())
+		Expect(os.ReadFile(second)).To(Equal(content))
+
+		Expect(os.Remove(second)).To(Succeed())
+		Expect(GarbageCollectBlobs()).To(Equal(int64(len(content))))
+		Expect(filepath.Join(dir, BlobsDir, "sha256", sha)).ToNot(BeAnExistingFile())
+	})
+
+	It("leaves the small files alone", func() {
+		small := filepath.Join(dir, "model.yaml")
+		config := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("name: model"))
+		}))
+		defer config.Close()
+
+		Expect(URI(config.URL).DownloadFile(small, "", 0, 1, noStatus)).To(Succeed())
+		Expect(filepath.Join(dir, BlobsDir, "sha256")).ToNot(BeADirectory())
+	})
+})
@@ -0,0 +1,266 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// torrentsDir is the directory next to the models keeping the files downloaded over BitTorrent while they are seeded
+	torrentsDir = ".torrents"
+	// torrentStallTimeout aborts a torrent download receiving nothing, e.g. without peers, to fall back to the mirrors
+	torrentStallTimeout = 120 * time.Second
+)
+
+// TorrentOptions configures the seeding of the files downloaded over BitTorrent
+type TorrentOptions struct {
+	// Seed keeps uploading the files once downloaded, until SeedRatio or SeedTime is reached
+	Seed      bool
+	SeedRatio float64       // 0 seeds without ratio limit
+	SeedTime  time.Duration // 0 seeds without time limit
+}
+
+var torrents = struct {
+	sync.Mutex
+	ctx     context.Context
+	opts    TorrentOptions
+	seeding map[string]bool
+}{
+	ctx:     context.Background(),
+	seeding: map[string]bool{},
+}
+
+// SetTorrentOptions sets how the files downloaded over BitTorrent are seeded. The seeding stops when ctx is done
+func SetTorrentOptions(ctx context.Context, opts TorrentOptions) {
+	torrents.Lock()
+	defer torrents.Unlock()
+	torrents.ctx = ctx
+	torrents.opts = opts
+}
+
+// LooksLikeTorrent reports whether the uri is a magnet link or a .torrent file, downloaded over BitTorrent
+func (u URI) LooksLikeTorrent() bool {
+	if strings.HasPrefix(string(u), MagnetPrefix) {
+		return true
+	}
+	return (strings.HasPrefix(string(u), HTTPPrefix) || strings.HasPrefix(string(u), HTTPSPrefix)) &&
+		strings.HasSuffix(strings.SplitN(string(u), "?", 2)[0], ".torrent")
+}
+
+// magnetName returns the display name (dn) of a magnet link, the name of its file
+func magnetName(magnet string) string {
+	_, query, _ := strings.Cut(magnet, "?")
+	for _, param := range strings.Split(query, "&") {
+		if value, found := strings.CutPrefix(param, "dn="); found {
+			name, err := url.QueryUnescape(value)
+			if err != nil {
+				return ""
+			}
+			return filepath.Base(name)
+		}
+	}
+	return ""
+}
+
+// downloadTorrent fetches the file from the magnet link or the .torrent file of the uri with aria2c, including
+// from the webseeds (ws) of the magnet links, and verifies its SHA. The failures are never transient: a swarm
+// without peers won't do better in a few seconds, the next mirror is tried instead
+func (uri URI) downloadTorrent(filePath, sha string, fileN, total int, downloadStatus func(string, string, string, float64)) error {
+	aria2c, err := exec.LookPath("aria2c")
+	if err != nil {
+		return &downloadError{err: fmt.Errorf("aria2c is required to download %q over BitTorrent: %w", filePath, err)}
+	}
+	log.Info().Msgf("Downloading %q over BitTorrent", uri)
+
+	dir := filepath.Join(filepath.Dir(filePath), torrentsDir, filepath.Base(filePath))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create directory %q: %v", dir, err)
+	}
+
+	cmd := exec.Command(aria2c,
+		"--dir="+dir,
+		"--seed-time=0",
+		"--follow-torrent=mem",
+		"--bt-save-metadata=true",
+		"--file-allocation=none",
+		"--summary-interval=1",
+		"--console-log-level=warn",
+		"--bt-stop-timeout="+strconv.Itoa(int(torrentStallTimeout.Seconds())),
+		string(uri),
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return &downloadError{err: fmt.Errorf("failed to start aria2c: %v", err)}
+	}
+	reportTorrentProgress(stdout, filePath, fileN, total, downloadStatus)
+	if err := cmd.Wait(); err != nil {
+		os.RemoveAll(dir)
+		return &downloadError{err: fmt.Errorf("failed to download %q over BitTorrent: %v: %s", filePath, err, strings.TrimSpace(stderr.String()))}
+	}
+
+	downloaded, err := torrentFile(dir, filepath.Base(filePath))
+	if err != nil {
+		os.RemoveAll(dir)
+		return &downloadError{err: err}
+	}
+
+	if sha != "" {
+		calculatedSHA, err := calculateSHA(downloaded)
+		if err != nil {
+			return fmt.Errorf("failed to calculate SHA for file %q: %v", downloaded, err)
+		}
+		if calculatedSHA != sha {
+			os.RemoveAll(dir)
+			return &downloadError{err: fmt.Errorf("SHA mismatch for file %q ( calculated: %s != metadata: %s )", filePath, calculatedSHA, sha)}
+		}
+	} else {
+		log.Debug().Msgf("SHA missing for %q. Skipping validation", filePath)
+	}
+
+	torrents.Lock()
+	opts, ctx := torrents.opts, torrents.ctx
+	torrents.Unlock()
+	if !opts.Seed {
+		defer os.RemoveAll(dir)
+		if err := os.Rename(downloaded, filePath); err != nil {
+			return fmt.Errorf("failed to rename file %s -> %s: %v", downloaded, filePath, err)
+		}
+		log.Info().Msgf("File %q downloaded over BitTorrent and verified", filePath)
+		return nil
+	}
+
+	// the seeded copy stays in the torrent directory, the model is a hard link to it and takes no extra space
+	if err := os.Link(downloaded, filePath); err != nil {
+		return fmt.Errorf("failed to link file %s -> %s: %v", downloaded, filePath, err)
+	}
+	log.Info().Msgf("File %q downloaded over BitTorrent and verified", filePath)
+	seedTorrent(ctx, aria2c, uri, dir, opts)
+	return nil
+}
+
+// seedTorrent uploads the file downloaded in dir in the background, until the limits of opts are reached or ctx
+// is done
+func seedTorrent(ctx context.Context, aria2c string, uri URI, dir string, opts TorrentOptions) {
+	torrents.Lock()
+	if torrents.seeding[dir] {
+		torrents.Unlock()
+		return
+	}
+	torrents.seeding[dir] = true
+	torrents.Unlock()
+
+	args := []string{
+		"--dir=" + dir,
+		"--follow-torrent=mem",
+		"--check-integrity=true",
+		"--bt-seed-unverified=false",
+		"--console-log-level=warn",
+		"--seed-ratio=" + strconv.FormatFloat(opts.SeedRatio, 'f', -1, 64),
+	}
+	if opts.SeedTime > 0 {
+		args = append(args, "--seed-time="+strconv.FormatFloat(opts.SeedTime.Minutes(), 'f', -1, 64))
+	}
+	cmd := exec.CommandContext(ctx, aria2c, append(args, string(uri))...)
+
+	go func() {
+		defer func() {
+			torrents.Lock()
+			delete(torrents.seeding, dir)
+			torrents.Unlock()
+		}()
+		log.Info().Str("dir", dir).Float64("ratio", opts.SeedRatio).Dur("time", opts.SeedTime).Msg("seeding over BitTorrent")
+		if out, err := cmd.CombinedOutput(); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Str("dir", dir).Msgf("seeding stopped: %s", strings.TrimSpace(string(out)))
+			return
+		}
+		log.Info().Str("dir", dir).Msg("seeding completed")
+	}()
+}
+
+// torrentFile returns the file named name downloaded in dir, or its only file. The metadata and the control files
+// of aria2c are ignored
+func torrentFile(dir, name string) (string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".torrent") || strings.HasSuffix(path, ".aria2") {
+			return err
+		}
+		if d.Name() == name {
+			files = []string{path}
+			return filepath.SkipAll
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("the torrent has %d files and none is named %q", len(files), name)
+	}
+	return files[0], nil
+}
+
+// torrentProgress matches the progress of the downloads in the summaries of aria2c, e.g.
+// [#2089b0 400.0MiB/1.2GiB(33%) CN:44 SD:5 DL:12MiB ETA:1m]
+var torrentProgress = regexp.MustCompile(`\[#\w+ (?:SEED\([^)]*\) )?([\d.]+[KMGT]?i?B)/([\d.]+[KMGT]?i?B)\((\d+)%\)`)
+
+// reportTorrentProgress reports the progress read in the output of aria2c to downloadStatus, until the end of the
+// output
+func reportTorrentProgress(output io.Reader, filePath string, fileN, total int, downloadStatus func(string, string, string, float64)) {
+	scanner := bufio.NewScanner(output)
+	scanner.Split(scanTorrentLines)
+	for scanner.Scan() {
+		current, size, percentage, ok := parseTorrentProgress(scanner.Text())
+		if !ok || downloadStatus == nil {
+			continue
+		}
+		if total > 1 {
+			percentage = (float64(fileN-1) + percentage/100) / float64(total) * 100
+		}
+		downloadStatus(filePath, current, size, percentage)
+	}
+	io.Copy(io.Discard, output)
+}
+
+func parseTorrentProgress(line string) (current, size string, percentage float64, ok bool) {
+	m := torrentProgress.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", 0, false
+	}
+	percentage, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return "", "", 0, false
+	}
+	return m[1], m[2], percentage, true
+}
+
+// scanTorrentLines splits the output of aria2c in lines, which its readouts end with a carriage return
+func scanTorrentLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
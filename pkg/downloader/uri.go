@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
@@ -27,6 +29,13 @@ const (
 	GithubURI         = "github:"
 	GithubURI2        = "github://"
 	LocalPrefix       = "file://"
+	MagnetPrefix      = "magnet:"
+)
+
+const (
+	downloadAttempts   = 3
+	downloadBackoff    = 2 * time.Second
+	maxDownloadBackoff = 30 * time.Second
 )
 
 type URI string
@@ -93,6 +102,10 @@ func (u URI) FilenameFromUrl() (string, error) {
 }
 
 func filenameFromUrl(urlstr string) (string, error) {
+	if strings.HasPrefix(urlstr, MagnetPrefix) {
+		return magnetName(urlstr), nil
+	}
+
 	// strip anything after @
 	if strings.Contains(urlstr, "@") {
 		urlstr = strings.Split(urlstr, "@")[0]
@@ -116,6 +129,7 @@ func (u URI) LooksLikeURL() bool {
 		strings.HasPrefix(string(u), GithubURI) ||
 		strings.HasPrefix(string(u), OllamaPrefix) ||
 		strings.HasPrefix(string(u), OCIPrefix) ||
+		strings.HasPrefix(string(u), MagnetPrefix) ||
 		strings.HasPrefix(string(u), GithubURI2)
 }
 
@@ -193,31 +207,26 @@ func removePartialFile(tmpFilePath string) error {
 }
 
 func (uri URI) DownloadFile(filePath, sha string, fileN, total int, downloadStatus func(string, string, string, float64)) error {
-	url := uri.ResolveURL()
-	if uri.LooksLikeOCI() {
-		progressStatus := func(desc ocispec.Descriptor) io.Writer {
-			return &progressWriter{
-				fileName:       filePath,
-				total:          desc.Size,
-				hash:           sha256.New(),
-				fileNo:         fileN,
-				totalFiles:     total,
-				downloadStatus: downloadStatus,
-			}
-		}
+	return DownloadFileWithMirrors([]URI{uri}, filePath, sha, fileN, total, downloadStatus)
+}
 
-		if strings.HasPrefix(url, OllamaPrefix) {
-			url = strings.TrimPrefix(url, OllamaPrefix)
-			return oci.OllamaFetchModel(url, filePath, progressStatus)
-		}
+// DownloadFileWithMirrors downloads the file from the first of uris, falling back to the following ones,
+// which are mirrors of the same file: all of them are verified against the same sha.
+// Transient failures are retried with exponential backoff, rotating across the mirrors.
+func DownloadFileWithMirrors(uris []URI, filePath, sha string, fileN, total int, downloadStatus func(string, string, string, float64)) error {
+	if len(uris) == 0 {
+		return fmt.Errorf("no URI to download file %q from", filePath)
+	}
 
-		url = strings.TrimPrefix(url, OCIPrefix)
-		img, err := oci.GetImage(url, "", nil, nil)
-		if err != nil {
-			return fmt.Errorf("failed to get image %q: %v", url, err)
+	if uris[0].LooksLikeOCI() {
+		var err error
+		for _, uri := range uris {
+			if err = uri.downloadOCI(filePath, sha, fileN, total, downloadStatus); err == nil {
+				return nil
+			}
+			log.Warn().Err(err).Msgf("Failed to download %q from %q", filePath, uri)
 		}
-
-		return oci.ExtractOCIImage(img, filepath.Dir(filePath))
+		return err
 	}
 
 	// Check if the file already exists
@@ -233,6 +242,9 @@ func (uri URI) DownloadFile(filePath, sha string, fileN, total int, downloadStat
 			if calculatedSHA == sha {
 				// SHA matches, skip downloading
 				log.Debug().Msgf("File %q already exists and matches the SHA. Skipping download", filePath)
+				if err := storeBlob(filePath, sha); err != nil {
+					log.Warn().Err(err).Msgf("Failed to store %q as a blob", filePath)
+				}
 				return nil
 			}
 			// SHA doesn't match, delete the file and download again
@@ -252,17 +264,122 @@ func (uri URI) DownloadFile(filePath, sha string, fileN, total int, downloadStat
 		return fmt.Errorf("failed to check file %q existence: %v", filePath, err)
 	}
 
+	// the same content downloaded for another model is shared instead of downloaded again
+	if linkBlob(filePath, sha) {
+		log.Info().Msgf("File %q is already stored as blob %s, sharing it", filePath, sha)
+		return extractIfArchive(filePath)
+	}
+
+	var errs error
+	failed := make([]bool, len(uris))
+	backoff := downloadBackoff
+	for attempt := 0; attempt < downloadAttempts; attempt++ {
+		if attempt > 0 {
+			log.Warn().Msgf("Failed to download %q, retrying in %s", filePath, backoff)
+			time.Sleep(backoff)
+			backoff = min(2*backoff, maxDownloadBackoff)
+		}
+
+		retry := false
+		for i, uri := range uris {
+			if failed[i] {
+				continue
+			}
+
+			var err error
+			if uri.LooksLikeTorrent() {
+				err = uri.downloadTorrent(filePath, sha, fileN, total, downloadStatus)
+			} else {
+				err = uri.download(filePath, sha, fileN, total, downloadStatus)
+			}
+			if err == nil {
+				if err := storeBlob(filePath, sha); err != nil {
+					log.Warn().Err(err).Msgf("Failed to store %q as a blob", filePath)
+				}
+				return extractIfArchive(filePath)
+			}
+
+			var de *downloadError
+			if !errors.As(err, &de) {
+				// not a failure of the mirror, the others won't do better
+				return err
+			}
+			log.Warn().Err(err).Msgf("Failed to download %q from %q", filePath, uri)
+			errs = errors.Join(errs, err)
+			if de.transient {
+				retry = true
+			} else {
+				failed[i] = true
+			}
+		}
+
+		if !retry {
+			break
+		}
+	}
+
+	return fmt.Errorf("failed to download file %q: %w", filePath, errs)
+}
+
+func (uri URI) downloadOCI(filePath, sha string, fileN, total int, downloadStatus func(string, string, string, float64)) error {
+	url := uri.ResolveURL()
+	progressStatus := func(desc ocispec.Descriptor) io.Writer {
+		return &progressWriter{
+			fileName:       filePath,
+			total:          desc.Size,
+			hash:           sha256.New(),
+			fileNo:         fileN,
+			totalFiles:     total,
+			downloadStatus: downloadStatus,
+		}
+	}
+
+	if strings.HasPrefix(url, OllamaPrefix) {
+		url = strings.TrimPrefix(url, OllamaPrefix)
+		return oci.OllamaFetchModel(url, filePath, progressStatus)
+	}
+
+	url = strings.TrimPrefix(url, OCIPrefix)
+	img, err := oci.GetImage(url, "", nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get image %q: %v", url, err)
+	}
+
+	return oci.ExtractOCIImage(img, filepath.Dir(filePath))
+}
+
+// downloadError is a failure of the remote end, transient ones are worth a retry
+type downloadError struct {
+	err       error
+	transient bool
+}
+
+func (e *downloadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *downloadError) Unwrap() error {
+	return e.err
+}
+
+// download fetches the file from the uri and verifies its SHA
+func (uri URI) download(filePath, sha string, fileN, total int, downloadStatus func(string, string, string, float64)) error {
+	url := uri.ResolveURL()
 	log.Info().Msgf("Downloading %q", url)
 
 	// Download file
 	resp, err := http.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to download file %q: %v", filePath, err)
+		return &downloadError{err: fmt.Errorf("failed to download file %q: %v", filePath, err), transient: true}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("failed to download url %q, invalid status code %d", url, resp.StatusCode)
+		return &downloadError{
+			err: fmt.Errorf("failed to download url %q, invalid status code %d", url, resp.StatusCode),
+			// server errors and rate limits are usually temporary
+			transient: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout,
+		}
 	}
 
 	// Create parent directory
@@ -297,26 +414,32 @@ func (uri URI) DownloadFile(filePath, sha string, fileN, total int, downloadStat
 	}
 	_, err = io.Copy(io.MultiWriter(outFile, progress), resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to write file %q: %v", filePath, err)
-	}
-
-	err = os.Rename(tmpFilePath, filePath)
-	if err != nil {
-		return fmt.Errorf("failed to rename temporary file %s -> %s: %v", tmpFilePath, filePath, err)
+		removePartialFile(tmpFilePath)
+		return &downloadError{err: fmt.Errorf("failed to write file %q: %v", filePath, err), transient: true}
 	}
 
 	if sha != "" {
 		// Verify SHA
 		calculatedSHA := fmt.Sprintf("%x", progress.hash.Sum(nil))
 		if calculatedSHA != sha {
+			removePartialFile(tmpFilePath)
 			log.Debug().Msgf("SHA mismatch for file %q ( calculated: %s != metadata: %s )", filePath, calculatedSHA, sha)
-			return fmt.Errorf("SHA mismatch for file %q ( calculated: %s != metadata: %s )", filePath, calculatedSHA, sha)
+			return &downloadError{err: fmt.Errorf("SHA mismatch for file %q ( calculated: %s != metadata: %s )", filePath, calculatedSHA, sha)}
 		}
 	} else {
 		log.Debug().Msgf("SHA missing for %q. Skipping validation", filePath)
 	}
 
+	err = os.Rename(tmpFilePath, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to rename temporary file %s -> %s: %v", tmpFilePath, filePath, err)
+	}
+
 	log.Info().Msgf("File %q downloaded and verified", filePath)
+	return nil
+}
+
+func extractIfArchive(filePath string) error {
 	if utils.IsArchive(filePath) {
 		basePath := filepath.Dir(filePath)
 		log.Info().Msgf("File %q is an archive, uncompressing to %s", filePath, basePath)
@@ -1,6 +1,14 @@
 package downloader_test
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
 	. "github.com/mudler/LocalAI/pkg/downloader"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -37,4 +45,98 @@ var _ = Describe("Gallery API tests", func() {
 			).ToNot(HaveOccurred())
 		})
 	})
+
+	Context("torrents", func() {
+		It("recognizes magnet links and .torrent files", func() {
+			Expect(URI("magnet:?xt=urn:btih:c9e15763f722f23e98a29decdfae341b98d53056&dn=model.gguf").LooksLikeTorrent()).To(BeTrue())
+			Expect(URI("https://example.com/model.gguf.torrent").LooksLikeTorrent()).To(BeTrue())
+			Expect(URI("https://example.com/model.gguf").LooksLikeTorrent()).To(BeFalse())
+			Expect(URI("magnet:?xt=urn:btih:c9e15763f722f23e98a29decdfae341b98d53056").LooksLikeURL()).To(BeTrue())
+		})
+		It("names the file of a magnet link after its display name", func() {
+			name, err := URI("magnet:?xt=urn:btih:c9e15763f722f23e98a29decdfae341b98d53056&dn=my%20model.gguf&ws=https%3A%2F%2Fexample.com%2Fmodel.gguf").FilenameFromUrl()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(Equal("my model.gguf"))
+		})
+	})
+
+	Context("DownloadFileWithMirrors", func() {
+		content := []byte("model weights")
+		sha := fmt.Sprintf("%x", sha256.Sum256(content))
+
+		var dir string
+		var mirror *httptest.Server
+		noStatus := func(string, string, string, float64) {}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "downloader")
+			Expect(err).ToNot(HaveOccurred())
+			mirror = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(content)
+			}))
+		})
+
+		AfterEach(func() {
+			mirror.Close()
+			os.RemoveAll(dir)
+		})
+
+		It("falls back to a mirror when the file is not found", func() {
+			missing := httptest.NewServer(http.NotFoundHandler())
+			defer missing.Close()
+
+			filePath := filepath.Join(dir, "model.bin")
+			Expect(DownloadFileWithMirrors([]URI{URI(missing.URL), URI(mirror.URL)}, filePath, sha, 0, 1, noStatus)).To(Succeed())
+			Expect(os.ReadFile(filePath)).To(Equal(content))
+		})
+
+		It("skips mirrors serving a file with a different SHA", func() {
+			corrupted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("corrupted"))
+			}))
+			defer corrupted.Close()
+
+			filePath := filepath.Join(dir, "model.bin")
+			Expect(DownloadFileWithMirrors([]URI{URI(corrupted.URL), URI(mirror.URL)}, filePath, sha, 0, 1, noStatus)).To(Succeed())
+			Expect(os.ReadFile(filePath)).To(Equal(content))
+		})
+
+		It("retries transient failures", func() {
+			var calls atomic.Int32
+			flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write(content)
+			}))
+			defer flaky.Close()
+
+			filePath := filepath.Join(dir, "model.bin")
+			Expect(URI(flaky.URL).DownloadFile(filePath, sha, 0, 1, noStatus)).To(Succeed())
+			Expect(calls.Load()).To(Equal(int32(2)))
+			Expect(os.ReadFile(filePath)).To(Equal(content))
+		})
+
+		It("falls back to the mirrors when the torrent can't be downloaded", func() {
+			// without aria2c on the PATH
+			DeferCleanup(os.Setenv, "PATH", os.Getenv("PATH"))
+			os.Setenv("PATH", "")
+
+			filePath := filepath.Join(dir, "model.bin")
+			magnet := URI("magnet:?xt=urn:btih:c9e15763f722f23e98a29decdfae341b98d53056&dn=model.bin")
+			Expect(DownloadFileWithMirrors([]URI{magnet, URI(mirror.URL)}, filePath, sha, 0, 1, noStatus)).To(Succeed())
+			Expect(os.ReadFile(filePath)).To(Equal(content))
+		})
+
+		It("fails when no mirror serves the file", func() {
+			missing := httptest.NewServer(http.NotFoundHandler())
+			defer missing.Close()
+
+			filePath := filepath.Join(dir, "model.bin")
+			Expect(DownloadFileWithMirrors([]URI{URI(missing.URL), URI(missing.URL + "/other")}, filePath, sha, 0, 1, noStatus)).ToNot(Succeed())
+			Expect(filePath).ToNot(BeAnExistingFile())
+		})
+	})
 })
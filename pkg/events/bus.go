@@ -0,0 +1,106 @@
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The types of the events, grouped by the prefix before the dot
+const (
+	ModelLoaded   = "model.loaded"
+	ModelUnloaded = "model.unloaded"
+	ModelHung     = "model.hung"
+
+	// WatchdogIdle and WatchdogBusy are sent when the watchdog stops the backend of a model
+	WatchdogIdle = "watchdog.idle"
+	WatchdogBusy = "watchdog.busy"
+
+	JobStarted   = "job.started"
+	JobCompleted = "job.completed"
+	JobFailed    = "job.failed"
+
+	ConfigReloaded = "config.reloaded"
+
+	P2PNodeJoined = "p2p.node_joined"
+	P2PNodeLeft   = "p2p.node_left"
+)
+
+// subscriberBuffer is the number of events kept for a subscriber not reading them, the following ones are dropped
+const subscriberBuffer = 64
+
+// Event is a change of the state of LocalAI
+type Event struct {
+	Type string         `json:"type"`
+	Time time.Time      `json:"time"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Matches returns whether the event is of one of the types, or of one of their groups (e.g. model). All the
+// events match without types
+func (e Event) Matches(types []string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	group, _, _ := strings.Cut(e.Type, ".")
+	for _, t := range types {
+		if t == e.Type || t == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus broadcasts the events to its subscribers. Publishing never blocks: the events are dropped for the
+// subscribers not reading them fast enough
+type Bus struct {
+	sync.Mutex
+	subscribers map[chan Event]struct{}
+
+	now func() time.Time
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: map[chan Event]struct{}{},
+		now:         time.Now,
+	}
+}
+
+// Publish sends the event to the subscribers. It's a no-op on a nil bus
+func (b *Bus) Publish(eventType string, data map[string]any) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	e := Event{Type: eventType, Time: b.now(), Data: data}
+	for s := range b.subscribers {
+		select {
+		case s <- e:
+		default:
+			log.Debug().Str("type", eventType).Msg("event dropped for a slow subscriber")
+		}
+	}
+}
+
+// Subscribe returns the channel receiving the events published from now on, and the function unsubscribing,
+// which closes it
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	s := make(chan Event, subscriberBuffer)
+	b.Lock()
+	b.subscribers[s] = struct{}{}
+	b.Unlock()
+
+	var once sync.Once
+	return s, func() {
+		once.Do(func() {
+			b.Lock()
+			defer b.Unlock()
+			delete(b.subscribers, s)
+			close(s)
+		})
+	}
+}
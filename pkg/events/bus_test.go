@@ -0,0 +1,55 @@
+package events_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/events"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bus", func() {
+	It("broadcasts the events to the subscribers", func() {
+		bus := NewBus()
+		first, unsubscribeFirst := bus.Subscribe()
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := bus.Subscribe()
+
+		bus.Publish(ModelLoaded, map[string]any{"model": "phi-2"})
+		for _, s := range []<-chan Event{first, second} {
+			e := <-s
+			Expect(e.Type).To(Equal(ModelLoaded))
+			Expect(e.Data).To(HaveKeyWithValue("model", "phi-2"))
+			Expect(e.Time).ToNot(BeZero())
+		}
+
+		unsubscribeSecond()
+		Eventually(second).Should(BeClosed())
+		bus.Publish(ModelUnloaded, nil)
+		Expect((<-first).Type).To(Equal(ModelUnloaded))
+	})
+
+	It("drops the events of the subscribers not reading them", func() {
+		bus := NewBus()
+		s, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+
+		for i := 0; i < 100; i++ {
+			bus.Publish(JobStarted, nil)
+		}
+		Expect(len(s)).To(Equal(cap(s)))
+	})
+
+	It("ignores the events of a nil bus", func() {
+		var bus *Bus
+		Expect(func() { bus.Publish(ConfigReloaded, nil) }).ToNot(Panic())
+	})
+
+	It("matches the events by type and by group", func() {
+		e := Event{Type: WatchdogIdle}
+		Expect(e.Matches(nil)).To(BeTrue())
+		Expect(e.Matches([]string{"watchdog"})).To(BeTrue())
+		Expect(e.Matches([]string{"model", WatchdogIdle})).To(BeTrue())
+		Expect(e.Matches([]string{WatchdogBusy})).To(BeFalse())
+		Expect(e.Matches([]string{"watch"})).To(BeFalse())
+	})
+})
@@ -0,0 +1,29 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var choiceEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// ChoiceGrammar returns the grammar constraining the output to exactly one of the choices
+func ChoiceGrammar(choices []string) (string, error) {
+	if len(choices) == 0 {
+		return "", errors.New("guided_choice requires at least one choice")
+	}
+	seen := map[string]bool{}
+	alternatives := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		if choice == "" {
+			return "", errors.New("guided_choice can't contain empty choices")
+		}
+		if seen[choice] {
+			return "", fmt.Errorf("guided_choice contains %q more than once", choice)
+		}
+		seen[choice] = true
+		alternatives = append(alternatives, `"`+choiceEscaper.Replace(choice)+`"`)
+	}
+	return "root ::= (" + strings.Join(alternatives, " | ") + ")", nil
+}
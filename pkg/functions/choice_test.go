@@ -0,0 +1,30 @@
+package functions_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Choice grammar", func() {
+	It("allows exactly one of the choices", func() {
+		grammar, err := ChoiceGrammar([]string{"yes", "no", "maybe"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(Equal(`root ::= ("yes" | "no" | "maybe")`))
+	})
+
+	It("escapes the choices", func() {
+		grammar, err := ChoiceGrammar([]string{`say "hi"`, "a\\b", "two\nlines"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(Equal(`root ::= ("say \"hi\"" | "a\\b" | "two\nlines")`))
+	})
+
+	It("rejects the invalid choices", func() {
+		_, err := ChoiceGrammar(nil)
+		Expect(err).To(HaveOccurred())
+		_, err = ChoiceGrammar([]string{"yes", ""})
+		Expect(err).To(HaveOccurred())
+		_, err = ChoiceGrammar([]string{"yes", "no", "yes"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,126 @@
+package functions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GrammarsDir is the directory, in the configs one, holding the custom grammars as <name>.gbnf files
+const GrammarsDir = "grammars"
+
+const (
+	SQLBNF = `root ::= "SELECT " columns " FROM " ident where? order? limit? ";"?
+
+columns ::= "*" | column ("," ws column)*
+column ::= ident | func "(" ("*" | ident) ")"
+func ::= "COUNT" | "SUM" | "AVG" | "MIN" | "MAX"
+
+where ::= " WHERE " condition ((" AND " | " OR ") condition)*
+condition ::= ident ws op ws value
+op ::= "=" | "!=" | "<>" | "<" | "<=" | ">" | ">=" | "LIKE"
+
+order ::= " ORDER BY " ident (" ASC" | " DESC")? ("," ws ident (" ASC" | " DESC")?)*
+limit ::= " LIMIT " [0-9]+
+
+value ::= number | string | "NULL" | "TRUE" | "FALSE"
+number ::= "-"? [0-9]+ ("." [0-9]+)?
+string ::= "'" ([^'] | "''")* "'"
+ident ::= [a-zA-Z_] [a-zA-Z0-9_]* ("." [a-zA-Z_] [a-zA-Z0-9_]*)?
+
+ws ::= " "?`
+
+	YAMLBNF = `root ::= pair+
+
+pair ::= key ":" (" " scalar "\n" | "\n" item+)
+item ::= "  - " scalar "\n"
+
+key ::= [a-zA-Z_] [a-zA-Z0-9_-]*
+scalar ::= [^ \n] [^\n]*`
+
+	CSVRowBNF = `root ::= field ("," field)* "\n"
+
+field ::= quoted | [^,"\n]*
+quoted ::= "\"" ([^"] | "\"\"")* "\""`
+)
+
+// BuiltinGrammars are the grammars shipped with LocalAI, requests can reference them by name
+var BuiltinGrammars = map[string]string{
+	"json":    JSONBNF,
+	"sql":     SQLBNF,
+	"yaml":    YAMLBNF,
+	"csv_row": CSVRowBNF,
+}
+
+// Grammar is a named grammar of the library
+type Grammar struct {
+	Name    string `json:"name"`
+	Grammar string `json:"grammar"`
+	BuiltIn bool   `json:"builtin"`
+}
+
+var grammarNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// IsGrammarName returns true if the grammar of a request is a reference to the library rather than a GBNF grammar
+func IsGrammarName(grammar string) bool {
+	return grammarNameRe.MatchString(grammar)
+}
+
+// ListGrammars returns the built-in grammars and the custom ones in the configs directory, sorted by name.
+// A custom grammar replaces the built-in one with the same name.
+func ListGrammars(configsDir string) ([]Grammar, error) {
+	grammars := map[string]Grammar{}
+	for name, grammar := range BuiltinGrammars {
+		grammars[name] = Grammar{Name: name, Grammar: grammar, BuiltIn: true}
+	}
+
+	if configsDir != "" {
+		files, err := filepath.Glob(filepath.Join(configsDir, GrammarsDir, "*.gbnf"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".gbnf")
+			if !IsGrammarName(name) {
+				continue
+			}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading grammar %q: %w", name, err)
+			}
+			grammars[name] = Grammar{Name: name, Grammar: string(content)}
+		}
+	}
+
+	list := make([]Grammar, 0, len(grammars))
+	for _, g := range grammars {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// ResolveGrammar returns the GBNF grammar registered under name, custom grammars first
+func ResolveGrammar(configsDir, name string) (string, error) {
+	if !IsGrammarName(name) {
+		return "", fmt.Errorf("invalid grammar name %q", name)
+	}
+
+	if configsDir != "" {
+		content, err := os.ReadFile(filepath.Join(configsDir, GrammarsDir, name+".gbnf"))
+		if err == nil {
+			return string(content), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed reading grammar %q: %w", name, err)
+		}
+	}
+
+	if grammar, exists := BuiltinGrammars[name]; exists {
+		return grammar, nil
+	}
+	return "", fmt.Errorf("unknown grammar %q", name)
+}
@@ -0,0 +1,63 @@
+package functions_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Grammar library", func() {
+	var configsDir string
+
+	BeforeEach(func() {
+		configsDir = GinkgoT().TempDir()
+		Expect(os.MkdirAll(filepath.Join(configsDir, GrammarsDir), 0750)).To(Succeed())
+	})
+
+	It("resolves the built-in grammars", func() {
+		grammar, err := ResolveGrammar(configsDir, "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(Equal(JSONBNF))
+	})
+
+	It("resolves the custom grammars, which take precedence over the built-in ones", func() {
+		Expect(os.WriteFile(filepath.Join(configsDir, GrammarsDir, "yesno.gbnf"), []byte(`root ::= ("yes" | "no")`), 0600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(configsDir, GrammarsDir, "json.gbnf"), []byte(`root ::= "{}"`), 0600)).To(Succeed())
+
+		grammar, err := ResolveGrammar(configsDir, "yesno")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(Equal(`root ::= ("yes" | "no")`))
+
+		grammar, err = ResolveGrammar(configsDir, "json")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(grammar).To(Equal(`root ::= "{}"`))
+	})
+
+	It("fails on unknown grammars", func() {
+		_, err := ResolveGrammar(configsDir, "unknown")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("tells grammar names from GBNF grammars", func() {
+		Expect(IsGrammarName("csv_row")).To(BeTrue())
+		Expect(IsGrammarName(`root ::= ("yes" | "no")`)).To(BeFalse())
+		Expect(IsGrammarName("")).To(BeFalse())
+	})
+
+	It("lists the built-in and the custom grammars sorted by name", func() {
+		Expect(os.WriteFile(filepath.Join(configsDir, GrammarsDir, "yesno.gbnf"), []byte(`root ::= ("yes" | "no")`), 0600)).To(Succeed())
+
+		grammars, err := ListGrammars(configsDir)
+		Expect(err).ToNot(HaveOccurred())
+
+		names := []string{}
+		for _, g := range grammars {
+			names = append(names, g.Name)
+			Expect(g.BuiltIn).To(Equal(g.Name != "yesno"))
+		}
+		Expect(names).To(Equal([]string{"csv_row", "json", "sql", "yaml", "yesno"}))
+	})
+})
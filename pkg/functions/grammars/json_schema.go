@@ -12,6 +12,8 @@ import (
 type JSONSchemaConverter struct {
 	propOrder map[string]int
 	rules     Rules
+	// refs are the references being visited, a reference to one of them is recursive
+	refs map[string]bool
 }
 
 func NewJSONSchemaConverter(propOrder string) *JSONSchemaConverter {
@@ -27,6 +29,7 @@ func NewJSONSchemaConverter(propOrder string) *JSONSchemaConverter {
 	return &JSONSchemaConverter{
 		propOrder: propOrderMap,
 		rules:     rules,
+		refs:      make(map[string]bool),
 	}
 }
 
@@ -59,15 +62,34 @@ func (sc *JSONSchemaConverter) addRule(name, rule string) string {
 }
 
 func (sc *JSONSchemaConverter) visit(schema map[string]interface{}, name string, rootSchema map[string]interface{}) (string, error) {
-	st, existType := schema["type"]
-	var schemaType string
-	if existType {
-		schemaType = st.(string)
-	}
 	ruleName := name
 	if name == "" {
 		ruleName = "root"
 	}
+	var schemaType string
+	switch st := schema["type"].(type) {
+	case string:
+		schemaType = st
+	case []interface{}:
+		// a list of types is the alternative of the schema with each type
+		var alternatives []string
+		for i, t := range st {
+			altSchema := make(map[string]interface{}, len(schema))
+			for k, v := range schema {
+				altSchema[k] = v
+			}
+			altSchema["type"] = t
+			alternative, err := sc.visit(altSchema, fmt.Sprintf("%s-%d", ruleName, i), rootSchema)
+			if err != nil {
+				return "", err
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		return sc.addRule(ruleName, strings.Join(alternatives, " | ")), nil
+	case nil:
+	default:
+		return "", fmt.Errorf("invalid type: %v", st)
+	}
 	_, oneOfExists := schema["oneOf"]
 	_, anyOfExists := schema["anyOf"]
 	if oneOfExists || anyOfExists {
@@ -96,6 +118,11 @@ func (sc *JSONSchemaConverter) visit(schema map[string]interface{}, name string,
 		rule := strings.Join(alternatives, " | ")
 		return sc.addRule(ruleName, rule), nil
 	} else if ref, exists := schema["$ref"].(string); exists {
+		if sc.refs[ref] {
+			return "", fmt.Errorf("recursive reference not supported: %s", ref)
+		}
+		sc.refs[ref] = true
+		defer delete(sc.refs, ref)
 		referencedSchema, err := sc.resolveReference(ref, rootSchema)
 		if err != nil {
 			return "", err
@@ -183,12 +210,15 @@ func (sc *JSONSchemaConverter) visit(schema map[string]interface{}, name string,
 	}
 }
 func (sc *JSONSchemaConverter) resolveReference(ref string, rootSchema map[string]interface{}) (map[string]interface{}, error) {
-	if !strings.HasPrefix(ref, "#/$defs/") {
+	defsKey := "$defs"
+	if strings.HasPrefix(ref, "#/definitions/") {
+		defsKey = "definitions"
+	} else if !strings.HasPrefix(ref, "#/$defs/") {
 		return nil, fmt.Errorf("invalid reference format: %s", ref)
 	}
 
-	defKey := strings.TrimPrefix(ref, "#/$defs/")
-	definitions, exists := rootSchema["$defs"].(map[string]interface{})
+	defKey := strings.TrimPrefix(ref, "#/"+defsKey+"/")
+	definitions, exists := rootSchema[defsKey].(map[string]interface{})
 	if !exists {
 		return nil, fmt.Errorf("no definitions found in the schema: %s", rootSchema)
 	}
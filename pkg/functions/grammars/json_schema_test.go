@@ -442,5 +442,25 @@ realvalue
 				}
 			}
 		})
+
+		It("generates the alternatives of a list of types", func() {
+			grammar, err := NewJSONSchemaConverter("").GrammarFromBytes([]byte(`{
+				"type": "object",
+				"properties": {"city": {"$ref": "#/definitions/city"}},
+				"definitions": {"city": {"type": ["string", "null"]}}
+			}`))
+			Expect(err).To(BeNil())
+			Expect(grammar).To(ContainSubstring(`root ::= "{" space "\"city\"" space ":" space root-city`))
+			Expect(grammar).To(ContainSubstring(`root-city ::= string | null`))
+			Expect(grammar).To(ContainSubstring(`null ::= "null" space`))
+		})
+
+		It("rejects the recursive references", func() {
+			_, err := NewJSONSchemaConverter("").GrammarFromBytes([]byte(`{
+				"$ref": "#/$defs/node",
+				"$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}}}}
+			}`))
+			Expect(err).To(MatchError(ContainSubstring("recursive reference")))
+		})
 	})
 })
@@ -13,21 +13,24 @@ func Provide(addr string, llm LLM) {
 	embeds[addr] = &embedBackend{s: &server{llm: llm}}
 }
 
-func NewClient(address string, parallel bool, wd WatchDog, enableWatchDog bool) Backend {
+// NewClient returns a client for the backend at the address. The credentials can be nil,
+// for the backends started by LocalAI
+func NewClient(address string, parallel bool, wd WatchDog, enableWatchDog bool, credentials *Credentials) Backend {
 	if bc, ok := embeds[address]; ok {
 		return bc
 	}
-	return buildClient(address, parallel, wd, enableWatchDog)
+	return buildClient(address, parallel, wd, enableWatchDog, credentials)
 }
 
-func buildClient(address string, parallel bool, wd WatchDog, enableWatchDog bool) Backend {
+func buildClient(address string, parallel bool, wd WatchDog, enableWatchDog bool, credentials *Credentials) Backend {
 	if !enableWatchDog {
 		wd = nil
 	}
 	return &Client{
-		address:  address,
-		parallel: parallel,
-		wd:       wd,
+		address:     address,
+		parallel:    parallel,
+		wd:          wd,
+		credentials: credentials,
 	}
 }
 
@@ -37,9 +40,10 @@ type Backend interface {
 	Embeddings(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.EmbeddingResult, error)
 	Predict(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.Reply, error)
 	LoadModel(ctx context.Context, in *pb.ModelOptions, opts ...grpc.CallOption) (*pb.Result, error)
-	PredictStream(ctx context.Context, in *pb.PredictOptions, f func(s []byte), opts ...grpc.CallOption) error
+	PredictStream(ctx context.Context, in *pb.PredictOptions, f func(reply *pb.Reply), opts ...grpc.CallOption) error
 	GenerateImage(ctx context.Context, in *pb.GenerateImageRequest, opts ...grpc.CallOption) (*pb.Result, error)
 	TTS(ctx context.Context, in *pb.TTSRequest, opts ...grpc.CallOption) (*pb.Result, error)
+	TTSStream(ctx context.Context, in *pb.TTSRequest, f func(chunk *pb.TTSChunk), opts ...grpc.CallOption) error
 	SoundGeneration(ctx context.Context, in *pb.SoundGenerationRequest, opts ...grpc.CallOption) (*pb.Result, error)
 	AudioTranscription(ctx context.Context, in *pb.TranscriptRequest, opts ...grpc.CallOption) (*pb.TranscriptResult, error)
 	TokenizeString(ctx context.Context, in *pb.PredictOptions, opts ...grpc.CallOption) (*pb.TokenizationResponse, error)
@@ -51,4 +55,9 @@ type Backend interface {
 	StoresFind(ctx context.Context, in *pb.StoresFindOptions, opts ...grpc.CallOption) (*pb.StoresFindResult, error)
 
 	Rerank(ctx context.Context, in *pb.RerankRequest, opts ...grpc.CallOption) (*pb.RerankResult, error)
+
+	Classify(ctx context.Context, in *pb.ClassifyRequest, opts ...grpc.CallOption) (*pb.ClassifyResult, error)
+
+	SaveState(ctx context.Context, in *pb.StateRequest, opts ...grpc.CallOption) (*pb.Result, error)
+	LoadState(ctx context.Context, in *pb.StateRequest, opts ...grpc.CallOption) (*pb.Result, error)
 }
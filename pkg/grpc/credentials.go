@@ -0,0 +1,75 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Credentials are used to authenticate with the backends not started by LocalAI,
+// and to verify their identity
+type Credentials struct {
+	// Token is sent as a bearer token in the authorization metadata of every call
+	Token string
+	// CACert is the path of the CA certificate verifying the backend certificate. When empty, the system CAs are used
+	CACert string
+	// ClientCert and ClientKey are the paths of the certificate and key presented to the backend (mTLS)
+	ClientCert, ClientKey string
+}
+
+// TLS returns true if the connection to the backend has to be encrypted
+func (c *Credentials) TLS() bool {
+	return c.CACert != "" || c.ClientCert != ""
+}
+
+func (c *Credentials) dialOptions() ([]grpc.DialOption, error) {
+	transport := insecure.NewCredentials()
+	if c.TLS() {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if c.CACert != "" {
+			pem, err := os.ReadFile(c.CACert)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading the backend CA certificate: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no valid certificate found in %s", c.CACert)
+			}
+		}
+		if c.ClientCert != "" {
+			cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed loading the client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport = credentials.NewTLS(tlsConfig)
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(transport)}
+	if c.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken{token: c.Token, tls: c.TLS()}))
+	}
+	return opts, nil
+}
+
+type bearerToken struct {
+	token string
+	tls   bool
+}
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+// RequireTransportSecurity is false when TLS is not configured, to allow sending the token
+// on trusted networks
+func (t bearerToken) RequireTransportSecurity() bool {
+	return t.tls
+}
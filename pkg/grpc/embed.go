@@ -10,6 +10,7 @@ import (
 
 var _ Backend = new(embedBackend)
 var _ pb.Backend_PredictStreamServer = new(embedBackendServerStream)
+var _ pb.Backend_TTSStreamServer = new(embedTTSServerStream)
 
 type embedBackend struct {
 	s *server
@@ -35,7 +36,7 @@ func (e *embedBackend) LoadModel(ctx context.Context, in *pb.ModelOptions, opts
 	return e.s.LoadModel(ctx, in)
 }
 
-func (e *embedBackend) PredictStream(ctx context.Context, in *pb.PredictOptions, f func(s []byte), opts ...grpc.CallOption) error {
+func (e *embedBackend) PredictStream(ctx context.Context, in *pb.PredictOptions, f func(reply *pb.Reply), opts ...grpc.CallOption) error {
 	bs := &embedBackendServerStream{
 		ctx: ctx,
 		fn:  f,
@@ -51,6 +52,10 @@ func (e *embedBackend) TTS(ctx context.Context, in *pb.TTSRequest, opts ...grpc.
 	return e.s.TTS(ctx, in)
 }
 
+func (e *embedBackend) TTSStream(ctx context.Context, in *pb.TTSRequest, f func(chunk *pb.TTSChunk), opts ...grpc.CallOption) error {
+	return e.s.TTSStream(in, &embedTTSServerStream{embedBackendServerStream{ctx: ctx}, f})
+}
+
 func (e *embedBackend) SoundGeneration(ctx context.Context, in *pb.SoundGenerationRequest, opts ...grpc.CallOption) (*pb.Result, error) {
 	return e.s.SoundGeneration(ctx, in)
 }
@@ -87,13 +92,25 @@ func (e *embedBackend) Rerank(ctx context.Context, in *pb.RerankRequest, opts ..
 	return e.s.Rerank(ctx, in)
 }
 
+func (e *embedBackend) Classify(ctx context.Context, in *pb.ClassifyRequest, opts ...grpc.CallOption) (*pb.ClassifyResult, error) {
+	return e.s.Classify(ctx, in)
+}
+
+func (e *embedBackend) SaveState(ctx context.Context, in *pb.StateRequest, opts ...grpc.CallOption) (*pb.Result, error) {
+	return e.s.SaveState(ctx, in)
+}
+
+func (e *embedBackend) LoadState(ctx context.Context, in *pb.StateRequest, opts ...grpc.CallOption) (*pb.Result, error) {
+	return e.s.LoadState(ctx, in)
+}
+
 type embedBackendServerStream struct {
 	ctx context.Context
-	fn  func(s []byte)
+	fn  func(reply *pb.Reply)
 }
 
 func (e *embedBackendServerStream) Send(reply *pb.Reply) error {
-	e.fn(reply.GetMessage())
+	e.fn(reply)
 	return nil
 }
 
@@ -122,3 +139,21 @@ func (e *embedBackendServerStream) SendMsg(m any) error {
 func (e *embedBackendServerStream) RecvMsg(m any) error {
 	return nil
 }
+
+// embedTTSServerStream passes the chunks of the TTS stream of an embedded backend to its function
+type embedTTSServerStream struct {
+	embedBackendServerStream
+	fn func(chunk *pb.TTSChunk)
+}
+
+func (e *embedTTSServerStream) Send(chunk *pb.TTSChunk) error {
+	e.fn(chunk)
+	return nil
+}
+
+func (e *embedTTSServerStream) SendMsg(m any) error {
+	if x, ok := m.(*pb.TTSChunk); ok {
+		return e.Send(x)
+	}
+	return nil
+}
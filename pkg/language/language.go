@@ -0,0 +1,168 @@
+package language
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// minLetters is the minimum number of letters needed to detect the language of a text
+const minLetters = 20
+
+var names = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"it": "Italian",
+	"pt": "Portuguese",
+	"nl": "Dutch",
+	"ru": "Russian",
+	"el": "Greek",
+	"he": "Hebrew",
+	"ar": "Arabic",
+	"hi": "Hindi",
+	"th": "Thai",
+	"zh": "Chinese",
+	"ja": "Japanese",
+	"ko": "Korean",
+}
+
+// stopwords are frequent words telling apart the languages written in the latin script
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "you", "with", "for", "this", "was", "be", "have", "not", "on", "what", "can"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "en", "es", "por", "para", "con", "una", "un", "del", "se", "no", "su", "como", "está"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "que", "en", "un", "une", "du", "pour", "pas", "dans", "je", "vous", "il", "sur", "ce"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "sie", "ich", "es", "auf", "für", "von", "dem", "sich", "auch"},
+	"it": {"il", "la", "di", "che", "e", "è", "un", "una", "per", "non", "sono", "del", "della", "con", "gli", "le", "si", "ma", "anche", "questo"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "é", "um", "uma", "para", "com", "não", "do", "da", "em", "no", "na", "se", "por"},
+	"nl": {"de", "het", "een", "en", "is", "van", "dat", "niet", "ik", "je", "op", "te", "zijn", "met", "voor", "er", "maar", "ook", "wat", "die"},
+}
+
+var stopwordSets = func() map[string]map[string]bool {
+	sets := map[string]map[string]bool{}
+	for language, list := range stopwords {
+		sets[language] = map[string]bool{}
+		for _, w := range list {
+			sets[language][w] = true
+		}
+	}
+	return sets
+}()
+
+// scripts are the languages detected by their script alone
+var scripts = []struct {
+	table    *unicode.RangeTable
+	language string
+}{
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Hangul, "ko"},
+}
+
+var codeBlocks = regexp.MustCompile("(?s)```.*?```")
+
+// Name returns the English name of the language with the given ISO 639-1 code, or the code if it's unknown
+func Name(code string) string {
+	if name, ok := names[strings.ToLower(code)]; ok {
+		return name
+	}
+	return code
+}
+
+// Supported returns whether Detect can tell the language with the given ISO 639-1 code
+func Supported(code string) bool {
+	_, ok := names[strings.ToLower(code)]
+	return ok
+}
+
+// Detect returns the ISO 639-1 code of the language of text, and whether it could be told.
+// It's a lightweight detector, based on the script and on frequent words, meant to tell
+// when a model answers in the wrong language: short texts are not detected.
+func Detect(text string) (string, bool) {
+	// code does not tell the language of the answer
+	text = codeBlocks.ReplaceAllString(text, " ")
+
+	letters, latin, han, kana := 0, 0, 0, 0
+	counts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		default:
+			for _, s := range scripts {
+				if unicode.Is(s.table, r) {
+					counts[s.language]++
+					break
+				}
+			}
+		}
+	}
+
+	// CJK texts are shorter
+	if han+kana >= minLetters/4 && 2*(han+kana) > letters {
+		if kana > 0 {
+			return "ja", true
+		}
+		return "zh", true
+	}
+	if letters < minLetters {
+		return "", false
+	}
+	for language, count := range counts {
+		if 2*count > letters {
+			return language, true
+		}
+	}
+	if 2*latin <= letters {
+		return "", false
+	}
+	return detectLatin(text)
+}
+
+// detectLatin tells the language of a text in the latin script by its frequent words
+func detectLatin(text string) (string, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	scores := map[string]int{}
+	for language, set := range stopwordSets {
+		for _, w := range words {
+			if set[w] {
+				scores[language]++
+			}
+		}
+	}
+
+	best, bestScore, second := "", 0, 0
+	for language, score := range scores {
+		switch {
+		case score > bestScore || (score == bestScore && language < best):
+			if best != "" && bestScore > second {
+				second = bestScore
+			}
+			best, bestScore = language, score
+		case score > second:
+			second = score
+		}
+	}
+
+	// the best language must be clearly ahead of the others
+	if bestScore < 3 || 2*bestScore < 3*second {
+		return "", false
+	}
+	return best, true
+}
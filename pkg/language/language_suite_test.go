@@ -0,0 +1,13 @@
+package language_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLanguage(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Language test suite")
+}
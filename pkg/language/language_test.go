@@ -0,0 +1,46 @@
+package language_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/language"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Language", func() {
+	Context("Detect", func() {
+		DescribeTable("detects the language of the text",
+			func(text, expected string) {
+				language, ok := Detect(text)
+				Expect(ok).To(BeTrue())
+				Expect(language).To(Equal(expected))
+			},
+			Entry("English", "The quick brown fox jumps over the lazy dog, and this is what you can see in the garden.", "en"),
+			Entry("Italian", "Il gatto è sul tavolo e non vuole scendere, ma questo non è un problema per la famiglia.", "it"),
+			Entry("Spanish", "El perro está en la casa y no quiere salir, pero eso no es un problema para la familia.", "es"),
+			Entry("French", "Le chat est sur la table et il ne veut pas descendre, mais ce n'est pas un problème pour vous.", "fr"),
+			Entry("German", "Der Hund ist in dem Haus und will nicht raus, aber das ist auch kein Problem für die Familie.", "de"),
+			Entry("Russian", "Собака находится в доме и не хочет выходить на улицу, но это не проблема.", "ru"),
+			Entry("Chinese", "这是一个很长的中文句子，用来测试语言检测的功能是否正常。", "zh"),
+			Entry("Japanese", "これは日本語の文章です。言語検出が正しく動作するかテストします。", "ja"),
+		)
+
+		It("does not detect the language of short texts", func() {
+			_, ok := Detect("Ok!")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("ignores the code blocks", func() {
+			language, ok := Detect("Sure!\n```go\nfunc main() { fmt.Println(\"hello\") }\n```\nThis is the code you asked for, and it prints the string.")
+			Expect(ok).To(BeTrue())
+			Expect(language).To(Equal("en"))
+		})
+	})
+
+	Context("Name", func() {
+		It("returns the name of the known languages", func() {
+			Expect(Name("it")).To(Equal("Italian"))
+			Expect(Name("IT")).To(Equal("Italian"))
+			Expect(Name("tlh")).To(Equal("tlh"))
+		})
+	})
+})
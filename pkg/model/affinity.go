@@ -0,0 +1,117 @@
+package model
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/rs/zerolog/log"
+)
+
+// numaNodeAuto places a backend on the NUMA node running the fewest pinned backends, then with the most free memory
+const numaNodeAuto = "auto"
+
+// cpuPinning is where the process of a backend runs: its CPUs, and the NUMA node holding its memory
+type cpuPinning struct {
+	cpus []int
+	node int // -1 when the memory isn't bound to a node
+}
+
+// resolveCPUPinning returns where the backend of a model runs, from the CPUs and the NUMA node of its config. It
+// returns nil when the backend isn't pinned: auto only pins the backends of the machines with several NUMA nodes
+func (ml *ModelLoader) resolveCPUPinning(modelID, cpus, node string) (*cpuPinning, error) {
+	if cpus == "" && node == "" {
+		return nil, nil
+	}
+
+	pinning := &cpuPinning{node: -1}
+	if cpus != "" {
+		var err error
+		if pinning.cpus, err = xsysinfo.ParseCPUList(cpus); err != nil {
+			return nil, err
+		}
+	}
+	if node == "" {
+		return pinning, nil
+	}
+
+	nodes, err := xsysinfo.NUMANodes()
+	if err != nil {
+		log.Warn().Err(err).Str("model", modelID).Msg("unable to list the NUMA nodes")
+	}
+	var selected *xsysinfo.NUMANode
+	if node == numaNodeAuto {
+		if len(nodes) < 2 {
+			log.Debug().Str("model", modelID).Int("nodes", len(nodes)).Msg("numa_node is auto, but there are less than 2 NUMA nodes to choose from")
+			if pinning.cpus == nil {
+				return nil, nil
+			}
+			return pinning, nil
+		}
+		selected = autoNUMANode(nodes, ml.pinnedNodeBackends())
+	} else {
+		id, err := strconv.Atoi(node)
+		if err != nil {
+			return nil, fmt.Errorf("invalid numa_node %q, it's a node number or auto", node)
+		}
+		for i := range nodes {
+			if nodes[i].ID == id {
+				selected = &nodes[i]
+			}
+		}
+		if selected == nil {
+			return nil, fmt.Errorf("NUMA node %d not found, the machine has %d nodes", id, len(nodes))
+		}
+	}
+
+	pinning.node = selected.ID
+	if pinning.cpus == nil {
+		pinning.cpus = selected.CPUs
+	}
+	ml.pinnedNodes[modelID] = selected.ID
+	log.Info().Str("model", modelID).Int("numa_node", selected.ID).Str("cpus", xsysinfo.FormatCPUList(pinning.cpus)).Msg("pinning the backend")
+	return pinning, nil
+}
+
+// pinnedNodeBackends counts the backends pinned on each NUMA node
+func (ml *ModelLoader) pinnedNodeBackends() map[int]int {
+	backends := map[int]int{}
+	for _, node := range ml.pinnedNodes {
+		backends[node]++
+	}
+	return backends
+}
+
+// autoNUMANode returns the node running the fewest pinned backends, then the one with the most free memory
+func autoNUMANode(nodes []xsysinfo.NUMANode, backends map[int]int) *xsysinfo.NUMANode {
+	best := &nodes[0]
+	for i := range nodes[1:] {
+		n := &nodes[i+1]
+		if backends[n.ID] < backends[best.ID] || (backends[n.ID] == backends[best.ID] && n.FreeMemory > best.FreeMemory) {
+			best = n
+		}
+	}
+	return best
+}
+
+// command returns the command running the backend pinned: with numactl, which binds its memory to the node as
+// well, or with taskset otherwise. The backend runs unpinned when neither is installed
+func (p *cpuPinning) command(name string, args []string) (string, []string) {
+	cpus := xsysinfo.FormatCPUList(p.cpus)
+	if numactl, err := exec.LookPath("numactl"); err == nil {
+		pinned := []string{"--physcpubind=" + cpus}
+		if p.node >= 0 {
+			pinned = append(pinned, "--membind="+strconv.Itoa(p.node))
+		}
+		return numactl, append(append(pinned, "--", name), args...)
+	}
+	if taskset, err := exec.LookPath("taskset"); err == nil {
+		if p.node >= 0 {
+			log.Warn().Int("numa_node", p.node).Msg("numactl is not installed, the memory of the backend is not bound to its NUMA node")
+		}
+		return taskset, append([]string{"--cpu-list", cpus, name}, args...)
+	}
+	log.Warn().Str("cpus", cpus).Msg("neither numactl nor taskset is installed, the backend is not pinned to its CPUs")
+	return name, args
+}
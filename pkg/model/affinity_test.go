@@ -0,0 +1,64 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CPU pinning", func() {
+	It("places the backends on the node running the fewest, then with the most free memory", func() {
+		nodes := []xsysinfo.NUMANode{
+			{ID: 0, CPUs: []int{0, 1}, FreeMemory: 10 << 30},
+			{ID: 1, CPUs: []int{2, 3}, FreeMemory: 20 << 30},
+		}
+		Expect(autoNUMANode(nodes, map[int]int{}).ID).To(Equal(1))
+		Expect(autoNUMANode(nodes, map[int]int{1: 1}).ID).To(Equal(0))
+		Expect(autoNUMANode(nodes, map[int]int{0: 1, 1: 1}).ID).To(Equal(1))
+	})
+
+	It("pins the backends to their CPUs", func() {
+		ml := NewModelLoader("")
+		pinning, err := ml.resolveCPUPinning("model", "0-3,8", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pinning).To(Equal(&cpuPinning{cpus: []int{0, 1, 2, 3, 8}, node: -1}))
+
+		Expect(ml.resolveCPUPinning("model", "", "")).To(BeNil())
+		_, err = ml.resolveCPUPinning("model", "3-1", "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("command", func() {
+		path := func(tools ...string) {
+			dir := GinkgoT().TempDir()
+			for _, tool := range tools {
+				Expect(os.WriteFile(filepath.Join(dir, tool), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+			}
+			GinkgoT().Setenv("PATH", dir)
+		}
+
+		It("runs the backend with numactl, binding its memory", func() {
+			path("numactl", "taskset")
+			name, args := (&cpuPinning{cpus: []int{0, 1, 2, 3}, node: 1}).command("/backends/llama-cpp", []string{"--addr", "127.0.0.1:1234"})
+			Expect(filepath.Base(name)).To(Equal("numactl"))
+			Expect(args).To(Equal([]string{"--physcpubind=0-3", "--membind=1", "--", "/backends/llama-cpp", "--addr", "127.0.0.1:1234"}))
+		})
+
+		It("falls back to taskset", func() {
+			path("taskset")
+			name, args := (&cpuPinning{cpus: []int{0, 2}, node: -1}).command("/backends/llama-cpp", []string{"--addr", "127.0.0.1:1234"})
+			Expect(filepath.Base(name)).To(Equal("taskset"))
+			Expect(args).To(Equal([]string{"--cpu-list", "0,2", "/backends/llama-cpp", "--addr", "127.0.0.1:1234"}))
+		})
+
+		It("runs the backend unpinned without the tools", func() {
+			path()
+			name, args := (&cpuPinning{cpus: []int{0}, node: 0}).command("/backends/llama-cpp", []string{"--addr", "127.0.0.1:1234"})
+			Expect(name).To(Equal("/backends/llama-cpp"))
+			Expect(args).To(Equal([]string{"--addr", "127.0.0.1:1234"}))
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"bufio"
+	"os"
+	"time"
+
+	process "github.com/mudler/go-processmanager"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	crashCheckInterval = 2 * time.Second
+	// crashLogLines is the number of lines kept from the end of the backend logs
+	crashLogLines = 500
+)
+
+// BackendCrash describes a backend process which exited without being stopped by LocalAI
+type BackendCrash struct {
+	ModelID string
+	Backend string
+	Address string
+	Time    time.Time
+	Stdout  []string
+	Stderr  []string
+}
+
+// SetCrashHandler sets the function called when a backend process exits abnormally
+func (ml *ModelLoader) SetCrashHandler(fn func(BackendCrash)) {
+	ml.onCrash = fn
+}
+
+// watchProcessExit reports the exit of the process, unless it was stopped by LocalAI
+func (ml *ModelLoader) watchProcessExit(id, backend, address string, p *process.Process) {
+	ticker := time.NewTicker(crashCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, stopped := ml.stoppedProcesses.LoadAndDelete(p); stopped {
+			return
+		}
+		if p.IsAlive() {
+			continue
+		}
+		if _, stopped := ml.stoppedProcesses.LoadAndDelete(p); stopped {
+			return
+		}
+
+		log.Error().Str("model", id).Str("backend", backend).Msg("backend process exited unexpectedly")
+		if ml.onCrash != nil {
+			ml.onCrash(BackendCrash{
+				ModelID: id,
+				Backend: backend,
+				Address: address,
+				Time:    time.Now(),
+				Stdout:  tailLines(p.StdoutPath(), crashLogLines),
+				Stderr:  tailLines(p.StderrPath(), crashLogLines),
+			})
+		}
+		return
+	}
+}
+
+// tailLines returns the last n lines of a file
+func tailLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
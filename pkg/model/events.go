@@ -0,0 +1,34 @@
+package model
+
+// ModelEvent is a change of the models loaded by the model loader
+type ModelEvent string
+
+const (
+	ModelLoaded   ModelEvent = "load"
+	ModelUnloaded ModelEvent = "unload"
+	// ModelHung is sent when the backend of a model is suspected to be hung, before it's restarted
+	ModelHung ModelEvent = "hung"
+
+	// ModelIdle and ModelBusy are sent by the watchdog when it stops the backend of a model idle or busy for too long
+	ModelIdle ModelEvent = "idle"
+	ModelBusy ModelEvent = "busy"
+)
+
+// AddModelEventHandler adds a function called when a model is loaded or unloaded. It's called while the loader
+// is locked, so it must not block nor use the loader
+func (ml *ModelLoader) AddModelEventHandler(fn func(modelID string, event ModelEvent)) {
+	ml.onModelEvent = append(ml.onModelEvent, fn)
+}
+
+func (ml *ModelLoader) modelEvent(modelID string, event ModelEvent) {
+	for _, fn := range ml.onModelEvent {
+		fn(modelID, event)
+	}
+}
+
+// MarkHung records that the backend of the model produced no output in time and is suspected to be hung
+func (ml *ModelLoader) MarkHung(modelID string) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	ml.modelEvent(modelID, ModelHung)
+}
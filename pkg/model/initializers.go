@@ -56,6 +56,39 @@ const (
 	LocalStoreBackend = "local-store"
 )
 
+// llamaCPPCPUVariants are the llama.cpp builds for CPU, from the fastest, with the CPU features they are compiled for
+var llamaCPPCPUVariants = []struct {
+	name     string
+	features []cpuid.FeatureID
+}{
+	{LLamaCPPAVX2, []cpuid.FeatureID{cpuid.AVX, cpuid.AVX2, cpuid.FMA3, cpuid.F16C}},
+	{LLamaCPPAVX, []cpuid.FeatureID{cpuid.AVX}},
+	{LLamaCPPFallback, nil},
+}
+
+// LLamaCPPCPUVariant returns the fastest llama.cpp CPU variant in the asset directory that the CPU supports,
+// or an empty string if no variant is shipped. When none of the shipped variants is supported, the error lists
+// the CPU features they miss: running them would crash the backend with an illegal instruction.
+func LLamaCPPCPUVariant(assetDir string) (string, error) {
+	unsupported := []string{}
+	for _, v := range llamaCPPCPUVariants {
+		if _, err := os.Stat(backendPath(assetDir, v.name)); err != nil {
+			continue
+		}
+		if xsysinfo.HasCPUCaps(v.features...) {
+			return v.name, nil
+		}
+		unsupported = append(unsupported, fmt.Sprintf("%s requires %s", v.name, strings.Join(xsysinfo.MissingCPUCaps(v.features...), ", ")))
+	}
+
+	if len(unsupported) == 0 {
+		return "", nil
+	}
+
+	return "", fmt.Errorf("no llama.cpp variant compatible with this CPU (%s). Build the variant without CPU extensions with `make backend-assets/grpc/%s`, or use an image that ships it",
+		strings.Join(unsupported, "; "), LLamaCPPFallback)
+}
+
 func backendPath(assetDir, backend string) string {
 	return filepath.Join(assetDir, "backend-assets", "grpc", backend)
 }
@@ -182,7 +215,7 @@ ENTRY:
 }
 
 // selectGRPCProcess selects the GRPC process to start based on system capabilities
-func selectGRPCProcess(backend, assetDir string, f16 bool) string {
+func selectGRPCProcess(backend, assetDir string, f16 bool) (string, error) {
 	foundCUDA := false
 	foundAMDGPU := false
 	foundIntelGPU := false
@@ -190,13 +223,13 @@ func selectGRPCProcess(backend, assetDir string, f16 bool) string {
 
 	// Select backend now just for llama.cpp
 	if backend != LLamaCPP {
-		return ""
+		return "", nil
 	}
 
 	// Note: This environment variable is read by the LocalAI's llama.cpp grpc-server
 	if os.Getenv("LLAMACPP_GRPC_SERVERS") != "" {
 		log.Info().Msgf("[%s] attempting to load with GRPC variant", LLamaCPPGRPC)
-		return backendPath(assetDir, LLamaCPPGRPC)
+		return backendPath(assetDir, LLamaCPPGRPC), nil
 	}
 
 	gpus, err := xsysinfo.GPUs()
@@ -204,7 +237,10 @@ func selectGRPCProcess(backend, assetDir string, f16 bool) string {
 		for _, gpu := range gpus {
 			if strings.Contains(gpu.String(), "nvidia") {
 				p := backendPath(assetDir, LLamaCPPCUDA)
-				if _, err := os.Stat(p); err == nil {
+				if !xsysinfo.HasCPUCaps(cpuid.AVX) {
+					// the CUDA variant is compiled with AVX
+					log.Warn().Msgf("Nvidia GPU device found, but the CPU doesn't support AVX required by the CUDA variant")
+				} else if _, err := os.Stat(p); err == nil {
 					log.Info().Msgf("[%s] attempting to load with CUDA variant", backend)
 					grpcProcess = p
 					foundCUDA = true
@@ -240,30 +276,19 @@ func selectGRPCProcess(backend, assetDir string, f16 bool) string {
 	}
 
 	if foundCUDA || foundAMDGPU || foundIntelGPU {
-		return grpcProcess
+		return grpcProcess, nil
 	}
 
-	if xsysinfo.HasCPUCaps(cpuid.AVX2) {
-		p := backendPath(assetDir, LLamaCPPAVX2)
-		if _, err := os.Stat(p); err == nil {
-			log.Info().Msgf("[%s] attempting to load with AVX2 variant", backend)
-			grpcProcess = p
-		}
-	} else if xsysinfo.HasCPUCaps(cpuid.AVX) {
-		p := backendPath(assetDir, LLamaCPPAVX)
-		if _, err := os.Stat(p); err == nil {
-			log.Info().Msgf("[%s] attempting to load with AVX variant", backend)
-			grpcProcess = p
-		}
-	} else {
-		p := backendPath(assetDir, LLamaCPPFallback)
-		if _, err := os.Stat(p); err == nil {
-			log.Info().Msgf("[%s] attempting to load with fallback variant", backend)
-			grpcProcess = p
-		}
+	variant, err := LLamaCPPCPUVariant(assetDir)
+	if err != nil {
+		return "", err
+	}
+	if variant != "" {
+		log.Info().Msgf("[%s] attempting to load with %s variant", backend, variant)
+		grpcProcess = backendPath(assetDir, variant)
 	}
 
-	return grpcProcess
+	return grpcProcess, nil
 }
 
 // starts the grpcModelProcess for the backend, and returns a grpc client
@@ -293,6 +318,11 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 			}
 		}
 
+		pinning, err := ml.resolveCPUPinning(o.model, o.cpus, o.numaNode)
+		if err != nil {
+			return nil, fmt.Errorf("failed pinning the backend: %w", err)
+		}
+
 		// Check if the backend is provided as external
 		if uri, ok := o.externalBackends[backend]; ok {
 			log.Debug().Msgf("Loading external backend: %s", uri)
@@ -304,7 +334,7 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 					return nil, fmt.Errorf("failed allocating free ports: %s", err.Error())
 				}
 				// Make sure the process is executable
-				if err := ml.startProcess(uri, o.model, serverAddress); err != nil {
+				if err := ml.startProcess(uri, o.model, serverAddress, pinning); err != nil {
 					log.Error().Err(err).Str("path", uri).Msg("failed to launch ")
 					return nil, err
 				}
@@ -315,7 +345,7 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 			} else {
 				log.Debug().Msg("external backend is uri")
 				// address
-				client = NewModel(uri)
+				client = NewRemoteModel(uri, o.externalBackendsCredentials)
 			}
 		} else {
 			grpcProcess := backendPath(o.assetDir, backend)
@@ -325,7 +355,11 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 
 			if autoDetect {
 				// autoDetect GRPC process to start based on system capabilities
-				if selectedProcess := selectGRPCProcess(backend, o.assetDir, o.gRPCOptions.F16Memory); selectedProcess != "" {
+				selectedProcess, err := selectGRPCProcess(backend, o.assetDir, o.gRPCOptions.F16Memory)
+				if err != nil {
+					return nil, err
+				}
+				if selectedProcess != "" {
 					grpcProcess = selectedProcess
 				}
 			}
@@ -346,7 +380,7 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 			args, grpcProcess = library.LoadLDSO(o.assetDir, args, grpcProcess)
 
 			// Make sure the process is executable in any circumstance
-			if err := ml.startProcess(grpcProcess, o.model, serverAddress, args...); err != nil {
+			if err := ml.startProcess(grpcProcess, o.model, serverAddress, pinning, args...); err != nil {
 				return nil, err
 			}
 
@@ -378,6 +412,11 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 		options := *o.gRPCOptions
 		options.Model = modelName
 		options.ModelFile = modelFile
+		options.TensorSplit = resolveTensorSplit(modelName, options.TensorSplit, options.MainGPU)
+		// the threads of a pinned backend run on its CPUs
+		if pinning != nil && (options.Threads <= 0 || int(options.Threads) > len(pinning.cpus)) {
+			options.Threads = int32(len(pinning.cpus))
+		}
 
 		log.Debug().Msgf("GRPC: Loading model with options: %+v", options)
 
@@ -389,6 +428,11 @@ func (ml *ModelLoader) grpcModel(backend string, o *Options) func(string, string
 			return nil, fmt.Errorf("could not load model (no success): %s", res.Message)
 		}
 
+		if o.statePath != "" {
+			client.statePath = o.statePath
+			ml.restoreState(modelName, client)
+		}
+
 		return client, nil
 	}
 }
@@ -433,6 +477,7 @@ func (ml *ModelLoader) BackendLoader(opts ...Option) (client grpc.Backend, err e
 	if err != nil {
 		return nil, err
 	}
+	ml.modelUsed(o, opts)
 
 	return model.GRPC(o.parallelRequests, ml.wd), nil
 }
@@ -447,6 +492,7 @@ func (ml *ModelLoader) GreedyLoader(opts ...Option) (grpc.Backend, error) {
 	if m := ml.CheckIsLoaded(o.model); m != nil {
 		log.Debug().Msgf("Model '%s' already loaded", o.model)
 		ml.mu.Unlock()
+		ml.modelUsed(o, nil)
 
 		return m.GRPC(o.parallelRequests, ml.wd), nil
 	}
@@ -493,6 +539,7 @@ func (ml *ModelLoader) GreedyLoader(opts ...Option) (grpc.Backend, error) {
 		for k, v := range o.externalBackends {
 			options = append(options, WithExternalBackend(k, v))
 		}
+		options = append(options, WithExternalBackendsCredentials(o.externalBackendsCredentials))
 
 		model, modelerr := ml.BackendLoader(options...)
 		if modelerr == nil && model != nil {
@@ -508,22 +555,14 @@ func (ml *ModelLoader) GreedyLoader(opts ...Option) (grpc.Backend, error) {
 
 		if autoDetect && key == LLamaCPP && err != nil {
 			// try as hard as possible to run the llama.cpp variants
-			backendToUse := ""
-			if xsysinfo.HasCPUCaps(cpuid.AVX2) {
-				if _, err := os.Stat(backendPath(o.assetDir, LLamaCPPAVX2)); err == nil {
-					backendToUse = LLamaCPPAVX2
-				}
-			} else if xsysinfo.HasCPUCaps(cpuid.AVX) {
-				if _, err := os.Stat(backendPath(o.assetDir, LLamaCPPAVX2)); err == nil {
-					backendToUse = LLamaCPPAVX
-				}
-			} else {
-				if _, err := os.Stat(backendPath(o.assetDir, LLamaCPPFallback)); err == nil {
-					backendToUse = LLamaCPPFallback
-				} else {
-					// If we don't have a fallback, just skip fallback
-					continue
-				}
+			backendToUse, variantErr := LLamaCPPCPUVariant(o.assetDir)
+			if variantErr != nil {
+				err = errors.Join(err, variantErr)
+				continue
+			}
+			if backendToUse == "" {
+				// If we don't have a fallback, just skip fallback
+				continue
 			}
 
 			// Autodetection failed, try the fallback
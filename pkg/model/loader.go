@@ -15,6 +15,7 @@ import (
 
 	process "github.com/mudler/go-processmanager"
 	"github.com/rs/zerolog/log"
+	gopsutil "github.com/shirou/gopsutil/v3/process"
 )
 
 // new idea: what if we declare a struct of these here, and use a loop to check?
@@ -27,6 +28,21 @@ type ModelLoader struct {
 	grpcProcesses map[string]*process.Process
 	templates     *templates.TemplateCache
 	wd            *WatchDog
+	sampler       processSampler
+
+	// stoppedProcesses are the processes stopped by LocalAI, which exit isn't a crash
+	stoppedProcesses sync.Map
+	onCrash          func(BackendCrash)
+	onModelEvent     []func(string, ModelEvent)
+
+	// reduced are the settings of the loaded models reduced after running out of memory
+	reduced map[string]ReducedSettings
+
+	// sequences tracks the order the models are used in, to prefetch the next one. Nil when disabled
+	sequences *usageSequences
+
+	// pinnedNodes are the NUMA nodes the backends are pinned on, by model
+	pinnedNodes map[string]int
 }
 
 func NewModelLoader(modelPath string) *ModelLoader {
@@ -35,6 +51,12 @@ func NewModelLoader(modelPath string) *ModelLoader {
 		models:        make(map[string]*Model),
 		templates:     templates.NewTemplateCache(modelPath),
 		grpcProcesses: make(map[string]*process.Process),
+		reduced:       make(map[string]ReducedSettings),
+		pinnedNodes:   make(map[string]int),
+		sampler: processSampler{
+			processes: make(map[string]*gopsutil.Process),
+			stats:     make(map[string]ProcessStats),
+		},
 	}
 
 	return nml
@@ -138,6 +160,7 @@ func (ml *ModelLoader) LoadModel(modelName string, loader func(string, string) (
 	}
 
 	ml.models[modelName] = model
+	ml.modelEvent(modelName, ModelLoaded)
 
 	return model, nil
 }
@@ -146,6 +169,7 @@ func (ml *ModelLoader) ShutdownModel(modelName string) error {
 	ml.mu.Lock()
 	defer ml.mu.Unlock()
 
+	ml.saveState(modelName)
 	return ml.stopModel(modelName)
 }
 
@@ -102,4 +102,30 @@ var _ = Describe("ModelLoader", func() {
 			Expect(modelLoader.CheckIsLoaded("test.model")).To(BeNil())
 		})
 	})
+
+	Context("AddModelEventHandler", func() {
+		It("should report the models loaded and unloaded", func() {
+			events := []string{}
+			modelLoader.AddModelEventHandler(func(modelID string, event model.ModelEvent) {
+				events = append(events, modelID+" "+string(event))
+			})
+			unloads := 0
+			modelLoader.AddModelEventHandler(func(modelID string, event model.ModelEvent) {
+				if event == model.ModelUnloaded {
+					unloads++
+				}
+			})
+
+			mockLoader := func(modelName, modelFile string) (*model.Model, error) {
+				return model.NewModel("test.model"), nil
+			}
+			_, err := modelLoader.LoadModel("test.model", mockLoader)
+			Expect(err).To(BeNil())
+			Expect(modelLoader.ShutdownModel("test.model")).To(Succeed())
+			Expect(modelLoader.ShutdownModel("test.model")).ToNot(Succeed())
+
+			Expect(events).To(Equal([]string{"test.model load", "test.model unload"}))
+			Expect(unloads).To(Equal(1))
+		})
+	})
 })
@@ -3,8 +3,11 @@ package model
 import grpc "github.com/mudler/LocalAI/pkg/grpc"
 
 type Model struct {
-	address string
-	client  grpc.Backend
+	address     string
+	client      grpc.Backend
+	credentials *grpc.Credentials
+	// statePath is where the sessions are saved when the backend is stopped
+	statePath string
 }
 
 func NewModel(address string) *Model {
@@ -13,6 +16,15 @@ func NewModel(address string) *Model {
 	}
 }
 
+// NewRemoteModel returns a model served by a backend not started by LocalAI,
+// dialed with the given credentials (which can be nil)
+func NewRemoteModel(address string, credentials *grpc.Credentials) *Model {
+	return &Model{
+		address:     address,
+		credentials: credentials,
+	}
+}
+
 func (m *Model) GRPC(parallel bool, wd *WatchDog) grpc.Backend {
 	if m.client != nil {
 		return m.client
@@ -23,6 +35,6 @@ func (m *Model) GRPC(parallel bool, wd *WatchDog) grpc.Backend {
 		enableWD = true
 	}
 
-	m.client = grpc.NewClient(m.address, parallel, wd, enableWD)
+	m.client = grpc.NewClient(m.address, parallel, wd, enableWD, m.credentials)
 	return m.client
 }
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// oomLogLines is the number of lines of the backend logs searched for an out of memory error
+const oomLogLines = 50
+
+// outOfMemorySignatures are the messages of the out of memory errors of the backends (lowercase): CUDA, Metal,
+// Vulkan, PyTorch and the C++ allocator
+var outOfMemorySignatures = []string{
+	"out of memory",
+	"outofmemory",
+	"out_of_memory",
+	"outofdevicememory",
+	"std::bad_alloc",
+	"failed to allocate",
+	"unable to allocate",
+	"cudamalloc failed",
+	"insufficient memory",
+}
+
+// IsOutOfMemory tells whether the backend of the model ran out of memory, from the error of the request or, since
+// the backends often report a generic error, from the end of their logs
+func (ml *ModelLoader) IsOutOfMemory(modelName string, err error) bool {
+	if err == nil {
+		return false
+	}
+	if outOfMemory(err.Error()) {
+		return true
+	}
+
+	ml.mu.Lock()
+	p, exists := ml.grpcProcesses[modelName]
+	ml.mu.Unlock()
+	if !exists {
+		return false
+	}
+	return outOfMemory(tailLines(p.StderrPath(), oomLogLines)...)
+}
+
+// outOfMemory tells whether one of the messages is an out of memory error
+func outOfMemory(messages ...string) bool {
+	for _, msg := range messages {
+		msg = strings.ToLower(msg)
+		for _, signature := range outOfMemorySignatures {
+			if strings.Contains(msg, signature) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReducedSettings are the settings a model was reloaded with after its backend ran out of memory, nil or zero for
+// the settings left as configured. They stay in effect until the model is unloaded
+type ReducedSettings struct {
+	GPULayers   *int
+	ContextSize *int
+	Batch       int
+}
+
+// Changes describes the reduced settings, e.g. "gpu_layers 16"
+func (r ReducedSettings) Changes() []string {
+	changes := []string{}
+	if r.GPULayers != nil {
+		changes = append(changes, fmt.Sprintf("gpu_layers %d", *r.GPULayers))
+	}
+	if r.ContextSize != nil {
+		changes = append(changes, fmt.Sprintf("context_size %d", *r.ContextSize))
+	}
+	if r.Batch != 0 {
+		changes = append(changes, fmt.Sprintf("batch %d", r.Batch))
+	}
+	return changes
+}
+
+// SetReducedSettings records the settings the model was reloaded with after running out of memory
+func (ml *ModelLoader) SetReducedSettings(modelID string, r ReducedSettings) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if _, loaded := ml.models[modelID]; !loaded {
+		return
+	}
+	ml.reduced[modelID] = r
+}
+
+// ReducedSettings returns the settings the loaded model runs with after running out of memory, false when it runs
+// with its configured settings
+func (ml *ModelLoader) ReducedSettings(modelID string) (ReducedSettings, bool) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	r, ok := ml.reduced[modelID]
+	return r, ok
+}
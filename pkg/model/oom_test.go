@@ -0,0 +1,20 @@
+package model
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("outOfMemory", func() {
+	It("detects the out of memory errors of the backends", func() {
+		Expect(outOfMemory("CUDA error: out of memory")).To(BeTrue())
+		Expect(outOfMemory("torch.OutOfMemoryError: CUDA out of memory. Tried to allocate 2.00 GiB")).To(BeTrue())
+		Expect(outOfMemory("ggml_vulkan: Device memory allocation failed: ErrorOutOfDeviceMemory")).To(BeTrue())
+		Expect(outOfMemory("loading model", "terminate called after throwing an instance of 'std::bad_alloc'")).To(BeTrue())
+	})
+
+	It("ignores the other errors", func() {
+		Expect(outOfMemory("could not load model (no success): Failed loading model")).To(BeFalse())
+		Expect(outOfMemory()).To(BeFalse())
+	})
+})
@@ -3,6 +3,7 @@ package model
 import (
 	"context"
 
+	grpc "github.com/mudler/LocalAI/pkg/grpc"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 )
 
@@ -15,12 +16,22 @@ type Options struct {
 
 	gRPCOptions *pb.ModelOptions
 
-	externalBackends map[string]string
+	externalBackends            map[string]string
+	externalBackendsCredentials *grpc.Credentials
 
 	grpcAttempts        int
 	grpcAttemptsDelay   int
 	singleActiveBackend bool
 	parallelRequests    bool
+
+	// prefetch is set when the model is loaded ahead of its use
+	prefetch bool
+
+	// statePath is where the sessions of the model are saved when its backend is stopped, empty to drop them
+	statePath string
+
+	// cpus and numaNode pin the process of the backend, see WithCPUPinning
+	cpus, numaNode string
 }
 
 type Option func(*Options)
@@ -29,6 +40,10 @@ var EnableParallelRequests = func(o *Options) {
 	o.parallelRequests = true
 }
 
+var asPrefetch = func(o *Options) {
+	o.prefetch = true
+}
+
 func WithExternalBackend(name string, uri string) Option {
 	return func(o *Options) {
 		if o.externalBackends == nil {
@@ -38,6 +53,13 @@ func WithExternalBackend(name string, uri string) Option {
 	}
 }
 
+// WithExternalBackendsCredentials sets the credentials used to dial the external backends given by address
+func WithExternalBackendsCredentials(credentials *grpc.Credentials) Option {
+	return func(o *Options) {
+		o.externalBackendsCredentials = credentials
+	}
+}
+
 func WithGRPCAttempts(attempts int) Option {
 	return func(o *Options) {
 		o.grpcAttempts = attempts
@@ -92,6 +114,23 @@ func WithSingleActiveBackend() Option {
 	}
 }
 
+// WithStatePath saves the sessions of the model in path when its backend is stopped, and restores them once
+// it's loaded again
+func WithStatePath(path string) Option {
+	return func(o *Options) {
+		o.statePath = path
+	}
+}
+
+// WithCPUPinning pins the process of the backend to the CPUs, a cpuset list like 0-15,32-47, and to the NUMA node,
+// whose memory it allocates. The node is a number, or auto to spread the backends on the nodes
+func WithCPUPinning(cpus, numaNode string) Option {
+	return func(o *Options) {
+		o.cpus = cpus
+		o.numaNode = numaNode
+	}
+}
+
 func NewOptions(opts ...Option) *Options {
 	o := &Options{
 		gRPCOptions:       &pb.ModelOptions{},
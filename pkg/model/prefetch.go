@@ -0,0 +1,161 @@
+package model
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+const (
+	// prefetchMinObservations is how many times a model must have followed another before it's prefetched
+	prefetchMinObservations = 3
+	// prefetchMinShare is the share of the models used after another that the prefetched one must have
+	prefetchMinShare = 0.5
+	// prefetchMemoryHeadroom is how much more memory than the size of the model files must be available
+	prefetchMemoryHeadroom = 1.2
+)
+
+// usageSequences counts which model is used after which, to guess the next model of pipeline-style workloads
+// (e.g. embeddings after a chat completion)
+type usageSequences struct {
+	mu   sync.Mutex
+	last string
+	// next counts, for each model, the models used right after it
+	next map[string]map[string]int
+	// options are the options each model was last loaded with, to load it again
+	options map[string][]Option
+	// prefetching are the models being prefetched
+	prefetching map[string]bool
+}
+
+func newUsageSequences() *usageSequences {
+	return &usageSequences{
+		next:        make(map[string]map[string]int),
+		options:     make(map[string][]Option),
+		prefetching: make(map[string]bool),
+	}
+}
+
+// observe records the use of a model, and the options it was loaded with when not nil
+func (u *usageSequences) observe(model string, opts []Option) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if opts != nil {
+		u.options[model] = append([]Option{}, opts...)
+	}
+	if u.last != "" && u.last != model {
+		if u.next[u.last] == nil {
+			u.next[u.last] = make(map[string]int)
+		}
+		u.next[u.last][model]++
+	}
+	u.last = model
+}
+
+// likelyNext returns the model most likely used after model, if it's likely enough
+func (u *usageSequences) likelyNext(model string) (string, []Option, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	total, best, bestCount := 0, "", 0
+	for m, count := range u.next[model] {
+		total += count
+		if count > bestCount || (count == bestCount && m < best) {
+			best, bestCount = m, count
+		}
+	}
+	if bestCount < prefetchMinObservations || float64(bestCount) < prefetchMinShare*float64(total) {
+		return "", nil, false
+	}
+	opts, ok := u.options[best]
+	return best, append([]Option{}, opts...), ok
+}
+
+// EnablePrefetch makes the loader load in the background the model likely used next, when enough memory is available
+func (ml *ModelLoader) EnablePrefetch() {
+	ml.sequences = newUsageSequences()
+}
+
+// modelUsed records the use of a model, and prefetches the model likely used after it
+func (ml *ModelLoader) modelUsed(o *Options, opts []Option) {
+	if ml.sequences == nil || o.prefetch {
+		return
+	}
+	ml.sequences.observe(o.model, opts)
+
+	// prefetching another model would stop the one in use
+	if o.singleActiveBackend {
+		return
+	}
+
+	next, nextOpts, ok := ml.sequences.likelyNext(o.model)
+	if !ok {
+		return
+	}
+
+	ml.sequences.mu.Lock()
+	if ml.sequences.prefetching[next] {
+		ml.sequences.mu.Unlock()
+		return
+	}
+	ml.sequences.prefetching[next] = true
+	ml.sequences.mu.Unlock()
+
+	go func() {
+		defer func() {
+			ml.sequences.mu.Lock()
+			delete(ml.sequences.prefetching, next)
+			ml.sequences.mu.Unlock()
+		}()
+
+		ml.mu.Lock()
+		_, loaded := ml.models[next]
+		ml.mu.Unlock()
+		if loaded {
+			return
+		}
+
+		if !ml.fitsInMemory(next) {
+			log.Debug().Str("model", next).Msg("not prefetching the model, not enough memory available")
+			return
+		}
+
+		log.Info().Str("model", next).Str("after", o.model).Msg("prefetching the model likely used next")
+		if _, err := ml.BackendLoader(append(nextOpts, asPrefetch)...); err != nil {
+			log.Warn().Err(err).Str("model", next).Msg("failed prefetching the model")
+		}
+	}()
+}
+
+// fitsInMemory returns whether the files of the model fit in the available memory. Models which
+// size is unknown (e.g. downloaded by the backend) are never prefetched
+func (ml *ModelLoader) fitsInMemory(model string) bool {
+	size := int64(0)
+	err := filepath.WalkDir(filepath.Join(ml.ModelPath, model), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() || d.Type()&os.ModeSymlink != 0 {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	if err != nil || size == 0 {
+		return false
+	}
+
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		return false
+	}
+	return float64(vm.Available) >= prefetchMemoryHeadroom*float64(size)
+}
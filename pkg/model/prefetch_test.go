@@ -0,0 +1,54 @@
+package model
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("usageSequences", func() {
+	var u *usageSequences
+
+	BeforeEach(func() {
+		u = newUsageSequences()
+	})
+
+	use := func(models ...string) {
+		for _, m := range models {
+			u.observe(m, []Option{WithModel(m)})
+		}
+	}
+
+	It("guesses the model used next once it's been seen enough times", func() {
+		use("chat", "embeddings", "chat", "embeddings")
+		_, _, ok := u.likelyNext("chat")
+		Expect(ok).To(BeFalse())
+
+		use("chat", "embeddings")
+		next, opts, ok := u.likelyNext("chat")
+		Expect(ok).To(BeTrue())
+		Expect(next).To(Equal("embeddings"))
+		Expect(NewOptions(opts...).model).To(Equal("embeddings"))
+	})
+
+	It("ignores the models used again", func() {
+		use("chat", "chat", "chat", "chat")
+		_, _, ok := u.likelyNext("chat")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("doesn't guess when no model follows most of the times", func() {
+		use("chat", "embeddings", "chat", "embeddings", "chat", "embeddings")
+		use("chat", "tts", "chat", "tts", "chat", "tts", "chat", "whisper", "chat", "whisper")
+		_, _, ok := u.likelyNext("chat")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("doesn't guess models which options are unknown", func() {
+		for i := 0; i < 3; i++ {
+			u.observe("chat", nil)
+			u.observe("embeddings", nil)
+		}
+		_, _, ok := u.likelyNext("chat")
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -32,12 +32,18 @@ func (ml *ModelLoader) StopAllExcept(s string) error {
 
 func (ml *ModelLoader) deleteProcess(s string) error {
 	if _, exists := ml.grpcProcesses[s]; exists {
+		ml.stoppedProcesses.Store(ml.grpcProcesses[s], struct{}{})
 		if err := ml.grpcProcesses[s].Stop(); err != nil {
 			log.Error().Err(err).Msgf("(deleteProcess) error while deleting grpc process %s", s)
 		}
 	}
 	delete(ml.grpcProcesses, s)
-	delete(ml.models, s)
+	delete(ml.reduced, s)
+	delete(ml.pinnedNodes, s)
+	if _, loaded := ml.models[s]; loaded {
+		delete(ml.models, s)
+		ml.modelEvent(s, ModelUnloaded)
+	}
 	return nil
 }
 
@@ -70,7 +76,7 @@ func (ml *ModelLoader) GetGRPCPID(id string) (int, error) {
 	return strconv.Atoi(p.PID)
 }
 
-func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string, args ...string) error {
+func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string, pinning *cpuPinning, args ...string) error {
 	// Make sure the process is executable
 	if err := os.Chmod(grpcProcess, 0700); err != nil {
 		return err
@@ -85,10 +91,15 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 		return err
 	}
 
+	name, args := filepath.Base(grpcProcess), append(args, []string{"--addr", serverAddress}...)
+	if pinning != nil {
+		name, args = pinning.command(filepath.Join(workDir, name), args)
+	}
+
 	grpcControlProcess := process.New(
 		process.WithTemporaryStateDir(),
-		process.WithName(filepath.Base(grpcProcess)),
-		process.WithArgs(append(args, []string{"--addr", serverAddress}...)...),
+		process.WithName(name),
+		process.WithArgs(args...),
 		process.WithEnvironment(os.Environ()...),
 		process.WithWorkDir(workDir),
 	)
@@ -105,11 +116,13 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 	}
 
 	log.Debug().Msgf("GRPC Service state dir: %s", grpcControlProcess.StateDir())
+	go ml.watchProcessExit(id, filepath.Base(grpcProcess), serverAddress, grpcControlProcess)
 	// clean up process
 	go func() {
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		<-c
+		ml.stoppedProcesses.Store(grpcControlProcess, struct{}{})
 		err := grpcControlProcess.Stop()
 		if err != nil {
 			log.Error().Err(err).Msg("error while shutting down grpc process")
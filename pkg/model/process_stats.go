@@ -0,0 +1,124 @@
+package model
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/rs/zerolog/log"
+	gopsutil "github.com/shirou/gopsutil/v3/process"
+)
+
+// ProcessStats is a sample of the resources used by a backend process
+type ProcessStats struct {
+	PID            int       `json:"pid"`
+	CPUPercent     float64   `json:"cpu_percent"`
+	RSS            uint64    `json:"rss"`
+	OpenFDs        int32     `json:"open_fds"`
+	UptimeSeconds  float64   `json:"uptime_seconds"`
+	GPUUtilization float64   `json:"gpu_utilization,omitempty"`
+	VRAM           uint64    `json:"vram,omitempty"`
+	SampledAt      time.Time `json:"sampled_at"`
+}
+
+type processSampler struct {
+	sync.Mutex
+	processes map[string]*gopsutil.Process
+	stats     map[string]ProcessStats
+}
+
+// ProcessStats returns the last sample of the backend process of the model
+func (ml *ModelLoader) ProcessStats(modelName string) (ProcessStats, bool) {
+	ml.sampler.Lock()
+	defer ml.sampler.Unlock()
+	s, exists := ml.sampler.stats[modelName]
+	return s, exists
+}
+
+// AllProcessStats returns the last sample of all the backend processes, by model
+func (ml *ModelLoader) AllProcessStats() map[string]ProcessStats {
+	ml.sampler.Lock()
+	defer ml.sampler.Unlock()
+	stats := make(map[string]ProcessStats, len(ml.sampler.stats))
+	for k, v := range ml.sampler.stats {
+		stats[k] = v
+	}
+	return stats
+}
+
+// SampleProcesses samples the resources used by the backend processes every interval,
+// until ctx is done
+func (ml *ModelLoader) SampleProcesses(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ml.sampleProcesses()
+		}
+	}
+}
+
+func (ml *ModelLoader) sampleProcesses() {
+	// Don't wait for models being loaded, retry on the next round
+	if !ml.mu.TryLock() {
+		return
+	}
+	pids := map[string]int{}
+	for id, p := range ml.grpcProcesses {
+		if pid, err := strconv.Atoi(p.PID); err == nil {
+			pids[id] = pid
+		}
+	}
+	ml.mu.Unlock()
+
+	gpuUsage, err := xsysinfo.GPUProcessesUsage()
+	if err != nil {
+		log.Debug().Err(err).Msg("cannot sample the GPU usage of the backends")
+	}
+
+	ml.sampler.Lock()
+	defer ml.sampler.Unlock()
+
+	for id := range ml.sampler.stats {
+		if _, exists := pids[id]; !exists {
+			delete(ml.sampler.stats, id)
+			delete(ml.sampler.processes, id)
+		}
+	}
+
+	for id, pid := range pids {
+		p, exists := ml.sampler.processes[id]
+		if !exists || p.Pid != int32(pid) {
+			if p, err = gopsutil.NewProcess(int32(pid)); err != nil {
+				log.Debug().Err(err).Str("model", id).Int("pid", pid).Msg("cannot sample backend process")
+				continue
+			}
+			ml.sampler.processes[id] = p
+		}
+
+		s := ProcessStats{PID: pid, SampledAt: time.Now()}
+		// CPU usage since the previous sample
+		if cpu, err := p.Percent(0); err == nil {
+			s.CPUPercent = cpu
+		}
+		if mem, err := p.MemoryInfo(); err == nil {
+			s.RSS = mem.RSS
+		}
+		if fds, err := p.NumFDs(); err == nil {
+			s.OpenFDs = fds
+		}
+		if created, err := p.CreateTime(); err == nil {
+			s.UptimeSeconds = time.Since(time.UnixMilli(created)).Seconds()
+		}
+		if gpu, exists := gpuUsage[pid]; exists {
+			s.GPUUtilization = gpu.Utilization
+			s.VRAM = gpu.VRAM
+		}
+		ml.sampler.stats[id] = s
+	}
+}
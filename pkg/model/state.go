@@ -0,0 +1,75 @@
+package model
+
+import (
+	"context"
+	"os"
+	"time"
+
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stateTimeout bounds the time to save or restore the sessions, which can take a while with large contexts
+const stateTimeout = 2 * time.Minute
+
+// saveState saves the sessions of a model before its backend is stopped, when its config asks for it. A busy backend
+// is stopped without saving them, since it may be hung
+func (ml *ModelLoader) saveState(modelName string) {
+	m, exists := ml.models[modelName]
+	if !exists || m.statePath == "" {
+		return
+	}
+	client := m.GRPC(false, ml.wd)
+	if client.IsBusy() {
+		log.Warn().Str("model", modelName).Msg("the backend is busy, its sessions are not saved before stopping it")
+		return
+	}
+
+	if err := os.RemoveAll(m.statePath); err != nil {
+		log.Error().Err(err).Str("model", modelName).Msg("failed to clear the saved sessions")
+		return
+	}
+	if err := os.MkdirAll(m.statePath, 0750); err != nil {
+		log.Error().Err(err).Str("model", modelName).Msg("failed to create the directory of the sessions")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	res, err := client.SaveState(ctx, &pb.StateRequest{Path: m.statePath})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		log.Debug().Str("model", modelName).Msg("the backend can't save its sessions")
+	case err != nil:
+		log.Error().Err(err).Str("model", modelName).Msg("failed to save the sessions")
+	case !res.Success:
+		log.Error().Str("model", modelName).Str("error", res.Message).Msg("failed to save the sessions")
+	default:
+		log.Info().Str("model", modelName).Str("path", m.statePath).Str("saved", res.Message).Msg("saved the sessions of the backend")
+	}
+}
+
+// restoreState restores the sessions saved when the backend of the model was stopped, then removes them: they are
+// saved again at the next stop
+func (ml *ModelLoader) restoreState(modelName string, m *Model) {
+	if _, err := os.Stat(m.statePath); err != nil {
+		return
+	}
+	defer os.RemoveAll(m.statePath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), stateTimeout)
+	defer cancel()
+	res, err := m.GRPC(false, ml.wd).LoadState(ctx, &pb.StateRequest{Path: m.statePath})
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		log.Debug().Str("model", modelName).Msg("the backend can't restore its sessions")
+	case err != nil:
+		log.Error().Err(err).Str("model", modelName).Msg("failed to restore the sessions")
+	case !res.Success:
+		log.Error().Str("model", modelName).Str("error", res.Message).Msg("failed to restore the sessions")
+	default:
+		log.Info().Str("model", modelName).Str("restored", res.Message).Msg("restored the sessions of the backend")
+	}
+}
@@ -19,6 +19,13 @@ type PromptTemplateData struct {
 	MessageIndex         int
 }
 
+// ResponseTemplateData is passed to the response templates, which post-process the output of the models
+type ResponseTemplateData struct {
+	Input     string // the prompt
+	Response  string
+	StopWords []string
+}
+
 type ChatMessageTemplateData struct {
 	SystemPrompt string
 	Role         string
@@ -37,6 +44,8 @@ const (
 	CompletionPromptTemplate
 	EditPromptTemplate
 	FunctionsPromptTemplate
+	ResponseTemplate
+	ModerationPromptTemplate
 )
 
 func (ml *ModelLoader) EvaluateTemplateForPrompt(templateType templates.TemplateType, templateName string, in PromptTemplateData) (string, error) {
@@ -47,6 +56,10 @@ func (ml *ModelLoader) EvaluateTemplateForPrompt(templateType templates.Template
 	return ml.templates.EvaluateTemplate(templateType, templateName, in)
 }
 
+func (ml *ModelLoader) EvaluateTemplateForResponse(templateName string, in ResponseTemplateData) (string, error) {
+	return ml.templates.EvaluateTemplate(ResponseTemplate, templateName, in)
+}
+
 func (ml *ModelLoader) EvaluateTemplateForChatMessage(templateName string, messageData ChatMessageTemplateData) (string, error) {
 	return ml.templates.EvaluateTemplate(ChatMessageTemplate, templateName, messageData)
 }
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	"github.com/rs/zerolog/log"
+)
+
+// tensorSplitAuto splits the layers of a model between the GPUs proportionally to their free VRAM
+const tensorSplitAuto = "auto"
+
+// resolveTensorSplit returns the tensor split a model is loaded with: auto is computed from the VRAM free at that
+// moment. The split and the main GPU which refer to more GPUs than the detected ones are reported
+func resolveTensorSplit(modelName, split, mainGPU string) string {
+	if split == "" && mainGPU == "" {
+		return split
+	}
+
+	gpus, err := xsysinfo.GPUsMemory()
+	if err != nil {
+		log.Warn().Err(err).Str("model", modelName).Msg("unable to list the GPUs")
+	}
+	if len(gpus) > 0 {
+		if ratios := strings.FieldsFunc(split, func(r rune) bool { return r == ',' || r == '/' }); split != tensorSplitAuto && len(ratios) > len(gpus) {
+			log.Warn().Str("model", modelName).Str("tensor_split", split).Int("gpus", len(gpus)).Msg("tensor_split has more ratios than GPUs")
+		}
+		if gpu, err := strconv.Atoi(mainGPU); err == nil && gpu >= len(gpus) {
+			log.Warn().Str("model", modelName).Int("main_gpu", gpu).Int("gpus", len(gpus)).Msg("main_gpu is not one of the GPUs")
+		}
+	}
+	if split != tensorSplitAuto {
+		return split
+	}
+
+	if len(gpus) < 2 {
+		log.Debug().Str("model", modelName).Int("gpus", len(gpus)).Msg("tensor_split is auto, but there are less than 2 GPUs to split the model between")
+		return ""
+	}
+	split = autoTensorSplit(gpus)
+	log.Info().Str("model", modelName).Str("tensor_split", split).Msg("splitting the model between the GPUs by free VRAM")
+	return split
+}
+
+// autoTensorSplit returns the free MiB of each GPU as the ratios, the backends normalize them
+func autoTensorSplit(gpus []xsysinfo.GPUMemory) string {
+	ratios := []string{}
+	for _, g := range gpus {
+		ratios = append(ratios, strconv.FormatUint(g.Free>>20, 10))
+	}
+	return strings.Join(ratios, ",")
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"github.com/mudler/LocalAI/pkg/xsysinfo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("autoTensorSplit", func() {
+	It("splits the model by the free VRAM of the GPUs", func() {
+		Expect(autoTensorSplit([]xsysinfo.GPUMemory{
+			{Index: 0, Total: 24 << 30, Free: 22 << 30},
+			{Index: 1, Total: 12 << 30, Free: 11<<30 + 512<<20},
+		})).To(Equal("22528,11776"))
+	})
+
+	It("keeps the explicit splits", func() {
+		Expect(resolveTensorSplit("model", "3,1", "")).To(Equal("3,1"))
+		Expect(resolveTensorSplit("model", "", "")).To(Equal(""))
+	})
+})
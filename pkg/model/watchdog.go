@@ -5,6 +5,7 @@ import (
 	"time"
 
 	process "github.com/mudler/go-processmanager"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -23,14 +24,17 @@ type WatchDog struct {
 	timeout, idletimeout time.Duration
 	addressMap           map[string]*process.Process
 	addressModelMap      map[string]string
+	addressUserMap       map[string]string
 	pm                   ProcessManager
 	stop                 chan bool
+	onEvent              func(string, ModelEvent)
 
 	busyCheck, idleCheck bool
 }
 
 type ProcessManager interface {
 	ShutdownModel(modelName string) error
+	ProcessStats(modelName string) (ProcessStats, bool)
 }
 
 func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy, idle bool) *WatchDog {
@@ -44,6 +48,21 @@ func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy
 		busyCheck:       busy,
 		idleCheck:       idle,
 		addressModelMap: make(map[string]string),
+		addressUserMap:  make(map[string]string),
+	}
+}
+
+// SetEventHandler sets the function called when the watchdog stops the backend of a model, with ModelIdle or
+// ModelBusy. It's called while the watchdog is locked, so it must not block
+func (wd *WatchDog) SetEventHandler(fn func(modelID string, event ModelEvent)) {
+	wd.Lock()
+	defer wd.Unlock()
+	wd.onEvent = fn
+}
+
+func (wd *WatchDog) event(modelID string, event ModelEvent) {
+	if wd.onEvent != nil {
+		wd.onEvent(modelID, event)
 	}
 }
 
@@ -72,10 +91,18 @@ func (wd *WatchDog) Mark(address string) {
 	delete(wd.idleTime, address)
 }
 
+// MarkUser records the end-user of the request keeping the backend busy
+func (wd *WatchDog) MarkUser(address, user string) {
+	wd.Lock()
+	defer wd.Unlock()
+	wd.addressUserMap[address] = user
+}
+
 func (wd *WatchDog) UnMark(ModelAddress string) {
 	wd.Lock()
 	defer wd.Unlock()
 	delete(wd.timetable, ModelAddress)
+	delete(wd.addressUserMap, ModelAddress)
 	wd.idleTime[ModelAddress] = time.Now()
 }
 
@@ -102,6 +129,18 @@ func (wd *WatchDog) Run() {
 	}
 }
 
+// killEvent returns the log event for a model being killed, with the last sample of its process
+func (wd *WatchDog) killEvent(model string, resolved bool) *zerolog.Event {
+	ev := log.Warn()
+	if !resolved {
+		return ev
+	}
+	if stats, exists := wd.pm.ProcessStats(model); exists {
+		ev = ev.Interface("process", stats)
+	}
+	return ev
+}
+
 func (wd *WatchDog) checkIdle() {
 	wd.Lock()
 	defer wd.Unlock()
@@ -109,13 +148,14 @@ func (wd *WatchDog) checkIdle() {
 	for address, t := range wd.idleTime {
 		log.Debug().Msgf("[WatchDog] %s: idle connection", address)
 		if time.Since(t) > wd.idletimeout {
-			log.Warn().Msgf("[WatchDog] Address %s is idle for too long, killing it", address)
 			model, ok := wd.addressModelMap[address]
+			wd.killEvent(model, ok).Msgf("[WatchDog] Address %s is idle for too long, killing it", address)
 			if ok {
 				if err := wd.pm.ShutdownModel(model); err != nil {
 					log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
 				}
 				log.Debug().Msgf("[WatchDog] model shut down: %s", address)
+				wd.event(model, ModelIdle)
 				delete(wd.idleTime, address)
 				delete(wd.addressModelMap, address)
 				delete(wd.addressMap, address)
@@ -139,17 +179,24 @@ func (wd *WatchDog) checkBusy() {
 
 			model, ok := wd.addressModelMap[address]
 			if ok {
-				log.Warn().Msgf("[WatchDog] Model %s is busy for too long, killing it", model)
+				ev := wd.killEvent(model, ok)
+				if user, ok := wd.addressUserMap[address]; ok {
+					ev = ev.Str("user", user)
+				}
+				ev.Msgf("[WatchDog] Model %s is busy for too long, killing it", model)
 				if err := wd.pm.ShutdownModel(model); err != nil {
 					log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
 				}
 				log.Debug().Msgf("[WatchDog] model shut down: %s", address)
+				wd.event(model, ModelBusy)
 				delete(wd.timetable, address)
 				delete(wd.addressModelMap, address)
 				delete(wd.addressMap, address)
+				delete(wd.addressUserMap, address)
 			} else {
 				log.Warn().Msgf("[WatchDog] Address %s unresolvable", address)
 				delete(wd.timetable, address)
+				delete(wd.addressUserMap, address)
 			}
 		}
 	}
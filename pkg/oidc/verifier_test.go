@@ -0,0 +1,215 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/oidc"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func segment(v interface{}) string {
+	data, err := json.Marshal(v)
+	Expect(err).ToNot(HaveOccurred())
+	return b64(data)
+}
+
+// signRS256 returns a JWT of the claims signed with the RSA key
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	signed := segment(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	Expect(err).ToNot(HaveOccurred())
+	return signed + "." + b64(sig)
+}
+
+// signES256 returns a JWT of the claims signed with the P-256 key
+func signES256(key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	signed := segment(map[string]string{"alg": "ES256", "kid": kid}) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	Expect(err).ToNot(HaveOccurred())
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + b64(sig)
+}
+
+var _ = Describe("Verifier", func() {
+	var (
+		rsaKey  *rsa.PrivateKey
+		ecKey   *ecdsa.PrivateKey
+		server  *httptest.Server
+		fetches int
+		// keysDelay slows down the responses of the keys of the issuer
+		keysDelay time.Duration
+		issuer    string
+	)
+
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": issuer,
+			"sub": "alice",
+			"aud": []string{"localai", "other"},
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+		ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		fetches, keysDelay = 0, 0
+
+		mux := http.NewServeMux()
+		server = httptest.NewServer(mux)
+		issuer = server.URL
+		mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/keys"})
+		})
+		mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+			fetches++
+			time.Sleep(keysDelay)
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+				{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+			}})
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("verifies the tokens signed with the keys discovered from the issuer", func() {
+		v := oidc.NewVerifier(issuer, "localai", "")
+
+		c, err := v.Verify(context.Background(), signRS256(rsaKey, "rsa-1", claims(map[string]interface{}{"scope": "chat embeddings"})))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.String("sub")).To(Equal("alice"))
+		Expect(c.Strings("scope")).To(Equal([]string{"chat", "embeddings"}))
+
+		c, err = v.Verify(context.Background(), signES256(ecKey, "ec-1", claims(map[string]interface{}{"groups": []string{"ml-users"}})))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Strings("groups")).To(Equal([]string{"ml-users"}))
+		Expect(fetches).To(Equal(1))
+	})
+
+	It("uses the configured keys URL", func() {
+		v := oidc.NewVerifier(issuer, "localai", issuer+"/keys")
+		_, err := v.Verify(context.Background(), signRS256(rsaKey, "rsa-1", claims(nil)))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("refuses the invalid tokens", func() {
+		v := oidc.NewVerifier(issuer, "localai", "")
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).ToNot(HaveOccurred())
+
+		for _, token := range []string{
+			signRS256(other, "rsa-1", claims(nil)),
+			signRS256(rsaKey, "unknown", claims(nil)),
+			signRS256(rsaKey, "rsa-1", claims(map[string]interface{}{"iss": "https://evil.example.com"})),
+			signRS256(rsaKey, "rsa-1", claims(map[string]interface{}{"aud": "someone-else"})),
+			signRS256(rsaKey, "rsa-1", claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+			signRS256(rsaKey, "rsa-1", claims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})),
+			signES256(ecKey, "rsa-1", claims(nil)),
+			"not.a.token",
+		} {
+			_, err := v.Verify(context.Background(), token)
+			Expect(err).To(HaveOccurred(), token)
+		}
+	})
+
+	It("refuses the tokens intended for another audience", func() {
+		v := oidc.NewVerifier(issuer, "localai", "")
+		for _, aud := range []interface{}{"someone-else", []string{"other"}, nil} {
+			_, err := v.Verify(context.Background(), signRS256(rsaKey, "rsa-1", claims(map[string]interface{}{"aud": aud})))
+			Expect(err).To(MatchError(ContainSubstring(`not intended for the audience "localai"`)), aud)
+		}
+		c, err := v.Verify(context.Background(), signRS256(rsaKey, "rsa-1", claims(map[string]interface{}{"aud": "localai"})))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Strings("aud")).To(Equal([]string{"localai"}))
+
+		// without audience, no token is accepted
+		_, err = oidc.NewVerifier(issuer, "", "").Verify(context.Background(), signRS256(rsaKey, "rsa-1", claims(nil)))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("fetches the keys once for the concurrent requests", func() {
+		keysDelay = 100 * time.Millisecond
+		v := oidc.NewVerifier(issuer, "localai", "")
+		token := signRS256(rsaKey, "rsa-1", claims(nil))
+
+		errs := make(chan error, 5)
+		for i := 0; i < cap(errs); i++ {
+			go func() {
+				_, err := v.Verify(context.Background(), token)
+				errs <- err
+			}()
+		}
+		for i := 0; i < cap(errs); i++ {
+			Expect(<-errs).ToNot(HaveOccurred())
+		}
+		Expect(fetches).To(Equal(1))
+
+		// the requests waiting for the keys give up with their context
+		v = oidc.NewVerifier(issuer, "localai", "")
+		go v.Verify(context.Background(), token)
+		time.Sleep(10 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := v.Verify(ctx, token)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+	})
+
+	It("refuses the unsigned and the symmetric tokens", func() {
+		v := oidc.NewVerifier(issuer, "localai", "")
+		unsigned := segment(map[string]string{"alg": "none", "kid": "rsa-1"}) + "." + segment(claims(nil)) + "."
+		_, err := v.Verify(context.Background(), unsigned)
+		Expect(err).To(MatchError(ContainSubstring("unsupported signing algorithm")))
+
+		hmac := segment(map[string]string{"alg": "HS256", "kid": "hmac"}) + "." + segment(claims(nil)) + ".c2ln"
+		_, err = v.Verify(context.Background(), hmac)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("doesn't fetch the keys again for each unknown key", func() {
+		v := oidc.NewVerifier(issuer, "localai", "")
+		for i := 0; i < 3; i++ {
+			_, err := v.Verify(context.Background(), signRS256(rsaKey, "unknown", claims(nil)))
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(fetches).To(Equal(1))
+	})
+
+	It("tells the JWTs from the API keys", func() {
+		Expect(oidc.IsJWT(signRS256(rsaKey, "rsa-1", claims(nil)))).To(BeTrue())
+		Expect(oidc.IsJWT("sk-1234")).To(BeFalse())
+		Expect(oidc.IsJWT(strings.Repeat("a.", 2))).To(BeFalse())
+	})
+})
@@ -0,0 +1,125 @@
+package reasoning
+
+import (
+	"strings"
+)
+
+const (
+	// ModePassthrough leaves the reasoning in the content, as generated by the model
+	ModePassthrough = "passthrough"
+	// ModeStrip removes the reasoning from the content
+	ModeStrip = "strip"
+	// ModeSeparate removes the reasoning from the content, and returns it in the reasoning_content field
+	ModeSeparate = "separate"
+
+	DefaultStartTag = "<think>"
+	DefaultEndTag   = "</think>"
+)
+
+// Config is the configuration of the reasoning blocks emitted by the model
+type Config struct {
+	// Mode is one of passthrough (default), strip and separate
+	Mode     string `yaml:"mode"`
+	StartTag string `yaml:"start_tag"`
+	EndTag   string `yaml:"end_tag"`
+}
+
+// Enabled returns whether the reasoning has to be split from the content
+func (c Config) Enabled() bool {
+	return c.Mode == ModeStrip || c.Mode == ModeSeparate
+}
+
+func (c Config) tags() (string, string) {
+	start, end := c.StartTag, c.EndTag
+	if start == "" {
+		start = DefaultStartTag
+	}
+	if end == "" {
+		end = DefaultEndTag
+	}
+	return start, end
+}
+
+// Splitter splits the reasoning blocks from the content of a streamed response
+type Splitter struct {
+	start, end  string
+	inReasoning bool
+	// trimContent is set after a reasoning block, to drop the blank lines separating it from the content
+	trimContent bool
+	pending     string
+}
+
+func NewSplitter(c Config) *Splitter {
+	start, end := c.tags()
+	return &Splitter{start: start, end: end}
+}
+
+// Feed processes the next chunk of the response, and returns the reasoning and the content in it.
+// The text which may be the beginning of a tag is kept until the next chunks tell.
+func (s *Splitter) Feed(chunk string) (string, string) {
+	s.pending += chunk
+
+	reasoning, content := strings.Builder{}, strings.Builder{}
+	emit := func(text string) {
+		if s.inReasoning {
+			reasoning.WriteString(text)
+			return
+		}
+		if s.trimContent {
+			text = strings.TrimLeft(text, " \t\r\n")
+			s.trimContent = text == ""
+		}
+		content.WriteString(text)
+	}
+
+	for {
+		tag := s.start
+		if s.inReasoning {
+			tag = s.end
+		}
+
+		if i := strings.Index(s.pending, tag); i >= 0 {
+			emit(s.pending[:i])
+			s.pending = s.pending[i+len(tag):]
+			s.inReasoning = !s.inReasoning
+			s.trimContent = !s.inReasoning
+			continue
+		}
+
+		keep := partialSuffix(s.pending, tag)
+		emit(s.pending[:len(s.pending)-keep])
+		s.pending = s.pending[len(s.pending)-keep:]
+		return reasoning.String(), content.String()
+	}
+}
+
+// Flush returns the text kept back at the end of the response
+func (s *Splitter) Flush() (string, string) {
+	text := s.pending
+	s.pending = ""
+	if s.inReasoning {
+		return text, ""
+	}
+	if s.trimContent {
+		text = strings.TrimLeft(text, " \t\r\n")
+	}
+	return "", text
+}
+
+// Split returns the reasoning and the content of a whole response
+func Split(c Config, text string) (string, string) {
+	s := NewSplitter(c)
+	reasoning, content := s.Feed(text)
+	restReasoning, restContent := s.Flush()
+	return strings.TrimSpace(reasoning + restReasoning), content + restContent
+}
+
+// partialSuffix returns the length of the longest suffix of text which is a prefix of tag
+func partialSuffix(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package reasoning_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/reasoning"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reasoning", func() {
+	Context("Split", func() {
+		It("splits the reasoning from the content", func() {
+			thought, content := Split(Config{Mode: ModeSeparate}, "<think>\nThe user greets me.\n</think>\n\nHello!")
+			Expect(thought).To(Equal("The user greets me."))
+			Expect(content).To(Equal("Hello!"))
+		})
+
+		It("leaves the responses without reasoning untouched", func() {
+			thought, content := Split(Config{Mode: ModeStrip}, "Hello!")
+			Expect(thought).To(BeEmpty())
+			Expect(content).To(Equal("Hello!"))
+		})
+
+		It("handles unterminated reasoning", func() {
+			thought, content := Split(Config{Mode: ModeStrip}, "<think>The generation stopped")
+			Expect(thought).To(Equal("The generation stopped"))
+			Expect(content).To(BeEmpty())
+		})
+
+		It("uses the configured tags", func() {
+			thought, content := Split(Config{Mode: ModeStrip, StartTag: "[THINK]", EndTag: "[/THINK]"}, "[THINK]hmm[/THINK]Hello <think>")
+			Expect(thought).To(Equal("hmm"))
+			Expect(content).To(Equal("Hello <think>"))
+		})
+	})
+
+	Context("Splitter", func() {
+		It("splits the streamed tokens, with tags spanning several tokens", func() {
+			s := NewSplitter(Config{Mode: ModeSeparate})
+			thought, content := "", ""
+			for _, token := range []string{"<", "th", "ink>", "2+2", " is 4</", "think", ">", "\n\n", "The answer", " is 4 <", "3"} {
+				t, c := s.Feed(token)
+				thought += t
+				content += c
+			}
+			t, c := s.Flush()
+			Expect(thought + t).To(Equal("2+2 is 4"))
+			Expect(content + c).To(Equal("The answer is 4 <3"))
+		})
+	})
+
+	It("is enabled only when stripping or separating the reasoning", func() {
+		Expect(Config{}.Enabled()).To(BeFalse())
+		Expect(Config{Mode: ModePassthrough}.Enabled()).To(BeFalse())
+		Expect(Config{Mode: ModeStrip}.Enabled()).To(BeTrue())
+		Expect(Config{Mode: ModeSeparate}.Enabled()).To(BeTrue())
+	})
+})
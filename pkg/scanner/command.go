@@ -0,0 +1,54 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Command scans the uploads with an external command, e.g. clamdscan. The path of the file is appended
+// to the arguments, and its name, kind and content type are set in the LOCALAI_UPLOAD_NAME,
+// LOCALAI_UPLOAD_KIND and LOCALAI_UPLOAD_CONTENT_TYPE environment variables.
+// As with clamscan, the exit code 0 means clean, 1 flagged, and any other one a failure of the scan
+type Command struct {
+	args []string
+}
+
+func (c *Command) Scan(ctx context.Context, u Upload) (Verdict, error) {
+	dir, err := os.MkdirTemp("", "localai-scan")
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "upload"+filepath.Ext(filepath.Base(u.Name)))
+	if err := os.WriteFile(path, u.Data, 0600); err != nil {
+		return Verdict{}, err
+	}
+
+	cmd := exec.CommandContext(ctx, c.args[0], append(c.args[1:], path)...)
+	cmd.Env = append(os.Environ(),
+		"LOCALAI_UPLOAD_NAME="+u.Name,
+		"LOCALAI_UPLOAD_KIND="+u.Kind,
+		"LOCALAI_UPLOAD_CONTENT_TYPE="+u.ContentType,
+	)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return Verdict{Clean: true}, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// the scanners print the path of the file, which means nothing to the clients
+		reason := strings.TrimSpace(strings.ReplaceAll(string(out), path, u.Name))
+		if reason == "" {
+			reason = "flagged by " + filepath.Base(c.args[0])
+		}
+		return Verdict{Reason: reason}, nil
+	}
+	return Verdict{}, fmt.Errorf("scanning %s with %s: %w: %s", u.Name, c.args[0], err, strings.TrimSpace(string(out)))
+}
@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTTP scans the uploads with an HTTP service: the file is POSTed with its content type, and its name
+// and kind in the X-Upload-Name and X-Upload-Kind headers. A 2xx status means clean, 403, 406, 422
+// and 451 flagged (the body tells why), and any other status a failure of the scan
+type HTTP struct {
+	url string
+}
+
+func (h *HTTP) Scan(ctx context.Context, u Upload) (Verdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(u.Data))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", u.ContentType)
+	req.Header.Set("X-Upload-Name", u.Name)
+	req.Header.Set("X-Upload-Kind", u.Kind)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusNotAcceptable, http.StatusUnprocessableEntity, http.StatusUnavailableForLegalReasons:
+		reason := strings.TrimSpace(string(body))
+		if reason == "" {
+			reason = resp.Status
+		}
+		return Verdict{Reason: reason}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Verdict{}, fmt.Errorf("scanning %s: the scanner answered %s", u.Name, resp.Status)
+	}
+	return Verdict{Clean: true}, nil
+}
@@ -0,0 +1,96 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultICAPPort    = "1344"
+	defaultICAPTimeout = 2 * time.Minute
+)
+
+// infectionHeaders are the headers the ICAP antivirus services (c-icap, Kaspersky, McAfee, ...) tell the threats in
+var infectionHeaders = []string{"X-Infection-Found", "X-Virus-Id", "X-Violations-Found"}
+
+// ICAP scans the uploads with an ICAP service (RFC 3507), e.g. c-icap with ClamAV, with RESPMOD requests:
+// a 204 answer means clean, a 200 one (the content was replaced) flagged
+type ICAP struct {
+	url  *url.URL
+	addr string
+}
+
+func NewICAP(u *url.URL) *ICAP {
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultICAPPort)
+	}
+	return &ICAP{url: u, addr: addr}
+}
+
+func (i *ICAP) Scan(ctx context.Context, u Upload) (Verdict, error) {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", i.addr)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultICAPTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	// the file is encapsulated in an HTTP response, its body chunked
+	httpHeader := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n", u.ContentType, len(u.Data))
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "RESPMOD %s ICAP/1.0\r\n", i.url.String())
+	fmt.Fprintf(w, "Host: %s\r\n", i.url.Host)
+	fmt.Fprintf(w, "Allow: 204\r\n")
+	fmt.Fprintf(w, "Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(httpHeader))
+	w.WriteString(httpHeader)
+	if len(u.Data) > 0 {
+		fmt.Fprintf(w, "%x\r\n", len(u.Data))
+		w.Write(u.Data)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("0\r\n\r\n")
+	if err := w.Flush(); err != nil {
+		return Verdict{}, err
+	}
+
+	r := textproto.NewReader(bufio.NewReader(conn))
+	status, err := r.ReadLine()
+	if err != nil {
+		return Verdict{}, err
+	}
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	proto, code, _ := strings.Cut(status, " ")
+	code, _, _ = strings.Cut(code, " ")
+	if !strings.HasPrefix(proto, "ICAP/") {
+		return Verdict{}, fmt.Errorf("scanning %s: invalid ICAP answer %q", u.Name, status)
+	}
+	switch code {
+	case "204":
+		return Verdict{Clean: true}, nil
+	case "200":
+		for _, h := range infectionHeaders {
+			if v := header.Get(h); v != "" {
+				return Verdict{Reason: v}, nil
+			}
+		}
+		return Verdict{Reason: "the content was replaced by the ICAP service"}, nil
+	}
+	return Verdict{}, fmt.Errorf("scanning %s: the ICAP service answered %q", u.Name, status)
+}
@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// The kinds of uploads, telling which endpoint received the file
+const (
+	KindFile  = "file"
+	KindImage = "image"
+	KindAudio = "audio"
+)
+
+// Upload is a file received by LocalAI, before it's stored or processed
+type Upload struct {
+	Name string
+	Kind string
+	// ContentType is sniffed from the data
+	ContentType string
+	Data        []byte
+}
+
+// NewUpload returns the upload of data, sniffing its content type
+func NewUpload(kind, name string, data []byte) Upload {
+	return Upload{Name: name, Kind: kind, ContentType: http.DetectContentType(data), Data: data}
+}
+
+// Verdict is the result of a scan
+type Verdict struct {
+	Clean bool `json:"clean"`
+	// Reason tells why the file was flagged, e.g. the name of the virus
+	Reason string `json:"reason,omitempty"`
+}
+
+// Scanner checks the uploaded files, e.g. with an antivirus. An error is returned when the file could not be scanned
+type Scanner interface {
+	Scan(ctx context.Context, u Upload) (Verdict, error)
+}
+
+// New returns the scanner of spec: an icap:// URL, an http(s):// URL or a command receiving the path of the file
+func New(spec string) (Scanner, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty scanner")
+	}
+
+	if u, err := url.Parse(spec); err == nil {
+		switch u.Scheme {
+		case "icap":
+			return NewICAP(u), nil
+		case "http", "https":
+			return &HTTP{url: spec}, nil
+		}
+	}
+	return &Command{args: strings.Fields(spec)}, nil
+}
+
+// Quarantine keeps a flagged upload in dir, next to a JSON file describing it, and returns its path
+func Quarantine(dir string, u Upload, v Verdict) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s-%s", time.Now().UTC().Format("20060102-150405.000000"), u.Kind, utils.SanitizeFileName(u.Name))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, u.Data, 0600); err != nil {
+		return "", err
+	}
+
+	info, err := json.MarshalIndent(struct {
+		Name        string    `json:"name"`
+		Kind        string    `json:"kind"`
+		ContentType string    `json:"content_type"`
+		Size        int       `json:"size"`
+		Reason      string    `json:"reason,omitempty"`
+		Quarantined time.Time `json:"quarantined"`
+	}{u.Name, u.Kind, u.ContentType, len(u.Data), v.Reason, time.Now()}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path+".json", info, 0600)
+}
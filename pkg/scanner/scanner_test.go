@@ -0,0 +1,161 @@
+package scanner_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/scanner"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+var _ = Describe("Upload scanners", func() {
+	clean := NewUpload(KindFile, "notes.txt", []byte("hello"))
+	infected := NewUpload(KindFile, "eicar.com", []byte(eicar))
+
+	Context("command", func() {
+		var dir string
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+		})
+
+		script := func(body string) string {
+			path := filepath.Join(dir, "scan.sh")
+			Expect(os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0700)).To(Succeed())
+			return path
+		}
+
+		It("tells clean and flagged files by the exit code", func() {
+			s, err := New(script(`grep -q EICAR "$1" || exit 0; echo "$1: Eicar-Signature FOUND"; exit 1`))
+			Expect(err).ToNot(HaveOccurred())
+
+			v, err := s.Scan(context.Background(), clean)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.Clean).To(BeTrue())
+
+			v, err = s.Scan(context.Background(), infected)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.Clean).To(BeFalse())
+			Expect(v.Reason).To(Equal("eicar.com: Eicar-Signature FOUND"))
+		})
+
+		It("passes the upload details in the environment", func() {
+			s, err := New(script(`[ "$LOCALAI_UPLOAD_KIND" = image ] && [ "$LOCALAI_UPLOAD_CONTENT_TYPE" = image/png ] || exit 1`))
+			Expect(err).ToNot(HaveOccurred())
+
+			v, err := s.Scan(context.Background(), NewUpload(KindImage, "a.png", []byte("\x89PNG\r\n\x1a\n0000")))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.Clean).To(BeTrue())
+		})
+
+		It("fails on the other exit codes", func() {
+			s, err := New(script(`echo "cannot connect"; exit 2`))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = s.Scan(context.Background(), clean)
+			Expect(err).To(MatchError(ContainSubstring("cannot connect")))
+		})
+	})
+
+	Context("HTTP", func() {
+		It("tells clean and flagged files by the status", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("X-Upload-Name")).ToNot(BeEmpty())
+				body, _ := io.ReadAll(r.Body)
+				switch {
+				case strings.Contains(string(body), "EICAR"):
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte("Eicar-Signature"))
+				case string(body) == "fail":
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			defer server.Close()
+
+			s, err := New(server.URL + "/scan")
+			Expect(err).ToNot(HaveOccurred())
+
+			v, err := s.Scan(context.Background(), clean)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.Clean).To(BeTrue())
+
+			v, err = s.Scan(context.Background(), infected)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal(Verdict{Reason: "Eicar-Signature"}))
+
+			_, err = s.Scan(context.Background(), NewUpload(KindFile, "f", []byte("fail")))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("ICAP", func() {
+		It("tells clean and flagged files by the status", func() {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer l.Close()
+
+			go func() {
+				defer GinkgoRecover()
+				for {
+					conn, err := l.Accept()
+					if err != nil {
+						return
+					}
+					r := bufio.NewReader(conn)
+					request := ""
+					for !strings.HasSuffix(request, "0\r\n\r\n") {
+						line, err := r.ReadString('\n')
+						if err != nil {
+							break
+						}
+						request += line
+					}
+					if strings.Contains(request, "EICAR") {
+						conn.Write([]byte("ICAP/1.0 200 OK\r\nX-Infection-Found: Type=0; Resolution=2; Threat=Eicar-Signature;\r\nEncapsulated: null-body=0\r\n\r\n"))
+					} else {
+						conn.Write([]byte("ICAP/1.0 204 No Content\r\n\r\n"))
+					}
+					conn.Close()
+				}
+			}()
+
+			s, err := New("icap://" + l.Addr().String() + "/avscan")
+			Expect(err).ToNot(HaveOccurred())
+
+			v, err := s.Scan(context.Background(), clean)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.Clean).To(BeTrue())
+
+			v, err = s.Scan(context.Background(), infected)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.Clean).To(BeFalse())
+			Expect(v.Reason).To(ContainSubstring("Eicar-Signature"))
+		})
+	})
+
+	Context("Quarantine", func() {
+		It("keeps the file and its description", func() {
+			dir := GinkgoT().TempDir()
+			path, err := Quarantine(dir, infected, Verdict{Reason: "Eicar-Signature"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filepath.Dir(path)).To(Equal(dir))
+
+			data, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(eicar))
+
+			info, err := os.ReadFile(path + ".json")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(info)).To(ContainSubstring(`"reason": "Eicar-Signature"`))
+		})
+	})
+})
@@ -0,0 +1,131 @@
+package sound
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The audio is handled as PCM16: little-endian signed 16-bit samples, mono
+
+// BytesToInt16 returns the samples of PCM16 audio, the last byte of an odd length being dropped
+func BytesToInt16(data []byte) []int16 {
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	return samples
+}
+
+// Int16ToBytes returns the PCM16 audio of the samples
+func Int16ToBytes(samples []int16) []byte {
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+	return data
+}
+
+// Resample converts the samples from a sample rate to another, interpolating them linearly
+func Resample(samples []int16, from, to int) []int16 {
+	if from == to || from <= 0 || to <= 0 || len(samples) == 0 {
+		return samples
+	}
+	resampled := make([]int16, int(int64(len(samples))*int64(to)/int64(from)))
+	ratio := float64(from) / float64(to)
+	for i := range resampled {
+		position := float64(i) * ratio
+		j := int(position)
+		if j+1 >= len(samples) {
+			resampled[i] = samples[len(samples)-1]
+			continue
+		}
+		fraction := position - float64(j)
+		resampled[i] = int16(math.Round(float64(samples[j])*(1-fraction) + float64(samples[j+1])*fraction))
+	}
+	return resampled
+}
+
+// EncodeWAV returns the WAV file of the samples
+func EncodeWAV(samples []int16, sampleRate int) []byte {
+	data := Int16ToBytes(samples)
+	return append(wavHeader(sampleRate, uint32(len(data))), data...)
+}
+
+// WAVStreamHeader returns the header of a WAV file streamed while its PCM16 samples are generated: its sizes are
+// unknown, and set to the largest value, read by the players as the end of the stream
+func WAVStreamHeader(sampleRate int) []byte {
+	return wavHeader(sampleRate, math.MaxUint32)
+}
+
+func wavHeader(sampleRate int, dataSize uint32) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(min(36+uint64(dataSize), math.MaxUint32)))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []any{
+		uint32(16),             // size of the fmt chunk
+		uint16(1),              // PCM
+		uint16(1),              // channels
+		uint32(sampleRate),     // sample rate
+		uint32(2 * sampleRate), // byte rate
+		uint16(2),              // block align
+		uint16(16),             // bits per sample
+	} {
+		binary.Write(buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, dataSize)
+	return buf.Bytes()
+}
+
+// DecodeWAV returns the samples and the sample rate of a PCM16 WAV file, its channels being mixed down to mono
+func DecodeWAV(wav []byte) ([]int16, int, error) {
+	if len(wav) < 12 || string(wav[:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+	channels, sampleRate := 0, 0
+	for chunk := wav[12:]; len(chunk) >= 8; {
+		id, size := string(chunk[:4]), int(binary.LittleEndian.Uint32(chunk[4:8]))
+		body := chunk[8:]
+		// the size of the data chunk of the streamed files is unknown
+		if size > len(body) || (id == "data" && size == 0) {
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, fmt.Errorf("invalid fmt chunk")
+			}
+			format, bits := binary.LittleEndian.Uint16(body), binary.LittleEndian.Uint16(body[14:])
+			if (format != 1 && format != 0xFFFE) || bits != 16 {
+				return nil, 0, fmt.Errorf("unsupported WAV format %d with %d bits per sample, only PCM16 is supported", format, bits)
+			}
+			channels, sampleRate = int(binary.LittleEndian.Uint16(body[2:])), int(binary.LittleEndian.Uint32(body[4:]))
+		case "data":
+			if channels == 0 {
+				return nil, 0, fmt.Errorf("the data chunk comes before the fmt chunk")
+			}
+			return mixDown(BytesToInt16(body[:size]), channels), sampleRate, nil
+		}
+		// the chunks are aligned on 2 bytes
+		chunk = body[min(size+size%2, len(body)):]
+	}
+	return nil, 0, fmt.Errorf("no data chunk in the WAV file")
+}
+
+func mixDown(samples []int16, channels int) []int16 {
+	if channels <= 1 {
+		return samples
+	}
+	mono := make([]int16, len(samples)/channels)
+	for i := range mono {
+		sum := 0
+		for c := 0; c < channels; c++ {
+			sum += int(samples[i*channels+c])
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
@@ -0,0 +1,13 @@
+package sound_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestSound(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LocalAI sound test")
+}
@@ -0,0 +1,71 @@
+package sound_test
+
+import (
+	"math"
+	"time"
+
+	. "github.com/mudler/LocalAI/pkg/sound"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// tone returns a sine wave of amplitude level during d at 16kHz
+func tone(level float64, d time.Duration) []int16 {
+	samples := make([]int16, 16000*d/time.Second)
+	for i := range samples {
+		samples[i] = int16(level * math.MaxInt16 * math.Sin(2*math.Pi*440*float64(i)/16000))
+	}
+	return samples
+}
+
+var _ = Describe("Sound", func() {
+	It("encodes and decodes the WAV files", func() {
+		samples := []int16{0, 1, -1, math.MaxInt16, math.MinInt16}
+		decoded, sampleRate, err := DecodeWAV(EncodeWAV(samples, 24000))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded).To(Equal(samples))
+		Expect(sampleRate).To(Equal(24000))
+
+		_, _, err = DecodeWAV([]byte("not a wav file"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("decodes the WAV files streamed with an unknown size", func() {
+		samples := []int16{0, 1, -1, math.MaxInt16, math.MinInt16}
+		decoded, sampleRate, err := DecodeWAV(append(WAVStreamHeader(22050), Int16ToBytes(samples)...))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded).To(Equal(samples))
+		Expect(sampleRate).To(Equal(22050))
+		// the header only differs by the sizes
+		Expect(WAVStreamHeader(22050)).To(HaveLen(44))
+		Expect(WAVStreamHeader(22050)[8:40]).To(Equal(EncodeWAV(nil, 22050)[8:40]))
+	})
+
+	It("resamples the audio", func() {
+		Expect(Resample([]int16{0, 100, 200, 300}, 16000, 32000)).To(Equal([]int16{0, 50, 100, 150, 200, 250, 300, 300}))
+		Expect(Resample([]int16{0, 100, 200, 300}, 16000, 8000)).To(Equal([]int16{0, 200}))
+		Expect(Resample(tone(0.5, time.Second), 16000, 24000)).To(HaveLen(24000))
+	})
+
+	It("detects the speech", func() {
+		vad := &VAD{Threshold: 0.5, SilenceDuration: 200 * time.Millisecond, SampleRate: 16000}
+		Expect(vad.Process(tone(0, 500*time.Millisecond))).To(BeEmpty())
+
+		// the audio is processed in chunks which aren't aligned on the frames
+		events := []VADEvent{}
+		audio := append(tone(0.3, time.Second), tone(0.001, 500*time.Millisecond)...)
+		for len(audio) > 0 {
+			n := min(1000, len(audio))
+			events = append(events, vad.Process(audio[:n])...)
+			audio = audio[n:]
+		}
+		Expect(events).To(Equal([]VADEvent{
+			{Speaking: true, At: 500 * time.Millisecond},
+			{Speaking: false, At: 1500 * time.Millisecond},
+		}))
+
+		vad.Reset()
+		Expect(vad.Process(tone(0.3, 100*time.Millisecond))).To(Equal([]VADEvent{{Speaking: true, At: 0}}))
+	})
+})
@@ -0,0 +1,85 @@
+package sound
+
+import (
+	"math"
+	"time"
+)
+
+// vadFrame is the duration of the frames whose level is measured by the VAD
+const vadFrame = 20 * time.Millisecond
+
+// VAD detects the speech in a stream of audio from its level: the speech starts with a frame louder than the
+// threshold, and stops after the silence duration without such a frame
+type VAD struct {
+	// Threshold is the activation of the VAD between 0 and 1, a frame is speech when its RMS level is above a tenth
+	// of it: 0.5 detects the speech at a normal volume
+	Threshold       float64
+	SilenceDuration time.Duration
+	SampleRate      int
+
+	frame    []int16
+	position int // the samples processed
+	speaking bool
+	start    int // the sample where the speech started
+	silence  int // the samples of silence since the last frame of speech
+}
+
+// VADEvent is the start or the stop of the speech, at a time from the start of the audio
+type VADEvent struct {
+	Speaking bool
+	At       time.Duration
+}
+
+// Process detects the speech in the samples following the ones already processed
+func (v *VAD) Process(samples []int16) []VADEvent {
+	events := []VADEvent{}
+	frameSize := int(int64(v.SampleRate) * int64(vadFrame) / int64(time.Second))
+	if frameSize == 0 {
+		return events
+	}
+	for len(samples) > 0 {
+		n := min(frameSize-len(v.frame), len(samples))
+		v.frame = append(v.frame, samples[:n]...)
+		samples = samples[n:]
+		if len(v.frame) < frameSize {
+			break
+		}
+
+		speech := rms(v.frame) > v.Threshold/10
+		v.frame = v.frame[:0]
+		v.position += frameSize
+		switch {
+		case speech && !v.speaking:
+			v.speaking, v.start, v.silence = true, v.position-frameSize, 0
+			events = append(events, VADEvent{Speaking: true, At: v.duration(v.start)})
+		case speech:
+			v.silence = 0
+		case v.speaking:
+			v.silence += frameSize
+			if v.duration(v.silence) >= v.SilenceDuration {
+				v.speaking = false
+				events = append(events, VADEvent{Speaking: false, At: v.duration(v.position - v.silence)})
+			}
+		}
+	}
+	return events
+}
+
+// Reset forgets the audio processed, the times of the next events start from the next samples
+func (v *VAD) Reset() {
+	v.frame, v.position, v.speaking, v.start, v.silence = v.frame[:0], 0, false, 0, 0
+}
+
+func (v *VAD) duration(samples int) time.Duration {
+	return time.Duration(int64(samples) * int64(time.Second) / int64(v.SampleRate))
+}
+
+// rms returns the RMS level of the samples, between 0 and 1
+func rms(samples []int16) float64 {
+	sum := 0.0
+	for _, s := range samples {
+		level := float64(s) / math.MaxInt16
+		sum += level * level
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
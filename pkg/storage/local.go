@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/utils"
+)
+
+// Local stores the artifacts in a directory, served by LocalAI under urlPath
+type Local struct {
+	dir     string
+	urlPath string
+}
+
+func NewLocal(dir, urlPath string) *Local {
+	// VerifyPath expects an absolute directory
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return &Local{
+		dir:     dir,
+		urlPath: strings.TrimSuffix(urlPath, "/"),
+	}
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.dir, key)
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if err := utils.VerifyPath(key, l.dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path(key)), 0750); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path(key), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := utils.VerifyPath(key, l.dir); err != nil {
+		return nil, err
+	}
+	return os.Open(l.path(key))
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := utils.VerifyPath(key, l.dir); err != nil {
+		return err
+	}
+	return os.Remove(l.path(key))
+}
+
+func (l *Local) URL(key string) (string, error) {
+	return l.urlPath + "/" + key, nil
+}
@@ -0,0 +1,67 @@
+package storage_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/mudler/LocalAI/pkg/storage"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Local storage", func() {
+	var dir string
+	var s *Local
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		s = NewLocal(dir, "/generated-images/")
+	})
+
+	It("stores, reads and deletes files", func() {
+		Expect(s.Put(context.Background(), "a.png", strings.NewReader("data"), 4)).To(Succeed())
+		Expect(filepath.Join(dir, "a.png")).To(BeAnExistingFile())
+
+		r, err := s.Get(context.Background(), "a.png")
+		Expect(err).ToNot(HaveOccurred())
+		data, err := io.ReadAll(r)
+		r.Close()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("data"))
+
+		Expect(s.Delete(context.Background(), "a.png")).To(Succeed())
+		Expect(filepath.Join(dir, "a.png")).ToNot(BeAnExistingFile())
+	})
+
+	It("returns URLs served by LocalAI", func() {
+		Expect(s.URL("a.png")).To(Equal("/generated-images/a.png"))
+	})
+
+	It("rejects keys outside of the directory", func() {
+		Expect(s.Put(context.Background(), "../a.png", strings.NewReader("data"), 4)).ToNot(Succeed())
+		_, err := s.Get(context.Background(), "../a.png")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("PutFile", func() {
+		It("moves the file to the storage", func() {
+			src := filepath.Join(GinkgoT().TempDir(), "b.png")
+			Expect(os.WriteFile(src, []byte("data"), 0600)).To(Succeed())
+
+			Expect(PutFile(context.Background(), s, "b.png", src)).To(Succeed())
+			Expect(src).ToNot(BeAnExistingFile())
+			Expect(filepath.Join(dir, "b.png")).To(BeAnExistingFile())
+		})
+
+		It("leaves the files already in place", func() {
+			src := filepath.Join(dir, "c.png")
+			Expect(os.WriteFile(src, []byte("data"), 0600)).To(Succeed())
+
+			Expect(PutFile(context.Background(), s, "c.png", src)).To(Succeed())
+			Expect(src).To(BeAnExistingFile())
+		})
+	})
+})
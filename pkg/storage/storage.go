@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Storage keeps the artifacts generated or received by LocalAI (images, audio, uploads)
+type Storage interface {
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL returns where clients download key from, relative URLs are served by LocalAI
+	URL(key string) (string, error)
+}
+
+// PutFile moves the local file at path to the storage under key, the file is removed once stored.
+// Files already in place in a local storage are left untouched.
+func PutFile(ctx context.Context, s Storage, key, path string) error {
+	if l, ok := s.(*Local); ok {
+		if same, _ := filepath.Abs(path); same == l.path(key) {
+			return nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, key, f, fi.Size()); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
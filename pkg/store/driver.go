@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	grpc "github.com/mudler/LocalAI/pkg/grpc"
+)
+
+const (
+	LocalDriver    = "local"
+	QdrantDriver   = "qdrant"
+	MilvusDriver   = "milvus"
+	PGVectorDriver = "pgvector"
+)
+
+// Store is the common interface implemented by the internal local-store backend
+// and by the external vector databases. Like the gRPC API, everything is in columnar
+// format so that keys[i] is associated with values[i].
+type Store interface {
+	Set(ctx context.Context, keys [][]float32, values [][]byte) error
+	Delete(ctx context.Context, keys [][]float32) error
+	Get(ctx context.Context, keys [][]float32) ([][]float32, [][]byte, error)
+	Find(ctx context.Context, key []float32, topk int) ([][]float32, [][]byte, []float32, error)
+}
+
+// Config describes how to reach an external vector database used as a store
+type Config struct {
+	// Driver is one of qdrant, milvus or pgvector. Empty or "local" uses the internal store
+	Driver string `json:"driver" yaml:"driver"`
+
+	// URL of the Qdrant or Milvus REST API (e.g. http://localhost:6333)
+	URL    string `json:"url,omitempty" yaml:"url,omitempty"`
+	APIKey string `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+
+	// Collection (Qdrant, Milvus) or table (pgvector) holding the vectors.
+	// Defaults to the store name
+	Collection string `json:"collection,omitempty" yaml:"collection,omitempty"`
+
+	// DSN is the PostgreSQL connection string used by pgvector
+	DSN string `json:"dsn,omitempty" yaml:"dsn,omitempty"`
+
+	// EmbeddingModel is the local model used to compute embeddings when
+	// the store is used by assistants (file_search)
+	EmbeddingModel string `json:"embedding_model,omitempty" yaml:"embedding_model,omitempty"`
+}
+
+func (c Config) IsExternal() bool {
+	return c.Driver != "" && c.Driver != LocalDriver
+}
+
+// New returns a Store for the external vector database described by the config
+func New(name string, c Config) (Store, error) {
+	if c.Collection == "" {
+		c.Collection = name
+	}
+
+	switch c.Driver {
+	case QdrantDriver:
+		return NewQdrant(c)
+	case MilvusDriver:
+		return NewMilvus(c)
+	case PGVectorDriver:
+		return NewPGVector(c)
+	}
+
+	return nil, fmt.Errorf("unknown store driver: %q", c.Driver)
+}
+
+type localStore struct {
+	backend grpc.Backend
+}
+
+// NewLocalStore wraps the local-store gRPC backend into a Store
+func NewLocalStore(b grpc.Backend) Store {
+	return &localStore{backend: b}
+}
+
+func (l *localStore) Set(ctx context.Context, keys [][]float32, values [][]byte) error {
+	return SetCols(ctx, l.backend, keys, values)
+}
+
+func (l *localStore) Delete(ctx context.Context, keys [][]float32) error {
+	return DeleteCols(ctx, l.backend, keys)
+}
+
+func (l *localStore) Get(ctx context.Context, keys [][]float32) ([][]float32, [][]byte, error) {
+	return GetCols(ctx, l.backend, keys)
+}
+
+func (l *localStore) Find(ctx context.Context, key []float32, topk int) ([][]float32, [][]byte, []float32, error) {
+	return Find(ctx, l.backend, key, topk)
+}
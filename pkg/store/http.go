@@ -0,0 +1,76 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// keyID derives a stable identifier from a key, so that setting the same key
+// twice updates the existing entry as it happens with the internal store
+func keyID(key []float32) string {
+	buf := make([]byte, 4*len(key))
+	for i, f := range key {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(f))
+	}
+	return uuid.NewSHA1(uuid.NameSpaceOID, buf).String()
+}
+
+func keyIDs(keys [][]float32) []string {
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = keyID(k)
+	}
+	return ids
+}
+
+// doJSON sends data as a JSON body and decodes the JSON response into out (if not nil)
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, data, out interface{}) (int, error) {
+	var body io.Reader
+	if data != nil {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("request to %s failed with status code %d: %s", url, resp.StatusCode, string(respBody))
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed decoding response from %s: %w", url, err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Milvus talks to a Milvus instance over its RESTful API (v2).
+// The collection is created on the first write with a VarChar primary key,
+// a vector field and the value stored as a dynamic field.
+type Milvus struct {
+	baseURL    string
+	collection string
+	headers    map[string]string
+	client     *http.Client
+
+	sync.Mutex
+	ready bool
+}
+
+type milvusResponse struct {
+	Code    int                      `json:"code"`
+	Message string                   `json:"message"`
+	Data    []map[string]interface{} `json:"data"`
+}
+
+type milvusHasResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Has bool `json:"has"`
+	} `json:"data"`
+}
+
+func NewMilvus(c Config) (*Milvus, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("milvus: url is required")
+	}
+
+	headers := map[string]string{}
+	if c.APIKey != "" {
+		headers["Authorization"] = "Bearer " + c.APIKey
+	}
+
+	return &Milvus{
+		baseURL:    strings.TrimSuffix(c.URL, "/"),
+		collection: c.Collection,
+		headers:    headers,
+		client:     &http.Client{},
+	}, nil
+}
+
+// call posts to a Milvus endpoint. Milvus replies with 200 also on failures,
+// reporting errors with a non-zero code in the body
+func (m *Milvus) call(ctx context.Context, path string, data interface{}) (*milvusResponse, error) {
+	res := &milvusResponse{}
+	if _, err := doJSON(ctx, m.client, http.MethodPost, m.baseURL+path, m.headers, data, res); err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, fmt.Errorf("milvus: %s failed (code %d): %s", path, res.Code, res.Message)
+	}
+	return res, nil
+}
+
+func (m *Milvus) ensureCollection(ctx context.Context, size int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.ready {
+		return nil
+	}
+
+	has := milvusHasResponse{}
+	if _, err := doJSON(ctx, m.client, http.MethodPost, m.baseURL+"/v2/vectordb/collections/has", m.headers, map[string]interface{}{
+		"collectionName": m.collection,
+	}, &has); err != nil {
+		return err
+	}
+	if has.Code != 0 {
+		return fmt.Errorf("milvus: failed checking collection %s: %s", m.collection, has.Message)
+	}
+
+	if !has.Data.Has {
+		_, err := m.call(ctx, "/v2/vectordb/collections/create", map[string]interface{}{
+			"collectionName":   m.collection,
+			"dimension":        size,
+			"metricType":       "COSINE",
+			"idType":           "VarChar",
+			"primaryFieldName": "id",
+			"vectorFieldName":  "vector",
+			"params": map[string]interface{}{
+				"max_length": 64,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	m.ready = true
+	return nil
+}
+
+func (m *Milvus) Set(ctx context.Context, keys [][]float32, values [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if len(keys) != len(values) {
+		return fmt.Errorf("milvus: keys and values must have the same length")
+	}
+
+	if err := m.ensureCollection(ctx, len(keys[0])); err != nil {
+		return err
+	}
+
+	data := make([]map[string]interface{}, len(keys))
+	for i, k := range keys {
+		data[i] = map[string]interface{}{
+			"id":     keyID(k),
+			"vector": k,
+			"value":  string(values[i]),
+		}
+	}
+
+	_, err := m.call(ctx, "/v2/vectordb/entities/upsert", map[string]interface{}{
+		"collectionName": m.collection,
+		"data":           data,
+	})
+	return err
+}
+
+func (m *Milvus) Delete(ctx context.Context, keys [][]float32) error {
+	ids := keyIDs(keys)
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = strconv.Quote(id)
+	}
+
+	_, err := m.call(ctx, "/v2/vectordb/entities/delete", map[string]interface{}{
+		"collectionName": m.collection,
+		"filter":         fmt.Sprintf("id in [%s]", strings.Join(quoted, ",")),
+	})
+	return err
+}
+
+func (m *Milvus) Get(ctx context.Context, keys [][]float32) ([][]float32, [][]byte, error) {
+	res, err := m.call(ctx, "/v2/vectordb/entities/get", map[string]interface{}{
+		"collectionName": m.collection,
+		"id":             keyIDs(keys),
+		"outputFields":   []string{"vector", "value"},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ks, vs, _ := milvusColumns(res.Data)
+	return ks, vs, nil
+}
+
+func (m *Milvus) Find(ctx context.Context, key []float32, topk int) ([][]float32, [][]byte, []float32, error) {
+	res, err := m.call(ctx, "/v2/vectordb/entities/search", map[string]interface{}{
+		"collectionName": m.collection,
+		"data":           [][]float32{key},
+		"annsField":      "vector",
+		"limit":          topk,
+		"outputFields":   []string{"vector", "value"},
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ks, vs, sims := milvusColumns(res.Data)
+	return ks, vs, sims, nil
+}
+
+func milvusColumns(rows []map[string]interface{}) ([][]float32, [][]byte, []float32) {
+	ks := make([][]float32, len(rows))
+	vs := make([][]byte, len(rows))
+	sims := make([]float32, len(rows))
+	for i, r := range rows {
+		if vec, ok := r["vector"].([]interface{}); ok {
+			ks[i] = make([]float32, len(vec))
+			for j, f := range vec {
+				if ff, ok := f.(float64); ok {
+					ks[i][j] = float32(ff)
+				}
+			}
+		}
+		if v, ok := r["value"].(string); ok {
+			vs[i] = []byte(v)
+		}
+		if d, ok := r["distance"].(float64); ok {
+			sims[i] = float32(d)
+		}
+	}
+	return ks, vs, sims
+}
@@ -0,0 +1,153 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+
+	"github.com/mudler/LocalAI/pkg/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var milvusFilterIDs = regexp.MustCompile(`"([^"]+)"`)
+
+// fakeMilvus serves the endpoints of the Milvus RESTful API (v2) used by the driver
+type fakeMilvus struct {
+	sync.Mutex
+	created map[string]interface{}
+	rows    map[string]map[string]interface{}
+	auth    []string
+	// failure is the error code replied to the writes, 0 to succeed
+	failure int
+}
+
+func (f *fakeMilvus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+
+	body := map[string]interface{}{}
+	json.NewDecoder(r.Body).Decode(&body)
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": data})
+	}
+	if body["collectionName"] != "docs" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 100, "message": "collection not found"})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v2/vectordb/collections/has":
+		reply(map[string]interface{}{"has": f.created != nil})
+	case "/v2/vectordb/collections/create":
+		f.created = body
+		reply(map[string]interface{}{})
+	case "/v2/vectordb/entities/upsert":
+		if f.failure != 0 {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": f.failure, "message": "upsert failed"})
+			return
+		}
+		for _, row := range body["data"].([]interface{}) {
+			row := row.(map[string]interface{})
+			f.rows[row["id"].(string)] = row
+		}
+		reply(map[string]interface{}{})
+	case "/v2/vectordb/entities/delete":
+		for _, id := range milvusFilterIDs.FindAllStringSubmatch(body["filter"].(string), -1) {
+			delete(f.rows, id[1])
+		}
+		reply(map[string]interface{}{})
+	case "/v2/vectordb/entities/get":
+		found := []map[string]interface{}{}
+		for _, id := range body["id"].([]interface{}) {
+			if row, ok := f.rows[id.(string)]; ok {
+				found = append(found, row)
+			}
+		}
+		reply(found)
+	case "/v2/vectordb/entities/search":
+		limit := int(body["limit"].(float64))
+		found := []map[string]interface{}{}
+		for _, row := range f.rows {
+			if len(found) < limit {
+				found = append(found, map[string]interface{}{"vector": row["vector"], "value": row["value"], "distance": 0.75})
+			}
+		}
+		reply(found)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var _ = Describe("Milvus", func() {
+	var (
+		fake   *fakeMilvus
+		server *httptest.Server
+		m      store.Store
+	)
+
+	BeforeEach(func() {
+		fake = &fakeMilvus{rows: map[string]map[string]interface{}{}}
+		server = httptest.NewServer(fake)
+		var err error
+		m, err = store.New("docs", store.Config{Driver: store.MilvusDriver, URL: server.URL, APIKey: "secret"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("requires an url", func() {
+		_, err := store.New("docs", store.Config{Driver: store.MilvusDriver})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("creates the collection on the first write and stores the vectors", func() {
+		ctx := context.Background()
+		keys := [][]float32{{1, 0}, {0, 1}}
+		Expect(m.Set(ctx, keys, [][]byte{[]byte("a"), []byte("b")})).To(Succeed())
+		Expect(fake.created).To(HaveKeyWithValue("dimension", float64(2)))
+		Expect(fake.created).To(HaveKeyWithValue("metricType", "COSINE"))
+		Expect(fake.rows).To(HaveLen(2))
+		Expect(fake.auth).To(HaveEach("Bearer secret"))
+
+		ks, vs, err := m.Get(ctx, [][]float32{{0, 1}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(Equal([][]float32{{0, 1}}))
+		Expect(vs).To(Equal([][]byte{[]byte("b")}))
+
+		ks, vs, sims, err := m.Find(ctx, []float32{1, 0}, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(HaveLen(1))
+		Expect(vs).To(HaveLen(1))
+		Expect(sims).To(Equal([]float32{0.75}))
+	})
+
+	It("deletes the vectors", func() {
+		ctx := context.Background()
+		Expect(m.Set(ctx, [][]float32{{1, 2}, {3, 4}}, [][]byte{[]byte("a"), []byte("b")})).To(Succeed())
+		Expect(m.Delete(ctx, [][]float32{{1, 2}})).To(Succeed())
+
+		ks, _, err := m.Get(ctx, [][]float32{{1, 2}, {3, 4}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(Equal([][]float32{{3, 4}}))
+	})
+
+	It("reports the errors replied with a success status", func() {
+		fake.failure = 1100
+		err := m.Set(context.Background(), [][]float32{{1, 2}}, [][]byte{[]byte("a")})
+		Expect(err).To(MatchError(ContainSubstring("upsert failed")))
+	})
+
+	It("reports the missing collections", func() {
+		other, err := store.New("other", store.Config{Driver: store.MilvusDriver, URL: server.URL})
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = other.Get(context.Background(), [][]float32{{1, 2}})
+		Expect(err).To(MatchError(ContainSubstring("collection not found")))
+	})
+})
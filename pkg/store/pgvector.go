@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+var pgTableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// PGVector stores the vectors in a PostgreSQL table using the pgvector extension.
+// The table is created on the first write.
+type PGVector struct {
+	dsn   string
+	table string
+
+	sync.Mutex
+	db    *sql.DB
+	ready bool
+}
+
+func NewPGVector(c Config) (*PGVector, error) {
+	if c.DSN == "" {
+		return nil, fmt.Errorf("pgvector: dsn is required")
+	}
+
+	table := strings.ReplaceAll(c.Collection, "-", "_")
+	if !pgTableName.MatchString(table) {
+		return nil, fmt.Errorf("pgvector: invalid table name %q", c.Collection)
+	}
+
+	return &PGVector{
+		dsn:   c.DSN,
+		table: table,
+	}, nil
+}
+
+func (p *PGVector) conn() (*sql.DB, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.db != nil {
+		return p.db, nil
+	}
+
+	db, err := sql.Open("pgx", p.dsn)
+	if err != nil {
+		return nil, err
+	}
+	p.db = db
+	return db, nil
+}
+
+func (p *PGVector) ensureTable(ctx context.Context, db *sql.DB, size int) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.ready {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS vector"); err != nil {
+		return fmt.Errorf("pgvector: failed enabling the vector extension: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id TEXT PRIMARY KEY, embedding vector(%d), value BYTEA)",
+		p.table, size)); err != nil {
+		return fmt.Errorf("pgvector: failed creating table %s: %w", p.table, err)
+	}
+
+	p.ready = true
+	return nil
+}
+
+func (p *PGVector) Set(ctx context.Context, keys [][]float32, values [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if len(keys) != len(values) {
+		return fmt.Errorf("pgvector: keys and values must have the same length")
+	}
+
+	db, err := p.conn()
+	if err != nil {
+		return err
+	}
+
+	if err := p.ensureTable(ctx, db, len(keys[0])); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (id, embedding, value) VALUES ($1, $2::vector, $3) ON CONFLICT (id) DO UPDATE SET embedding = EXCLUDED.embedding, value = EXCLUDED.value",
+		p.table)
+	for i, k := range keys {
+		if _, err := tx.ExecContext(ctx, query, keyID(k), formatVector(k), values[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *PGVector) Delete(ctx context.Context, keys [][]float32) error {
+	db, err := p.conn()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", p.table)
+	for _, k := range keys {
+		if _, err := db.ExecContext(ctx, query, keyID(k)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PGVector) Get(ctx context.Context, keys [][]float32) ([][]float32, [][]byte, error) {
+	db, err := p.conn()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := fmt.Sprintf("SELECT embedding::text, value FROM %s WHERE id = $1", p.table)
+
+	var ks [][]float32
+	var vs [][]byte
+	for _, k := range keys {
+		var embedding string
+		var value []byte
+		err := db.QueryRowContext(ctx, query, keyID(k)).Scan(&embedding, &value)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		vec, err := parseVector(embedding)
+		if err != nil {
+			return nil, nil, err
+		}
+		ks = append(ks, vec)
+		vs = append(vs, value)
+	}
+
+	return ks, vs, nil
+}
+
+func (p *PGVector) Find(ctx context.Context, key []float32, topk int) ([][]float32, [][]byte, []float32, error) {
+	db, err := p.conn()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT embedding::text, value, 1 - (embedding <=> $1::vector) AS similarity FROM %s ORDER BY embedding <=> $1::vector LIMIT $2",
+		p.table), formatVector(key), topk)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	var ks [][]float32
+	var vs [][]byte
+	var sims []float32
+	for rows.Next() {
+		var embedding string
+		var value []byte
+		var similarity float64
+		if err := rows.Scan(&embedding, &value, &similarity); err != nil {
+			return nil, nil, nil, err
+		}
+
+		vec, err := parseVector(embedding)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		ks = append(ks, vec)
+		vs = append(vs, value)
+		sims = append(sims, float32(similarity))
+	}
+
+	return ks, vs, sims, rows.Err()
+}
+
+// formatVector encodes a key in the pgvector text format, e.g. [1,2,3]
+func formatVector(v []float32) string {
+	parts := make([]string, len(v))
+	for i, f := range v {
+		parts[i] = strconv.FormatFloat(float64(f), 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+func parseVector(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "[")
+	s = strings.TrimSuffix(s, "]")
+	if s == "" {
+		return []float32{}, nil
+	}
+
+	parts := strings.Split(s, ",")
+	v := make([]float32, len(parts))
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 32)
+		if err != nil {
+			return nil, fmt.Errorf("pgvector: invalid vector %q: %w", s, err)
+		}
+		v[i] = float32(f)
+	}
+	return v, nil
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakePGRow struct {
+	embedding string
+	value     []byte
+}
+
+// fakePG is a database/sql connector answering the statements of the pgvector driver from memory
+type fakePG struct {
+	sync.Mutex
+	statements []string
+	rows       map[string]fakePGRow
+}
+
+func (f *fakePG) Connect(context.Context) (driver.Conn, error) { return fakePGConn{f}, nil }
+func (f *fakePG) Driver() driver.Driver                        { return nil }
+
+type fakePGConn struct{ db *fakePG }
+
+func (c fakePGConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakePGConn) Close() error                        { return nil }
+func (c fakePGConn) Begin() (driver.Tx, error)           { return fakePGTx{}, nil }
+
+type fakePGTx struct{}
+
+func (fakePGTx) Commit() error   { return nil }
+func (fakePGTx) Rollback() error { return nil }
+
+func (c fakePGConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.Lock()
+	defer c.db.Unlock()
+	c.db.statements = append(c.db.statements, query)
+
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		c.db.rows[args[0].Value.(string)] = fakePGRow{embedding: args[1].Value.(string), value: args[2].Value.([]byte)}
+	case strings.HasPrefix(query, "DELETE"):
+		delete(c.db.rows, args[0].Value.(string))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakePGConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.Lock()
+	defer c.db.Unlock()
+	c.db.statements = append(c.db.statements, query)
+
+	if strings.Contains(query, "WHERE id = $1") {
+		rows := &fakePGRows{columns: []string{"embedding", "value"}}
+		if row, ok := c.db.rows[args[0].Value.(string)]; ok {
+			rows.values = [][]driver.Value{{row.embedding, row.value}}
+		}
+		return rows, nil
+	}
+
+	// the nearest neighbours by cosine similarity
+	key, err := parseVector(args[0].Value.(string))
+	if err != nil {
+		return nil, err
+	}
+	rows := &fakePGRows{columns: []string{"embedding", "value", "similarity"}}
+	for _, row := range c.db.rows {
+		vec, err := parseVector(row.embedding)
+		if err != nil {
+			return nil, err
+		}
+		rows.values = append(rows.values, []driver.Value{row.embedding, row.value, cosine(key, vec)})
+	}
+	sort.Slice(rows.values, func(i, j int) bool { return rows.values[i][2].(float64) > rows.values[j][2].(float64) })
+	if limit := int(args[1].Value.(int64)); len(rows.values) > limit {
+		rows.values = rows.values[:limit]
+	}
+	return rows, nil
+}
+
+type fakePGRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakePGRows) Columns() []string { return r.columns }
+func (r *fakePGRows) Close() error      { return nil }
+func (r *fakePGRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+var _ = Describe("PGVector", func() {
+	var (
+		fake *fakePG
+		p    *PGVector
+	)
+
+	BeforeEach(func() {
+		fake = &fakePG{rows: map[string]fakePGRow{}}
+		var err error
+		p, err = NewPGVector(Config{DSN: "postgres://localhost/db", Collection: "my-docs"})
+		Expect(err).ToNot(HaveOccurred())
+		p.db = sql.OpenDB(fake)
+	})
+
+	It("requires a dsn and a valid table name", func() {
+		_, err := NewPGVector(Config{Collection: "docs"})
+		Expect(err).To(HaveOccurred())
+		_, err = NewPGVector(Config{DSN: "postgres://localhost/db", Collection: "docs; DROP TABLE users"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("creates the table on the first write and stores the vectors", func() {
+		ctx := context.Background()
+		Expect(p.Set(ctx, [][]float32{{1, 0}, {0, 1}}, [][]byte{[]byte("a"), []byte("b")})).To(Succeed())
+		Expect(fake.statements[0]).To(Equal("CREATE EXTENSION IF NOT EXISTS vector"))
+		Expect(fake.statements[1]).To(Equal("CREATE TABLE IF NOT EXISTS my_docs (id TEXT PRIMARY KEY, embedding vector(2), value BYTEA)"))
+		Expect(fake.rows).To(HaveLen(2))
+
+		Expect(p.Set(ctx, [][]float32{{1, 1}}, [][]byte{[]byte("c")})).To(Succeed())
+		Expect(fake.statements).To(HaveLen(5))
+
+		ks, vs, err := p.Get(ctx, [][]float32{{0, 1}, {5, 5}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(Equal([][]float32{{0, 1}}))
+		Expect(vs).To(Equal([][]byte{[]byte("b")}))
+	})
+
+	It("finds the nearest vectors", func() {
+		ctx := context.Background()
+		Expect(p.Set(ctx, [][]float32{{1, 0}, {0, 1}, {1, 1}}, [][]byte{[]byte("a"), []byte("b"), []byte("c")})).To(Succeed())
+
+		ks, vs, sims, err := p.Find(ctx, []float32{1, 0.1}, 2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(Equal([][]float32{{1, 0}, {1, 1}}))
+		Expect(vs).To(Equal([][]byte{[]byte("a"), []byte("c")}))
+		Expect(sims).To(HaveLen(2))
+		Expect(sims[0]).To(BeNumerically(">", sims[1]))
+	})
+
+	It("deletes the vectors", func() {
+		ctx := context.Background()
+		Expect(p.Set(ctx, [][]float32{{1, 0}, {0, 1}}, [][]byte{[]byte("a"), []byte("b")})).To(Succeed())
+		Expect(p.Delete(ctx, [][]float32{{1, 0}})).To(Succeed())
+
+		ks, _, err := p.Get(ctx, [][]float32{{1, 0}, {0, 1}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(Equal([][]float32{{0, 1}}))
+	})
+})
+
+var _ = Describe("pgvector vectors", func() {
+	It("round trips the text format", func() {
+		Expect(formatVector([]float32{1, -0.5, 0.25})).To(Equal("[1,-0.5,0.25]"))
+		Expect(parseVector("[1,-0.5,0.25]")).To(Equal([]float32{1, -0.5, 0.25}))
+		Expect(parseVector("[]")).To(Equal([]float32{}))
+		_, err := parseVector("[1,x]")
+		Expect(err).To(HaveOccurred())
+	})
+})
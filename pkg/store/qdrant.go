@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Qdrant talks to a Qdrant instance over its REST API.
+// The collection is created on the first write, using the cosine distance.
+type Qdrant struct {
+	baseURL    string
+	collection string
+	headers    map[string]string
+	client     *http.Client
+
+	sync.Mutex
+	ready bool
+}
+
+type qdrantPoint struct {
+	ID      string                 `json:"id"`
+	Vector  []float32              `json:"vector,omitempty"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	Score   float32                `json:"score,omitempty"`
+}
+
+type qdrantPointsResponse struct {
+	Result []qdrantPoint `json:"result"`
+}
+
+func NewQdrant(c Config) (*Qdrant, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("qdrant: url is required")
+	}
+
+	headers := map[string]string{}
+	if c.APIKey != "" {
+		headers["api-key"] = c.APIKey
+	}
+
+	return &Qdrant{
+		baseURL:    strings.TrimSuffix(c.URL, "/"),
+		collection: c.Collection,
+		headers:    headers,
+		client:     &http.Client{},
+	}, nil
+}
+
+func (q *Qdrant) url(path string) string {
+	return fmt.Sprintf("%s/collections/%s%s", q.baseURL, url.PathEscape(q.collection), path)
+}
+
+func (q *Qdrant) ensureCollection(ctx context.Context, size int) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.ready {
+		return nil
+	}
+
+	status, err := doJSON(ctx, q.client, http.MethodGet, q.url(""), q.headers, nil, nil)
+	if err != nil && status != http.StatusNotFound {
+		return err
+	}
+
+	if status == http.StatusNotFound {
+		_, err = doJSON(ctx, q.client, http.MethodPut, q.url(""), q.headers, map[string]interface{}{
+			"vectors": map[string]interface{}{
+				"size":     size,
+				"distance": "Cosine",
+			},
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("qdrant: failed creating collection %s: %w", q.collection, err)
+		}
+	}
+
+	q.ready = true
+	return nil
+}
+
+func (q *Qdrant) Set(ctx context.Context, keys [][]float32, values [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if len(keys) != len(values) {
+		return fmt.Errorf("qdrant: keys and values must have the same length")
+	}
+
+	if err := q.ensureCollection(ctx, len(keys[0])); err != nil {
+		return err
+	}
+
+	points := make([]qdrantPoint, len(keys))
+	for i, k := range keys {
+		points[i] = qdrantPoint{
+			ID:      keyID(k),
+			Vector:  k,
+			Payload: map[string]interface{}{"value": string(values[i])},
+		}
+	}
+
+	_, err := doJSON(ctx, q.client, http.MethodPut, q.url("/points?wait=true"), q.headers, map[string]interface{}{
+		"points": points,
+	}, nil)
+	return err
+}
+
+func (q *Qdrant) Delete(ctx context.Context, keys [][]float32) error {
+	_, err := doJSON(ctx, q.client, http.MethodPost, q.url("/points/delete?wait=true"), q.headers, map[string]interface{}{
+		"points": keyIDs(keys),
+	}, nil)
+	return err
+}
+
+func (q *Qdrant) Get(ctx context.Context, keys [][]float32) ([][]float32, [][]byte, error) {
+	res := qdrantPointsResponse{}
+	_, err := doJSON(ctx, q.client, http.MethodPost, q.url("/points"), q.headers, map[string]interface{}{
+		"ids":          keyIDs(keys),
+		"with_payload": true,
+		"with_vector":  true,
+	}, &res)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ks, vs, _ := qdrantColumns(res.Result)
+	return ks, vs, nil
+}
+
+func (q *Qdrant) Find(ctx context.Context, key []float32, topk int) ([][]float32, [][]byte, []float32, error) {
+	res := qdrantPointsResponse{}
+	_, err := doJSON(ctx, q.client, http.MethodPost, q.url("/points/search"), q.headers, map[string]interface{}{
+		"vector":       key,
+		"limit":        topk,
+		"with_payload": true,
+		"with_vector":  true,
+	}, &res)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ks, vs, sims := qdrantColumns(res.Result)
+	return ks, vs, sims, nil
+}
+
+func qdrantColumns(points []qdrantPoint) ([][]float32, [][]byte, []float32) {
+	ks := make([][]float32, len(points))
+	vs := make([][]byte, len(points))
+	sims := make([]float32, len(points))
+	for i, p := range points {
+		ks[i] = p.Vector
+		if v, ok := p.Payload["value"].(string); ok {
+			vs[i] = []byte(v)
+		}
+		sims[i] = p.Score
+	}
+	return ks, vs, sims
+}
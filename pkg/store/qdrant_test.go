@@ -0,0 +1,169 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/mudler/LocalAI/pkg/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeQdrantPoint struct {
+	ID      string                 `json:"id"`
+	Vector  []float32              `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+	Score   float32                `json:"score,omitempty"`
+}
+
+// fakeQdrant serves the endpoints of the Qdrant REST API used by the driver, for the collection "docs"
+type fakeQdrant struct {
+	sync.Mutex
+	created map[string]interface{}
+	points  map[string]fakeQdrantPoint
+	apiKeys []string
+}
+
+func (f *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.apiKeys = append(f.apiKeys, r.Header.Get("api-key"))
+
+	body := map[string]json.RawMessage{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	ids := func(field string) []string {
+		ids := []string{}
+		json.Unmarshal(body[field], &ids)
+		return ids
+	}
+	reply := func(points []fakeQdrantPoint) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": points})
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/collections/docs":
+		if f.created == nil {
+			http.Error(w, `{"status":{"error":"Not found"}}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"result":{}}`))
+	case r.Method == http.MethodPut && r.URL.Path == "/collections/docs":
+		f.created = map[string]interface{}{}
+		json.Unmarshal(body["vectors"], &f.created)
+		w.Write([]byte(`{"result":true}`))
+	case r.Method == http.MethodPut && r.URL.Path == "/collections/docs/points":
+		points := []fakeQdrantPoint{}
+		json.Unmarshal(body["points"], &points)
+		for _, p := range points {
+			f.points[p.ID] = p
+		}
+		w.Write([]byte(`{"result":{}}`))
+	case r.URL.Path == "/collections/docs/points/delete":
+		for _, id := range ids("points") {
+			delete(f.points, id)
+		}
+		w.Write([]byte(`{"result":{}}`))
+	case r.URL.Path == "/collections/docs/points":
+		found := []fakeQdrantPoint{}
+		for _, id := range ids("ids") {
+			if p, ok := f.points[id]; ok {
+				found = append(found, p)
+			}
+		}
+		reply(found)
+	case r.URL.Path == "/collections/docs/points/search":
+		limit := 0
+		json.Unmarshal(body["limit"], &limit)
+		found := []fakeQdrantPoint{}
+		for _, p := range f.points {
+			if len(found) < limit {
+				p.Score = 0.5
+				found = append(found, p)
+			}
+		}
+		reply(found)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var _ = Describe("Qdrant", func() {
+	var (
+		fake   *fakeQdrant
+		server *httptest.Server
+		q      store.Store
+	)
+
+	BeforeEach(func() {
+		fake = &fakeQdrant{points: map[string]fakeQdrantPoint{}}
+		server = httptest.NewServer(fake)
+		var err error
+		q, err = store.New("docs", store.Config{Driver: store.QdrantDriver, URL: server.URL + "/", APIKey: "secret"})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("requires an url", func() {
+		_, err := store.New("docs", store.Config{Driver: store.QdrantDriver})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("creates the collection on the first write and stores the vectors", func() {
+		ctx := context.Background()
+		keys := [][]float32{{1, 0, 0}, {0, 1, 0}}
+		Expect(q.Set(ctx, keys, [][]byte{[]byte("a"), []byte("b")})).To(Succeed())
+		Expect(fake.created).To(Equal(map[string]interface{}{"size": float64(3), "distance": "Cosine"}))
+		Expect(fake.points).To(HaveLen(2))
+		Expect(fake.apiKeys).To(HaveEach("secret"))
+
+		ks, vs, err := q.Get(ctx, [][]float32{{0, 1, 0}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(Equal([][]float32{{0, 1, 0}}))
+		Expect(vs).To(Equal([][]byte{[]byte("b")}))
+
+		ks, vs, sims, err := q.Find(ctx, []float32{1, 0, 0}, 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(HaveLen(1))
+		Expect(vs).To(HaveLen(1))
+		Expect(sims).To(Equal([]float32{0.5}))
+	})
+
+	It("updates the entries of the keys set again", func() {
+		ctx := context.Background()
+		Expect(q.Set(ctx, [][]float32{{1, 2}}, [][]byte{[]byte("old")})).To(Succeed())
+		Expect(q.Set(ctx, [][]float32{{1, 2}}, [][]byte{[]byte("new")})).To(Succeed())
+		Expect(fake.points).To(HaveLen(1))
+
+		_, vs, err := q.Get(ctx, [][]float32{{1, 2}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vs).To(Equal([][]byte{[]byte("new")}))
+	})
+
+	It("deletes the vectors", func() {
+		ctx := context.Background()
+		Expect(q.Set(ctx, [][]float32{{1, 2}, {3, 4}}, [][]byte{[]byte("a"), []byte("b")})).To(Succeed())
+		Expect(q.Delete(ctx, [][]float32{{1, 2}})).To(Succeed())
+
+		ks, _, err := q.Get(ctx, [][]float32{{1, 2}, {3, 4}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ks).To(Equal([][]float32{{3, 4}}))
+	})
+
+	It("rejects keys and values of different lengths", func() {
+		Expect(q.Set(context.Background(), [][]float32{{1, 2}}, nil)).ToNot(Succeed())
+	})
+
+	It("reports the errors of the server", func() {
+		server.Close()
+		_, _, _, err := q.Find(context.Background(), []float32{1, 2}, 1)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -94,7 +94,7 @@ func (tc *TemplateCache) loadTemplateIfExists(templateType TemplateType, templat
 	}
 
 	// Parse the template
-	tmpl, err := template.New("prompt").Funcs(sprig.FuncMap()).Parse(dat)
+	tmpl, err := template.New("prompt").Funcs(sprig.FuncMap()).Funcs(funcMap).Parse(dat)
 	if err != nil {
 		return err
 	}
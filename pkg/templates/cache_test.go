@@ -57,6 +57,26 @@ var _ = Describe("TemplateCache", func() {
 				Expect(result).To(Equal(""))
 			})
 		})
+
+		Context("when post-processing a response", func() {
+			It("should strip the reasoning tags", func() {
+				result, err := templateCache.EvaluateTemplate(1, `{{ regexReplaceAll "(?s)<think>.*?</think>\\s*" .Response "" }}`, map[string]string{"Response": "<think>\nhmm\n</think>\nHello"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal("Hello"))
+			})
+
+			It("should extract the JSON", func() {
+				result, err := templateCache.EvaluateTemplate(1, "{{ extractJSON .Response }}", map[string]string{"Response": "Sure! ```json\n{\"a\": [1, 2]}\n```"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal(`{"a": [1, 2]}`))
+			})
+
+			It("should trim the trailing stop words", func() {
+				result, err := templateCache.EvaluateTemplate(1, "{{ trimSuffixes .StopWords .Response }}", map[string]any{"StopWords": []string{"</s>", "<|im_end|>"}, "Response": "Hello</s>\n<|im_end|>\n"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result).To(Equal("Hello"))
+			})
+		})
 	})
 
 	Describe("concurrency", func() {
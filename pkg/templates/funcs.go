@@ -0,0 +1,47 @@
+package templates
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+)
+
+// funcMap holds the functions available to the templates on top of the sprig ones
+var funcMap = template.FuncMap{
+	"extractJSON":  ExtractJSON,
+	"trimSuffixes": TrimSuffixes,
+	"variable":     Variable,
+}
+
+// ExtractJSON returns the first valid JSON object or array found in s, or s if there is none
+func ExtractJSON(s string) string {
+	for i, c := range s {
+		if c != '{' && c != '[' {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(s[i:]))
+		var v json.RawMessage
+		if err := dec.Decode(&v); err == nil {
+			return string(v)
+		}
+	}
+	return s
+}
+
+// TrimSuffixes removes from the end of s any of the suffixes, and the whitespace around them,
+// as long as one of them is found: e.g. the stop words the model emitted before stopping
+func TrimSuffixes(suffixes []string, s string) string {
+	for {
+		trimmed := strings.TrimRightFunc(s, func(r rune) bool { return r == ' ' || r == '\n' || r == '\t' || r == '\r' })
+		for _, suffix := range suffixes {
+			if suffix != "" && strings.HasSuffix(trimmed, suffix) {
+				trimmed = strings.TrimSuffix(trimmed, suffix)
+				break
+			}
+		}
+		if trimmed == s {
+			return s
+		}
+		s = trimmed
+	}
+}
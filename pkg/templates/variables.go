@@ -0,0 +1,63 @@
+package templates
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"sync"
+)
+
+// variables holds the prompt variables, the named text snippets (company facts, style guides...) the templates and
+// the requests reference: they are looked up when the prompts are built, so their updates apply to the next requests
+var variables = struct {
+	sync.RWMutex
+	values map[string]string
+}{values: map[string]string{}}
+
+// variableRe matches the references to the variables in the texts of the requests, written as in the templates
+var variableRe = regexp.MustCompile(`\{\{\s*variable\s+"([^"]*)"\s*\}\}`)
+
+// SetVariables replaces all the prompt variables
+func SetVariables(values map[string]string) {
+	variables.Lock()
+	defer variables.Unlock()
+	variables.values = maps.Clone(values)
+	if variables.values == nil {
+		variables.values = map[string]string{}
+	}
+}
+
+// Variables returns a copy of the prompt variables
+func Variables() map[string]string {
+	variables.RLock()
+	defer variables.RUnlock()
+	return maps.Clone(variables.values)
+}
+
+// Variable returns the value of the prompt variable name, the templates failing on the unknown variables
+func Variable(name string) (string, error) {
+	variables.RLock()
+	defer variables.RUnlock()
+	value, exists := variables.values[name]
+	if !exists {
+		return "", fmt.Errorf("unknown variable %q", name)
+	}
+	return value, nil
+}
+
+// ExpandVariables replaces the references to the prompt variables in s, {{variable "name"}}, by their values. The
+// values aren't expanded in turn
+func ExpandVariables(s string) (string, error) {
+	var err error
+	expanded := variableRe.ReplaceAllStringFunc(s, func(ref string) string {
+		value, e := Variable(variableRe.FindStringSubmatch(ref)[1])
+		if e != nil && err == nil {
+			err = e
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
@@ -0,0 +1,49 @@
+package templates_test
+
+import (
+	"os"
+
+	"github.com/mudler/LocalAI/pkg/templates"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Prompt variables", func() {
+	BeforeEach(func() {
+		templates.SetVariables(map[string]string{"company": "ACME", "style": "Answer briefly."})
+	})
+
+	AfterEach(func() {
+		templates.SetVariables(nil)
+	})
+
+	It("gives the current values to the templates", func() {
+		dir, err := os.MkdirTemp("", "templates")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		templateCache := templates.NewTemplateCache(dir)
+		template := `{{ variable "style" }} {{.Input}}`
+
+		result, err := templateCache.EvaluateTemplate(1, template, map[string]string{"Input": "Hi"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal("Answer briefly. Hi"))
+
+		// the template is cached, the variable isn't
+		templates.SetVariables(map[string]string{"style": "Answer in detail."})
+		result, err = templateCache.EvaluateTemplate(1, template, map[string]string{"Input": "Hi"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal("Answer in detail. Hi"))
+
+		_, err = templateCache.EvaluateTemplate(1, `{{ variable "unknown" }}`, nil)
+		Expect(err).To(MatchError(ContainSubstring(`unknown variable "unknown"`)))
+	})
+
+	It("expands the references of the texts", func() {
+		Expect(templates.ExpandVariables(`You work for {{variable "company"}}. {{ variable "style" }}`)).To(Equal("You work for ACME. Answer briefly."))
+		Expect(templates.ExpandVariables(`{{ .Input }} {{variable "company"}}`)).To(Equal("{{ .Input }} ACME"))
+		Expect(templates.ExpandVariables("no variables")).To(Equal("no variables"))
+
+		_, err := templates.ExpandVariables(`{{variable "company"}} {{variable "unknown"}}`)
+		Expect(err).To(MatchError(`unknown variable "unknown"`))
+	})
+})
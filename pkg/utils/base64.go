@@ -43,10 +43,9 @@ func GetImageURLAsBase64(s string) (string, error) {
 	}
 
 	// if the string instead is prefixed with "data:image/...;base64,", drop it
-	dropPrefix := []string{"data:image/jpeg;base64,", "data:image/png;base64,"}
-	for _, prefix := range dropPrefix {
-		if strings.HasPrefix(s, prefix) {
-			return strings.ReplaceAll(s, prefix, ""), nil
+	if strings.HasPrefix(s, "data:image/") {
+		if _, data, found := strings.Cut(s, ";base64,"); found {
+			return data, nil
 		}
 	}
 	return "", fmt.Errorf("not valid string")
@@ -0,0 +1,22 @@
+package utils
+
+import "context"
+
+type userKey struct{}
+
+// ContextWithUser attaches the end-user identifier of a request (the OpenAI `user` field) to ctx
+func ContextWithUser(ctx context.Context, user string) context.Context {
+	if user == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the end-user identifier attached to ctx, if any
+func UserFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
@@ -0,0 +1,113 @@
+package utils_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+
+	. "github.com/mudler/LocalAI/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	// white band on the left side
+	for x := 0; x < w/4; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{255, 255, 255, 255})
+		}
+	}
+	return img
+}
+
+var _ = Describe("utils/image tests", func() {
+	It("returns images within the limits unchanged", func() {
+		buf := bytes.Buffer{}
+		Expect(png.Encode(&buf, testImage(40, 20))).To(Succeed())
+
+		out, err := PreprocessImage(buf.Bytes(), ImageLimits{MaxDimension: 100})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out).To(Equal(buf.Bytes()))
+	})
+	It("downscales images keeping the aspect ratio", func() {
+		buf := bytes.Buffer{}
+		Expect(png.Encode(&buf, testImage(400, 100))).To(Succeed())
+
+		out, err := PreprocessImage(buf.Bytes(), ImageLimits{MaxDimension: 200})
+		Expect(err).ToNot(HaveOccurred())
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal("png"))
+		Expect(cfg.Width).To(Equal(200))
+		Expect(cfg.Height).To(Equal(50))
+	})
+	It("rejects images exceeding the pixel limit", func() {
+		buf := bytes.Buffer{}
+		Expect(png.Encode(&buf, testImage(400, 100))).To(Succeed())
+
+		_, err := PreprocessImage(buf.Bytes(), ImageLimits{MaxPixels: 1000})
+		Expect(err).To(MatchError(ContainSubstring("exceeds the limit")))
+	})
+	It("rejects HEIC and invalid images", func() {
+		_, err := PreprocessImage([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), ImageLimits{})
+		Expect(err).To(MatchError(ErrUnsupportedImageFormat))
+		_, err = PreprocessImage([]byte("FOO"), ImageLimits{})
+		Expect(err).To(MatchError(ErrUnsupportedImageFormat))
+	})
+	It("rotates JPEG images according to the EXIF orientation", func() {
+		buf := bytes.Buffer{}
+		Expect(jpeg.Encode(&buf, testImage(40, 20), nil)).To(Succeed())
+		data := buf.Bytes()
+
+		// APP1 segment with a little endian TIFF header and an orientation (0x0112) tag set to 6
+		tiff := []byte{'I', 'I', 0x2A, 0, 8, 0, 0, 0, 1, 0, 0x12, 0x01, 3, 0, 1, 0, 0, 0, 6, 0, 0, 0, 0, 0, 0, 0}
+		segment := append([]byte("Exif\x00\x00"), tiff...)
+		app1 := append([]byte{0xFF, 0xE1, 0, byte(len(segment) + 2)}, segment...)
+		withExif := append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+
+		out, err := PreprocessImage(withExif, ImageLimits{})
+		Expect(err).ToNot(HaveOccurred())
+		img, format, err := image.Decode(bytes.NewReader(out))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal("jpeg"))
+		Expect(img.Bounds().Dx()).To(Equal(20))
+		Expect(img.Bounds().Dy()).To(Equal(40))
+
+		// rotated clockwise: the white band is now on top
+		top, _, _, _ := img.At(10, 2).RGBA()
+		bottom, _, _, _ := img.At(10, 35).RGBA()
+		Expect(top >> 8).To(BeNumerically(">", 200))
+		Expect(bottom >> 8).To(BeNumerically("<", 50))
+	})
+	DescribeTable("ignores the corrupted segments and EXIF data",
+		func(segment []byte) {
+			buf := bytes.Buffer{}
+			Expect(jpeg.Encode(&buf, testImage(40, 20), nil)).To(Succeed())
+			// with a JFIF header, the decoder reads the configuration up to the frame header, and the segment is
+			// inserted after it
+			jfif := []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 0, 0, 1, 0, 1, 0, 0}
+			data := append(append(append([]byte{}, buf.Bytes()[:2]...), jfif...), buf.Bytes()[2:]...)
+
+			sof := bytes.Index(data, []byte{0xFF, 0xC0})
+			Expect(sof).To(BeNumerically(">", 0))
+			end := sof + 2 + int(data[sof+2])<<8 + int(data[sof+3])
+			corrupted := append(append(append([]byte{}, data[:end]...), segment...), data[end:]...)
+
+			out, err := PreprocessImage(corrupted, ImageLimits{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(out).To(Equal(corrupted))
+		},
+		Entry("zero-length segment", []byte{0xFF, 0xE1, 0, 0}),
+		Entry("segment shorter than its size", []byte{0xFF, 0xE1, 0, 1}),
+		Entry("segment longer than the image", []byte{0xFF, 0xE1, 0xFF, 0xFF, 'E', 'x', 'i', 'f'}),
+		Entry("IFD offset outside of the EXIF data", append([]byte{0xFF, 0xE1, 0, 22},
+			append([]byte("Exif\x00\x00"), 'I', 'I', 0x2A, 0, 0xF0, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0, 0, 0)...)),
+		Entry("IFD offset inside the TIFF header", append([]byte{0xFF, 0xE1, 0, 22},
+			append([]byte("Exif\x00\x00"), 'M', 'M', 0, 0x2A, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0)...)),
+		Entry("truncated IFD entries", append([]byte{0xFF, 0xE1, 0, 24},
+			append([]byte("Exif\x00\x00"), 'I', 'I', 0x2A, 0, 8, 0, 0, 0, 5, 0, 0x12, 0x01, 3, 0, 1, 0)...)),
+	)
+})
@@ -1,37 +1,62 @@
 package utils
 
 import (
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
-var lastProgress time.Time = time.Now()
-var startTime time.Time = time.Now()
+// downloadProgress logs the progress of the downloads every 5 seconds, with their ETA
+type downloadProgress struct {
+	sync.Mutex
+	lastProgress time.Time
+	startTime    time.Time
+}
+
+var defaultDownloadProgress = newDownloadProgress()
+
+func newDownloadProgress() *downloadProgress {
+	return &downloadProgress{lastProgress: time.Now(), startTime: time.Now()}
+}
+
+// NewDownloadProgress returns a progress callback with its own timers, for the downloads running concurrently
+func NewDownloadProgress() func(fileName string, current string, total string, percentage float64) {
+	return newDownloadProgress().display
+}
 
 func ResetDownloadTimers() {
-	lastProgress = time.Now()
-	startTime = time.Now()
+	defaultDownloadProgress.Lock()
+	defer defaultDownloadProgress.Unlock()
+	defaultDownloadProgress.lastProgress = time.Now()
+	defaultDownloadProgress.startTime = time.Now()
 }
 
 func DisplayDownloadFunction(fileName string, current string, total string, percentage float64) {
-	currentTime := time.Now()
-
-	if currentTime.Sub(lastProgress) >= 5*time.Second {
+	defaultDownloadProgress.display(fileName, current, total, percentage)
+}
 
-		lastProgress = currentTime
+func (p *downloadProgress) display(fileName string, current string, total string, percentage float64) {
+	currentTime := time.Now()
 
-		// calculate ETA based on percentage and elapsed time
-		var eta time.Duration
-		if percentage > 0 {
-			elapsed := currentTime.Sub(startTime)
-			eta = time.Duration(float64(elapsed)*(100/percentage) - float64(elapsed))
-		}
+	p.Lock()
+	if currentTime.Sub(p.lastProgress) < 5*time.Second {
+		p.Unlock()
+		return
+	}
+	p.lastProgress = currentTime
+	elapsed := currentTime.Sub(p.startTime)
+	p.Unlock()
+
+	// calculate ETA based on percentage and elapsed time
+	var eta time.Duration
+	if percentage > 0 {
+		eta = time.Duration(float64(elapsed)*(100/percentage) - float64(elapsed))
+	}
 
-		if total != "" {
-			log.Info().Msgf("Downloading %s: %s/%s (%.2f%%) ETA: %s", fileName, current, total, percentage, eta)
-		} else {
-			log.Info().Msgf("Downloading: %s", current)
-		}
+	if total != "" {
+		log.Info().Msgf("Downloading %s: %s/%s (%.2f%%) ETA: %s", fileName, current, total, percentage, eta)
+	} else {
+		log.Info().Msgf("Downloading: %s", current)
 	}
 }
@@ -0,0 +1,146 @@
+package xnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrBlocked is returned when dialing a host the guard doesn't allow
+var ErrBlocked = errors.New("outbound connection blocked by the privacy mode")
+
+type dialFunc = func(ctx context.Context, network, address string) (net.Conn, error)
+
+type proxyFunc = func(*http.Request) (*url.URL, error)
+
+// Guard blocks the outbound connections to the hosts which are not allowed, and counts them. The loopback
+// addresses are always allowed
+type Guard struct {
+	hosts    []string
+	networks []*net.IPNet
+
+	sync.Mutex
+	blocked map[string]int
+}
+
+// Report is the audit of the connections blocked by the guard
+type Report struct {
+	AllowedHosts []string       `json:"allowed_hosts"`
+	Blocked      int            `json:"blocked"`
+	BlockedHosts map[string]int `json:"blocked_hosts"`
+}
+
+// NewGuard returns a guard allowing the given hosts: host names, *.domain wildcards, IP addresses and CIDRs
+func NewGuard(allowed []string) (*Guard, error) {
+	g := &Guard{blocked: map[string]int{}}
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "" {
+			continue
+		}
+		if strings.Contains(a, "/") {
+			_, network, err := net.ParseCIDR(a)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowed network %q: %w", a, err)
+			}
+			g.networks = append(g.networks, network)
+			continue
+		}
+		g.hosts = append(g.hosts, a)
+	}
+	return g, nil
+}
+
+// Allowed tells whether connecting to the host is allowed
+func (g *Guard) Allowed(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			return true
+		}
+		for _, n := range g.networks {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, h := range g.hosts {
+		if h == host || (strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// DialContext wraps dial, refusing the connections to the hosts which are not allowed
+func (g *Guard) DialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		if err := g.check(host); err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, address)
+	}
+}
+
+// Proxy wraps the proxy function of an HTTP transport, refusing the requests to the hosts which are not allowed.
+// It guards the transports of the libraries which don't use the default transport
+func (g *Guard) Proxy(proxy proxyFunc) proxyFunc {
+	return func(req *http.Request) (*url.URL, error) {
+		if err := g.check(req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		return proxy(req)
+	}
+}
+
+// check counts the connections to the hosts which are not allowed
+func (g *Guard) check(host string) error {
+	if g.Allowed(host) {
+		return nil
+	}
+	g.Lock()
+	g.blocked[host]++
+	g.Unlock()
+	return fmt.Errorf("%w: %s", ErrBlocked, host)
+}
+
+// Install makes the default HTTP transport, used by the HTTP clients of LocalAI, dial through the guard
+func (g *Guard) Install() error {
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("the default HTTP transport is a %T, it can't be guarded", http.DefaultTransport)
+	}
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	t.DialContext = g.DialContext(dial)
+	return nil
+}
+
+func (g *Guard) Report() Report {
+	g.Lock()
+	defer g.Unlock()
+	r := Report{AllowedHosts: []string{}, BlockedHosts: map[string]int{}}
+	r.AllowedHosts = append(r.AllowedHosts, g.hosts...)
+	for _, n := range g.networks {
+		r.AllowedHosts = append(r.AllowedHosts, n.String())
+	}
+	for host, n := range g.blocked {
+		r.BlockedHosts[host] = n
+		r.Blocked += n
+	}
+	return r
+}
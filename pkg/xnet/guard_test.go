@@ -0,0 +1,76 @@
+package xnet_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/mudler/LocalAI/pkg/xnet"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Guard", func() {
+	It("allows the loopback and the allowed hosts only", func() {
+		g, err := xnet.NewGuard([]string{"huggingface.co", "*.example.com", "10.0.0.0/8"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(g.Allowed("localhost")).To(BeTrue())
+		Expect(g.Allowed("127.0.0.1")).To(BeTrue())
+		Expect(g.Allowed("::1")).To(BeTrue())
+		Expect(g.Allowed("HuggingFace.co")).To(BeTrue())
+		Expect(g.Allowed("models.example.com")).To(BeTrue())
+		Expect(g.Allowed("10.1.2.3")).To(BeTrue())
+
+		Expect(g.Allowed("cdn-lfs.huggingface.co")).To(BeFalse())
+		Expect(g.Allowed("example.com")).To(BeFalse())
+		Expect(g.Allowed("192.168.1.1")).To(BeFalse())
+		Expect(g.Allowed("github.com")).To(BeFalse())
+	})
+
+	It("rejects invalid networks", func() {
+		_, err := xnet.NewGuard([]string{"10.0.0.0/64"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("counts the blocked connections", func() {
+		g, err := xnet.NewGuard(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		dialed := []string{}
+		dial := g.DialContext(func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			return nil, nil
+		})
+
+		_, err = dial(context.Background(), "tcp", "localhost:8080")
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 2; i++ {
+			_, err = dial(context.Background(), "tcp", "github.com:443")
+			Expect(errors.Is(err, xnet.ErrBlocked)).To(BeTrue())
+		}
+
+		Expect(dialed).To(Equal([]string{"localhost:8080"}))
+		report := g.Report()
+		Expect(report.Blocked).To(Equal(2))
+		Expect(report.BlockedHosts).To(Equal(map[string]int{"github.com": 2}))
+	})
+
+	It("blocks the requests of the transports with their own dialer", func() {
+		g, err := xnet.NewGuard([]string{"otel-collector"})
+		Expect(err).ToNot(HaveOccurred())
+		proxy := g.Proxy(func(*http.Request) (*url.URL, error) { return nil, nil })
+
+		for _, allowed := range []string{"http://otel-collector:4318/v1/traces", "http://127.0.0.1:4318/v1/traces"} {
+			req, _ := http.NewRequest(http.MethodPost, allowed, nil)
+			_, err = proxy(req)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		req, _ := http.NewRequest(http.MethodPost, "https://api.honeycomb.io/v1/traces", nil)
+		_, err = proxy(req)
+		Expect(errors.Is(err, xnet.ErrBlocked)).To(BeTrue())
+		Expect(g.Report().BlockedHosts).To(Equal(map[string]int{"api.honeycomb.io": 1}))
+	})
+})
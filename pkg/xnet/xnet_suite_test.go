@@ -0,0 +1,13 @@
+package xnet_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestXNet(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LocalAI network test")
+}
@@ -37,6 +37,17 @@ func HasCPUCaps(ids ...cpuid.FeatureID) bool {
 	return cpuid.CPU.Supports(ids...)
 }
 
+// MissingCPUCaps returns the names of the features the CPU doesn't support
+func MissingCPUCaps(ids ...cpuid.FeatureID) []string {
+	missing := []string{}
+	for _, id := range ids {
+		if !cpuid.CPU.Supports(id) {
+			missing = append(missing, id.String())
+		}
+	}
+	return missing
+}
+
 func CPUPhysicalCores() int {
 	if cpuid.CPU.PhysicalCores == 0 {
 		return 1
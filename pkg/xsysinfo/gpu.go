@@ -1,6 +1,11 @@
 package xsysinfo
 
 import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
 	"github.com/jaypipes/ghw"
 	"github.com/jaypipes/ghw/pkg/gpu"
 )
@@ -13,3 +18,135 @@ func GPUs() ([]*gpu.GraphicsCard, error) {
 
 	return gpu.GraphicsCards, nil
 }
+
+// GPUProcessUsage is the usage of the NVIDIA GPUs by a process
+type GPUProcessUsage struct {
+	Utilization float64 // SM utilization, in percent
+	VRAM        uint64  // bytes
+}
+
+// GPUProcessesUsage returns the GPU usage of the processes running on NVIDIA GPUs, by PID.
+// It returns an empty map when nvidia-smi is not available.
+func GPUProcessesUsage() (map[int]GPUProcessUsage, error) {
+	usage := map[int]GPUProcessUsage{}
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return usage, nil
+	}
+
+	out, err := exec.Command("nvidia-smi", "--query-compute-apps=pid,used_memory", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return usage, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		mib, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		u := usage[pid]
+		u.VRAM += mib << 20
+		usage[pid] = u
+	}
+
+	// # gpu pid type sm mem enc dec command
+	out, err = exec.Command("nvidia-smi", "pmon", "-c", "1", "-s", "u").Output()
+	if err != nil {
+		return usage, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		sm, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			continue
+		}
+		u := usage[pid]
+		u.Utilization += sm
+		usage[pid] = u
+	}
+
+	return usage, nil
+}
+
+// GPUMemory is the memory of a NVIDIA GPU
+type GPUMemory struct {
+	Index int
+	Total uint64 // bytes
+	Free  uint64 // bytes
+}
+
+// GPUsMemory returns the memory of the NVIDIA GPUs visible to the backends: the ones listed in
+// CUDA_VISIBLE_DEVICES, in its order, when it's set. It returns an empty list when nvidia-smi is not available.
+func GPUsMemory() ([]GPUMemory, error) {
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return nil, nil
+	}
+
+	out, err := exec.Command("nvidia-smi", "--query-gpu=index,memory.total,memory.free", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, err
+	}
+	return visibleGPUs(parseGPUsMemory(string(out)), os.Getenv("CUDA_VISIBLE_DEVICES")), nil
+}
+
+func parseGPUsMemory(out string) []GPUMemory {
+	gpus := []GPUMemory{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		total, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+		free, err := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
+		if err != nil {
+			continue
+		}
+		gpus = append(gpus, GPUMemory{Index: index, Total: total << 20, Free: free << 20})
+	}
+	return gpus
+}
+
+// visibleGPUs filters the GPUs by the indexes of CUDA_VISIBLE_DEVICES. The UUIDs are not resolved, all the
+// GPUs are returned then
+func visibleGPUs(gpus []GPUMemory, visible string) []GPUMemory {
+	if visible == "" {
+		return gpus
+	}
+	filtered := []GPUMemory{}
+	for _, v := range strings.Split(visible, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return gpus
+		}
+		// CUDA ignores the devices after an invalid one
+		if index < 0 {
+			break
+		}
+		for _, g := range gpus {
+			if g.Index == index {
+				filtered = append(filtered, g)
+			}
+		}
+	}
+	return filtered
+}
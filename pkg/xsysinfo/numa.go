@@ -0,0 +1,121 @@
+package xsysinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const numaNodesPath = "/sys/devices/system/node"
+
+// NUMANode is a NUMA node of the machine, with its CPUs
+type NUMANode struct {
+	ID         int
+	CPUs       []int
+	FreeMemory uint64 // bytes
+}
+
+// NUMANodes returns the NUMA nodes of the machine, sorted by ID. It returns an empty list when the kernel doesn't
+// report them, e.g. out of Linux.
+func NUMANodes() ([]NUMANode, error) {
+	return readNUMANodes(numaNodesPath)
+}
+
+func readNUMANodes(root string) ([]NUMANode, error) {
+	dirs, err := filepath.Glob(filepath.Join(root, "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	nodes := []NUMANode{}
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		cpuList, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+		cpus, err := ParseCPUList(strings.TrimSpace(string(cpuList)))
+		if err != nil {
+			return nil, fmt.Errorf("NUMA node %d: %w", id, err)
+		}
+		node := NUMANode{ID: id, CPUs: cpus}
+		if meminfo, err := os.ReadFile(filepath.Join(dir, "meminfo")); err == nil {
+			node.FreeMemory = parseNodeMemFree(string(meminfo))
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// parseNodeMemFree reads the free memory of the meminfo of a node, e.g. "Node 0 MemFree: 1234 kB"
+func parseNodeMemFree(meminfo string) uint64 {
+	for _, line := range strings.Split(meminfo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && fields[2] == "MemFree:" {
+			kb, err := strconv.ParseUint(fields[3], 10, 64)
+			if err == nil {
+				return kb << 10
+			}
+		}
+	}
+	return 0
+}
+
+// ParseCPUList parses a list of CPUs in the format of the kernel and of the cpusets, e.g. 0-3,8,10-11. The CPUs
+// are returned sorted, without duplicates
+func ParseCPUList(list string) ([]int, error) {
+	set := map[int]struct{}{}
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid CPU %q in %q", first, list)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU range %q in %q", part, list)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			set[cpu] = struct{}{}
+		}
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("no CPU in %q", list)
+	}
+	cpus := make([]int, 0, len(set))
+	for cpu := range set {
+		cpus = append(cpus, cpu)
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// FormatCPUList formats sorted CPUs as a list in the format of the kernel, e.g. 0-3,8
+func FormatCPUList(cpus []int) string {
+	parts := []string{}
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}